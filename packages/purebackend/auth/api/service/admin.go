@@ -2,13 +2,18 @@ package service
 
 import (
 	// "fmt"
+	"encoding/json"
 	"net/http"
+	"time"
 
 	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/config"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/gc"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
 	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
 )
 
 // BindAdminApi registers the admin api endpoints and the corresponding handlers.
@@ -17,6 +22,9 @@ func BindAdminApi(app core.App, rg *echo.Group) {
 
 	orgGroup := rg.Group("/org", authmiddlewares.RequireAuthContext)
 	orgGroup.GET("/all", api.DefaultHandler(GetAllAdminOrgs))
+
+	adminGroup := rg.Group("/admin", authmiddlewares.RequireAuthContext)
+	adminGroup.POST("/gc", api.DefaultHandler(RunStorageGC))
 }
 
 // GetAllAdminOrgs godoc
@@ -49,3 +57,66 @@ func (api *Api) GetAllAdminOrgs(request *models.Request) *models.Response {
 }
 
 var GetAllAdminOrgs ServiceFunc = (*Api).GetAllAdminOrgs
+
+// RunStorageGC godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Collect orphaned storage objects.
+//	@Description	Delete the storage objects that are no longer referenced by any version, log or blob and are older than the grace period. Runs for all organizations if org_id is empty. Only accessible by admins.
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/admin/gc [post]
+//	@Param			options	body	commonmodels.GCRequest	true	"Garbage collector options"
+func (api *Api) RunStorageGC(request *models.Request) *models.Response {
+	if request.User == nil {
+		return models.NewErrorResponse(http.StatusUnauthorized, "Unauthorized")
+	}
+	if !config.HasAdminAccess(request.User.Email) {
+		return models.NewErrorResponse(http.StatusForbidden, "Forbidden")
+	}
+	var gcRequest commonmodels.GCRequest
+	if len(request.Body) > 0 {
+		if err := json.Unmarshal(request.Body, &gcRequest); err != nil {
+			return models.NewErrorResponse(http.StatusBadRequest, "Invalid request body")
+		}
+	}
+	options := gc.Options{
+		DryRun:      gcRequest.DryRun,
+		GracePeriod: gc.DefaultGracePeriod,
+	}
+	if gcRequest.GracePeriod != nil {
+		// objects being uploaded are not referenced yet, a grace period
+		// keeps them from being collected
+		if *gcRequest.GracePeriod <= 0 {
+			return models.NewErrorResponse(http.StatusBadRequest, "Grace period must be positive")
+		}
+		options.GracePeriod = time.Duration(*gcRequest.GracePeriod) * time.Second
+	}
+	if gcRequest.OrgId == "" {
+		reports, err := gc.RunAll(api.app, options)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		return models.NewDataResponse(http.StatusOK, reports, "Storage garbage collection completed")
+	}
+	orgId, err := uuid.FromString(gcRequest.OrgId)
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid organization id")
+	}
+	org, err := api.app.Dao().GetOrgById(orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if org == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Organization not found")
+	}
+	report, err := gc.Run(api.app, orgId, options)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, []*models.GCReportResponse{report}, "Storage garbage collection completed")
+}
+
+var RunStorageGC ServiceFunc = (*Api).RunStorageGC
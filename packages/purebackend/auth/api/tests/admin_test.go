@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	impl "github.com/PureMLHQ/PureML/packages/purebackend/core/daos/datastore"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	gcOrphanKey = fmt.Sprintf("model-registry/%s/models/orphan/model.pkl", test.ValidAdminUserOrgUuid)
+	gcBlobKey   = impl.BlobKey(test.ValidAdminUserOrgUuid, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08")
+)
+
+// seedStorageObjects stores an orphaned object and a referenced blob in the
+// LOCAL storage of the admin org an hour ago.
+func seedStorageObjects(t *testing.T, app *test.TestApp) {
+	fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err := fs.Upload([]byte("orphan"), gcOrphanKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Upload([]byte("test"), gcBlobKey); err != nil {
+		t.Fatal(err)
+	}
+	blob, _, err := app.Dao().AcquireBlob(test.ValidAdminUserOrgUuid, "LOCAL", "", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Dao().CompleteBlob(blob.UUID); err != nil {
+		t.Fatal(err)
+	}
+	// the objects were stored an hour ago
+	storedAt := time.Now().Add(-time.Hour)
+	for _, key := range []string{gcOrphanKey, gcBlobKey} {
+		if err := os.Chtimes(app.LocalStorageDir()+"/"+key, storedAt, storedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func localStorageObjectExists(app *test.TestApp, key string) bool {
+	_, err := os.Stat(app.LocalStorageDir() + "/" + key)
+	return err == nil
+}
+
+func TestRunStorageGC(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "run storage gc + unauthorized",
+			Method:         http.MethodPost,
+			Url:            "/api/admin/gc",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "run storage gc + valid token + not admin user",
+			Method: http.MethodPost,
+			Url:    "/api/admin/gc",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"Forbidden"`,
+			},
+		},
+		{
+			Name:   "run storage gc + valid token + invalid org id",
+			Method: http.MethodPost,
+			Url:    "/api/admin/gc",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"org_id":"invalid"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid organization id"`,
+			},
+		},
+		{
+			Name:   "run storage gc + valid token + no grace period",
+			Method: http.MethodPost,
+			Url:    "/api/admin/gc",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"grace_period":0}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Grace period must be positive"`,
+			},
+		},
+		{
+			Name:   "run storage gc + valid token + grace period not elapsed",
+			Method: http.MethodPost,
+			Url:    "/api/admin/gc",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedStorageObjects(t, app)
+			},
+			Body:           strings.NewReader(`{"org_id":"` + test.ValidAdminUserOrgUuid.String() + `"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"scanned":2`,
+				`"orphans":[]`,
+				`"deleted":0`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if !localStorageObjectExists(app, gcOrphanKey) {
+					t.Fatal("Expected recent object to be kept")
+				}
+			},
+		},
+		{
+			Name:   "run storage gc + valid token + dry run",
+			Method: http.MethodPost,
+			Url:    "/api/admin/gc",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedStorageObjects(t, app)
+			},
+			Body:           strings.NewReader(`{"org_id":"` + test.ValidAdminUserOrgUuid.String() + `","dry_run":true,"grace_period":60}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"dry_run":true`,
+				`"scanned":2`,
+				`"key":"` + gcOrphanKey + `"`,
+				`"deleted":0`,
+				`"message":"Storage garbage collection completed"`,
+			},
+			NotExpectedContent: []string{
+				`"key":"` + gcBlobKey + `"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if !localStorageObjectExists(app, gcOrphanKey) {
+					t.Fatal("Expected dry run to keep the orphaned object")
+				}
+			},
+		},
+		{
+			Name:   "run storage gc + valid token + orphans deleted",
+			Method: http.MethodPost,
+			Url:    "/api/admin/gc",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedStorageObjects(t, app)
+			},
+			Body:           strings.NewReader(`{"grace_period":60}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"dry_run":false`,
+				`"key":"` + gcOrphanKey + `"`,
+				`"deleted":1`,
+				`"reclaimed_bytes":6`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if localStorageObjectExists(app, gcOrphanKey) {
+					t.Fatal("Expected orphaned object to be deleted")
+				}
+				if !localStorageObjectExists(app, gcBlobKey) {
+					t.Fatal("Expected referenced blob to be kept")
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
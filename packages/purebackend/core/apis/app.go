@@ -11,6 +11,9 @@ import (
 
 	"github.com/PureMLHQ/PureML/packages/purebackend/core"
 	config "github.com/PureMLHQ/PureML/packages/purebackend/core/config"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/gc"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/stream"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/trash"
 	"github.com/fatih/color"
 	"github.com/labstack/echo/v4/middleware"
 	"golang.org/x/crypto/acme"
@@ -38,6 +41,31 @@ func Serve(app core.App, hideStartBanner bool) error {
 		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
 	}))
 
+	// fail the imports interrupted by the last shutdown
+	if err := app.Dao().FailInterruptedImportJobs(); err != nil {
+		log.Println("import:", err)
+	}
+
+	// start the storage garbage collector
+	if gcSettings := app.Settings().GC; gcSettings.Enabled {
+		gracePeriod := gc.DefaultGracePeriod
+		if gcSettings.GracePeriod > 0 {
+			gracePeriod = time.Duration(gcSettings.GracePeriod) * time.Second
+		}
+		stopGC := gc.Start(app, time.Duration(gcSettings.Interval)*time.Second, gc.Options{
+			GracePeriod: gracePeriod,
+		})
+		defer stopGC()
+	}
+
+	// start the purge of the expired trash items
+	stopTrash := trash.Start(app, time.Duration(app.Settings().Trash.Interval)*time.Second)
+	defer stopTrash()
+
+	// start the purge of the expired stream events
+	stopStream := stream.Start(app, time.Duration(app.Settings().Stream.Interval)*time.Second)
+	defer stopStream()
+
 	// start http server
 	// ---
 	mainAddr := httpAddr
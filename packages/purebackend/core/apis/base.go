@@ -53,22 +53,48 @@ func InitApi(app core.App) (*echo.Echo, error) {
 	modelservice.BindModelApi(app, rg)
 	modelservice.BindModelReadmeApi(app, rg)
 	modelservice.BindModelBranchApi(app, rg)
+	modelservice.BindModelBranchProtectionApi(app, rg)
 	modelservice.BindModelBranchVersionApi(app, rg)
 	modelservice.BindModelReviewApi(app, rg)
 	modelservice.BindModelLogsApi(app, rg)
+	modelservice.BindModelMetricApi(app, rg)
+	modelservice.BindModelStreamApi(app, rg)
+	modelservice.BindModelLeaderboardApi(app, rg)
 	modelservice.BindModelActivityApi(app, rg)
+	modelservice.BindModelUploadApi(app, rg)
+	modelservice.BindModelPresignedApi(app, rg)
+	modelservice.BindModelFilesApi(app, rg)
+	modelservice.BindModelImportApi(app, rg)
+	modelservice.BindModelAliasApi(app, rg)
+	modelservice.BindModelTagApi(app, rg)
+	modelservice.BindModelStageApi(app, rg)
+	modelservice.BindModelTrashApi(app, rg)
+	modelservice.BindModelCompareApi(app, rg)
 
 	//Dataset APIs
 	datasetservice.BindDatasetApi(app, rg)
 	datasetservice.BindDatasetReadmeApi(app, rg)
 	datasetservice.BindDatasetBranchApi(app, rg)
+	datasetservice.BindDatasetBranchProtectionApi(app, rg)
 	datasetservice.BindDatasetBranchVersionApi(app, rg)
 	datasetservice.BindDatasetReviewApi(app, rg)
 	datasetservice.BindDatasetLogsApi(app, rg)
+	datasetservice.BindDatasetMetricApi(app, rg)
+	datasetservice.BindDatasetStreamApi(app, rg)
 	datasetservice.BindDatasetActivityApi(app, rg)
+	datasetservice.BindDatasetUploadApi(app, rg)
+	datasetservice.BindDatasetPresignedApi(app, rg)
+	datasetservice.BindDatasetFilesApi(app, rg)
+	datasetservice.BindDatasetImportApi(app, rg)
+	datasetservice.BindDatasetAliasApi(app, rg)
+	datasetservice.BindDatasetTagApi(app, rg)
+	datasetservice.BindDatasetTrashApi(app, rg)
+	datasetservice.BindDatasetCompareApi(app, rg)
 
 	//Secret APIs
 	userorgservice.BindSecretsApi(app, rg)
+	userorgservice.BindStorageMigrationApi(app, rg)
+	userorgservice.BindTrashApi(app, rg)
 
 	return e, nil
 }
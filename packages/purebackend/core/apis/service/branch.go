@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+)
+
+// ValidateBranchName returns the error response of a name that can't be
+// given to a new or renamed branch of a model or dataset, nil if it can.
+// isTaken reports whether a branch, in the trash or not, has the name.
+func ValidateBranchName(name string, isTaken func(name string) (bool, error)) *models.Response {
+	if name == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Branch name cannot be empty")
+	}
+	taken, err := isTaken(name)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if taken {
+		return models.NewErrorResponse(http.StatusBadRequest, "Branch already exists")
+	}
+	return nil
+}
+
+// UpdateBranch renames the branch of the request to the branch_name of its
+// body and makes it the default branch if is_default is set, for both the
+// model and dataset handlers. subject is Model or Dataset, name and
+// isDefault are the current name and default state of the branch, and
+// update stores the new name (empty to keep it) and default state.
+func UpdateBranch(request *models.Request, subject string, name string, isDefault bool, isTaken func(name string) (bool, error), update func(name string, isDefault bool) (interface{}, error)) *models.Response {
+	request.ParseJsonBody()
+	branchNameAttr := request.GetParsedBodyAttribute("branch_name")
+	makeDefault, makeDefaultSet := request.GetParsedBodyAttribute("is_default").(bool)
+	if branchNameAttr == nil && !makeDefaultSet {
+		return models.NewErrorResponse(http.StatusBadRequest, "Nothing to update")
+	}
+	if makeDefaultSet && !makeDefault && isDefault {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("%s needs a default branch, make another branch the default instead", subject))
+	}
+	var branchName string
+	if branchNameAttr != nil {
+		branchName, _ = branchNameAttr.(string)
+		if branchName == name {
+			branchName = ""
+		} else if errresp := ValidateBranchName(branchName, isTaken); errresp != nil {
+			return errresp
+		}
+	}
+	branch, err := update(branchName, makeDefault)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, branch, fmt.Sprintf("%s branch updated", subject))
+}
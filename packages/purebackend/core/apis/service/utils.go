@@ -20,6 +20,20 @@ import (
 )
 
 func ExtractRequest(context echo.Context) *models.Request {
+	request := ExtractStreamRequest(context)
+	// if content type is multipart formdata
+	contentType := strings.Split(context.Request().Header.Get("Content-Type"), ";")[0]
+	if contentType == "multipart/form-data" {
+		request.FormValues, request.FormFiles = extractFormData(context)
+	} else {
+		request.Body = extractBody(context)
+	}
+	return request
+}
+
+// ExtractStreamRequest extracts the request like ExtractRequest but leaves
+// its body unread, so that handlers can stream it.
+func ExtractStreamRequest(context echo.Context) *models.Request {
 	request := &models.Request{}
 	if context.Get(authmiddlewares.ContextAuthKey) != nil {
 		request.User = context.Get(authmiddlewares.ContextAuthKey).(*userorgmodels.UserClaims)
@@ -64,13 +78,6 @@ func ExtractRequest(context echo.Context) *models.Request {
 	request.Headers = extractHeaders(context)
 	request.PathParams = extractPathParams(context)
 	request.QueryParams = extractQueryParams(context)
-	// if content type is multipart formdata
-	contentType := strings.Split(context.Request().Header.Get("Content-Type"), ";")[0]
-	if contentType == "multipart/form-data" {
-		request.FormValues, request.FormFiles = extractFormData(context)
-	} else {
-		request.Body = extractBody(context)
-	}
 	return request
 }
 
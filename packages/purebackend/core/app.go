@@ -1,6 +1,8 @@
 package core
 
 import (
+	"hash"
+
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/daos"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/settings"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/filesystem"
@@ -33,8 +35,18 @@ type App interface {
 	// after you are done working with it.
 	NewFilesystem(sourceSecrets *commonmodels.SourceSecrets) (*filesystem.System, error)
 
-	// UploadFile uploads a file to the app storage.
-	UploadFile(file *filesystem.File, basePath string, sourceSecrets *commonmodels.SourceSecrets) (string, error)
+	// LocalStorageDir returns the directory where the LOCAL source files are stored.
+	LocalStorageDir() string
+
+	// ManagedStorageSecrets returns the source secrets of the instance wide
+	// PUREML-STORAGE bucket configured in the app settings.
+	ManagedStorageSecrets() (*commonmodels.SourceSecrets, error)
+
+	// UploadFile uploads a file to the app storage, encrypted with dataKey
+	// unless it is empty.
+	//
+	// The optional hashes are fed with the file content while uploading.
+	UploadFile(file *filesystem.File, basePath string, sourceSecrets *commonmodels.SourceSecrets, dataKey string, hashes ...hash.Hash) (string, error)
 
 	// NewSearchClient creates and returns a configured search.SearchClient instance.
 	NewSearchClient() *search.SearchClient
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"hash"
 	"os"
 	"path/filepath"
 	"strings"
@@ -34,6 +36,10 @@ func Cleanup(optDataDir ...string) error {
 
 var _ App = (*BaseApp)(nil)
 
+// ErrManagedStorageDisabled is returned when PUREML-STORAGE is requested
+// but neither S3 nor R2 is enabled in the app settings.
+var ErrManagedStorageDisabled = errors.New("pureml storage is not configured for this instance")
+
 // BaseApp implements core.App and defines the base PureBackend app structure.
 type BaseApp struct {
 	// configurable parameters
@@ -79,6 +85,17 @@ func NewBaseApp(appConfig *BaseAppConfig) *BaseApp {
 		if appConfig.Settings.Search.Enabled {
 			app.settings.Search = appConfig.Settings.Search
 		}
+		if appConfig.Settings.GC.Enabled {
+			app.settings.GC = appConfig.Settings.GC
+		}
+		if appConfig.Settings.Encryption.MasterKey != "" {
+			app.settings.Encryption = appConfig.Settings.Encryption
+		}
+		app.settings.Import = appConfig.Settings.Import
+		app.settings.Trash = appConfig.Settings.Trash
+		app.settings.Logs = appConfig.Settings.Logs
+		app.settings.Stream = appConfig.Settings.Stream
+		app.settings.Upload = appConfig.Settings.Upload
 		if appConfig.Settings.AdminAuthToken.Secret != "" {
 			app.settings.AdminAuthToken = appConfig.Settings.AdminAuthToken
 		}
@@ -199,38 +216,111 @@ func (app *BaseApp) Settings() *settings.Settings {
 }
 
 // NewFilesystem creates a new local or Object storage filesystem instance
-// based on the current app settings.
+// based on the source secrets.
+//
+// LOCAL sources are stored under `<DataDir>/storage` and PUREML-STORAGE
+// sources use the instance managed bucket configured in the app settings.
 //
 // NB! Make sure to call `Close()` on the returned result
 // after you are done working with it.
 func (app *BaseApp) NewFilesystem(sourceSecrets *commonmodels.SourceSecrets) (*filesystem.System, error) {
 	switch strings.ToUpper(sourceSecrets.SourceType) {
+	case "LOCAL":
+		return filesystem.NewLocal(app.LocalStorageDir())
+	case "PUREML-STORAGE":
+		switch {
+		case app.settings.S3.Enabled:
+			return filesystem.NewS3(
+				app.settings.S3.Bucket,
+				app.settings.S3.Region,
+				app.settings.S3.Endpoint,
+				app.settings.S3.AccessKey,
+				app.settings.S3.Secret,
+				app.settings.S3.ForcePathStyle,
+			)
+		case app.settings.R2.Enabled:
+			return filesystem.NewR2(
+				app.settings.R2.AccountId,
+				app.settings.R2.Bucket,
+				app.settings.R2.Endpoint,
+				app.settings.R2.AccessKey,
+				app.settings.R2.Secret,
+				app.settings.R2.ForcePathStyle,
+			)
+		}
+		return nil, ErrManagedStorageDisabled
 	case "S3":
 		return filesystem.NewS3(
-			app.settings.S3.Bucket,
-			app.settings.S3.Region,
-			app.settings.S3.Endpoint,
-			app.settings.S3.AccessKey,
-			app.settings.S3.Secret,
-			app.settings.S3.ForcePathStyle,
+			sourceSecrets.BucketName,
+			sourceSecrets.BucketLocation,
+			sourceSecrets.Endpoint,
+			sourceSecrets.AccessKeyId,
+			sourceSecrets.AccessKeySecret,
+			// custom S3 compatible endpoints usually don't support virtual-hosted buckets
+			sourceSecrets.Endpoint != "",
 		)
 	case "R2":
 		return filesystem.NewR2(
-			app.settings.R2.AccountId,
-			app.settings.R2.Bucket,
-			app.settings.R2.Endpoint,
-			app.settings.R2.AccessKey,
-			app.settings.R2.Secret,
-			app.settings.R2.ForcePathStyle,
+			sourceSecrets.AccountId,
+			sourceSecrets.BucketName,
+			sourceSecrets.Endpoint,
+			sourceSecrets.AccessKeyId,
+			sourceSecrets.AccessKeySecret,
+			false,
 		)
 	default:
-		// fallback to local filesystem
-		return filesystem.NewLocal(filepath.Join(app.DataDir(), "storage"))
+		return nil, fmt.Errorf("unsupported storage source %q", sourceSecrets.SourceType)
 	}
 }
 
-// UploadFile uploads a file to the app storage.
-func (app *BaseApp) UploadFile(file *filesystem.File, basePath string, sourceSecrets *commonmodels.SourceSecrets) (string, error) {
+// LocalStorageDir returns the directory where the LOCAL source files are stored.
+func (app *BaseApp) LocalStorageDir() string {
+	return filepath.Join(app.DataDir(), "storage")
+}
+
+// ManagedStorageSecrets returns the source secrets of the instance wide
+// PUREML-STORAGE bucket configured in the S3 (or R2) app settings.
+func (app *BaseApp) ManagedStorageSecrets() (*commonmodels.SourceSecrets, error) {
+	switch {
+	case app.settings.S3.Enabled:
+		s3 := app.settings.S3
+		publicURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s3.Bucket, s3.Region)
+		if s3.Endpoint != "" {
+			publicURL = strings.TrimSuffix(s3.Endpoint, "/") + "/" + s3.Bucket
+		}
+		return &commonmodels.SourceSecrets{
+			SourceType:      "PUREML-STORAGE",
+			AccessKeyId:     s3.AccessKey,
+			AccessKeySecret: s3.Secret,
+			BucketName:      s3.Bucket,
+			BucketLocation:  s3.Region,
+			Endpoint:        s3.Endpoint,
+			PublicURL:       publicURL,
+		}, nil
+	case app.settings.R2.Enabled:
+		r2 := app.settings.R2
+		publicURL := fmt.Sprintf("https://%s.r2.cloudflarestorage.com/%s", r2.AccountId, r2.Bucket)
+		if r2.Endpoint != "" {
+			publicURL = strings.TrimSuffix(r2.Endpoint, "/")
+		}
+		return &commonmodels.SourceSecrets{
+			SourceType:      "PUREML-STORAGE",
+			AccountId:       r2.AccountId,
+			AccessKeyId:     r2.AccessKey,
+			AccessKeySecret: r2.Secret,
+			BucketName:      r2.Bucket,
+			Endpoint:        r2.Endpoint,
+			PublicURL:       publicURL,
+		}, nil
+	}
+	return nil, ErrManagedStorageDisabled
+}
+
+// UploadFile uploads a file to the app storage, encrypted with dataKey
+// unless it is empty.
+//
+// The optional hashes are fed with the file content while uploading.
+func (app *BaseApp) UploadFile(file *filesystem.File, basePath string, sourceSecrets *commonmodels.SourceSecrets, dataKey string, hashes ...hash.Hash) (string, error) {
 	fs, err := app.NewFilesystem(sourceSecrets)
 	if err != nil {
 		return "", err
@@ -238,7 +328,7 @@ func (app *BaseApp) UploadFile(file *filesystem.File, basePath string, sourceSec
 	defer fs.Close()
 
 	path := basePath + "/" + file.Name
-	if err := fs.UploadFile(file, path); err != nil {
+	if err := fs.Encrypted(dataKey).UploadFile(file, path, hashes...); err != nil {
 		return "", err
 	}
 	return path, nil
@@ -0,0 +1,37 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/settings"
+)
+
+func TestNewBaseAppSettings(t *testing.T) {
+	app := core.NewBaseApp(&core.BaseAppConfig{
+		Settings: &settings.Settings{
+			Import: settings.ImportConfig{Timeout: 30},
+			Trash:  settings.TrashConfig{Retention: 3600, Interval: 60},
+			Logs:   settings.LogsConfig{MaxBatchSize: 1024},
+			Stream: settings.StreamConfig{Retention: 600, Interval: 30, PollInterval: 250},
+			Upload: settings.UploadConfig{MaxChunkSize: 2048},
+		},
+	})
+
+	appSettings := app.Settings()
+	if appSettings.Import.Timeout != 30 {
+		t.Errorf("Expected import timeout 30, got %d", appSettings.Import.Timeout)
+	}
+	if appSettings.Trash.Retention != 3600 || appSettings.Trash.Interval != 60 {
+		t.Errorf("Expected trash retention 3600 and interval 60, got %v", appSettings.Trash)
+	}
+	if appSettings.Logs.MaxBatchSize != 1024 {
+		t.Errorf("Expected logs max batch size 1024, got %d", appSettings.Logs.MaxBatchSize)
+	}
+	if appSettings.Stream.Retention != 600 || appSettings.Stream.Interval != 30 || appSettings.Stream.PollInterval != 250 {
+		t.Errorf("Expected stream retention 600, interval 30 and poll interval 250, got %v", appSettings.Stream)
+	}
+	if appSettings.Upload.MaxChunkSize != 2048 {
+		t.Errorf("Expected upload max chunk size 2048, got %d", appSettings.Upload.MaxChunkSize)
+	}
+}
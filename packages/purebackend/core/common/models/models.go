@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -11,9 +13,75 @@ type ReadmeRequest struct {
 	Content  string `json:"content"`
 }
 
+type UploadSessionRequest struct {
+	FileName string `json:"file_name"`
+	Storage  string `json:"storage"`
+}
+
+type ConfirmUploadRequest struct {
+	Hash          string `json:"hash"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	Size          int64  `json:"size"`
+	Lineage       string `json:"lineage"`
+}
+
+type FinalizeUploadRequest struct {
+	Hash          string `json:"hash"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	Lineage       string `json:"lineage"`
+}
+
 type LogRequest struct {
 	Key  string `json:"key"`
 	Data string `json:"data"`
+	Type string `json:"type"`
+}
+
+type LogsRequest struct {
+	Logs []LogRequest `json:"logs"`
+}
+
+// MetricRequest is a numeric value of a metric key. The timestamp defaults
+// to the time the metric is received.
+type MetricRequest struct {
+	Key       string     `json:"key"`
+	Value     *float64   `json:"value"`
+	Step      *int64     `json:"step"`
+	Timestamp *time.Time `json:"timestamp"`
+}
+
+type MetricsRequest struct {
+	Metrics []MetricRequest `json:"metrics"`
+}
+
+type GCRequest struct {
+	OrgId       string `json:"org_id"`
+	DryRun      bool   `json:"dry_run"`
+	GracePeriod *int64 `json:"grace_period"`
+}
+
+type StorageMigrationRequest struct {
+	Source   string   `json:"source"`
+	Target   string   `json:"target"`
+	Models   []string `json:"models"`
+	Datasets []string `json:"datasets"`
+}
+
+type ImportRequest struct {
+	URL           string `json:"url"`
+	Hash          string `json:"hash"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	Storage       string `json:"storage"`
+	Lineage       string `json:"lineage"`
+}
+
+type VersionAliasRequest struct {
+	Branch  string `json:"branch"`
+	Version string `json:"version"`
+}
+
+type VersionTagsRequest struct {
+	Tags []string `json:"tags"`
 }
 
 // Response models
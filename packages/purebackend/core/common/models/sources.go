@@ -49,6 +49,17 @@ type SourceSecrets struct {
 	BucketLocation  string `json:"bucket_location"`
 	PublicURL       string `json:"public_url"`
 	SourceType      string `json:"source_type"`
+	// Endpoint overrides the default S3 endpoint (eg. for S3 compatible storages)
+	Endpoint string `json:"endpoint"`
+}
+
+// DataKey is the key encrypting a stored file. Only the wrapped key
+// (encrypted with the organization key of KeyVersion) is persisted,
+// Key is the unwrapped key and is empty for plain files.
+type DataKey struct {
+	Key        string `json:"-"`
+	WrappedKey string `json:"-"`
+	KeyVersion int    `json:"-"`
 }
 
 var SupportedSources = []string{"S3", "R2", "LOCAL", "PUREML-STORAGE"}
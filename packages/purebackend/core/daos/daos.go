@@ -2,6 +2,7 @@ package daos
 
 import (
 	"errors"
+	"time"
 
 	authmodels "github.com/PureMLHQ/PureML/packages/purebackend/auth/models"
 	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
@@ -218,7 +219,11 @@ func (dao *Dao) CreateModelBranch(modelUUID uuid.UUID, branchName string) (*mode
 	return dao.Datastore().CreateModelBranch(modelUUID, branchName)
 }
 
-func (dao *Dao) CreateModelBranches(modelUUID uuid.UUID, branchNames []string) ([]modelmodels.ModelBranchResponse, error) {
+func (dao *Dao) CreateModelBranchFromVersion(modelUUID uuid.UUID, branchName string, baseVersionUUID uuid.UUID) (*modelmodels.ModelBranchResponse, error) {
+	return dao.Datastore().CreateModelBranchFromVersion(modelUUID, branchName, baseVersionUUID)
+}
+
+func (dao *Dao) CreateModelBranches(orgId uuid.UUID, modelUUID uuid.UUID, branchNames []string, userUUID uuid.UUID) ([]modelmodels.ModelBranchResponse, error) {
 	var branches []modelmodels.ModelBranchResponse
 
 	for _, branchName := range branchNames {
@@ -226,14 +231,38 @@ func (dao *Dao) CreateModelBranches(modelUUID uuid.UUID, branchNames []string) (
 		if err != nil {
 			return nil, err
 		}
+		// main is the default branch of a new model and only receives
+		// versions through reviews
+		if branchName == "main" {
+			branch, err = dao.UpdateModelBranch(modelUUID, branch.UUID, "", true)
+			if err != nil {
+				return nil, err
+			}
+			_, err = dao.SetModelBranchProtection(orgId, branch.UUID, models.BranchProtectionRules{BlockDirectRegistration: true}, userUUID)
+			if err != nil {
+				return nil, err
+			}
+		}
 		branches = append(branches, *branch)
 	}
 
 	return branches, nil
 }
 
-func (dao *Dao) RegisterModelFile(modelBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, path string, isEmpty bool, hash string, userUUID uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
-	return dao.Datastore().RegisterModelFile(modelBranchUUID, sourceType, sourcePublicURL, path, isEmpty, hash, userUUID)
+func (dao *Dao) RegisterModelFile(modelBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, path string, isEmpty bool, hash string, hashAlgorithm string, digest string, fileName string, blobUUID uuid.NullUUID, dataKey *commonmodels.DataKey, userUUID uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
+	return dao.Datastore().RegisterModelFile(modelBranchUUID, sourceType, sourcePublicURL, path, isEmpty, hash, hashAlgorithm, digest, fileName, blobUUID, dataKey, userUUID)
+}
+
+func (dao *Dao) RegisterModelReference(modelBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, key string, hash string, hashAlgorithm string, digest string, size int64, etag string, userUUID uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
+	return dao.Datastore().RegisterModelReference(modelBranchUUID, sourceType, sourcePublicURL, key, hash, hashAlgorithm, digest, size, etag, userUUID)
+}
+
+func (dao *Dao) RegisterModelFiles(modelBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, manifestDigest string, files []models.VersionFileResponse, userUUID uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
+	return dao.Datastore().RegisterModelFiles(modelBranchUUID, sourceType, sourcePublicURL, manifestDigest, files, userUUID)
+}
+
+func (dao *Dao) GetModelVersionFiles(modelVersionUUID uuid.UUID) ([]models.VersionFileResponse, error) {
+	return dao.Datastore().GetModelVersionFiles(modelVersionUUID)
 }
 
 func (dao *Dao) GetModelAllBranches(modelUUID uuid.UUID) ([]modelmodels.ModelBranchResponse, error) {
@@ -252,6 +281,10 @@ func (dao *Dao) GetModelBranchByUUID(modelBranchUUID uuid.UUID) (*modelmodels.Mo
 	return dao.Datastore().GetModelBranchByUUID(modelBranchUUID)
 }
 
+func (dao *Dao) FindModelVersionHash(modelBranchUUID uuid.UUID, hash string) (bool, bool, error) {
+	return dao.Datastore().FindModelVersionHash(modelBranchUUID, hash)
+}
+
 func (dao *Dao) GetModelBranchAllVersions(modelBranchUUID uuid.UUID, withLogs bool) ([]modelmodels.ModelBranchVersionResponse, error) {
 	return dao.Datastore().GetModelBranchAllVersions(modelBranchUUID, withLogs)
 }
@@ -280,7 +313,11 @@ func (dao *Dao) CreateDatasetBranch(datasetUUID uuid.UUID, branchName string) (*
 	return dao.Datastore().CreateDatasetBranch(datasetUUID, branchName)
 }
 
-func (dao *Dao) CreateDatasetBranches(datasetUUID uuid.UUID, branchNames []string) ([]datasetmodels.DatasetBranchResponse, error) {
+func (dao *Dao) CreateDatasetBranchFromVersion(datasetUUID uuid.UUID, branchName string, baseVersionUUID uuid.UUID) (*datasetmodels.DatasetBranchResponse, error) {
+	return dao.Datastore().CreateDatasetBranchFromVersion(datasetUUID, branchName, baseVersionUUID)
+}
+
+func (dao *Dao) CreateDatasetBranches(orgId uuid.UUID, datasetUUID uuid.UUID, branchNames []string, userUUID uuid.UUID) ([]datasetmodels.DatasetBranchResponse, error) {
 	var branches []datasetmodels.DatasetBranchResponse
 
 	for _, branchName := range branchNames {
@@ -288,14 +325,38 @@ func (dao *Dao) CreateDatasetBranches(datasetUUID uuid.UUID, branchNames []strin
 		if err != nil {
 			return nil, err
 		}
+		// main is the default branch of a new dataset and only receives
+		// versions through reviews
+		if branchName == "main" {
+			branch, err = dao.UpdateDatasetBranch(datasetUUID, branch.UUID, "", true)
+			if err != nil {
+				return nil, err
+			}
+			_, err = dao.SetDatasetBranchProtection(orgId, branch.UUID, models.BranchProtectionRules{BlockDirectRegistration: true}, userUUID)
+			if err != nil {
+				return nil, err
+			}
+		}
 		branches = append(branches, *branch)
 	}
 
 	return branches, nil
 }
 
-func (dao *Dao) RegisterDatasetFile(datasetBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, path string, isEmpty bool, hash string, lineage string, userUUID uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
-	return dao.Datastore().RegisterDatasetFile(datasetBranchUUID, sourceType, sourcePublicURL, path, isEmpty, hash, lineage, userUUID)
+func (dao *Dao) RegisterDatasetFile(datasetBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, path string, isEmpty bool, hash string, hashAlgorithm string, digest string, fileName string, blobUUID uuid.NullUUID, dataKey *commonmodels.DataKey, lineage string, userUUID uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
+	return dao.Datastore().RegisterDatasetFile(datasetBranchUUID, sourceType, sourcePublicURL, path, isEmpty, hash, hashAlgorithm, digest, fileName, blobUUID, dataKey, lineage, userUUID)
+}
+
+func (dao *Dao) RegisterDatasetReference(datasetBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, key string, hash string, hashAlgorithm string, digest string, size int64, etag string, lineage string, userUUID uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
+	return dao.Datastore().RegisterDatasetReference(datasetBranchUUID, sourceType, sourcePublicURL, key, hash, hashAlgorithm, digest, size, etag, lineage, userUUID)
+}
+
+func (dao *Dao) RegisterDatasetFiles(datasetBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, manifestDigest string, files []models.VersionFileResponse, lineage string, userUUID uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
+	return dao.Datastore().RegisterDatasetFiles(datasetBranchUUID, sourceType, sourcePublicURL, manifestDigest, files, lineage, userUUID)
+}
+
+func (dao *Dao) GetDatasetVersionFiles(datasetVersionUUID uuid.UUID) ([]models.VersionFileResponse, error) {
+	return dao.Datastore().GetDatasetVersionFiles(datasetVersionUUID)
 }
 
 func (dao *Dao) GetDatasetAllBranches(datasetUUID uuid.UUID) ([]datasetmodels.DatasetBranchResponse, error) {
@@ -314,6 +375,10 @@ func (dao *Dao) GetDatasetBranchByUUID(datasetBranchUUID uuid.UUID) (*datasetmod
 	return dao.Datastore().GetDatasetBranchByUUID(datasetBranchUUID)
 }
 
+func (dao *Dao) FindDatasetVersionHash(datasetBranchUUID uuid.UUID, hash string) (bool, bool, error) {
+	return dao.Datastore().FindDatasetVersionHash(datasetBranchUUID, hash)
+}
+
 func (dao *Dao) GetDatasetBranchAllVersions(datasetBranchUUID uuid.UUID) ([]datasetmodels.DatasetBranchVersionResponse, error) {
 	return dao.Datastore().GetDatasetBranchAllVersions(datasetBranchUUID)
 }
@@ -429,3 +494,415 @@ func (dao *Dao) CreateDatasetReview(datasetUUID uuid.UUID, userUUID uuid.UUID, f
 func (dao *Dao) UpdateDatasetReview(reviewUUID uuid.UUID, updatedAttributes map[string]any) (*datasetmodels.DatasetReviewResponse, error) {
 	return dao.Datastore().UpdateDatasetReview(reviewUUID, updatedAttributes)
 }
+
+func (dao *Dao) CreateModelUploadSession(orgId uuid.UUID, modelBranchUUID uuid.UUID, fileName string, storage string, userUUID uuid.UUID) (*models.UploadSessionResponse, error) {
+	return dao.Datastore().CreateModelUploadSession(orgId, modelBranchUUID, fileName, storage, userUUID)
+}
+
+func (dao *Dao) CreateDatasetUploadSession(orgId uuid.UUID, datasetBranchUUID uuid.UUID, fileName string, storage string, userUUID uuid.UUID) (*models.UploadSessionResponse, error) {
+	return dao.Datastore().CreateDatasetUploadSession(orgId, datasetBranchUUID, fileName, storage, userUUID)
+}
+
+func (dao *Dao) GetModelUploadSession(modelBranchUUID uuid.UUID, sessionUUID uuid.UUID) (*models.UploadSessionResponse, error) {
+	return dao.Datastore().GetModelUploadSession(modelBranchUUID, sessionUUID)
+}
+
+func (dao *Dao) GetDatasetUploadSession(datasetBranchUUID uuid.UUID, sessionUUID uuid.UUID) (*models.UploadSessionResponse, error) {
+	return dao.Datastore().GetDatasetUploadSession(datasetBranchUUID, sessionUUID)
+}
+
+func (dao *Dao) CreateUploadChunk(sessionUUID uuid.UUID, number int, size int64) (*models.UploadChunkResponse, error) {
+	return dao.Datastore().CreateUploadChunk(sessionUUID, number, size)
+}
+
+func (dao *Dao) ClaimUploadSession(sessionUUID uuid.UUID) (bool, error) {
+	return dao.Datastore().ClaimUploadSession(sessionUUID)
+}
+
+func (dao *Dao) ReleaseUploadSession(sessionUUID uuid.UUID) error {
+	return dao.Datastore().ReleaseUploadSession(sessionUUID)
+}
+
+func (dao *Dao) ReferenceBlob(orgId uuid.UUID, sourceType string, sourceURL string, digest string) (*models.BlobResponse, error) {
+	return dao.Datastore().ReferenceBlob(orgId, sourceType, sourceURL, digest)
+}
+
+func (dao *Dao) AcquireBlob(orgId uuid.UUID, sourceType string, sourceURL string, digest string, size int64, dataKey *commonmodels.DataKey) (*models.BlobResponse, bool, error) {
+	return dao.Datastore().AcquireBlob(orgId, sourceType, sourceURL, digest, size, dataKey)
+}
+
+func (dao *Dao) CompleteBlob(blobUUID uuid.UUID) error {
+	return dao.Datastore().CompleteBlob(blobUUID)
+}
+
+func (dao *Dao) RetainBlob(blobUUID uuid.UUID) error {
+	return dao.Datastore().RetainBlob(blobUUID)
+}
+
+func (dao *Dao) ReleaseBlob(blobUUID uuid.UUID) (*models.BlobResponse, error) {
+	return dao.Datastore().ReleaseBlob(blobUUID)
+}
+
+func (dao *Dao) DeleteModelVersion(modelVersionUUID uuid.UUID) ([]*models.BlobResponse, error) {
+	return dao.Datastore().DeleteModelVersion(modelVersionUUID)
+}
+
+func (dao *Dao) DeleteDatasetVersion(datasetVersionUUID uuid.UUID) ([]*models.BlobResponse, error) {
+	return dao.Datastore().DeleteDatasetVersion(datasetVersionUUID)
+}
+
+func (dao *Dao) GetOrgStorageReferences(orgId uuid.UUID) ([]string, error) {
+	return dao.Datastore().GetOrgStorageReferences(orgId)
+}
+
+func (dao *Dao) GetOrgOpenUploadSessions(orgId uuid.UUID) ([]uuid.UUID, error) {
+	return dao.Datastore().GetOrgOpenUploadSessions(orgId)
+}
+
+func (dao *Dao) RecordModelStorageUsage(orgId uuid.UUID, modelUUID uuid.UUID, modelVersionUUID uuid.NullUUID, sourceType string, sourceURL string, key string, size int64) error {
+	return dao.Datastore().RecordModelStorageUsage(orgId, modelUUID, modelVersionUUID, sourceType, sourceURL, key, size)
+}
+
+func (dao *Dao) RecordDatasetStorageUsage(orgId uuid.UUID, datasetUUID uuid.UUID, datasetVersionUUID uuid.NullUUID, sourceType string, sourceURL string, key string, size int64) error {
+	return dao.Datastore().RecordDatasetStorageUsage(orgId, datasetUUID, datasetVersionUUID, sourceType, sourceURL, key, size)
+}
+
+func (dao *Dao) RemoveStorageUsage(orgId uuid.UUID, sourceType string, sourceURL string, key string) error {
+	return dao.Datastore().RemoveStorageUsage(orgId, sourceType, sourceURL, key)
+}
+
+func (dao *Dao) GetOrgStorageUsage(orgId uuid.UUID) (*models.StorageUsageResponse, error) {
+	return dao.Datastore().GetOrgStorageUsage(orgId)
+}
+
+func (dao *Dao) CreateStorageMigration(orgId uuid.UUID, sourceStorage string, targetStorage string, sourceType string, sourceURL string, modelUUIDs []uuid.UUID, datasetUUIDs []uuid.UUID, userUUID uuid.UUID) (*models.StorageMigrationResponse, error) {
+	return dao.Datastore().CreateStorageMigration(orgId, sourceStorage, targetStorage, sourceType, sourceURL, modelUUIDs, datasetUUIDs, userUUID)
+}
+
+func (dao *Dao) GetStorageMigration(orgId uuid.UUID, migrationUUID uuid.UUID) (*models.StorageMigrationResponse, error) {
+	return dao.Datastore().GetStorageMigration(orgId, migrationUUID)
+}
+
+func (dao *Dao) GetOrgStorageMigrations(orgId uuid.UUID) ([]*models.StorageMigrationResponse, error) {
+	return dao.Datastore().GetOrgStorageMigrations(orgId)
+}
+
+func (dao *Dao) UpdateStorageMigrationStatus(migrationUUID uuid.UUID, status string, errMessage string) error {
+	return dao.Datastore().UpdateStorageMigrationStatus(migrationUUID, status, errMessage)
+}
+
+func (dao *Dao) FailStorageMigrationItem(itemUUID uuid.UUID, errMessage string) error {
+	return dao.Datastore().FailStorageMigrationItem(itemUUID, errMessage)
+}
+
+func (dao *Dao) CompleteStorageMigrationItem(orgId uuid.UUID, itemUUID uuid.UUID, targetType string, targetURL string, targetKey string, size int64) error {
+	return dao.Datastore().CompleteStorageMigrationItem(orgId, itemUUID, targetType, targetURL, targetKey, size)
+}
+
+func (dao *Dao) ReleaseStorageMigrationItemSource(orgId uuid.UUID, itemUUID uuid.UUID, sourceType string, sourceURL string) (string, error) {
+	return dao.Datastore().ReleaseStorageMigrationItemSource(orgId, itemUUID, sourceType, sourceURL)
+}
+
+func (dao *Dao) GetBlob(orgId uuid.UUID, sourceType string, sourceURL string, digest string) (*models.BlobResponse, error) {
+	return dao.Datastore().GetBlob(orgId, sourceType, sourceURL, digest)
+}
+
+func (dao *Dao) GetOrgEncryption(orgId uuid.UUID) (*models.OrgEncryptionResponse, error) {
+	return dao.Datastore().GetOrgEncryption(orgId)
+}
+
+func (dao *Dao) GetOrgVersionDataKeys(orgId uuid.UUID, keyVersion int) ([]models.VersionDataKeyResponse, error) {
+	return dao.Datastore().GetOrgVersionDataKeys(orgId, keyVersion)
+}
+
+func (dao *Dao) UpdateVersionDataKey(kind string, versionUUID uuid.UUID, oldKeyVersion int, dataKey *commonmodels.DataKey) error {
+	return dao.Datastore().UpdateVersionDataKey(kind, versionUUID, oldKeyVersion, dataKey)
+}
+
+func (dao *Dao) CreateModelImportJob(orgId uuid.UUID, modelBranchUUID uuid.UUID, url string, hash string, hashAlgorithm string, storage string, userUUID uuid.UUID) (*models.ImportJobResponse, error) {
+	return dao.Datastore().CreateModelImportJob(orgId, modelBranchUUID, url, hash, hashAlgorithm, storage, userUUID)
+}
+
+func (dao *Dao) CreateDatasetImportJob(orgId uuid.UUID, datasetBranchUUID uuid.UUID, url string, hash string, hashAlgorithm string, storage string, lineage string, userUUID uuid.UUID) (*models.ImportJobResponse, error) {
+	return dao.Datastore().CreateDatasetImportJob(orgId, datasetBranchUUID, url, hash, hashAlgorithm, storage, lineage, userUUID)
+}
+
+func (dao *Dao) GetModelImportJob(modelBranchUUID uuid.UUID, jobUUID uuid.UUID) (*models.ImportJobResponse, error) {
+	return dao.Datastore().GetModelImportJob(modelBranchUUID, jobUUID)
+}
+
+func (dao *Dao) GetDatasetImportJob(datasetBranchUUID uuid.UUID, jobUUID uuid.UUID) (*models.ImportJobResponse, error) {
+	return dao.Datastore().GetDatasetImportJob(datasetBranchUUID, jobUUID)
+}
+
+func (dao *Dao) UpdateImportJobStatus(jobUUID uuid.UUID, status string, errorMessage string) error {
+	return dao.Datastore().UpdateImportJobStatus(jobUUID, status, errorMessage)
+}
+
+func (dao *Dao) CompleteImportJob(jobUUID uuid.UUID, size int64, version string) error {
+	return dao.Datastore().CompleteImportJob(jobUUID, size, version)
+}
+
+func (dao *Dao) FailInterruptedImportJobs() error {
+	return dao.Datastore().FailInterruptedImportJobs()
+}
+
+func (dao *Dao) SetModelAlias(modelUUID uuid.UUID, name string, modelVersionUUID uuid.UUID, userUUID uuid.UUID) (*models.VersionAliasResponse, error) {
+	return dao.Datastore().SetModelAlias(modelUUID, name, modelVersionUUID, userUUID)
+}
+
+func (dao *Dao) SetDatasetAlias(datasetUUID uuid.UUID, name string, datasetVersionUUID uuid.UUID, userUUID uuid.UUID) (*models.VersionAliasResponse, error) {
+	return dao.Datastore().SetDatasetAlias(datasetUUID, name, datasetVersionUUID, userUUID)
+}
+
+func (dao *Dao) GetModelAlias(modelUUID uuid.UUID, name string) (*models.VersionAliasResponse, error) {
+	return dao.Datastore().GetModelAlias(modelUUID, name)
+}
+
+func (dao *Dao) GetDatasetAlias(datasetUUID uuid.UUID, name string) (*models.VersionAliasResponse, error) {
+	return dao.Datastore().GetDatasetAlias(datasetUUID, name)
+}
+
+func (dao *Dao) GetModelAliases(modelUUID uuid.UUID) ([]models.VersionAliasResponse, error) {
+	return dao.Datastore().GetModelAliases(modelUUID)
+}
+
+func (dao *Dao) GetDatasetAliases(datasetUUID uuid.UUID) ([]models.VersionAliasResponse, error) {
+	return dao.Datastore().GetDatasetAliases(datasetUUID)
+}
+
+func (dao *Dao) DeleteModelAlias(modelUUID uuid.UUID, name string, userUUID uuid.UUID) error {
+	return dao.Datastore().DeleteModelAlias(modelUUID, name, userUUID)
+}
+
+func (dao *Dao) DeleteDatasetAlias(datasetUUID uuid.UUID, name string, userUUID uuid.UUID) error {
+	return dao.Datastore().DeleteDatasetAlias(datasetUUID, name, userUUID)
+}
+
+func (dao *Dao) GetModelAliasHistory(modelUUID uuid.UUID, name string) ([]models.VersionAliasEventResponse, error) {
+	return dao.Datastore().GetModelAliasHistory(modelUUID, name)
+}
+
+func (dao *Dao) GetDatasetAliasHistory(datasetUUID uuid.UUID, name string) ([]models.VersionAliasEventResponse, error) {
+	return dao.Datastore().GetDatasetAliasHistory(datasetUUID, name)
+}
+
+func (dao *Dao) AddModelVersionTags(orgId uuid.UUID, modelVersionUUID uuid.UUID, tags []string, userUUID uuid.UUID) ([]string, error) {
+	return dao.Datastore().AddModelVersionTags(orgId, modelVersionUUID, tags, userUUID)
+}
+
+func (dao *Dao) AddDatasetVersionTags(orgId uuid.UUID, datasetVersionUUID uuid.UUID, tags []string, userUUID uuid.UUID) ([]string, error) {
+	return dao.Datastore().AddDatasetVersionTags(orgId, datasetVersionUUID, tags, userUUID)
+}
+
+func (dao *Dao) GetModelVersionTags(modelVersionUUID uuid.UUID) ([]string, error) {
+	return dao.Datastore().GetModelVersionTags(modelVersionUUID)
+}
+
+func (dao *Dao) GetDatasetVersionTags(datasetVersionUUID uuid.UUID) ([]string, error) {
+	return dao.Datastore().GetDatasetVersionTags(datasetVersionUUID)
+}
+
+func (dao *Dao) GetModelTaggedVersions(modelUUID uuid.UUID, tag string) ([]modelmodels.ModelBranchVersionResponse, error) {
+	return dao.Datastore().GetModelTaggedVersions(modelUUID, tag)
+}
+
+func (dao *Dao) GetDatasetTaggedVersions(datasetUUID uuid.UUID, tag string) ([]datasetmodels.DatasetBranchVersionResponse, error) {
+	return dao.Datastore().GetDatasetTaggedVersions(datasetUUID, tag)
+}
+
+func (dao *Dao) SetModelRequireProductionReview(modelUUID uuid.UUID, require bool) error {
+	return dao.Datastore().SetModelRequireProductionReview(modelUUID, require)
+}
+
+func (dao *Dao) HasAcceptedModelReview(modelVersionUUID uuid.UUID) (bool, error) {
+	return dao.Datastore().HasAcceptedModelReview(modelVersionUUID)
+}
+
+func (dao *Dao) TransitionModelVersionStage(modelUUID uuid.UUID, modelVersionUUID uuid.UUID, toStage string, reason string, userUUID uuid.UUID) (*modelmodels.ModelStageTransitionResponse, error) {
+	return dao.Datastore().TransitionModelVersionStage(modelUUID, modelVersionUUID, toStage, reason, userUUID)
+}
+
+func (dao *Dao) GetModelStageTransitions(modelUUID uuid.UUID) ([]modelmodels.ModelStageTransitionResponse, error) {
+	return dao.Datastore().GetModelStageTransitions(modelUUID)
+}
+
+func (dao *Dao) GetModelVersionStageTransitions(modelVersionUUID uuid.UUID) ([]modelmodels.ModelStageTransitionResponse, error) {
+	return dao.Datastore().GetModelVersionStageTransitions(modelVersionUUID)
+}
+
+func (dao *Dao) GetModelStageVersions(modelUUID uuid.UUID, stage string) ([]modelmodels.ModelBranchVersionResponse, error) {
+	return dao.Datastore().GetModelStageVersions(modelUUID, stage)
+}
+
+func (dao *Dao) TrashModel(orgId uuid.UUID, modelUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	return dao.Datastore().TrashModel(orgId, modelUUID, name, userUUID, purgeAt)
+}
+
+func (dao *Dao) TrashModelBranch(orgId uuid.UUID, modelBranchUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	return dao.Datastore().TrashModelBranch(orgId, modelBranchUUID, name, userUUID, purgeAt)
+}
+
+func (dao *Dao) TrashModelVersion(orgId uuid.UUID, modelVersionUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	return dao.Datastore().TrashModelVersion(orgId, modelVersionUUID, name, userUUID, purgeAt)
+}
+
+func (dao *Dao) TrashDataset(orgId uuid.UUID, datasetUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	return dao.Datastore().TrashDataset(orgId, datasetUUID, name, userUUID, purgeAt)
+}
+
+func (dao *Dao) TrashDatasetBranch(orgId uuid.UUID, datasetBranchUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	return dao.Datastore().TrashDatasetBranch(orgId, datasetBranchUUID, name, userUUID, purgeAt)
+}
+
+func (dao *Dao) TrashDatasetVersion(orgId uuid.UUID, datasetVersionUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	return dao.Datastore().TrashDatasetVersion(orgId, datasetVersionUUID, name, userUUID, purgeAt)
+}
+
+func (dao *Dao) GetOrgTrash(orgId uuid.UUID) ([]models.TrashItemResponse, error) {
+	return dao.Datastore().GetOrgTrash(orgId)
+}
+
+func (dao *Dao) GetExpiredTrashItems(before time.Time) ([]models.TrashItemResponse, error) {
+	return dao.Datastore().GetExpiredTrashItems(before)
+}
+
+func (dao *Dao) GetTrashItem(orgId uuid.UUID, itemUUID uuid.UUID) (*models.TrashItemResponse, error) {
+	return dao.Datastore().GetTrashItem(orgId, itemUUID)
+}
+
+func (dao *Dao) IsModelNameTrashed(orgId uuid.UUID, name string) (bool, error) {
+	return dao.Datastore().IsModelNameTrashed(orgId, name)
+}
+
+func (dao *Dao) IsDatasetNameTrashed(orgId uuid.UUID, name string) (bool, error) {
+	return dao.Datastore().IsDatasetNameTrashed(orgId, name)
+}
+
+func (dao *Dao) RestoreTrashItem(itemUUID uuid.UUID) error {
+	return dao.Datastore().RestoreTrashItem(itemUUID)
+}
+
+func (dao *Dao) PurgeTrashItem(itemUUID uuid.UUID) ([]models.StoredObjectResponse, error) {
+	return dao.Datastore().PurgeTrashItem(itemUUID)
+}
+
+func (dao *Dao) GetModelReferences(modelUUID uuid.UUID, branchUUID uuid.NullUUID, versionUUID uuid.NullUUID) (*models.ReferencesResponse, error) {
+	return dao.Datastore().GetModelReferences(modelUUID, branchUUID, versionUUID)
+}
+
+func (dao *Dao) GetDatasetReferences(datasetUUID uuid.UUID, branchUUID uuid.NullUUID, versionUUID uuid.NullUUID) (*models.ReferencesResponse, error) {
+	return dao.Datastore().GetDatasetReferences(datasetUUID, branchUUID, versionUUID)
+}
+
+func (dao *Dao) IsModelBranchNameTaken(modelUUID uuid.UUID, name string) (bool, error) {
+	return dao.Datastore().IsModelBranchNameTaken(modelUUID, name)
+}
+
+func (dao *Dao) IsDatasetBranchNameTaken(datasetUUID uuid.UUID, name string) (bool, error) {
+	return dao.Datastore().IsDatasetBranchNameTaken(datasetUUID, name)
+}
+
+func (dao *Dao) UpdateModelBranch(modelUUID uuid.UUID, modelBranchUUID uuid.UUID, name string, isDefault bool) (*modelmodels.ModelBranchResponse, error) {
+	return dao.Datastore().UpdateModelBranch(modelUUID, modelBranchUUID, name, isDefault)
+}
+
+func (dao *Dao) UpdateDatasetBranch(datasetUUID uuid.UUID, datasetBranchUUID uuid.UUID, name string, isDefault bool) (*datasetmodels.DatasetBranchResponse, error) {
+	return dao.Datastore().UpdateDatasetBranch(datasetUUID, datasetBranchUUID, name, isDefault)
+}
+
+func (dao *Dao) GetModelBranchProtection(modelBranchUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	return dao.Datastore().GetModelBranchProtection(modelBranchUUID)
+}
+
+func (dao *Dao) SetModelBranchProtection(orgId uuid.UUID, modelBranchUUID uuid.UUID, rules models.BranchProtectionRules, userUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	return dao.Datastore().SetModelBranchProtection(orgId, modelBranchUUID, rules, userUUID)
+}
+
+func (dao *Dao) DeleteModelBranchProtection(modelBranchUUID uuid.UUID) error {
+	return dao.Datastore().DeleteModelBranchProtection(modelBranchUUID)
+}
+
+func (dao *Dao) GetDatasetBranchProtection(datasetBranchUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	return dao.Datastore().GetDatasetBranchProtection(datasetBranchUUID)
+}
+
+func (dao *Dao) SetDatasetBranchProtection(orgId uuid.UUID, datasetBranchUUID uuid.UUID, rules models.BranchProtectionRules, userUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	return dao.Datastore().SetDatasetBranchProtection(orgId, datasetBranchUUID, rules, userUUID)
+}
+
+func (dao *Dao) DeleteDatasetBranchProtection(datasetBranchUUID uuid.UUID) error {
+	return dao.Datastore().DeleteDatasetBranchProtection(datasetBranchUUID)
+}
+
+func (dao *Dao) ApproveModelReview(reviewUUID uuid.UUID, userUUID uuid.UUID) (*models.ReviewApprovalResponse, error) {
+	return dao.Datastore().ApproveModelReview(reviewUUID, userUUID)
+}
+
+func (dao *Dao) CountModelReviewApprovals(reviewUUID uuid.UUID) (int64, error) {
+	return dao.Datastore().CountModelReviewApprovals(reviewUUID)
+}
+
+func (dao *Dao) ApproveDatasetReview(reviewUUID uuid.UUID, userUUID uuid.UUID) (*models.ReviewApprovalResponse, error) {
+	return dao.Datastore().ApproveDatasetReview(reviewUUID, userUUID)
+}
+
+func (dao *Dao) CountDatasetReviewApprovals(reviewUUID uuid.UUID) (int64, error) {
+	return dao.Datastore().CountDatasetReviewApprovals(reviewUUID)
+}
+
+func (dao *Dao) CompareModelBranches(baseBranchUUID uuid.UUID, baseVersionUUID uuid.NullUUID, headBranchUUID uuid.UUID, headVersionUUID uuid.NullUUID) (*models.CompareResponse, error) {
+	return dao.Datastore().CompareModelBranches(baseBranchUUID, baseVersionUUID, headBranchUUID, headVersionUUID)
+}
+
+func (dao *Dao) CompareDatasetBranches(baseBranchUUID uuid.UUID, baseVersionUUID uuid.NullUUID, headBranchUUID uuid.UUID, headVersionUUID uuid.NullUUID) (*models.CompareResponse, error) {
+	return dao.Datastore().CompareDatasetBranches(baseBranchUUID, baseVersionUUID, headBranchUUID, headVersionUUID)
+}
+
+func (dao *Dao) CreateModelVersionMetrics(modelVersionUUID uuid.UUID, metrics []commonmodels.MetricRequest) ([]models.MetricSeriesResponse, error) {
+	return dao.Datastore().CreateModelVersionMetrics(modelVersionUUID, metrics)
+}
+
+func (dao *Dao) GetModelVersionMetrics(modelVersionUUID uuid.UUID, key string, withPoints bool) ([]models.MetricSeriesResponse, error) {
+	return dao.Datastore().GetModelVersionMetrics(modelVersionUUID, key, withPoints)
+}
+
+func (dao *Dao) CreateDatasetVersionMetrics(datasetVersionUUID uuid.UUID, metrics []commonmodels.MetricRequest) ([]models.MetricSeriesResponse, error) {
+	return dao.Datastore().CreateDatasetVersionMetrics(datasetVersionUUID, metrics)
+}
+
+func (dao *Dao) GetDatasetVersionMetrics(datasetVersionUUID uuid.UUID, key string, withPoints bool) ([]models.MetricSeriesResponse, error) {
+	return dao.Datastore().GetDatasetVersionMetrics(datasetVersionUUID, key, withPoints)
+}
+
+func (dao *Dao) CreateLogsForModelVersion(modelVersionUUID uuid.UUID, logs []commonmodels.LogRequest) ([]models.LogBatchEntryResponse, error) {
+	return dao.Datastore().CreateLogsForModelVersion(modelVersionUUID, logs)
+}
+
+func (dao *Dao) CreateLogsForDatasetVersion(datasetVersionUUID uuid.UUID, logs []commonmodels.LogRequest) ([]models.LogBatchEntryResponse, error) {
+	return dao.Datastore().CreateLogsForDatasetVersion(datasetVersionUUID, logs)
+}
+
+func (dao *Dao) GetModelLeaderboard(orgId uuid.UUID, query modelmodels.ModelLeaderboardQuery) ([]modelmodels.ModelLeaderboardEntryResponse, error) {
+	return dao.Datastore().GetModelLeaderboard(orgId, query)
+}
+
+func (dao *Dao) GetModelStreamEvents(modelUUID uuid.UUID, modelVersionUUID uuid.NullUUID, kinds []string, afterID uint64) ([]models.StreamEventResponse, error) {
+	return dao.Datastore().GetModelStreamEvents(modelUUID, modelVersionUUID, kinds, afterID)
+}
+
+func (dao *Dao) GetDatasetStreamEvents(datasetUUID uuid.UUID, datasetVersionUUID uuid.NullUUID, kinds []string, afterID uint64) ([]models.StreamEventResponse, error) {
+	return dao.Datastore().GetDatasetStreamEvents(datasetUUID, datasetVersionUUID, kinds, afterID)
+}
+
+func (dao *Dao) GetLastStreamEventID() (uint64, error) {
+	return dao.Datastore().GetLastStreamEventID()
+}
+
+func (dao *Dao) GetLastStreamEventIDBefore(before time.Time) (uint64, error) {
+	return dao.Datastore().GetLastStreamEventIDBefore(before)
+}
+
+func (dao *Dao) PurgeStreamEvents(before time.Time) (int64, error) {
+	return dao.Datastore().PurgeStreamEvents(before)
+}
@@ -1,10 +1,16 @@
 package impl
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
+	"mime"
 	"os"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -57,16 +63,20 @@ func NewSQLiteDatastore(optDataDir ...string) *Datastore {
 		dataDir = optDataDir[0]
 	}
 	databasePath := fmt.Sprintf("%s/pureml.db", dataDir)
+	// background jobs (eg. imports) write concurrently with the requests,
+	// so wait for the lock instead of failing with SQLITE_BUSY
 	if config.IsCGOEnabled() {
-		dialector = cgosqlite.Open(databasePath)
+		dialector = cgosqlite.Open(databasePath + "?_busy_timeout=5000")
 	} else {
-		dialector = puregosqlite.Open(databasePath)
+		dialector = puregosqlite.Open(databasePath + "?_pragma=busy_timeout(5000)")
 	}
 	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
 		fmt.Println(err)
 		panic("Error connecting to database")
 	}
+	// the main branches were protected before the protection rules existed
+	protectMain := !db.Migrator().HasTable(&dbmodels.BranchProtection{})
 	err = db.AutoMigrate(
 		dbmodels.Activity{},
 		modeldbmodels.Model{},
@@ -77,10 +87,27 @@ func NewSQLiteDatastore(optDataDir ...string) *Datastore {
 		datasetdbmodels.DatasetVersion{},
 		datasetdbmodels.Lineage{},
 		dbmodels.Log{},
-		// dbmodels.Tag{},
+		dbmodels.Metric{},
+		dbmodels.StreamEvent{},
+		dbmodels.VersionFile{},
+		dbmodels.ImportJob{},
+		dbmodels.Tag{},
+		dbmodels.VersionAlias{},
+		dbmodels.VersionAliasEvent{},
+		dbmodels.UploadSession{},
+		dbmodels.UploadChunk{},
+		dbmodels.Blob{},
+		dbmodels.StorageUsage{},
+		dbmodels.StorageMigration{},
+		dbmodels.StorageMigrationItem{},
+		dbmodels.TrashItem{},
+		dbmodels.BranchProtection{},
+		dbmodels.BranchProtectionReviewer{},
+		dbmodels.ReviewApproval{},
 		datasetdbmodels.Dataset{},
 		modeldbmodels.ModelBranch{},
 		modeldbmodels.ModelReview{},
+		modeldbmodels.ModelStageTransition{},
 		modeldbmodels.ModelUser{},
 		modeldbmodels.ModelVersion{},
 		userorgdbmodels.Organization{},
@@ -94,6 +121,11 @@ func NewSQLiteDatastore(optDataDir ...string) *Datastore {
 	if err != nil {
 		return &Datastore{}
 	}
+	if protectMain {
+		if err := protectMainBranches(db); err != nil {
+			return &Datastore{}
+		}
+	}
 	return &Datastore{
 		DB: db,
 	}
@@ -107,6 +139,8 @@ func NewPostgresDatastore(databaseUrl string) *Datastore {
 		fmt.Println(err)
 		panic("Error connecting to database")
 	}
+	// the main branches were protected before the protection rules existed
+	protectMain := !db.Migrator().HasTable(&dbmodels.BranchProtection{})
 	err = db.AutoMigrate(
 		dbmodels.Activity{},
 		modeldbmodels.Model{},
@@ -117,10 +151,27 @@ func NewPostgresDatastore(databaseUrl string) *Datastore {
 		datasetdbmodels.DatasetVersion{},
 		datasetdbmodels.Lineage{},
 		dbmodels.Log{},
-		// dbmodels.Tag{},
+		dbmodels.Metric{},
+		dbmodels.StreamEvent{},
+		dbmodels.VersionFile{},
+		dbmodels.ImportJob{},
+		dbmodels.Tag{},
+		dbmodels.VersionAlias{},
+		dbmodels.VersionAliasEvent{},
+		dbmodels.UploadSession{},
+		dbmodels.UploadChunk{},
+		dbmodels.Blob{},
+		dbmodels.StorageUsage{},
+		dbmodels.StorageMigration{},
+		dbmodels.StorageMigrationItem{},
+		dbmodels.TrashItem{},
+		dbmodels.BranchProtection{},
+		dbmodels.BranchProtectionReviewer{},
+		dbmodels.ReviewApproval{},
 		datasetdbmodels.Dataset{},
 		modeldbmodels.ModelBranch{},
 		modeldbmodels.ModelReview{},
+		modeldbmodels.ModelStageTransition{},
 		modeldbmodels.ModelUser{},
 		modeldbmodels.ModelVersion{},
 		userorgdbmodels.Organization{},
@@ -134,6 +185,11 @@ func NewPostgresDatastore(databaseUrl string) *Datastore {
 	if err != nil {
 		return &Datastore{}
 	}
+	if protectMain {
+		if err := protectMainBranches(db); err != nil {
+			return &Datastore{}
+		}
+	}
 	return &Datastore{
 		DB: db,
 	}
@@ -676,6 +732,18 @@ func (ds *Datastore) UpdateOrg(orgId uuid.UUID, updatedAttributes map[string]int
 	if updatedAttributes["avatar"] != nil {
 		org.Avatar = updatedAttributes["avatar"].(string)
 	}
+	if updatedAttributes["storage_soft_quota"] != nil {
+		org.StorageSoftQuota = updatedAttributes["storage_soft_quota"].(int64)
+	}
+	if updatedAttributes["storage_hard_quota"] != nil {
+		org.StorageHardQuota = updatedAttributes["storage_hard_quota"].(int64)
+	}
+	if updatedAttributes["encryption_enabled"] != nil {
+		org.EncryptionEnabled = updatedAttributes["encryption_enabled"].(bool)
+	}
+	if updatedAttributes["encryption_key_version"] != nil {
+		org.EncryptionKeyVersion = updatedAttributes["encryption_key_version"].(int)
+	}
 	result = ds.DB.Save(&org)
 	if result.Error != nil {
 		return nil, result.Error
@@ -714,6 +782,8 @@ func (ds *Datastore) GetOrgAllPublicModels(orgId uuid.UUID) ([]modelmodels.Model
 			Name:     model.Name,
 			Wiki:     model.Wiki,
 			IsPublic: model.IsPublic,
+
+			RequireProductionReview: model.RequireProductionReview,
 			CreatedBy: userorgmodels.UserHandleResponse{
 				UUID:   model.CreatedByUser.UUID,
 				Handle: model.CreatedByUser.Handle,
@@ -801,6 +871,7 @@ func (ds *Datastore) GetUserByHandle(handle string) (*userorgmodels.UserProfileR
 	numberOfModel := int64(0)
 	ds.DB.Model(&modeldbmodels.ModelUser{}).Where("user_uuid = ?", user.BaseModel.UUID).Count(&numberOfModel)
 	return &userorgmodels.UserProfileResponse{
+		UUID:             user.UUID,
 		Name:             user.Name,
 		Email:            user.Email,
 		Handle:           user.Handle,
@@ -1118,6 +1189,201 @@ func IncrementVersion(latestVersion string) string {
 	return newVersion
 }
 
+// maxVersionAllocationAttempts is the number of times the registration of a
+// version is tried when it conflicts with a concurrent registration.
+const maxVersionAllocationAttempts = 5
+
+// ErrVersionHashConflict is returned when a version with the same hash is
+// already registered on the branch.
+var ErrVersionHashConflict = errors.New("version with this hash already exists")
+
+// allocateVersion runs create, which inserts the next version of the branch,
+// in a transaction holding a write lock on the branch row. Registrations on
+// a branch are so serialized on Postgres, and on SQLite, which has no row
+// locks, the write takes the database lock up front. Registrations that
+// still conflict are retried, those of a hash already on the branch fail
+// with ErrVersionHashConflict.
+func allocateVersion(db *gorm.DB, branch interface{}, branchUUID uuid.UUID, create func(tx *gorm.DB) error) error {
+	var err error
+	for attempt := 1; attempt <= maxVersionAllocationAttempts; attempt++ {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(branch).Where("uuid = ?", branchUUID).UpdateColumn("uuid", gorm.Expr("uuid")).Error
+			if err != nil {
+				return err
+			}
+			return create(tx)
+		})
+		if err != nil && isHashConflict(err) {
+			return ErrVersionHashConflict
+		}
+		if err == nil || !isVersionConflict(err) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt*10+rand.Intn(10)) * time.Millisecond)
+	}
+	return err
+}
+
+// isHashConflict reports whether the error is caused by a version with the
+// same hash on the branch.
+func isHashConflict(err error) bool {
+	message := err.Error()
+	for _, conflict := range []string{
+		"idx_model_branch_hash",
+		"idx_dataset_branch_hash",
+		"UNIQUE constraint failed: model_versions.hash",
+		"UNIQUE constraint failed: dataset_versions.hash",
+	} {
+		if strings.Contains(message, conflict) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVersionConflict reports whether the error is caused by a concurrent
+// registration of a version on the same branch.
+func isVersionConflict(err error) bool {
+	message := err.Error()
+	for _, conflict := range []string{
+		"idx_model_branch_version",
+		"idx_dataset_branch_version",
+		"UNIQUE constraint failed: model_versions.version",
+		"UNIQUE constraint failed: dataset_versions.version",
+		"database is locked",
+		"could not serialize access",
+		"deadlock detected",
+	} {
+		if strings.Contains(message, conflict) {
+			return true
+		}
+	}
+	return false
+}
+
+// VersionDigests returns the known digests of a model or dataset version
+// keyed by algorithm. Versions registered before server-side verification
+// have no digests.
+func VersionDigests(hashAlgorithm string, hash string, digest string) map[string]string {
+	digests := map[string]string{}
+	if digest == "" {
+		return digests
+	}
+	digests["sha256"] = digest
+	if hashAlgorithm != "" {
+		digests[hashAlgorithm] = hash
+	}
+	return digests
+}
+
+// VersionDataKey returns the wrapped data key of an encrypted model or
+// dataset version, nil for plain versions.
+func VersionDataKey(wrappedKey string, keyVersion int) *commonmodels.DataKey {
+	if wrappedKey == "" {
+		return nil
+	}
+	return &commonmodels.DataKey{WrappedKey: wrappedKey, KeyVersion: keyVersion}
+}
+
+// SourcePath returns the path recorded for a stored model or dataset file.
+// Files of sources without a public URL (eg. LOCAL) are recorded by their
+// storage key so that the path stays valid if the data dir is moved.
+func SourcePath(sourcePublicURL string, filePath string) string {
+	if sourcePublicURL == "" {
+		return filePath
+	}
+	return fmt.Sprintf("%s/%s", sourcePublicURL, filePath)
+}
+
+// fileMediaType returns the media type of a version file guessed from its
+// extension.
+func fileMediaType(fileName string) string {
+	mediaType := mime.TypeByExtension(path.Ext(fileName))
+	if mediaType == "" {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+func newVersionFiles(sourcePublicURL string, files []models.VersionFileResponse) []dbmodels.VersionFile {
+	versionFiles := make([]dbmodels.VersionFile, 0, len(files))
+	for _, file := range files {
+		versionFile := dbmodels.VersionFile{
+			Name:       file.Path,
+			Size:       file.Size,
+			Digest:     strings.ToLower(file.Digest),
+			MediaType:  file.MediaType,
+			Path:       SourcePath(sourcePublicURL, file.StoragePath),
+			SourceType: file.SourceType,
+			BlobUUID:   file.BlobUUID,
+		}
+		if file.DataKey != nil {
+			versionFile.EncryptionKey = file.DataKey.WrappedKey
+			versionFile.EncryptionKeyVersion = file.DataKey.KeyVersion
+		}
+		versionFiles = append(versionFiles, versionFile)
+	}
+	return versionFiles
+}
+
+func newVersionFileResponse(versionFile *dbmodels.VersionFile) models.VersionFileResponse {
+	return models.VersionFileResponse{
+		Path:        versionFile.Name,
+		Size:        versionFile.Size,
+		Digest:      versionFile.Digest,
+		MediaType:   versionFile.MediaType,
+		StoragePath: versionFile.Path,
+		SourceType:  versionFile.SourceType,
+		BlobUUID:    versionFile.BlobUUID,
+		DataKey:     VersionDataKey(versionFile.EncryptionKey, versionFile.EncryptionKeyVersion),
+	}
+}
+
+// getVersionFiles returns the manifest of a multi-file version, column
+// being the version column of its files.
+func (ds *Datastore) getVersionFiles(column string, versionUUID uuid.UUID) ([]models.VersionFileResponse, error) {
+	var versionFiles []dbmodels.VersionFile
+	err := ds.DB.Where(column+" = ?", versionUUID).Order("name").Find(&versionFiles).Error
+	if err != nil {
+		return nil, err
+	}
+	files := []models.VersionFileResponse{}
+	for i := range versionFiles {
+		files = append(files, newVersionFileResponse(&versionFiles[i]))
+	}
+	return files, nil
+}
+
+// singleVersionFile returns the manifest of a single file version, which
+// is empty if the version has no file.
+func (ds *Datastore) singleVersionFile(sourceType string, filePath string, fileName string, digest string, blobUUID uuid.NullUUID, dataKey *commonmodels.DataKey) ([]models.VersionFileResponse, error) {
+	files := []models.VersionFileResponse{}
+	if filePath == "" {
+		return files, nil
+	}
+	if fileName == "" {
+		fileName = path.Base(filePath)
+	}
+	var size int64
+	if blobUUID.Valid {
+		var blob dbmodels.Blob
+		if err := ds.DB.Where("uuid = ?", blobUUID.UUID).Limit(1).Find(&blob).Error; err != nil {
+			return nil, err
+		}
+		size = blob.Size
+	}
+	return append(files, models.VersionFileResponse{
+		Path:        fileName,
+		Size:        size,
+		Digest:      digest,
+		MediaType:   fileMediaType(fileName),
+		StoragePath: filePath,
+		SourceType:  sourceType,
+		BlobUUID:    blobUUID,
+		DataKey:     dataKey,
+	}), nil
+}
+
 /////////////////////////////// MODEL METHODS /////////////////////////////////
 
 func (ds *Datastore) GetModelByName(orgId uuid.UUID, modelName string) (*modelmodels.ModelResponse, error) {
@@ -1150,6 +1416,8 @@ func (ds *Datastore) GetModelByName(orgId uuid.UUID, modelName string) (*modelmo
 			Email:  model.UpdatedByUser.Email,
 		},
 		IsPublic: model.IsPublic,
+
+		RequireProductionReview: model.RequireProductionReview,
 		Readme: commonmodels.ReadmeResponse{
 			UUID: model.Readme.UUID,
 			LatestVersion: commonmodels.ReadmeVersionResponse{
@@ -1192,6 +1460,8 @@ func (ds *Datastore) GetModelByUUID(modelUUID uuid.UUID) (*modelmodels.ModelResp
 			Email:  model.UpdatedByUser.Email,
 		},
 		IsPublic: model.IsPublic,
+
+		RequireProductionReview: model.RequireProductionReview,
 		Readme: commonmodels.ReadmeResponse{
 			UUID: model.Readme.UUID,
 			LatestVersion: commonmodels.ReadmeVersionResponse{
@@ -1368,6 +1638,8 @@ func (ds *Datastore) CreateModel(orgId uuid.UUID, name string, wiki string, isPu
 			Email:  model.UpdatedByUser.Email,
 		},
 		IsPublic: model.IsPublic,
+
+		RequireProductionReview: model.RequireProductionReview,
 		Readme: commonmodels.ReadmeResponse{
 			UUID: model.Readme.UUID,
 			LatestVersion: commonmodels.ReadmeVersionResponse{
@@ -1414,6 +1686,8 @@ func (ds *Datastore) GetAllPublicModels() ([]modelmodels.ModelResponse, error) {
 				Description: model.Org.Description,
 			},
 			IsPublic: model.IsPublic,
+
+			RequireProductionReview: model.RequireProductionReview,
 		}
 	}
 	return modelResponses, nil
@@ -1446,6 +1720,8 @@ func (ds *Datastore) GetAllModels(orgId uuid.UUID) ([]modelmodels.ModelResponse,
 				Email:  model.UpdatedByUser.Email,
 			},
 			IsPublic: model.IsPublic,
+
+			RequireProductionReview: model.RequireProductionReview,
 		}
 	}
 	return modelResponses, nil
@@ -1453,7 +1729,7 @@ func (ds *Datastore) GetAllModels(orgId uuid.UUID) ([]modelmodels.ModelResponse,
 
 func (ds *Datastore) GetModelAllBranches(modelUUID uuid.UUID) ([]modelmodels.ModelBranchResponse, error) {
 	var modelBranches []modeldbmodels.ModelBranch
-	result := ds.DB.Preload("Model").Where("model_uuid = ?", modelUUID).Find(&modelBranches)
+	result := ds.DB.Preload("Model").Preload("BaseBranch").Preload("BaseVersion").Where("model_uuid = ?", modelUUID).Find(&modelBranches)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -1467,6 +1743,7 @@ func (ds *Datastore) GetModelAllBranches(modelUUID uuid.UUID) ([]modelmodels.Mod
 				Name: branch.Model.Name,
 			},
 			IsDefault: branch.IsDefault,
+			Base:      modelBranchBase(branch),
 		}
 	}
 	return branches, nil
@@ -1496,23 +1773,71 @@ func (ds *Datastore) CreateModelBranch(modelUUID uuid.UUID, modelBranchName stri
 	}, nil
 }
 
-func (ds *Datastore) RegisterModelFile(modelBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, filePath string, isEmpty bool, hash string, userUUID uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
+// CreateModelBranchFromVersion creates the branch with a copy of the version as
+// its first version and records the version as the base of the branch.
+func (ds *Datastore) CreateModelBranchFromVersion(modelUUID uuid.UUID, modelBranchName string, baseVersionUUID uuid.UUID) (*modelmodels.ModelBranchResponse, error) {
+	var modelBranch modeldbmodels.ModelBranch
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		var baseVersion modeldbmodels.ModelVersion
+		if err := tx.Where("uuid = ?", baseVersionUUID).First(&baseVersion).Error; err != nil {
+			return err
+		}
+		modelBranch = modeldbmodels.ModelBranch{
+			Name:            modelBranchName,
+			ModelUUID:       modelUUID,
+			BaseBranchUUID:  uuid.NullUUID{UUID: baseVersion.BranchUUID, Valid: true},
+			BaseVersionUUID: uuid.NullUUID{UUID: baseVersion.UUID, Valid: true},
+		}
+		if err := tx.Omit(clause.Associations).Create(&modelBranch).Error; err != nil {
+			return err
+		}
+		_, err := copyModelVersion(tx, baseVersionUUID, modelBranch.UUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds.GetModelBranchByUUID(modelBranch.UUID)
+}
+
+// modelBranchBase returns the version the branch was created from, or nil if
+// the branch was created empty or its base was deleted.
+func modelBranchBase(modelBranch modeldbmodels.ModelBranch) *modelmodels.ModelBranchBaseResponse {
+	if modelBranch.BaseBranch == nil || modelBranch.BaseVersion == nil {
+		return nil
+	}
+	return &modelmodels.ModelBranchBaseResponse{
+		Branch: modelmodels.ModelBranchNameResponse{
+			UUID: modelBranch.BaseBranch.UUID,
+			Name: modelBranch.BaseBranch.Name,
+		},
+		Version: modelmodels.ModelBranchVersionNameResponse{
+			UUID:    modelBranch.BaseVersion.UUID,
+			Version: modelBranch.BaseVersion.Version,
+		},
+	}
+}
+
+// nextModelVersion returns the name of the next version of the model branch.
+// It must be called within allocateVersion so that concurrent registrations
+// don't get the same version. Versions in the trash are counted so that a
+// restored version never clashes with a newer one.
+func nextModelVersion(db *gorm.DB, modelBranchUUID uuid.UUID) string {
 	latestModelVersion := modeldbmodels.ModelVersion{
 		BranchUUID: modelBranchUUID,
 	}
-	res := ds.DB.Where(&latestModelVersion).Order("created_at desc").Limit(1).Find(&latestModelVersion)
-
-	var newVersion string
+	res := db.Unscoped().Where(&latestModelVersion).Order("LENGTH(version) DESC").Order("version DESC").Limit(1).Find(&latestModelVersion)
 	if res.RowsAffected == 0 {
-		newVersion = "v1"
-	} else {
-		latestVersion := latestModelVersion.Version
-		newVersion = IncrementVersion(latestVersion)
+		return "v1"
 	}
+	return IncrementVersion(latestModelVersion.Version)
+}
 
+func (ds *Datastore) RegisterModelFile(modelBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, filePath string, isEmpty bool, hash string, hashAlgorithm string, digest string, fileName string, blobUUID uuid.NullUUID, dataKey *commonmodels.DataKey, userUUID uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
 	modelVersion := modeldbmodels.ModelVersion{
-		Hash:    hash,
-		Version: newVersion,
+		Hash:          hash,
+		HashAlgorithm: hashAlgorithm,
+		Digest:        digest,
 		Branch: modeldbmodels.ModelBranch{
 			BaseModel: commondbmodels.BaseModel{
 				UUID: modelBranchUUID,
@@ -1523,30 +1848,81 @@ func (ds *Datastore) RegisterModelFile(modelBranchUUID uuid.UUID, sourceType str
 				UUID: userUUID,
 			},
 		},
-		Path:       fmt.Sprintf("%s/%s", sourcePublicURL, filePath),
+		Path:       SourcePath(sourcePublicURL, filePath),
 		SourceType: sourceType,
+		FileName:   fileName,
+		BlobUUID:   blobUUID,
 		IsEmpty:    isEmpty,
 	}
+	if dataKey != nil {
+		modelVersion.EncryptionKey = dataKey.WrappedKey
+		modelVersion.EncryptionKeyVersion = dataKey.KeyVersion
+	}
+
+	return ds.createModelVersion(&modelVersion)
+}
 
-	err := ds.DB.Create(&modelVersion).Error
+// RegisterModelReference registers a new version of the model branch that
+// references an object of the source instead of storing its own copy. The
+// object is never deleted nor moved by the registry.
+func (ds *Datastore) RegisterModelReference(modelBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, key string, hash string, hashAlgorithm string, digest string, size int64, etag string, userUUID uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
+	modelVersion := modeldbmodels.ModelVersion{
+		Hash:          hash,
+		HashAlgorithm: hashAlgorithm,
+		Digest:        digest,
+		Branch: modeldbmodels.ModelBranch{
+			BaseModel: commondbmodels.BaseModel{
+				UUID: modelBranchUUID,
+			},
+		},
+		CreatedByUser: userorgdbmodels.User{
+			BaseModel: commondbmodels.BaseModel{
+				UUID: userUUID,
+			},
+		},
+		Path:         SourcePath(sourcePublicURL, key),
+		SourceType:   sourceType,
+		FileName:     path.Base(key),
+		IsReferenced: true,
+		Size:         size,
+		ETag:         etag,
+	}
+	return ds.createModelVersion(&modelVersion)
+}
+
+// createModelVersion creates the version as the next version of its branch.
+func (ds *Datastore) createModelVersion(modelVersion *modeldbmodels.ModelVersion) (*modelmodels.ModelBranchVersionResponse, error) {
+	err := allocateVersion(ds.DB, &modeldbmodels.ModelBranch{}, modelVersion.Branch.UUID, func(tx *gorm.DB) error {
+		modelVersion.Version = nextModelVersion(tx, modelVersion.Branch.UUID)
+		if err := tx.Create(modelVersion).Error; err != nil {
+			return err
+		}
+		if err := completeBlobs(tx, modelVersion.BlobUUID); err != nil {
+			return err
+		}
+		return recordVersionRegistered(tx, "model_version_uuid", modelVersion.UUID)
+	})
 	if err != nil {
 		return nil, err
 	}
-	err = ds.DB.Preload("Branch").Preload("CreatedByUser").Find(&modelVersion).Error
+	err = ds.DB.Preload("Branch").Preload("CreatedByUser").Find(modelVersion).Error
 	if err != nil {
 		return nil, err
 	}
 
 	return &modelmodels.ModelBranchVersionResponse{
-		UUID:    modelVersion.UUID,
-		Hash:    modelVersion.Hash,
-		Version: modelVersion.Version,
+		UUID:          modelVersion.UUID,
+		Hash:          modelVersion.Hash,
+		HashAlgorithm: modelVersion.HashAlgorithm,
+		Digests:       VersionDigests(modelVersion.HashAlgorithm, modelVersion.Hash, modelVersion.Digest),
+		Version:       modelVersion.Version,
 		Branch: modelmodels.ModelBranchNameResponse{
 			UUID: modelVersion.Branch.UUID,
 			Name: modelVersion.Branch.Name,
 		},
 		Path:       modelVersion.Path,
 		SourceType: modelVersion.SourceType,
+		FileName:   modelVersion.FileName,
 		CreatedBy: userorgmodels.UserHandleResponse{
 			UUID:   modelVersion.CreatedByUser.UUID,
 			Name:   modelVersion.CreatedByUser.Name,
@@ -1554,60 +1930,201 @@ func (ds *Datastore) RegisterModelFile(modelBranchUUID uuid.UUID, sourceType str
 			Email:  modelVersion.CreatedByUser.Email,
 			Handle: modelVersion.CreatedByUser.Handle,
 		},
-		CreatedAt: modelVersion.CreatedAt,
-		IsEmpty:   modelVersion.IsEmpty,
+		CreatedAt:    modelVersion.CreatedAt,
+		IsEmpty:      modelVersion.IsEmpty,
+		Stage:        modelVersion.Stage,
+		Encrypted:    modelVersion.EncryptionKey != "",
+		IsManifest:   modelVersion.IsManifest,
+		IsReferenced: modelVersion.IsReferenced,
+		Size:         modelVersion.Size,
+		ETag:         modelVersion.ETag,
+		DataKey:      VersionDataKey(modelVersion.EncryptionKey, modelVersion.EncryptionKeyVersion),
 	}, nil
 }
 
-func (ds *Datastore) MigrateModelVersionBranch(modelVersion uuid.UUID, toBranch uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
-	var modelVersionDB modeldbmodels.ModelVersion
-	err := ds.DB.Preload("Branch").Preload("CreatedByUser").Preload("Path").Where("uuid = ?", modelVersion).First(&modelVersionDB).Error
+// RegisterModelFiles registers a new version of the model branch made of
+// the files of the manifest, whose digest is the version hash. The file
+// storage paths are the keys of their blobs in the source.
+func (ds *Datastore) RegisterModelFiles(modelBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, manifestDigest string, files []models.VersionFileResponse, userUUID uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
+	var modelVersion modeldbmodels.ModelVersion
+	err := allocateVersion(ds.DB, &modeldbmodels.ModelBranch{}, modelBranchUUID, func(tx *gorm.DB) error {
+		modelVersion = modeldbmodels.ModelVersion{
+			Hash:          manifestDigest,
+			HashAlgorithm: "sha256",
+			Digest:        manifestDigest,
+			Version:       nextModelVersion(tx, modelBranchUUID),
+			Branch: modeldbmodels.ModelBranch{
+				BaseModel: commondbmodels.BaseModel{
+					UUID: modelBranchUUID,
+				},
+			},
+			CreatedByUser: userorgdbmodels.User{
+				BaseModel: commondbmodels.BaseModel{
+					UUID: userUUID,
+				},
+			},
+			SourceType: sourceType,
+			IsManifest: true,
+		}
+		if err := tx.Create(&modelVersion).Error; err != nil {
+			return err
+		}
+		versionFiles := newVersionFiles(sourcePublicURL, files)
+		for i := range versionFiles {
+			versionFiles[i].ModelVersionUUID = uuid.NullUUID{UUID: modelVersion.UUID, Valid: true}
+		}
+		if err := tx.Create(&versionFiles).Error; err != nil {
+			return err
+		}
+		for _, versionFile := range versionFiles {
+			if err := completeBlobs(tx, versionFile.BlobUUID); err != nil {
+				return err
+			}
+		}
+		return recordVersionRegistered(tx, "model_version_uuid", modelVersion.UUID)
+	})
 	if err != nil {
 		return nil, err
 	}
-	//Update the branch of the model version
-	modelVersionDB.Branch.UUID = toBranch
-	modelVersionDB.BaseModel.UUID = uuid.Nil
-	modelVersionDB.CreatedAt = time.Now()
-	modelVersionDB.UpdatedAt = time.Now()
-	err = ds.DB.Save(&modelVersionDB).Error
-	if err != nil {
+	return ds.GetModelBranchVersion(modelBranchUUID, modelVersion.Version)
+}
+
+// GetModelVersionFiles returns the manifest of the model version, a single
+// file version having a manifest of its file. Returns nil if the version
+// doesn't exist.
+func (ds *Datastore) GetModelVersionFiles(modelVersionUUID uuid.UUID) ([]models.VersionFileResponse, error) {
+	var modelVersion modeldbmodels.ModelVersion
+	res := ds.DB.Where("uuid = ?", modelVersionUUID).Limit(1).Find(&modelVersion)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	if modelVersion.IsManifest {
+		return ds.getVersionFiles("model_version_uuid", modelVersionUUID)
+	}
+	return ds.singleVersionFile(modelVersion.SourceType, modelVersion.Path, modelVersion.FileName, modelVersion.Digest, modelVersion.BlobUUID, VersionDataKey(modelVersion.EncryptionKey, modelVersion.EncryptionKeyVersion))
+}
+
+// copyModelVersion copies the version with its files and logs as the next
+// version of the branch. The copy shares the blobs of the version and starts
+// without a stage.
+func copyModelVersion(tx *gorm.DB, modelVersionUUID uuid.UUID, toBranchUUID uuid.UUID) (*modeldbmodels.ModelVersion, error) {
+	var modelVersion modeldbmodels.ModelVersion
+	if err := tx.Where("uuid = ?", modelVersionUUID).First(&modelVersion).Error; err != nil {
+		return nil, err
+	}
+	modelVersion.BaseModel = commondbmodels.BaseModel{}
+	modelVersion.BranchUUID = toBranchUUID
+	modelVersion.Version = nextModelVersion(tx, toBranchUUID)
+	modelVersion.Stage = modelmodels.ModelStageNone
+	if err := tx.Omit(clause.Associations).Create(&modelVersion).Error; err != nil {
+		return nil, err
+	}
+	if err := copyVersionData(tx, "model_version_uuid", modelVersionUUID, modelVersion.UUID, modelVersion.BlobUUID); err != nil {
 		return nil, err
 	}
+	return &modelVersion, nil
+}
 
-	// Migrate logs
+// copyVersionData copies the files, logs and metrics of the version to its
+// copy and takes a new reference on the blobs they share.
+func copyVersionData(tx *gorm.DB, column string, fromUUID uuid.UUID, toUUID uuid.UUID, blobUUID uuid.NullUUID) error {
+	to := uuid.NullUUID{UUID: toUUID, Valid: true}
+	blobUUIDs := []uuid.NullUUID{blobUUID}
+	var versionFiles []dbmodels.VersionFile
+	if err := tx.Where(column+" = ?", fromUUID).Find(&versionFiles).Error; err != nil {
+		return err
+	}
+	for _, versionFile := range versionFiles {
+		versionFile.BaseModel = commondbmodels.BaseModel{}
+		if column == "model_version_uuid" {
+			versionFile.ModelVersionUUID = to
+		} else {
+			versionFile.DatasetVersionUUID = to
+		}
+		if err := tx.Omit(clause.Associations).Create(&versionFile).Error; err != nil {
+			return err
+		}
+		blobUUIDs = append(blobUUIDs, versionFile.BlobUUID)
+	}
+	for _, blobUUID := range blobUUIDs {
+		if !blobUUID.Valid {
+			continue
+		}
+		err := tx.Model(&dbmodels.Blob{}).Where("uuid = ?", blobUUID.UUID).Update("ref_count", gorm.Expr("ref_count + 1")).Error
+		if err != nil {
+			return err
+		}
+	}
 	var logs []dbmodels.Log
-	err = ds.DB.Where("model_version_uuid = ?", modelVersion).Preload("ModelVersion").Find(&logs).Error
-	if err != nil {
-		return nil, err
+	if err := tx.Where(column+" = ?", fromUUID).Find(&logs).Error; err != nil {
+		return err
 	}
-	err = ds.DB.Transaction(func(tx *gorm.DB) error {
-		for _, log := range logs {
-			log.BaseModel.UUID = uuid.Nil
-			log.ModelVersion.BaseModel.UUID = modelVersionDB.UUID
-			log.CreatedAt = time.Now()
-			log.UpdatedAt = time.Now()
-			err = tx.Save(&log).Error
-			if err != nil {
-				return err
-			}
+	for _, log := range logs {
+		log.BaseModel = commondbmodels.BaseModel{}
+		if column == "model_version_uuid" {
+			log.ModelVersionUUID = to
+		} else {
+			log.DatasetVersionUUID = to
 		}
-		return nil
+		if err := tx.Omit(clause.Associations).Create(&log).Error; err != nil {
+			return err
+		}
+	}
+	var metrics []dbmodels.Metric
+	if err := tx.Where(column+" = ?", fromUUID).Find(&metrics).Error; err != nil {
+		return err
+	}
+	for _, metric := range metrics {
+		metric.BaseModel = commondbmodels.BaseModel{}
+		if column == "model_version_uuid" {
+			metric.ModelVersionUUID = to
+		} else {
+			metric.DatasetVersionUUID = to
+		}
+		if err := tx.Omit(clause.Associations).Create(&metric).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateModelVersionBranch copies the version with its files and logs as
+// the next version of the branch.
+func (ds *Datastore) MigrateModelVersionBranch(modelVersion uuid.UUID, toBranch uuid.UUID) (*modelmodels.ModelBranchVersionResponse, error) {
+	var copied *modeldbmodels.ModelVersion
+	err := allocateVersion(ds.DB, &modeldbmodels.ModelBranch{}, toBranch, func(tx *gorm.DB) error {
+		var err error
+		copied, err = copyModelVersion(tx, modelVersion, toBranch)
+		if err != nil {
+			return err
+		}
+		return recordVersionRegistered(tx, "model_version_uuid", copied.UUID)
 	})
 	if err != nil {
 		return nil, err
 	}
+	var modelVersionDB modeldbmodels.ModelVersion
+	err = ds.DB.Preload("Branch").Preload("CreatedByUser").Where("uuid = ?", copied.UUID).First(&modelVersionDB).Error
+	if err != nil {
+		return nil, err
+	}
 
 	return &modelmodels.ModelBranchVersionResponse{
-		UUID:    modelVersionDB.UUID,
-		Hash:    modelVersionDB.Hash,
-		Version: modelVersionDB.Version,
+		UUID:          modelVersionDB.UUID,
+		Hash:          modelVersionDB.Hash,
+		HashAlgorithm: modelVersionDB.HashAlgorithm,
+		Digests:       VersionDigests(modelVersionDB.HashAlgorithm, modelVersionDB.Hash, modelVersionDB.Digest),
+		Version:       modelVersionDB.Version,
 		Branch: modelmodels.ModelBranchNameResponse{
 			UUID: modelVersionDB.BranchUUID,
 			Name: modelVersionDB.Branch.Name,
 		},
 		Path:       modelVersionDB.Path,
 		SourceType: modelVersionDB.SourceType,
+		FileName:   modelVersionDB.FileName,
 		CreatedBy: userorgmodels.UserHandleResponse{
 			UUID:   modelVersionDB.CreatedByUser.UUID,
 			Handle: modelVersionDB.CreatedByUser.Handle,
@@ -1615,8 +2132,15 @@ func (ds *Datastore) MigrateModelVersionBranch(modelVersion uuid.UUID, toBranch
 			Avatar: modelVersionDB.CreatedByUser.Avatar,
 			Email:  modelVersionDB.CreatedByUser.Email,
 		},
-		CreatedAt: modelVersionDB.CreatedAt,
-		IsEmpty:   modelVersionDB.IsEmpty,
+		CreatedAt:    modelVersionDB.CreatedAt,
+		IsEmpty:      modelVersionDB.IsEmpty,
+		Stage:        modelVersionDB.Stage,
+		Encrypted:    modelVersionDB.EncryptionKey != "",
+		IsManifest:   modelVersionDB.IsManifest,
+		IsReferenced: modelVersionDB.IsReferenced,
+		Size:         modelVersionDB.Size,
+		ETag:         modelVersionDB.ETag,
+		DataKey:      VersionDataKey(modelVersionDB.EncryptionKey, modelVersionDB.EncryptionKeyVersion),
 	}, nil
 }
 
@@ -1629,15 +2153,18 @@ func (ds *Datastore) GetModelAllVersions(modelUUID uuid.UUID) ([]modelmodels.Mod
 	var modelVersionsResponse []modelmodels.ModelBranchVersionResponse
 	for _, modelVersion := range modelVersions {
 		modelVersionsResponse = append(modelVersionsResponse, modelmodels.ModelBranchVersionResponse{
-			UUID:    modelVersion.UUID,
-			Hash:    modelVersion.Hash,
-			Version: modelVersion.Version,
+			UUID:          modelVersion.UUID,
+			Hash:          modelVersion.Hash,
+			HashAlgorithm: modelVersion.HashAlgorithm,
+			Digests:       VersionDigests(modelVersion.HashAlgorithm, modelVersion.Hash, modelVersion.Digest),
+			Version:       modelVersion.Version,
 			Branch: modelmodels.ModelBranchNameResponse{
 				UUID: modelVersion.Branch.UUID,
 				Name: modelVersion.Branch.Name,
 			},
 			Path:       modelVersion.Path,
 			SourceType: modelVersion.SourceType,
+			FileName:   modelVersion.FileName,
 			CreatedBy: userorgmodels.UserHandleResponse{
 				UUID:   modelVersion.CreatedByUser.UUID,
 				Handle: modelVersion.CreatedByUser.Handle,
@@ -1645,8 +2172,15 @@ func (ds *Datastore) GetModelAllVersions(modelUUID uuid.UUID) ([]modelmodels.Mod
 				Avatar: modelVersion.CreatedByUser.Avatar,
 				Email:  modelVersion.CreatedByUser.Email,
 			},
-			CreatedAt: modelVersion.CreatedAt,
-			IsEmpty:   modelVersion.IsEmpty,
+			CreatedAt:    modelVersion.CreatedAt,
+			IsEmpty:      modelVersion.IsEmpty,
+			Stage:        modelVersion.Stage,
+			Encrypted:    modelVersion.EncryptionKey != "",
+			IsManifest:   modelVersion.IsManifest,
+			IsReferenced: modelVersion.IsReferenced,
+			Size:         modelVersion.Size,
+			ETag:         modelVersion.ETag,
+			DataKey:      VersionDataKey(modelVersion.EncryptionKey, modelVersion.EncryptionKeyVersion),
 		})
 	}
 	return modelVersionsResponse, nil
@@ -1658,7 +2192,7 @@ func (ds *Datastore) GetModelBranchByName(orgId uuid.UUID, modelName string, mod
 	if err != nil {
 		return nil, err
 	}
-	res := ds.DB.Where("name = ?", modelBranchName).Where("model_uuid = ?", model.UUID).Preload("Model").Limit(1).Find(&modelBranch)
+	res := ds.DB.Where("name = ?", modelBranchName).Where("model_uuid = ?", model.UUID).Preload("Model").Preload("BaseBranch").Preload("BaseVersion").Limit(1).Find(&modelBranch)
 	if res.RowsAffected == 0 {
 		return nil, nil
 	}
@@ -1673,12 +2207,13 @@ func (ds *Datastore) GetModelBranchByName(orgId uuid.UUID, modelName string, mod
 			Name: modelBranch.Model.Name,
 		},
 		IsDefault: modelBranch.IsDefault,
+		Base:      modelBranchBase(modelBranch),
 	}, nil
 }
 
 func (ds *Datastore) GetModelBranchByUUID(modelBranchUUID uuid.UUID) (*modelmodels.ModelBranchResponse, error) {
 	var modelBranch modeldbmodels.ModelBranch
-	res := ds.DB.Where("uuid = ?", modelBranchUUID).Preload("Model").Limit(1).Find(&modelBranch)
+	res := ds.DB.Where("uuid = ?", modelBranchUUID).Preload("Model").Preload("BaseBranch").Preload("BaseVersion").Limit(1).Find(&modelBranch)
 	if res.RowsAffected == 0 {
 		return nil, nil
 	}
@@ -1693,6 +2228,7 @@ func (ds *Datastore) GetModelBranchByUUID(modelBranchUUID uuid.UUID) (*modelmode
 			Name: modelBranch.Model.Name,
 		},
 		IsDefault: modelBranch.IsDefault,
+		Base:      modelBranchBase(modelBranch),
 	}, nil
 }
 
@@ -1705,15 +2241,24 @@ func (ds *Datastore) GetModelBranchAllVersions(modelBranchUUID uuid.UUID, withLo
 	var modelVersionsResponse []modelmodels.ModelBranchVersionResponse
 	for _, modelVersion := range modelVersions {
 		modelBranchVersion := modelmodels.ModelBranchVersionResponse{
-			UUID:    modelVersion.UUID,
-			Hash:    modelVersion.Hash,
-			Version: modelVersion.Version,
+			UUID:          modelVersion.UUID,
+			Hash:          modelVersion.Hash,
+			HashAlgorithm: modelVersion.HashAlgorithm,
+			Digests:       VersionDigests(modelVersion.HashAlgorithm, modelVersion.Hash, modelVersion.Digest),
+			Version:       modelVersion.Version,
 			Branch: modelmodels.ModelBranchNameResponse{
 				UUID: modelVersion.Branch.UUID,
 				Name: modelVersion.Branch.Name,
 			},
-			Path:    modelVersion.Path,
-			IsEmpty: modelVersion.IsEmpty,
+			Path:         modelVersion.Path,
+			IsEmpty:      modelVersion.IsEmpty,
+			Stage:        modelVersion.Stage,
+			Encrypted:    modelVersion.EncryptionKey != "",
+			IsManifest:   modelVersion.IsManifest,
+			IsReferenced: modelVersion.IsReferenced,
+			Size:         modelVersion.Size,
+			ETag:         modelVersion.ETag,
+			DataKey:      VersionDataKey(modelVersion.EncryptionKey, modelVersion.EncryptionKeyVersion),
 			CreatedBy: userorgmodels.UserHandleResponse{
 				UUID:   modelVersion.CreatedByUser.UUID,
 				Handle: modelVersion.CreatedByUser.Handle,
@@ -1742,6 +2287,17 @@ func (ds *Datastore) GetModelBranchAllVersions(modelBranchUUID uuid.UUID, withLo
 	return modelVersionsResponse, nil
 }
 
+// FindModelVersionHash reports whether a version of the branch has the hash,
+// and whether that version is in the trash, where it still holds the hash.
+func (ds *Datastore) FindModelVersionHash(modelBranchUUID uuid.UUID, hash string) (bool, bool, error) {
+	var modelVersion modeldbmodels.ModelVersion
+	res := ds.DB.Unscoped().Where("branch_uuid = ? AND hash = ?", modelBranchUUID, hash).Limit(1).Find(&modelVersion)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, false, res.Error
+	}
+	return true, modelVersion.DeletedAt.Valid, nil
+}
+
 func (ds *Datastore) GetModelBranchVersion(modelBranchUUID uuid.UUID, version string) (*modelmodels.ModelBranchVersionResponse, error) {
 	var modelVersion modeldbmodels.ModelVersion
 	var res *gorm.DB
@@ -1757,15 +2313,18 @@ func (ds *Datastore) GetModelBranchVersion(modelBranchUUID uuid.UUID, version st
 		return nil, nil
 	}
 	return &modelmodels.ModelBranchVersionResponse{
-		UUID:    modelVersion.UUID,
-		Hash:    modelVersion.Hash,
-		Version: modelVersion.Version,
+		UUID:          modelVersion.UUID,
+		Hash:          modelVersion.Hash,
+		HashAlgorithm: modelVersion.HashAlgorithm,
+		Digests:       VersionDigests(modelVersion.HashAlgorithm, modelVersion.Hash, modelVersion.Digest),
+		Version:       modelVersion.Version,
 		Branch: modelmodels.ModelBranchNameResponse{
 			UUID: modelVersion.Branch.UUID,
 			Name: modelVersion.Branch.Name,
 		},
 		Path:       modelVersion.Path,
 		SourceType: modelVersion.SourceType,
+		FileName:   modelVersion.FileName,
 		CreatedBy: userorgmodels.UserHandleResponse{
 			UUID:   modelVersion.CreatedByUser.UUID,
 			Handle: modelVersion.CreatedByUser.Handle,
@@ -1773,8 +2332,15 @@ func (ds *Datastore) GetModelBranchVersion(modelBranchUUID uuid.UUID, version st
 			Avatar: modelVersion.CreatedByUser.Avatar,
 			Email:  modelVersion.CreatedByUser.Email,
 		},
-		CreatedAt: modelVersion.CreatedAt,
-		IsEmpty:   modelVersion.IsEmpty,
+		CreatedAt:    modelVersion.CreatedAt,
+		IsEmpty:      modelVersion.IsEmpty,
+		Stage:        modelVersion.Stage,
+		Encrypted:    modelVersion.EncryptionKey != "",
+		IsManifest:   modelVersion.IsManifest,
+		IsReferenced: modelVersion.IsReferenced,
+		Size:         modelVersion.Size,
+		ETag:         modelVersion.ETag,
+		DataKey:      VersionDataKey(modelVersion.EncryptionKey, modelVersion.EncryptionKeyVersion),
 	}, nil
 }
 
@@ -2118,7 +2684,7 @@ func (ds *Datastore) GetAllDatasets(orgId uuid.UUID, showPublic bool) ([]dataset
 
 func (ds *Datastore) GetDatasetAllBranches(datasetUUID uuid.UUID) ([]datasetmodels.DatasetBranchResponse, error) {
 	var datasetBranches []datasetdbmodels.DatasetBranch
-	result := ds.DB.Preload("Dataset").Where("dataset_uuid = ?", datasetUUID).Find(&datasetBranches)
+	result := ds.DB.Preload("Dataset").Preload("BaseBranch").Preload("BaseVersion").Where("dataset_uuid = ?", datasetUUID).Find(&datasetBranches)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -2132,6 +2698,7 @@ func (ds *Datastore) GetDatasetAllBranches(datasetUUID uuid.UUID) ([]datasetmode
 				Name: branch.Dataset.Name,
 			},
 			IsDefault: branch.IsDefault,
+			Base:      datasetBranchBase(branch),
 		}
 	}
 	return branches, nil
@@ -2161,23 +2728,71 @@ func (ds *Datastore) CreateDatasetBranch(datasetUUID uuid.UUID, datasetBranchNam
 	}, nil
 }
 
-func (ds *Datastore) RegisterDatasetFile(datasetBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, filePath string, isEmpty bool, hash string, lineage string, userUUID uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
+// CreateDatasetBranchFromVersion creates the branch with a copy of the version as
+// its first version and records the version as the base of the branch.
+func (ds *Datastore) CreateDatasetBranchFromVersion(datasetUUID uuid.UUID, datasetBranchName string, baseVersionUUID uuid.UUID) (*datasetmodels.DatasetBranchResponse, error) {
+	var datasetBranch datasetdbmodels.DatasetBranch
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		var baseVersion datasetdbmodels.DatasetVersion
+		if err := tx.Where("uuid = ?", baseVersionUUID).First(&baseVersion).Error; err != nil {
+			return err
+		}
+		datasetBranch = datasetdbmodels.DatasetBranch{
+			Name:            datasetBranchName,
+			DatasetUUID:     datasetUUID,
+			BaseBranchUUID:  uuid.NullUUID{UUID: baseVersion.BranchUUID, Valid: true},
+			BaseVersionUUID: uuid.NullUUID{UUID: baseVersion.UUID, Valid: true},
+		}
+		if err := tx.Omit(clause.Associations).Create(&datasetBranch).Error; err != nil {
+			return err
+		}
+		_, err := copyDatasetVersion(tx, baseVersionUUID, datasetBranch.UUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds.GetDatasetBranchByUUID(datasetBranch.UUID)
+}
+
+// datasetBranchBase returns the version the branch was created from, or nil if
+// the branch was created empty or its base was deleted.
+func datasetBranchBase(datasetBranch datasetdbmodels.DatasetBranch) *datasetmodels.DatasetBranchBaseResponse {
+	if datasetBranch.BaseBranch == nil || datasetBranch.BaseVersion == nil {
+		return nil
+	}
+	return &datasetmodels.DatasetBranchBaseResponse{
+		Branch: datasetmodels.DatasetBranchNameResponse{
+			UUID: datasetBranch.BaseBranch.UUID,
+			Name: datasetBranch.BaseBranch.Name,
+		},
+		Version: datasetmodels.DatasetBranchVersionNameResponse{
+			UUID:    datasetBranch.BaseVersion.UUID,
+			Version: datasetBranch.BaseVersion.Version,
+		},
+	}
+}
+
+// nextDatasetVersion returns the name of the next version of the dataset
+// branch. It must be called within allocateVersion so that concurrent
+// registrations don't get the same version. Versions in the trash are
+// counted so that a restored version never clashes with a newer one.
+func nextDatasetVersion(db *gorm.DB, datasetBranchUUID uuid.UUID) string {
 	latestDatasetVersion := datasetdbmodels.DatasetVersion{
 		BranchUUID: datasetBranchUUID,
 	}
-	res := ds.DB.Where(&latestDatasetVersion).Order("created_at desc").Limit(1).Find(&latestDatasetVersion)
-
-	var newVersion string
+	res := db.Unscoped().Where(&latestDatasetVersion).Order("LENGTH(version) DESC").Order("version DESC").Limit(1).Find(&latestDatasetVersion)
 	if res.RowsAffected == 0 {
-		newVersion = "v1"
-	} else {
-		latestVersion := latestDatasetVersion.Version
-		newVersion = IncrementVersion(latestVersion)
+		return "v1"
 	}
+	return IncrementVersion(latestDatasetVersion.Version)
+}
 
+func (ds *Datastore) RegisterDatasetFile(datasetBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, filePath string, isEmpty bool, hash string, hashAlgorithm string, digest string, fileName string, blobUUID uuid.NullUUID, dataKey *commonmodels.DataKey, lineage string, userUUID uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
 	datasetVersion := datasetdbmodels.DatasetVersion{
-		Hash:    hash,
-		Version: newVersion,
+		Hash:          hash,
+		HashAlgorithm: hashAlgorithm,
+		Digest:        digest,
 		Branch: datasetdbmodels.DatasetBranch{
 			BaseModel: commondbmodels.BaseModel{
 				UUID: datasetBranchUUID,
@@ -2191,29 +2806,83 @@ func (ds *Datastore) RegisterDatasetFile(datasetBranchUUID uuid.UUID, sourceType
 		Lineage: datasetdbmodels.Lineage{
 			Lineage: lineage,
 		},
-		Path:       fmt.Sprintf("%s/%s", sourcePublicURL, filePath),
+		Path:       SourcePath(sourcePublicURL, filePath),
 		SourceType: sourceType,
+		FileName:   fileName,
+		BlobUUID:   blobUUID,
 		IsEmpty:    isEmpty,
 	}
-	err := ds.DB.Create(&datasetVersion).Error
+	if dataKey != nil {
+		datasetVersion.EncryptionKey = dataKey.WrappedKey
+		datasetVersion.EncryptionKeyVersion = dataKey.KeyVersion
+	}
+	return ds.createDatasetVersion(&datasetVersion)
+}
+
+// RegisterDatasetReference registers a new version of the dataset branch that
+// references an object of the source instead of storing its own copy. The
+// object is never deleted nor moved by the registry.
+func (ds *Datastore) RegisterDatasetReference(datasetBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, key string, hash string, hashAlgorithm string, digest string, size int64, etag string, lineage string, userUUID uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
+	datasetVersion := datasetdbmodels.DatasetVersion{
+		Hash:          hash,
+		HashAlgorithm: hashAlgorithm,
+		Digest:        digest,
+		Branch: datasetdbmodels.DatasetBranch{
+			BaseModel: commondbmodels.BaseModel{
+				UUID: datasetBranchUUID,
+			},
+		},
+		CreatedByUser: userorgdbmodels.User{
+			BaseModel: commondbmodels.BaseModel{
+				UUID: userUUID,
+			},
+		},
+		Lineage: datasetdbmodels.Lineage{
+			Lineage: lineage,
+		},
+		Path:         SourcePath(sourcePublicURL, key),
+		SourceType:   sourceType,
+		FileName:     path.Base(key),
+		IsReferenced: true,
+		Size:         size,
+		ETag:         etag,
+	}
+	return ds.createDatasetVersion(&datasetVersion)
+}
+
+// createDatasetVersion creates the version as the next version of its branch.
+func (ds *Datastore) createDatasetVersion(datasetVersion *datasetdbmodels.DatasetVersion) (*datasetmodels.DatasetBranchVersionResponse, error) {
+	err := allocateVersion(ds.DB, &datasetdbmodels.DatasetBranch{}, datasetVersion.Branch.UUID, func(tx *gorm.DB) error {
+		datasetVersion.Version = nextDatasetVersion(tx, datasetVersion.Branch.UUID)
+		if err := tx.Create(datasetVersion).Error; err != nil {
+			return err
+		}
+		if err := completeBlobs(tx, datasetVersion.BlobUUID); err != nil {
+			return err
+		}
+		return recordVersionRegistered(tx, "dataset_version_uuid", datasetVersion.UUID)
+	})
 	if err != nil {
 		return nil, err
 	}
-	err = ds.DB.Preload("Lineage").Preload("Branch").Preload("CreatedByUser").Find(&datasetVersion).Error
+	err = ds.DB.Preload("Lineage").Preload("Branch").Preload("CreatedByUser").Find(datasetVersion).Error
 	if err != nil {
 		return nil, err
 	}
 
 	return &datasetmodels.DatasetBranchVersionResponse{
-		UUID:    datasetVersion.UUID,
-		Hash:    datasetVersion.Hash,
-		Version: datasetVersion.Version,
+		UUID:          datasetVersion.UUID,
+		Hash:          datasetVersion.Hash,
+		HashAlgorithm: datasetVersion.HashAlgorithm,
+		Digests:       VersionDigests(datasetVersion.HashAlgorithm, datasetVersion.Hash, datasetVersion.Digest),
+		Version:       datasetVersion.Version,
 		Branch: datasetmodels.DatasetBranchNameResponse{
 			UUID: datasetVersion.Branch.UUID,
 			Name: datasetVersion.Branch.Name,
 		},
 		Path:       datasetVersion.Path,
 		SourceType: datasetVersion.SourceType,
+		FileName:   datasetVersion.FileName,
 		Lineage: datasetmodels.LineageResponse{
 			UUID:    datasetVersion.Lineage.UUID,
 			Lineage: datasetVersion.Lineage.Lineage,
@@ -2225,62 +2894,147 @@ func (ds *Datastore) RegisterDatasetFile(datasetBranchUUID uuid.UUID, sourceType
 			Email:  datasetVersion.CreatedByUser.Email,
 			Handle: datasetVersion.CreatedByUser.Handle,
 		},
-		CreatedAt: datasetVersion.CreatedAt,
-		IsEmpty:   datasetVersion.IsEmpty,
+		CreatedAt:    datasetVersion.CreatedAt,
+		IsEmpty:      datasetVersion.IsEmpty,
+		Encrypted:    datasetVersion.EncryptionKey != "",
+		IsManifest:   datasetVersion.IsManifest,
+		IsReferenced: datasetVersion.IsReferenced,
+		Size:         datasetVersion.Size,
+		ETag:         datasetVersion.ETag,
+		DataKey:      VersionDataKey(datasetVersion.EncryptionKey, datasetVersion.EncryptionKeyVersion),
 	}, nil
 }
 
-func (ds *Datastore) MigrateDatasetVersionBranch(datasetVersion uuid.UUID, toBranch uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
-	var datasetVersionDB datasetdbmodels.DatasetVersion
-	err := ds.DB.Preload("Branch").Preload("Lineage").Preload("Path").Preload("CreatedByUser").Where("uuid = ?", datasetVersion).First(&datasetVersionDB).Error
-	if err != nil {
-		return nil, err
-	}
-	//Update the branch of the dataset version
-	datasetVersionDB.Branch.UUID = toBranch
-	datasetVersionDB.BaseModel.UUID = uuid.Nil
-	//Create a new lineage record
-	datasetVersionDB.Lineage.UUID = uuid.Nil
-	datasetVersionDB.CreatedAt = time.Now()
-	datasetVersionDB.UpdatedAt = time.Now()
-	err = ds.DB.Save(&datasetVersionDB).Error
-	if err != nil {
-		return nil, err
-	}
-
-	// Migrate logs
-	var logs []dbmodels.Log
-	err = ds.DB.Where("dataset_version_uuid = ?", datasetVersion).Preload("DatasetVersion").Find(&logs).Error
-	if err != nil {
-		return nil, err
-	}
-	err = ds.DB.Transaction(func(tx *gorm.DB) error {
-		for _, log := range logs {
-			log.BaseModel.UUID = uuid.Nil
-			log.DatasetVersion.BaseModel.UUID = datasetVersionDB.UUID
-			log.CreatedAt = time.Now()
-			log.UpdatedAt = time.Now()
-			err = tx.Save(&log).Error
-			if err != nil {
+// RegisterDatasetFiles registers a new version of the dataset branch made
+// of the files of the manifest, whose digest is the version hash. The file
+// storage paths are the keys of their blobs in the source.
+func (ds *Datastore) RegisterDatasetFiles(datasetBranchUUID uuid.UUID, sourceType string, sourcePublicURL string, manifestDigest string, files []models.VersionFileResponse, lineage string, userUUID uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
+	var datasetVersion datasetdbmodels.DatasetVersion
+	err := allocateVersion(ds.DB, &datasetdbmodels.DatasetBranch{}, datasetBranchUUID, func(tx *gorm.DB) error {
+		datasetVersion = datasetdbmodels.DatasetVersion{
+			Hash:          manifestDigest,
+			HashAlgorithm: "sha256",
+			Digest:        manifestDigest,
+			Version:       nextDatasetVersion(tx, datasetBranchUUID),
+			Branch: datasetdbmodels.DatasetBranch{
+				BaseModel: commondbmodels.BaseModel{
+					UUID: datasetBranchUUID,
+				},
+			},
+			CreatedByUser: userorgdbmodels.User{
+				BaseModel: commondbmodels.BaseModel{
+					UUID: userUUID,
+				},
+			},
+			Lineage: datasetdbmodels.Lineage{
+				Lineage: lineage,
+			},
+			SourceType: sourceType,
+			IsManifest: true,
+		}
+		if err := tx.Create(&datasetVersion).Error; err != nil {
+			return err
+		}
+		versionFiles := newVersionFiles(sourcePublicURL, files)
+		for i := range versionFiles {
+			versionFiles[i].DatasetVersionUUID = uuid.NullUUID{UUID: datasetVersion.UUID, Valid: true}
+		}
+		if err := tx.Create(&versionFiles).Error; err != nil {
+			return err
+		}
+		for _, versionFile := range versionFiles {
+			if err := completeBlobs(tx, versionFile.BlobUUID); err != nil {
 				return err
 			}
 		}
-		return nil
+		return recordVersionRegistered(tx, "dataset_version_uuid", datasetVersion.UUID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds.GetDatasetBranchVersion(datasetBranchUUID, datasetVersion.Version)
+}
+
+// GetDatasetVersionFiles returns the manifest of the dataset version, a
+// single file version having a manifest of its file. Returns nil if the
+// version doesn't exist.
+func (ds *Datastore) GetDatasetVersionFiles(datasetVersionUUID uuid.UUID) ([]models.VersionFileResponse, error) {
+	var datasetVersion datasetdbmodels.DatasetVersion
+	res := ds.DB.Where("uuid = ?", datasetVersionUUID).Limit(1).Find(&datasetVersion)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	if datasetVersion.IsManifest {
+		return ds.getVersionFiles("dataset_version_uuid", datasetVersionUUID)
+	}
+	return ds.singleVersionFile(datasetVersion.SourceType, datasetVersion.Path, datasetVersion.FileName, datasetVersion.Digest, datasetVersion.BlobUUID, VersionDataKey(datasetVersion.EncryptionKey, datasetVersion.EncryptionKeyVersion))
+}
+
+// copyDatasetVersion copies the version with its lineage, files and logs as
+// the next version of the branch. The copy shares the blobs of the version.
+func copyDatasetVersion(tx *gorm.DB, datasetVersionUUID uuid.UUID, toBranchUUID uuid.UUID) (*datasetdbmodels.DatasetVersion, error) {
+	var datasetVersion datasetdbmodels.DatasetVersion
+	if err := tx.Preload("Lineage").Where("uuid = ?", datasetVersionUUID).First(&datasetVersion).Error; err != nil {
+		return nil, err
+	}
+	datasetVersion.BaseModel = commondbmodels.BaseModel{}
+	datasetVersion.BranchUUID = toBranchUUID
+	datasetVersion.Version = nextDatasetVersion(tx, toBranchUUID)
+	if datasetVersion.LineageUUID.Valid {
+		// the copy gets its own lineage record
+		lineage := datasetdbmodels.Lineage{Lineage: datasetVersion.Lineage.Lineage}
+		if err := tx.Create(&lineage).Error; err != nil {
+			return nil, err
+		}
+		datasetVersion.LineageUUID = uuid.NullUUID{UUID: lineage.UUID, Valid: true}
+		datasetVersion.Lineage = lineage
+	}
+	if err := tx.Omit(clause.Associations).Create(&datasetVersion).Error; err != nil {
+		return nil, err
+	}
+	if err := copyVersionData(tx, "dataset_version_uuid", datasetVersionUUID, datasetVersion.UUID, datasetVersion.BlobUUID); err != nil {
+		return nil, err
+	}
+	return &datasetVersion, nil
+}
+
+// MigrateDatasetVersionBranch copies the version with its lineage, files and
+// logs as the next version of the branch.
+func (ds *Datastore) MigrateDatasetVersionBranch(datasetVersion uuid.UUID, toBranch uuid.UUID) (*datasetmodels.DatasetBranchVersionResponse, error) {
+	var copied *datasetdbmodels.DatasetVersion
+	err := allocateVersion(ds.DB, &datasetdbmodels.DatasetBranch{}, toBranch, func(tx *gorm.DB) error {
+		var err error
+		copied, err = copyDatasetVersion(tx, datasetVersion, toBranch)
+		if err != nil {
+			return err
+		}
+		return recordVersionRegistered(tx, "dataset_version_uuid", copied.UUID)
 	})
 	if err != nil {
 		return nil, err
 	}
+	var datasetVersionDB datasetdbmodels.DatasetVersion
+	err = ds.DB.Preload("Branch").Preload("Lineage").Preload("CreatedByUser").Where("uuid = ?", copied.UUID).First(&datasetVersionDB).Error
+	if err != nil {
+		return nil, err
+	}
 
 	return &datasetmodels.DatasetBranchVersionResponse{
-		UUID:    datasetVersionDB.UUID,
-		Hash:    datasetVersionDB.Hash,
-		Version: datasetVersionDB.Version,
+		UUID:          datasetVersionDB.UUID,
+		Hash:          datasetVersionDB.Hash,
+		HashAlgorithm: datasetVersionDB.HashAlgorithm,
+		Digests:       VersionDigests(datasetVersionDB.HashAlgorithm, datasetVersionDB.Hash, datasetVersionDB.Digest),
+		Version:       datasetVersionDB.Version,
 		Branch: datasetmodels.DatasetBranchNameResponse{
 			UUID: datasetVersionDB.BranchUUID,
 			Name: datasetVersionDB.Branch.Name,
 		},
 		Path:       datasetVersionDB.Path,
 		SourceType: datasetVersionDB.SourceType,
+		FileName:   datasetVersionDB.FileName,
 		Lineage: datasetmodels.LineageResponse{
 			UUID:    datasetVersionDB.Lineage.UUID,
 			Lineage: datasetVersionDB.Lineage.Lineage,
@@ -2292,8 +3046,14 @@ func (ds *Datastore) MigrateDatasetVersionBranch(datasetVersion uuid.UUID, toBra
 			Email:  datasetVersionDB.CreatedByUser.Email,
 			Handle: datasetVersionDB.CreatedByUser.Handle,
 		},
-		CreatedAt: datasetVersionDB.CreatedAt,
-		IsEmpty:   datasetVersionDB.IsEmpty,
+		CreatedAt:    datasetVersionDB.CreatedAt,
+		IsEmpty:      datasetVersionDB.IsEmpty,
+		Encrypted:    datasetVersionDB.EncryptionKey != "",
+		IsManifest:   datasetVersionDB.IsManifest,
+		IsReferenced: datasetVersionDB.IsReferenced,
+		Size:         datasetVersionDB.Size,
+		ETag:         datasetVersionDB.ETag,
+		DataKey:      VersionDataKey(datasetVersionDB.EncryptionKey, datasetVersionDB.EncryptionKeyVersion),
 	}, nil
 }
 
@@ -2306,15 +3066,18 @@ func (ds *Datastore) GetDatasetAllVersions(datasetUUID uuid.UUID) ([]datasetmode
 	var datasetVersionsResponse []datasetmodels.DatasetBranchVersionResponse
 	for _, datasetVersion := range datasetVersions {
 		datasetVersionsResponse = append(datasetVersionsResponse, datasetmodels.DatasetBranchVersionResponse{
-			UUID:    datasetVersion.UUID,
-			Hash:    datasetVersion.Hash,
-			Version: datasetVersion.Version,
+			UUID:          datasetVersion.UUID,
+			Hash:          datasetVersion.Hash,
+			HashAlgorithm: datasetVersion.HashAlgorithm,
+			Digests:       VersionDigests(datasetVersion.HashAlgorithm, datasetVersion.Hash, datasetVersion.Digest),
+			Version:       datasetVersion.Version,
 			Branch: datasetmodels.DatasetBranchNameResponse{
 				UUID: datasetVersion.Branch.UUID,
 				Name: datasetVersion.Branch.Name,
 			},
 			Path:       datasetVersion.Path,
 			SourceType: datasetVersion.SourceType,
+			FileName:   datasetVersion.FileName,
 			Lineage: datasetmodels.LineageResponse{
 				UUID:    datasetVersion.Lineage.UUID,
 				Lineage: datasetVersion.Lineage.Lineage,
@@ -2326,8 +3089,14 @@ func (ds *Datastore) GetDatasetAllVersions(datasetUUID uuid.UUID) ([]datasetmode
 				Email:  datasetVersion.CreatedByUser.Email,
 				Handle: datasetVersion.CreatedByUser.Handle,
 			},
-			CreatedAt: datasetVersion.CreatedAt,
-			IsEmpty:   datasetVersion.IsEmpty,
+			CreatedAt:    datasetVersion.CreatedAt,
+			IsEmpty:      datasetVersion.IsEmpty,
+			Encrypted:    datasetVersion.EncryptionKey != "",
+			IsManifest:   datasetVersion.IsManifest,
+			IsReferenced: datasetVersion.IsReferenced,
+			Size:         datasetVersion.Size,
+			ETag:         datasetVersion.ETag,
+			DataKey:      VersionDataKey(datasetVersion.EncryptionKey, datasetVersion.EncryptionKeyVersion),
 		})
 	}
 	return datasetVersionsResponse, nil
@@ -2339,7 +3108,7 @@ func (ds *Datastore) GetDatasetBranchByName(orgId uuid.UUID, datasetName string,
 	if err != nil {
 		return nil, err
 	}
-	res := ds.DB.Where("name = ?", datasetBranchName).Where("dataset_uuid = ?", dataset.UUID).Preload("Dataset").Limit(1).Find(&datasetBranch)
+	res := ds.DB.Where("name = ?", datasetBranchName).Where("dataset_uuid = ?", dataset.UUID).Preload("Dataset").Preload("BaseBranch").Preload("BaseVersion").Limit(1).Find(&datasetBranch)
 	if res.RowsAffected == 0 {
 		return nil, nil
 	}
@@ -2351,14 +3120,18 @@ func (ds *Datastore) GetDatasetBranchByName(orgId uuid.UUID, datasetName string,
 			Name: datasetBranch.Dataset.Name,
 		},
 		IsDefault: datasetBranch.IsDefault,
+		Base:      datasetBranchBase(datasetBranch),
 	}, nil
 }
 
 func (ds *Datastore) GetDatasetBranchByUUID(datasetBranchUUID uuid.UUID) (*datasetmodels.DatasetBranchResponse, error) {
 	var datasetBranch datasetdbmodels.DatasetBranch
-	err := ds.DB.Where("uuid = ?", datasetBranchUUID).Preload("Dataset").Find(&datasetBranch).Error
-	if err != nil {
-		return nil, err
+	res := ds.DB.Where("uuid = ?", datasetBranchUUID).Preload("Dataset").Preload("BaseBranch").Preload("BaseVersion").Limit(1).Find(&datasetBranch)
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	if res.Error != nil {
+		return nil, res.Error
 	}
 	return &datasetmodels.DatasetBranchResponse{
 		UUID: datasetBranch.UUID,
@@ -2368,6 +3141,7 @@ func (ds *Datastore) GetDatasetBranchByUUID(datasetBranchUUID uuid.UUID) (*datas
 			Name: datasetBranch.Dataset.Name,
 		},
 		IsDefault: datasetBranch.IsDefault,
+		Base:      datasetBranchBase(datasetBranch),
 	}, nil
 }
 
@@ -2380,15 +3154,18 @@ func (ds *Datastore) GetDatasetBranchAllVersions(datasetBranchUUID uuid.UUID) ([
 	var datasetVersionsResponse []datasetmodels.DatasetBranchVersionResponse
 	for _, datasetVersion := range datasetVersions {
 		datasetVersionsResponse = append(datasetVersionsResponse, datasetmodels.DatasetBranchVersionResponse{
-			UUID:    datasetVersion.UUID,
-			Hash:    datasetVersion.Hash,
-			Version: datasetVersion.Version,
+			UUID:          datasetVersion.UUID,
+			Hash:          datasetVersion.Hash,
+			HashAlgorithm: datasetVersion.HashAlgorithm,
+			Digests:       VersionDigests(datasetVersion.HashAlgorithm, datasetVersion.Hash, datasetVersion.Digest),
+			Version:       datasetVersion.Version,
 			Branch: datasetmodels.DatasetBranchNameResponse{
 				UUID: datasetVersion.Branch.UUID,
 				Name: datasetVersion.Branch.Name,
 			},
 			Path:       datasetVersion.Path,
 			SourceType: datasetVersion.SourceType,
+			FileName:   datasetVersion.FileName,
 			Lineage: datasetmodels.LineageResponse{
 				UUID:    datasetVersion.Lineage.UUID,
 				Lineage: datasetVersion.Lineage.Lineage,
@@ -2400,13 +3177,30 @@ func (ds *Datastore) GetDatasetBranchAllVersions(datasetBranchUUID uuid.UUID) ([
 				Email:  datasetVersion.CreatedByUser.Email,
 				Handle: datasetVersion.CreatedByUser.Handle,
 			},
-			CreatedAt: datasetVersion.CreatedAt,
-			IsEmpty:   datasetVersion.IsEmpty,
+			CreatedAt:    datasetVersion.CreatedAt,
+			IsEmpty:      datasetVersion.IsEmpty,
+			Encrypted:    datasetVersion.EncryptionKey != "",
+			IsManifest:   datasetVersion.IsManifest,
+			IsReferenced: datasetVersion.IsReferenced,
+			Size:         datasetVersion.Size,
+			ETag:         datasetVersion.ETag,
+			DataKey:      VersionDataKey(datasetVersion.EncryptionKey, datasetVersion.EncryptionKeyVersion),
 		})
 	}
 	return datasetVersionsResponse, nil
 }
 
+// FindDatasetVersionHash reports whether a version of the branch has the hash,
+// and whether that version is in the trash, where it still holds the hash.
+func (ds *Datastore) FindDatasetVersionHash(datasetBranchUUID uuid.UUID, hash string) (bool, bool, error) {
+	var datasetVersion datasetdbmodels.DatasetVersion
+	res := ds.DB.Unscoped().Where("branch_uuid = ? AND hash = ?", datasetBranchUUID, hash).Limit(1).Find(&datasetVersion)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, false, res.Error
+	}
+	return true, datasetVersion.DeletedAt.Valid, nil
+}
+
 func (ds *Datastore) GetDatasetBranchVersion(datasetBranchUUID uuid.UUID, version string) (*datasetmodels.DatasetBranchVersionResponse, error) {
 	var datasetVersion datasetdbmodels.DatasetVersion
 	var res *gorm.DB
@@ -2422,15 +3216,18 @@ func (ds *Datastore) GetDatasetBranchVersion(datasetBranchUUID uuid.UUID, versio
 		return nil, nil
 	}
 	return &datasetmodels.DatasetBranchVersionResponse{
-		UUID:    datasetVersion.UUID,
-		Hash:    datasetVersion.Hash,
-		Version: datasetVersion.Version,
+		UUID:          datasetVersion.UUID,
+		Hash:          datasetVersion.Hash,
+		HashAlgorithm: datasetVersion.HashAlgorithm,
+		Digests:       VersionDigests(datasetVersion.HashAlgorithm, datasetVersion.Hash, datasetVersion.Digest),
+		Version:       datasetVersion.Version,
 		Branch: datasetmodels.DatasetBranchNameResponse{
 			UUID: datasetVersion.Branch.UUID,
 			Name: datasetVersion.Branch.Name,
 		},
 		Path:       datasetVersion.Path,
 		SourceType: datasetVersion.SourceType,
+		FileName:   datasetVersion.FileName,
 		Lineage: datasetmodels.LineageResponse{
 			UUID:    datasetVersion.Lineage.UUID,
 			Lineage: datasetVersion.Lineage.Lineage,
@@ -2442,8 +3239,14 @@ func (ds *Datastore) GetDatasetBranchVersion(datasetBranchUUID uuid.UUID, versio
 			Email:  datasetVersion.CreatedByUser.Email,
 			Handle: datasetVersion.CreatedByUser.Handle,
 		},
-		CreatedAt: datasetVersion.CreatedAt,
-		IsEmpty:   datasetVersion.IsEmpty,
+		CreatedAt:    datasetVersion.CreatedAt,
+		IsEmpty:      datasetVersion.IsEmpty,
+		Encrypted:    datasetVersion.EncryptionKey != "",
+		IsManifest:   datasetVersion.IsManifest,
+		IsReferenced: datasetVersion.IsReferenced,
+		Size:         datasetVersion.Size,
+		ETag:         datasetVersion.ETag,
+		DataKey:      VersionDataKey(datasetVersion.EncryptionKey, datasetVersion.EncryptionKeyVersion),
 	}, nil
 }
 
@@ -2460,6 +3263,7 @@ func (ds *Datastore) GetLogForModelVersion(modelVersionUUID uuid.UUID) ([]models
 		logsResponse = append(logsResponse, models.LogResponse{
 			Key:  log.Key,
 			Data: log.Data,
+			Type: log.Type,
 			ModelVersion: modelmodels.ModelBranchVersionNameResponse{
 				UUID:    log.ModelVersion.UUID,
 				Version: log.ModelVersion.Version,
@@ -2480,6 +3284,7 @@ func (ds *Datastore) GetKeyLogForModelVersion(modelVersionUUID uuid.UUID, key st
 		logsResponse = append(logsResponse, models.LogResponse{
 			Key:  log.Key,
 			Data: log.Data,
+			Type: log.Type,
 			ModelVersion: modelmodels.ModelBranchVersionNameResponse{
 				UUID:    log.ModelVersion.UUID,
 				Version: log.ModelVersion.Version,
@@ -2507,15 +3312,24 @@ func (ds *Datastore) CreateLogForModelVersion(key string, data string, modelVers
 			},
 		},
 	}
-	err = ds.DB.Clauses(clause.OnConflict{
-		UpdateAll: true,
-	}).Create(&log).Preload("ModelVersion").Find(&log).Error
+	err = ds.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			UpdateAll: true,
+		}).Create(&log).Preload("ModelVersion").Find(&log).Error
+		if err != nil {
+			return err
+		}
+		return recordVersionEvents(tx, "model_version_uuid", modelVersionUUID, models.StreamLog, []interface{}{
+			models.StreamLogResponse{Key: log.Key, Data: log.Data, Type: log.Type},
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &models.LogResponse{
 		Key:  log.Key,
 		Data: log.Data,
+		Type: log.Type,
 		ModelVersion: modelmodels.ModelBranchVersionNameResponse{
 			UUID:    log.ModelVersion.UUID,
 			Version: log.ModelVersion.Version,
@@ -2534,6 +3348,7 @@ func (ds *Datastore) GetLogForDatasetVersion(datasetVersion uuid.UUID) ([]models
 		logsResponse = append(logsResponse, models.LogResponse{
 			Key:  log.Key,
 			Data: log.Data,
+			Type: log.Type,
 			DatasetVersion: datasetmodels.DatasetBranchVersionNameResponse{
 				UUID:    log.DatasetVersion.UUID,
 				Version: log.DatasetVersion.Version,
@@ -2554,6 +3369,7 @@ func (ds *Datastore) GetKeyLogForDatasetVersion(datasetVersion uuid.UUID, key st
 		logsResponse = append(logsResponse, models.LogResponse{
 			Key:  log.Key,
 			Data: log.Data,
+			Type: log.Type,
 			DatasetVersion: datasetmodels.DatasetBranchVersionNameResponse{
 				UUID:    log.DatasetVersion.UUID,
 				Version: log.DatasetVersion.Version,
@@ -2581,15 +3397,24 @@ func (ds *Datastore) CreateLogForDatasetVersion(key string, data string, dataset
 			},
 		},
 	}
-	err = ds.DB.Clauses(clause.OnConflict{
-		UpdateAll: true,
-	}).Create(&log).Preload("DatasetVersion").Find(&log).Error
+	err = ds.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			UpdateAll: true,
+		}).Create(&log).Preload("DatasetVersion").Find(&log).Error
+		if err != nil {
+			return err
+		}
+		return recordVersionEvents(tx, "dataset_version_uuid", datasetVersionUUID, models.StreamLog, []interface{}{
+			models.StreamLogResponse{Key: log.Key, Data: log.Data, Type: log.Type},
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &models.LogResponse{
 		Key:  log.Key,
 		Data: log.Data,
+		Type: log.Type,
 		DatasetVersion: datasetmodels.DatasetBranchVersionNameResponse{
 			UUID:    log.DatasetVersion.UUID,
 			Version: log.DatasetVersion.Version,
@@ -2833,6 +3658,8 @@ func (ds *Datastore) GetSecretByName(orgId uuid.UUID, secretName string) (*commo
 				sourceSecret.BucketLocation = secret.Value
 			case "S3_PUBLIC_URL":
 				sourceSecret.PublicURL = secret.Value
+			case "S3_ENDPOINT":
+				sourceSecret.Endpoint = secret.Value
 			}
 
 		}
@@ -3103,7 +3930,12 @@ func (ds *Datastore) CreateModelReview(modelUUID uuid.UUID, userUUID uuid.UUID,
 		IsComplete:  isComplete,
 		IsAccepted:  isAccepted,
 	}
-	err := ds.DB.Create(&review).Find(&review).Error
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&review).Find(&review).Error; err != nil {
+			return err
+		}
+		return recordReview(tx, "model_version_uuid", review.Model.UUID, streamModelReview(review))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -3177,7 +4009,7 @@ func (ds *Datastore) UpdateModelReview(reviewUUID uuid.UUID, updatedAttributes m
 		if result.Error != nil {
 			return result.Error
 		}
-		return nil
+		return recordReview(tx, "model_version_uuid", review.Model.UUID, streamModelReview(review))
 	})
 	if err != nil {
 		return nil, err
@@ -3322,7 +4154,12 @@ func (ds *Datastore) CreateDatasetReview(datasetUUID uuid.UUID, userUUID uuid.UU
 		IsComplete:  isComplete,
 		IsAccepted:  isAccepted,
 	}
-	err := ds.DB.Create(&review).Find(&review).Error
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&review).Find(&review).Error; err != nil {
+			return err
+		}
+		return recordReview(tx, "dataset_version_uuid", review.Dataset.UUID, streamDatasetReview(review))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -3397,7 +4234,7 @@ func (ds *Datastore) UpdateDatasetReview(reviewUUID uuid.UUID, updatedAttributes
 		if result.Error != nil {
 			return result.Error
 		}
-		return nil
+		return recordReview(tx, "dataset_version_uuid", review.Dataset.UUID, streamDatasetReview(review))
 	})
 	if err != nil {
 		return nil, err
@@ -3429,3 +4266,3577 @@ func (ds *Datastore) UpdateDatasetReview(reviewUUID uuid.UUID, updatedAttributes
 		},
 	}, nil
 }
+
+/////////////////////////////// UPLOAD SESSION METHODS ///////////////////////////////
+
+func (ds *Datastore) CreateModelUploadSession(orgId uuid.UUID, modelBranchUUID uuid.UUID, fileName string, storage string, userUUID uuid.UUID) (*models.UploadSessionResponse, error) {
+	session := dbmodels.UploadSession{
+		OrganizationUUID: orgId,
+		ModelBranchUUID: uuid.NullUUID{
+			UUID:  modelBranchUUID,
+			Valid: true,
+		},
+		FileName:  fileName,
+		Storage:   storage,
+		CreatedBy: userUUID,
+	}
+	err := ds.DB.Create(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return ds.getUploadSession(ds.DB.Where("uuid = ?", session.UUID))
+}
+
+func (ds *Datastore) CreateDatasetUploadSession(orgId uuid.UUID, datasetBranchUUID uuid.UUID, fileName string, storage string, userUUID uuid.UUID) (*models.UploadSessionResponse, error) {
+	session := dbmodels.UploadSession{
+		OrganizationUUID: orgId,
+		DatasetBranchUUID: uuid.NullUUID{
+			UUID:  datasetBranchUUID,
+			Valid: true,
+		},
+		FileName:  fileName,
+		Storage:   storage,
+		CreatedBy: userUUID,
+	}
+	err := ds.DB.Create(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return ds.getUploadSession(ds.DB.Where("uuid = ?", session.UUID))
+}
+
+func (ds *Datastore) GetModelUploadSession(modelBranchUUID uuid.UUID, sessionUUID uuid.UUID) (*models.UploadSessionResponse, error) {
+	return ds.getUploadSession(ds.DB.Where("uuid = ?", sessionUUID).Where("model_branch_uuid = ?", modelBranchUUID))
+}
+
+func (ds *Datastore) GetDatasetUploadSession(datasetBranchUUID uuid.UUID, sessionUUID uuid.UUID) (*models.UploadSessionResponse, error) {
+	return ds.getUploadSession(ds.DB.Where("uuid = ?", sessionUUID).Where("dataset_branch_uuid = ?", datasetBranchUUID))
+}
+
+func (ds *Datastore) getUploadSession(query *gorm.DB) (*models.UploadSessionResponse, error) {
+	var session dbmodels.UploadSession
+	res := query.Preload("CreatedByUser").Preload("Chunks", func(db *gorm.DB) *gorm.DB {
+		return db.Order("number ASC")
+	}).Limit(1).Find(&session)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	chunks := []models.UploadChunkResponse{}
+	ranges := []models.UploadChunkRangeResponse{}
+	var receivedBytes int64
+	for _, chunk := range session.Chunks {
+		chunks = append(chunks, models.UploadChunkResponse{
+			Number: chunk.Number,
+			Size:   chunk.Size,
+		})
+		receivedBytes += chunk.Size
+		if len(ranges) > 0 && ranges[len(ranges)-1].End == chunk.Number-1 {
+			ranges[len(ranges)-1].End = chunk.Number
+		} else {
+			ranges = append(ranges, models.UploadChunkRangeResponse{
+				Start: chunk.Number,
+				End:   chunk.Number,
+			})
+		}
+	}
+	return &models.UploadSessionResponse{
+		UUID:           session.UUID,
+		FileName:       session.FileName,
+		Storage:        session.Storage,
+		IsComplete:     session.IsComplete,
+		Chunks:         chunks,
+		ReceivedRanges: ranges,
+		ReceivedBytes:  receivedBytes,
+		CreatedBy: userorgmodels.UserHandleResponse{
+			UUID:   session.CreatedByUser.UUID,
+			Handle: session.CreatedByUser.Handle,
+			Name:   session.CreatedByUser.Name,
+			Avatar: session.CreatedByUser.Avatar,
+			Email:  session.CreatedByUser.Email,
+		},
+		CreatedAt: session.CreatedAt,
+	}, nil
+}
+
+func (ds *Datastore) CreateUploadChunk(sessionUUID uuid.UUID, number int, size int64) (*models.UploadChunkResponse, error) {
+	var chunk dbmodels.UploadChunk
+	err := ds.DB.Where("session_uuid = ?", sessionUUID).Where("number = ?", number).First(&chunk).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	chunk.SessionUUID = sessionUUID
+	chunk.Number = number
+	chunk.Size = size
+	err = ds.DB.Save(&chunk).Error
+	if err != nil {
+		return nil, err
+	}
+	return &models.UploadChunkResponse{
+		Number: chunk.Number,
+		Size:   chunk.Size,
+	}, nil
+}
+
+// ClaimUploadSession marks the upload session as complete unless it already
+// is and reports whether it did, so that a single finalize registers it.
+func (ds *Datastore) ClaimUploadSession(sessionUUID uuid.UUID) (bool, error) {
+	res := ds.DB.Model(&dbmodels.UploadSession{}).Where("uuid = ?", sessionUUID).Where("is_complete = ?", false).Update("is_complete", true)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ReleaseUploadSession reopens an upload session claimed by a finalize that
+// failed, so that its chunks can be uploaded again.
+func (ds *Datastore) ReleaseUploadSession(sessionUUID uuid.UUID) error {
+	return ds.DB.Model(&dbmodels.UploadSession{}).Where("uuid = ?", sessionUUID).Update("is_complete", false).Error
+}
+
+/////////////////////////////// BLOB METHODS ///////////////////////////////
+
+// BlobKey returns the content addressed storage key of a blob of the org
+// with the given sha256 digest.
+func BlobKey(orgId uuid.UUID, digest string) string {
+	digest = strings.ToLower(digest)
+	if len(digest) < 4 {
+		return fmt.Sprintf("blobs/%s/sha256/%s", orgId, digest)
+	}
+	return fmt.Sprintf("blobs/%s/sha256/%s/%s/%s", orgId, digest[0:2], digest[2:4], digest)
+}
+
+func newBlobResponse(blob *dbmodels.Blob) *models.BlobResponse {
+	return &models.BlobResponse{
+		UUID:       blob.UUID,
+		SourceType: blob.SourceType,
+		Digest:     blob.Digest,
+		Key:        blob.Key,
+		Size:       blob.Size,
+		RefCount:   blob.RefCount,
+		SourceURL:  blob.SourceURL,
+		DataKey:    VersionDataKey(blob.EncryptionKey, blob.EncryptionKeyVersion),
+	}
+}
+
+// ReferenceBlob takes a new reference on an already stored blob of the org.
+// Returns nil if the source has no blob with the digest, or if its content
+// is still being stored.
+func (ds *Datastore) ReferenceBlob(orgId uuid.UUID, sourceType string, sourceURL string, digest string) (*models.BlobResponse, error) {
+	var blob dbmodels.Blob
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("organization_uuid = ? AND source_type = ? AND source_url = ? AND digest = ? AND is_pending = ?", orgId, sourceType, sourceURL, strings.ToLower(digest), false).Limit(1).Find(&blob)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		blob.RefCount++
+		return tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if blob.UUID == uuid.Nil {
+		return nil, nil
+	}
+	response := newBlobResponse(&blob)
+	if response.DataKey == nil {
+		response.DataKey, err = blobDataKey(ds.DB, &blob)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// GetBlob returns the blob of the org with the digest without referencing
+// it, nil if the source doesn't store it.
+func (ds *Datastore) GetBlob(orgId uuid.UUID, sourceType string, sourceURL string, digest string) (*models.BlobResponse, error) {
+	var blob dbmodels.Blob
+	res := ds.DB.Where("organization_uuid = ? AND source_type = ? AND source_url = ? AND digest = ?", orgId, sourceType, sourceURL, strings.ToLower(digest)).Limit(1).Find(&blob)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	response := newBlobResponse(&blob)
+	dataKey, err := blobDataKey(ds.DB, &blob)
+	if err != nil {
+		return nil, err
+	}
+	response.DataKey = dataKey
+	return response, nil
+}
+
+// blobDataKey returns the data key encrypting the blob content, which is
+// shared by all the versions referencing the blob. Returns nil for plain blobs.
+// Blobs stored before their key was recorded get it from their versions.
+func blobDataKey(tx *gorm.DB, blob *dbmodels.Blob) (*commonmodels.DataKey, error) {
+	if blob.EncryptionKey != "" {
+		return VersionDataKey(blob.EncryptionKey, blob.EncryptionKeyVersion), nil
+	}
+	blobUUID := blob.UUID
+	var modelVersion modeldbmodels.ModelVersion
+	res := tx.Unscoped().Where("blob_uuid = ?", blobUUID).Limit(1).Find(&modelVersion)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return VersionDataKey(modelVersion.EncryptionKey, modelVersion.EncryptionKeyVersion), nil
+	}
+	var datasetVersion datasetdbmodels.DatasetVersion
+	res = tx.Unscoped().Where("blob_uuid = ?", blobUUID).Limit(1).Find(&datasetVersion)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return VersionDataKey(datasetVersion.EncryptionKey, datasetVersion.EncryptionKeyVersion), nil
+	}
+	var versionFile dbmodels.VersionFile
+	res = tx.Unscoped().Where("blob_uuid = ?", blobUUID).Limit(1).Find(&versionFile)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return VersionDataKey(versionFile.EncryptionKey, versionFile.EncryptionKeyVersion), nil
+}
+
+// ErrBlobPending is returned when the blob is being stored by a concurrent
+// registration of the same content.
+var ErrBlobPending = errors.New("blob is being stored")
+
+// pendingBlobTimeout is the time after which a blob still pending is
+// considered abandoned (eg. on a crash while storing its content).
+const pendingBlobTimeout = time.Hour
+
+// AcquireBlob takes a reference on the blob of the org with the digest,
+// creating it if the source doesn't store it yet. The returned flag reports
+// whether the blob was created, in which case the caller must store its
+// content encrypted with dataKey (if not nil) and register a version of
+// it. The blob is pending, and can't be referenced, until the version is
+// registered.
+func (ds *Datastore) AcquireBlob(orgId uuid.UUID, sourceType string, sourceURL string, digest string, size int64, dataKey *commonmodels.DataKey) (*models.BlobResponse, bool, error) {
+	blob, err := ds.ReferenceBlob(orgId, sourceType, sourceURL, digest)
+	if err != nil || blob != nil {
+		return blob, false, err
+	}
+	newBlob := dbmodels.Blob{
+		OrganizationUUID: orgId,
+		SourceType:       sourceType,
+		SourceURL:        sourceURL,
+		Digest:           strings.ToLower(digest),
+		Key:              BlobKey(orgId, digest),
+		Size:             size,
+		RefCount:         1,
+		IsPending:        true,
+	}
+	if dataKey != nil {
+		newBlob.EncryptionKey = dataKey.WrappedKey
+		newBlob.EncryptionKeyVersion = dataKey.KeyVersion
+	}
+	if err := ds.DB.Create(&newBlob).Error; err != nil {
+		// the blob may have been created concurrently
+		blob, referr := ds.ReferenceBlob(orgId, sourceType, sourceURL, digest)
+		if referr != nil {
+			return nil, false, referr
+		}
+		if blob != nil {
+			return blob, false, nil
+		}
+		// or still be pending, in which case it is taken over once abandoned
+		var pendingBlob dbmodels.Blob
+		res := ds.DB.Where("organization_uuid = ? AND source_type = ? AND source_url = ? AND digest = ? AND is_pending = ?", orgId, sourceType, sourceURL, strings.ToLower(digest), true).Limit(1).Find(&pendingBlob)
+		if res.Error != nil {
+			return nil, false, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil, false, err
+		}
+		res = ds.DB.Model(&pendingBlob).Where("updated_at < ?", time.Now().Add(-pendingBlobTimeout)).Updates(map[string]interface{}{
+			"size":                   size,
+			"encryption_key":         newBlob.EncryptionKey,
+			"encryption_key_version": newBlob.EncryptionKeyVersion,
+			"updated_at":             time.Now(),
+		})
+		if res.Error != nil {
+			return nil, false, res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil, false, ErrBlobPending
+		}
+		pendingBlob.Size = size
+		pendingBlob.EncryptionKey = newBlob.EncryptionKey
+		pendingBlob.EncryptionKeyVersion = newBlob.EncryptionKeyVersion
+		return newBlobResponse(&pendingBlob), true, nil
+	}
+	return newBlobResponse(&newBlob), true, nil
+}
+
+// CompleteBlob marks the blob created by AcquireBlob as stored, so that
+// it can be referenced. Registering a version of the blob completes it.
+func (ds *Datastore) CompleteBlob(blobUUID uuid.UUID) error {
+	return completeBlobs(ds.DB, uuid.NullUUID{UUID: blobUUID, Valid: true})
+}
+
+// completeBlobs marks the blobs of a registered version as stored.
+func completeBlobs(tx *gorm.DB, blobUUIDs ...uuid.NullUUID) error {
+	for _, blobUUID := range blobUUIDs {
+		if !blobUUID.Valid {
+			continue
+		}
+		err := tx.Model(&dbmodels.Blob{}).Where("uuid = ?", blobUUID.UUID).Where("is_pending = ?", true).Update("is_pending", false).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetainBlob takes another reference on a blob the caller already
+// references, pending or not.
+func (ds *Datastore) RetainBlob(blobUUID uuid.UUID) error {
+	return ds.DB.Model(&dbmodels.Blob{}).Where("uuid = ?", blobUUID).Update("ref_count", gorm.Expr("ref_count + 1")).Error
+}
+
+// ReleaseBlob drops a reference on the blob. The blob is returned once it
+// is no longer referenced so that the caller can remove its content.
+func (ds *Datastore) ReleaseBlob(blobUUID uuid.UUID) (*models.BlobResponse, error) {
+	var unreferenced *models.BlobResponse
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		unreferenced, err = releaseBlob(tx, blobUUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unreferenced, nil
+}
+
+func releaseBlob(tx *gorm.DB, blobUUID uuid.UUID) (*models.BlobResponse, error) {
+	var blob dbmodels.Blob
+	res := tx.Where("uuid = ?", blobUUID).Limit(1).Find(&blob)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, res.Error
+	}
+	if blob.RefCount > 1 {
+		return nil, tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+	}
+	if err := tx.Unscoped().Delete(&blob).Error; err != nil {
+		return nil, err
+	}
+	if err := removeStorageUsage(tx, blob.OrganizationUUID, blob.SourceType, blob.SourceURL, blob.Key); err != nil {
+		return nil, err
+	}
+	blob.RefCount = 0
+	return newBlobResponse(&blob), nil
+}
+
+// releaseBlobs drops a reference on each of the blobs, returning the ones
+// that are no longer referenced.
+func releaseBlobs(tx *gorm.DB, blobUUIDs []uuid.NullUUID) ([]*models.BlobResponse, error) {
+	var unreferenced []*models.BlobResponse
+	for _, blobUUID := range blobUUIDs {
+		if !blobUUID.Valid {
+			continue
+		}
+		blob, err := releaseBlob(tx, blobUUID.UUID)
+		if err != nil {
+			return nil, err
+		}
+		if blob != nil {
+			unreferenced = append(unreferenced, blob)
+		}
+	}
+	return unreferenced, nil
+}
+
+// DeleteModelVersion permanently deletes the model version with its logs,
+// files, tags and stage transitions, and releases their blobs. The blobs
+// that are no longer referenced are returned.
+func (ds *Datastore) DeleteModelVersion(modelVersionUUID uuid.UUID) ([]*models.BlobResponse, error) {
+	var unreferenced []*models.BlobResponse
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		unreferenced, _, err = purgeModelVersion(tx, modelVersionUUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unreferenced, nil
+}
+
+// purgeModelVersion permanently deletes the model version, trashed or not,
+// see DeleteModelVersion. The objects of its file logs are returned along
+// with the unreferenced blobs.
+func purgeModelVersion(tx *gorm.DB, modelVersionUUID uuid.UUID) ([]*models.BlobResponse, []models.StoredObjectResponse, error) {
+	var modelVersion modeldbmodels.ModelVersion
+	if err := tx.Unscoped().Where("uuid = ?", modelVersionUUID).First(&modelVersion).Error; err != nil {
+		return nil, nil, err
+	}
+	fileLogs, err := releaseStoredObjects(tx, "model_version_uuid", modelVersionUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, table := range []interface{}{&dbmodels.Log{}, &dbmodels.Metric{}, &dbmodels.StreamEvent{}, &dbmodels.StorageUsage{}, &dbmodels.Tag{}, &dbmodels.VersionAliasEvent{}, &modeldbmodels.ModelStageTransition{}} {
+		if err := tx.Unscoped().Where("model_version_uuid = ?", modelVersionUUID).Delete(table).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := deleteReviews(tx, &modeldbmodels.ModelReview{}, "model_review_uuid", "from_branch_version_uuid = ?", modelVersionUUID); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Unscoped().Delete(&modelVersion).Error; err != nil {
+		return nil, nil, err
+	}
+	blobUUIDs := []uuid.NullUUID{modelVersion.BlobUUID}
+	var versionFiles []dbmodels.VersionFile
+	if err := tx.Where("model_version_uuid = ?", modelVersionUUID).Find(&versionFiles).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, versionFile := range versionFiles {
+		blobUUIDs = append(blobUUIDs, versionFile.BlobUUID)
+	}
+	if err := tx.Unscoped().Where("model_version_uuid = ?", modelVersionUUID).Delete(&dbmodels.VersionFile{}).Error; err != nil {
+		return nil, nil, err
+	}
+	unreferenced, err := releaseBlobs(tx, blobUUIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return unreferenced, fileLogs, nil
+}
+
+// DeleteDatasetVersion permanently deletes the dataset version with its
+// logs, files, lineage and tags, and releases their blobs. The blobs that
+// are no longer referenced are returned.
+func (ds *Datastore) DeleteDatasetVersion(datasetVersionUUID uuid.UUID) ([]*models.BlobResponse, error) {
+	var unreferenced []*models.BlobResponse
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		unreferenced, _, err = purgeDatasetVersion(tx, datasetVersionUUID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unreferenced, nil
+}
+
+// purgeDatasetVersion permanently deletes the dataset version, trashed or
+// not, see DeleteDatasetVersion. The objects of its file logs are returned
+// along with the unreferenced blobs.
+func purgeDatasetVersion(tx *gorm.DB, datasetVersionUUID uuid.UUID) ([]*models.BlobResponse, []models.StoredObjectResponse, error) {
+	var datasetVersion datasetdbmodels.DatasetVersion
+	if err := tx.Unscoped().Where("uuid = ?", datasetVersionUUID).First(&datasetVersion).Error; err != nil {
+		return nil, nil, err
+	}
+	fileLogs, err := releaseStoredObjects(tx, "dataset_version_uuid", datasetVersionUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, table := range []interface{}{&dbmodels.Log{}, &dbmodels.Metric{}, &dbmodels.StreamEvent{}, &dbmodels.StorageUsage{}, &dbmodels.Tag{}, &dbmodels.VersionAliasEvent{}} {
+		if err := tx.Unscoped().Where("dataset_version_uuid = ?", datasetVersionUUID).Delete(table).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := deleteReviews(tx, &datasetdbmodels.DatasetReview{}, "dataset_review_uuid", "from_branch_version_uuid = ?", datasetVersionUUID); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Unscoped().Delete(&datasetVersion).Error; err != nil {
+		return nil, nil, err
+	}
+	if datasetVersion.LineageUUID.Valid {
+		if err := tx.Unscoped().Where("uuid = ?", datasetVersion.LineageUUID.UUID).Delete(&datasetdbmodels.Lineage{}).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	blobUUIDs := []uuid.NullUUID{datasetVersion.BlobUUID}
+	var versionFiles []dbmodels.VersionFile
+	if err := tx.Where("dataset_version_uuid = ?", datasetVersionUUID).Find(&versionFiles).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, versionFile := range versionFiles {
+		blobUUIDs = append(blobUUIDs, versionFile.BlobUUID)
+	}
+	if err := tx.Unscoped().Where("dataset_version_uuid = ?", datasetVersionUUID).Delete(&dbmodels.VersionFile{}).Error; err != nil {
+		return nil, nil, err
+	}
+	unreferenced, err := releaseBlobs(tx, blobUUIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return unreferenced, fileLogs, nil
+}
+
+// storedObjects returns the objects whose storage usage is recorded for the
+// model, dataset or version in the column, which are the files of its file
+// logs.
+func storedObjects(tx *gorm.DB, column string, ownerUUID uuid.UUID) ([]models.StoredObjectResponse, error) {
+	var usages []dbmodels.StorageUsage
+	if err := tx.Where(column+" = ?", ownerUUID).Find(&usages).Error; err != nil {
+		return nil, err
+	}
+	objects := []models.StoredObjectResponse{}
+	for _, usage := range usages {
+		objects = append(objects, models.StoredObjectResponse{
+			SourceType: usage.SourceType,
+			SourceURL:  usage.SourceURL,
+			Key:        usage.Key,
+		})
+	}
+	return objects, nil
+}
+
+// releaseStoredObjects returns the objects of the file logs of the version
+// in the column that no log of another version references. Copied versions
+// share the objects of their logs, so the usage of a shared object is handed
+// over to a version still referencing it, which deletes it when purged.
+func releaseStoredObjects(tx *gorm.DB, column string, versionUUID uuid.UUID) ([]models.StoredObjectResponse, error) {
+	var usages []dbmodels.StorageUsage
+	if err := tx.Where(column+" = ?", versionUUID).Find(&usages).Error; err != nil {
+		return nil, err
+	}
+	objects := []models.StoredObjectResponse{}
+	for _, usage := range usages {
+		var log dbmodels.Log
+		res := tx.Unscoped().
+			Where(column+" IS NOT NULL AND "+column+" <> ?", versionUUID).
+			Where("data IN ?", []string{fmt.Sprintf("%s/%s", usage.SourceURL, usage.Key), SourcePath(usage.SourceURL, usage.Key)}).
+			Limit(1).Find(&log)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			objects = append(objects, models.StoredObjectResponse{
+				SourceType: usage.SourceType,
+				SourceURL:  usage.SourceURL,
+				Key:        usage.Key,
+			})
+			continue
+		}
+		owner := log.ModelVersionUUID
+		if column == "dataset_version_uuid" {
+			owner = log.DatasetVersionUUID
+		}
+		if err := tx.Model(&dbmodels.StorageUsage{}).Where("uuid = ?", usage.UUID).Update(column, owner).Error; err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+/////////////////////////////// STORAGE REFERENCE METHODS ///////////////////////////////
+
+// GetOrgStorageReferences returns the recorded paths of every model and
+// dataset version of the org (soft deleted ones included) and of their
+// files, the data of their logs and the keys of the org blobs.
+func (ds *Datastore) GetOrgStorageReferences(orgId uuid.UUID) ([]string, error) {
+	modelVersions := func() *gorm.DB {
+		return ds.DB.Unscoped().Model(&modeldbmodels.ModelVersion{}).
+			Joins("JOIN model_branches ON model_branches.uuid = model_versions.branch_uuid").
+			Joins("JOIN models ON models.uuid = model_branches.model_uuid").
+			Where("models.organization_uuid = ?", orgId)
+	}
+	datasetVersions := func() *gorm.DB {
+		return ds.DB.Unscoped().Model(&datasetdbmodels.DatasetVersion{}).
+			Joins("JOIN dataset_branches ON dataset_branches.uuid = dataset_versions.branch_uuid").
+			Joins("JOIN datasets ON datasets.uuid = dataset_branches.dataset_uuid").
+			Where("datasets.organization_uuid = ?", orgId)
+	}
+	queries := []struct {
+		query  *gorm.DB
+		column string
+	}{
+		{modelVersions(), "model_versions.path"},
+		{datasetVersions(), "dataset_versions.path"},
+		{ds.DB.Unscoped().Model(&dbmodels.Log{}).Where("model_version_uuid IN (?)", modelVersions().Select("model_versions.uuid")), "data"},
+		{ds.DB.Unscoped().Model(&dbmodels.Log{}).Where("dataset_version_uuid IN (?)", datasetVersions().Select("dataset_versions.uuid")), "data"},
+		{ds.DB.Unscoped().Model(&dbmodels.VersionFile{}).Where("model_version_uuid IN (?)", modelVersions().Select("model_versions.uuid")), "path"},
+		{ds.DB.Unscoped().Model(&dbmodels.VersionFile{}).Where("dataset_version_uuid IN (?)", datasetVersions().Select("dataset_versions.uuid")), "path"},
+		{ds.DB.Model(&dbmodels.Blob{}).Where("organization_uuid = ?", orgId), "key"},
+		// sources of migrated files are kept until the migration is confirmed
+		{ds.DB.Model(&dbmodels.StorageMigrationItem{}).
+			Joins("JOIN storage_migrations ON storage_migrations.uuid = storage_migration_items.migration_uuid").
+			Where("storage_migrations.organization_uuid = ? AND storage_migration_items.status <> ?", orgId, models.StorageMigrationItemDeleted), "storage_migration_items.source_key"},
+	}
+	var references []string
+	for _, q := range queries {
+		var values []string
+		if err := q.query.Pluck(q.column, &values).Error; err != nil {
+			return nil, err
+		}
+		references = append(references, values...)
+	}
+	return references, nil
+}
+
+// GetOrgOpenUploadSessions returns the uuids of the upload sessions of the
+// org that are not finalized yet.
+func (ds *Datastore) GetOrgOpenUploadSessions(orgId uuid.UUID) ([]uuid.UUID, error) {
+	var sessions []dbmodels.UploadSession
+	err := ds.DB.Select("uuid").Where("organization_uuid = ? AND is_complete = ?", orgId, false).Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	sessionUUIDs := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		sessionUUIDs = append(sessionUUIDs, session.UUID)
+	}
+	return sessionUUIDs, nil
+}
+
+/////////////////////////////// STORAGE USAGE METHODS ///////////////////////////////
+
+// RecordModelStorageUsage records an object stored for the model. The
+// version is set for objects owned by a single version (e.g. log files).
+func (ds *Datastore) RecordModelStorageUsage(orgId uuid.UUID, modelUUID uuid.UUID, modelVersionUUID uuid.NullUUID, sourceType string, sourceURL string, key string, size int64) error {
+	return recordStorageUsage(ds.DB, &dbmodels.StorageUsage{
+		OrganizationUUID: orgId,
+		SourceType:       sourceType,
+		SourceURL:        sourceURL,
+		Key:              key,
+		Size:             size,
+		ModelUUID:        uuid.NullUUID{UUID: modelUUID, Valid: true},
+		ModelVersionUUID: modelVersionUUID,
+	})
+}
+
+// RecordDatasetStorageUsage records an object stored for the dataset. The
+// version is set for objects owned by a single version (e.g. log files).
+func (ds *Datastore) RecordDatasetStorageUsage(orgId uuid.UUID, datasetUUID uuid.UUID, datasetVersionUUID uuid.NullUUID, sourceType string, sourceURL string, key string, size int64) error {
+	return recordStorageUsage(ds.DB, &dbmodels.StorageUsage{
+		OrganizationUUID:   orgId,
+		SourceType:         sourceType,
+		SourceURL:          sourceURL,
+		Key:                key,
+		Size:               size,
+		DatasetUUID:        uuid.NullUUID{UUID: datasetUUID, Valid: true},
+		DatasetVersionUUID: datasetVersionUUID,
+	})
+}
+
+func recordStorageUsage(tx *gorm.DB, usage *dbmodels.StorageUsage) error {
+	// an overwritten object replaces the recorded size
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_uuid"}, {Name: "source_type"}, {Name: "source_url"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "updated_at"}),
+	}).Create(usage).Error
+}
+
+// RemoveStorageUsage removes the usage recorded for a deleted object.
+func (ds *Datastore) RemoveStorageUsage(orgId uuid.UUID, sourceType string, sourceURL string, key string) error {
+	return removeStorageUsage(ds.DB, orgId, sourceType, sourceURL, key)
+}
+
+func removeStorageUsage(tx *gorm.DB, orgId uuid.UUID, sourceType string, sourceURL string, key string) error {
+	return tx.Unscoped().Where("organization_uuid = ? AND source_type = ? AND source_url = ? AND key = ?", orgId, sourceType, sourceURL, key).Delete(&dbmodels.StorageUsage{}).Error
+}
+
+// GetOrgStorageUsage returns the bytes stored by the org, per model and per
+// dataset, along with the org quotas. Returns nil if the org doesn't exist.
+func (ds *Datastore) GetOrgStorageUsage(orgId uuid.UUID) (*models.StorageUsageResponse, error) {
+	var org userorgdbmodels.Organization
+	res := ds.DB.Where("uuid = ?", orgId).Limit(1).Find(&org)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	var usedBytes int64
+	err := ds.DB.Model(&dbmodels.StorageUsage{}).Select("COALESCE(SUM(size), 0)").Where("organization_uuid = ?", orgId).Scan(&usedBytes).Error
+	if err != nil {
+		return nil, err
+	}
+	modelUsages := []models.StorageUsageItemResponse{}
+	err = ds.DB.Model(&dbmodels.StorageUsage{}).
+		Select("models.uuid AS uuid, models.name AS name, SUM(storage_usages.size) AS used_bytes").
+		Joins("JOIN models ON models.uuid = storage_usages.model_uuid").
+		Where("storage_usages.organization_uuid = ?", orgId).
+		Group("models.uuid, models.name").
+		Order("models.name").
+		Scan(&modelUsages).Error
+	if err != nil {
+		return nil, err
+	}
+	datasetUsages := []models.StorageUsageItemResponse{}
+	err = ds.DB.Model(&dbmodels.StorageUsage{}).
+		Select("datasets.uuid AS uuid, datasets.name AS name, SUM(storage_usages.size) AS used_bytes").
+		Joins("JOIN datasets ON datasets.uuid = storage_usages.dataset_uuid").
+		Where("storage_usages.organization_uuid = ?", orgId).
+		Group("datasets.uuid, datasets.name").
+		Order("datasets.name").
+		Scan(&datasetUsages).Error
+	if err != nil {
+		return nil, err
+	}
+	return &models.StorageUsageResponse{
+		OrgUUID:   orgId,
+		UsedBytes: usedBytes,
+		SoftQuota: org.StorageSoftQuota,
+		HardQuota: org.StorageHardQuota,
+		Models:    modelUsages,
+		Datasets:  datasetUsages,
+	}, nil
+}
+
+/////////////////////////////// STORAGE MIGRATION METHODS ///////////////////////////////
+
+// StorageKey returns the storage key of a recorded path (or file log data)
+// of the source with the given public url. Returns false if the path is
+// not stored in the source.
+func StorageKey(sourcePublicURL string, path string) (string, bool) {
+	if sourcePublicURL == "" {
+		if strings.Contains(path, "://") {
+			return "", false
+		}
+		return strings.TrimPrefix(path, "/"), true
+	}
+	if !strings.HasPrefix(path, sourcePublicURL+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(path, sourcePublicURL+"/"), true
+}
+
+func isOrgRegistryKey(orgId uuid.UUID, key string) bool {
+	return strings.HasPrefix(key, fmt.Sprintf("model-registry/%s/", orgId)) ||
+		strings.HasPrefix(key, fmt.Sprintf("dataset-registry/%s/", orgId))
+}
+
+func newStorageMigrationItemResponse(item *dbmodels.StorageMigrationItem) models.StorageMigrationItemResponse {
+	response := models.StorageMigrationItemResponse{
+		UUID:      item.UUID,
+		SourceKey: item.SourceKey,
+		TargetKey: item.TargetKey,
+		Digest:    item.Digest,
+		Size:      item.Size,
+		Status:    item.Status,
+		Error:     item.Error,
+	}
+	switch {
+	case item.LogUUID.Valid:
+		response.Kind = "log"
+		response.ObjectUUID = item.LogUUID.UUID
+	case item.VersionFileUUID.Valid:
+		response.Kind = "version_file"
+		response.ObjectUUID = item.VersionFileUUID.UUID
+	case item.ModelVersionUUID.Valid:
+		response.Kind = "model_version"
+		response.ObjectUUID = item.ModelVersionUUID.UUID
+	default:
+		response.Kind = "dataset_version"
+		response.ObjectUUID = item.DatasetVersionUUID.UUID
+	}
+	return response
+}
+
+func newStorageMigrationResponse(migration *dbmodels.StorageMigration) *models.StorageMigrationResponse {
+	items := []models.StorageMigrationItemResponse{}
+	for i := range migration.Items {
+		items = append(items, newStorageMigrationItemResponse(&migration.Items[i]))
+	}
+	return &models.StorageMigrationResponse{
+		UUID:          migration.UUID,
+		OrgUUID:       migration.OrganizationUUID,
+		SourceStorage: migration.SourceStorage,
+		TargetStorage: migration.TargetStorage,
+		Status:        migration.Status,
+		Error:         migration.Error,
+		CreatedBy:     migration.CreatedBy,
+		CreatedAt:     migration.CreatedAt,
+		Items:         items,
+	}
+}
+
+// CreateStorageMigration plans the migration of the version files (the
+// files of multi-file versions included) and file logs of the models and
+// datasets (soft deleted versions included) that are stored in the source
+// with the given type and public url. Objects referenced by versions are
+// owned by the organization and are never migrated.
+func (ds *Datastore) CreateStorageMigration(orgId uuid.UUID, sourceStorage string, targetStorage string, sourceType string, sourceURL string, modelUUIDs []uuid.UUID, datasetUUIDs []uuid.UUID, userUUID uuid.UUID) (*models.StorageMigrationResponse, error) {
+	var items []dbmodels.StorageMigrationItem
+	if len(modelUUIDs) > 0 {
+		var branches []modeldbmodels.ModelBranch
+		if err := ds.DB.Unscoped().Where("model_uuid IN ?", modelUUIDs).Find(&branches).Error; err != nil {
+			return nil, err
+		}
+		branchModels := map[uuid.UUID]uuid.UUID{}
+		branchUUIDs := []uuid.UUID{}
+		for _, branch := range branches {
+			branchModels[branch.UUID] = branch.ModelUUID
+			branchUUIDs = append(branchUUIDs, branch.UUID)
+		}
+		var versions []modeldbmodels.ModelVersion
+		if err := ds.DB.Unscoped().Where("branch_uuid IN ?", branchUUIDs).Find(&versions).Error; err != nil {
+			return nil, err
+		}
+		versionModels := map[uuid.UUID]uuid.UUID{}
+		versionUUIDs := []uuid.UUID{}
+		for _, version := range versions {
+			modelUUID := branchModels[version.BranchUUID]
+			versionModels[version.UUID] = modelUUID
+			versionUUIDs = append(versionUUIDs, version.UUID)
+			if version.IsEmpty || version.IsReferenced || version.SourceType != sourceType {
+				continue
+			}
+			key, ok := StorageKey(sourceURL, version.Path)
+			if !ok || key == "" {
+				continue
+			}
+			items = append(items, dbmodels.StorageMigrationItem{
+				ModelUUID:        uuid.NullUUID{UUID: modelUUID, Valid: true},
+				ModelVersionUUID: uuid.NullUUID{UUID: version.UUID, Valid: true},
+				SourceKey:        key,
+				SourceBlobUUID:   version.BlobUUID,
+				Digest:           version.Digest,
+			})
+		}
+		var versionFiles []dbmodels.VersionFile
+		if err := ds.DB.Unscoped().Where("model_version_uuid IN ?", versionUUIDs).Find(&versionFiles).Error; err != nil {
+			return nil, err
+		}
+		for _, versionFile := range versionFiles {
+			if versionFile.SourceType != sourceType {
+				continue
+			}
+			key, ok := StorageKey(sourceURL, versionFile.Path)
+			if !ok || key == "" {
+				continue
+			}
+			items = append(items, dbmodels.StorageMigrationItem{
+				ModelUUID:        uuid.NullUUID{UUID: versionModels[versionFile.ModelVersionUUID.UUID], Valid: true},
+				ModelVersionUUID: versionFile.ModelVersionUUID,
+				VersionFileUUID:  uuid.NullUUID{UUID: versionFile.UUID, Valid: true},
+				SourceKey:        key,
+				SourceBlobUUID:   versionFile.BlobUUID,
+				Digest:           versionFile.Digest,
+			})
+		}
+		var logs []dbmodels.Log
+		if err := ds.DB.Unscoped().Where("model_version_uuid IN ?", versionUUIDs).Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			key, ok := StorageKey(sourceURL, log.Data)
+			if !ok || !isOrgRegistryKey(orgId, key) {
+				continue
+			}
+			items = append(items, dbmodels.StorageMigrationItem{
+				ModelUUID:        uuid.NullUUID{UUID: versionModels[log.ModelVersionUUID.UUID], Valid: true},
+				ModelVersionUUID: log.ModelVersionUUID,
+				LogUUID:          uuid.NullUUID{UUID: log.UUID, Valid: true},
+				SourceKey:        key,
+			})
+		}
+	}
+	if len(datasetUUIDs) > 0 {
+		var branches []datasetdbmodels.DatasetBranch
+		if err := ds.DB.Unscoped().Where("dataset_uuid IN ?", datasetUUIDs).Find(&branches).Error; err != nil {
+			return nil, err
+		}
+		branchDatasets := map[uuid.UUID]uuid.UUID{}
+		branchUUIDs := []uuid.UUID{}
+		for _, branch := range branches {
+			branchDatasets[branch.UUID] = branch.DatasetUUID
+			branchUUIDs = append(branchUUIDs, branch.UUID)
+		}
+		var versions []datasetdbmodels.DatasetVersion
+		if err := ds.DB.Unscoped().Where("branch_uuid IN ?", branchUUIDs).Find(&versions).Error; err != nil {
+			return nil, err
+		}
+		versionDatasets := map[uuid.UUID]uuid.UUID{}
+		versionUUIDs := []uuid.UUID{}
+		for _, version := range versions {
+			datasetUUID := branchDatasets[version.BranchUUID]
+			versionDatasets[version.UUID] = datasetUUID
+			versionUUIDs = append(versionUUIDs, version.UUID)
+			if version.IsEmpty || version.IsReferenced || version.SourceType != sourceType {
+				continue
+			}
+			key, ok := StorageKey(sourceURL, version.Path)
+			if !ok || key == "" {
+				continue
+			}
+			items = append(items, dbmodels.StorageMigrationItem{
+				DatasetUUID:        uuid.NullUUID{UUID: datasetUUID, Valid: true},
+				DatasetVersionUUID: uuid.NullUUID{UUID: version.UUID, Valid: true},
+				SourceKey:          key,
+				SourceBlobUUID:     version.BlobUUID,
+				Digest:             version.Digest,
+			})
+		}
+		var versionFiles []dbmodels.VersionFile
+		if err := ds.DB.Unscoped().Where("dataset_version_uuid IN ?", versionUUIDs).Find(&versionFiles).Error; err != nil {
+			return nil, err
+		}
+		for _, versionFile := range versionFiles {
+			if versionFile.SourceType != sourceType {
+				continue
+			}
+			key, ok := StorageKey(sourceURL, versionFile.Path)
+			if !ok || key == "" {
+				continue
+			}
+			items = append(items, dbmodels.StorageMigrationItem{
+				DatasetUUID:        uuid.NullUUID{UUID: versionDatasets[versionFile.DatasetVersionUUID.UUID], Valid: true},
+				DatasetVersionUUID: versionFile.DatasetVersionUUID,
+				VersionFileUUID:    uuid.NullUUID{UUID: versionFile.UUID, Valid: true},
+				SourceKey:          key,
+				SourceBlobUUID:     versionFile.BlobUUID,
+				Digest:             versionFile.Digest,
+			})
+		}
+		var logs []dbmodels.Log
+		if err := ds.DB.Unscoped().Where("dataset_version_uuid IN ?", versionUUIDs).Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			key, ok := StorageKey(sourceURL, log.Data)
+			if !ok || !isOrgRegistryKey(orgId, key) {
+				continue
+			}
+			items = append(items, dbmodels.StorageMigrationItem{
+				DatasetUUID:        uuid.NullUUID{UUID: versionDatasets[log.DatasetVersionUUID.UUID], Valid: true},
+				DatasetVersionUUID: log.DatasetVersionUUID,
+				LogUUID:            uuid.NullUUID{UUID: log.UUID, Valid: true},
+				SourceKey:          key,
+			})
+		}
+	}
+	migration := dbmodels.StorageMigration{
+		OrganizationUUID: orgId,
+		SourceStorage:    sourceStorage,
+		TargetStorage:    targetStorage,
+		Status:           models.StorageMigrationPending,
+		CreatedBy:        userUUID,
+	}
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&migration).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].MigrationUUID = migration.UUID
+			items[i].Status = models.StorageMigrationItemPending
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds.GetStorageMigration(orgId, migration.UUID)
+}
+
+// GetStorageMigration returns the migration of the org with its items.
+// Returns nil if the migration doesn't exist.
+func (ds *Datastore) GetStorageMigration(orgId uuid.UUID, migrationUUID uuid.UUID) (*models.StorageMigrationResponse, error) {
+	var migration dbmodels.StorageMigration
+	res := ds.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("source_key")
+	}).Where("uuid = ? AND organization_uuid = ?", migrationUUID, orgId).Limit(1).Find(&migration)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	response := newStorageMigrationResponse(&migration)
+	if err := ds.loadStorageMigrationDataKeys(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// loadStorageMigrationDataKeys sets the data keys of the migrated
+// version files, which are copied as stored.
+func (ds *Datastore) loadStorageMigrationDataKeys(migration *models.StorageMigrationResponse) error {
+	modelVersionUUIDs := []uuid.UUID{}
+	datasetVersionUUIDs := []uuid.UUID{}
+	versionFileUUIDs := []uuid.UUID{}
+	for _, item := range migration.Items {
+		switch item.Kind {
+		case "model_version":
+			modelVersionUUIDs = append(modelVersionUUIDs, item.ObjectUUID)
+		case "dataset_version":
+			datasetVersionUUIDs = append(datasetVersionUUIDs, item.ObjectUUID)
+		case "version_file":
+			versionFileUUIDs = append(versionFileUUIDs, item.ObjectUUID)
+		}
+	}
+	dataKeys := map[uuid.UUID]*commonmodels.DataKey{}
+	if len(modelVersionUUIDs) > 0 {
+		var versions []modeldbmodels.ModelVersion
+		if err := ds.DB.Unscoped().Where("uuid IN ?", modelVersionUUIDs).Find(&versions).Error; err != nil {
+			return err
+		}
+		for _, version := range versions {
+			dataKeys[version.UUID] = VersionDataKey(version.EncryptionKey, version.EncryptionKeyVersion)
+		}
+	}
+	if len(datasetVersionUUIDs) > 0 {
+		var versions []datasetdbmodels.DatasetVersion
+		if err := ds.DB.Unscoped().Where("uuid IN ?", datasetVersionUUIDs).Find(&versions).Error; err != nil {
+			return err
+		}
+		for _, version := range versions {
+			dataKeys[version.UUID] = VersionDataKey(version.EncryptionKey, version.EncryptionKeyVersion)
+		}
+	}
+	if len(versionFileUUIDs) > 0 {
+		var versionFiles []dbmodels.VersionFile
+		if err := ds.DB.Unscoped().Where("uuid IN ?", versionFileUUIDs).Find(&versionFiles).Error; err != nil {
+			return err
+		}
+		for _, versionFile := range versionFiles {
+			dataKeys[versionFile.UUID] = VersionDataKey(versionFile.EncryptionKey, versionFile.EncryptionKeyVersion)
+		}
+	}
+	for i := range migration.Items {
+		if migration.Items[i].Kind != "log" {
+			migration.Items[i].DataKey = dataKeys[migration.Items[i].ObjectUUID]
+		}
+	}
+	return nil
+}
+
+// GetOrgStorageMigrations returns the migrations of the org, latest first.
+func (ds *Datastore) GetOrgStorageMigrations(orgId uuid.UUID) ([]*models.StorageMigrationResponse, error) {
+	var migrations []dbmodels.StorageMigration
+	err := ds.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("source_key")
+	}).Where("organization_uuid = ?", orgId).Order("created_at DESC").Find(&migrations).Error
+	if err != nil {
+		return nil, err
+	}
+	responses := []*models.StorageMigrationResponse{}
+	for i := range migrations {
+		responses = append(responses, newStorageMigrationResponse(&migrations[i]))
+	}
+	return responses, nil
+}
+
+// UpdateStorageMigrationStatus updates the status of the migration.
+func (ds *Datastore) UpdateStorageMigrationStatus(migrationUUID uuid.UUID, status string, errMessage string) error {
+	return ds.DB.Model(&dbmodels.StorageMigration{}).Where("uuid = ?", migrationUUID).Updates(map[string]interface{}{
+		"status": status,
+		"error":  errMessage,
+	}).Error
+}
+
+// FailStorageMigrationItem records the error of a migration item so
+// that it is retried when the migration is resumed.
+func (ds *Datastore) FailStorageMigrationItem(itemUUID uuid.UUID, errMessage string) error {
+	return ds.DB.Model(&dbmodels.StorageMigrationItem{}).Where("uuid = ?", itemUUID).Updates(map[string]interface{}{
+		"status": models.StorageMigrationItemFailed,
+		"error":  errMessage,
+	}).Error
+}
+
+// CompleteStorageMigrationItem points the migrated version or file log to
+// its copy at targetKey in the target source. A version file copied to its
+// content addressed key is referenced through the target blob. The source
+// file stays referenced until the migration is confirmed.
+func (ds *Datastore) CompleteStorageMigrationItem(orgId uuid.UUID, itemUUID uuid.UUID, targetType string, targetURL string, targetKey string, size int64) error {
+	return ds.DB.Transaction(func(tx *gorm.DB) error {
+		var item dbmodels.StorageMigrationItem
+		if err := tx.Where("uuid = ?", itemUUID).First(&item).Error; err != nil {
+			return err
+		}
+		usage := dbmodels.StorageUsage{
+			OrganizationUUID: orgId,
+			SourceType:       targetType,
+			SourceURL:        targetURL,
+			Key:              targetKey,
+			Size:             size,
+			ModelUUID:        item.ModelUUID,
+			DatasetUUID:      item.DatasetUUID,
+		}
+		if item.LogUUID.Valid {
+			usage.ModelVersionUUID = item.ModelVersionUUID
+			usage.DatasetVersionUUID = item.DatasetVersionUUID
+			err := tx.Unscoped().Model(&dbmodels.Log{}).Where("uuid = ?", item.LogUUID.UUID).Update("data", fmt.Sprintf("%s/%s", targetURL, targetKey)).Error
+			if err != nil {
+				return err
+			}
+			if err := recordStorageUsage(tx, &usage); err != nil {
+				return err
+			}
+		} else {
+			var blobUUID uuid.NullUUID
+			targetBlobExisted := false
+			if item.Digest != "" && targetKey == BlobKey(orgId, item.Digest) {
+				var blob dbmodels.Blob
+				res := tx.Where("organization_uuid = ? AND source_type = ? AND source_url = ? AND digest = ?", orgId, targetType, targetURL, strings.ToLower(item.Digest)).Limit(1).Find(&blob)
+				if res.Error != nil {
+					return res.Error
+				}
+				if res.RowsAffected > 0 {
+					if err := tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count + 1")).Error; err != nil {
+						return err
+					}
+					targetBlobExisted = true
+				} else {
+					blob = dbmodels.Blob{
+						OrganizationUUID: orgId,
+						SourceType:       targetType,
+						SourceURL:        targetURL,
+						Digest:           strings.ToLower(item.Digest),
+						Key:              targetKey,
+						Size:             size,
+						RefCount:         1,
+					}
+					// the copied content keeps the key of the migrated version
+					dataKey, err := migrationItemDataKey(tx, &item)
+					if err != nil {
+						return err
+					}
+					if dataKey != nil {
+						blob.EncryptionKey = dataKey.WrappedKey
+						blob.EncryptionKeyVersion = dataKey.KeyVersion
+					}
+					if err := tx.Create(&blob).Error; err != nil {
+						return err
+					}
+					if err := recordStorageUsage(tx, &usage); err != nil {
+						return err
+					}
+				}
+				blobUUID = uuid.NullUUID{UUID: blob.UUID, Valid: true}
+			} else {
+				usage.ModelVersionUUID = item.ModelVersionUUID
+				usage.DatasetVersionUUID = item.DatasetVersionUUID
+				if err := recordStorageUsage(tx, &usage); err != nil {
+					return err
+				}
+			}
+			attributes := map[string]interface{}{
+				"path":        SourcePath(targetURL, targetKey),
+				"source_type": targetType,
+				"blob_uuid":   blobUUID,
+			}
+			if blobUUID.Valid && targetBlobExisted {
+				// the version now shares the content (and its key) of the target blob
+				var blob dbmodels.Blob
+				if err := tx.Where("uuid = ?", blobUUID.UUID).First(&blob).Error; err != nil {
+					return err
+				}
+				dataKey, err := blobDataKey(tx, &blob)
+				if err != nil {
+					return err
+				}
+				attributes["encryption_key"] = ""
+				attributes["encryption_key_version"] = 0
+				if dataKey != nil {
+					attributes["encryption_key"] = dataKey.WrappedKey
+					attributes["encryption_key_version"] = dataKey.KeyVersion
+				}
+			}
+			var err error
+			switch {
+			case item.VersionFileUUID.Valid:
+				err = tx.Unscoped().Model(&dbmodels.VersionFile{}).Where("uuid = ?", item.VersionFileUUID.UUID).Updates(attributes).Error
+				if err != nil {
+					return err
+				}
+				// a multi-file version is recorded in the storage of its last migrated file
+				versionAttributes := map[string]interface{}{"source_type": targetType}
+				if item.ModelVersionUUID.Valid {
+					err = tx.Unscoped().Model(&modeldbmodels.ModelVersion{}).Where("uuid = ?", item.ModelVersionUUID.UUID).Updates(versionAttributes).Error
+				} else {
+					err = tx.Unscoped().Model(&datasetdbmodels.DatasetVersion{}).Where("uuid = ?", item.DatasetVersionUUID.UUID).Updates(versionAttributes).Error
+				}
+			case item.ModelVersionUUID.Valid:
+				err = tx.Unscoped().Model(&modeldbmodels.ModelVersion{}).Where("uuid = ?", item.ModelVersionUUID.UUID).Updates(attributes).Error
+			default:
+				err = tx.Unscoped().Model(&datasetdbmodels.DatasetVersion{}).Where("uuid = ?", item.DatasetVersionUUID.UUID).Updates(attributes).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Model(&item).Updates(map[string]interface{}{
+			"status":     models.StorageMigrationItemCopied,
+			"target_key": targetKey,
+			"size":       size,
+			"error":      "",
+		}).Error
+	})
+}
+
+// migrationItemDataKey returns the data key of the version or version file
+// migrated by the item, nil if it is plain.
+func migrationItemDataKey(tx *gorm.DB, item *dbmodels.StorageMigrationItem) (*commonmodels.DataKey, error) {
+	switch {
+	case item.VersionFileUUID.Valid:
+		var versionFile dbmodels.VersionFile
+		if err := tx.Unscoped().Where("uuid = ?", item.VersionFileUUID.UUID).First(&versionFile).Error; err != nil {
+			return nil, err
+		}
+		return VersionDataKey(versionFile.EncryptionKey, versionFile.EncryptionKeyVersion), nil
+	case item.ModelVersionUUID.Valid:
+		var modelVersion modeldbmodels.ModelVersion
+		if err := tx.Unscoped().Where("uuid = ?", item.ModelVersionUUID.UUID).First(&modelVersion).Error; err != nil {
+			return nil, err
+		}
+		return VersionDataKey(modelVersion.EncryptionKey, modelVersion.EncryptionKeyVersion), nil
+	default:
+		var datasetVersion datasetdbmodels.DatasetVersion
+		if err := tx.Unscoped().Where("uuid = ?", item.DatasetVersionUUID.UUID).First(&datasetVersion).Error; err != nil {
+			return nil, err
+		}
+		return VersionDataKey(datasetVersion.EncryptionKey, datasetVersion.EncryptionKeyVersion), nil
+	}
+}
+
+// ReleaseStorageMigrationItemSource drops the reference of a copied
+// migration item on its source file. The returned key is no longer
+// referenced and should be deleted from the source.
+func (ds *Datastore) ReleaseStorageMigrationItemSource(orgId uuid.UUID, itemUUID uuid.UUID, sourceType string, sourceURL string) (string, error) {
+	var unreferencedKey string
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		var item dbmodels.StorageMigrationItem
+		if err := tx.Where("uuid = ?", itemUUID).First(&item).Error; err != nil {
+			return err
+		}
+		if item.Status != models.StorageMigrationItemCopied {
+			return nil
+		}
+		if item.SourceBlobUUID.Valid {
+			blob, err := releaseBlob(tx, item.SourceBlobUUID.UUID)
+			if err != nil {
+				return err
+			}
+			if blob != nil {
+				unreferencedKey = blob.Key
+			}
+		} else {
+			if err := removeStorageUsage(tx, orgId, sourceType, sourceURL, item.SourceKey); err != nil {
+				return err
+			}
+			unreferencedKey = item.SourceKey
+		}
+		return tx.Model(&item).Update("status", models.StorageMigrationItemDeleted).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return unreferencedKey, nil
+}
+
+/////////////////////////////// ENCRYPTION METHODS ///////////////////////////////
+
+// GetOrgEncryption returns the encryption settings of the org, nil if the
+// org doesn't exist.
+func (ds *Datastore) GetOrgEncryption(orgId uuid.UUID) (*models.OrgEncryptionResponse, error) {
+	var org userorgdbmodels.Organization
+	res := ds.DB.Where("uuid = ?", orgId).Limit(1).Find(&org)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &models.OrgEncryptionResponse{
+		OrgUUID:    org.UUID,
+		Enabled:    org.EncryptionEnabled,
+		KeyVersion: org.EncryptionKeyVersion,
+	}, nil
+}
+
+// GetOrgVersionDataKeys returns the data keys of the model and dataset
+// versions of the org (soft deleted versions included), of their files and
+// of the blobs of the org that are wrapped with an org key older than
+// keyVersion.
+func (ds *Datastore) GetOrgVersionDataKeys(orgId uuid.UUID, keyVersion int) ([]models.VersionDataKeyResponse, error) {
+	var modelVersions []modeldbmodels.ModelVersion
+	err := ds.DB.Unscoped().Select("model_versions.*").
+		Joins("JOIN model_branches ON model_branches.uuid = model_versions.branch_uuid").
+		Joins("JOIN models ON models.uuid = model_branches.model_uuid").
+		Where("models.organization_uuid = ? AND model_versions.encryption_key <> '' AND model_versions.encryption_key_version < ?", orgId, keyVersion).
+		Find(&modelVersions).Error
+	if err != nil {
+		return nil, err
+	}
+	var datasetVersions []datasetdbmodels.DatasetVersion
+	err = ds.DB.Unscoped().Select("dataset_versions.*").
+		Joins("JOIN dataset_branches ON dataset_branches.uuid = dataset_versions.branch_uuid").
+		Joins("JOIN datasets ON datasets.uuid = dataset_branches.dataset_uuid").
+		Where("datasets.organization_uuid = ? AND dataset_versions.encryption_key <> '' AND dataset_versions.encryption_key_version < ?", orgId, keyVersion).
+		Find(&datasetVersions).Error
+	if err != nil {
+		return nil, err
+	}
+	var versionFiles []dbmodels.VersionFile
+	err = ds.DB.Unscoped().
+		Where("model_version_uuid IN (?) OR dataset_version_uuid IN (?)",
+			ds.DB.Unscoped().Model(&modeldbmodels.ModelVersion{}).Select("model_versions.uuid").
+				Joins("JOIN model_branches ON model_branches.uuid = model_versions.branch_uuid").
+				Joins("JOIN models ON models.uuid = model_branches.model_uuid").
+				Where("models.organization_uuid = ?", orgId),
+			ds.DB.Unscoped().Model(&datasetdbmodels.DatasetVersion{}).Select("dataset_versions.uuid").
+				Joins("JOIN dataset_branches ON dataset_branches.uuid = dataset_versions.branch_uuid").
+				Joins("JOIN datasets ON datasets.uuid = dataset_branches.dataset_uuid").
+				Where("datasets.organization_uuid = ?", orgId)).
+		Where("encryption_key <> '' AND encryption_key_version < ?", keyVersion).
+		Find(&versionFiles).Error
+	if err != nil {
+		return nil, err
+	}
+	var blobs []dbmodels.Blob
+	err = ds.DB.Where("organization_uuid = ? AND encryption_key <> '' AND encryption_key_version < ?", orgId, keyVersion).Find(&blobs).Error
+	if err != nil {
+		return nil, err
+	}
+	dataKeys := []models.VersionDataKeyResponse{}
+	for _, version := range modelVersions {
+		dataKeys = append(dataKeys, models.VersionDataKeyResponse{
+			Kind:    "model_version",
+			UUID:    version.UUID,
+			DataKey: commonmodels.DataKey{WrappedKey: version.EncryptionKey, KeyVersion: version.EncryptionKeyVersion},
+		})
+	}
+	for _, version := range datasetVersions {
+		dataKeys = append(dataKeys, models.VersionDataKeyResponse{
+			Kind:    "dataset_version",
+			UUID:    version.UUID,
+			DataKey: commonmodels.DataKey{WrappedKey: version.EncryptionKey, KeyVersion: version.EncryptionKeyVersion},
+		})
+	}
+	for _, versionFile := range versionFiles {
+		dataKeys = append(dataKeys, models.VersionDataKeyResponse{
+			Kind:    "version_file",
+			UUID:    versionFile.UUID,
+			DataKey: commonmodels.DataKey{WrappedKey: versionFile.EncryptionKey, KeyVersion: versionFile.EncryptionKeyVersion},
+		})
+	}
+	for _, blob := range blobs {
+		dataKeys = append(dataKeys, models.VersionDataKeyResponse{
+			Kind:    "blob",
+			UUID:    blob.UUID,
+			DataKey: commonmodels.DataKey{WrappedKey: blob.EncryptionKey, KeyVersion: blob.EncryptionKeyVersion},
+		})
+	}
+	return dataKeys, nil
+}
+
+// UpdateVersionDataKey replaces the wrapped data key of a model or dataset
+// version (or version file or blob), unless it was changed since it was wrapped with oldKeyVersion.
+func (ds *Datastore) UpdateVersionDataKey(kind string, versionUUID uuid.UUID, oldKeyVersion int, dataKey *commonmodels.DataKey) error {
+	var model interface{} = &modeldbmodels.ModelVersion{}
+	switch kind {
+	case "dataset_version":
+		model = &datasetdbmodels.DatasetVersion{}
+	case "version_file":
+		model = &dbmodels.VersionFile{}
+	case "blob":
+		model = &dbmodels.Blob{}
+	}
+	return ds.DB.Unscoped().Model(model).Where("uuid = ? AND encryption_key_version = ?", versionUUID, oldKeyVersion).Updates(map[string]interface{}{
+		"encryption_key":         dataKey.WrappedKey,
+		"encryption_key_version": dataKey.KeyVersion,
+	}).Error
+}
+
+/////////////////////////////// IMPORT JOB METHODS ///////////////////////////////
+
+func (ds *Datastore) CreateModelImportJob(orgId uuid.UUID, modelBranchUUID uuid.UUID, url string, hash string, hashAlgorithm string, storage string, userUUID uuid.UUID) (*models.ImportJobResponse, error) {
+	job := dbmodels.ImportJob{
+		OrganizationUUID: orgId,
+		ModelBranchUUID:  uuid.NullUUID{UUID: modelBranchUUID, Valid: true},
+		URL:              url,
+		Hash:             hash,
+		HashAlgorithm:    hashAlgorithm,
+		Storage:          storage,
+		Status:           models.ImportPending,
+		CreatedBy:        userUUID,
+	}
+	if err := ds.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return ds.getImportJob(ds.DB.Where("uuid = ?", job.UUID))
+}
+
+func (ds *Datastore) CreateDatasetImportJob(orgId uuid.UUID, datasetBranchUUID uuid.UUID, url string, hash string, hashAlgorithm string, storage string, lineage string, userUUID uuid.UUID) (*models.ImportJobResponse, error) {
+	job := dbmodels.ImportJob{
+		OrganizationUUID:  orgId,
+		DatasetBranchUUID: uuid.NullUUID{UUID: datasetBranchUUID, Valid: true},
+		URL:               url,
+		Hash:              hash,
+		HashAlgorithm:     hashAlgorithm,
+		Storage:           storage,
+		Lineage:           lineage,
+		Status:            models.ImportPending,
+		CreatedBy:         userUUID,
+	}
+	if err := ds.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return ds.getImportJob(ds.DB.Where("uuid = ?", job.UUID))
+}
+
+func (ds *Datastore) GetModelImportJob(modelBranchUUID uuid.UUID, jobUUID uuid.UUID) (*models.ImportJobResponse, error) {
+	return ds.getImportJob(ds.DB.Where("uuid = ?", jobUUID).Where("model_branch_uuid = ?", modelBranchUUID))
+}
+
+func (ds *Datastore) GetDatasetImportJob(datasetBranchUUID uuid.UUID, jobUUID uuid.UUID) (*models.ImportJobResponse, error) {
+	return ds.getImportJob(ds.DB.Where("uuid = ?", jobUUID).Where("dataset_branch_uuid = ?", datasetBranchUUID))
+}
+
+func (ds *Datastore) getImportJob(query *gorm.DB) (*models.ImportJobResponse, error) {
+	var job dbmodels.ImportJob
+	res := query.Preload("CreatedByUser").Limit(1).Find(&job)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &models.ImportJobResponse{
+		UUID:          job.UUID,
+		URL:           job.URL,
+		Hash:          job.Hash,
+		HashAlgorithm: job.HashAlgorithm,
+		Storage:       job.Storage,
+		Lineage:       job.Lineage,
+		Status:        job.Status,
+		Error:         job.Error,
+		Size:          job.Size,
+		Version:       job.Version,
+		CreatedBy: userorgmodels.UserHandleResponse{
+			UUID:   job.CreatedByUser.UUID,
+			Handle: job.CreatedByUser.Handle,
+			Name:   job.CreatedByUser.Name,
+			Avatar: job.CreatedByUser.Avatar,
+			Email:  job.CreatedByUser.Email,
+		},
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}, nil
+}
+
+// UpdateImportJobStatus sets the status of the import job, with the error
+// of a failed import.
+func (ds *Datastore) UpdateImportJobStatus(jobUUID uuid.UUID, status string, errorMessage string) error {
+	return ds.DB.Model(&dbmodels.ImportJob{}).Where("uuid = ?", jobUUID).Updates(map[string]interface{}{
+		"status": status,
+		"error":  errorMessage,
+	}).Error
+}
+
+// CompleteImportJob records the fetched size and the registered version of
+// the import job.
+func (ds *Datastore) CompleteImportJob(jobUUID uuid.UUID, size int64, version string) error {
+	return ds.DB.Model(&dbmodels.ImportJob{}).Where("uuid = ?", jobUUID).Updates(map[string]interface{}{
+		"status":  models.ImportCompleted,
+		"error":   "",
+		"size":    size,
+		"version": version,
+	}).Error
+}
+
+// FailInterruptedImportJobs fails the pending and running import jobs. Jobs
+// run in the server process, those left unfinished by a restart won't
+// complete anymore.
+func (ds *Datastore) FailInterruptedImportJobs() error {
+	return ds.DB.Model(&dbmodels.ImportJob{}).Where("status IN ?", []string{models.ImportPending, models.ImportRunning}).Updates(map[string]interface{}{
+		"status": models.ImportFailed,
+		"error":  "Import interrupted by a server restart",
+	}).Error
+}
+
+/////////////////////////////// VERSION ALIAS METHODS ///////////////////////////////
+
+// SetModelAlias points the alias of the model to the version, creating the
+// alias if needed, and records the move in the alias history.
+func (ds *Datastore) SetModelAlias(modelUUID uuid.UUID, name string, modelVersionUUID uuid.UUID, userUUID uuid.UUID) (*models.VersionAliasResponse, error) {
+	owner := uuid.NullUUID{UUID: modelUUID, Valid: true}
+	version := uuid.NullUUID{UUID: modelVersionUUID, Valid: true}
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setVersionAlias(tx, "model_uuid", dbmodels.VersionAlias{Name: name, ModelUUID: owner, ModelVersionUUID: version, UpdatedBy: userUUID}); err != nil {
+			return err
+		}
+		return tx.Create(&dbmodels.VersionAliasEvent{Name: name, ModelUUID: owner, ModelVersionUUID: version, CreatedBy: userUUID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds.GetModelAlias(modelUUID, name)
+}
+
+// SetDatasetAlias points the alias of the dataset to the version, creating
+// the alias if needed, and records the move in the alias history.
+func (ds *Datastore) SetDatasetAlias(datasetUUID uuid.UUID, name string, datasetVersionUUID uuid.UUID, userUUID uuid.UUID) (*models.VersionAliasResponse, error) {
+	owner := uuid.NullUUID{UUID: datasetUUID, Valid: true}
+	version := uuid.NullUUID{UUID: datasetVersionUUID, Valid: true}
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setVersionAlias(tx, "dataset_uuid", dbmodels.VersionAlias{Name: name, DatasetUUID: owner, DatasetVersionUUID: version, UpdatedBy: userUUID}); err != nil {
+			return err
+		}
+		return tx.Create(&dbmodels.VersionAliasEvent{Name: name, DatasetUUID: owner, DatasetVersionUUID: version, CreatedBy: userUUID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds.GetDatasetAlias(datasetUUID, name)
+}
+
+func setVersionAlias(tx *gorm.DB, ownerColumn string, alias dbmodels.VersionAlias) error {
+	owner := alias.ModelUUID
+	if ownerColumn == "dataset_uuid" {
+		owner = alias.DatasetUUID
+	}
+	res := tx.Model(&dbmodels.VersionAlias{}).Where(ownerColumn+" = ?", owner).Where("name = ?", alias.Name).Updates(map[string]interface{}{
+		"model_version_uuid":   alias.ModelVersionUUID,
+		"dataset_version_uuid": alias.DatasetVersionUUID,
+		"updated_by":           alias.UpdatedBy,
+	})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+	return tx.Create(&alias).Error
+}
+
+func (ds *Datastore) GetModelAlias(modelUUID uuid.UUID, name string) (*models.VersionAliasResponse, error) {
+	var alias dbmodels.VersionAlias
+	res := ds.DB.Where("model_uuid = ?", modelUUID).Where("name = ?", name).Preload("ModelVersion.Branch").Preload("UpdatedByUser").Limit(1).Find(&alias)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	response := versionAliasResponse(alias, alias.ModelVersion.UUID, alias.ModelVersion.Branch.Name, alias.ModelVersion.Version)
+	return &response, nil
+}
+
+func (ds *Datastore) GetDatasetAlias(datasetUUID uuid.UUID, name string) (*models.VersionAliasResponse, error) {
+	var alias dbmodels.VersionAlias
+	res := ds.DB.Where("dataset_uuid = ?", datasetUUID).Where("name = ?", name).Preload("DatasetVersion.Branch").Preload("UpdatedByUser").Limit(1).Find(&alias)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	response := versionAliasResponse(alias, alias.DatasetVersion.UUID, alias.DatasetVersion.Branch.Name, alias.DatasetVersion.Version)
+	return &response, nil
+}
+
+func (ds *Datastore) GetModelAliases(modelUUID uuid.UUID) ([]models.VersionAliasResponse, error) {
+	var aliases []dbmodels.VersionAlias
+	err := ds.DB.Where("model_uuid = ?", modelUUID).Preload("ModelVersion.Branch").Preload("UpdatedByUser").Order("name").Find(&aliases).Error
+	if err != nil {
+		return nil, err
+	}
+	responses := []models.VersionAliasResponse{}
+	for _, alias := range aliases {
+		responses = append(responses, versionAliasResponse(alias, alias.ModelVersion.UUID, alias.ModelVersion.Branch.Name, alias.ModelVersion.Version))
+	}
+	return responses, nil
+}
+
+func (ds *Datastore) GetDatasetAliases(datasetUUID uuid.UUID) ([]models.VersionAliasResponse, error) {
+	var aliases []dbmodels.VersionAlias
+	err := ds.DB.Where("dataset_uuid = ?", datasetUUID).Preload("DatasetVersion.Branch").Preload("UpdatedByUser").Order("name").Find(&aliases).Error
+	if err != nil {
+		return nil, err
+	}
+	responses := []models.VersionAliasResponse{}
+	for _, alias := range aliases {
+		responses = append(responses, versionAliasResponse(alias, alias.DatasetVersion.UUID, alias.DatasetVersion.Branch.Name, alias.DatasetVersion.Version))
+	}
+	return responses, nil
+}
+
+func versionAliasResponse(alias dbmodels.VersionAlias, versionUUID uuid.UUID, branch string, version string) models.VersionAliasResponse {
+	return models.VersionAliasResponse{
+		Name:        alias.Name,
+		Branch:      branch,
+		Version:     version,
+		VersionUUID: versionUUID,
+		UpdatedBy: userorgmodels.UserHandleResponse{
+			UUID:   alias.UpdatedByUser.UUID,
+			Handle: alias.UpdatedByUser.Handle,
+			Name:   alias.UpdatedByUser.Name,
+			Avatar: alias.UpdatedByUser.Avatar,
+			Email:  alias.UpdatedByUser.Email,
+		},
+		UpdatedAt: alias.UpdatedAt,
+	}
+}
+
+// DeleteModelAlias removes the alias of the model and records the removal
+// in the alias history.
+func (ds *Datastore) DeleteModelAlias(modelUUID uuid.UUID, name string, userUUID uuid.UUID) error {
+	return ds.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("model_uuid = ?", modelUUID).Where("name = ?", name).Delete(&dbmodels.VersionAlias{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&dbmodels.VersionAliasEvent{Name: name, ModelUUID: uuid.NullUUID{UUID: modelUUID, Valid: true}, CreatedBy: userUUID}).Error
+	})
+}
+
+// DeleteDatasetAlias removes the alias of the dataset and records the
+// removal in the alias history.
+func (ds *Datastore) DeleteDatasetAlias(datasetUUID uuid.UUID, name string, userUUID uuid.UUID) error {
+	return ds.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("dataset_uuid = ?", datasetUUID).Where("name = ?", name).Delete(&dbmodels.VersionAlias{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&dbmodels.VersionAliasEvent{Name: name, DatasetUUID: uuid.NullUUID{UUID: datasetUUID, Valid: true}, CreatedBy: userUUID}).Error
+	})
+}
+
+// GetModelAliasHistory returns the moves of the alias of the model, latest
+// first.
+func (ds *Datastore) GetModelAliasHistory(modelUUID uuid.UUID, name string) ([]models.VersionAliasEventResponse, error) {
+	var events []dbmodels.VersionAliasEvent
+	err := ds.DB.Where("model_uuid = ?", modelUUID).Where("name = ?", name).Preload("ModelVersion.Branch").Preload("CreatedByUser").Order("created_at desc").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	responses := []models.VersionAliasEventResponse{}
+	for _, event := range events {
+		responses = append(responses, versionAliasEventResponse(event, event.ModelVersion.Branch.Name, event.ModelVersion.Version))
+	}
+	return responses, nil
+}
+
+// GetDatasetAliasHistory returns the moves of the alias of the dataset,
+// latest first.
+func (ds *Datastore) GetDatasetAliasHistory(datasetUUID uuid.UUID, name string) ([]models.VersionAliasEventResponse, error) {
+	var events []dbmodels.VersionAliasEvent
+	err := ds.DB.Where("dataset_uuid = ?", datasetUUID).Where("name = ?", name).Preload("DatasetVersion.Branch").Preload("CreatedByUser").Order("created_at desc").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	responses := []models.VersionAliasEventResponse{}
+	for _, event := range events {
+		responses = append(responses, versionAliasEventResponse(event, event.DatasetVersion.Branch.Name, event.DatasetVersion.Version))
+	}
+	return responses, nil
+}
+
+func versionAliasEventResponse(event dbmodels.VersionAliasEvent, branch string, version string) models.VersionAliasEventResponse {
+	return models.VersionAliasEventResponse{
+		Name:    event.Name,
+		Branch:  branch,
+		Version: version,
+		MovedBy: userorgmodels.UserHandleResponse{
+			UUID:   event.CreatedByUser.UUID,
+			Handle: event.CreatedByUser.Handle,
+			Name:   event.CreatedByUser.Name,
+			Avatar: event.CreatedByUser.Avatar,
+			Email:  event.CreatedByUser.Email,
+		},
+		MovedAt: event.CreatedAt,
+	}
+}
+
+/////////////////////////////// VERSION TAG METHODS ///////////////////////////////
+
+// AddModelVersionTags adds the tags to the model version, keeping the tags
+// it already has, and returns all its tags.
+func (ds *Datastore) AddModelVersionTags(orgId uuid.UUID, modelVersionUUID uuid.UUID, tags []string, userUUID uuid.UUID) ([]string, error) {
+	for _, tag := range tags {
+		err := ds.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&dbmodels.Tag{
+			OrganizationUUID: orgId,
+			ModelVersionUUID: uuid.NullUUID{UUID: modelVersionUUID, Valid: true},
+			Tag:              tag,
+			CreatedBy:        userUUID,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ds.GetModelVersionTags(modelVersionUUID)
+}
+
+// AddDatasetVersionTags adds the tags to the dataset version, keeping the
+// tags it already has, and returns all its tags.
+func (ds *Datastore) AddDatasetVersionTags(orgId uuid.UUID, datasetVersionUUID uuid.UUID, tags []string, userUUID uuid.UUID) ([]string, error) {
+	for _, tag := range tags {
+		err := ds.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&dbmodels.Tag{
+			OrganizationUUID:   orgId,
+			DatasetVersionUUID: uuid.NullUUID{UUID: datasetVersionUUID, Valid: true},
+			Tag:                tag,
+			CreatedBy:          userUUID,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ds.GetDatasetVersionTags(datasetVersionUUID)
+}
+
+func (ds *Datastore) GetModelVersionTags(modelVersionUUID uuid.UUID) ([]string, error) {
+	tags := []string{}
+	err := ds.DB.Model(&dbmodels.Tag{}).Where("model_version_uuid = ?", modelVersionUUID).Order("tag").Pluck("tag", &tags).Error
+	return tags, err
+}
+
+func (ds *Datastore) GetDatasetVersionTags(datasetVersionUUID uuid.UUID) ([]string, error) {
+	tags := []string{}
+	err := ds.DB.Model(&dbmodels.Tag{}).Where("dataset_version_uuid = ?", datasetVersionUUID).Order("tag").Pluck("tag", &tags).Error
+	return tags, err
+}
+
+// GetModelTaggedVersions returns the versions of every branch of the model
+// with the tag, latest first.
+func (ds *Datastore) GetModelTaggedVersions(modelUUID uuid.UUID, tag string) ([]modelmodels.ModelBranchVersionResponse, error) {
+	var modelVersions []modeldbmodels.ModelVersion
+	err := ds.DB.Select("model_versions.*").
+		Joins("JOIN model_branches ON model_branches.uuid = model_versions.branch_uuid").
+		Joins("JOIN tags ON tags.model_version_uuid = model_versions.uuid").
+		Where("model_branches.model_uuid = ?", modelUUID).Where("tags.tag = ?", tag).Where("tags.deleted_at IS NULL").
+		Order("model_versions.created_at desc").Find(&modelVersions).Error
+	if err != nil {
+		return nil, err
+	}
+	versions := []modelmodels.ModelBranchVersionResponse{}
+	for _, modelVersion := range modelVersions {
+		version, err := ds.GetModelBranchVersion(modelVersion.BranchUUID, modelVersion.Version)
+		if err != nil {
+			return nil, err
+		}
+		if version != nil {
+			versions = append(versions, *version)
+		}
+	}
+	return versions, nil
+}
+
+// GetDatasetTaggedVersions returns the versions of every branch of the
+// dataset with the tag, latest first.
+func (ds *Datastore) GetDatasetTaggedVersions(datasetUUID uuid.UUID, tag string) ([]datasetmodels.DatasetBranchVersionResponse, error) {
+	var datasetVersions []datasetdbmodels.DatasetVersion
+	err := ds.DB.Select("dataset_versions.*").
+		Joins("JOIN dataset_branches ON dataset_branches.uuid = dataset_versions.branch_uuid").
+		Joins("JOIN tags ON tags.dataset_version_uuid = dataset_versions.uuid").
+		Where("dataset_branches.dataset_uuid = ?", datasetUUID).Where("tags.tag = ?", tag).Where("tags.deleted_at IS NULL").
+		Order("dataset_versions.created_at desc").Find(&datasetVersions).Error
+	if err != nil {
+		return nil, err
+	}
+	versions := []datasetmodels.DatasetBranchVersionResponse{}
+	for _, datasetVersion := range datasetVersions {
+		version, err := ds.GetDatasetBranchVersion(datasetVersion.BranchUUID, datasetVersion.Version)
+		if err != nil {
+			return nil, err
+		}
+		if version != nil {
+			versions = append(versions, *version)
+		}
+	}
+	return versions, nil
+}
+
+/////////////////////////////// MODEL STAGE METHODS ///////////////////////////////
+
+func (ds *Datastore) SetModelRequireProductionReview(modelUUID uuid.UUID, require bool) error {
+	return ds.DB.Model(&modeldbmodels.Model{}).Where("uuid = ?", modelUUID).Update("require_production_review", require).Error
+}
+
+// HasAcceptedModelReview reports whether a review of the model version was
+// completed and accepted.
+func (ds *Datastore) HasAcceptedModelReview(modelVersionUUID uuid.UUID) (bool, error) {
+	var count int64
+	err := ds.DB.Model(&modeldbmodels.ModelReview{}).Where("from_branch_version_uuid = ?", modelVersionUUID).Where("is_complete = ?", true).Where("is_accepted = ?", true).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// TransitionModelVersionStage moves the model version to the stage and
+// records the transition. A model has at most one production version, so
+// moving a version to production archives the current one.
+func (ds *Datastore) TransitionModelVersionStage(modelUUID uuid.UUID, modelVersionUUID uuid.UUID, toStage string, reason string, userUUID uuid.UUID) (*modelmodels.ModelStageTransitionResponse, error) {
+	var transition modeldbmodels.ModelStageTransition
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		// Transitions of a model are serialized by a write lock on its row, so
+		// that concurrent promotions can not leave two production versions.
+		err := tx.Model(&modeldbmodels.Model{}).Where("uuid = ?", modelUUID).UpdateColumn("uuid", gorm.Expr("uuid")).Error
+		if err != nil {
+			return err
+		}
+		var modelVersion modeldbmodels.ModelVersion
+		err = tx.Where("uuid = ?", modelVersionUUID).Preload("Branch").First(&modelVersion).Error
+		if err != nil {
+			return err
+		}
+		if toStage == modelmodels.ModelStageProduction {
+			var current []modeldbmodels.ModelVersion
+			err = tx.Select("model_versions.*").Joins("JOIN model_branches ON model_branches.uuid = model_versions.branch_uuid").Where("model_branches.model_uuid = ?", modelUUID).Where("model_versions.stage = ?", modelmodels.ModelStageProduction).Where("model_versions.uuid <> ?", modelVersionUUID).Find(&current).Error
+			if err != nil {
+				return err
+			}
+			for _, version := range current {
+				err = setModelVersionStage(tx, modelUUID, version.UUID, version.Stage, modelmodels.ModelStageArchived, fmt.Sprintf("Replaced by %s/%s", modelVersion.Branch.Name, modelVersion.Version), userUUID, &modeldbmodels.ModelStageTransition{})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return setModelVersionStage(tx, modelUUID, modelVersionUUID, modelVersion.Stage, toStage, reason, userUUID, &transition)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = ds.DB.Preload("ModelVersion.Branch").Preload("CreatedByUser").First(&transition, "uuid = ?", transition.UUID).Error
+	if err != nil {
+		return nil, err
+	}
+	response := modelStageTransitionResponse(transition)
+	return &response, nil
+}
+
+func setModelVersionStage(tx *gorm.DB, modelUUID uuid.UUID, modelVersionUUID uuid.UUID, fromStage string, toStage string, reason string, userUUID uuid.UUID, transition *modeldbmodels.ModelStageTransition) error {
+	err := tx.Model(&modeldbmodels.ModelVersion{}).Where("uuid = ?", modelVersionUUID).Update("stage", toStage).Error
+	if err != nil {
+		return err
+	}
+	*transition = modeldbmodels.ModelStageTransition{
+		ModelUUID:        modelUUID,
+		ModelVersionUUID: modelVersionUUID,
+		FromStage:        fromStage,
+		ToStage:          toStage,
+		Reason:           reason,
+		CreatedBy:        userUUID,
+	}
+	return tx.Create(transition).Error
+}
+
+func (ds *Datastore) GetModelStageTransitions(modelUUID uuid.UUID) ([]modelmodels.ModelStageTransitionResponse, error) {
+	return ds.getModelStageTransitions(ds.DB.Where("model_uuid = ?", modelUUID))
+}
+
+func (ds *Datastore) GetModelVersionStageTransitions(modelVersionUUID uuid.UUID) ([]modelmodels.ModelStageTransitionResponse, error) {
+	return ds.getModelStageTransitions(ds.DB.Where("model_version_uuid = ?", modelVersionUUID))
+}
+
+func (ds *Datastore) getModelStageTransitions(query *gorm.DB) ([]modelmodels.ModelStageTransitionResponse, error) {
+	var transitions []modeldbmodels.ModelStageTransition
+	err := query.Preload("ModelVersion.Branch").Preload("CreatedByUser").Order("created_at DESC").Find(&transitions).Error
+	if err != nil {
+		return nil, err
+	}
+	responses := []modelmodels.ModelStageTransitionResponse{}
+	for _, transition := range transitions {
+		responses = append(responses, modelStageTransitionResponse(transition))
+	}
+	return responses, nil
+}
+
+func modelStageTransitionResponse(transition modeldbmodels.ModelStageTransition) modelmodels.ModelStageTransitionResponse {
+	return modelmodels.ModelStageTransitionResponse{
+		UUID: transition.UUID,
+		Branch: modelmodels.ModelBranchNameResponse{
+			UUID: transition.ModelVersion.Branch.UUID,
+			Name: transition.ModelVersion.Branch.Name,
+		},
+		Version: modelmodels.ModelBranchVersionNameResponse{
+			UUID:    transition.ModelVersion.UUID,
+			Version: transition.ModelVersion.Version,
+		},
+		FromStage: transition.FromStage,
+		ToStage:   transition.ToStage,
+		Reason:    transition.Reason,
+		CreatedBy: userorgmodels.UserHandleResponse{
+			UUID:   transition.CreatedByUser.UUID,
+			Handle: transition.CreatedByUser.Handle,
+			Name:   transition.CreatedByUser.Name,
+			Avatar: transition.CreatedByUser.Avatar,
+			Email:  transition.CreatedByUser.Email,
+		},
+		CreatedAt: transition.CreatedAt,
+	}
+}
+
+// GetModelStageVersions returns the versions of every branch of the model
+// in the stage, latest first.
+func (ds *Datastore) GetModelStageVersions(modelUUID uuid.UUID, stage string) ([]modelmodels.ModelBranchVersionResponse, error) {
+	var rows []modeldbmodels.ModelVersion
+	err := ds.DB.Select("model_versions.*").Joins("JOIN model_branches ON model_branches.uuid = model_versions.branch_uuid").Where("model_branches.model_uuid = ?", modelUUID).Where("model_versions.stage = ?", stage).Preload("Branch").Order("model_versions.created_at DESC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	versions := []modelmodels.ModelBranchVersionResponse{}
+	for _, row := range rows {
+		version, err := ds.GetModelBranchVersion(row.BranchUUID, row.Version)
+		if err != nil {
+			return nil, err
+		}
+		if version != nil {
+			versions = append(versions, *version)
+		}
+	}
+	return versions, nil
+}
+
+/////////////////////////////// TRASH METHODS ///////////////////////////////
+
+// ErrTrashedParent is returned when restoring an item whose model, dataset
+// or branch is still in the trash.
+var ErrTrashedParent = errors.New("the parent of the item is in the trash")
+
+// trashItem soft deletes the rows of the item with the deletes and records
+// it in the trash of its org.
+func (ds *Datastore) trashItem(item dbmodels.TrashItem, deletes ...func(tx *gorm.DB) error) (*models.TrashItemResponse, error) {
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		for _, delete := range deletes {
+			if err := delete(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds.GetTrashItem(item.OrganizationUUID, item.UUID)
+}
+
+// TrashModel moves the model to the trash with all its branches and versions.
+func (ds *Datastore) TrashModel(orgId uuid.UUID, modelUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	item := dbmodels.TrashItem{OrganizationUUID: orgId, Kind: models.TrashModel, ObjectUUID: modelUUID, Name: name, DeletedBy: userUUID, PurgeAt: purgeAt}
+	return ds.trashItem(item, func(tx *gorm.DB) error {
+		branches := tx.Model(&modeldbmodels.ModelBranch{}).Select("uuid").Where("model_uuid = ?", modelUUID)
+		if err := tx.Where("branch_uuid IN (?)", branches).Delete(&modeldbmodels.ModelVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("model_uuid = ?", modelUUID).Delete(&modeldbmodels.ModelBranch{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("uuid = ?", modelUUID).Delete(&modeldbmodels.Model{}).Error
+	})
+}
+
+// TrashModelBranch moves the model branch to the trash with all its versions.
+func (ds *Datastore) TrashModelBranch(orgId uuid.UUID, modelBranchUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	return ds.trashBranch(modelBranchTables, orgId, modelBranchUUID, name, userUUID, purgeAt)
+}
+
+func (ds *Datastore) TrashModelVersion(orgId uuid.UUID, modelVersionUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	item := dbmodels.TrashItem{OrganizationUUID: orgId, Kind: models.TrashModelVersion, ObjectUUID: modelVersionUUID, Name: name, DeletedBy: userUUID, PurgeAt: purgeAt}
+	return ds.trashItem(item, func(tx *gorm.DB) error {
+		return tx.Where("uuid = ?", modelVersionUUID).Delete(&modeldbmodels.ModelVersion{}).Error
+	})
+}
+
+// TrashDataset moves the dataset to the trash with all its branches and
+// versions.
+func (ds *Datastore) TrashDataset(orgId uuid.UUID, datasetUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	item := dbmodels.TrashItem{OrganizationUUID: orgId, Kind: models.TrashDataset, ObjectUUID: datasetUUID, Name: name, DeletedBy: userUUID, PurgeAt: purgeAt}
+	return ds.trashItem(item, func(tx *gorm.DB) error {
+		branches := tx.Model(&datasetdbmodels.DatasetBranch{}).Select("uuid").Where("dataset_uuid = ?", datasetUUID)
+		if err := tx.Where("branch_uuid IN (?)", branches).Delete(&datasetdbmodels.DatasetVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("dataset_uuid = ?", datasetUUID).Delete(&datasetdbmodels.DatasetBranch{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("uuid = ?", datasetUUID).Delete(&datasetdbmodels.Dataset{}).Error
+	})
+}
+
+// TrashDatasetBranch moves the dataset branch to the trash with all its
+// versions.
+func (ds *Datastore) TrashDatasetBranch(orgId uuid.UUID, datasetBranchUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	return ds.trashBranch(datasetBranchTables, orgId, datasetBranchUUID, name, userUUID, purgeAt)
+}
+
+func (ds *Datastore) TrashDatasetVersion(orgId uuid.UUID, datasetVersionUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	item := dbmodels.TrashItem{OrganizationUUID: orgId, Kind: models.TrashDatasetVersion, ObjectUUID: datasetVersionUUID, Name: name, DeletedBy: userUUID, PurgeAt: purgeAt}
+	return ds.trashItem(item, func(tx *gorm.DB) error {
+		return tx.Where("uuid = ?", datasetVersionUUID).Delete(&datasetdbmodels.DatasetVersion{}).Error
+	})
+}
+
+// GetOrgTrash returns the items of the trash of the org, latest deleted first.
+func (ds *Datastore) GetOrgTrash(orgId uuid.UUID) ([]models.TrashItemResponse, error) {
+	return ds.getTrashItems(ds.DB.Where("organization_uuid = ?", orgId))
+}
+
+// GetExpiredTrashItems returns the items of every org to purge before the time.
+func (ds *Datastore) GetExpiredTrashItems(before time.Time) ([]models.TrashItemResponse, error) {
+	return ds.getTrashItems(ds.DB.Where("purge_at < ?", before))
+}
+
+func (ds *Datastore) getTrashItems(query *gorm.DB) ([]models.TrashItemResponse, error) {
+	var items []dbmodels.TrashItem
+	err := query.Preload("DeletedByUser").Order("created_at DESC").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	responses := []models.TrashItemResponse{}
+	for _, item := range items {
+		responses = append(responses, trashItemResponse(item))
+	}
+	return responses, nil
+}
+
+func (ds *Datastore) GetTrashItem(orgId uuid.UUID, itemUUID uuid.UUID) (*models.TrashItemResponse, error) {
+	var item dbmodels.TrashItem
+	res := ds.DB.Where("uuid = ?", itemUUID).Where("organization_uuid = ?", orgId).Preload("DeletedByUser").Limit(1).Find(&item)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	response := trashItemResponse(item)
+	return &response, nil
+}
+
+func trashItemResponse(item dbmodels.TrashItem) models.TrashItemResponse {
+	return models.TrashItemResponse{
+		UUID:       item.UUID,
+		OrgUUID:    item.OrganizationUUID,
+		Kind:       item.Kind,
+		ObjectUUID: item.ObjectUUID,
+		Name:       item.Name,
+		DeletedBy: userorgmodels.UserHandleResponse{
+			UUID:   item.DeletedByUser.UUID,
+			Handle: item.DeletedByUser.Handle,
+			Name:   item.DeletedByUser.Name,
+			Avatar: item.DeletedByUser.Avatar,
+			Email:  item.DeletedByUser.Email,
+		},
+		DeletedAt: item.CreatedAt,
+		PurgeAt:   item.PurgeAt,
+	}
+}
+
+// IsModelNameTrashed reports whether a model of the org with the name is
+// in the trash, which keeps the name from being reused until it is purged.
+func (ds *Datastore) IsModelNameTrashed(orgId uuid.UUID, name string) (bool, error) {
+	var count int64
+	err := ds.DB.Unscoped().Model(&modeldbmodels.Model{}).Where("organization_uuid = ?", orgId).Where("name = ?", name).Where("deleted_at IS NOT NULL").Count(&count).Error
+	return count > 0, err
+}
+
+// IsDatasetNameTrashed reports whether a dataset of the org with the name
+// is in the trash, which keeps the name from being reused until it is purged.
+func (ds *Datastore) IsDatasetNameTrashed(orgId uuid.UUID, name string) (bool, error) {
+	var count int64
+	err := ds.DB.Unscoped().Model(&datasetdbmodels.Dataset{}).Where("organization_uuid = ?", orgId).Where("name = ?", name).Where("deleted_at IS NOT NULL").Count(&count).Error
+	return count > 0, err
+}
+
+// RestoreTrashItem restores the item with the rows trashed along with it.
+// The branches and versions trashed on their own stay in the trash.
+// Returns ErrTrashedParent if the model, dataset or branch of the item is
+// in the trash.
+func (ds *Datastore) RestoreTrashItem(itemUUID uuid.UUID) error {
+	return ds.DB.Transaction(func(tx *gorm.DB) error {
+		var item dbmodels.TrashItem
+		if err := tx.Where("uuid = ?", itemUUID).First(&item).Error; err != nil {
+			return err
+		}
+		// the item is removed first so that its own rows are not kept as
+		// trashed on their own
+		if err := tx.Unscoped().Delete(&item).Error; err != nil {
+			return err
+		}
+		var err error
+		switch item.Kind {
+		case models.TrashModel:
+			branches := tx.Unscoped().Model(&modeldbmodels.ModelBranch{}).Select("uuid").Where("model_uuid = ?", item.ObjectUUID).Where("uuid NOT IN (?)", trashedObjects(tx, models.TrashModelBranch))
+			err = restoreRows(tx, &modeldbmodels.ModelVersion{}, models.TrashModelVersion, "branch_uuid IN (?)", branches)
+			if err == nil {
+				err = restoreRows(tx, &modeldbmodels.ModelBranch{}, models.TrashModelBranch, "model_uuid = ?", item.ObjectUUID)
+			}
+			if err == nil {
+				err = restoreRows(tx, &modeldbmodels.Model{}, models.TrashModel, "uuid = ?", item.ObjectUUID)
+			}
+		case models.TrashModelBranch:
+			var branch modeldbmodels.ModelBranch
+			if err := tx.Unscoped().Where("uuid = ?", item.ObjectUUID).First(&branch).Error; err != nil {
+				return err
+			}
+			if err := requireRow(tx, &modeldbmodels.Model{}, branch.ModelUUID); err != nil {
+				return err
+			}
+			err = restoreRows(tx, &modeldbmodels.ModelVersion{}, models.TrashModelVersion, "branch_uuid = ?", item.ObjectUUID)
+			if err == nil {
+				err = restoreRows(tx, &modeldbmodels.ModelBranch{}, models.TrashModelBranch, "uuid = ?", item.ObjectUUID)
+			}
+		case models.TrashModelVersion:
+			var version modeldbmodels.ModelVersion
+			if err := tx.Unscoped().Where("uuid = ?", item.ObjectUUID).First(&version).Error; err != nil {
+				return err
+			}
+			if err := requireRow(tx, &modeldbmodels.ModelBranch{}, version.BranchUUID); err != nil {
+				return err
+			}
+			err = restoreRows(tx, &modeldbmodels.ModelVersion{}, models.TrashModelVersion, "uuid = ?", item.ObjectUUID)
+		case models.TrashDataset:
+			branches := tx.Unscoped().Model(&datasetdbmodels.DatasetBranch{}).Select("uuid").Where("dataset_uuid = ?", item.ObjectUUID).Where("uuid NOT IN (?)", trashedObjects(tx, models.TrashDatasetBranch))
+			err = restoreRows(tx, &datasetdbmodels.DatasetVersion{}, models.TrashDatasetVersion, "branch_uuid IN (?)", branches)
+			if err == nil {
+				err = restoreRows(tx, &datasetdbmodels.DatasetBranch{}, models.TrashDatasetBranch, "dataset_uuid = ?", item.ObjectUUID)
+			}
+			if err == nil {
+				err = restoreRows(tx, &datasetdbmodels.Dataset{}, models.TrashDataset, "uuid = ?", item.ObjectUUID)
+			}
+		case models.TrashDatasetBranch:
+			var branch datasetdbmodels.DatasetBranch
+			if err := tx.Unscoped().Where("uuid = ?", item.ObjectUUID).First(&branch).Error; err != nil {
+				return err
+			}
+			if err := requireRow(tx, &datasetdbmodels.Dataset{}, branch.DatasetUUID); err != nil {
+				return err
+			}
+			err = restoreRows(tx, &datasetdbmodels.DatasetVersion{}, models.TrashDatasetVersion, "branch_uuid = ?", item.ObjectUUID)
+			if err == nil {
+				err = restoreRows(tx, &datasetdbmodels.DatasetBranch{}, models.TrashDatasetBranch, "uuid = ?", item.ObjectUUID)
+			}
+		case models.TrashDatasetVersion:
+			var version datasetdbmodels.DatasetVersion
+			if err := tx.Unscoped().Where("uuid = ?", item.ObjectUUID).First(&version).Error; err != nil {
+				return err
+			}
+			if err := requireRow(tx, &datasetdbmodels.DatasetBranch{}, version.BranchUUID); err != nil {
+				return err
+			}
+			err = restoreRows(tx, &datasetdbmodels.DatasetVersion{}, models.TrashDatasetVersion, "uuid = ?", item.ObjectUUID)
+		default:
+			err = fmt.Errorf("unknown trash item kind %s", item.Kind)
+		}
+		return err
+	})
+}
+
+// trashedObjects selects the objects of the kind trashed on their own.
+func trashedObjects(tx *gorm.DB, kind string) *gorm.DB {
+	return tx.Model(&dbmodels.TrashItem{}).Select("object_uuid").Where("kind = ?", kind)
+}
+
+// restoreRows undeletes the rows of the table matching the query, except
+// the ones trashed on their own as kind.
+func restoreRows(tx *gorm.DB, table interface{}, kind string, query string, args ...interface{}) error {
+	rows := tx.Unscoped().Model(table).Where(query, args...).Where("deleted_at IS NOT NULL")
+	if kind != "" {
+		rows = rows.Where("uuid NOT IN (?)", trashedObjects(tx, kind))
+	}
+	return rows.Update("deleted_at", nil).Error
+}
+
+// requireRow returns ErrTrashedParent if the row of the table is deleted.
+func requireRow(tx *gorm.DB, table interface{}, rowUUID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(table).Where("uuid = ?", rowUUID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTrashedParent
+	}
+	return nil
+}
+
+// PurgeTrashItem permanently deletes the item with everything trashed along
+// with it, and releases their blobs. Returns the objects to remove from the
+// storages: the blobs that are no longer referenced and the file logs.
+// Legacy version files not stored as blobs are left to the storage garbage
+// collector.
+func (ds *Datastore) PurgeTrashItem(itemUUID uuid.UUID) ([]models.StoredObjectResponse, error) {
+	objects := []models.StoredObjectResponse{}
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		var item dbmodels.TrashItem
+		if err := tx.Where("uuid = ?", itemUUID).First(&item).Error; err != nil {
+			return err
+		}
+		var blobs []*models.BlobResponse
+		var fileLogs []models.StoredObjectResponse
+		var err error
+		switch item.Kind {
+		case models.TrashModel:
+			blobs, fileLogs, err = purgeModel(tx, item.ObjectUUID)
+		case models.TrashModelBranch:
+			blobs, fileLogs, err = purgeModelBranch(tx, item.ObjectUUID)
+		case models.TrashModelVersion:
+			blobs, fileLogs, err = purgeModelVersion(tx, item.ObjectUUID)
+		case models.TrashDataset:
+			blobs, fileLogs, err = purgeDataset(tx, item.ObjectUUID)
+		case models.TrashDatasetBranch:
+			blobs, fileLogs, err = purgeDatasetBranch(tx, item.ObjectUUID)
+		case models.TrashDatasetVersion:
+			blobs, fileLogs, err = purgeDatasetVersion(tx, item.ObjectUUID)
+		default:
+			err = fmt.Errorf("unknown trash item kind %s", item.Kind)
+		}
+		if err != nil {
+			return err
+		}
+		for _, blob := range blobs {
+			objects = append(objects, models.StoredObjectResponse{SourceType: blob.SourceType, SourceURL: blob.SourceURL, Key: blob.Key})
+		}
+		objects = append(objects, fileLogs...)
+		return tx.Unscoped().Delete(&item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// purgeModel permanently deletes the model with its branches and everything
+// attached to it.
+func purgeModel(tx *gorm.DB, modelUUID uuid.UUID) ([]*models.BlobResponse, []models.StoredObjectResponse, error) {
+	var blobs []*models.BlobResponse
+	fileLogs := []models.StoredObjectResponse{}
+	var branches []modeldbmodels.ModelBranch
+	if err := tx.Unscoped().Where("model_uuid = ?", modelUUID).Find(&branches).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, branch := range branches {
+		branchBlobs, branchFileLogs, err := purgeModelBranch(tx, branch.UUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		blobs = append(blobs, branchBlobs...)
+		fileLogs = append(fileLogs, branchFileLogs...)
+	}
+	// the files of the model not attached to a version
+	ownFileLogs, err := storedObjects(tx, "model_uuid", modelUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileLogs = append(fileLogs, ownFileLogs...)
+	if err := deleteReviews(tx, &modeldbmodels.ModelReview{}, "model_review_uuid", "model_uuid = ?", modelUUID); err != nil {
+		return nil, nil, err
+	}
+	for _, table := range []interface{}{&dbmodels.VersionAlias{}, &dbmodels.VersionAliasEvent{}, &dbmodels.StreamEvent{}, &dbmodels.Activity{}, &dbmodels.StorageUsage{}, &modeldbmodels.ModelStageTransition{}, &modeldbmodels.ModelUser{}} {
+		if err := tx.Unscoped().Where("model_uuid = ?", modelUUID).Delete(table).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := purgeReadme(tx, "model_uuid", modelUUID); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Unscoped().Where("uuid = ?", modelUUID).Delete(&modeldbmodels.Model{}).Error; err != nil {
+		return nil, nil, err
+	}
+	return blobs, fileLogs, nil
+}
+
+// purgeModelBranch permanently deletes the model branch with its versions,
+// its reviews and its imports.
+func purgeModelBranch(tx *gorm.DB, modelBranchUUID uuid.UUID) ([]*models.BlobResponse, []models.StoredObjectResponse, error) {
+	var blobs []*models.BlobResponse
+	fileLogs := []models.StoredObjectResponse{}
+	var versions []modeldbmodels.ModelVersion
+	if err := tx.Unscoped().Where("branch_uuid = ?", modelBranchUUID).Find(&versions).Error; err != nil {
+		return nil, nil, err
+	}
+	versionUUIDs := []uuid.UUID{modelBranchUUID}
+	for _, version := range versions {
+		versionBlobs, versionFileLogs, err := purgeModelVersion(tx, version.UUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		blobs = append(blobs, versionBlobs...)
+		fileLogs = append(fileLogs, versionFileLogs...)
+		versionUUIDs = append(versionUUIDs, version.UUID)
+	}
+	if err := deleteReviews(tx, &modeldbmodels.ModelReview{}, "model_review_uuid", "from_branch_uuid = ? OR to_branch_uuid = ?", modelBranchUUID, modelBranchUUID); err != nil {
+		return nil, nil, err
+	}
+	if err := deleteBranchProtection(tx, "model_branch_uuid", modelBranchUUID); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Unscoped().Where("model_branch_uuid = ?", modelBranchUUID).Delete(&dbmodels.ImportJob{}).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Unscoped().Where("uuid = ?", modelBranchUUID).Delete(&modeldbmodels.ModelBranch{}).Error; err != nil {
+		return nil, nil, err
+	}
+	// the branch and its versions may have been trashed on their own
+	if err := tx.Unscoped().Where("object_uuid IN ?", versionUUIDs).Delete(&dbmodels.TrashItem{}).Error; err != nil {
+		return nil, nil, err
+	}
+	return blobs, fileLogs, nil
+}
+
+// purgeDataset permanently deletes the dataset with its branches and
+// everything attached to it.
+func purgeDataset(tx *gorm.DB, datasetUUID uuid.UUID) ([]*models.BlobResponse, []models.StoredObjectResponse, error) {
+	var blobs []*models.BlobResponse
+	fileLogs := []models.StoredObjectResponse{}
+	var branches []datasetdbmodels.DatasetBranch
+	if err := tx.Unscoped().Where("dataset_uuid = ?", datasetUUID).Find(&branches).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, branch := range branches {
+		branchBlobs, branchFileLogs, err := purgeDatasetBranch(tx, branch.UUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		blobs = append(blobs, branchBlobs...)
+		fileLogs = append(fileLogs, branchFileLogs...)
+	}
+	// the files of the dataset not attached to a version
+	ownFileLogs, err := storedObjects(tx, "dataset_uuid", datasetUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileLogs = append(fileLogs, ownFileLogs...)
+	if err := deleteReviews(tx, &datasetdbmodels.DatasetReview{}, "dataset_review_uuid", "dataset_uuid = ?", datasetUUID); err != nil {
+		return nil, nil, err
+	}
+	for _, table := range []interface{}{&dbmodels.VersionAlias{}, &dbmodels.VersionAliasEvent{}, &dbmodels.StreamEvent{}, &dbmodels.Activity{}, &dbmodels.StorageUsage{}, &datasetdbmodels.DatasetUser{}} {
+		if err := tx.Unscoped().Where("dataset_uuid = ?", datasetUUID).Delete(table).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := purgeReadme(tx, "dataset_uuid", datasetUUID); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Unscoped().Where("uuid = ?", datasetUUID).Delete(&datasetdbmodels.Dataset{}).Error; err != nil {
+		return nil, nil, err
+	}
+	return blobs, fileLogs, nil
+}
+
+// purgeDatasetBranch permanently deletes the dataset branch with its
+// versions, its reviews and its imports.
+func purgeDatasetBranch(tx *gorm.DB, datasetBranchUUID uuid.UUID) ([]*models.BlobResponse, []models.StoredObjectResponse, error) {
+	var blobs []*models.BlobResponse
+	fileLogs := []models.StoredObjectResponse{}
+	var versions []datasetdbmodels.DatasetVersion
+	if err := tx.Unscoped().Where("branch_uuid = ?", datasetBranchUUID).Find(&versions).Error; err != nil {
+		return nil, nil, err
+	}
+	versionUUIDs := []uuid.UUID{datasetBranchUUID}
+	for _, version := range versions {
+		versionBlobs, versionFileLogs, err := purgeDatasetVersion(tx, version.UUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		blobs = append(blobs, versionBlobs...)
+		fileLogs = append(fileLogs, versionFileLogs...)
+		versionUUIDs = append(versionUUIDs, version.UUID)
+	}
+	if err := deleteReviews(tx, &datasetdbmodels.DatasetReview{}, "dataset_review_uuid", "from_branch_uuid = ? OR to_branch_uuid = ?", datasetBranchUUID, datasetBranchUUID); err != nil {
+		return nil, nil, err
+	}
+	if err := deleteBranchProtection(tx, "dataset_branch_uuid", datasetBranchUUID); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Unscoped().Where("dataset_branch_uuid = ?", datasetBranchUUID).Delete(&dbmodels.ImportJob{}).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Unscoped().Where("uuid = ?", datasetBranchUUID).Delete(&datasetdbmodels.DatasetBranch{}).Error; err != nil {
+		return nil, nil, err
+	}
+	// the branch and its versions may have been trashed on their own
+	if err := tx.Unscoped().Where("object_uuid IN ?", versionUUIDs).Delete(&dbmodels.TrashItem{}).Error; err != nil {
+		return nil, nil, err
+	}
+	return blobs, fileLogs, nil
+}
+
+func purgeReadme(tx *gorm.DB, ownerColumn string, ownerUUID uuid.UUID) error {
+	readmes := tx.Unscoped().Model(&commondbmodels.Readme{}).Select("uuid").Where(ownerColumn+" = ?", ownerUUID)
+	if err := tx.Unscoped().Where("readme_uuid IN (?)", readmes).Delete(&commondbmodels.ReadmeVersion{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where(ownerColumn+" = ?", ownerUUID).Delete(&commondbmodels.Readme{}).Error
+}
+
+// GetModelReferences returns the aliases and the open reviews of the model
+// referencing the branch (if valid) or the version (if valid), or anything
+// of the model otherwise.
+func (ds *Datastore) GetModelReferences(modelUUID uuid.UUID, branchUUID uuid.NullUUID, versionUUID uuid.NullUUID) (*models.ReferencesResponse, error) {
+	aliases := ds.DB.Model(&dbmodels.VersionAlias{}).Joins("JOIN model_versions ON model_versions.uuid = version_aliases.model_version_uuid").Where("version_aliases.model_uuid = ?", modelUUID)
+	reviews := ds.DB.Model(&modeldbmodels.ModelReview{}).Where("model_uuid = ?", modelUUID).Where("is_complete = ?", false)
+	if branchUUID.Valid {
+		aliases = aliases.Where("model_versions.branch_uuid = ?", branchUUID.UUID)
+		reviews = reviews.Where("from_branch_uuid = ? OR to_branch_uuid = ?", branchUUID.UUID, branchUUID.UUID)
+	}
+	if versionUUID.Valid {
+		aliases = aliases.Where("model_versions.uuid = ?", versionUUID.UUID)
+		reviews = reviews.Where("from_branch_version_uuid = ?", versionUUID.UUID)
+	}
+	return references(aliases, reviews)
+}
+
+// GetDatasetReferences returns the aliases and the open reviews of the
+// dataset referencing the branch (if valid) or the version (if valid), or
+// anything of the dataset otherwise.
+func (ds *Datastore) GetDatasetReferences(datasetUUID uuid.UUID, branchUUID uuid.NullUUID, versionUUID uuid.NullUUID) (*models.ReferencesResponse, error) {
+	aliases := ds.DB.Model(&dbmodels.VersionAlias{}).Joins("JOIN dataset_versions ON dataset_versions.uuid = version_aliases.dataset_version_uuid").Where("version_aliases.dataset_uuid = ?", datasetUUID)
+	reviews := ds.DB.Model(&datasetdbmodels.DatasetReview{}).Where("dataset_uuid = ?", datasetUUID).Where("is_complete = ?", false)
+	if branchUUID.Valid {
+		aliases = aliases.Where("dataset_versions.branch_uuid = ?", branchUUID.UUID)
+		reviews = reviews.Where("from_branch_uuid = ? OR to_branch_uuid = ?", branchUUID.UUID, branchUUID.UUID)
+	}
+	if versionUUID.Valid {
+		aliases = aliases.Where("dataset_versions.uuid = ?", versionUUID.UUID)
+		reviews = reviews.Where("from_branch_version_uuid = ?", versionUUID.UUID)
+	}
+	return references(aliases, reviews)
+}
+
+func references(aliases *gorm.DB, reviews *gorm.DB) (*models.ReferencesResponse, error) {
+	response := &models.ReferencesResponse{Aliases: []string{}}
+	if err := aliases.Order("version_aliases.name").Pluck("version_aliases.name", &response.Aliases).Error; err != nil {
+		return nil, err
+	}
+	if err := reviews.Count(&response.OpenReviews).Error; err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+/////////////////////////////// BRANCH METHODS ///////////////////////////////
+
+// branchTables are the tables of the branches of models or datasets, so
+// that the branch methods are shared by both.
+type branchTables struct {
+	branch      interface{}
+	version     interface{}
+	ownerColumn string
+	trashKind   string
+}
+
+var (
+	modelBranchTables   = branchTables{branch: &modeldbmodels.ModelBranch{}, version: &modeldbmodels.ModelVersion{}, ownerColumn: "model_uuid", trashKind: models.TrashModelBranch}
+	datasetBranchTables = branchTables{branch: &datasetdbmodels.DatasetBranch{}, version: &datasetdbmodels.DatasetVersion{}, ownerColumn: "dataset_uuid", trashKind: models.TrashDatasetBranch}
+)
+
+// updateBranch renames the branch of the model or dataset if name is set,
+// and makes it the default branch in place of the current one if isDefault
+// is set.
+func (ds *Datastore) updateBranch(tables branchTables, ownerUUID uuid.UUID, branchUUID uuid.UUID, name string, isDefault bool) error {
+	return ds.DB.Transaction(func(tx *gorm.DB) error {
+		if name != "" {
+			if err := tx.Model(tables.branch).Where("uuid = ?", branchUUID).Update("name", name).Error; err != nil {
+				return err
+			}
+		}
+		if isDefault {
+			if err := tx.Model(tables.branch).Where(tables.ownerColumn+" = ?", ownerUUID).Where("uuid <> ?", branchUUID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(tables.branch).Where("uuid = ?", branchUUID).Update("is_default", true).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// trashBranch moves the branch of the model or dataset to the trash with all
+// its versions.
+func (ds *Datastore) trashBranch(tables branchTables, orgId uuid.UUID, branchUUID uuid.UUID, name string, userUUID uuid.UUID, purgeAt time.Time) (*models.TrashItemResponse, error) {
+	item := dbmodels.TrashItem{OrganizationUUID: orgId, Kind: tables.trashKind, ObjectUUID: branchUUID, Name: name, DeletedBy: userUUID, PurgeAt: purgeAt}
+	return ds.trashItem(item, func(tx *gorm.DB) error {
+		if err := tx.Where("branch_uuid = ?", branchUUID).Delete(tables.version).Error; err != nil {
+			return err
+		}
+		return tx.Where("uuid = ?", branchUUID).Delete(tables.branch).Error
+	})
+}
+
+// isBranchNameTaken reports whether the model or dataset has a branch with
+// the name, including the branches in the trash.
+func (ds *Datastore) isBranchNameTaken(tables branchTables, ownerUUID uuid.UUID, name string) (bool, error) {
+	var count int64
+	err := ds.DB.Unscoped().Model(tables.branch).Where(tables.ownerColumn+" = ?", ownerUUID).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+func (ds *Datastore) IsModelBranchNameTaken(modelUUID uuid.UUID, name string) (bool, error) {
+	return ds.isBranchNameTaken(modelBranchTables, modelUUID, name)
+}
+
+func (ds *Datastore) IsDatasetBranchNameTaken(datasetUUID uuid.UUID, name string) (bool, error) {
+	return ds.isBranchNameTaken(datasetBranchTables, datasetUUID, name)
+}
+
+// UpdateModelBranch renames the model branch if name is set, and makes it
+// the default branch of the model if isDefault is set.
+func (ds *Datastore) UpdateModelBranch(modelUUID uuid.UUID, modelBranchUUID uuid.UUID, name string, isDefault bool) (*modelmodels.ModelBranchResponse, error) {
+	if err := ds.updateBranch(modelBranchTables, modelUUID, modelBranchUUID, name, isDefault); err != nil {
+		return nil, err
+	}
+	return ds.GetModelBranchByUUID(modelBranchUUID)
+}
+
+// UpdateDatasetBranch renames the dataset branch if name is set, and makes
+// it the default branch of the dataset if isDefault is set.
+func (ds *Datastore) UpdateDatasetBranch(datasetUUID uuid.UUID, datasetBranchUUID uuid.UUID, name string, isDefault bool) (*datasetmodels.DatasetBranchResponse, error) {
+	if err := ds.updateBranch(datasetBranchTables, datasetUUID, datasetBranchUUID, name, isDefault); err != nil {
+		return nil, err
+	}
+	return ds.GetDatasetBranchByUUID(datasetBranchUUID)
+}
+
+/////////////////////////////// BRANCH PROTECTION METHODS ///////////////////////////////
+
+// protectMainBranches protects the main branches of the existing models and
+// datasets against direct registration, which was enforced for every main
+// branch before the protection rules.
+func protectMainBranches(db *gorm.DB) error {
+	for _, branches := range []struct {
+		table       string
+		ownerTable  string
+		ownerColumn string
+		column      string
+	}{
+		{"model_branches", "models", "model_uuid", "model_branch_uuid"},
+		{"dataset_branches", "datasets", "dataset_uuid", "dataset_branch_uuid"},
+	} {
+		var rows []struct {
+			UUID             uuid.UUID
+			OrganizationUUID uuid.UUID
+			CreatedBy        uuid.UUID
+		}
+		err := db.Table(branches.table).
+			Select(branches.table+".uuid, "+branches.ownerTable+".organization_uuid, "+branches.ownerTable+".created_by").
+			Joins("JOIN "+branches.ownerTable+" ON "+branches.ownerTable+".uuid = "+branches.table+"."+branches.ownerColumn).
+			Where(branches.table+".name = ?", "main").
+			Where(branches.table + ".deleted_at IS NULL").
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			protection := dbmodels.BranchProtection{
+				OrganizationUUID:        row.OrganizationUUID,
+				BlockDirectRegistration: true,
+				UpdatedBy:               row.CreatedBy,
+			}
+			if branches.column == "model_branch_uuid" {
+				protection.ModelBranchUUID = uuid.NullUUID{UUID: row.UUID, Valid: true}
+			} else {
+				protection.DatasetBranchUUID = uuid.NullUUID{UUID: row.UUID, Valid: true}
+			}
+			if err := db.Create(&protection).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ds *Datastore) getBranchProtection(column string, branchUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	var protection dbmodels.BranchProtection
+	res := ds.DB.Where(column+" = ?", branchUUID).Preload("Reviewers.User").Preload("UpdatedByUser").Limit(1).Find(&protection)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	reviewers := []userorgmodels.UserHandleResponse{}
+	for _, reviewer := range protection.Reviewers {
+		reviewers = append(reviewers, userorgmodels.UserHandleResponse{
+			UUID:   reviewer.User.UUID,
+			Handle: reviewer.User.Handle,
+			Name:   reviewer.User.Name,
+			Avatar: reviewer.User.Avatar,
+			Email:  reviewer.User.Email,
+		})
+	}
+	return &models.BranchProtectionResponse{
+		UUID:                    protection.UUID,
+		BlockDirectRegistration: protection.BlockDirectRegistration,
+		RequiredApprovals:       protection.RequiredApprovals,
+		BlockDeletion:           protection.BlockDeletion,
+		Reviewers:               reviewers,
+		UpdatedBy: userorgmodels.UserHandleResponse{
+			UUID:   protection.UpdatedByUser.UUID,
+			Handle: protection.UpdatedByUser.Handle,
+			Name:   protection.UpdatedByUser.Name,
+			Avatar: protection.UpdatedByUser.Avatar,
+			Email:  protection.UpdatedByUser.Email,
+		},
+		UpdatedAt: protection.UpdatedAt,
+	}, nil
+}
+
+func (ds *Datastore) setBranchProtection(orgId uuid.UUID, column string, branchUUID uuid.UUID, rules models.BranchProtectionRules, userUUID uuid.UUID) error {
+	return ds.DB.Transaction(func(tx *gorm.DB) error {
+		var protection dbmodels.BranchProtection
+		if err := tx.Where(column+" = ?", branchUUID).Limit(1).Find(&protection).Error; err != nil {
+			return err
+		}
+		protection.OrganizationUUID = orgId
+		if column == "model_branch_uuid" {
+			protection.ModelBranchUUID = uuid.NullUUID{UUID: branchUUID, Valid: true}
+		} else {
+			protection.DatasetBranchUUID = uuid.NullUUID{UUID: branchUUID, Valid: true}
+		}
+		protection.BlockDirectRegistration = rules.BlockDirectRegistration
+		protection.RequiredApprovals = rules.RequiredApprovals
+		protection.BlockDeletion = rules.BlockDeletion
+		protection.UpdatedBy = userUUID
+		if err := tx.Save(&protection).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("branch_protection_uuid = ?", protection.UUID).Delete(&dbmodels.BranchProtectionReviewer{}).Error; err != nil {
+			return err
+		}
+		for _, reviewerUUID := range rules.Reviewers {
+			reviewer := dbmodels.BranchProtectionReviewer{BranchProtectionUUID: protection.UUID, UserUUID: reviewerUUID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reviewer).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func deleteBranchProtection(tx *gorm.DB, column string, branchUUID uuid.UUID) error {
+	protections := tx.Unscoped().Model(&dbmodels.BranchProtection{}).Select("uuid").Where(column+" = ?", branchUUID)
+	if err := tx.Where("branch_protection_uuid IN (?)", protections).Delete(&dbmodels.BranchProtectionReviewer{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where(column+" = ?", branchUUID).Delete(&dbmodels.BranchProtection{}).Error
+}
+
+// GetModelBranchProtection returns the protection rules of the model
+// branch, or nil if it is not protected.
+func (ds *Datastore) GetModelBranchProtection(modelBranchUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	return ds.getBranchProtection("model_branch_uuid", modelBranchUUID)
+}
+
+// SetModelBranchProtection sets the protection rules of the model branch.
+func (ds *Datastore) SetModelBranchProtection(orgId uuid.UUID, modelBranchUUID uuid.UUID, rules models.BranchProtectionRules, userUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	if err := ds.setBranchProtection(orgId, "model_branch_uuid", modelBranchUUID, rules, userUUID); err != nil {
+		return nil, err
+	}
+	return ds.GetModelBranchProtection(modelBranchUUID)
+}
+
+func (ds *Datastore) DeleteModelBranchProtection(modelBranchUUID uuid.UUID) error {
+	return deleteBranchProtection(ds.DB, "model_branch_uuid", modelBranchUUID)
+}
+
+// GetDatasetBranchProtection returns the protection rules of the dataset
+// branch, or nil if it is not protected.
+func (ds *Datastore) GetDatasetBranchProtection(datasetBranchUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	return ds.getBranchProtection("dataset_branch_uuid", datasetBranchUUID)
+}
+
+// SetDatasetBranchProtection sets the protection rules of the dataset branch.
+func (ds *Datastore) SetDatasetBranchProtection(orgId uuid.UUID, datasetBranchUUID uuid.UUID, rules models.BranchProtectionRules, userUUID uuid.UUID) (*models.BranchProtectionResponse, error) {
+	if err := ds.setBranchProtection(orgId, "dataset_branch_uuid", datasetBranchUUID, rules, userUUID); err != nil {
+		return nil, err
+	}
+	return ds.GetDatasetBranchProtection(datasetBranchUUID)
+}
+
+func (ds *Datastore) DeleteDatasetBranchProtection(datasetBranchUUID uuid.UUID) error {
+	return deleteBranchProtection(ds.DB, "dataset_branch_uuid", datasetBranchUUID)
+}
+
+func (ds *Datastore) approveReview(column string, reviewUUID uuid.UUID, userUUID uuid.UUID) (*models.ReviewApprovalResponse, error) {
+	approval := dbmodels.ReviewApproval{UserUUID: userUUID}
+	if column == "model_review_uuid" {
+		approval.ModelReviewUUID = uuid.NullUUID{UUID: reviewUUID, Valid: true}
+	} else {
+		approval.DatasetReviewUUID = uuid.NullUUID{UUID: reviewUUID, Valid: true}
+	}
+	// approving twice keeps the first approval
+	err := ds.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&approval).Error
+	if err != nil {
+		return nil, err
+	}
+	err = ds.DB.Where(column+" = ?", reviewUUID).Where("user_uuid = ?", userUUID).Preload("User").First(&approval).Error
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReviewApprovalResponse{
+		UUID:       approval.UUID,
+		ReviewUUID: reviewUUID,
+		ApprovedBy: userorgmodels.UserHandleResponse{
+			UUID:   approval.User.UUID,
+			Handle: approval.User.Handle,
+			Name:   approval.User.Name,
+			Avatar: approval.User.Avatar,
+			Email:  approval.User.Email,
+		},
+		ApprovedAt: approval.CreatedAt,
+	}, nil
+}
+
+func (ds *Datastore) countReviewApprovals(column string, reviewUUID uuid.UUID) (int64, error) {
+	var count int64
+	err := ds.DB.Model(&dbmodels.ReviewApproval{}).Where(column+" = ?", reviewUUID).Count(&count).Error
+	return count, err
+}
+
+// deleteReviews permanently deletes the reviews of the table matching the
+// query with their approvals.
+func deleteReviews(tx *gorm.DB, table interface{}, column string, query string, args ...interface{}) error {
+	reviews := tx.Unscoped().Model(table).Select("uuid").Where(query, args...)
+	if err := tx.Unscoped().Where(column+" IN (?)", reviews).Delete(&dbmodels.ReviewApproval{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where(query, args...).Delete(table).Error
+}
+
+func (ds *Datastore) ApproveModelReview(reviewUUID uuid.UUID, userUUID uuid.UUID) (*models.ReviewApprovalResponse, error) {
+	return ds.approveReview("model_review_uuid", reviewUUID, userUUID)
+}
+
+func (ds *Datastore) CountModelReviewApprovals(reviewUUID uuid.UUID) (int64, error) {
+	return ds.countReviewApprovals("model_review_uuid", reviewUUID)
+}
+
+func (ds *Datastore) ApproveDatasetReview(reviewUUID uuid.UUID, userUUID uuid.UUID) (*models.ReviewApprovalResponse, error) {
+	return ds.approveReview("dataset_review_uuid", reviewUUID, userUUID)
+}
+
+func (ds *Datastore) CountDatasetReviewApprovals(reviewUUID uuid.UUID) (int64, error) {
+	return ds.countReviewApprovals("dataset_review_uuid", reviewUUID)
+}
+
+/////////////////////////////// COMPARE METHODS ///////////////////////////////
+
+// compareTables are the tables compared by the compare methods, so that they
+// are shared by models and datasets.
+type compareTables struct {
+	branchTables
+	versionColumn string
+	versionFiles  func(ds *Datastore, versionUUID uuid.UUID) ([]models.VersionFileResponse, error)
+}
+
+var (
+	modelCompareTables   = compareTables{branchTables: modelBranchTables, versionColumn: "model_version_uuid", versionFiles: (*Datastore).GetModelVersionFiles}
+	datasetCompareTables = compareTables{branchTables: datasetBranchTables, versionColumn: "dataset_version_uuid", versionFiles: (*Datastore).GetDatasetVersionFiles}
+)
+
+type compareBranch struct {
+	UUID            uuid.UUID
+	Name            string
+	OwnerUUID       uuid.UUID
+	BaseBranchUUID  uuid.NullUUID
+	BaseVersionUUID uuid.NullUUID
+}
+
+// compareBranchVersions returns the versions of the branch in order, up to
+// the compared version if it is set, and the compared version, which is the
+// latest version otherwise.
+func (ds *Datastore) compareBranchVersions(tables compareTables, branchUUID uuid.UUID, versionUUID uuid.NullUUID) ([]models.CompareVersionResponse, *models.CompareVersionResponse, error) {
+	var versions []models.CompareVersionResponse
+	err := ds.DB.Model(tables.version).Select("uuid, version, hash, created_at").Where("branch_uuid = ?", branchUUID).Order("LENGTH(version)").Order("version").Scan(&versions).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(versions) == 0 {
+		return versions, nil, nil
+	}
+	if !versionUUID.Valid {
+		return versions, &versions[len(versions)-1], nil
+	}
+	for i := range versions {
+		if versions[i].UUID == versionUUID.UUID {
+			return versions[:i+1], &versions[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("version %s is not on branch %s", versionUUID.UUID, branchUUID)
+}
+
+// compareLogs returns the latest value of each key logged for the version.
+func (ds *Datastore) compareLogs(tables compareTables, version *models.CompareVersionResponse) (map[string]string, error) {
+	values := map[string]string{}
+	if version == nil {
+		return values, nil
+	}
+	var logs []dbmodels.Log
+	err := ds.DB.Where(tables.versionColumn+" = ?", version.UUID).Order("created_at").Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		values[log.Key] = log.Data
+	}
+	return values, nil
+}
+
+// compareReadme returns the readme of the model or dataset as it was when
+// the version was registered. The times are compared here rather than in the
+// query as they are stored as text by some databases.
+func (ds *Datastore) compareReadme(tables compareTables, ownerUUID uuid.UUID, version *models.CompareVersionResponse) (*commondbmodels.ReadmeVersion, error) {
+	current := &commondbmodels.ReadmeVersion{}
+	if version == nil {
+		return current, nil
+	}
+	var readmeVersions []commondbmodels.ReadmeVersion
+	err := ds.DB.Joins("JOIN readmes ON readmes.uuid = readme_versions.readme_uuid").Where("readmes."+tables.ownerColumn+" = ?", ownerUUID).Find(&readmeVersions).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range readmeVersions {
+		if !readmeVersions[i].CreatedAt.After(version.CreatedAt) && !readmeVersions[i].CreatedAt.Before(current.CreatedAt) {
+			current = &readmeVersions[i]
+		}
+	}
+	return current, nil
+}
+
+// compareFiles diffs the artifact manifests of the versions by path.
+func (ds *Datastore) compareFiles(tables compareTables, baseVersion *models.CompareVersionResponse, headVersion *models.CompareVersionResponse) ([]models.FileDiffResponse, error) {
+	var baseFiles, headFiles []models.VersionFileResponse
+	var err error
+	if baseVersion != nil {
+		if baseFiles, err = tables.versionFiles(ds, baseVersion.UUID); err != nil {
+			return nil, err
+		}
+	}
+	if headVersion != nil {
+		if headFiles, err = tables.versionFiles(ds, headVersion.UUID); err != nil {
+			return nil, err
+		}
+	}
+	diffs := []models.FileDiffResponse{}
+	index := map[string]int{}
+	for _, file := range baseFiles {
+		index[file.Path] = len(diffs)
+		diffs = append(diffs, models.FileDiffResponse{Path: file.Path, Status: models.FileRemoved, BaseDigest: file.Digest, BaseSize: file.Size})
+	}
+	for _, file := range headFiles {
+		i, ok := index[file.Path]
+		if !ok {
+			diffs = append(diffs, models.FileDiffResponse{Path: file.Path, Status: models.FileAdded, HeadDigest: file.Digest, HeadSize: file.Size})
+			continue
+		}
+		diffs[i].HeadDigest = file.Digest
+		diffs[i].HeadSize = file.Size
+		if diffs[i].BaseDigest == file.Digest && diffs[i].BaseSize == file.Size {
+			diffs[i].Status = models.FileUnchanged
+		} else {
+			diffs[i].Status = models.FileModified
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+// compareBranches compares the head branch with the base branch, up to the
+// given versions of each or their latest versions.
+func (ds *Datastore) compareBranches(tables compareTables, baseBranchUUID uuid.UUID, baseVersionUUID uuid.NullUUID, headBranchUUID uuid.UUID, headVersionUUID uuid.NullUUID) (*models.CompareResponse, error) {
+	var branches []compareBranch
+	err := ds.DB.Model(tables.branch).Select("uuid, name, "+tables.ownerColumn+" AS owner_uuid, base_branch_uuid, base_version_uuid").Where("uuid IN ?", []uuid.UUID{baseBranchUUID, headBranchUUID}).Scan(&branches).Error
+	if err != nil {
+		return nil, err
+	}
+	var base, head compareBranch
+	for _, branch := range branches {
+		if branch.UUID == baseBranchUUID {
+			base = branch
+		}
+		if branch.UUID == headBranchUUID {
+			head = branch
+		}
+	}
+	baseVersions, baseVersion, err := ds.compareBranchVersions(tables, baseBranchUUID, baseVersionUUID)
+	if err != nil {
+		return nil, err
+	}
+	headVersions, headVersion, err := ds.compareBranchVersions(tables, headBranchUUID, headVersionUUID)
+	if err != nil {
+		return nil, err
+	}
+	compare := &models.CompareResponse{
+		Base:       models.CompareRefResponse{BranchUUID: base.UUID, Branch: base.Name, Version: baseVersion},
+		Head:       models.CompareRefResponse{BranchUUID: head.UUID, Branch: head.Name, Version: headVersion},
+		OnlyInBase: []models.CompareVersionResponse{},
+		OnlyInHead: []models.CompareVersionResponse{},
+		Logs:       []models.LogDiffResponse{},
+	}
+
+	baseHashes := map[string]bool{}
+	for _, version := range baseVersions {
+		baseHashes[version.Hash] = true
+	}
+	headHashes := map[string]bool{}
+	for _, version := range headVersions {
+		headHashes[version.Hash] = true
+		if !baseHashes[version.Hash] {
+			compare.OnlyInHead = append(compare.OnlyInHead, version)
+		}
+	}
+	forkPoint := -1
+	for i, version := range baseVersions {
+		if !headHashes[version.Hash] {
+			compare.OnlyInBase = append(compare.OnlyInBase, version)
+		}
+		if head.BaseBranchUUID.Valid && head.BaseBranchUUID.UUID == base.UUID && head.BaseVersionUUID.Valid && head.BaseVersionUUID.UUID == version.UUID {
+			forkPoint = i
+		}
+	}
+	if forkPoint < 0 {
+		for i := len(baseVersions) - 1; i >= 0; i-- {
+			if headHashes[baseVersions[i].Hash] {
+				forkPoint = i
+				break
+			}
+		}
+	}
+	if forkPoint >= 0 {
+		compare.ForkPoint = &baseVersions[forkPoint]
+		compare.BaseMoved = forkPoint < len(baseVersions)-1
+	}
+	compare.AheadBy = len(compare.OnlyInHead)
+	compare.BehindBy = len(compare.OnlyInBase)
+
+	baseLogs, err := ds.compareLogs(tables, baseVersion)
+	if err != nil {
+		return nil, err
+	}
+	headLogs, err := ds.compareLogs(tables, headVersion)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for key := range baseLogs {
+		keys = append(keys, key)
+	}
+	for key := range headLogs {
+		if _, ok := baseLogs[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		diff := models.LogDiffResponse{Key: key}
+		if value, ok := baseLogs[key]; ok {
+			diff.Base = &value
+		}
+		if value, ok := headLogs[key]; ok {
+			diff.Head = &value
+		}
+		diff.Changed = diff.Base == nil || diff.Head == nil || *diff.Base != *diff.Head
+		compare.Logs = append(compare.Logs, diff)
+	}
+
+	baseReadme, err := ds.compareReadme(tables, base.OwnerUUID, baseVersion)
+	if err != nil {
+		return nil, err
+	}
+	headReadme, err := ds.compareReadme(tables, head.OwnerUUID, headVersion)
+	if err != nil {
+		return nil, err
+	}
+	compare.Readme = models.ReadmeDiffResponse{
+		BaseVersion: baseReadme.Version,
+		Base:        baseReadme.Content,
+		HeadVersion: headReadme.Version,
+		Head:        headReadme.Content,
+		Changed:     baseReadme.Content != headReadme.Content,
+	}
+
+	compare.Files, err = ds.compareFiles(tables, baseVersion, headVersion)
+	if err != nil {
+		return nil, err
+	}
+	return compare, nil
+}
+
+// CompareModelBranches compares the head branch of the model with the base
+// branch. The versions are the latest versions of the branches if not set.
+func (ds *Datastore) CompareModelBranches(baseBranchUUID uuid.UUID, baseVersionUUID uuid.NullUUID, headBranchUUID uuid.UUID, headVersionUUID uuid.NullUUID) (*models.CompareResponse, error) {
+	return ds.compareBranches(modelCompareTables, baseBranchUUID, baseVersionUUID, headBranchUUID, headVersionUUID)
+}
+
+// CompareDatasetBranches compares the head branch of the dataset with the
+// base branch. The versions are the latest versions of the branches if not
+// set.
+func (ds *Datastore) CompareDatasetBranches(baseBranchUUID uuid.UUID, baseVersionUUID uuid.NullUUID, headBranchUUID uuid.UUID, headVersionUUID uuid.NullUUID) (*models.CompareResponse, error) {
+	return ds.compareBranches(datasetCompareTables, baseBranchUUID, baseVersionUUID, headBranchUUID, headVersionUUID)
+}
+
+/////////////////////////////// METRIC METHODS ///////////////////////////////
+
+// createMetrics stores the metrics of the version in a single transaction
+// and returns the aggregates of the keys they belong to.
+func (ds *Datastore) createMetrics(column string, versionUUID uuid.UUID, metricRequests []commonmodels.MetricRequest) ([]models.MetricSeriesResponse, error) {
+	version := uuid.NullUUID{UUID: versionUUID, Valid: true}
+	now := time.Now()
+	metrics := make([]dbmodels.Metric, 0, len(metricRequests))
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, metricRequest := range metricRequests {
+		metric := dbmodels.Metric{Key: metricRequest.Key, Value: *metricRequest.Value, Step: metricRequest.Step, Timestamp: now}
+		if metricRequest.Timestamp != nil {
+			metric.Timestamp = *metricRequest.Timestamp
+		}
+		if column == "model_version_uuid" {
+			metric.ModelVersionUUID = version
+		} else {
+			metric.DatasetVersionUUID = version
+		}
+		metrics = append(metrics, metric)
+		if !seen[metric.Key] {
+			seen[metric.Key] = true
+			keys = append(keys, metric.Key)
+		}
+	}
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&metrics, 100).Error; err != nil {
+			return err
+		}
+		events := make([]interface{}, 0, len(metrics))
+		for _, metric := range metrics {
+			events = append(events, models.StreamMetricResponse{Key: metric.Key, Value: metric.Value, Step: metric.Step, Timestamp: metric.Timestamp})
+		}
+		return recordVersionEvents(tx, column, versionUUID, models.StreamMetric, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds.getMetrics(column, versionUUID, keys, false)
+}
+
+// getMetrics returns the series of the metric keys of the version, all keys
+// if none are given, with or without their points.
+func (ds *Datastore) getMetrics(column string, versionUUID uuid.UUID, keys []string, withPoints bool) ([]models.MetricSeriesResponse, error) {
+	var metrics []dbmodels.Metric
+	query := ds.DB.Where(column+" = ?", versionUUID)
+	if len(keys) > 0 {
+		query = query.Where("key IN ?", keys)
+	}
+	if err := query.Order("key").Order("timestamp").Order("created_at").Find(&metrics).Error; err != nil {
+		return nil, err
+	}
+	// Points without a step are kept in time order before the stepped ones
+	sort.SliceStable(metrics, func(i, j int) bool {
+		if metrics[i].Key != metrics[j].Key {
+			return metrics[i].Key < metrics[j].Key
+		}
+		if metrics[j].Step == nil {
+			return false
+		}
+		return metrics[i].Step == nil || *metrics[i].Step < *metrics[j].Step
+	})
+	series := []models.MetricSeriesResponse{}
+	for _, metric := range metrics {
+		if len(series) == 0 || series[len(series)-1].Key != metric.Key {
+			series = append(series, models.MetricSeriesResponse{Key: metric.Key, Min: metric.Value, Max: metric.Value})
+		}
+		current := &series[len(series)-1]
+		if withPoints {
+			current.Points = append(current.Points, models.MetricPointResponse{Value: metric.Value, Step: metric.Step, Timestamp: metric.Timestamp})
+		}
+		current.Count++
+		current.Last = metric.Value
+		current.Min = math.Min(current.Min, metric.Value)
+		current.Max = math.Max(current.Max, metric.Value)
+		current.Mean += (metric.Value - current.Mean) / float64(current.Count)
+	}
+	return series, nil
+}
+
+// CreateModelVersionMetrics stores the metrics of the model version and
+// returns the aggregates of their keys.
+func (ds *Datastore) CreateModelVersionMetrics(modelVersionUUID uuid.UUID, metrics []commonmodels.MetricRequest) ([]models.MetricSeriesResponse, error) {
+	return ds.createMetrics("model_version_uuid", modelVersionUUID, metrics)
+}
+
+// GetModelVersionMetrics returns the metric series of the model version, of
+// the key only if set.
+func (ds *Datastore) GetModelVersionMetrics(modelVersionUUID uuid.UUID, key string, withPoints bool) ([]models.MetricSeriesResponse, error) {
+	var keys []string
+	if key != "" {
+		keys = append(keys, key)
+	}
+	return ds.getMetrics("model_version_uuid", modelVersionUUID, keys, withPoints)
+}
+
+// CreateDatasetVersionMetrics stores the metrics of the dataset version and
+// returns the aggregates of their keys.
+func (ds *Datastore) CreateDatasetVersionMetrics(datasetVersionUUID uuid.UUID, metrics []commonmodels.MetricRequest) ([]models.MetricSeriesResponse, error) {
+	return ds.createMetrics("dataset_version_uuid", datasetVersionUUID, metrics)
+}
+
+// GetDatasetVersionMetrics returns the metric series of the dataset version,
+// of the key only if set.
+func (ds *Datastore) GetDatasetVersionMetrics(datasetVersionUUID uuid.UUID, key string, withPoints bool) ([]models.MetricSeriesResponse, error) {
+	var keys []string
+	if key != "" {
+		keys = append(keys, key)
+	}
+	return ds.getMetrics("dataset_version_uuid", datasetVersionUUID, keys, withPoints)
+}
+
+/////////////////////////////// LOG BATCH METHODS ///////////////////////////////
+
+// createLogs writes the logs of the version in a single transaction. As for
+// a single log, a key already logged for the version has its data replaced,
+// so the last entry of a key wins. It returns whether each entry created or
+// updated its key.
+func (ds *Datastore) createLogs(column string, versionUUID uuid.UUID, logRequests []commonmodels.LogRequest) ([]string, error) {
+	version := uuid.NullUUID{UUID: versionUUID, Valid: true}
+	statuses := make([]string, len(logRequests))
+	err := ds.DB.Transaction(func(tx *gorm.DB) error {
+		keys := []string{}
+		for _, logRequest := range logRequests {
+			keys = append(keys, logRequest.Key)
+		}
+		var existing []dbmodels.Log
+		if err := tx.Where(column+" = ?", versionUUID).Where("key IN ?", keys).Find(&existing).Error; err != nil {
+			return err
+		}
+		logs := map[string]*dbmodels.Log{}
+		for i := range existing {
+			logs[existing[i].Key] = &existing[i]
+		}
+		created := []*dbmodels.Log{}
+		updated := []*dbmodels.Log{}
+		for i, logRequest := range logRequests {
+			log, ok := logs[logRequest.Key]
+			if !ok {
+				log = &dbmodels.Log{Key: logRequest.Key}
+				if column == "model_version_uuid" {
+					log.ModelVersionUUID = version
+				} else {
+					log.DatasetVersionUUID = version
+				}
+				logs[log.Key] = log
+				created = append(created, log)
+				statuses[i] = models.LogCreated
+			} else {
+				// the logs created by the batch are written with their last data
+				if log.UUID != uuid.Nil {
+					updated = append(updated, log)
+				}
+				statuses[i] = models.LogUpdated
+			}
+			log.Data = logRequest.Data
+			log.Type = logRequest.Type
+		}
+		if len(created) > 0 {
+			if err := tx.Omit(clause.Associations).CreateInBatches(created, 100).Error; err != nil {
+				return err
+			}
+		}
+		for _, log := range updated {
+			if err := tx.Model(&dbmodels.Log{}).Where("uuid = ?", log.UUID).Updates(map[string]interface{}{"data": log.Data, "type": log.Type}).Error; err != nil {
+				return err
+			}
+		}
+		events := make([]interface{}, 0, len(logRequests))
+		for _, logRequest := range logRequests {
+			events = append(events, models.StreamLogResponse{Key: logRequest.Key, Data: logRequest.Data, Type: logRequest.Type})
+		}
+		return recordVersionEvents(tx, column, versionUUID, models.StreamLog, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// CreateLogsForModelVersion writes the logs of the model version in a single
+// transaction and returns the result of each of them.
+func (ds *Datastore) CreateLogsForModelVersion(modelVersionUUID uuid.UUID, logs []commonmodels.LogRequest) ([]models.LogBatchEntryResponse, error) {
+	var modelVersion modeldbmodels.ModelVersion
+	if err := ds.DB.Where("uuid = ?", modelVersionUUID).First(&modelVersion).Error; err != nil {
+		return nil, err
+	}
+	statuses, err := ds.createLogs("model_version_uuid", modelVersionUUID, logs)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]models.LogBatchEntryResponse, 0, len(logs))
+	for i, log := range logs {
+		entries = append(entries, models.LogBatchEntryResponse{
+			Index:  i,
+			Key:    log.Key,
+			Status: statuses[i],
+			Log: &models.LogResponse{
+				Key:  log.Key,
+				Data: log.Data,
+				Type: log.Type,
+				ModelVersion: modelmodels.ModelBranchVersionNameResponse{
+					UUID:    modelVersion.UUID,
+					Version: modelVersion.Version,
+				},
+			},
+		})
+	}
+	return entries, nil
+}
+
+// CreateLogsForDatasetVersion writes the logs of the dataset version in a
+// single transaction and returns the result of each of them.
+func (ds *Datastore) CreateLogsForDatasetVersion(datasetVersionUUID uuid.UUID, logs []commonmodels.LogRequest) ([]models.LogBatchEntryResponse, error) {
+	var datasetVersion datasetdbmodels.DatasetVersion
+	if err := ds.DB.Where("uuid = ?", datasetVersionUUID).First(&datasetVersion).Error; err != nil {
+		return nil, err
+	}
+	statuses, err := ds.createLogs("dataset_version_uuid", datasetVersionUUID, logs)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]models.LogBatchEntryResponse, 0, len(logs))
+	for i, log := range logs {
+		entries = append(entries, models.LogBatchEntryResponse{
+			Index:  i,
+			Key:    log.Key,
+			Status: statuses[i],
+			Log: &models.LogResponse{
+				Key:  log.Key,
+				Data: log.Data,
+				Type: log.Type,
+				DatasetVersion: datasetmodels.DatasetBranchVersionNameResponse{
+					UUID:    datasetVersion.UUID,
+					Version: datasetVersion.Version,
+				},
+			},
+		})
+	}
+	return entries, nil
+}
+
+/////////////////////////////// LEADERBOARD METHODS ///////////////////////////////
+
+type leaderboardRow struct {
+	VersionUUID uuid.UUID
+	Version     string
+	Hash        string
+	Stage       string
+	CreatedAt   time.Time
+	BranchUUID  uuid.UUID
+	Branch      string
+	ModelUUID   uuid.UUID
+	Model       string
+	Data        string
+}
+
+// GetModelLeaderboard ranks the model versions of the org by the value
+// logged for the key of the query. The versions whose log is not a number
+// are left out. The dates are compared here rather than in the query as
+// they are stored as text by some databases.
+func (ds *Datastore) GetModelLeaderboard(orgId uuid.UUID, query modelmodels.ModelLeaderboardQuery) ([]modelmodels.ModelLeaderboardEntryResponse, error) {
+	db := ds.DB.Table("logs").
+		Select("model_versions.uuid AS version_uuid, model_versions.version, model_versions.hash, model_versions.stage, model_versions.created_at, model_branches.uuid AS branch_uuid, model_branches.name AS branch, models.uuid AS model_uuid, models.name AS model, logs.data").
+		Joins("JOIN model_versions ON model_versions.uuid = logs.model_version_uuid AND model_versions.deleted_at IS NULL").
+		Joins("JOIN model_branches ON model_branches.uuid = model_versions.branch_uuid AND model_branches.deleted_at IS NULL").
+		Joins("JOIN models ON models.uuid = model_branches.model_uuid AND models.deleted_at IS NULL").
+		Where("logs.deleted_at IS NULL").
+		Where("logs.key = ?", query.Key).
+		Where("models.organization_uuid = ?", orgId)
+	if query.ModelUUID.Valid {
+		db = db.Where("models.uuid = ?", query.ModelUUID.UUID)
+	}
+	if query.Branch != "" {
+		db = db.Where("model_branches.name = ?", query.Branch)
+	}
+	if query.Stage != "" {
+		db = db.Where("model_versions.stage = ?", query.Stage)
+	}
+	if query.Alias != "" {
+		db = db.Where("model_versions.uuid IN (?)", ds.DB.Model(&dbmodels.VersionAlias{}).Select("model_version_uuid").Where("name = ?", query.Alias))
+	}
+	var rows []leaderboardRow
+	if err := db.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	entries := []modelmodels.ModelLeaderboardEntryResponse{}
+	for _, row := range rows {
+		if query.From != nil && row.CreatedAt.Before(*query.From) {
+			continue
+		}
+		if query.To != nil && row.CreatedAt.After(*query.To) {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(row.Data), 64)
+		if err != nil || math.IsNaN(value) {
+			continue
+		}
+		entries = append(entries, modelmodels.ModelLeaderboardEntryResponse{
+			Model:     modelmodels.ModelNameResponse{UUID: row.ModelUUID, Name: row.Model},
+			Branch:    modelmodels.ModelBranchNameResponse{UUID: row.BranchUUID, Name: row.Branch},
+			Version:   modelmodels.ModelBranchVersionNameResponse{UUID: row.VersionUUID, Version: row.Version},
+			Hash:      row.Hash,
+			Stage:     row.Stage,
+			Aliases:   []string{},
+			CreatedAt: row.CreatedAt,
+			Value:     value,
+		})
+	}
+	// the earliest version comes first among equal values
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return (entries[i].Value < entries[j].Value) == query.Ascending
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	for i := range entries {
+		if i > 0 && entries[i].Value == entries[i-1].Value {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+	}
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	versionUUIDs := make([]uuid.UUID, 0, len(entries))
+	index := map[uuid.UUID]int{}
+	for i, entry := range entries {
+		versionUUIDs = append(versionUUIDs, entry.Version.UUID)
+		index[entry.Version.UUID] = i
+	}
+	var aliases []dbmodels.VersionAlias
+	if err := ds.DB.Where("model_version_uuid IN ?", versionUUIDs).Order("name").Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	for _, alias := range aliases {
+		i := index[alias.ModelVersionUUID.UUID]
+		entries[i].Aliases = append(entries[i].Aliases, alias.Name)
+	}
+	if len(query.Metrics) > 0 {
+		var logs []dbmodels.Log
+		if err := ds.DB.Where("model_version_uuid IN ?", versionUUIDs).Where("key IN ?", query.Metrics).Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			i := index[log.ModelVersionUUID.UUID]
+			if entries[i].Metrics == nil {
+				entries[i].Metrics = map[string]string{}
+			}
+			entries[i].Metrics[log.Key] = log.Data
+		}
+	}
+	return entries, nil
+}
+
+/////////////////////////////// STREAM EVENT METHODS ///////////////////////////////
+
+// maxStreamEvents is the maximum number of stream events returned at once.
+const maxStreamEvents = 500
+
+// streamVersion is a version of a model or dataset with its branch and the
+// model or dataset it belongs to.
+type streamVersion struct {
+	UUID      uuid.UUID
+	Version   string
+	Branch    string
+	Hash      string
+	CreatedAt time.Time
+	OwnerUUID uuid.UUID
+}
+
+// streamOwnerColumn returns the model or dataset column of the stream
+// events matching a version column.
+func streamOwnerColumn(column string) string {
+	if column == "dataset_version_uuid" {
+		return "dataset_uuid"
+	}
+	return "model_uuid"
+}
+
+// getStreamVersion returns the version the column refers to, nil if it
+// doesn't exist.
+func getStreamVersion(tx *gorm.DB, column string, versionUUID uuid.UUID) (*streamVersion, error) {
+	versions, branches := "model_versions", "model_branches"
+	if column == "dataset_version_uuid" {
+		versions, branches = "dataset_versions", "dataset_branches"
+	}
+	var version streamVersion
+	res := tx.Table(versions).
+		Select(versions+".uuid, "+versions+".version, "+branches+".name AS branch, "+versions+".hash, "+versions+".created_at, "+branches+"."+streamOwnerColumn(column)+" AS owner_uuid").
+		Joins("JOIN "+branches+" ON "+branches+".uuid = "+versions+".branch_uuid").
+		Where(versions+".uuid = ?", versionUUID).
+		Limit(1).
+		Scan(&version)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &version, nil
+}
+
+// newStreamEvent creates an event of the stream of the model or dataset
+// owning the version column.
+func newStreamEvent(column string, ownerUUID uuid.UUID, versionUUID uuid.NullUUID, kind string, data interface{}) (dbmodels.StreamEvent, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return dbmodels.StreamEvent{}, err
+	}
+	event := dbmodels.StreamEvent{Kind: kind, Data: string(encoded)}
+	owner := uuid.NullUUID{UUID: ownerUUID, Valid: true}
+	if column == "model_version_uuid" {
+		event.ModelUUID = owner
+		event.ModelVersionUUID = versionUUID
+	} else {
+		event.DatasetUUID = owner
+		event.DatasetVersionUUID = versionUUID
+	}
+	return event, nil
+}
+
+// recordVersionEvents appends an event of the kind for each data written
+// for the version to the stream of its model or dataset.
+func recordVersionEvents(tx *gorm.DB, column string, versionUUID uuid.UUID, kind string, data []interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	version, err := getStreamVersion(tx, column, versionUUID)
+	if err != nil || version == nil {
+		return err
+	}
+	events := make([]dbmodels.StreamEvent, 0, len(data))
+	for _, item := range data {
+		event, err := newStreamEvent(column, version.OwnerUUID, uuid.NullUUID{UUID: versionUUID, Valid: true}, kind, item)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	return tx.CreateInBatches(&events, 100).Error
+}
+
+// recordVersionRegistered appends the registration of the version to the
+// stream of its model or dataset.
+func recordVersionRegistered(tx *gorm.DB, column string, versionUUID uuid.UUID) error {
+	version, err := getStreamVersion(tx, column, versionUUID)
+	if err != nil || version == nil {
+		return err
+	}
+	event, err := newStreamEvent(column, version.OwnerUUID, uuid.NullUUID{UUID: versionUUID, Valid: true}, models.StreamVersion, models.StreamVersionResponse{
+		UUID:      version.UUID,
+		Version:   version.Version,
+		Branch:    version.Branch,
+		Hash:      version.Hash,
+		CreatedAt: version.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Create(&event).Error
+}
+
+// recordReview appends the created or updated review to the stream of the
+// model or dataset.
+func recordReview(tx *gorm.DB, column string, ownerUUID uuid.UUID, review models.StreamReviewResponse) error {
+	event, err := newStreamEvent(column, ownerUUID, uuid.NullUUID{}, models.StreamReview, review)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&event).Error
+}
+
+// getStreamEvents returns the events of the stream of the model or dataset
+// following afterID, of the version and kinds only if set.
+func (ds *Datastore) getStreamEvents(column string, ownerUUID uuid.UUID, versionUUID uuid.NullUUID, kinds []string, afterID uint64) ([]models.StreamEventResponse, error) {
+	var events []dbmodels.StreamEvent
+	query := ds.DB.Where(streamOwnerColumn(column)+" = ?", ownerUUID).Where("id > ?", afterID)
+	if versionUUID.Valid {
+		query = query.Where(column+" = ?", versionUUID.UUID)
+	}
+	if len(kinds) > 0 {
+		query = query.Where("kind IN ?", kinds)
+	}
+	if err := query.Order("id").Limit(maxStreamEvents).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	responses := make([]models.StreamEventResponse, 0, len(events))
+	for _, event := range events {
+		version := event.ModelVersionUUID
+		if column == "dataset_version_uuid" {
+			version = event.DatasetVersionUUID
+		}
+		response := models.StreamEventResponse{
+			ID:        event.ID,
+			Kind:      event.Kind,
+			Data:      json.RawMessage(event.Data),
+			CreatedAt: event.CreatedAt,
+		}
+		if version.Valid {
+			versionUUID := version.UUID
+			response.VersionUUID = &versionUUID
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// GetModelStreamEvents returns the events of the stream of the model
+// following afterID, of the version and kinds only if set.
+func (ds *Datastore) GetModelStreamEvents(modelUUID uuid.UUID, modelVersionUUID uuid.NullUUID, kinds []string, afterID uint64) ([]models.StreamEventResponse, error) {
+	return ds.getStreamEvents("model_version_uuid", modelUUID, modelVersionUUID, kinds, afterID)
+}
+
+// GetDatasetStreamEvents returns the events of the stream of the dataset
+// following afterID, of the version and kinds only if set.
+func (ds *Datastore) GetDatasetStreamEvents(datasetUUID uuid.UUID, datasetVersionUUID uuid.NullUUID, kinds []string, afterID uint64) ([]models.StreamEventResponse, error) {
+	return ds.getStreamEvents("dataset_version_uuid", datasetUUID, datasetVersionUUID, kinds, afterID)
+}
+
+// GetLastStreamEventID returns the id of the last event of all the streams,
+// 0 if there is none.
+func (ds *Datastore) GetLastStreamEventID() (uint64, error) {
+	var id uint64
+	err := ds.DB.Model(&dbmodels.StreamEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetLastStreamEventIDBefore returns the id of the last event of all the
+// streams created before the time, 0 if there is none.
+func (ds *Datastore) GetLastStreamEventIDBefore(before time.Time) (uint64, error) {
+	var id uint64
+	err := ds.DB.Model(&dbmodels.StreamEvent{}).Where("created_at < ?", before).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// PurgeStreamEvents deletes the stream events created before the time and
+// returns how many were deleted.
+func (ds *Datastore) PurgeStreamEvents(before time.Time) (int64, error) {
+	res := ds.DB.Where("created_at < ?", before).Delete(&dbmodels.StreamEvent{})
+	return res.RowsAffected, res.Error
+}
+
+// streamModelReview returns the data of the stream event of the review.
+func streamModelReview(review modeldbmodels.ModelReview) models.StreamReviewResponse {
+	return models.StreamReviewResponse{
+		UUID:              review.UUID,
+		Title:             review.Title,
+		FromBranch:        review.FromBranch.UUID,
+		FromBranchVersion: review.FromBranchVersion.UUID,
+		ToBranch:          review.ToBranch.UUID,
+		IsComplete:        review.IsComplete,
+		IsAccepted:        review.IsAccepted,
+	}
+}
+
+// streamDatasetReview returns the data of the stream event of the review.
+func streamDatasetReview(review datasetdbmodels.DatasetReview) models.StreamReviewResponse {
+	return models.StreamReviewResponse{
+		UUID:              review.UUID,
+		Title:             review.Title,
+		FromBranch:        review.FromBranch.UUID,
+		FromBranchVersion: review.FromBranchVersion.UUID,
+		ToBranch:          review.ToBranch.UUID,
+		IsComplete:        review.IsComplete,
+		IsAccepted:        review.IsAccepted,
+	}
+}
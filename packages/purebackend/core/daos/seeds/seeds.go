@@ -5,6 +5,7 @@ import (
 
 	authdbmodels "github.com/PureMLHQ/PureML/packages/purebackend/auth/dbmodels"
 	commondbmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
 	datasetdbmodels "github.com/PureMLHQ/PureML/packages/purebackend/dataset/dbmodels"
 	modeldbmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/dbmodels"
 	userorgdbmodels "github.com/PureMLHQ/PureML/packages/purebackend/user_org/dbmodels"
@@ -15,6 +16,7 @@ import (
 
 var defaultUUID = uuid.Must(uuid.FromString("11111111-1111-1111-1111-111111111111"))
 var defaultUUID2 = uuid.Must(uuid.FromString("22222222-2222-2222-2222-222222222222"))
+var defaultNullUUID = uuid.NullUUID{UUID: defaultUUID, Valid: true}
 
 func All() []Seed {
 	return []Seed{
@@ -188,6 +190,17 @@ func CreateModel(db *gorm.DB, uuid uuid.UUID, name string, wiki string, isPublic
 	if err != nil {
 		return err
 	}
+	if isPublic {
+		err = db.Create(&dbmodels.BranchProtection{
+			OrganizationUUID:        defaultUUID,
+			ModelBranchUUID:         defaultNullUUID,
+			BlockDirectRegistration: true,
+			UpdatedBy:               defaultUUID,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -287,6 +300,17 @@ func CreateDataset(db *gorm.DB, uuid uuid.UUID, name string, wiki string, isPubl
 	if err != nil {
 		return err
 	}
+	if isPublic {
+		err = db.Create(&dbmodels.BranchProtection{
+			OrganizationUUID:        defaultUUID,
+			DatasetBranchUUID:       defaultNullUUID,
+			BlockDirectRegistration: true,
+			UpdatedBy:               defaultUUID,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
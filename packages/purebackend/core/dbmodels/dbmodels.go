@@ -1,6 +1,8 @@
 package dbmodels
 
 import (
+	"time"
+
 	commondbmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/dbmodels"
 	datasetdbmodels "github.com/PureMLHQ/PureML/packages/purebackend/dataset/dbmodels"
 	modeldbmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/dbmodels"
@@ -21,15 +23,52 @@ type Activity struct {
 	Dataset datasetdbmodels.Dataset `gorm:"foreignKey:DatasetUUID"`
 }
 
+// Tag is a free-form label of a model or dataset version, used to search
+// and filter the versions. Tags are immutable once added.
 type Tag struct {
-	ModelUUID        uuid.NullUUID `json:"model_uuid" gorm:"type:uuid;primaryKey"`
-	DatasetUUID      uuid.NullUUID `json:"dataset_uuid" gorm:"type:uuid;primaryKey"`
-	OrganizationUUID uuid.UUID     `json:"organization_uuid" gorm:"type:uuid;not null;index:idx_org_tag,unique"`
-	Tag              string        `json:"tag" gorm:"not null;index:idx_org_tag,unique"`
+	commondbmodels.BaseModel `gorm:"embedded"`
+	OrganizationUUID         uuid.UUID     `json:"organization_uuid" gorm:"type:uuid;not null;index"`
+	ModelVersionUUID         uuid.NullUUID `json:"model_version_uuid" gorm:"type:uuid;index:idx_model_version_tag,unique"`
+	DatasetVersionUUID       uuid.NullUUID `json:"dataset_version_uuid" gorm:"type:uuid;index:idx_dataset_version_tag,unique"`
+	Tag                      string        `json:"tag" gorm:"not null;index;index:idx_model_version_tag,unique;index:idx_dataset_version_tag,unique"`
+	CreatedBy                uuid.UUID     `json:"created_by" gorm:"type:uuid;not null"`
 
-	Model   modeldbmodels.Model          `gorm:"foreignKey:ModelUUID"`
-	Dataset datasetdbmodels.Dataset      `gorm:"foreignKey:DatasetUUID"`
-	Org     userorgdbmodels.Organization `gorm:"foreignKey:OrganizationUUID"`
+	Org            userorgdbmodels.Organization   `gorm:"foreignKey:OrganizationUUID"`
+	ModelVersion   modeldbmodels.ModelVersion     `gorm:"foreignKey:ModelVersionUUID"`
+	DatasetVersion datasetdbmodels.DatasetVersion `gorm:"foreignKey:DatasetVersionUUID"`
+	CreatedByUser  userorgdbmodels.User           `gorm:"foreignKey:CreatedBy"`
+}
+
+// VersionAlias is a named, movable pointer of a model or dataset to one of
+// its versions (eg. production -> main/v7).
+type VersionAlias struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	Name                     string        `json:"name" gorm:"not null;index:idx_model_alias,unique;index:idx_dataset_alias,unique"`
+	ModelUUID                uuid.NullUUID `json:"model_uuid" gorm:"type:uuid;index:idx_model_alias,unique"`
+	DatasetUUID              uuid.NullUUID `json:"dataset_uuid" gorm:"type:uuid;index:idx_dataset_alias,unique"`
+	ModelVersionUUID         uuid.NullUUID `json:"model_version_uuid" gorm:"type:uuid"`
+	DatasetVersionUUID       uuid.NullUUID `json:"dataset_version_uuid" gorm:"type:uuid"`
+	UpdatedBy                uuid.UUID     `json:"updated_by" gorm:"type:uuid;not null"`
+
+	ModelVersion   modeldbmodels.ModelVersion     `gorm:"foreignKey:ModelVersionUUID"`
+	DatasetVersion datasetdbmodels.DatasetVersion `gorm:"foreignKey:DatasetVersionUUID"`
+	UpdatedByUser  userorgdbmodels.User           `gorm:"foreignKey:UpdatedBy"`
+}
+
+// VersionAliasEvent records a move of a version alias. The version is null
+// when the alias was removed.
+type VersionAliasEvent struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	Name                     string        `json:"name" gorm:"not null;index"`
+	ModelUUID                uuid.NullUUID `json:"model_uuid" gorm:"type:uuid;index"`
+	DatasetUUID              uuid.NullUUID `json:"dataset_uuid" gorm:"type:uuid;index"`
+	ModelVersionUUID         uuid.NullUUID `json:"model_version_uuid" gorm:"type:uuid"`
+	DatasetVersionUUID       uuid.NullUUID `json:"dataset_version_uuid" gorm:"type:uuid"`
+	CreatedBy                uuid.UUID     `json:"created_by" gorm:"type:uuid;not null"`
+
+	ModelVersion   modeldbmodels.ModelVersion     `gorm:"foreignKey:ModelVersionUUID"`
+	DatasetVersion datasetdbmodels.DatasetVersion `gorm:"foreignKey:DatasetVersionUUID"`
+	CreatedByUser  userorgdbmodels.User           `gorm:"foreignKey:CreatedBy"`
 }
 
 type Log struct {
@@ -43,3 +82,209 @@ type Log struct {
 	ModelVersion   modeldbmodels.ModelVersion     `gorm:"foreignKey:ModelVersionUUID"`
 	DatasetVersion datasetdbmodels.DatasetVersion `gorm:"foreignKey:DatasetVersionUUID"`
 }
+
+// Metric is a numeric value logged for a model or dataset version, with an
+// optional step so that a key holds a series (eg. the accuracy per epoch).
+type Metric struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	Key                      string        `json:"key" gorm:"not null;index"`
+	Value                    float64       `json:"value"`
+	Step                     *int64        `json:"step"`
+	Timestamp                time.Time     `json:"timestamp"`
+	ModelVersionUUID         uuid.NullUUID `json:"model_version_uuid" gorm:"type:uuid;index"`
+	DatasetVersionUUID       uuid.NullUUID `json:"dataset_version_uuid" gorm:"type:uuid;index"`
+}
+
+// StreamEvent is an entry of the live stream of a model or dataset: a log
+// or a metric written for one of its versions, a registered version or a
+// review. The auto incremented ID orders the stream and is the id of the
+// server-sent event, so that clients resume after the last one they got.
+type StreamEvent struct {
+	ID                 uint64        `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind               string        `json:"kind" gorm:"not null"`
+	ModelUUID          uuid.NullUUID `json:"model_uuid" gorm:"type:uuid;index"`
+	DatasetUUID        uuid.NullUUID `json:"dataset_uuid" gorm:"type:uuid;index"`
+	ModelVersionUUID   uuid.NullUUID `json:"model_version_uuid" gorm:"type:uuid;index"`
+	DatasetVersionUUID uuid.NullUUID `json:"dataset_version_uuid" gorm:"type:uuid;index"`
+	Data               string        `json:"data"`
+	CreatedAt          time.Time     `json:"created_at" gorm:"index"`
+}
+
+type VersionFile struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	ModelVersionUUID         uuid.NullUUID `json:"model_version_uuid" gorm:"type:uuid;index"`
+	DatasetVersionUUID       uuid.NullUUID `json:"dataset_version_uuid" gorm:"type:uuid;index"`
+	Name                     string        `json:"name" gorm:"not null"`
+	Size                     int64         `json:"size"`
+	Digest                   string        `json:"digest" gorm:"not null"`
+	MediaType                string        `json:"media_type"`
+	Path                     string        `json:"path"`
+	SourceType               string        `json:"source_type"`
+	BlobUUID                 uuid.NullUUID `json:"blob_uuid" gorm:"type:uuid;"`
+	EncryptionKey            string        `json:"encryption_key"`
+	EncryptionKeyVersion     int           `json:"encryption_key_version"`
+
+	ModelVersion   modeldbmodels.ModelVersion     `gorm:"foreignKey:ModelVersionUUID"`
+	DatasetVersion datasetdbmodels.DatasetVersion `gorm:"foreignKey:DatasetVersionUUID"`
+}
+
+type UploadSession struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	OrganizationUUID         uuid.UUID     `json:"organization_uuid" gorm:"type:uuid;not null"`
+	ModelBranchUUID          uuid.NullUUID `json:"model_branch_uuid" gorm:"type:uuid;"`
+	DatasetBranchUUID        uuid.NullUUID `json:"dataset_branch_uuid" gorm:"type:uuid;"`
+	FileName                 string        `json:"file_name" gorm:"not null"`
+	Storage                  string        `json:"storage" gorm:"not null"`
+	IsComplete               bool          `json:"is_complete" default:"false"`
+	CreatedBy                uuid.UUID     `json:"created_by" gorm:"type:uuid;not null"`
+
+	Org           userorgdbmodels.Organization  `gorm:"foreignKey:OrganizationUUID"`
+	ModelBranch   modeldbmodels.ModelBranch     `gorm:"foreignKey:ModelBranchUUID"`
+	DatasetBranch datasetdbmodels.DatasetBranch `gorm:"foreignKey:DatasetBranchUUID"`
+	CreatedByUser userorgdbmodels.User          `gorm:"foreignKey:CreatedBy"`
+	Chunks        []UploadChunk                 `gorm:"foreignKey:SessionUUID"`
+}
+
+type UploadChunk struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	SessionUUID              uuid.UUID `json:"session_uuid" gorm:"type:uuid;not null;index:idx_upload_session_chunk,unique"`
+	Number                   int       `json:"number" gorm:"not null;index:idx_upload_session_chunk,unique"`
+	Size                     int64     `json:"size"`
+}
+
+type Blob struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	OrganizationUUID         uuid.UUID `json:"organization_uuid" gorm:"type:uuid;not null;index:idx_org_source_blob,unique"`
+	SourceType               string    `json:"source_type" gorm:"not null;index:idx_org_source_blob,unique"`
+	SourceURL                string    `json:"source_url" gorm:"index:idx_org_source_blob,unique"`
+	Digest                   string    `json:"digest" gorm:"not null;index:idx_org_source_blob,unique"`
+	Key                      string    `json:"key" gorm:"not null"`
+	Size                     int64     `json:"size"`
+	RefCount                 int       `json:"ref_count"`
+	IsPending                bool      `json:"is_pending" gorm:"default:false"`
+	EncryptionKey            string    `json:"encryption_key"`
+	EncryptionKeyVersion     int       `json:"encryption_key_version"`
+
+	Org userorgdbmodels.Organization `gorm:"foreignKey:OrganizationUUID"`
+}
+
+type StorageUsage struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	OrganizationUUID         uuid.UUID     `json:"organization_uuid" gorm:"type:uuid;not null;index:idx_org_source_usage,unique"`
+	SourceType               string        `json:"source_type" gorm:"not null;index:idx_org_source_usage,unique"`
+	SourceURL                string        `json:"source_url" gorm:"index:idx_org_source_usage,unique"`
+	Key                      string        `json:"key" gorm:"not null;index:idx_org_source_usage,unique"`
+	Size                     int64         `json:"size"`
+	ModelUUID                uuid.NullUUID `json:"model_uuid" gorm:"type:uuid;"`
+	DatasetUUID              uuid.NullUUID `json:"dataset_uuid" gorm:"type:uuid;"`
+	ModelVersionUUID         uuid.NullUUID `json:"model_version_uuid" gorm:"type:uuid;"`
+	DatasetVersionUUID       uuid.NullUUID `json:"dataset_version_uuid" gorm:"type:uuid;"`
+
+	Org     userorgdbmodels.Organization `gorm:"foreignKey:OrganizationUUID"`
+	Model   modeldbmodels.Model          `gorm:"foreignKey:ModelUUID"`
+	Dataset datasetdbmodels.Dataset      `gorm:"foreignKey:DatasetUUID"`
+}
+
+type StorageMigration struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	OrganizationUUID         uuid.UUID `json:"organization_uuid" gorm:"type:uuid;not null"`
+	SourceStorage            string    `json:"source_storage" gorm:"not null"`
+	TargetStorage            string    `json:"target_storage" gorm:"not null"`
+	Status                   string    `json:"status" gorm:"not null;default:pending"`
+	Error                    string    `json:"error"`
+	CreatedBy                uuid.UUID `json:"created_by" gorm:"type:uuid;not null"`
+
+	Org           userorgdbmodels.Organization `gorm:"foreignKey:OrganizationUUID"`
+	CreatedByUser userorgdbmodels.User         `gorm:"foreignKey:CreatedBy"`
+	Items         []StorageMigrationItem       `gorm:"foreignKey:MigrationUUID"`
+}
+
+type StorageMigrationItem struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	MigrationUUID            uuid.UUID     `json:"migration_uuid" gorm:"type:uuid;not null;index"`
+	ModelUUID                uuid.NullUUID `json:"model_uuid" gorm:"type:uuid;"`
+	DatasetUUID              uuid.NullUUID `json:"dataset_uuid" gorm:"type:uuid;"`
+	ModelVersionUUID         uuid.NullUUID `json:"model_version_uuid" gorm:"type:uuid;"`
+	DatasetVersionUUID       uuid.NullUUID `json:"dataset_version_uuid" gorm:"type:uuid;"`
+	LogUUID                  uuid.NullUUID `json:"log_uuid" gorm:"type:uuid;"`
+	VersionFileUUID          uuid.NullUUID `json:"version_file_uuid" gorm:"type:uuid;"`
+	SourceKey                string        `json:"source_key" gorm:"not null"`
+	SourceBlobUUID           uuid.NullUUID `json:"source_blob_uuid" gorm:"type:uuid;"`
+	TargetKey                string        `json:"target_key"`
+	Digest                   string        `json:"digest"`
+	Size                     int64         `json:"size"`
+	Status                   string        `json:"status" gorm:"not null;default:pending"`
+	Error                    string        `json:"error"`
+}
+
+// ImportJob is a server side import of an artifact from an url, which is
+// registered as a new version of the branch once fetched and verified.
+type ImportJob struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	OrganizationUUID         uuid.UUID     `json:"organization_uuid" gorm:"type:uuid;not null"`
+	ModelBranchUUID          uuid.NullUUID `json:"model_branch_uuid" gorm:"type:uuid;"`
+	DatasetBranchUUID        uuid.NullUUID `json:"dataset_branch_uuid" gorm:"type:uuid;"`
+	URL                      string        `json:"url" gorm:"not null"`
+	Hash                     string        `json:"hash" gorm:"not null"`
+	HashAlgorithm            string        `json:"hash_algorithm"`
+	Storage                  string        `json:"storage" gorm:"not null"`
+	Lineage                  string        `json:"lineage"`
+	Status                   string        `json:"status" gorm:"not null;default:pending"`
+	Error                    string        `json:"error"`
+	Size                     int64         `json:"size"`
+	Version                  string        `json:"version"`
+	CreatedBy                uuid.UUID     `json:"created_by" gorm:"type:uuid;not null"`
+
+	Org           userorgdbmodels.Organization  `gorm:"foreignKey:OrganizationUUID"`
+	ModelBranch   modeldbmodels.ModelBranch     `gorm:"foreignKey:ModelBranchUUID"`
+	DatasetBranch datasetdbmodels.DatasetBranch `gorm:"foreignKey:DatasetBranchUUID"`
+	CreatedByUser userorgdbmodels.User          `gorm:"foreignKey:CreatedBy"`
+}
+
+// TrashItem is a soft deleted model, model branch, dataset or version of an
+// org, which can be restored until it is purged at PurgeAt.
+type TrashItem struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	OrganizationUUID         uuid.UUID `json:"organization_uuid" gorm:"type:uuid;not null;index"`
+	Kind                     string    `json:"kind" gorm:"not null;index:idx_trash_object,unique"`
+	ObjectUUID               uuid.UUID `json:"object_uuid" gorm:"type:uuid;not null;index:idx_trash_object,unique"`
+	Name                     string    `json:"name"`
+	DeletedBy                uuid.UUID `json:"deleted_by" gorm:"type:uuid;not null"`
+	PurgeAt                  time.Time `json:"purge_at" gorm:"index"`
+
+	DeletedByUser userorgdbmodels.User `gorm:"foreignKey:DeletedBy"`
+}
+
+// BranchProtection holds the protection rules of a model or dataset branch.
+// When Reviewers is not empty, only these users can approve and accept the
+// reviews into the branch.
+type BranchProtection struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	OrganizationUUID         uuid.UUID     `json:"organization_uuid" gorm:"type:uuid;not null;index"`
+	ModelBranchUUID          uuid.NullUUID `json:"model_branch_uuid" gorm:"type:uuid;index:idx_model_branch_protection,unique"`
+	DatasetBranchUUID        uuid.NullUUID `json:"dataset_branch_uuid" gorm:"type:uuid;index:idx_dataset_branch_protection,unique"`
+	BlockDirectRegistration  bool          `json:"block_direct_registration"`
+	RequiredApprovals        int           `json:"required_approvals"`
+	BlockDeletion            bool          `json:"block_deletion"`
+	UpdatedBy                uuid.UUID     `json:"updated_by" gorm:"type:uuid;not null"`
+
+	Reviewers     []BranchProtectionReviewer `gorm:"foreignKey:BranchProtectionUUID"`
+	UpdatedByUser userorgdbmodels.User       `gorm:"foreignKey:UpdatedBy"`
+}
+
+type BranchProtectionReviewer struct {
+	BranchProtectionUUID uuid.UUID `json:"branch_protection_uuid" gorm:"type:uuid;primaryKey"`
+	UserUUID             uuid.UUID `json:"user_uuid" gorm:"type:uuid;primaryKey"`
+
+	User userorgdbmodels.User `gorm:"foreignKey:UserUUID"`
+}
+
+// ReviewApproval is the approval of a model or dataset review by a user.
+type ReviewApproval struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	ModelReviewUUID          uuid.NullUUID `json:"model_review_uuid" gorm:"type:uuid;index:idx_model_review_approval,unique"`
+	DatasetReviewUUID        uuid.NullUUID `json:"dataset_review_uuid" gorm:"type:uuid;index:idx_dataset_review_approval,unique"`
+	UserUUID                 uuid.UUID     `json:"user_uuid" gorm:"type:uuid;not null;index:idx_model_review_approval,unique;index:idx_dataset_review_approval,unique"`
+
+	User userorgdbmodels.User `gorm:"foreignKey:UserUUID"`
+}
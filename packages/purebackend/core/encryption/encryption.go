@@ -0,0 +1,134 @@
+// Package encryption implements the envelope encryption of the stored
+// model and dataset files.
+//
+// Every file stored for an organization with encryption enabled is
+// encrypted with its own random data key. The data key is stored with the
+// version, wrapped with the organization key, which is derived from the
+// instance master key and the key version of the organization. Rotating
+// the organization key only rewraps the data keys, so the files don't
+// need to be uploaded again.
+package encryption
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/security"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrNotConfigured = errors.New("encryption at rest is not configured for this instance")
+	ErrOrgNotFound   = errors.New("organization not found")
+)
+
+// Configured reports whether the instance has a master key to derive the
+// organization keys from.
+func Configured(app core.App) bool {
+	return app.Settings().Encryption.MasterKey != ""
+}
+
+func orgKey(app core.App, orgId uuid.UUID, keyVersion int) (string, error) {
+	if !Configured(app) {
+		return "", ErrNotConfigured
+	}
+	return security.DeriveKey(app.Settings().Encryption.MasterKey, fmt.Sprintf("org:%s:%d", orgId, keyVersion)), nil
+}
+
+// NewDataKey generates the data key of a new file of the org. The returned
+// key is empty (ie. the file is stored as is) when the org doesn't encrypt
+// its files.
+func NewDataKey(app core.App, orgId uuid.UUID) (*commonmodels.DataKey, error) {
+	orgEncryption, err := app.Dao().GetOrgEncryption(orgId)
+	if err != nil {
+		return nil, err
+	}
+	if orgEncryption == nil || !orgEncryption.Enabled {
+		return &commonmodels.DataKey{}, nil
+	}
+	key, err := orgKey(app, orgId, orgEncryption.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	dataKey := security.RandomString(32)
+	wrappedKey, err := security.Encrypt([]byte(dataKey), key)
+	if err != nil {
+		return nil, err
+	}
+	return &commonmodels.DataKey{
+		Key:        dataKey,
+		WrappedKey: wrappedKey,
+		KeyVersion: orgEncryption.KeyVersion,
+	}, nil
+}
+
+// Open unwraps the data key of a stored file of the org. An empty key is
+// returned for plain files.
+func Open(app core.App, orgId uuid.UUID, dataKey *commonmodels.DataKey) (string, error) {
+	if dataKey == nil || dataKey.WrappedKey == "" {
+		return "", nil
+	}
+	key, err := orgKey(app, orgId, dataKey.KeyVersion)
+	if err != nil {
+		return "", err
+	}
+	plainKey, err := security.Decrypt(dataKey.WrappedKey, key)
+	if err != nil {
+		return "", err
+	}
+	return string(plainKey), nil
+}
+
+// Rotate moves the org to a new key version and rewraps the data keys of
+// its model and dataset versions with the new org key.
+//
+// The previous org keys stay derivable, so the data keys that are not
+// rewrapped yet (eg. after an interruption) remain readable and are
+// rewrapped by the next rotation.
+func Rotate(app core.App, orgId uuid.UUID) (*models.OrgEncryptionResponse, error) {
+	orgEncryption, err := app.Dao().GetOrgEncryption(orgId)
+	if err != nil {
+		return nil, err
+	}
+	if orgEncryption == nil {
+		return nil, ErrOrgNotFound
+	}
+	keyVersion := orgEncryption.KeyVersion + 1
+	key, err := orgKey(app, orgId, keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := app.Dao().UpdateOrg(orgId, map[string]interface{}{
+		"encryption_key_version": keyVersion,
+	}); err != nil {
+		return nil, err
+	}
+	orgEncryption.KeyVersion = keyVersion
+
+	dataKeys, err := app.Dao().GetOrgVersionDataKeys(orgId, keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	for _, versionKey := range dataKeys {
+		plainKey, err := Open(app, orgId, &versionKey.DataKey)
+		if err != nil {
+			return nil, fmt.Errorf("unwrap data key of %s %s: %w", versionKey.Kind, versionKey.UUID, err)
+		}
+		wrappedKey, err := security.Encrypt([]byte(plainKey), key)
+		if err != nil {
+			return nil, err
+		}
+		err = app.Dao().UpdateVersionDataKey(versionKey.Kind, versionKey.UUID, versionKey.DataKey.KeyVersion, &commonmodels.DataKey{
+			WrappedKey: wrappedKey,
+			KeyVersion: keyVersion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		orgEncryption.RewrappedKeys++
+	}
+	return orgEncryption, nil
+}
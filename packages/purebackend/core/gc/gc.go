@@ -0,0 +1,235 @@
+// Package gc removes orphaned objects (uploads that were never registered,
+// files of deleted branches, etc.) from the storages of the organizations.
+package gc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// DefaultGracePeriod is how long an unreferenced object is kept before
+	// it is collected, so that in-flight uploads are never removed.
+	DefaultGracePeriod = 24 * time.Hour
+
+	// DefaultInterval is the default period of the background collector.
+	DefaultInterval = 24 * time.Hour
+)
+
+// Options defines the collector run options.
+type Options struct {
+	// DryRun only reports the orphaned objects without deleting them.
+	DryRun bool
+
+	// GracePeriod is the minimum age of a collected object.
+	GracePeriod time.Duration
+}
+
+// OrgPrefixes returns the storage prefixes owned by the org.
+func OrgPrefixes(orgId uuid.UUID) []string {
+	return []string{
+		fmt.Sprintf("model-registry/%s/", orgId),
+		fmt.Sprintf("dataset-registry/%s/", orgId),
+		fmt.Sprintf("blobs/%s/", orgId),
+	}
+}
+
+// IsManagedKey reports whether the key is under the prefixes where the
+// registry stores the objects of any org, which may be collected.
+func IsManagedKey(key string) bool {
+	for _, prefix := range []string{"model-registry/", "dataset-registry/", "blobs/"} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunAll collects the orphaned objects of every organization.
+func RunAll(app core.App, options Options) ([]*models.GCReportResponse, error) {
+	orgs, err := app.Dao().GetAllAdminOrgs()
+	if err != nil {
+		return nil, err
+	}
+	reports := []*models.GCReportResponse{}
+	for _, org := range orgs {
+		report, err := Run(app, org.UUID, options)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// Run walks the org prefixes of every storage connected to the org and
+// deletes the objects that are neither referenced by a model or dataset
+// version, a file log, a blob nor an open upload session, and that are
+// older than the grace period.
+func Run(app core.App, orgId uuid.UUID, options Options) (*models.GCReportResponse, error) {
+	storages, err := OrgStorages(app, orgId)
+	if err != nil {
+		return nil, err
+	}
+	references, err := app.Dao().GetOrgStorageReferences(orgId)
+	if err != nil {
+		return nil, err
+	}
+	openSessions, err := app.Dao().GetOrgOpenUploadSessions(orgId)
+	if err != nil {
+		return nil, err
+	}
+	referenced := referencedKeys(references, storages)
+
+	report := &models.GCReportResponse{
+		OrgUUID:     orgId,
+		DryRun:      options.DryRun,
+		GracePeriod: int64(options.GracePeriod.Seconds()),
+		Orphans:     []models.GCOrphanResponse{},
+		Errors:      []string{},
+	}
+	cutoff := time.Now().Add(-options.GracePeriod)
+	scanned := map[string]struct{}{}
+	for _, sourceSecrets := range storages {
+		// several sources may point to the same bucket
+		if _, ok := scanned[storageID(sourceSecrets)]; ok {
+			continue
+		}
+		scanned[storageID(sourceSecrets)] = struct{}{}
+		fs, err := app.NewFilesystem(sourceSecrets)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", sourceSecrets.SourceType, err))
+			continue
+		}
+		for _, prefix := range OrgPrefixes(orgId) {
+			objects, err := fs.List(prefix)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", sourceSecrets.SourceType, err))
+				continue
+			}
+			for _, obj := range objects {
+				report.Scanned++
+				if _, ok := referenced[obj.Key]; ok || obj.ModTime.After(cutoff) || inUploadSession(obj.Key, openSessions) {
+					continue
+				}
+				report.Orphans = append(report.Orphans, models.GCOrphanResponse{
+					SourceType: sourceSecrets.SourceType,
+					Key:        obj.Key,
+					Size:       obj.Size,
+					ModTime:    obj.ModTime,
+				})
+				if options.DryRun {
+					continue
+				}
+				if err := fs.Delete(obj.Key); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", obj.Key, err))
+					continue
+				}
+				if err := app.Dao().RemoveStorageUsage(orgId, sourceSecrets.SourceType, sourceSecrets.PublicURL, obj.Key); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", obj.Key, err))
+				}
+				report.Deleted++
+				report.ReclaimedBytes += obj.Size
+			}
+		}
+		fs.Close()
+	}
+	return report, nil
+}
+
+// Start runs the collector for every organization periodically in the
+// background until the returned stop function is called.
+func Start(app core.App, interval time.Duration, options Options) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				reports, err := RunAll(app, options)
+				if err != nil {
+					log.Println("storage gc:", err)
+				}
+				for _, report := range reports {
+					if report.Deleted > 0 || len(report.Errors) > 0 {
+						log.Printf("storage gc: org %s deleted %d orphans (%d bytes), %d errors\n", report.OrgUUID, report.Deleted, report.ReclaimedBytes, len(report.Errors))
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// OrgStorages returns the LOCAL storage, the managed PUREML-STORAGE bucket
+// (if configured) and the storages of the org secrets.
+func OrgStorages(app core.App, orgId uuid.UUID) ([]*commonmodels.SourceSecrets, error) {
+	storages := []*commonmodels.SourceSecrets{
+		{SourceType: "LOCAL"},
+	}
+	if managed, err := app.ManagedStorageSecrets(); err == nil {
+		storages = append(storages, managed)
+	}
+	secretNames, err := app.Dao().GetOrganizationSecrets(orgId)
+	if err != nil {
+		return nil, err
+	}
+	for _, secretName := range secretNames {
+		sourceSecrets, err := app.Dao().GetSecretByName(orgId, secretName)
+		if err != nil || sourceSecrets == nil {
+			continue
+		}
+		storages = append(storages, sourceSecrets)
+	}
+	return storages, nil
+}
+
+// referencedKeys resolves the recorded paths to storage keys. A path is
+// resolved against the public url of every storage so that buckets shared
+// by several secrets never lose referenced objects.
+func referencedKeys(references []string, storages []*commonmodels.SourceSecrets) map[string]struct{} {
+	keys := map[string]struct{}{}
+	for _, reference := range references {
+		if reference == "" {
+			continue
+		}
+		keys[strings.TrimPrefix(reference, "/")] = struct{}{}
+		for _, sourceSecrets := range storages {
+			if sourceSecrets.PublicURL != "" && strings.HasPrefix(reference, sourceSecrets.PublicURL+"/") {
+				keys[strings.TrimPrefix(reference, sourceSecrets.PublicURL+"/")] = struct{}{}
+			}
+		}
+	}
+	return keys
+}
+
+func storageID(sourceSecrets *commonmodels.SourceSecrets) string {
+	if sourceSecrets.SourceType == "LOCAL" {
+		return "LOCAL"
+	}
+	return sourceSecrets.Endpoint + "|" + sourceSecrets.AccountId + "|" + sourceSecrets.BucketName
+}
+
+func inUploadSession(key string, openSessions []uuid.UUID) bool {
+	for _, sessionUUID := range openSessions {
+		if strings.Contains(key, sessionUUID.String()) {
+			return true
+		}
+	}
+	return false
+}
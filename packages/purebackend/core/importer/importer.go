@@ -0,0 +1,125 @@
+// Package importer fetches the artifacts imported from urls on the server
+// side, so that the clients don't have to download and upload them again.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+)
+
+var (
+	ErrInvalidURL     = errors.New("only http and https urls can be imported")
+	ErrHostNotAllowed = errors.New("importing from this host is not allowed")
+	ErrTooLarge       = errors.New("the artifact exceeds the size limit")
+)
+
+// DefaultTimeout limits the duration of a fetch when the import settings
+// don't set a timeout.
+const DefaultTimeout = 30 * time.Minute
+
+// Artifact is a fetched artifact, stored in a temporary file until it is
+// removed.
+type Artifact struct {
+	Path string
+	Name string
+	Size int64
+}
+
+// Remove deletes the temporary file of the artifact.
+func (a *Artifact) Remove() error {
+	return os.Remove(a.Path)
+}
+
+// ValidateURL checks that the url is an http(s) url of a host allowed by
+// the import settings.
+func ValidateURL(app core.App, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	allowedHosts := app.Settings().Import.AllowedHosts
+	if len(allowedHosts) == 0 {
+		return nil
+	}
+	for _, host := range allowedHosts {
+		if strings.EqualFold(strings.TrimSpace(host), u.Hostname()) {
+			return nil
+		}
+	}
+	return ErrHostNotAllowed
+}
+
+// Fetch downloads the content of the url into a temporary file, writing it
+// to the hashes too. Redirects are only followed to allowed hosts. Content
+// larger than maxSize, unless it is 0, fails the fetch with ErrTooLarge.
+func Fetch(app core.App, rawURL string, maxSize int64, hashes ...hash.Hash) (*Artifact, error) {
+	if err := ValidateURL(app, rawURL); err != nil {
+		return nil, err
+	}
+	timeout := DefaultTimeout
+	if app.Settings().Import.Timeout > 0 {
+		timeout = time.Duration(app.Settings().Import.Timeout) * time.Second
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return ValidateURL(app, req.URL.String())
+		},
+	}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: unexpected status %s", rawURL, resp.Status)
+	}
+	body := io.Reader(resp.Body)
+	if maxSize > 0 {
+		if resp.ContentLength > maxSize {
+			return nil, ErrTooLarge
+		}
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+
+	f, err := os.CreateTemp("", "pureml-import-*")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	writers := []io.Writer{f}
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), body)
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+	if maxSize > 0 && size > maxSize {
+		os.Remove(f.Name())
+		return nil, ErrTooLarge
+	}
+	name := path.Base(resp.Request.URL.Path)
+	if name == "/" || name == "." {
+		name = "artifact"
+	}
+	return &Artifact{
+		Path: f.Name(),
+		Name: name,
+		Size: size,
+	}, nil
+}
@@ -0,0 +1,221 @@
+// Package migration copies the stored artifacts (version files and file
+// logs) of an organization from one storage to another.
+//
+// A migration is planned once with its items and can be resumed until
+// every item is copied. The migrated versions and logs point to the target
+// storage right after their copy is verified, while the source files are
+// only deleted once the migration is confirmed.
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	impl "github.com/PureMLHQ/PureML/packages/purebackend/core/daos/datastore"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/filesystem"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrMigrationNotFound  = errors.New("storage migration not found")
+	ErrMigrationCompleted = errors.New("storage migration is already completed")
+	ErrMigrationNotCopied = errors.New("storage migration has files left to copy")
+)
+
+// ResolveStorage returns the source secrets of the storage of the org,
+// which is either LOCAL, PUREML-STORAGE or the name of an org secret.
+func ResolveStorage(app core.App, orgId uuid.UUID, storage string) (*commonmodels.SourceSecrets, error) {
+	switch strings.ToUpper(storage) {
+	case "LOCAL":
+		return &commonmodels.SourceSecrets{SourceType: "LOCAL"}, nil
+	case "PUREML-STORAGE":
+		return app.ManagedStorageSecrets()
+	}
+	sourceSecrets, err := app.Dao().GetSecretByName(orgId, storage)
+	if err != nil || sourceSecrets == nil {
+		return nil, fmt.Errorf("source %s not connected properly to organization", storage)
+	}
+	return sourceSecrets, nil
+}
+
+// Run copies the items of the migration that are not copied yet, which
+// resumes an interrupted or failed migration. Failed items are recorded
+// on the migration and retried by the next run.
+func Run(app core.App, orgId uuid.UUID, migrationUUID uuid.UUID) (*models.StorageMigrationResponse, error) {
+	migration, err := app.Dao().GetStorageMigration(orgId, migrationUUID)
+	if err != nil {
+		return nil, err
+	}
+	if migration == nil {
+		return nil, ErrMigrationNotFound
+	}
+	if migration.Status == models.StorageMigrationCompleted {
+		return nil, ErrMigrationCompleted
+	}
+	if err := app.Dao().UpdateStorageMigrationStatus(migrationUUID, models.StorageMigrationRunning, ""); err != nil {
+		return nil, err
+	}
+	if err := run(app, orgId, migration); err != nil {
+		if err := app.Dao().UpdateStorageMigrationStatus(migrationUUID, models.StorageMigrationFailed, err.Error()); err != nil {
+			return nil, err
+		}
+	}
+	return app.Dao().GetStorageMigration(orgId, migrationUUID)
+}
+
+func run(app core.App, orgId uuid.UUID, migration *models.StorageMigrationResponse) error {
+	source, err := ResolveStorage(app, orgId, migration.SourceStorage)
+	if err != nil {
+		return err
+	}
+	target, err := ResolveStorage(app, orgId, migration.TargetStorage)
+	if err != nil {
+		return err
+	}
+	srcFS, err := app.NewFilesystem(source)
+	if err != nil {
+		return err
+	}
+	defer srcFS.Close()
+	dstFS, err := app.NewFilesystem(target)
+	if err != nil {
+		return err
+	}
+	defer dstFS.Close()
+
+	failed := 0
+	for _, item := range migration.Items {
+		if item.Status == models.StorageMigrationItemCopied || item.Status == models.StorageMigrationItemDeleted {
+			continue
+		}
+		if err := migrateItem(app, orgId, target, srcFS, dstFS, item); err != nil {
+			if err := app.Dao().FailStorageMigrationItem(item.UUID, err.Error()); err != nil {
+				return err
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to migrate", failed, len(migration.Items))
+	}
+	return app.Dao().UpdateStorageMigrationStatus(migration.UUID, models.StorageMigrationCopied, "")
+}
+
+// migrateItem copies the item file to the target, verifies the digest of
+// the copy and points the migrated version or log to it.
+//
+// Encrypted files are copied with their data key, so the digest is verified
+// on the decrypted content. A blob already stored by the target is reused
+// as is, with its own data key.
+func migrateItem(app core.App, orgId uuid.UUID, target *commonmodels.SourceSecrets, srcFS *filesystem.System, dstFS *filesystem.System, item models.StorageMigrationItemResponse) error {
+	targetKey := item.SourceKey
+	if item.Kind != "log" && item.Digest != "" {
+		targetKey = impl.BlobKey(orgId, item.Digest)
+		blob, err := app.Dao().GetBlob(orgId, target.SourceType, target.PublicURL, strings.ToLower(item.Digest))
+		if err != nil {
+			return err
+		}
+		if blob != nil {
+			return app.Dao().CompleteStorageMigrationItem(orgId, item.UUID, target.SourceType, target.PublicURL, blob.Key, blob.Size)
+		}
+	}
+	expected := strings.ToLower(item.Digest)
+	key, err := encryption.Open(app, orgId, item.DataKey)
+	if err != nil {
+		return err
+	}
+	srcFS = srcFS.Encrypted(key)
+	dstFS = dstFS.Encrypted(key)
+
+	// the target may already store the content (resumed run)
+	copied := false
+	actual, _, err := sha256Sum(dstFS, targetKey)
+	if err != nil || expected == "" || actual != expected {
+		h := sha256.New()
+		_, err = srcFS.CopyTo(dstFS, item.SourceKey, targetKey, h)
+		if err != nil {
+			return fmt.Errorf("copy %s: %w", item.SourceKey, err)
+		}
+		copied = true
+		sourceDigest := hex.EncodeToString(h.Sum(nil))
+		if expected == "" {
+			expected = sourceDigest
+		} else if sourceDigest != expected {
+			dstFS.Delete(targetKey)
+			return fmt.Errorf("digest mismatch: expected sha256 %s, source has %s", expected, sourceDigest)
+		}
+		actual, _, err = sha256Sum(dstFS, targetKey)
+		if err != nil {
+			return fmt.Errorf("verify %s: %w", targetKey, err)
+		}
+	}
+	if actual != expected {
+		if copied {
+			dstFS.Delete(targetKey)
+		}
+		return fmt.Errorf("digest mismatch: expected sha256 %s, copy has %s", expected, actual)
+	}
+	// the stored size is accounted, which includes the encryption overhead
+	attrs, err := dstFS.Attributes(targetKey)
+	if err != nil {
+		return err
+	}
+	return app.Dao().CompleteStorageMigrationItem(orgId, item.UUID, target.SourceType, target.PublicURL, targetKey, attrs.Size)
+}
+
+func sha256Sum(fs *filesystem.System, key string) (string, int64, error) {
+	h := sha256.New()
+	size, err := fs.Hash(key, h)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// Confirm completes a copied migration by deleting the source files that
+// are no longer referenced.
+func Confirm(app core.App, orgId uuid.UUID, migrationUUID uuid.UUID) (*models.StorageMigrationResponse, error) {
+	migration, err := app.Dao().GetStorageMigration(orgId, migrationUUID)
+	if err != nil {
+		return nil, err
+	}
+	if migration == nil {
+		return nil, ErrMigrationNotFound
+	}
+	if migration.Status == models.StorageMigrationCompleted {
+		return nil, ErrMigrationCompleted
+	}
+	if migration.Status != models.StorageMigrationCopied {
+		return nil, ErrMigrationNotCopied
+	}
+	source, err := ResolveStorage(app, orgId, migration.SourceStorage)
+	if err != nil {
+		return nil, err
+	}
+	srcFS, err := app.NewFilesystem(source)
+	if err != nil {
+		return nil, err
+	}
+	defer srcFS.Close()
+	for _, item := range migration.Items {
+		key, err := app.Dao().ReleaseStorageMigrationItemSource(orgId, item.UUID, source.SourceType, source.PublicURL)
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			// a leftover file is collected by the storage gc
+			srcFS.Delete(key)
+		}
+	}
+	if err := app.Dao().UpdateStorageMigrationStatus(migrationUUID, models.StorageMigrationCompleted, ""); err != nil {
+		return nil, err
+	}
+	return app.Dao().GetStorageMigration(orgId, migrationUUID)
+}
@@ -1,10 +1,17 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"reflect"
+	"regexp"
+	"sort"
+	"time"
 
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
 	datasetmodels "github.com/PureMLHQ/PureML/packages/purebackend/dataset/models"
 	modelmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/models"
 	userorgmodels "github.com/PureMLHQ/PureML/packages/purebackend/user_org/models"
@@ -31,6 +38,17 @@ type Response struct {
 	StatusCode int
 }
 
+// FileResponse describes a stored file that should be streamed
+// to the client instead of a json response.
+type FileResponse struct {
+	SourceSecrets *commonmodels.SourceSecrets
+	Key           string
+	Name          string
+	ETag          string
+	// DataKey decrypts the stored file, empty for plain files
+	DataKey string
+}
+
 func (r *Response) ToJson() map[string]interface{} {
 	return map[string]interface{}{
 		"status":  r.Body.Status,
@@ -101,4 +119,448 @@ type LogResponse struct {
 	Data           string                                         `json:"data"`
 	ModelVersion   modelmodels.ModelBranchVersionNameResponse     `json:"model_version"`
 	DatasetVersion datasetmodels.DatasetBranchVersionNameResponse `json:"dataset_version"`
+	Type           string                                         `json:"type"`
+}
+
+// Log batch entry statuses.
+const (
+	LogCreated = "created"
+	LogUpdated = "updated"
+	LogInvalid = "invalid"
+)
+
+// LogBatchEntryResponse is the result of an entry of a log batch, in the
+// order of the batch.
+type LogBatchEntryResponse struct {
+	Index  int          `json:"index"`
+	Key    string       `json:"key"`
+	Status string       `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Log    *LogResponse `json:"log,omitempty"`
+}
+
+// Stream event kinds.
+const (
+	StreamLog     = "log"
+	StreamMetric  = "metric"
+	StreamVersion = "version"
+	StreamReview  = "review"
+)
+
+// StreamEventResponse is an event of the live stream of a model or dataset.
+// Data holds the log, metric, version or review of the kind of the event.
+type StreamEventResponse struct {
+	ID          uint64          `json:"id"`
+	Kind        string          `json:"kind"`
+	VersionUUID *uuid.UUID      `json:"version_uuid,omitempty"`
+	Data        json.RawMessage `json:"data"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// StreamLogResponse is the data of a log event.
+type StreamLogResponse struct {
+	Key  string `json:"key"`
+	Data string `json:"data"`
+	Type string `json:"type"`
+}
+
+// StreamMetricResponse is the data of a metric event.
+type StreamMetricResponse struct {
+	Key       string    `json:"key"`
+	Value     float64   `json:"value"`
+	Step      *int64    `json:"step"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// StreamVersionResponse is the data of a registered version event.
+type StreamVersionResponse struct {
+	UUID      uuid.UUID `json:"uuid"`
+	Version   string    `json:"version"`
+	Branch    string    `json:"branch"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StreamReviewResponse is the data of a created or updated review event.
+type StreamReviewResponse struct {
+	UUID              uuid.UUID `json:"uuid"`
+	Title             string    `json:"title"`
+	FromBranch        uuid.UUID `json:"from_branch_uuid"`
+	FromBranchVersion uuid.UUID `json:"from_branch_version_uuid"`
+	ToBranch          uuid.UUID `json:"to_branch_uuid"`
+	IsComplete        bool      `json:"is_complete"`
+	IsAccepted        bool      `json:"is_accepted"`
+}
+
+// EventStream is a stream of events resolved by a service. Fetch returns
+// the events following an event id, the stream starts after LastEventID.
+type EventStream struct {
+	LastEventID uint64
+	Fetch       func(afterID uint64) ([]StreamEventResponse, error)
+}
+
+type UploadSessionResponse struct {
+	UUID           uuid.UUID                        `json:"uuid"`
+	FileName       string                           `json:"file_name"`
+	Storage        string                           `json:"storage"`
+	IsComplete     bool                             `json:"is_complete"`
+	Chunks         []UploadChunkResponse            `json:"chunks"`
+	ReceivedRanges []UploadChunkRangeResponse       `json:"received_ranges"`
+	ReceivedBytes  int64                            `json:"received_bytes"`
+	CreatedBy      userorgmodels.UserHandleResponse `json:"created_by"`
+	CreatedAt      time.Time                        `json:"created_at"`
+}
+
+type UploadChunkResponse struct {
+	Number int   `json:"number"`
+	Size   int64 `json:"size"`
+}
+
+type UploadChunkRangeResponse struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type PresignedURLResponse struct {
+	Method    string                 `json:"method"`
+	URL       string                 `json:"url"`
+	Proxied   bool                   `json:"proxied"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Upload    *UploadSessionResponse `json:"upload,omitempty"`
+}
+
+type BlobResponse struct {
+	UUID       uuid.UUID `json:"uuid"`
+	SourceType string    `json:"source_type"`
+	Digest     string    `json:"digest"`
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	RefCount   int       `json:"ref_count"`
+	SourceURL  string    `json:"-"`
+
+	// DataKey is the key encrypting the content, nil for plain blobs
+	DataKey *commonmodels.DataKey `json:"-"`
+}
+
+// VersionFileResponse is an entry of the manifest of a model or dataset
+// version, Path being the name of the file in the version.
+type VersionFileResponse struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+
+	// StoragePath is the recorded path of the stored file (its storage
+	// key when registering), the storage is not exposed in the manifest
+	StoragePath string                `json:"-"`
+	SourceType  string                `json:"-"`
+	BlobUUID    uuid.NullUUID         `json:"-"`
+	DataKey     *commonmodels.DataKey `json:"-"`
+}
+
+// ManifestDigest returns the sha256 digest of the manifest of a multi-file
+// version, which is the json array of its files (path, size, digest and
+// media type) sorted by path.
+func ManifestDigest(files []VersionFileResponse) string {
+	manifest := make([]VersionFileResponse, len(files))
+	copy(manifest, files)
+	sort.Slice(manifest, func(i, j int) bool {
+		return manifest[i].Path < manifest[j].Path
+	})
+	data, _ := json.Marshal(manifest)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type GCReportResponse struct {
+	OrgUUID        uuid.UUID          `json:"org_uuid"`
+	DryRun         bool               `json:"dry_run"`
+	GracePeriod    int64              `json:"grace_period"`
+	Scanned        int                `json:"scanned"`
+	Orphans        []GCOrphanResponse `json:"orphans"`
+	Deleted        int                `json:"deleted"`
+	ReclaimedBytes int64              `json:"reclaimed_bytes"`
+	Errors         []string           `json:"errors"`
+}
+
+type GCOrphanResponse struct {
+	SourceType string    `json:"source_type"`
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+}
+
+type StorageUsageResponse struct {
+	OrgUUID   uuid.UUID                  `json:"org_uuid"`
+	UsedBytes int64                      `json:"used_bytes"`
+	SoftQuota int64                      `json:"soft_quota"`
+	HardQuota int64                      `json:"hard_quota"`
+	Models    []StorageUsageItemResponse `json:"models"`
+	Datasets  []StorageUsageItemResponse `json:"datasets"`
+}
+
+type StorageUsageItemResponse struct {
+	UUID      uuid.UUID `json:"uuid"`
+	Name      string    `json:"name"`
+	UsedBytes int64     `json:"used_bytes"`
+}
+
+// Storage migration and migration item statuses.
+const (
+	StorageMigrationPending   = "pending"
+	StorageMigrationRunning   = "running"
+	StorageMigrationFailed    = "failed"
+	StorageMigrationCopied    = "copied"
+	StorageMigrationCompleted = "completed"
+
+	StorageMigrationItemPending = "pending"
+	StorageMigrationItemFailed  = "failed"
+	StorageMigrationItemCopied  = "copied"
+	StorageMigrationItemDeleted = "deleted"
+)
+
+type StorageMigrationResponse struct {
+	UUID          uuid.UUID                      `json:"uuid"`
+	OrgUUID       uuid.UUID                      `json:"org_uuid"`
+	SourceStorage string                         `json:"source_storage"`
+	TargetStorage string                         `json:"target_storage"`
+	Status        string                         `json:"status"`
+	Error         string                         `json:"error"`
+	CreatedBy     uuid.UUID                      `json:"created_by"`
+	CreatedAt     time.Time                      `json:"created_at"`
+	Items         []StorageMigrationItemResponse `json:"items"`
+}
+
+type StorageMigrationItemResponse struct {
+	UUID       uuid.UUID `json:"uuid"`
+	Kind       string    `json:"kind"`
+	ObjectUUID uuid.UUID `json:"object_uuid"`
+	SourceKey  string    `json:"source_key"`
+	TargetKey  string    `json:"target_key"`
+	Digest     string    `json:"digest"`
+	Size       int64     `json:"size"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error"`
+
+	// DataKey is the key encrypting the version file, nil for plain files
+	DataKey *commonmodels.DataKey `json:"-"`
+}
+
+type OrgEncryptionResponse struct {
+	OrgUUID       uuid.UUID `json:"org_uuid"`
+	Enabled       bool      `json:"enabled"`
+	KeyVersion    int       `json:"key_version"`
+	RewrappedKeys int       `json:"rewrapped_keys"`
+}
+
+type VersionDataKeyResponse struct {
+	Kind    string               `json:"kind"`
+	UUID    uuid.UUID            `json:"uuid"`
+	DataKey commonmodels.DataKey `json:"-"`
+}
+
+// Import job statuses.
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportFailed    = "failed"
+	ImportCompleted = "completed"
+)
+
+type ImportJobResponse struct {
+	UUID          uuid.UUID                        `json:"uuid"`
+	URL           string                           `json:"url"`
+	Hash          string                           `json:"hash"`
+	HashAlgorithm string                           `json:"hash_algorithm"`
+	Storage       string                           `json:"storage"`
+	Lineage       string                           `json:"lineage,omitempty"`
+	Status        string                           `json:"status"`
+	Error         string                           `json:"error"`
+	Size          int64                            `json:"size"`
+	Version       string                           `json:"version"`
+	CreatedBy     userorgmodels.UserHandleResponse `json:"created_by"`
+	CreatedAt     time.Time                        `json:"created_at"`
+	UpdatedAt     time.Time                        `json:"updated_at"`
+}
+
+var aliasNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+var versionNamePattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// IsValidAliasName reports whether the name can be used as a version alias.
+// The names of the versions (v1, v2...) and latest are reserved.
+func IsValidAliasName(name string) bool {
+	return aliasNamePattern.MatchString(name) && !versionNamePattern.MatchString(name) && name != "latest"
+}
+
+type VersionAliasResponse struct {
+	Name        string                           `json:"name"`
+	Branch      string                           `json:"branch"`
+	Version     string                           `json:"version"`
+	VersionUUID uuid.UUID                        `json:"version_uuid"`
+	UpdatedBy   userorgmodels.UserHandleResponse `json:"updated_by"`
+	UpdatedAt   time.Time                        `json:"updated_at"`
+}
+
+// VersionAliasEventResponse is a move of a version alias. The branch and
+// version are empty when the alias was removed.
+type VersionAliasEventResponse struct {
+	Name    string                           `json:"name"`
+	Branch  string                           `json:"branch"`
+	Version string                           `json:"version"`
+	MovedBy userorgmodels.UserHandleResponse `json:"moved_by"`
+	MovedAt time.Time                        `json:"moved_at"`
+}
+
+// Trash item kinds.
+const (
+	TrashModel          = "model"
+	TrashModelBranch    = "model_branch"
+	TrashModelVersion   = "model_version"
+	TrashDataset        = "dataset"
+	TrashDatasetBranch  = "dataset_branch"
+	TrashDatasetVersion = "dataset_version"
+)
+
+type TrashItemResponse struct {
+	UUID       uuid.UUID                        `json:"uuid"`
+	OrgUUID    uuid.UUID                        `json:"org_uuid"`
+	Kind       string                           `json:"kind"`
+	ObjectUUID uuid.UUID                        `json:"object_uuid"`
+	Name       string                           `json:"name"`
+	DeletedBy  userorgmodels.UserHandleResponse `json:"deleted_by"`
+	DeletedAt  time.Time                        `json:"deleted_at"`
+	PurgeAt    time.Time                        `json:"purge_at"`
+}
+
+// BranchProtectionResponse is the protection rules of a model or dataset
+// branch. Anyone with access to the branch can review it when Reviewers is
+// empty.
+type BranchProtectionResponse struct {
+	UUID                    uuid.UUID                          `json:"uuid"`
+	BlockDirectRegistration bool                               `json:"block_direct_registration"`
+	RequiredApprovals       int                                `json:"required_approvals"`
+	BlockDeletion           bool                               `json:"block_deletion"`
+	Reviewers               []userorgmodels.UserHandleResponse `json:"reviewers"`
+	UpdatedBy               userorgmodels.UserHandleResponse   `json:"updated_by"`
+	UpdatedAt               time.Time                          `json:"updated_at"`
+}
+
+// BranchProtectionRules is the protection rules to set on a branch.
+type BranchProtectionRules struct {
+	BlockDirectRegistration bool        `json:"block_direct_registration"`
+	RequiredApprovals       int         `json:"required_approvals"`
+	BlockDeletion           bool        `json:"block_deletion"`
+	Reviewers               []uuid.UUID `json:"reviewers"`
+}
+
+type ReviewApprovalResponse struct {
+	UUID       uuid.UUID                        `json:"uuid"`
+	ReviewUUID uuid.UUID                        `json:"review_uuid"`
+	ApprovedBy userorgmodels.UserHandleResponse `json:"approved_by"`
+	ApprovedAt time.Time                        `json:"approved_at"`
+}
+
+// ReferencesResponse lists what keeps a model, dataset, branch or version
+// from being deleted.
+type ReferencesResponse struct {
+	Aliases     []string `json:"aliases"`
+	OpenReviews int64    `json:"open_reviews"`
+}
+
+// StoredObjectResponse is an object of a storage, left to be removed once
+// nothing references it anymore.
+type StoredObjectResponse struct {
+	SourceType string `json:"source_type"`
+	SourceURL  string `json:"source_url"`
+	Key        string `json:"key"`
+}
+
+// File diff statuses.
+const (
+	FileAdded     = "added"
+	FileRemoved   = "removed"
+	FileModified  = "modified"
+	FileUnchanged = "unchanged"
+)
+
+// CompareResponse compares two branches, or two versions, of a model or
+// dataset. The versions of the branches are matched by their hash, and the
+// fork point is the version of the base the head branch was created from
+// or, failing that, the latest version both branches have.
+type CompareResponse struct {
+	Base       CompareRefResponse       `json:"base"`
+	Head       CompareRefResponse       `json:"head"`
+	ForkPoint  *CompareVersionResponse  `json:"fork_point"`
+	AheadBy    int                      `json:"ahead_by"`
+	BehindBy   int                      `json:"behind_by"`
+	BaseMoved  bool                     `json:"base_moved"`
+	OnlyInBase []CompareVersionResponse `json:"only_in_base"`
+	OnlyInHead []CompareVersionResponse `json:"only_in_head"`
+	Logs       []LogDiffResponse        `json:"logs"`
+	Readme     ReadmeDiffResponse       `json:"readme"`
+	Files      []FileDiffResponse       `json:"files"`
+}
+
+// CompareRefResponse is a side of a comparison. Version is the compared
+// version, nil if the branch has no versions.
+type CompareRefResponse struct {
+	BranchUUID uuid.UUID               `json:"branch_uuid"`
+	Branch     string                  `json:"branch"`
+	Version    *CompareVersionResponse `json:"version"`
+}
+
+type CompareVersionResponse struct {
+	UUID      uuid.UUID `json:"uuid"`
+	Version   string    `json:"version"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LogDiffResponse is the latest value of a log key on each side, nil if
+// the key was not logged.
+type LogDiffResponse struct {
+	Key     string  `json:"key"`
+	Base    *string `json:"base"`
+	Head    *string `json:"head"`
+	Changed bool    `json:"changed"`
+}
+
+// ReadmeDiffResponse is the readme as it was when each compared version was
+// registered.
+type ReadmeDiffResponse struct {
+	BaseVersion string `json:"base_version"`
+	Base        string `json:"base"`
+	HeadVersion string `json:"head_version"`
+	Head        string `json:"head"`
+	Changed     bool   `json:"changed"`
+}
+
+// FileDiffResponse is a file of the artifact manifests of the compared
+// versions.
+type FileDiffResponse struct {
+	Path       string `json:"path"`
+	Status     string `json:"status"`
+	BaseDigest string `json:"base_digest"`
+	HeadDigest string `json:"head_digest"`
+	BaseSize   int64  `json:"base_size"`
+	HeadSize   int64  `json:"head_size"`
+}
+
+type MetricPointResponse struct {
+	Value     float64   `json:"value"`
+	Step      *int64    `json:"step"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// MetricSeriesResponse is the series of values of a metric key of a version,
+// ordered by step then timestamp, with its aggregates. Last is the value of
+// the last point of the series.
+type MetricSeriesResponse struct {
+	Key    string                `json:"key"`
+	Points []MetricPointResponse `json:"points,omitempty"`
+	Count  int                   `json:"count"`
+	Last   float64               `json:"last"`
+	Min    float64               `json:"min"`
+	Max    float64               `json:"max"`
+	Mean   float64               `json:"mean"`
 }
@@ -9,6 +9,14 @@ type Settings struct {
 	S3     S3Config     `form:"s3" json:"s3"`
 	R2     R2Config     `form:"r2" json:"r2"`
 	Search SearchConfig `form:"search" json:"search"`
+	GC     GCConfig     `form:"gc" json:"gc"`
+
+	Encryption EncryptionConfig `form:"encryption" json:"encryption"`
+	Import     ImportConfig     `form:"import" json:"import"`
+	Trash      TrashConfig      `form:"trash" json:"trash"`
+	Logs       LogsConfig       `form:"logs" json:"logs"`
+	Stream     StreamConfig     `form:"stream" json:"stream"`
+	Upload     UploadConfig     `form:"upload" json:"upload"`
 
 	AdminAuthToken              TokenConfig       `form:"adminAuthToken" json:"adminAuthToken"`
 	MailVerifificationAuthToken TokenConfig       `form:"mailVerifificationAuthToken" json:"mailVerifificationAuthToken"`
@@ -67,6 +75,62 @@ type SearchConfig struct {
 	AdminAPIKey string `form:"admin_api_key" json:"admin_api_key"`
 }
 
+// GCConfig configures the background storage garbage collector.
+// Interval and GracePeriod are in seconds.
+type GCConfig struct {
+	Enabled     bool  `form:"enabled" json:"enabled"`
+	Interval    int64 `form:"interval" json:"interval"`
+	GracePeriod int64 `form:"gracePeriod" json:"gracePeriod"`
+}
+
+// EncryptionConfig configures the encryption at rest of the stored
+// artifacts. The organization keys are derived from MasterKey, so changing
+// it makes the already encrypted artifacts unreadable.
+type EncryptionConfig struct {
+	MasterKey string `form:"masterKey" json:"masterKey"`
+}
+
+// ImportConfig configures the server side imports of artifacts from urls.
+// AllowedHosts restricts the hosts the artifacts are fetched from (any host
+// if empty) and Timeout (in seconds) limits the duration of a fetch, 30
+// minutes if not set.
+type ImportConfig struct {
+	AllowedHosts []string `form:"allowedHosts" json:"allowedHosts"`
+	Timeout      int64    `form:"timeout" json:"timeout"`
+}
+
+// TrashConfig configures the trash of the deleted models, datasets, branches
+// and versions. Retention is how long they can be restored before they are
+// purged, Interval the period of the purge. Both are in seconds.
+type TrashConfig struct {
+	Retention int64 `form:"retention" json:"retention"`
+	Interval  int64 `form:"interval" json:"interval"`
+}
+
+// LogsConfig configures the logging of model and dataset versions.
+// MaxBatchSize limits the size in bytes of the body of a log batch.
+type LogsConfig struct {
+	MaxBatchSize int64 `form:"maxBatchSize" json:"maxBatchSize"`
+}
+
+// StreamConfig configures the live event streams of the models and datasets.
+// Retention is how long the events can be replayed and Interval the period
+// of their purge, both in seconds. PollInterval is how often, in
+// milliseconds, an open stream looks for new events.
+type StreamConfig struct {
+	Retention    int64 `form:"retention" json:"retention"`
+	Interval     int64 `form:"interval" json:"interval"`
+	PollInterval int64 `form:"pollInterval" json:"pollInterval"`
+}
+
+// UploadConfig configures the resumable uploads of model and dataset files.
+// MaxChunkSize limits the size in bytes of an uploaded chunk and MaxFileSize
+// the size of a file uploaded through the proxied presigned upload.
+type UploadConfig struct {
+	MaxChunkSize int64 `form:"maxChunkSize" json:"maxChunkSize"`
+	MaxFileSize  int64 `form:"maxFileSize" json:"maxFileSize"`
+}
+
 type MailServiceConfig struct {
 	Enabled  bool   `form:"enabled" json:"enabled"`
 	Host     string `form:"host" json:"host"`
@@ -0,0 +1,192 @@
+// Package stream serves the live event streams of the models and datasets
+// as server-sent events and purges the events once their retention period
+// is over.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+)
+
+const (
+	// DefaultRetention is how long the events can be replayed.
+	DefaultRetention = 24 * time.Hour
+
+	// DefaultInterval is the default period of the background purge.
+	DefaultInterval = time.Hour
+
+	// DefaultPollInterval is how often an open stream looks for new events.
+	DefaultPollInterval = time.Second
+
+	// HeartbeatInterval is the period of the comments sent on idle streams
+	// so that proxies don't close them.
+	HeartbeatInterval = 15 * time.Second
+
+	// RetryDelay is how long clients wait before reconnecting.
+	RetryDelay = 3 * time.Second
+
+	// CommitLag is how long after its creation an event may still become
+	// visible. Ids are assigned when the events are written, a transaction
+	// committing late can so add an event behind those already sent.
+	CommitLag = 10 * time.Second
+)
+
+// Retention returns the configured retention of the events.
+func Retention(app core.App) time.Duration {
+	if retention := app.Settings().Stream.Retention; retention > 0 {
+		return time.Duration(retention) * time.Second
+	}
+	return DefaultRetention
+}
+
+// PollInterval returns the configured poll interval of the open streams.
+func PollInterval(app core.App) time.Duration {
+	if interval := app.Settings().Stream.PollInterval; interval > 0 {
+		return time.Duration(interval) * time.Millisecond
+	}
+	return DefaultPollInterval
+}
+
+// Serve writes the events of the stream to w as server-sent events until ctx
+// is done. Events are sent as they are written, starting after the last
+// event id of the stream. The events created within CommitLag are fetched
+// again until they settle, so that those committed late are sent too.
+func Serve(ctx context.Context, app core.App, w http.ResponseWriter, stream *models.EventStream) error {
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// disable the response buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flush := func() {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", RetryDelay.Milliseconds()); err != nil {
+		return err
+	}
+	flush()
+
+	// the events up to the last event id are already known to the client,
+	// fetching starts from the last settled one to catch those committed late
+	settledID, err := app.Dao().GetLastStreamEventIDBefore(time.Now().Add(-CommitLag))
+	if err != nil {
+		return err
+	}
+	afterID := stream.LastEventID
+	if settledID < afterID {
+		afterID = settledID
+	}
+	sent := map[uint64]bool{}
+	events, err := stream.Fetch(afterID)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if event.ID <= stream.LastEventID {
+			sent[event.ID] = true
+		}
+	}
+
+	poll := time.NewTicker(PollInterval(app))
+	defer poll.Stop()
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		events, err := stream.Fetch(afterID)
+		if err != nil {
+			return err
+		}
+		written := 0
+		settled := true
+		for _, event := range events {
+			if !sent[event.ID] {
+				if err := Write(w, event); err != nil {
+					return err
+				}
+				sent[event.ID] = true
+				written++
+			}
+			// the fetch moves past the leading events once they settled
+			settled = settled && time.Since(event.CreatedAt) > CommitLag
+			if settled {
+				afterID = event.ID
+				delete(sent, event.ID)
+			}
+		}
+		if written > 0 {
+			flush()
+			// more events may be waiting
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-poll.C:
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+			flush()
+		}
+	}
+}
+
+// Write writes the event as a server-sent event named after its kind.
+func Write(w io.Writer, event models.StreamEventResponse) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Kind, data)
+	return err
+}
+
+// PurgeExpired deletes the events older than the retention and returns how
+// many were deleted.
+func PurgeExpired(app core.App) (int64, error) {
+	return app.Dao().PurgeStreamEvents(time.Now().Add(-Retention(app)))
+}
+
+// Start runs the purge of the expired events in the background every
+// interval, DefaultInterval if not positive. The returned function stops it.
+func Start(app core.App, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				purged, err := PurgeExpired(app)
+				if err != nil {
+					log.Println("stream:", err)
+				}
+				if purged > 0 {
+					log.Printf("stream: purged %d events\n", purged)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
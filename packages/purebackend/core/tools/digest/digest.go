@@ -0,0 +1,109 @@
+package digest
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strings"
+)
+
+// DefaultAlgorithm is the digest algorithm used when none is requested.
+//
+// Stored artifacts always get a sha256 digest computed, regardless
+// of the algorithm requested by the client.
+const DefaultAlgorithm = "sha256"
+
+// SupportedAlgorithms lists the digest algorithms that can be verified.
+var SupportedAlgorithms = []string{"sha256", "sha512", "sha1", "md5"}
+
+// New creates a new hash.Hash for the provided algorithm name.
+func New(algorithm string) (hash.Hash, error) {
+	switch NormalizeAlgorithm(algorithm) {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	default:
+		return nil, errors.New("unsupported digest algorithm " + algorithm)
+	}
+}
+
+// NormalizeAlgorithm lowercases the algorithm name, strips any dashes
+// (eg. "SHA-256" -> "sha256") and falls back to DefaultAlgorithm if empty.
+func NormalizeAlgorithm(algorithm string) string {
+	algorithm = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(algorithm), "-", ""))
+	if algorithm == "" {
+		return DefaultAlgorithm
+	}
+	return algorithm
+}
+
+// Equal reports whether the two hex encoded digests are the same
+// (case insensitive).
+func Equal(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// -------------------------------------------------------------------
+
+// Digester computes the digest of the requested algorithm together with
+// the sha256 digest in a single pass over the written data.
+type Digester struct {
+	Algorithm string
+
+	hash   hash.Hash
+	sha256 hash.Hash
+}
+
+// NewDigester creates a new Digester for the provided algorithm.
+func NewDigester(algorithm string) (*Digester, error) {
+	algorithm = NormalizeAlgorithm(algorithm)
+	h, err := New(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	d := &Digester{
+		Algorithm: algorithm,
+		hash:      h,
+	}
+	if algorithm == "sha256" {
+		d.sha256 = h
+	} else {
+		d.sha256 = sha256.New()
+	}
+	return d, nil
+}
+
+// Hashes returns the distinct hashes that should receive the data.
+func (d *Digester) Hashes() []hash.Hash {
+	if d.hash == d.sha256 {
+		return []hash.Hash{d.hash}
+	}
+	return []hash.Hash{d.hash, d.sha256}
+}
+
+// Write implements the [io.Writer] interface.
+func (d *Digester) Write(p []byte) (int, error) {
+	for _, h := range d.Hashes() {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// Sum returns the hex encoded digest of the requested algorithm.
+func (d *Digester) Sum() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// Sha256 returns the hex encoded sha256 digest.
+func (d *Digester) Sha256() string {
+	return hex.EncodeToString(d.sha256.Sum(nil))
+}
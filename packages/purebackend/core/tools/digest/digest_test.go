@@ -0,0 +1,91 @@
+package digest_test
+
+import (
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
+)
+
+func TestNormalizeAlgorithm(t *testing.T) {
+	scenarios := []struct {
+		algorithm string
+		expected  string
+	}{
+		{"", "sha256"},
+		{"  ", "sha256"},
+		{"SHA-256", "sha256"},
+		{"Sha512", "sha512"},
+		{"md5", "md5"},
+		{"crc32", "crc32"},
+	}
+
+	for i, scenario := range scenarios {
+		result := digest.NormalizeAlgorithm(scenario.algorithm)
+		if result != scenario.expected {
+			t.Errorf("(%d) Expected %q, got %q", i, scenario.expected, result)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, algorithm := range digest.SupportedAlgorithms {
+		if _, err := digest.New(algorithm); err != nil {
+			t.Errorf("Expected %q to be supported, got error %v", algorithm, err)
+		}
+	}
+
+	if _, err := digest.New("crc32"); err == nil {
+		t.Error("Expected error for unsupported algorithm, got nil")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !digest.Equal("ABCDEF", "abcdef ") {
+		t.Error("Expected digests to be equal")
+	}
+	if digest.Equal("abcdef", "abcde0") {
+		t.Error("Expected digests to be different")
+	}
+}
+
+func TestDigester(t *testing.T) {
+	scenarios := []struct {
+		algorithm      string
+		expectError    bool
+		expectedHashes int
+		expectedSum    string
+	}{
+		{"", false, 1, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+		{"sha256", false, 1, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+		{"md5", false, 2, "acbd18db4cc2f85cedef654fccc4a4d8"},
+		{"sha1", false, 2, "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"},
+		{"crc32", true, 0, ""},
+	}
+
+	for i, scenario := range scenarios {
+		d, err := digest.NewDigester(scenario.algorithm)
+		if scenario.expectError {
+			if err == nil {
+				t.Errorf("(%d) Expected error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("(%d) Expected nil, got error %v", i, err)
+			continue
+		}
+
+		if len(d.Hashes()) != scenario.expectedHashes {
+			t.Errorf("(%d) Expected %d hashes, got %d", i, scenario.expectedHashes, len(d.Hashes()))
+		}
+
+		d.Write([]byte("foo"))
+
+		if d.Sum() != scenario.expectedSum {
+			t.Errorf("(%d) Expected sum %q, got %q", i, scenario.expectedSum, d.Sum())
+		}
+		if d.Sha256() != "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae" {
+			t.Errorf("(%d) Expected sha256 of foo, got %q", i, d.Sha256())
+		}
+	}
+}
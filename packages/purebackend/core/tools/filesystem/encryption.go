@@ -0,0 +1,217 @@
+package filesystem
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Encrypted files start with encryptionMagic followed by the content split
+// in chunks of encryptionChunkSize bytes, each sealed with AES-GCM under
+// its own random nonce. The chunk index and a final chunk flag are
+// authenticated with every chunk, so reordered or truncated files fail to
+// decrypt, while any plaintext offset can be read without decrypting the
+// preceding chunks.
+const (
+	encryptionMagic     = "PMLENC01"
+	encryptionChunkSize = 64 * 1024
+	encryptionNonceSize = 12
+	encryptionOverhead  = encryptionNonceSize + 16
+)
+
+var ErrInvalidEncryptedFile = errors.New("invalid encrypted file")
+
+func newEncryptionCipher(key string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, encryptionNonceSize)
+}
+
+func chunkAdditionalData(index int64, final bool) []byte {
+	ad := make([]byte, 9)
+	binary.BigEndian.PutUint64(ad, uint64(index))
+	if final {
+		ad[8] = 1
+	}
+	return ad
+}
+
+// decryptedSize returns the plaintext size and the number of chunks of an
+// encrypted file of size bytes.
+func decryptedSize(size int64) (int64, int64, error) {
+	body := size - int64(len(encryptionMagic))
+	if body < encryptionOverhead {
+		return 0, 0, ErrInvalidEncryptedFile
+	}
+	chunks := (body + encryptionChunkSize + encryptionOverhead - 1) / (encryptionChunkSize + encryptionOverhead)
+	last := body - (chunks-1)*(encryptionChunkSize+encryptionOverhead)
+	if last < encryptionOverhead {
+		return 0, 0, ErrInvalidEncryptedFile
+	}
+	return body - chunks*encryptionOverhead, chunks, nil
+}
+
+// encryptWriter encrypts the written content into w.
+//
+// Close must be called to seal the final chunk, it doesn't close w.
+type encryptWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	index int64
+}
+
+func newEncryptWriter(w io.Writer, key string) (*encryptWriter, error) {
+	aead, err := newEncryptionCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encryptionMagic); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, encryptionChunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// a full chunk is only sealed once more content follows it,
+		// since the last one has to be flagged as final
+		if len(e.buf) == encryptionChunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):encryptionChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(final bool) error {
+	nonce := make([]byte, encryptionNonceSize, encryptionNonceSize+len(e.buf)+e.aead.Overhead())
+	if _, err := io.ReadFull(crand.Reader, nonce); err != nil {
+		return err
+	}
+	sealed := e.aead.Seal(nonce, nonce, e.buf, chunkAdditionalData(e.index, final))
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.index++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// decryptReader decrypts the encrypted content of r.
+type decryptReader struct {
+	r      io.ReadSeeker
+	aead   cipher.AEAD
+	size   int64
+	chunks int64
+	offset int64
+
+	chunk      []byte
+	chunkIndex int64
+}
+
+// newDecryptReader creates a reader of the plaintext of the encrypted
+// file r with the size (encrypted) bytes.
+func newDecryptReader(r io.ReadSeeker, size int64, key string) (*decryptReader, error) {
+	aead, err := newEncryptionCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plainSize, chunks, err := decryptedSize(size)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, len(encryptionMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != encryptionMagic {
+		return nil, ErrInvalidEncryptedFile
+	}
+	return &decryptReader{
+		r:          r,
+		aead:       aead,
+		size:       plainSize,
+		chunks:     chunks,
+		chunkIndex: -1,
+	}, nil
+}
+
+// Size returns the plaintext size.
+func (d *decryptReader) Size() int64 {
+	return d.size
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	if d.offset >= d.size {
+		return 0, io.EOF
+	}
+	index := d.offset / encryptionChunkSize
+	if index != d.chunkIndex {
+		if err := d.load(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.chunk[d.offset%encryptionChunkSize:])
+	d.offset += int64(n)
+	return n, nil
+}
+
+func (d *decryptReader) load(index int64) error {
+	start := int64(len(encryptionMagic)) + index*(encryptionChunkSize+encryptionOverhead)
+	if _, err := d.r.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	final := index == d.chunks-1
+	plainSize := int64(encryptionChunkSize)
+	if final {
+		plainSize = d.size - index*encryptionChunkSize
+	}
+	sealed := make([]byte, plainSize+encryptionOverhead)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return err
+	}
+	chunk, err := d.aead.Open(sealed[encryptionNonceSize:encryptionNonceSize], sealed[:encryptionNonceSize], sealed[encryptionNonceSize:], chunkAdditionalData(index, final))
+	if err != nil {
+		return ErrInvalidEncryptedFile
+	}
+	d.chunk = chunk
+	d.chunkIndex = index
+	return nil
+}
+
+func (d *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.offset
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	d.offset = offset
+	return offset, nil
+}
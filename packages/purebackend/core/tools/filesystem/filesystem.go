@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash"
 	"image"
 	"io"
 	"mime/multipart"
@@ -29,8 +30,9 @@ import (
 )
 
 type System struct {
-	ctx    context.Context
-	bucket *blob.Bucket
+	ctx     context.Context
+	bucket  *blob.Bucket
+	dataKey string
 }
 
 // NewS3 initializes an S3 filesystem instance.
@@ -129,6 +131,49 @@ func (s *System) Close() error {
 	return s.bucket.Close()
 }
 
+// Encrypted returns a view of the filesystem that encrypts the written
+// content with dataKey (must be valid 32 char aes key) and decrypts the
+// read content with it.
+//
+// Upload, UploadFile, Compose and the destination of CopyTo encrypt,
+// while Hash, Serve and the source of CopyTo decrypt. The other methods
+// (eg. Copy, Attributes or SignedURL) work with the stored encrypted bytes.
+// An empty dataKey returns the plain filesystem.
+func (s *System) Encrypted(dataKey string) *System {
+	return &System{ctx: s.ctx, bucket: s.bucket, dataKey: dataKey}
+}
+
+// newReader opens the file at fileKey location for reading its content,
+// decrypting it for encrypted filesystems.
+func (s *System) newReader(fileKey string) (io.ReadSeeker, int64, func() error, error) {
+	br, err := s.bucket.NewReader(s.ctx, fileKey, nil)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if s.dataKey == "" {
+		return br, br.Size(), br.Close, nil
+	}
+	dr, err := newDecryptReader(br, br.Size(), s.dataKey)
+	if err != nil {
+		br.Close()
+		return nil, 0, nil, err
+	}
+	return dr, dr.Size(), br.Close, nil
+}
+
+// newContentWriter wraps w with the encryption of encrypted filesystems.
+// The returned close func must be called before closing w.
+func (s *System) newContentWriter(w io.Writer) (io.Writer, func() error, error) {
+	if s.dataKey == "" {
+		return w, func() error { return nil }, nil
+	}
+	ew, err := newEncryptWriter(w, s.dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ew, ew.Close, nil
+}
+
 // Exists checks if file with fileKey path exists or not.
 func (s *System) Exists(fileKey string) (bool, error) {
 	return s.bucket.Exists(s.ctx, fileKey)
@@ -139,6 +184,36 @@ func (s *System) Attributes(fileKey string) (*blob.Attributes, error) {
 	return s.bucket.Attributes(s.ctx, fileKey)
 }
 
+// SignedURL returns a short-lived URL that allows direct access to the
+// file at fileKey location with the provided HTTP method ("GET" or "PUT"),
+// without going through the server.
+//
+// Not all storages support signed urls (eg. the local filesystem),
+// in which case an error is returned.
+func (s *System) SignedURL(fileKey string, method string, expiry time.Duration) (string, error) {
+	return s.bucket.SignedURL(s.ctx, fileKey, &blob.SignedURLOptions{
+		Method: method,
+		Expiry: expiry,
+	})
+}
+
+// Hash streams the file at fileKey location into the provided hashes
+// and returns the number of read bytes.
+func (s *System) Hash(fileKey string, hashes ...hash.Hash) (int64, error) {
+	r, _, closeReader, err := s.newReader(fileKey)
+	if err != nil {
+		return 0, err
+	}
+	defer closeReader()
+
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		writers[i] = h
+	}
+
+	return io.Copy(io.MultiWriter(writers...), r)
+}
+
 // Upload writes content into the fileKey location.
 func (s *System) Upload(content []byte, fileKey string) error {
 	opts := &blob.WriterOptions{
@@ -150,7 +225,18 @@ func (s *System) Upload(content []byte, fileKey string) error {
 		return writerErr
 	}
 
-	if _, err := w.Write(content); err != nil {
+	cw, closeContent, err := s.newContentWriter(w)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	if _, err := cw.Write(content); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := closeContent(); err != nil {
 		w.Close()
 		return err
 	}
@@ -158,8 +244,52 @@ func (s *System) Upload(content []byte, fileKey string) error {
 	return w.Close()
 }
 
+// UploadReader streams the content of r into the fileKey location and
+// returns its size. If reading r fails the write is aborted, so that an
+// existing file at fileKey is left untouched.
+func (s *System) UploadReader(r io.Reader, fileKey string) (int64, error) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	opts := &blob.WriterOptions{
+		ContentType: "application/octet-stream",
+	}
+
+	w, err := s.bucket.NewWriter(ctx, fileKey, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	cw, closeContent, err := s.newContentWriter(w)
+	if err != nil {
+		cancel()
+		w.Close()
+		return 0, err
+	}
+
+	size, err := io.Copy(cw, r)
+	if err != nil {
+		cancel()
+		w.Close()
+		return 0, err
+	}
+
+	if err := closeContent(); err != nil {
+		cancel()
+		w.Close()
+		return 0, err
+	}
+
+	return size, w.Close()
+}
+
 // UploadFile uploads the provided multipart file to the fileKey location.
-func (s *System) UploadFile(file *File, fileKey string) error {
+//
+// The optional hashes are fed with the uploaded content, allowing the
+// caller to compute the file digests while streaming it to the storage.
+// For encrypted filesystems the content is encrypted on the fly and the
+// hashes are fed with the plaintext.
+func (s *System) UploadFile(file *File, fileKey string, hashes ...hash.Hash) error {
 	f, err := file.Reader.Open()
 	if err != nil {
 		return err
@@ -195,7 +325,27 @@ func (s *System) UploadFile(file *File, fileKey string) error {
 		return err
 	}
 
-	if _, err := w.ReadFrom(f); err != nil {
+	cw, closeContent, err := s.newContentWriter(w)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	var dst io.Writer = cw
+	if len(hashes) > 0 {
+		writers := []io.Writer{cw}
+		for _, h := range hashes {
+			writers = append(writers, h)
+		}
+		dst = io.MultiWriter(writers...)
+	}
+
+	if _, err := io.Copy(dst, f); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := closeContent(); err != nil {
 		w.Close()
 		return err
 	}
@@ -248,11 +398,160 @@ func (s *System) UploadMultipart(fh *multipart.FileHeader, fileKey string) error
 	return w.Close()
 }
 
+// Compose concatenates the files stored at srcKeys (in the provided order)
+// into a single file at the dstKey location.
+//
+// The source files are streamed one after another, so none of them
+// is required to fit in memory. The optional hashes are fed with the
+// composed content. The source files are read as stored, only the
+// composed file is encrypted for encrypted filesystems.
+func (s *System) Compose(srcKeys []string, dstKey string, originalName string, hashes ...hash.Hash) error {
+	if len(srcKeys) == 0 {
+		return errors.New("Compose requires at least one source file.")
+	}
+
+	if len(originalName) > 255 {
+		// keep only the first 255 chars as a very rudimentary measure
+		// to prevent the metadata to grow too big in size
+		originalName = originalName[:255]
+	}
+	opts := &blob.WriterOptions{
+		Metadata: map[string]string{
+			"original-filename": originalName,
+		},
+	}
+
+	// cancelling the writer context aborts the write
+	// so that no partial file is left behind on failure
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	w, err := s.bucket.NewWriter(ctx, dstKey, opts)
+	if err != nil {
+		return err
+	}
+
+	cw, closeContent, err := s.newContentWriter(w)
+	if err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+
+	var dst io.Writer = cw
+	if len(hashes) > 0 {
+		writers := []io.Writer{cw}
+		for _, h := range hashes {
+			writers = append(writers, h)
+		}
+		dst = io.MultiWriter(writers...)
+	}
+
+	for _, srcKey := range srcKeys {
+		r, err := s.bucket.NewReader(s.ctx, srcKey, nil)
+		if err != nil {
+			cancel()
+			w.Close()
+			return err
+		}
+
+		_, err = io.Copy(dst, r)
+		r.Close()
+		if err != nil {
+			cancel()
+			w.Close()
+			return err
+		}
+	}
+
+	if err := closeContent(); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// Copy copies the file stored at srcKey to dstKey.
+func (s *System) Copy(srcKey string, dstKey string) error {
+	return s.bucket.Copy(s.ctx, dstKey, srcKey, nil)
+}
+
+// CopyTo streams the file stored at srcKey to the dstKey location of
+// another filesystem and returns the number of copied bytes.
+//
+// The content is decrypted with the key of s and encrypted with the key
+// of dst, so copying between plain filesystems keeps the stored bytes.
+// The optional hashes are fed with the copied (plaintext) content.
+func (s *System) CopyTo(dst *System, srcKey string, dstKey string, hashes ...hash.Hash) (int64, error) {
+	attrs, err := s.bucket.Attributes(s.ctx, srcKey)
+	if err != nil {
+		return 0, err
+	}
+
+	r, _, closeReader, err := s.newReader(srcKey)
+	if err != nil {
+		return 0, err
+	}
+	defer closeReader()
+
+	w, err := dst.bucket.NewWriter(dst.ctx, dstKey, &blob.WriterOptions{
+		ContentType: attrs.ContentType,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	cw, closeContent, err := dst.newContentWriter(w)
+	if err != nil {
+		w.Close()
+		return 0, err
+	}
+
+	writers := []io.Writer{cw}
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+
+	n, err := io.Copy(io.MultiWriter(writers...), r)
+	if err != nil {
+		w.Close()
+		return n, err
+	}
+
+	if err := closeContent(); err != nil {
+		w.Close()
+		return n, err
+	}
+
+	return n, w.Close()
+}
+
 // Delete deletes stored file at fileKey location.
 func (s *System) Delete(fileKey string) error {
 	return s.bucket.Delete(s.ctx, fileKey)
 }
 
+// List returns the attributes of all files starting with the specified prefix.
+func (s *System) List(prefix string) ([]*blob.ListObject, error) {
+	objects := []*blob.ListObject{}
+	iter := s.bucket.List(&blob.ListOptions{
+		Prefix: prefix,
+	})
+	for {
+		obj, err := iter.Next(s.ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
 // DeletePrefix deletes everything starting with the specified prefix.
 func (s *System) DeletePrefix(prefix string) []error {
 	failed := []error{}
@@ -341,6 +640,17 @@ func (s *System) Serve(res http.ResponseWriter, req *http.Request, fileKey strin
 	}
 	defer br.Close()
 
+	var content io.ReadSeeker = br
+	size := br.Size()
+	if s.dataKey != "" {
+		dr, err := newDecryptReader(br, br.Size(), s.dataKey)
+		if err != nil {
+			return err
+		}
+		content = dr
+		size = dr.Size()
+	}
+
 	disposition := "attachment"
 	realContentType := br.ContentType()
 	if list.ExistInSlice(realContentType, inlineServeContentTypes) {
@@ -361,7 +671,7 @@ func (s *System) Serve(res http.ResponseWriter, req *http.Request, fileKey strin
 
 	res.Header().Set("Content-Disposition", disposition+"; filename="+name)
 	res.Header().Set("Content-Type", extContentType)
-	res.Header().Set("Content-Length", strconv.FormatInt(br.Size(), 10))
+	res.Header().Set("Content-Length", strconv.FormatInt(size, 10))
 	res.Header().Set("Content-Security-Policy", "default-src 'none'; media-src 'self'; style-src 'unsafe-inline'; sandbox")
 
 	// all HTTP date/time stamps MUST be represented in Greenwich Mean Time (GMT)
@@ -380,7 +690,7 @@ func (s *System) Serve(res http.ResponseWriter, req *http.Request, fileKey strin
 		res.Header().Set("Cache-Control", "max-age=2592000, stale-while-revalidate=86400")
 	}
 
-	http.ServeContent(res, req, name, br.ModTime(), br)
+	http.ServeContent(res, req, name, br.ModTime(), content)
 
 	return nil
 }
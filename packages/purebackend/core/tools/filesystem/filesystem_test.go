@@ -2,8 +2,12 @@ package filesystem_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"image"
 	"image/png"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +15,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/filesystem"
 )
@@ -82,6 +88,199 @@ func TestFileSystemAttributes(t *testing.T) {
 	}
 }
 
+func TestFileSystemSignedURL(t *testing.T) {
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+
+	fs, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	// the local filesystem doesn't support signed urls
+	if _, err := fs.SignedURL("test/sub1.txt", http.MethodGet, time.Minute); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+func TestFileSystemHash(t *testing.T) {
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+
+	fs, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	if _, err := fs.Hash("missing.txt", sha256.New()); err == nil {
+		t.Fatal("Expected error for missing file, got nil")
+	}
+
+	if err := fs.Upload([]byte("aaabbbccc"), "test/hash.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	h := sha256.New()
+	size, err := fs.Hash("test/hash.txt", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 9 {
+		t.Fatalf("Expected size 9, got %d", size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != "fb84a45f6df7d1d17036f939f1cfeb87339ff5dbdf411222f3762dd76779a287" {
+		t.Fatalf("Expected file sha256 digest, got %q", sum)
+	}
+}
+
+func TestFileSystemCopy(t *testing.T) {
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+
+	fs, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	if err := fs.Copy("missing.txt", "copy.txt"); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if err := fs.Copy("test/sub1.txt", "copies/sub1.txt"); err != nil {
+		t.Fatalf("Expected nil, got error %v", err)
+	}
+
+	for _, key := range []string{"test/sub1.txt", "copies/sub1.txt"} {
+		if exists, _ := fs.Exists(key); !exists {
+			t.Fatalf("Expected %s to exist", key)
+		}
+	}
+}
+
+func TestFileSystemCopyTo(t *testing.T) {
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+
+	dstDir := createTestDir(t)
+	defer os.RemoveAll(dstDir)
+
+	fs, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	dst, err := filesystem.NewLocal(filepath.Join(dstDir, "copies"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if _, err := fs.CopyTo(dst, "missing.txt", "copy.txt"); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	h := sha256.New()
+	n, err := fs.CopyTo(dst, "test/sub1.txt", "moved/sub1.txt", h)
+	if err != nil {
+		t.Fatalf("Expected nil, got error %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dstDir, "copies", "moved", "sub1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) {
+		t.Fatalf("Expected %d copied bytes, got %d", len(content), n)
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(h.Sum(nil)) != hex.EncodeToString(sum[:]) {
+		t.Fatal("Expected the hash to be fed with the copied content")
+	}
+	if exists, _ := fs.Exists("test/sub1.txt"); !exists {
+		t.Fatal("Expected the source file to be kept")
+	}
+}
+
+func TestFileSystemEncrypted(t *testing.T) {
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+
+	fs, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	key := "abcdefghijklmnopqrstuvwxyz123456"
+	encrypted := fs.Encrypted(key)
+
+	// spans multiple encryption chunks
+	content := bytes.Repeat([]byte("0123456789"), 20000)
+	if err := encrypted.Upload(content, "encrypted.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := os.ReadFile(filepath.Join(dir, "encrypted.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, content[:100]) {
+		t.Fatal("Expected the stored content to be encrypted")
+	}
+
+	h := sha256.New()
+	n, err := encrypted.Hash("encrypted.bin", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	if n != int64(len(content)) || hex.EncodeToString(h.Sum(nil)) != hex.EncodeToString(sum[:]) {
+		t.Fatal("Expected the hash to be fed with the decrypted content")
+	}
+
+	if _, err := fs.Encrypted("00000000000000000000000000000000").Hash("encrypted.bin", sha256.New()); err == nil {
+		t.Fatal("Expected error for the wrong key, got nil")
+	}
+
+	if _, err := encrypted.Hash("test/sub1.txt", sha256.New()); err == nil {
+		t.Fatal("Expected error for a plain file, got nil")
+	}
+
+	// copying to a plain filesystem decrypts the content
+	if _, err := encrypted.CopyTo(fs, "encrypted.bin", "decrypted.bin"); err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := os.ReadFile(filepath.Join(dir, "decrypted.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, content) {
+		t.Fatal("Expected the copy to be decrypted")
+	}
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Add("Range", "bytes=65530-65545")
+	if err := encrypted.Serve(res, req, "encrypted.bin", "encrypted.bin"); err != nil {
+		t.Fatal(err)
+	}
+	result := res.Result()
+	if result.StatusCode != http.StatusPartialContent {
+		t.Fatalf("Expected StatusCode %d, got %d", http.StatusPartialContent, result.StatusCode)
+	}
+	expectedRange := "bytes 65530-65545/200000"
+	if cr := result.Header.Get("Content-Range"); cr != expectedRange {
+		t.Fatalf("Expected Content-Range %q, got %q", expectedRange, cr)
+	}
+	if body := res.Body.String(); body != string(content[65530:65546]) {
+		t.Fatalf("Expected body %q, got %q", content[65530:65546], body)
+	}
+}
+
 func TestFileSystemDelete(t *testing.T) {
 	dir := createTestDir(t)
 	defer os.RemoveAll(dir)
@@ -101,6 +300,37 @@ func TestFileSystemDelete(t *testing.T) {
 	}
 }
 
+func TestFileSystemList(t *testing.T) {
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+
+	fs, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	objects, err := fs.List("missing/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Fatalf("Expected no files, got %d", len(objects))
+	}
+
+	objects, err = fs.List("test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	if len(keys) != 2 || keys[0] != "test/sub1.txt" || keys[1] != "test/sub2.txt" {
+		t.Fatalf("Expected test/sub1.txt and test/sub2.txt, got %v", keys)
+	}
+}
+
 func TestFileSystemDeletePrefix(t *testing.T) {
 	dir := createTestDir(t)
 	defer os.RemoveAll(dir)
@@ -244,6 +474,95 @@ func TestFileSystemUpload(t *testing.T) {
 	}
 }
 
+func TestFileSystemUploadReader(t *testing.T) {
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+
+	fs, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	fileKey := "newdir/newkey.txt"
+
+	size, err := fs.UploadReader(strings.NewReader("demo"), fileKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 4 {
+		t.Fatalf("Expected size 4, got %d", size)
+	}
+
+	// a failed read keeps the existing file
+	_, err = fs.UploadReader(io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("read failed"))), fileKey)
+	if err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	content, err := os.ReadFile(filepath.Join(dir, fileKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "demo" {
+		t.Fatalf("Expected %q, got %q", "demo", string(content))
+	}
+}
+
+func TestFileSystemCompose(t *testing.T) {
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+
+	fs, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	if err := fs.Compose([]string{}, "composed.txt", "composed.txt"); err == nil {
+		t.Fatal("Expected error for missing source files, got nil")
+	}
+
+	if err := fs.Compose([]string{"missing/1", "missing/2"}, "composed.txt", "composed.txt"); err == nil {
+		t.Fatal("Expected error for not existing source files, got nil")
+	}
+	if exists, _ := fs.Exists("composed.txt"); exists {
+		t.Fatal("Expected failed compose to not leave a partial file")
+	}
+
+	parts := []string{"chunks/1", "chunks/2", "chunks/3"}
+	for i, part := range parts {
+		if err := fs.Upload([]byte(strings.Repeat(string(rune('a'+i)), 3)), part); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileKey := "newdir/composed.txt"
+
+	h := sha256.New()
+	if err := fs.Compose(parts, fileKey, "original.txt", h); err != nil {
+		t.Fatal(err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != "fb84a45f6df7d1d17036f939f1cfeb87339ff5dbdf411222f3762dd76779a287" {
+		t.Fatalf("Expected composed content sha256 digest, got %q", sum)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, fileKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "aaabbbccc" {
+		t.Fatalf("Expected composed content %q, got %q", "aaabbbccc", string(content))
+	}
+
+	attrs, err := fs.Attributes(fileKey)
+	if err != nil {
+		t.Fatalf("Failed to fetch file attributes: %v", err)
+	}
+	if name, ok := attrs.Metadata["original-filename"]; !ok || name != "original.txt" {
+		t.Fatalf("Expected original-filename to be %q, got %q", "original.txt", name)
+	}
+}
+
 func TestFileSystemServe(t *testing.T) {
 	dir := createTestDir(t)
 	defer os.RemoveAll(dir)
@@ -3,6 +3,7 @@ package security
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	crand "crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
@@ -20,6 +21,15 @@ func S256Challenge(code string) string {
 	return strings.TrimRight(base64.URLEncoding.EncodeToString(h.Sum(nil)), "=")
 }
 
+// DeriveKey derives a 32 char key (valid for Encrypt and Decrypt) bound
+// to info from the secret, so that a single secret can produce
+// independent keys for different purposes.
+func DeriveKey(secret string, info string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(info))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:32]
+}
+
 // Encrypt encrypts data with key (must be valid 32 char aes key).
 func Encrypt(data []byte, key string) (string, error) {
 	block, err := aes.NewCipher([]byte(key))
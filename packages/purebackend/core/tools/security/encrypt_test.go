@@ -24,6 +24,25 @@ func TestS256Challenge(t *testing.T) {
 	}
 }
 
+func TestDeriveKey(t *testing.T) {
+	key := security.DeriveKey("secret", "info")
+	if len(key) != 32 {
+		t.Fatalf("Expected 32 chars key, got %q", key)
+	}
+	if again := security.DeriveKey("secret", "info"); again != key {
+		t.Fatalf("Expected the derivation to be deterministic, got %q and %q", key, again)
+	}
+	if other := security.DeriveKey("secret", "other"); other == key {
+		t.Fatal("Expected different keys for different infos")
+	}
+	if other := security.DeriveKey("other", "info"); other == key {
+		t.Fatal("Expected different keys for different secrets")
+	}
+	if _, err := security.Encrypt([]byte("123"), key); err != nil {
+		t.Fatalf("Expected a valid aes key, got error %v", err)
+	}
+}
+
 func TestEncrypt(t *testing.T) {
 	scenarios := []struct {
 		data        string
@@ -0,0 +1,120 @@
+// Package trash purges the deleted models, datasets, branches and versions
+// once their retention period is over.
+package trash
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/gc"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+)
+
+const (
+	// DefaultRetention is how long a deleted object can be restored.
+	DefaultRetention = 30 * 24 * time.Hour
+
+	// DefaultInterval is the default period of the background purge.
+	DefaultInterval = time.Hour
+)
+
+// Retention returns the configured retention of the trash.
+func Retention(app core.App) time.Duration {
+	if retention := app.Settings().Trash.Retention; retention > 0 {
+		return time.Duration(retention) * time.Second
+	}
+	return DefaultRetention
+}
+
+// PurgeTime returns when an object deleted now is purged.
+func PurgeTime(app core.App) time.Time {
+	return time.Now().Add(Retention(app))
+}
+
+// Purge permanently deletes the trash item and removes its blobs and file
+// logs from the storages of the org. Storage errors are returned after the
+// item is purged from the database, the remaining objects are left to the
+// storage garbage collector.
+func Purge(app core.App, item *models.TrashItemResponse) []error {
+	objects, err := app.Dao().PurgeTrashItem(item.UUID)
+	if err != nil {
+		return []error{err}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	storages, err := gc.OrgStorages(app, item.OrgUUID)
+	if err != nil {
+		return []error{err}
+	}
+	errs := []error{}
+	for _, object := range objects {
+		for _, sourceSecrets := range storages {
+			if sourceSecrets.SourceType != object.SourceType || sourceSecrets.PublicURL != object.SourceURL {
+				continue
+			}
+			fs, err := app.NewFilesystem(sourceSecrets)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", object.SourceType, err))
+				break
+			}
+			if err := fs.Delete(object.Key); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", object.Key, err))
+			} else if err := app.Dao().RemoveStorageUsage(item.OrgUUID, object.SourceType, object.SourceURL, object.Key); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", object.Key, err))
+			}
+			fs.Close()
+			break
+		}
+	}
+	return errs
+}
+
+// PurgeExpired purges the items of every org whose retention is over.
+func PurgeExpired(app core.App) (int, error) {
+	items, err := app.Dao().GetExpiredTrashItems(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for i := range items {
+		errs := Purge(app, &items[i])
+		for _, err := range errs {
+			log.Printf("trash: purge of %s %s: %v\n", items[i].Kind, items[i].Name, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Start purges the expired items periodically in the background until the
+// returned stop function is called.
+func Start(app core.App, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				purged, err := PurgeExpired(app)
+				if err != nil {
+					log.Println("trash:", err)
+				}
+				if purged > 0 {
+					log.Printf("trash: purged %d items\n", purged)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
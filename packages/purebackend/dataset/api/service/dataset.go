@@ -168,11 +168,18 @@ func (api *Api) CreateDataset(request *models.Request) *models.Response {
 	if dataset != nil {
 		return models.NewErrorResponse(http.StatusBadRequest, "Dataset already exists")
 	}
+	trashed, err := api.app.Dao().IsDatasetNameTrashed(orgId, datasetName)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if trashed {
+		return models.NewErrorResponse(http.StatusBadRequest, "Dataset with this name is in the trash")
+	}
 	dataset, err = api.app.Dao().CreateDataset(orgId, datasetName, datasetWikiData, datasetIsPublicData, datasetReadmeData, userUUID)
 	if err != nil {
 		return models.NewServerErrorResponse(err)
 	}
-	_, err = api.app.Dao().CreateDatasetBranches(dataset.UUID, datasetBranchNamesData)
+	_, err = api.app.Dao().CreateDatasetBranches(orgId, dataset.UUID, datasetBranchNamesData, userUUID)
 	if err != nil {
 		return models.NewServerErrorResponse(err)
 	}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+)
+
+// BindDatasetAliasApi registers the dataset version alias api endpoints and the corresponding handlers.
+func BindDatasetAliasApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	datasetGroup := rg.Group("/org/:orgId/dataset", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	datasetGroup.GET("/:datasetName/alias", api.DefaultHandler(GetDatasetAliases), middlewares.ValidateDataset(api.app))
+	datasetGroup.GET("/:datasetName/alias/:aliasName", api.DefaultHandler(GetDatasetAlias), middlewares.ValidateDataset(api.app))
+	datasetGroup.POST("/:datasetName/alias/:aliasName", api.DefaultHandler(SetDatasetAlias), middlewares.ValidateDataset(api.app))
+	datasetGroup.GET("/:datasetName/alias/:aliasName/history", api.DefaultHandler(GetDatasetAliasHistory), middlewares.ValidateDataset(api.app))
+	datasetGroup.DELETE("/:datasetName/alias/:aliasName/delete", api.DefaultHandler(DeleteDatasetAlias), middlewares.ValidateDataset(api.app))
+}
+
+// GetDatasetAliases godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get all aliases of a dataset
+//	@Description	Get all version aliases of a dataset with the branch and version they point to
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/alias [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+func (api *Api) GetDatasetAliases(request *models.Request) *models.Response {
+	aliases, err := api.app.Dao().GetDatasetAliases(request.GetDatasetUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, aliases, "All dataset aliases")
+}
+
+// GetDatasetAlias godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get a dataset alias
+//	@Description	Get the branch and version a dataset alias points to
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/alias/{aliasName} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			aliasName	path	string	true	"Alias Name"
+func (api *Api) GetDatasetAlias(request *models.Request) *models.Response {
+	alias, err := api.app.Dao().GetDatasetAlias(request.GetDatasetUUID(), request.GetPathParam("aliasName"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if alias == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Alias not found")
+	}
+	return models.NewDataResponse(http.StatusOK, alias, "Dataset alias details")
+}
+
+// SetDatasetAlias godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Set a dataset alias
+//	@Description	Point a dataset alias (eg. production) to a branch version, creating the alias if needed. The move is recorded in the alias history. The alias can then be used wherever a version is accepted on that branch
+//	@Tags			Dataset
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/alias/{aliasName} [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			datasetName	path	string								true	"Dataset Name"
+//	@Param			aliasName	path	string								true	"Alias Name"
+//	@Param			data		body	commonmodels.VersionAliasRequest	true	"Branch and version of the alias"
+func (api *Api) SetDatasetAlias(request *models.Request) *models.Response {
+	aliasName := request.GetPathParam("aliasName")
+	if !models.IsValidAliasName(aliasName) {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid alias name %s", aliasName))
+	}
+	request.ParseJsonBody()
+	branchName, _ := request.GetParsedBodyAttribute("branch").(string)
+	versionName, _ := request.GetParsedBodyAttribute("version").(string)
+	if branchName == "" || versionName == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Branch and version are required")
+	}
+	branch, err := api.app.Dao().GetDatasetBranchByName(request.GetOrgId(), request.GetDatasetName(), branchName)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if branch == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Branch not found")
+	}
+	version, err := api.app.Dao().GetDatasetBranchVersion(branch.UUID, versionName)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	alias, err := api.app.Dao().SetDatasetAlias(request.GetDatasetUUID(), aliasName, version.UUID, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, alias, "Dataset alias updated")
+}
+
+// GetDatasetAliasHistory godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the history of a dataset alias
+//	@Description	Get the moves of a dataset alias, latest first, with who moved it and when
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/alias/{aliasName}/history [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			aliasName	path	string	true	"Alias Name"
+func (api *Api) GetDatasetAliasHistory(request *models.Request) *models.Response {
+	history, err := api.app.Dao().GetDatasetAliasHistory(request.GetDatasetUUID(), request.GetPathParam("aliasName"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if len(history) == 0 {
+		return models.NewErrorResponse(http.StatusNotFound, "Alias not found")
+	}
+	return models.NewDataResponse(http.StatusOK, history, "Dataset alias history")
+}
+
+// DeleteDatasetAlias godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Delete a dataset alias
+//	@Description	Remove a dataset alias. The removal is recorded in the alias history
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/alias/{aliasName}/delete [delete]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			aliasName	path	string	true	"Alias Name"
+func (api *Api) DeleteDatasetAlias(request *models.Request) *models.Response {
+	aliasName := request.GetPathParam("aliasName")
+	alias, err := api.app.Dao().GetDatasetAlias(request.GetDatasetUUID(), aliasName)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if alias == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Alias not found")
+	}
+	err = api.app.Dao().DeleteDatasetAlias(request.GetDatasetUUID(), aliasName, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, nil, "Dataset alias deleted")
+}
+
+var GetDatasetAliases ServiceFunc = (*Api).GetDatasetAliases
+var GetDatasetAlias ServiceFunc = (*Api).GetDatasetAlias
+var SetDatasetAlias ServiceFunc = (*Api).SetDatasetAlias
+var GetDatasetAliasHistory ServiceFunc = (*Api).GetDatasetAliasHistory
+var DeleteDatasetAlias ServiceFunc = (*Api).DeleteDatasetAlias
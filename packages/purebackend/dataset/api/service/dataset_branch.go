@@ -1,14 +1,19 @@
 package service
 
 import (
+	"fmt"
 	"net/http"
 
 	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	coreservice "github.com/PureMLHQ/PureML/packages/purebackend/core/apis/service"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/trash"
 	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	datasetmodels "github.com/PureMLHQ/PureML/packages/purebackend/dataset/models"
 	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
 	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
 )
 
 // BindDatasetBranchApi registers the admin api endpoints and the corresponding handlers.
@@ -76,7 +81,7 @@ func (api *Api) GetDatasetBranch(request *models.Request) *models.Response {
 //
 //	@Security		ApiKeyAuth
 //	@Summary		Create a new branch of a dataset
-//	@Description	Create a new branch of a dataset
+//	@Description	Create a new branch of a dataset. The branch starts empty, or with a copy of from_version of from_branch when they are given
 //	@Tags			Dataset
 //	@Accept			*/*
 //	@Produce		json
@@ -95,47 +100,84 @@ func (api *Api) CreateDatasetBranch(request *models.Request) *models.Response {
 	} else {
 		datasetBranchNameData = datasetBranchName.(string)
 	}
-	if datasetBranchNameData == "" {
-		return models.NewErrorResponse(http.StatusBadRequest, "Branch name cannot be empty")
+	isTaken := func(name string) (bool, error) {
+		return api.app.Dao().IsDatasetBranchNameTaken(datasetUUID, name)
+	}
+	if errresp := coreservice.ValidateBranchName(datasetBranchNameData, isTaken); errresp != nil {
+		return errresp
 	}
 	datasetBranches, err := api.app.Dao().GetDatasetAllBranches(datasetUUID)
 	if err != nil {
 		return models.NewErrorResponse(http.StatusInternalServerError, err.Error())
 	}
-	for _, branch := range datasetBranches {
-		if branch.Name == datasetBranchNameData {
-			return models.NewErrorResponse(http.StatusBadRequest, "Branch already exists")
+	fromBranch, _ := request.GetParsedBodyAttribute("from_branch").(string)
+	fromVersion, _ := request.GetParsedBodyAttribute("from_version").(string)
+	if fromBranch == "" && fromVersion == "" {
+		datasetBranch, err := api.app.Dao().CreateDatasetBranch(datasetUUID, datasetBranchNameData)
+		if err != nil {
+			return models.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		}
+		return models.NewDataResponse(http.StatusOK, datasetBranch, "Dataset branch created")
+	}
+	if fromBranch == "" || fromVersion == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Both from_branch and from_version are required to create a branch from a version")
+	}
+	var baseBranch *datasetmodels.DatasetBranchResponse
+	for i := range datasetBranches {
+		if datasetBranches[i].Name == fromBranch {
+			baseBranch = &datasetBranches[i]
 		}
 	}
-	modelBranch, err := api.app.Dao().CreateDatasetBranch(datasetUUID, datasetBranchNameData)
+	if baseBranch == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "From branch not found")
+	}
+	baseVersion, err := api.app.Dao().GetDatasetBranchVersion(baseBranch.UUID, fromVersion)
 	if err != nil {
-		return models.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		return models.NewServerErrorResponse(err)
+	}
+	if baseVersion == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "From version not found")
 	}
-	return models.NewDataResponse(http.StatusOK, modelBranch, "Dataset branch created")
+	datasetBranch, err := api.app.Dao().CreateDatasetBranchFromVersion(datasetUUID, datasetBranchNameData, baseVersion.UUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, datasetBranch, "Dataset branch created")
 }
 
-// TODO: UpdateDatasetBranch godoc
+// UpdateDatasetBranch godoc
 //
 //	@Security		ApiKeyAuth
 //	@Summary		Update a branch of a dataset
-//	@Description	Update a branch of a dataset
+//	@Description	Rename a branch of a dataset, or make it the default branch of the dataset in place of the current one
 //	@Tags			Dataset
-//	@Accept			*/*
+//	@Accept			json
 //	@Produce		json
 //	@Success		200	{object}	map[string]interface{}
 //	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/update [post]
 //	@Param			orgId		path	string	true	"Organization Id"
 //	@Param			datasetName	path	string	true	"Dataset Name"
 //	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			data		body	object	true	"branch_name and is_default"
 func (api *Api) UpdateDatasetBranch(request *models.Request) *models.Response {
-	return nil
+	datasetUUID := request.GetDatasetUUID()
+	branch, err := api.app.Dao().GetDatasetBranchByUUID(request.GetDatasetBranchUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	isTaken := func(name string) (bool, error) {
+		return api.app.Dao().IsDatasetBranchNameTaken(datasetUUID, name)
+	}
+	return coreservice.UpdateBranch(request, "Dataset", branch.Name, branch.IsDefault, isTaken, func(name string, isDefault bool) (interface{}, error) {
+		return api.app.Dao().UpdateDatasetBranch(datasetUUID, branch.UUID, name, isDefault)
+	})
 }
 
-// TODO: DeleteDatasetBranch godoc
+// DeleteDatasetBranch godoc
 //
 //	@Security		ApiKeyAuth
 //	@Summary		Delete a branch of a dataset
-//	@Description	Delete a branch of a dataset
+//	@Description	Move a branch of a dataset with all its versions to the trash of the organization. It can be restored until it is purged, its versions, logs and reviews are removed when it is purged. The default branch and a branch referenced by an alias or an open review cannot be deleted
 //	@Tags			Dataset
 //	@Accept			*/*
 //	@Produce		json
@@ -145,7 +187,30 @@ func (api *Api) UpdateDatasetBranch(request *models.Request) *models.Response {
 //	@Param			datasetName	path	string	true	"Dataset Name"
 //	@Param			branchName	path	string	true	"Branch Name"
 func (api *Api) DeleteDatasetBranch(request *models.Request) *models.Response {
-	return nil
+	branchUUID := request.GetDatasetBranchUUID()
+	branch, err := api.app.Dao().GetDatasetBranchByUUID(branchUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if branch.IsDefault {
+		return models.NewErrorResponse(http.StatusBadRequest, "Default branch cannot be deleted")
+	}
+	protection, err := api.app.Dao().GetDatasetBranchProtection(branchUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if protection != nil && protection.BlockDeletion {
+		return models.NewErrorResponse(http.StatusBadRequest, "Branch is protected against deletion")
+	}
+	if errresp := api.validateDatasetUnreferenced(request, "Branch", uuid.NullUUID{UUID: branchUUID, Valid: true}, uuid.NullUUID{}); errresp != nil {
+		return errresp
+	}
+	name := fmt.Sprintf("%s/%s", request.GetDatasetName(), request.GetDatasetBranchName())
+	item, err := api.app.Dao().TrashDatasetBranch(request.GetOrgId(), branchUUID, name, request.GetUserUUID(), trash.PurgeTime(api.app))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, item, "Dataset branch moved to trash")
 }
 
 var GetDatasetAllBranches ServiceFunc = (*Api).GetDatasetAllBranches
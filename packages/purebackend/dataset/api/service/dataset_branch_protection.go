@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindDatasetBranchProtectionApi registers the admin api endpoints and the corresponding handlers.
+func BindDatasetBranchProtectionApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	datasetGroup := rg.Group("/org/:orgId/dataset", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	datasetGroup.GET("/:datasetName/branch/:branchName/protection", api.DefaultHandler(GetDatasetBranchProtection), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.POST("/:datasetName/branch/:branchName/protection", api.DefaultHandler(SetDatasetBranchProtection), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.DELETE("/:datasetName/branch/:branchName/protection/delete", api.DefaultHandler(DeleteDatasetBranchProtection), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.POST("/:datasetName/review/:reviewId/approve", api.DefaultHandler(ApproveDatasetReview), middlewares.ValidateDataset(api.app))
+}
+
+// GetDatasetBranchProtection godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the protection rules of a dataset branch
+//	@Description	Get the protection rules of a dataset branch
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/protection [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+func (api *Api) GetDatasetBranchProtection(request *models.Request) *models.Response {
+	protection, err := api.app.Dao().GetDatasetBranchProtection(request.GetDatasetBranchUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if protection == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Branch is not protected")
+	}
+	return models.NewDataResponse(http.StatusOK, protection, "Dataset branch protection")
+}
+
+// SetDatasetBranchProtection godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Set the protection rules of a dataset branch
+//	@Description	Block direct registration or deletion of a dataset branch, require approvals before a review is merged into it and restrict who can accept its reviews. Only organization owners can protect branches
+//	@Tags			Dataset
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/protection [post]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			data		body	object	true	"block_direct_registration, required_approvals, block_deletion and reviewers (user handles)"
+func (api *Api) SetDatasetBranchProtection(request *models.Request) *models.Response {
+	if errresp := api.validateDatasetOrgOwner(request, "You are not authorized to protect the branches of this dataset"); errresp != nil {
+		return errresp
+	}
+	request.ParseJsonBody()
+	rules, errresp := api.parseBranchProtectionRules(request)
+	if errresp != nil {
+		return errresp
+	}
+	protection, err := api.app.Dao().SetDatasetBranchProtection(request.GetOrgId(), request.GetDatasetBranchUUID(), *rules, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, protection, "Dataset branch protection updated")
+}
+
+// DeleteDatasetBranchProtection godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Remove the protection rules of a dataset branch
+//	@Description	Remove the protection rules of a dataset branch. Only organization owners can unprotect branches
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/protection/delete [delete]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+func (api *Api) DeleteDatasetBranchProtection(request *models.Request) *models.Response {
+	if errresp := api.validateDatasetOrgOwner(request, "You are not authorized to unprotect the branches of this dataset"); errresp != nil {
+		return errresp
+	}
+	err := api.app.Dao().DeleteDatasetBranchProtection(request.GetDatasetBranchUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, nil, "Dataset branch protection removed")
+}
+
+// ApproveDatasetReview godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Approve a review of a dataset
+//	@Description	Approve a review of a dataset. Protected branches can require approvals before a review is merged into them
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/review/{reviewId}/approve [post]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			reviewId	path	string	true	"Review UUID"
+func (api *Api) ApproveDatasetReview(request *models.Request) *models.Response {
+	reviewUUID, err := uuid.FromString(request.GetPathParam("reviewId"))
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid review id")
+	}
+	review, err := api.app.Dao().GetDatasetReview(reviewUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if review == nil || review.Dataset.UUID != request.GetDatasetUUID() {
+		return models.NewErrorResponse(http.StatusNotFound, "Review with given ID not found")
+	}
+	if review.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Review already complete")
+	}
+	userUUID := request.GetUserUUID()
+	if review.CreatedBy.UUID == userUUID {
+		return models.NewErrorResponse(http.StatusBadRequest, "You cannot approve your own review")
+	}
+	protection, err := api.app.Dao().GetDatasetBranchProtection(review.ToBranch.UUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if !isBranchReviewer(protection, userUUID) {
+		return models.NewErrorResponse(http.StatusForbidden, "You are not a reviewer of this branch")
+	}
+	approval, err := api.app.Dao().ApproveDatasetReview(reviewUUID, userUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, approval, "Dataset review approved")
+}
+
+// parseBranchProtectionRules reads the protection rules from the request
+// body, resolving the reviewer handles to members of the organization.
+func (api *Api) parseBranchProtectionRules(request *models.Request) (*models.BranchProtectionRules, *models.Response) {
+	rules := &models.BranchProtectionRules{}
+	rules.BlockDirectRegistration, _ = request.GetParsedBodyAttribute("block_direct_registration").(bool)
+	rules.BlockDeletion, _ = request.GetParsedBodyAttribute("block_deletion").(bool)
+	var requiredApprovals float64
+	if value := request.GetParsedBodyAttribute("required_approvals"); value != nil {
+		var ok bool
+		requiredApprovals, ok = value.(float64)
+		if !ok || requiredApprovals < 0 || requiredApprovals != float64(int(requiredApprovals)) {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, "required_approvals must be a non-negative integer")
+		}
+	}
+	rules.RequiredApprovals = int(requiredApprovals)
+	reviewers, _ := request.GetParsedBodyAttribute("reviewers").([]interface{})
+	for _, reviewer := range reviewers {
+		handle, _ := reviewer.(string)
+		user, err := api.app.Dao().GetUserByHandle(handle)
+		if err != nil {
+			return nil, models.NewServerErrorResponse(err)
+		}
+		if user == nil {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("User %s not found", handle))
+		}
+		userOrganization, err := api.app.Dao().GetUserOrganizationByOrgIdAndUserUUID(request.GetOrgId(), user.UUID)
+		if err != nil {
+			return nil, models.NewServerErrorResponse(err)
+		}
+		if userOrganization == nil {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("User %s is not a member of the organization", handle))
+		}
+		rules.Reviewers = append(rules.Reviewers, user.UUID)
+	}
+	return rules, nil
+}
+
+// isBranchReviewer reports whether the user may approve and accept the
+// reviews of the protected branch. Anyone can when no reviewers are listed.
+func isBranchReviewer(protection *models.BranchProtectionResponse, userUUID uuid.UUID) bool {
+	if protection == nil || len(protection.Reviewers) == 0 {
+		return true
+	}
+	for _, reviewer := range protection.Reviewers {
+		if reviewer.UUID == userUUID {
+			return true
+		}
+	}
+	return false
+}
+
+func (api *Api) validateDatasetBranchRegistration(request *models.Request) *models.Response {
+	protection, err := api.app.Dao().GetDatasetBranchProtection(request.GetDatasetBranchUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if protection != nil && protection.BlockDirectRegistration {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Cannot register dataset directly to %s branch", request.GetPathParam("branchName")))
+	}
+	return nil
+}
+
+// validateDatasetReviewMerge checks that the user may accept the review into
+// the branch. The review is nil while it is being created.
+func (api *Api) validateDatasetReviewMerge(toBranchUUID uuid.UUID, reviewUUID uuid.NullUUID, userUUID uuid.UUID) *models.Response {
+	protection, err := api.app.Dao().GetDatasetBranchProtection(toBranchUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if protection == nil {
+		return nil
+	}
+	if !isBranchReviewer(protection, userUUID) {
+		return models.NewErrorResponse(http.StatusForbidden, "You are not allowed to accept reviews into this branch")
+	}
+	if protection.RequiredApprovals == 0 {
+		return nil
+	}
+	var approvals int64
+	if reviewUUID.Valid {
+		approvals, err = api.app.Dao().CountDatasetReviewApprovals(reviewUUID.UUID)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+	}
+	if approvals < int64(protection.RequiredApprovals) {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Review needs %d approvals before merge", protection.RequiredApprovals))
+	}
+	return nil
+}
+
+func (api *Api) validateDatasetOrgOwner(request *models.Request, message string) *models.Response {
+	userOrganization, err := api.app.Dao().GetUserOrganizationByOrgIdAndUserUUID(request.GetOrgId(), request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if userOrganization == nil || userOrganization.Role != "owner" {
+		return models.NewErrorResponse(http.StatusForbidden, message)
+	}
+	return nil
+}
+
+var GetDatasetBranchProtection ServiceFunc = (*Api).GetDatasetBranchProtection
+var SetDatasetBranchProtection ServiceFunc = (*Api).SetDatasetBranchProtection
+var DeleteDatasetBranchProtection ServiceFunc = (*Api).DeleteDatasetBranchProtection
+var ApproveDatasetReview ServiceFunc = (*Api).ApproveDatasetReview
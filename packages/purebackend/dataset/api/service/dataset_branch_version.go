@@ -3,12 +3,14 @@ package service
 import (
 	"fmt"
 	"net/http"
-	"strings"
+	"path"
 
 	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core"
 	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/filesystem"
 	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
 	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
@@ -25,6 +27,7 @@ func BindDatasetBranchVersionApi(app core.App, rg *echo.Group) {
 	datasetGroup.POST("/:datasetName/branch/:branchName/register", api.DefaultHandler(RegisterDataset), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
 	datasetGroup.GET("/:datasetName/branch/:branchName/version", api.DefaultHandler(GetDatasetBranchAllVersions), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
 	datasetGroup.GET("/:datasetName/branch/:branchName/version/:version", api.DefaultHandler(GetDatasetBranchVersion), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
+	datasetGroup.GET("/:datasetName/branch/:branchName/version/:version/download", api.FileHandler(DownloadDatasetBranchVersion), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
 }
 
 // GetDatasetBranchAllVersions godoc
@@ -40,12 +43,20 @@ func BindDatasetBranchVersionApi(app core.App, rg *echo.Group) {
 //	@Param			orgId		path	string	true	"Organization Id"
 //	@Param			datasetName	path	string	true	"Dataset Name"
 //	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			tag			query	string	false	"Only the versions with the tag"
 func (api *Api) GetDatasetBranchAllVersions(request *models.Request) *models.Response {
 	var response *models.Response
 	branchUUID := request.GetDatasetBranchUUID()
 	allVersions, err := api.app.Dao().GetDatasetBranchAllVersions(branchUUID)
 	if err != nil {
 		return models.NewServerErrorResponse(err)
+	}
+	if tag := request.GetQueryParam("tag"); tag != "" {
+		allVersions, err = api.filterDatasetVersionsByTag(request.GetDatasetUUID(), allVersions, tag)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		response = models.NewDataResponse(http.StatusOK, allVersions, "All dataset branch versions")
 	} else {
 		response = models.NewDataResponse(http.StatusOK, allVersions, "All dataset branch versions")
 	}
@@ -79,6 +90,68 @@ func (api *Api) GetDatasetBranchVersion(request *models.Request) *models.Respons
 	return models.NewDataResponse(http.StatusOK, version, "Dataset branch version details")
 }
 
+// DownloadDatasetBranchVersion godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Download specific branch version file of a dataset
+//	@Description	Download the file of a specific branch version of a dataset. Supports Range, If-Range and If-None-Match requests to resume large downloads
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		octet-stream
+//	@Success		200	{file}	binary
+//	@Success		206	{file}	binary
+//	@Success		304
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/version/{version}/download [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) DownloadDatasetBranchVersion(request *models.Request) (*models.FileResponse, *models.Response) {
+	orgId := request.GetOrgId()
+	branchUUID := request.GetDatasetBranchUUID()
+	versionName := request.PathParams["version"]
+	version, err := api.app.Dao().GetDatasetBranchVersion(branchUUID, versionName)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	if version.IsManifest {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Dataset version has multiple files, download them individually")
+	}
+	if version.IsEmpty || version.Path == "" {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Dataset version has no file")
+	}
+	sourceSecrets, fileKey, errresp := api.GetStoredFileSourceSecrets(version.SourceType, version.Path, orgId)
+	if errresp != nil {
+		return nil, errresp
+	}
+	fileName := version.FileName
+	if fileName == "" {
+		fileName = path.Base(fileKey)
+	}
+	etag := version.Hash
+	if digest, ok := version.Digests["sha256"]; ok {
+		etag = digest
+	} else if version.IsReferenced && version.ETag != "" {
+		// the hash of a reference registered without compute_hash is not
+		// verified, the object etag is
+		etag = version.ETag
+	}
+	dataKey, err := encryption.Open(api.app, orgId, version.DataKey)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	return &models.FileResponse{
+		SourceSecrets: sourceSecrets,
+		Key:           fileKey,
+		Name:          fileName,
+		ETag:          etag,
+		DataKey:       dataKey,
+	}, nil
+}
+
 // VerifyDatasetBranchHashStatus godoc
 //
 //	@Security		ApiKeyAuth
@@ -136,13 +209,13 @@ func (api *Api) VerifyDatasetBranchHashStatus(request *models.Request) *models.R
 //
 //	@Security		ApiKeyAuth
 //	@Summary		Register dataset
-//	@Description	Register dataset file. Create dataset and default branches if not exists
+//	@Description	Register dataset file. Create dataset and default branches if not exists. A version can reference an object already stored in a storage of the organization instead of uploading a file, the object is then never deleted nor moved by the registry
 //	@Tags			Dataset
 //	@Accept			*/*
 //	@Produce		json
 //	@Success		200	{object}	map[string]interface{}
 //	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/register [post]
-//	@Param			file		formData	file							true	"Dataset file"
+//	@Param			file		formData	file							false	"Dataset file (required unless uri is set)"
 //	@Param			orgId		path		string							true	"Organization UUID"
 //	@Param			datasetName	path		string							true	"Dataset name"
 //	@Param			branchName	path		string							true	"Branch name"
@@ -152,12 +225,24 @@ func (api *Api) RegisterDataset(request *models.Request) *models.Response {
 	userUUID := request.GetUserUUID()
 	datasetUUID := request.GetDatasetUUID()
 	datasetBranchUUID := request.GetDatasetBranchUUID()
+	var datasetURI string
+	if request.FormValues["uri"] != nil && len(request.FormValues["uri"]) > 0 {
+		datasetURI = request.FormValues["uri"][0]
+	}
 	var datasetHash string
 	if request.FormValues["hash"] != nil && len(request.FormValues["hash"]) > 0 && request.FormValues["hash"][0] != "" {
 		datasetHash = request.FormValues["hash"][0]
-	} else {
+	} else if datasetURI == "" {
 		return models.NewErrorResponse(http.StatusBadRequest, "Hash is required")
 	}
+	var datasetHashAlgorithm string
+	if request.FormValues["hash_algorithm"] != nil && len(request.FormValues["hash_algorithm"]) > 0 {
+		datasetHashAlgorithm = request.FormValues["hash_algorithm"][0]
+	}
+	digester, errresp := api.NewDigester(datasetHashAlgorithm)
+	if errresp != nil {
+		return errresp
+	}
 	var datasetSourceSecretName string
 	if request.FormValues["storage"] != nil && len(request.FormValues["storage"]) > 0 {
 		datasetSourceSecretName = request.FormValues["storage"][0]
@@ -171,69 +256,121 @@ func (api *Api) RegisterDataset(request *models.Request) *models.Response {
 		datasetLineage = request.FormValues["lineage"][0]
 	}
 	fileHeader := request.GetFormFile("file")
-	if fileHeader == nil {
+	if fileHeader == nil && datasetURI == "" {
 		return models.NewErrorResponse(http.StatusBadRequest, "File is required")
 	}
-	datasetBranchName := request.GetPathParam("branchName")
-	if datasetBranchName == "main" {
-		return models.NewErrorResponse(http.StatusBadRequest, "Cannot register dataset directly to main branch")
+	if errresp := api.validateDatasetBranchRegistration(request); errresp != nil {
+		return errresp
 	}
-	versions, err := api.app.Dao().GetDatasetBranchAllVersions(datasetBranchUUID)
-	if err != nil {
-		return models.NewServerErrorResponse(err)
+	if datasetURI != "" {
+		computeHash := request.FormValues["compute_hash"] != nil && len(request.FormValues["compute_hash"]) > 0 && request.FormValues["compute_hash"][0] == "true"
+		return api.RegisterDatasetReference(request, datasetURI, datasetSourceSecretName, datasetHash, computeHash, datasetLineage, digester)
 	}
-	response := false
-	for _, version := range versions {
-		if version.Hash == datasetHash {
-			response = true
-			break
-		}
+	if errresp := api.checkDatasetVersionHash(datasetBranchUUID, datasetHash); errresp != nil {
+		return errresp
 	}
-	if response {
-		return models.NewErrorResponse(http.StatusBadRequest, "Dataset with this hash already exists")
+	datasetSourceSecrets, errresp := api.GetSourceSecrets(datasetSourceSecretName, orgId)
+	if errresp != nil {
+		return errresp
 	}
-	var datasetSourceType string
-	var datasetSourceSecrets *commonmodels.SourceSecrets
-	var errresp *models.Response
-	if strings.ToUpper(datasetSourceSecretName) != "LOCAL" {
-		datasetSourceSecrets, errresp = api.ValidateSourceTypeAndGetSourceSecrets(datasetSourceSecretName, orgId)
+	var filePath, hashAlgorithm, fileDigest, fileName string
+	var blobUUID uuid.NullUUID
+	var fileDataKey *commonmodels.DataKey
+	var softQuotaExceeded bool
+	if !datasetIsEmpty {
+		file, err := filesystem.NewFileFromMultipart(fileHeader)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		dataKey, err := encryption.NewDataKey(api.app, orgId)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		uploadPath, err := api.app.UploadFile(file, fmt.Sprintf("dataset-registry/%s/datasets/%s/%s", orgId, datasetUUID, datasetBranchUUID), datasetSourceSecrets, dataKey.Key, digester.Hashes()...)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		errresp = api.VerifyUploadedFileHash(digester, datasetHash, uploadPath, datasetSourceSecrets)
 		if errresp != nil {
 			return errresp
 		}
-		datasetSourceType = datasetSourceSecrets.SourceType
-	} else {
-		datasetSourceType = "LOCAL"
-		datasetSourceSecrets.SourceType = "LOCAL"
+		blob, exceeded, errresp := api.StoreUploadedBlob(orgId, datasetUUID, datasetSourceSecrets, uploadPath, digester.Sha256(), fileHeader.Size, dataKey)
+		if errresp != nil {
+			return errresp
+		}
+		softQuotaExceeded = exceeded
+		datasetHash = digester.Sum()
+		hashAlgorithm = digester.Algorithm
+		fileDigest = blob.Digest
+		filePath = blob.Key
+		fileName = fileHeader.Filename
+		blobUUID = uuid.NullUUID{UUID: blob.UUID, Valid: true}
+		fileDataKey = blob.DataKey
 	}
-	sourceValid := false
-	for source := range commonmodels.SupportedSources {
-		if commonmodels.SupportedSources[source] == datasetSourceType {
-			sourceValid = true
-			break
+	datasetVersion, err := api.app.Dao().RegisterDatasetFile(datasetBranchUUID, datasetSourceSecrets.SourceType, datasetSourceSecrets.PublicURL, filePath, datasetIsEmpty, datasetHash, hashAlgorithm, fileDigest, fileName, blobUUID, fileDataKey, datasetLineage, userUUID)
+	if err != nil {
+		if blobUUID.Valid {
+			api.ReleaseBlob(datasetSourceSecrets, blobUUID.UUID)
 		}
+		return registerVersionErrorResponse(err)
 	}
-	if !sourceValid {
-		return models.NewErrorResponse(http.StatusBadRequest, "Unsupported dataset storage")
+	return models.NewDataResponse(http.StatusOK, datasetVersion, withSoftQuotaWarning("Dataset successfully registered", softQuotaExceeded))
+}
+
+// RegisterDatasetReference registers a dataset version referencing the
+// object of the uri in the storage instead of uploading a file. The size and
+// ETag of the object are recorded, and its digest is computed by streaming
+// it once when requested or when no hash is provided.
+func (api *Api) RegisterDatasetReference(request *models.Request, uri string, storage string, hash string, computeHash bool, lineage string, digester *digest.Digester) *models.Response {
+	orgId := request.GetOrgId()
+	datasetBranchUUID := request.GetDatasetBranchUUID()
+	sourceSecrets, errresp := api.GetSourceSecrets(storage, orgId)
+	if errresp != nil {
+		return errresp
 	}
-	var filePath string
-	if !datasetIsEmpty {
-		file, err := filesystem.NewFileFromMultipart(fileHeader)
-		if err != nil {
+	key, errresp := api.ResolveReference(uri, sourceSecrets)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	exists, err := fs.Exists(key)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if !exists {
+		return models.NewErrorResponse(http.StatusNotFound, "Referenced object not found")
+	}
+	attrs, err := fs.Attributes(key)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	var fileDigest string
+	if computeHash || hash == "" {
+		if _, err := fs.Hash(key, digester.Hashes()...); err != nil {
 			return models.NewServerErrorResponse(err)
 		}
-		filePath, err = api.app.UploadFile(file, fmt.Sprintf("dataset-registry/%s/datasets/%s/%s", orgId, datasetUUID, datasetBranchUUID), datasetSourceSecrets)
-		if err != nil {
-			return models.NewServerErrorResponse(err)
+		if hash != "" && !digest.Equal(digester.Sum(), hash) {
+			return models.NewErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("Hash mismatch: expected %s %s, computed %s", digester.Algorithm, hash, digester.Sum()))
 		}
+		hash = digester.Sum()
+		fileDigest = digester.Sha256()
 	}
-	datasetVersion, err := api.app.Dao().RegisterDatasetFile(datasetBranchUUID, datasetSourceType, datasetSourceSecrets.PublicURL, filePath, datasetIsEmpty, datasetHash, datasetLineage, userUUID)
+	if errresp := api.checkDatasetVersionHash(datasetBranchUUID, hash); errresp != nil {
+		return errresp
+	}
+	datasetVersion, err := api.app.Dao().RegisterDatasetReference(datasetBranchUUID, sourceSecrets.SourceType, sourceSecrets.PublicURL, key, hash, digester.Algorithm, fileDigest, attrs.Size, attrs.ETag, lineage, request.GetUserUUID())
 	if err != nil {
-		return models.NewServerErrorResponse(err)
+		return registerVersionErrorResponse(err)
 	}
 	return models.NewDataResponse(http.StatusOK, datasetVersion, "Dataset successfully registered")
 }
 
 var GetDatasetBranchAllVersions ServiceFunc = (*Api).GetDatasetBranchAllVersions
 var GetDatasetBranchVersion ServiceFunc = (*Api).GetDatasetBranchVersion
+var DownloadDatasetBranchVersion FileServiceFunc = (*Api).DownloadDatasetBranchVersion
 var VerifyDatasetBranchHashStatus ServiceFunc = (*Api).VerifyDatasetBranchHashStatus
 var RegisterDataset ServiceFunc = (*Api).RegisterDataset
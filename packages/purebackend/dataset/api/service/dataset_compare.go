@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindDatasetCompareApi registers the dataset compare api endpoints and the corresponding handlers.
+func BindDatasetCompareApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	datasetGroup := rg.Group("/org/:orgId/dataset", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	datasetGroup.GET("/:datasetName/compare", api.DefaultHandler(CompareDatasetBranches), middlewares.ValidateDataset(api.app))
+}
+
+// CompareDatasetBranches godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Compare two branches or versions of a dataset
+//	@Description	Compare the head branch of a dataset with the base branch. Lists the versions only on each side, the fork point and whether the base moved since, and diffs the logs, readme and files of the compared versions, which are the latest versions unless base_version or head_version are given
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/compare [get]
+//	@Param			orgId			path	string	true	"Organization Id"
+//	@Param			datasetName		path	string	true	"Dataset Name"
+//	@Param			base			query	string	true	"Base branch name"
+//	@Param			head			query	string	true	"Head branch name"
+//	@Param			base_version	query	string	false	"Base version"
+//	@Param			head_version	query	string	false	"Head version"
+func (api *Api) CompareDatasetBranches(request *models.Request) *models.Response {
+	baseBranchName := request.GetQueryParam("base")
+	headBranchName := request.GetQueryParam("head")
+	if baseBranchName == "" || headBranchName == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Base and head branches are required")
+	}
+	baseBranchUUID, baseVersionUUID, errresp := api.compareDatasetRef(request, baseBranchName, request.GetQueryParam("base_version"))
+	if errresp != nil {
+		return errresp
+	}
+	headBranchUUID, headVersionUUID, errresp := api.compareDatasetRef(request, headBranchName, request.GetQueryParam("head_version"))
+	if errresp != nil {
+		return errresp
+	}
+	compare, err := api.app.Dao().CompareDatasetBranches(baseBranchUUID, baseVersionUUID, headBranchUUID, headVersionUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, compare, "Dataset branches compared")
+}
+
+// compareDatasetRef resolves a side of a comparison to the branch and, when
+// given, the version of the branch.
+func (api *Api) compareDatasetRef(request *models.Request, branchName string, versionName string) (uuid.UUID, uuid.NullUUID, *models.Response) {
+	branch, err := api.app.Dao().GetDatasetBranchByName(request.GetOrgId(), request.GetDatasetName(), branchName)
+	if err != nil {
+		return uuid.Nil, uuid.NullUUID{}, models.NewServerErrorResponse(err)
+	}
+	if branch == nil {
+		return uuid.Nil, uuid.NullUUID{}, models.NewErrorResponse(http.StatusNotFound, fmt.Sprintf("Branch %s not found", branchName))
+	}
+	if versionName == "" {
+		return branch.UUID, uuid.NullUUID{}, nil
+	}
+	version, err := api.app.Dao().GetDatasetBranchVersion(branch.UUID, versionName)
+	if err != nil {
+		return uuid.Nil, uuid.NullUUID{}, models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return uuid.Nil, uuid.NullUUID{}, models.NewErrorResponse(http.StatusNotFound, fmt.Sprintf("Version %s of branch %s not found", versionName, branchName))
+	}
+	return branch.UUID, uuid.NullUUID{UUID: version.UUID, Valid: true}, nil
+}
+
+var CompareDatasetBranches ServiceFunc = (*Api).CompareDatasetBranches
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/filesystem"
+	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindDatasetFilesApi registers the multi-file dataset version api endpoints and the corresponding handlers.
+func BindDatasetFilesApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	datasetGroup := rg.Group("/org/:orgId/dataset", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	datasetGroup.POST("/:datasetName/branch/:branchName/register/files", api.DefaultHandler(RegisterDatasetFiles), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.GET("/:datasetName/branch/:branchName/version/:version/files", api.DefaultHandler(GetDatasetBranchVersionFiles), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
+	datasetGroup.GET("/:datasetName/branch/:branchName/version/:version/files/*", api.FileHandler(DownloadDatasetBranchVersionFile), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
+}
+
+// RegisterDatasetFiles godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Register dataset files
+//	@Description	Register a dataset version made of several files (eg. data shards and their schema). The version hash is the sha256 digest of its manifest, the json array of its files (path, size, digest and media type) sorted by path
+//	@Tags			Dataset
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/register/files [post]
+//	@Param			files		formData	file	true	"Dataset files"
+//	@Param			paths		formData	string	false	"Path of each file in the version, in the order of the files (defaults to the file names)"
+//	@Param			hash		formData	string	false	"Expected manifest digest"
+//	@Param			lineage		formData	string	false	"Dataset lineage"
+//	@Param			storage		formData	string	false	"Storage"
+//	@Param			orgId		path		string	true	"Organization UUID"
+//	@Param			datasetName	path		string	true	"Dataset name"
+//	@Param			branchName	path		string	true	"Branch name"
+func (api *Api) RegisterDatasetFiles(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	datasetUUID := request.GetDatasetUUID()
+	datasetBranchUUID := request.GetDatasetBranchUUID()
+	if errresp := api.validateDatasetBranchRegistration(request); errresp != nil {
+		return errresp
+	}
+	fileHeaders := request.FormFiles["files"]
+	if len(fileHeaders) == 0 {
+		return models.NewErrorResponse(http.StatusBadRequest, "Files are required")
+	}
+	filePaths := request.FormValues["paths"]
+	if len(filePaths) > 0 && len(filePaths) != len(fileHeaders) {
+		return models.NewErrorResponse(http.StatusBadRequest, "A path is required for every file")
+	}
+	var datasetHash, datasetLineage, datasetSourceSecretName string
+	if len(request.FormValues["hash"]) > 0 {
+		datasetHash = request.FormValues["hash"][0]
+	}
+	if len(request.FormValues["lineage"]) > 0 {
+		datasetLineage = request.FormValues["lineage"][0]
+	}
+	if len(request.FormValues["storage"]) > 0 {
+		datasetSourceSecretName = request.FormValues["storage"][0]
+	}
+
+	files := make([]models.VersionFileResponse, 0, len(fileHeaders))
+	seen := map[string]bool{}
+	for i, fileHeader := range fileHeaders {
+		filePath := fileHeader.Filename
+		if len(filePaths) > 0 {
+			filePath = filePaths[i]
+		}
+		filePath, ok := cleanVersionFilePath(filePath)
+		if !ok {
+			return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid file path %s", filePath))
+		}
+		if seen[filePath] {
+			return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Duplicate file path %s", filePath))
+		}
+		seen[filePath] = true
+		file, err := describeVersionFile(fileHeader, filePath)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		files = append(files, *file)
+	}
+	manifestDigest := models.ManifestDigest(files)
+	if datasetHash != "" && !digest.Equal(datasetHash, manifestDigest) {
+		return models.NewErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("Hash mismatch: expected sha256 %s, computed %s", datasetHash, manifestDigest))
+	}
+	if errresp := api.checkDatasetVersionHash(datasetBranchUUID, manifestDigest); errresp != nil {
+		return errresp
+	}
+	datasetSourceSecrets, errresp := api.GetSourceSecrets(datasetSourceSecretName, orgId)
+	if errresp != nil {
+		return errresp
+	}
+
+	var softQuotaExceeded bool
+	releaseFiles := func() {
+		for _, file := range files {
+			if file.BlobUUID.Valid {
+				api.ReleaseBlob(datasetSourceSecrets, file.BlobUUID.UUID)
+			}
+		}
+	}
+	uploadDir := fmt.Sprintf("dataset-registry/%s/datasets/%s/%s", orgId, datasetUUID, datasetBranchUUID)
+	stored := map[string]int{}
+	for i := range files {
+		// identical files of the version share the blob of the first one
+		if first, ok := stored[files[i].Digest]; ok {
+			if err := api.app.Dao().RetainBlob(files[first].BlobUUID.UUID); err != nil {
+				releaseFiles()
+				return models.NewServerErrorResponse(err)
+			}
+			files[i].StoragePath = files[first].StoragePath
+			files[i].SourceType = files[first].SourceType
+			files[i].BlobUUID = files[first].BlobUUID
+			files[i].DataKey = files[first].DataKey
+			continue
+		}
+		exceeded, errresp := api.StoreVersionFile(orgId, datasetUUID, datasetSourceSecrets, uploadDir, fileHeaders[i], &files[i])
+		if errresp != nil {
+			releaseFiles()
+			return errresp
+		}
+		softQuotaExceeded = softQuotaExceeded || exceeded
+		stored[files[i].Digest] = i
+	}
+	datasetVersion, err := api.app.Dao().RegisterDatasetFiles(datasetBranchUUID, datasetSourceSecrets.SourceType, datasetSourceSecrets.PublicURL, manifestDigest, files, datasetLineage, userUUID)
+	if err != nil {
+		releaseFiles()
+		return registerVersionErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, datasetVersion, withSoftQuotaWarning("Dataset successfully registered", softQuotaExceeded))
+}
+
+// GetDatasetBranchVersionFiles godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the files of a dataset version
+//	@Description	Get the manifest of a dataset version, listing the path, size, digest and media type of its files
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/version/{version}/files [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) GetDatasetBranchVersionFiles(request *models.Request) *models.Response {
+	version, err := api.app.Dao().GetDatasetBranchVersion(request.GetDatasetBranchUUID(), request.PathParams["version"])
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	files, err := api.app.Dao().GetDatasetVersionFiles(version.UUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, files, "Dataset branch version files")
+}
+
+// DownloadDatasetBranchVersionFile godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Download a file of a dataset version
+//	@Description	Download a file of a dataset version by its path in the manifest. Supports Range, If-Range and If-None-Match requests to resume large downloads
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		octet-stream
+//	@Success		200	{file}	binary
+//	@Success		206	{file}	binary
+//	@Success		304
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/version/{version}/files/{path} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+//	@Param			path		path	string	true	"File path"
+func (api *Api) DownloadDatasetBranchVersionFile(request *models.Request) (*models.FileResponse, *models.Response) {
+	orgId := request.GetOrgId()
+	filePath := request.GetPathParam("*")
+	if unescaped, err := url.PathUnescape(filePath); err == nil {
+		filePath = unescaped
+	}
+	version, err := api.app.Dao().GetDatasetBranchVersion(request.GetDatasetBranchUUID(), request.PathParams["version"])
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	files, err := api.app.Dao().GetDatasetVersionFiles(version.UUID)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	var file *models.VersionFileResponse
+	for i := range files {
+		if files[i].Path == filePath {
+			file = &files[i]
+			break
+		}
+	}
+	if file == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "File not found in dataset version")
+	}
+	sourceSecrets, fileKey, errresp := api.GetStoredFileSourceSecrets(file.SourceType, file.StoragePath, orgId)
+	if errresp != nil {
+		return nil, errresp
+	}
+	dataKey, err := encryption.Open(api.app, orgId, file.DataKey)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	return &models.FileResponse{
+		SourceSecrets: sourceSecrets,
+		Key:           fileKey,
+		Name:          path.Base(file.Path),
+		ETag:          file.Digest,
+		DataKey:       dataKey,
+	}, nil
+}
+
+// StoreVersionFile stores the uploaded file of a multi-file dataset version
+// as a blob (unless the source already stores its content) and sets the
+// storage of its manifest entry. The returned flag reports whether the
+// storage soft quota is exceeded.
+func (api *Api) StoreVersionFile(orgId uuid.UUID, datasetUUID uuid.UUID, sourceSecrets *commonmodels.SourceSecrets, uploadDir string, fileHeader *multipart.FileHeader, file *models.VersionFileResponse) (bool, *models.Response) {
+	blob, errresp := api.ReferenceStoredBlob(orgId, sourceSecrets, digest.DefaultAlgorithm, file.Digest)
+	if errresp != nil {
+		return false, errresp
+	}
+	var softQuotaExceeded bool
+	if blob == nil {
+		softQuotaExceeded, errresp = api.CheckStorageQuota(orgId, file.Size)
+		if errresp != nil {
+			return false, errresp
+		}
+		upload, err := filesystem.NewFileFromMultipart(fileHeader)
+		if err != nil {
+			return false, models.NewServerErrorResponse(err)
+		}
+		dataKey, err := encryption.NewDataKey(api.app, orgId)
+		if err != nil {
+			return false, models.NewServerErrorResponse(err)
+		}
+		digester, errresp := api.NewDigester(digest.DefaultAlgorithm)
+		if errresp != nil {
+			return false, errresp
+		}
+		uploadPath, err := api.app.UploadFile(upload, uploadDir, sourceSecrets, dataKey.Key, digester.Hashes()...)
+		if err != nil {
+			return false, models.NewServerErrorResponse(err)
+		}
+		errresp = api.VerifyUploadedFileHash(digester, file.Digest, uploadPath, sourceSecrets)
+		if errresp != nil {
+			return false, errresp
+		}
+		blob, errresp = api.StoreBlob(orgId, datasetUUID, sourceSecrets, uploadPath, digester.Sha256(), dataKey)
+		if errresp != nil {
+			return false, errresp
+		}
+	}
+	file.StoragePath = blob.Key
+	file.SourceType = sourceSecrets.SourceType
+	file.BlobUUID = uuid.NullUUID{UUID: blob.UUID, Valid: true}
+	file.DataKey = blob.DataKey
+	return softQuotaExceeded, nil
+}
+
+// cleanVersionFilePath validates the path of a file in a multi-file
+// version, which must be relative and stay inside the version.
+func cleanVersionFilePath(filePath string) (string, bool) {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" || strings.HasPrefix(filePath, "/") || strings.Contains(filePath, "\\") {
+		return filePath, false
+	}
+	cleaned := path.Clean(filePath)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return filePath, false
+	}
+	return cleaned, true
+}
+
+// describeVersionFile computes the manifest entry (without its storage) of
+// an uploaded file. The media type sent with the file is kept unless it is
+// generic, in which case it is detected from the content.
+func describeVersionFile(fileHeader *multipart.FileHeader, filePath string) (*models.VersionFileResponse, error) {
+	f, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mediaType := fileHeader.Header.Get("Content-Type")
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mt, err := mimetype.DetectReader(f)
+		if err != nil {
+			return nil, err
+		}
+		mediaType = mt.String()
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return &models.VersionFileResponse{
+		Path:      filePath,
+		Size:      size,
+		Digest:    hex.EncodeToString(h.Sum(nil)),
+		MediaType: mediaType,
+	}, nil
+}
+
+var RegisterDatasetFiles ServiceFunc = (*Api).RegisterDatasetFiles
+var GetDatasetBranchVersionFiles ServiceFunc = (*Api).GetDatasetBranchVersionFiles
+var DownloadDatasetBranchVersionFile FileServiceFunc = (*Api).DownloadDatasetBranchVersionFile
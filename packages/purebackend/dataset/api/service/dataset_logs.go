@@ -1,7 +1,10 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -15,8 +18,12 @@ import (
 	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
 	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
 	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
 )
 
+// defaultMaxLogBatchSize is the maximum size of a log batch unless configured.
+const defaultMaxLogBatchSize = 1 << 20
+
 // BindDatasetLogsApi registers the admin api endpoints and the corresponding handlers.
 func BindDatasetLogsApi(app core.App, rg *echo.Group) {
 	api := Api{app: app}
@@ -25,6 +32,7 @@ func BindDatasetLogsApi(app core.App, rg *echo.Group) {
 	datasetGroup.GET("/:datasetName/branch/:branchName/version/:version/log", api.DefaultHandler(GetAllLogsDataset), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
 	datasetGroup.GET("/:datasetName/branch/:branchName/version/:version/log/:key", api.DefaultHandler(GetKeyLogsDataset), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
 	datasetGroup.POST("/:datasetName/branch/:branchName/version/:version/log", api.DefaultHandler(LogDataset), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
+	datasetGroup.POST("/:datasetName/branch/:branchName/version/:version/log/batch", api.LogBatchHandler(LogBatchDataset), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
 	datasetGroup.POST("/:datasetName/branch/:branchName/version/:version/logfile", api.DefaultHandler(LogFileDataset), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
 }
 
@@ -121,6 +129,47 @@ func (api *Api) LogDataset(request *models.Request) *models.Response {
 	return response
 }
 
+// LogBatchDataset godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Log a batch of data for dataset
+//	@Description	Log a batch of key, data and type entries for dataset in a single transaction. No entry is logged if any is invalid. Returns the result of each entry in the order of the batch
+//	@Tags			Dataset
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/version/{version}/log/batch [post]
+//	@Param			orgId		path	string						true	"Organization Id"
+//	@Param			datasetName	path	string						true	"Dataset Name"
+//	@Param			branchName	path	string						true	"Branch Name"
+//	@Param			version		path	string						true	"Version"
+//	@Param			data		body	commonmodels.LogsRequest	true	"Data to log"
+func (api *Api) LogBatchDataset(request *models.Request, body io.Reader) *models.Response {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return models.NewErrorResponse(http.StatusRequestEntityTooLarge, fmt.Sprintf("Log batch exceeds the maximum size of %d bytes", maxBytesErr.Limit))
+		}
+		return models.NewServerErrorResponse(err)
+	}
+	var logsRequest commonmodels.LogsRequest
+	if err := json.Unmarshal(data, &logsRequest); err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if len(logsRequest.Logs) == 0 {
+		return models.NewErrorResponse(http.StatusBadRequest, "Logs are required")
+	}
+	if entries := validateLogBatch(logsRequest.Logs); entries != nil {
+		return models.NewDataResponse(http.StatusBadRequest, entries, "Invalid log entries")
+	}
+	result, err := api.app.Dao().CreateLogsForDatasetVersion(request.GetDatasetBranchVersionUUID(), logsRequest.Logs)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, result, "Logs created")
+}
+
 // LogFileDataset godoc
 //
 //	@Security		ApiKeyAuth
@@ -150,22 +199,19 @@ func (api *Api) LogFileDataset(request *models.Request) *models.Response {
 		return models.NewErrorResponse(http.StatusBadRequest, "File is required")
 	}
 	versionUUID := request.GetDatasetBranchVersionUUID()
-	sourceSecrets, errresp := api.ValidateSourceTypeAndGetSourceSecrets(datasetSourceSecretName, orgId)
+	sourceSecrets, errresp := api.GetSourceSecrets(datasetSourceSecretName, orgId)
 	if errresp != nil {
 		return errresp
 	}
-	datasetSourceType := sourceSecrets.SourceType
-	sourceValid := false
-	for source := range commonmodels.SupportedSources {
-		if commonmodels.SupportedSources[source] == datasetSourceType {
-			sourceValid = true
-			break
-		}
+	var logsSize int64
+	for _, fileHeader := range fileHeaders {
+		logsSize += fileHeader.Size
 	}
-	if !sourceValid {
-		return models.NewErrorResponse(http.StatusBadRequest, "Unsupported dataset storage")
+	softQuotaExceeded, errresp := api.CheckStorageQuota(orgId, logsSize)
+	if errresp != nil {
+		return errresp
 	}
-	logs := make(map[string]string)
+	logs := []commonmodels.LogRequest{}
 	for _, fileHeader := range fileHeaders {
 		name := fileHeader.Filename
 		originalExt := filepath.Ext(name)
@@ -174,25 +220,59 @@ func (api *Api) LogFileDataset(request *models.Request) *models.Response {
 		if err != nil {
 			return models.NewServerErrorResponse(err)
 		}
-		filePath, err := api.app.UploadFile(file, fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/logs", orgId, datasetUUID, datasetBranchUUID), sourceSecrets)
+		filePath, err := api.app.UploadFile(file, fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/logs", orgId, datasetUUID, datasetBranchUUID), sourceSecrets, "")
 		if err != nil {
 			return models.NewServerErrorResponse(err)
 		}
-		logs[key] = fmt.Sprintf("%s/%s", sourceSecrets.PublicURL, filePath)
-	}
-	var results []*models.LogResponse
-	for key, data := range logs {
-		result, err := api.app.Dao().CreateLogForDatasetVersion(key, data, versionUUID)
+		err = api.app.Dao().RecordDatasetStorageUsage(orgId, datasetUUID, uuid.NullUUID{UUID: versionUUID, Valid: true}, sourceSecrets.SourceType, sourceSecrets.PublicURL, filePath, fileHeader.Size)
 		if err != nil {
 			return models.NewServerErrorResponse(err)
 		}
-		results = append(results, result)
+		logs = append(logs, commonmodels.LogRequest{Key: key, Data: fmt.Sprintf("%s/%s", sourceSecrets.PublicURL, filePath)})
+	}
+	entries, err := api.app.Dao().CreateLogsForDatasetVersion(versionUUID, logs)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	var results []*models.LogResponse
+	for _, entry := range entries {
+		results = append(results, entry.Log)
 	}
-	response := models.NewDataResponse(http.StatusOK, results, "Logs created")
+	response := models.NewDataResponse(http.StatusOK, results, withSoftQuotaWarning("Logs created", softQuotaExceeded))
 	return response
 }
 
+// maxLogBatchSize returns the maximum size in bytes of the body of a log
+// batch.
+func (api *Api) maxLogBatchSize() int64 {
+	if maxSize := api.app.Settings().Logs.MaxBatchSize; maxSize > 0 {
+		return maxSize
+	}
+	return defaultMaxLogBatchSize
+}
+
+// validateLogBatch checks the entries of a log batch, returning the result
+// of each entry if any is invalid.
+func validateLogBatch(logs []commonmodels.LogRequest) []models.LogBatchEntryResponse {
+	invalid := false
+	entries := make([]models.LogBatchEntryResponse, 0, len(logs))
+	for i, log := range logs {
+		entry := models.LogBatchEntryResponse{Index: i, Key: log.Key}
+		if log.Key == "" {
+			entry.Status = models.LogInvalid
+			entry.Error = "Key is required"
+			invalid = true
+		}
+		entries = append(entries, entry)
+	}
+	if !invalid {
+		return nil
+	}
+	return entries
+}
+
 var GetAllLogsDataset ServiceFunc = (*Api).GetAllLogsDataset
 var GetKeyLogsDataset ServiceFunc = (*Api).GetKeyLogsDataset
 var LogDataset ServiceFunc = (*Api).LogDataset
+var LogBatchDataset ChunkServiceFunc = (*Api).LogBatchDataset
 var LogFileDataset ServiceFunc = (*Api).LogFileDataset
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
+	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// presignedURLExpiry is the validity duration of the minted presigned urls.
+const presignedURLExpiry = 15 * time.Minute
+
+// BindDatasetPresignedApi registers the admin api endpoints and the corresponding handlers.
+func BindDatasetPresignedApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	datasetGroup := rg.Group("/org/:orgId/dataset", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	datasetGroup.POST("/:datasetName/branch/:branchName/presigned-upload", api.DefaultHandler(CreateDatasetPresignedUpload), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.PUT("/:datasetName/branch/:branchName/presigned-upload/:uploadId", api.FileUploadHandler(UploadDatasetPresignedFile), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.POST("/:datasetName/branch/:branchName/presigned-upload/:uploadId/confirm", api.DefaultHandler(ConfirmDatasetPresignedUpload), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.GET("/:datasetName/branch/:branchName/version/:version/presigned-download", api.DefaultHandler(GetDatasetPresignedDownload), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
+}
+
+// CreateDatasetPresignedUpload godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Create presigned dataset upload url
+//	@Description	Create a short-lived presigned PUT url to upload the dataset file directly to the S3/R2 bucket of the storage. For other storages the url points to the proxied upload endpoint which requires the usual authorization header. The dataset version is registered with the confirm endpoint once the file is uploaded
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/presigned-upload [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			datasetName	path	string								true	"Dataset Name"
+//	@Param			branchName	path	string								true	"Branch Name"
+//	@Param			data		body	commonmodels.UploadSessionRequest	true	"Upload details"
+func (api *Api) CreateDatasetPresignedUpload(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	datasetBranchUUID := request.GetDatasetBranchUUID()
+	request.ParseJsonBody()
+	fileName, _ := request.GetParsedBodyAttribute("file_name").(string)
+	if fileName == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "File name is required")
+	}
+	storage, _ := request.GetParsedBodyAttribute("storage").(string)
+	sourceSecrets, errresp := api.GetSourceSecrets(storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	session, err := api.app.Dao().CreateDatasetUploadSession(orgId, datasetBranchUUID, filepath.Base(fileName), storage, userUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	presigned := &models.PresignedURLResponse{
+		Method:    http.MethodPut,
+		ExpiresAt: time.Now().Add(presignedURLExpiry),
+		Upload:    session,
+	}
+	if supportsPresignedURLs(sourceSecrets) {
+		fs, err := api.app.NewFilesystem(sourceSecrets)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		defer fs.Close()
+		presigned.URL, err = fs.SignedURL(datasetUploadFileKey(request, session), http.MethodPut, presignedURLExpiry)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+	} else {
+		presigned.Proxied = true
+		presigned.URL = fmt.Sprintf("/api/org/%s/dataset/%s/branch/%s/presigned-upload/%s", orgId, url.PathEscape(request.GetDatasetName()), url.PathEscape(request.GetDatasetBranchName()), session.UUID)
+	}
+	return models.NewDataResponse(http.StatusOK, presigned, "Dataset presigned upload url created")
+}
+
+// UploadDatasetPresignedFile godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Upload dataset file of a presigned upload
+//	@Description	Proxied fallback of the presigned upload url for storages that don't support presigned urls
+//	@Tags			Dataset
+//	@Accept			application/octet-stream
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/presigned-upload/{uploadId} [put]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			uploadId	path	string	true	"Upload session UUID"
+func (api *Api) UploadDatasetPresignedFile(request *models.Request, body io.Reader) *models.Response {
+	orgId := request.GetOrgId()
+	session, errresp := api.getDatasetUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	if session.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Upload session already finalized")
+	}
+	sourceSecrets, errresp := api.GetSourceSecrets(session.Storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	size, err := fs.UploadReader(body, datasetUploadFileKey(request, session))
+	if err != nil {
+		return fileUploadErrorResponse(err)
+	}
+	if size == 0 {
+		fs.Delete(datasetUploadFileKey(request, session))
+		return models.NewErrorResponse(http.StatusBadRequest, "File is empty")
+	}
+	return models.NewDataResponse(http.StatusOK, nil, "Dataset file uploaded")
+}
+
+// ConfirmDatasetPresignedUpload godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Confirm presigned dataset upload
+//	@Description	Verify the size and hash of the uploaded dataset file and register it as a new dataset version
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/presigned-upload/{uploadId}/confirm [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			datasetName	path	string								true	"Dataset Name"
+//	@Param			branchName	path	string								true	"Branch Name"
+//	@Param			uploadId	path	string								true	"Upload session UUID"
+//	@Param			data		body	commonmodels.ConfirmUploadRequest	true	"Expected hash, size and lineage"
+func (api *Api) ConfirmDatasetPresignedUpload(request *models.Request) *models.Response {
+	datasetBranchUUID := request.GetDatasetBranchUUID()
+	session, errresp := api.getDatasetUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	if session.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Upload session already finalized")
+	}
+	request.ParseJsonBody()
+	datasetHash, _ := request.GetParsedBodyAttribute("hash").(string)
+	if datasetHash == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Hash is required")
+	}
+	hashAlgorithm, _ := request.GetParsedBodyAttribute("hash_algorithm").(string)
+	digester, errresp := api.NewDigester(hashAlgorithm)
+	if errresp != nil {
+		return errresp
+	}
+	datasetSize, _ := request.GetParsedBodyAttribute("size").(float64)
+	datasetLineage, _ := request.GetParsedBodyAttribute("lineage").(string)
+	if errresp := api.validateDatasetBranchRegistration(request); errresp != nil {
+		return errresp
+	}
+	if errresp := api.checkDatasetVersionHash(datasetBranchUUID, datasetHash); errresp != nil {
+		return errresp
+	}
+	if errresp := api.claimUploadSession(session.UUID); errresp != nil {
+		return errresp
+	}
+	response := api.registerDatasetPresignedUpload(request, session, datasetHash, digester, int64(datasetSize), datasetLineage)
+	if response.Body.Status != http.StatusOK {
+		// reopen the session so that the confirmation can be retried
+		api.app.Dao().ReleaseUploadSession(session.UUID)
+	}
+	return response
+}
+
+// registerDatasetPresignedUpload verifies the file uploaded for the claimed
+// session and registers it as a new version of the branch.
+func (api *Api) registerDatasetPresignedUpload(request *models.Request, session *models.UploadSessionResponse, datasetHash string, digester *digest.Digester, datasetSize int64, datasetLineage string) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	datasetBranchUUID := request.GetDatasetBranchUUID()
+	sourceSecrets, errresp := api.GetSourceSecrets(session.Storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	filePath := datasetUploadFileKey(request, session)
+	exists, err := fs.Exists(filePath)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if !exists {
+		return models.NewErrorResponse(http.StatusNotFound, "Uploaded file not found")
+	}
+	attrs, err := fs.Attributes(filePath)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if datasetSize > 0 && attrs.Size != datasetSize {
+		return models.NewErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("Size mismatch: expected %d bytes, uploaded %d bytes", datasetSize, attrs.Size))
+	}
+	_, err = fs.Hash(filePath, digester.Hashes()...)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	errresp = api.VerifyUploadedFileHash(digester, datasetHash, filePath, sourceSecrets)
+	if errresp != nil {
+		return errresp
+	}
+	dataKey, err := encryption.NewDataKey(api.app, orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if dataKey.Key != "" {
+		// the file was uploaded as is, bypassing the server
+		encryptedPath := filePath + ".encrypted"
+		if _, err := fs.CopyTo(fs.Encrypted(dataKey.Key), filePath, encryptedPath); err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		fs.Delete(filePath)
+		filePath = encryptedPath
+	}
+	blob, softQuotaExceeded, errresp := api.StoreUploadedBlob(orgId, request.GetDatasetUUID(), sourceSecrets, filePath, digester.Sha256(), attrs.Size, dataKey)
+	if errresp != nil {
+		return errresp
+	}
+	datasetVersion, err := api.app.Dao().RegisterDatasetFile(datasetBranchUUID, sourceSecrets.SourceType, sourceSecrets.PublicURL, blob.Key, false, digester.Sum(), digester.Algorithm, blob.Digest, session.FileName, uuid.NullUUID{UUID: blob.UUID, Valid: true}, blob.DataKey, datasetLineage, userUUID)
+	if err != nil {
+		api.ReleaseBlob(sourceSecrets, blob.UUID)
+		return registerVersionErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, datasetVersion, withSoftQuotaWarning("Dataset successfully registered", softQuotaExceeded))
+}
+
+// GetDatasetPresignedDownload godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get presigned dataset download url
+//	@Description	Get a short-lived presigned GET url to download the dataset version file directly from the S3/R2 bucket. For other storages the url points to the proxied download endpoint which requires the usual authorization header
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/version/{version}/presigned-download [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) GetDatasetPresignedDownload(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	version, err := api.app.Dao().GetDatasetBranchVersion(request.GetDatasetBranchUUID(), request.GetDatasetBranchVersionName())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	if version.IsManifest {
+		return models.NewErrorResponse(http.StatusBadRequest, "Dataset version has multiple files, download them individually")
+	}
+	if version.IsEmpty || version.Path == "" {
+		return models.NewErrorResponse(http.StatusNotFound, "Dataset version has no file")
+	}
+	sourceSecrets, fileKey, errresp := api.GetStoredFileSourceSecrets(version.SourceType, version.Path, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	presigned := &models.PresignedURLResponse{
+		Method:    http.MethodGet,
+		ExpiresAt: time.Now().Add(presignedURLExpiry),
+	}
+	// encrypted files are decrypted by the proxied download
+	if supportsPresignedURLs(sourceSecrets) && !version.Encrypted {
+		fs, err := api.app.NewFilesystem(sourceSecrets)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		defer fs.Close()
+		presigned.URL, err = fs.SignedURL(fileKey, http.MethodGet, presignedURLExpiry)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+	} else {
+		presigned.Proxied = true
+		presigned.URL = fmt.Sprintf("/api/org/%s/dataset/%s/branch/%s/version/%s/download", orgId, url.PathEscape(request.GetDatasetName()), url.PathEscape(request.GetDatasetBranchName()), version.Version)
+	}
+	return models.NewDataResponse(http.StatusOK, presigned, "Dataset presigned download url created")
+}
+
+// supportsPresignedURLs reports whether files of the source can be
+// accessed directly through presigned urls.
+func supportsPresignedURLs(sourceSecrets *commonmodels.SourceSecrets) bool {
+	sourceType := strings.ToUpper(sourceSecrets.SourceType)
+	return sourceType == "S3" || sourceType == "R2" || sourceType == "PUREML-STORAGE"
+}
+
+func datasetUploadFileKey(request *models.Request, session *models.UploadSessionResponse) string {
+	return fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/%s_%s", request.GetOrgId(), request.GetDatasetUUID(), request.GetDatasetBranchUUID(), session.UUID, session.FileName)
+}
+
+var CreateDatasetPresignedUpload ServiceFunc = (*Api).CreateDatasetPresignedUpload
+var UploadDatasetPresignedFile ChunkServiceFunc = (*Api).UploadDatasetPresignedFile
+var ConfirmDatasetPresignedUpload ServiceFunc = (*Api).ConfirmDatasetPresignedUpload
+var GetDatasetPresignedDownload ServiceFunc = (*Api).GetDatasetPresignedDownload
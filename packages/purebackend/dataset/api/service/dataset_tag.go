@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	datasetmodels "github.com/PureMLHQ/PureML/packages/purebackend/dataset/models"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// maxTagLength is the maximum length of a version tag.
+const maxTagLength = 128
+
+// BindDatasetTagApi registers the dataset version tag api endpoints and the corresponding handlers.
+func BindDatasetTagApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	datasetGroup := rg.Group("/org/:orgId/dataset", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	datasetGroup.GET("/:datasetName/tag/:tag", api.DefaultHandler(GetDatasetTaggedVersions), middlewares.ValidateDataset(api.app))
+	datasetGroup.GET("/:datasetName/branch/:branchName/version/:version/tags", api.DefaultHandler(GetDatasetVersionTags), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
+	datasetGroup.POST("/:datasetName/branch/:branchName/version/:version/tags", api.DefaultHandler(AddDatasetVersionTags), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
+}
+
+// GetDatasetTaggedVersions godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the versions of a dataset with a tag
+//	@Description	Get the versions of every branch of a dataset with the tag, latest first
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/tag/{tag} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			tag			path	string	true	"Tag"
+func (api *Api) GetDatasetTaggedVersions(request *models.Request) *models.Response {
+	versions, err := api.app.Dao().GetDatasetTaggedVersions(request.GetDatasetUUID(), request.GetPathParam("tag"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, versions, "Dataset versions with tag")
+}
+
+// GetDatasetVersionTags godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the tags of a dataset version
+//	@Description	Get the tags of a dataset version
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/version/{version}/tags [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) GetDatasetVersionTags(request *models.Request) *models.Response {
+	tags, err := api.app.Dao().GetDatasetVersionTags(request.GetDatasetBranchVersionUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, tags, "Dataset version tags")
+}
+
+// AddDatasetVersionTags godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Tag a dataset version
+//	@Description	Add free-form tags to a dataset version. Tags are immutable, the tags the version already has are kept
+//	@Tags			Dataset
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/version/{version}/tags [post]
+//	@Param			orgId		path	string							true	"Organization Id"
+//	@Param			datasetName	path	string							true	"Dataset Name"
+//	@Param			branchName	path	string							true	"Branch Name"
+//	@Param			version		path	string							true	"Version"
+//	@Param			data		body	commonmodels.VersionTagsRequest	true	"Tags"
+func (api *Api) AddDatasetVersionTags(request *models.Request) *models.Response {
+	request.ParseJsonBody()
+	tags, errresp := parseVersionTags(request.GetParsedBodyAttribute("tags"))
+	if errresp != nil {
+		return errresp
+	}
+	allTags, err := api.app.Dao().AddDatasetVersionTags(request.GetOrgId(), request.GetDatasetBranchVersionUUID(), tags, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, allTags, "Dataset version tagged")
+}
+
+// parseVersionTags validates the tags of the request body, dropping the
+// surrounding spaces and duplicates.
+func parseVersionTags(value interface{}) ([]string, *models.Response) {
+	values, ok := value.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Tags are required")
+	}
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		tag, ok := value.(string)
+		tag = strings.TrimSpace(tag)
+		if !ok || tag == "" || len(tag) > maxTagLength {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid tag %v", value))
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// filterDatasetVersionsByTag keeps the versions with the tag.
+func (api *Api) filterDatasetVersionsByTag(datasetUUID uuid.UUID, versions []datasetmodels.DatasetBranchVersionResponse, tag string) ([]datasetmodels.DatasetBranchVersionResponse, error) {
+	taggedVersions, err := api.app.Dao().GetDatasetTaggedVersions(datasetUUID, tag)
+	if err != nil {
+		return nil, err
+	}
+	tagged := map[uuid.UUID]bool{}
+	for _, version := range taggedVersions {
+		tagged[version.UUID] = true
+	}
+	filtered := []datasetmodels.DatasetBranchVersionResponse{}
+	for _, version := range versions {
+		if tagged[version.UUID] {
+			filtered = append(filtered, version)
+		}
+	}
+	return filtered, nil
+}
+
+var GetDatasetTaggedVersions ServiceFunc = (*Api).GetDatasetTaggedVersions
+var GetDatasetVersionTags ServiceFunc = (*Api).GetDatasetVersionTags
+var AddDatasetVersionTags ServiceFunc = (*Api).AddDatasetVersionTags
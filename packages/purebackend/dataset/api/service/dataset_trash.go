@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/trash"
+	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindDatasetTrashApi registers the dataset and dataset version delete api endpoints and the corresponding handlers.
+func BindDatasetTrashApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	datasetGroup := rg.Group("/org/:orgId/dataset", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	datasetGroup.DELETE("/:datasetName/delete", api.DefaultHandler(DeleteDataset), middlewares.ValidateDataset(api.app))
+	datasetGroup.DELETE("/:datasetName/branch/:branchName/version/:version/delete", api.DefaultHandler(DeleteDatasetVersion), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app), middlewares.ValidateDatasetBranchVersion(api.app))
+}
+
+// DeleteDataset godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Delete a dataset
+//	@Description	Move a dataset with all its branches and versions to the trash of the organization. It can be restored until it is purged. A dataset referenced by an alias or an open review cannot be deleted
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/delete [delete]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+func (api *Api) DeleteDataset(request *models.Request) *models.Response {
+	if errresp := api.validateDatasetUnreferenced(request, "Dataset", uuid.NullUUID{}, uuid.NullUUID{}); errresp != nil {
+		return errresp
+	}
+	item, err := api.app.Dao().TrashDataset(request.GetOrgId(), request.GetDatasetUUID(), request.GetDatasetName(), request.GetUserUUID(), trash.PurgeTime(api.app))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, item, "Dataset moved to trash")
+}
+
+// DeleteDatasetVersion godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Delete a version of a dataset branch
+//	@Description	Move a version of a dataset branch to the trash of the organization. It can be restored until it is purged, its files are removed from the storage when it is purged. A version referenced by an alias or an open review cannot be deleted
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/version/{version}/delete [delete]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) DeleteDatasetVersion(request *models.Request) *models.Response {
+	version, err := api.app.Dao().GetDatasetBranchVersion(request.GetDatasetBranchUUID(), request.GetPathParam("version"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if errresp := api.validateDatasetUnreferenced(request, "Version", uuid.NullUUID{}, uuid.NullUUID{UUID: version.UUID, Valid: true}); errresp != nil {
+		return errresp
+	}
+	name := fmt.Sprintf("%s/%s/%s", request.GetDatasetName(), request.GetDatasetBranchName(), version.Version)
+	item, err := api.app.Dao().TrashDatasetVersion(request.GetOrgId(), version.UUID, name, request.GetUserUUID(), trash.PurgeTime(api.app))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, item, "Dataset version moved to trash")
+}
+
+func (api *Api) validateDatasetUnreferenced(request *models.Request, subject string, branchUUID uuid.NullUUID, versionUUID uuid.NullUUID) *models.Response {
+	references, err := api.app.Dao().GetDatasetReferences(request.GetDatasetUUID(), branchUUID, versionUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if len(references.Aliases) > 0 {
+		return models.NewErrorResponse(http.StatusConflict, fmt.Sprintf("%s is referenced by the alias %s", subject, references.Aliases[0]))
+	}
+	if references.OpenReviews > 0 {
+		return models.NewErrorResponse(http.StatusConflict, fmt.Sprintf("%s is referenced by an open review", subject))
+	}
+	return nil
+}
+
+var DeleteDataset ServiceFunc = (*Api).DeleteDataset
+var DeleteDatasetVersion ServiceFunc = (*Api).DeleteDatasetVersion
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
+	"github.com/PureMLHQ/PureML/packages/purebackend/dataset/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindDatasetUploadApi registers the admin api endpoints and the corresponding handlers.
+func BindDatasetUploadApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	datasetGroup := rg.Group("/org/:orgId/dataset", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	datasetGroup.POST("/:datasetName/branch/:branchName/upload", api.DefaultHandler(CreateDatasetUploadSession), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.GET("/:datasetName/branch/:branchName/upload/:uploadId", api.DefaultHandler(GetDatasetUploadSession), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.PUT("/:datasetName/branch/:branchName/upload/:uploadId/chunk/:chunkNumber", api.ChunkHandler(UploadDatasetChunk), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+	datasetGroup.POST("/:datasetName/branch/:branchName/upload/:uploadId/finalize", api.DefaultHandler(FinalizeDatasetUpload), middlewares.ValidateDataset(api.app), middlewares.ValidateDatasetBranch(api.app))
+}
+
+// CreateDatasetUploadSession godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Create dataset upload session
+//	@Description	Create a resumable upload session for a dataset file. Chunks are uploaded separately and the dataset version is registered on finalize
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/upload [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			datasetName	path	string								true	"Dataset Name"
+//	@Param			branchName	path	string								true	"Branch Name"
+//	@Param			data		body	commonmodels.UploadSessionRequest	true	"Upload session details"
+func (api *Api) CreateDatasetUploadSession(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	datasetBranchUUID := request.GetDatasetBranchUUID()
+	request.ParseJsonBody()
+	fileName, _ := request.GetParsedBodyAttribute("file_name").(string)
+	if fileName == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "File name is required")
+	}
+	storage, _ := request.GetParsedBodyAttribute("storage").(string)
+	_, errresp := api.GetSourceSecrets(storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	session, err := api.app.Dao().CreateDatasetUploadSession(orgId, datasetBranchUUID, filepath.Base(fileName), storage, userUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, session, "Dataset upload session created")
+}
+
+// GetDatasetUploadSession godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get dataset upload session
+//	@Description	Get dataset upload session details along with the received chunk ranges
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/upload/{uploadId} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			uploadId	path	string	true	"Upload session UUID"
+func (api *Api) GetDatasetUploadSession(request *models.Request) *models.Response {
+	session, errresp := api.getDatasetUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	return models.NewDataResponse(http.StatusOK, session, "Dataset upload session details")
+}
+
+// UploadDatasetChunk godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Upload dataset file chunk
+//	@Description	Upload a numbered chunk of the dataset file. Uploading the same chunk number again replaces it
+//	@Tags			Dataset
+//	@Accept			application/octet-stream
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/upload/{uploadId}/chunk/{chunkNumber} [put]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			datasetName	path	string	true	"Dataset Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			uploadId	path	string	true	"Upload session UUID"
+//	@Param			chunkNumber	path	int		true	"Chunk number (starting from 1)"
+func (api *Api) UploadDatasetChunk(request *models.Request, body io.Reader) *models.Response {
+	orgId := request.GetOrgId()
+	session, errresp := api.getDatasetUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	if session.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Upload session already finalized")
+	}
+	chunkNumber, err := strconv.Atoi(request.GetPathParam("chunkNumber"))
+	if err != nil || chunkNumber < 1 {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid chunk number")
+	}
+	sourceSecrets, errresp := api.GetSourceSecrets(session.Storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	size, err := fs.UploadReader(body, datasetUploadChunkKey(request, session.UUID, chunkNumber))
+	if err != nil {
+		return chunkUploadErrorResponse(err)
+	}
+	if size == 0 {
+		fs.Delete(datasetUploadChunkKey(request, session.UUID, chunkNumber))
+		return models.NewErrorResponse(http.StatusBadRequest, "Chunk is empty")
+	}
+	chunk, err := api.app.Dao().CreateUploadChunk(session.UUID, chunkNumber, size)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, chunk, "Dataset file chunk uploaded")
+}
+
+// FinalizeDatasetUpload godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Finalize dataset upload session
+//	@Description	Assemble the uploaded chunks into the dataset file and register it as a new dataset version
+//	@Tags			Dataset
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/dataset/{datasetName}/branch/{branchName}/upload/{uploadId}/finalize [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			datasetName	path	string								true	"Dataset Name"
+//	@Param			branchName	path	string								true	"Branch Name"
+//	@Param			uploadId	path	string								true	"Upload session UUID"
+//	@Param			data		body	commonmodels.FinalizeUploadRequest	true	"Expected hash and lineage"
+func (api *Api) FinalizeDatasetUpload(request *models.Request) *models.Response {
+	datasetBranchUUID := request.GetDatasetBranchUUID()
+	session, errresp := api.getDatasetUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	if session.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Upload session already finalized")
+	}
+	request.ParseJsonBody()
+	datasetHash, _ := request.GetParsedBodyAttribute("hash").(string)
+	if datasetHash == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Hash is required")
+	}
+	hashAlgorithm, _ := request.GetParsedBodyAttribute("hash_algorithm").(string)
+	digester, errresp := api.NewDigester(hashAlgorithm)
+	if errresp != nil {
+		return errresp
+	}
+	datasetLineage, _ := request.GetParsedBodyAttribute("lineage").(string)
+	if errresp := api.validateDatasetBranchRegistration(request); errresp != nil {
+		return errresp
+	}
+	if errresp := api.checkDatasetVersionHash(datasetBranchUUID, datasetHash); errresp != nil {
+		return errresp
+	}
+	if len(session.Chunks) == 0 {
+		return models.NewErrorResponse(http.StatusBadRequest, "No chunks uploaded")
+	}
+	var chunkKeys []string
+	for i, chunk := range session.Chunks {
+		if chunk.Number != i+1 {
+			return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Missing chunk %d", i+1))
+		}
+		chunkKeys = append(chunkKeys, datasetUploadChunkKey(request, session.UUID, chunk.Number))
+	}
+	if errresp := api.claimUploadSession(session.UUID); errresp != nil {
+		return errresp
+	}
+	response := api.registerDatasetUpload(request, session, datasetHash, digester, datasetLineage, chunkKeys)
+	if response.Body.Status != http.StatusOK {
+		// reopen the session so that the finalize can be retried
+		api.app.Dao().ReleaseUploadSession(session.UUID)
+	}
+	return response
+}
+
+// registerDatasetUpload assembles the chunks of the claimed upload session
+// and registers the dataset file as a new version of the branch.
+func (api *Api) registerDatasetUpload(request *models.Request, session *models.UploadSessionResponse, datasetHash string, digester *digest.Digester, datasetLineage string, chunkKeys []string) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	datasetUUID := request.GetDatasetUUID()
+	datasetBranchUUID := request.GetDatasetBranchUUID()
+	sourceSecrets, errresp := api.GetSourceSecrets(session.Storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	dataKey, err := encryption.NewDataKey(api.app, orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	filePath := datasetUploadFileKey(request, session)
+	err = fs.Encrypted(dataKey.Key).Compose(chunkKeys, filePath, session.FileName, digester.Hashes()...)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	// on mismatch the chunks are kept so that the bad ones can be uploaded again
+	errresp = api.VerifyUploadedFileHash(digester, datasetHash, filePath, sourceSecrets)
+	if errresp != nil {
+		return errresp
+	}
+	attrs, err := fs.Attributes(filePath)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	blob, softQuotaExceeded, errresp := api.StoreUploadedBlob(orgId, datasetUUID, sourceSecrets, filePath, digester.Sha256(), attrs.Size, dataKey)
+	if errresp != nil {
+		return errresp
+	}
+	datasetVersion, err := api.app.Dao().RegisterDatasetFile(datasetBranchUUID, sourceSecrets.SourceType, sourceSecrets.PublicURL, blob.Key, false, digester.Sum(), digester.Algorithm, blob.Digest, session.FileName, uuid.NullUUID{UUID: blob.UUID, Valid: true}, blob.DataKey, datasetLineage, userUUID)
+	if err != nil {
+		api.ReleaseBlob(sourceSecrets, blob.UUID)
+		return registerVersionErrorResponse(err)
+	}
+	// chunks are kept until the version is registered so that a failed
+	// finalize can be retried
+	fs.DeletePrefix(fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/uploads/%s", orgId, datasetUUID, datasetBranchUUID, session.UUID))
+	return models.NewDataResponse(http.StatusOK, datasetVersion, withSoftQuotaWarning("Dataset successfully registered", softQuotaExceeded))
+}
+
+func (api *Api) getDatasetUploadSession(request *models.Request) (*models.UploadSessionResponse, *models.Response) {
+	sessionUUID, err := uuid.FromString(request.GetPathParam("uploadId"))
+	if err != nil {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Invalid upload session id")
+	}
+	session, err := api.app.Dao().GetDatasetUploadSession(request.GetDatasetBranchUUID(), sessionUUID)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	if session == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Upload session not found")
+	}
+	return session, nil
+}
+
+func datasetUploadChunkKey(request *models.Request, sessionUUID uuid.UUID, chunkNumber int) string {
+	return fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/uploads/%s/%d", request.GetOrgId(), request.GetDatasetUUID(), request.GetDatasetBranchUUID(), sessionUUID, chunkNumber)
+}
+
+var CreateDatasetUploadSession ServiceFunc = (*Api).CreateDatasetUploadSession
+var GetDatasetUploadSession ServiceFunc = (*Api).GetDatasetUploadSession
+var UploadDatasetChunk ChunkServiceFunc = (*Api).UploadDatasetChunk
+var FinalizeDatasetUpload ServiceFunc = (*Api).FinalizeDatasetUpload
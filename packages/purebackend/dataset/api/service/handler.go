@@ -1,19 +1,40 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
 
 	coreservice "github.com/PureMLHQ/PureML/packages/purebackend/core/apis/service"
+	impl "github.com/PureMLHQ/PureML/packages/purebackend/core/daos/datastore"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/gc"
 	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/stream"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
 	"github.com/labstack/echo/v4"
 	uuid "github.com/satori/go.uuid"
 )
 
 type ServiceFunc func(*Api, *models.Request) *models.Response
 
+type FileServiceFunc func(*Api, *models.Request) (*models.FileResponse, *models.Response)
+
+type StreamServiceFunc func(*Api, *models.Request) (*models.EventStream, *models.Response)
+
+type ChunkServiceFunc func(*Api, *models.Request, io.Reader) *models.Response
+
+// defaultMaxUploadChunkSize is the maximum size of an uploaded chunk unless configured.
+const defaultMaxUploadChunkSize = 64 << 20
+
+// defaultMaxUploadFileSize is the maximum size of a file uploaded through the
+// proxied presigned upload unless configured, that of a single S3 upload.
+const defaultMaxUploadFileSize = 5 << 30
+
 func (api *Api) DefaultHandler(f ServiceFunc) echo.HandlerFunc {
 	return func(context echo.Context) error {
 		request := coreservice.ExtractRequest(context)
@@ -28,6 +49,181 @@ func (api *Api) DefaultHandler(f ServiceFunc) echo.HandlerFunc {
 	}
 }
 
+// FileHandler streams the file resolved by f from its storage.
+// Range, If-Range and If-None-Match requests are handled by the filesystem.
+func (api *Api) FileHandler(f FileServiceFunc) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		request := coreservice.ExtractRequest(context)
+		file, response := f(api, request)
+		if response == nil {
+			response = api.serveFile(context, file)
+		}
+		if response == nil {
+			return nil
+		}
+		responseWriter := context.Response().Writer
+		if response.Error != nil {
+			populateErrorResponse(context, response, responseWriter)
+		} else {
+			populateSuccessResponse(context, response, responseWriter)
+		}
+		return nil
+	}
+}
+
+// ChunkHandler passes the request body to f as a stream limited to the
+// maximum chunk size instead of reading it in memory.
+func (api *Api) ChunkHandler(f ChunkServiceFunc) echo.HandlerFunc {
+	return api.streamBodyHandler(f, api.maxUploadChunkSize)
+}
+
+// FileUploadHandler passes the request body to f as a stream limited to the
+// maximum file size instead of reading it in memory.
+func (api *Api) FileUploadHandler(f ChunkServiceFunc) echo.HandlerFunc {
+	return api.streamBodyHandler(f, api.maxUploadFileSize)
+}
+
+// LogBatchHandler passes the request body to f as a stream limited to the
+// maximum log batch size, so that oversized batches are rejected while read.
+func (api *Api) LogBatchHandler(f ChunkServiceFunc) echo.HandlerFunc {
+	return api.streamBodyHandler(f, api.maxLogBatchSize)
+}
+
+func (api *Api) streamBodyHandler(f ChunkServiceFunc, maxSize func() int64) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		request := coreservice.ExtractStreamRequest(context)
+		body := http.MaxBytesReader(context.Response(), context.Request().Body, maxSize())
+		defer body.Close()
+		response := f(api, request, body)
+		responseWriter := context.Response().Writer
+		if response.Error != nil {
+			populateErrorResponse(context, response, responseWriter)
+		} else {
+			populateSuccessResponse(context, response, responseWriter)
+		}
+		return nil
+	}
+}
+
+// maxUploadChunkSize returns the configured maximum size of an uploaded chunk.
+func (api *Api) maxUploadChunkSize() int64 {
+	if maxSize := api.app.Settings().Upload.MaxChunkSize; maxSize > 0 {
+		return maxSize
+	}
+	return defaultMaxUploadChunkSize
+}
+
+// chunkUploadErrorResponse returns the response of a chunk that couldn't be
+// stored, 413 if it exceeds the maximum chunk size.
+func chunkUploadErrorResponse(err error) *models.Response {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return models.NewErrorResponse(http.StatusRequestEntityTooLarge, fmt.Sprintf("Chunk exceeds the maximum size of %d bytes", maxBytesErr.Limit))
+	}
+	return models.NewServerErrorResponse(err)
+}
+
+// maxUploadFileSize returns the configured maximum size of a file uploaded
+// through the proxied presigned upload.
+func (api *Api) maxUploadFileSize() int64 {
+	if maxSize := api.app.Settings().Upload.MaxFileSize; maxSize > 0 {
+		return maxSize
+	}
+	return defaultMaxUploadFileSize
+}
+
+// fileUploadErrorResponse returns the response of a file that couldn't be
+// stored, 413 if it exceeds the maximum file size.
+func fileUploadErrorResponse(err error) *models.Response {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return models.NewErrorResponse(http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds the maximum size of %d bytes", maxBytesErr.Limit))
+	}
+	return models.NewServerErrorResponse(err)
+}
+
+// registerVersionErrorResponse returns the response of a version that
+// couldn't be registered, 400 if its hash is already on the branch.
+func registerVersionErrorResponse(err error) *models.Response {
+	if errors.Is(err, impl.ErrVersionHashConflict) {
+		return models.NewErrorResponse(http.StatusBadRequest, "Dataset with this hash already exists")
+	}
+	return models.NewServerErrorResponse(err)
+}
+
+// checkDatasetVersionHash returns a 400 response if a version of the branch,
+// including one in the trash, already has the hash.
+func (api *Api) checkDatasetVersionHash(datasetBranchUUID uuid.UUID, hash string) *models.Response {
+	exists, trashed, err := api.app.Dao().FindDatasetVersionHash(datasetBranchUUID, hash)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if trashed {
+		return models.NewErrorResponse(http.StatusBadRequest, "Dataset with this hash already exists in the trash")
+	}
+	if exists {
+		return models.NewErrorResponse(http.StatusBadRequest, "Dataset with this hash already exists")
+	}
+	return nil
+}
+
+// claimUploadSession claims the upload session for a finalize, so that
+// concurrent finalize requests don't register it twice.
+func (api *Api) claimUploadSession(sessionUUID uuid.UUID) *models.Response {
+	claimed, err := api.app.Dao().ClaimUploadSession(sessionUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if !claimed {
+		return models.NewErrorResponse(http.StatusConflict, "Upload session already finalized")
+	}
+	return nil
+}
+
+// StreamHandler serves the event stream resolved by f as server-sent events
+// until the client disconnects.
+func (api *Api) StreamHandler(f StreamServiceFunc) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		request := coreservice.ExtractRequest(context)
+		eventStream, response := f(api, request)
+		if response == nil {
+			return stream.Serve(context.Request().Context(), api.app, context.Response(), eventStream)
+		}
+		responseWriter := context.Response().Writer
+		if response.Error != nil {
+			populateErrorResponse(context, response, responseWriter)
+		} else {
+			populateSuccessResponse(context, response, responseWriter)
+		}
+		return nil
+	}
+}
+
+func (api *Api) serveFile(context echo.Context, file *models.FileResponse) *models.Response {
+	fs, err := api.app.NewFilesystem(file.SourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	exists, err := fs.Exists(file.Key)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if !exists {
+		return models.NewErrorResponse(http.StatusNotFound, "File not found in storage")
+	}
+	if file.ETag != "" {
+		context.Response().Header().Set("ETag", `"`+file.ETag+`"`)
+	}
+	// downloads are authorized per request so they must not be shared by caches
+	context.Response().Header().Set("Cache-Control", "private, no-cache")
+	err = fs.Encrypted(file.DataKey).Serve(context.Response(), context.Request(), file.Key, file.Name)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return nil
+}
+
 func populateSuccessResponse(context echo.Context, response *models.Response, responseWriter http.ResponseWriter) {
 	context.Response().WriteHeader(response.StatusCode)
 	_, err := responseWriter.Write(coreservice.ConvertToBytes(response.Body))
@@ -50,8 +246,10 @@ func (api *Api) ValidateSourceTypeAndGetSourceSecrets(datasetSourceSecretName st
 	var sourceSecrets *commonmodels.SourceSecrets
 	var err error
 	if strings.ToUpper(datasetSourceSecretName) == "PUREML-STORAGE" {
-		sourceSecrets, err = api.app.Dao().GetSecretByName(uuid.Must(uuid.FromString("11111111-1111-1111-1111-111111111111")), "admin")
-		sourceSecrets.SourceType = "PUREML-STORAGE"
+		sourceSecrets, err = api.app.ManagedStorageSecrets()
+		if err != nil {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, "PureML storage is not configured for this instance")
+		}
 	} else {
 		sourceSecrets, err = api.app.Dao().GetSecretByName(orgId, datasetSourceSecretName)
 	}
@@ -60,3 +258,270 @@ func (api *Api) ValidateSourceTypeAndGetSourceSecrets(datasetSourceSecretName st
 	}
 	return sourceSecrets, nil
 }
+
+// GetSourceSecrets resolves the storage name to the source secrets
+// of the organization. LOCAL storage doesn't need any secrets.
+func (api *Api) GetSourceSecrets(storage string, orgId uuid.UUID) (*commonmodels.SourceSecrets, *models.Response) {
+	var sourceSecrets *commonmodels.SourceSecrets
+	if strings.ToUpper(storage) == "LOCAL" {
+		sourceSecrets = &commonmodels.SourceSecrets{
+			SourceType: "LOCAL",
+		}
+	} else {
+		var errresp *models.Response
+		sourceSecrets, errresp = api.ValidateSourceTypeAndGetSourceSecrets(storage, orgId)
+		if errresp != nil {
+			return nil, errresp
+		}
+	}
+	for _, source := range commonmodels.SupportedSources {
+		if source == sourceSecrets.SourceType {
+			return sourceSecrets, nil
+		}
+	}
+	return nil, models.NewErrorResponse(http.StatusBadRequest, "Unsupported dataset storage")
+}
+
+// NewDigester creates a digester for the requested hash algorithm
+// (sha256 if empty) to compute the uploaded dataset file digest.
+func (api *Api) NewDigester(hashAlgorithm string) (*digest.Digester, *models.Response) {
+	digester, err := digest.NewDigester(hashAlgorithm)
+	if err != nil {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Unsupported hash algorithm")
+	}
+	return digester, nil
+}
+
+// VerifyUploadedFileHash compares the computed digest of the uploaded dataset
+// file with the hash provided by the client. On mismatch the uploaded file
+// is deleted from the storage.
+func (api *Api) VerifyUploadedFileHash(digester *digest.Digester, expectedHash string, filePath string, sourceSecrets *commonmodels.SourceSecrets) *models.Response {
+	if digest.Equal(digester.Sum(), expectedHash) {
+		return nil
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	if err := fs.Delete(filePath); err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("Hash mismatch: expected %s %s, computed %s", digester.Algorithm, expectedHash, digester.Sum()))
+}
+
+// ReferenceStoredBlob takes a new reference on the blob of the org if the
+// source already stores content with the sha256 hash, so that registering
+// identical content doesn't need another upload.
+func (api *Api) ReferenceStoredBlob(orgId uuid.UUID, sourceSecrets *commonmodels.SourceSecrets, hashAlgorithm string, hash string) (*models.BlobResponse, *models.Response) {
+	if digest.NormalizeAlgorithm(hashAlgorithm) != digest.DefaultAlgorithm {
+		return nil, nil
+	}
+	blob, err := api.app.Dao().ReferenceBlob(orgId, sourceSecrets.SourceType, sourceSecrets.PublicURL, hash)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	return blob, nil
+}
+
+// StoreBlob moves the verified dataset file at filePath to its content addressed
+// blob and takes a reference on it. If the source already stores the same
+// content the uploaded file is dropped instead. A new blob is accounted to
+// the dataset storage usage.
+//
+// The uploaded file is encrypted with dataKey (if not empty), which becomes
+// the key of a new blob. The returned blob holds the key of its content.
+func (api *Api) StoreBlob(orgId uuid.UUID, datasetUUID uuid.UUID, sourceSecrets *commonmodels.SourceSecrets, filePath string, sha256Digest string, dataKey *commonmodels.DataKey) (*models.BlobResponse, *models.Response) {
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	attrs, err := fs.Attributes(filePath)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	blob, created, err := api.app.Dao().AcquireBlob(orgId, sourceSecrets.SourceType, sourceSecrets.PublicURL, sha256Digest, attrs.Size, dataKey)
+	if errors.Is(err, impl.ErrBlobPending) {
+		fs.Delete(filePath)
+		return nil, models.NewErrorResponse(http.StatusConflict, "The same content is being stored by another upload, retry later")
+	}
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	if created {
+		if err := fs.Copy(filePath, blob.Key); err != nil {
+			api.app.Dao().ReleaseBlob(blob.UUID)
+			return nil, models.NewServerErrorResponse(err)
+		}
+		err = api.app.Dao().RecordDatasetStorageUsage(orgId, datasetUUID, uuid.NullUUID{}, sourceSecrets.SourceType, sourceSecrets.PublicURL, blob.Key, blob.Size)
+		if err != nil {
+			return nil, models.NewServerErrorResponse(err)
+		}
+	}
+	// the uploaded file is either copied to the new blob or a duplicate of it
+	fs.Delete(filePath)
+	return blob, nil
+}
+
+// StoreUploadedBlob stores the verified dataset file at filePath like StoreBlob.
+// If the source already stores its content the upload is dropped and the
+// stored blob is referenced, otherwise storing size more bytes must fit the
+// storage quota of the org. The returned flag reports whether the soft
+// quota is exceeded.
+func (api *Api) StoreUploadedBlob(orgId uuid.UUID, datasetUUID uuid.UUID, sourceSecrets *commonmodels.SourceSecrets, filePath string, sha256Digest string, size int64, dataKey *commonmodels.DataKey) (*models.BlobResponse, bool, *models.Response) {
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return nil, false, models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	blob, errresp := api.ReferenceStoredBlob(orgId, sourceSecrets, digest.DefaultAlgorithm, sha256Digest)
+	if errresp != nil {
+		return nil, false, errresp
+	}
+	if blob != nil {
+		fs.Delete(filePath)
+		return blob, false, nil
+	}
+	softQuotaExceeded, errresp := api.CheckStorageQuota(orgId, size)
+	if errresp != nil {
+		fs.Delete(filePath)
+		return nil, false, errresp
+	}
+	blob, errresp = api.StoreBlob(orgId, datasetUUID, sourceSecrets, filePath, sha256Digest, dataKey)
+	if errresp != nil {
+		return nil, false, errresp
+	}
+	return blob, softQuotaExceeded, nil
+}
+
+// CheckStorageQuota verifies that storing size more bytes keeps the org
+// within its hard storage quota. The returned flag reports whether the
+// soft quota is exceeded, which doesn't prevent the upload.
+func (api *Api) CheckStorageQuota(orgId uuid.UUID, size int64) (bool, *models.Response) {
+	usage, err := api.app.Dao().GetOrgStorageUsage(orgId)
+	if err != nil {
+		return false, models.NewServerErrorResponse(err)
+	}
+	if usage == nil {
+		return false, nil
+	}
+	if usage.HardQuota > 0 {
+		if size > usage.HardQuota {
+			return false, models.NewErrorResponse(http.StatusRequestEntityTooLarge, "File exceeds the organization storage quota")
+		}
+		if usage.UsedBytes+size > usage.HardQuota {
+			return false, models.NewErrorResponse(http.StatusInsufficientStorage, "Organization storage quota exceeded")
+		}
+	}
+	return usage.SoftQuota > 0 && usage.UsedBytes+size > usage.SoftQuota, nil
+}
+
+// RemainingStorageQuota returns the bytes the org can still store under its
+// hard quota, 0 if it has none.
+func (api *Api) RemainingStorageQuota(orgId uuid.UUID) (int64, *models.Response) {
+	usage, err := api.app.Dao().GetOrgStorageUsage(orgId)
+	if err != nil {
+		return 0, models.NewServerErrorResponse(err)
+	}
+	if usage == nil || usage.HardQuota <= 0 {
+		return 0, nil
+	}
+	if usage.UsedBytes >= usage.HardQuota {
+		return 0, models.NewErrorResponse(http.StatusInsufficientStorage, "Organization storage quota exceeded")
+	}
+	return usage.HardQuota - usage.UsedBytes, nil
+}
+
+// withSoftQuotaWarning appends the soft quota warning to a success message.
+func withSoftQuotaWarning(message string, softQuotaExceeded bool) string {
+	if !softQuotaExceeded {
+		return message
+	}
+	return message + ". Organization storage soft quota exceeded"
+}
+
+// ReleaseBlob drops a reference on the blob and removes its content
+// from the storage once it is no longer referenced.
+func (api *Api) ReleaseBlob(sourceSecrets *commonmodels.SourceSecrets, blobUUID uuid.UUID) error {
+	blob, err := api.app.Dao().ReleaseBlob(blobUUID)
+	if err != nil || blob == nil {
+		return err
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+	return fs.Delete(blob.Key)
+}
+
+// GetStoredFileSourceSecrets resolves the source secrets and the storage key
+// of a stored dataset file from its source type and recorded path.
+func (api *Api) GetStoredFileSourceSecrets(sourceType string, path string, orgId uuid.UUID) (*commonmodels.SourceSecrets, string, *models.Response) {
+	switch strings.ToUpper(sourceType) {
+	case "LOCAL":
+		return &commonmodels.SourceSecrets{SourceType: "LOCAL"}, strings.TrimPrefix(path, "/"), nil
+	case "PUREML-STORAGE":
+		sourceSecrets, errresp := api.ValidateSourceTypeAndGetSourceSecrets(sourceType, orgId)
+		if errresp != nil {
+			return nil, "", errresp
+		}
+		if strings.HasPrefix(path, sourceSecrets.PublicURL+"/") {
+			return sourceSecrets, strings.TrimPrefix(path, sourceSecrets.PublicURL+"/"), nil
+		}
+	default:
+		secretNames, err := api.app.Dao().GetOrganizationSecrets(orgId)
+		if err != nil {
+			return nil, "", models.NewServerErrorResponse(err)
+		}
+		for _, secretName := range secretNames {
+			sourceSecrets, err := api.app.Dao().GetSecretByName(orgId, secretName)
+			if err != nil || sourceSecrets == nil || sourceSecrets.SourceType != strings.ToUpper(sourceType) {
+				continue
+			}
+			if strings.HasPrefix(path, sourceSecrets.PublicURL+"/") {
+				return sourceSecrets, strings.TrimPrefix(path, sourceSecrets.PublicURL+"/"), nil
+			}
+		}
+	}
+	return nil, "", models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Source %s of the dataset file not connected properly to organization", sourceType))
+}
+
+// ResolveReference validates the uri of an object referenced by a dataset
+// version (s3://bucket/key, r2://bucket/key, the public url of the source
+// or file://key for LOCAL) against the source and returns its storage key.
+// Objects stored by the registry itself can't be referenced.
+func (api *Api) ResolveReference(uri string, sourceSecrets *commonmodels.SourceSecrets) (string, *models.Response) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", models.NewErrorResponse(http.StatusBadRequest, "Invalid reference uri")
+	}
+	var key string
+	matched := false
+	switch strings.ToLower(u.Scheme) {
+	case "file":
+		matched = sourceSecrets.SourceType == "LOCAL"
+		key = u.Host + u.Path
+	case "s3", "r2":
+		matched = sourceSecrets.SourceType == strings.ToUpper(u.Scheme) && u.Host == sourceSecrets.BucketName
+		key = u.Path
+	case "http", "https":
+		matched = sourceSecrets.SourceType != "PUREML-STORAGE" && sourceSecrets.PublicURL != "" && strings.HasPrefix(uri, sourceSecrets.PublicURL+"/")
+		key, err = url.PathUnescape(strings.TrimPrefix(uri, sourceSecrets.PublicURL+"/"))
+		if err != nil {
+			return "", models.NewErrorResponse(http.StatusBadRequest, "Invalid reference uri")
+		}
+	}
+	if !matched {
+		return "", models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Reference %s does not belong to the %s storage", uri, strings.ToLower(sourceSecrets.SourceType)))
+	}
+	key = path.Clean(strings.TrimPrefix(key, "/"))
+	if key == "." || key == ".." || strings.HasPrefix(key, "../") {
+		return "", models.NewErrorResponse(http.StatusBadRequest, "Invalid reference uri")
+	}
+	if gc.IsManagedKey(key) {
+		return "", models.NewErrorResponse(http.StatusBadRequest, "Objects stored by the registry can't be referenced")
+	}
+	return key, nil
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+// setDatasetAlias points the alias of the Demo Dataset to the version of
+// the branch.
+func setDatasetAlias(t *testing.T, e *echo.Echo, name string, branch string, version string) {
+	req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/dataset/Demo%20Dataset/alias/"+name, strings.NewReader(`{"branch":"`+branch+`","version":"`+version+`"}`))
+	req.Header.Set("Authorization", test.ValidAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Expected alias to be set, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDatasetAlias(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "set dataset alias + valid token + latest is reserved",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/alias/latest",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch":"dev","version":"v1"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid alias name latest"`,
+			},
+		},
+		{
+			Name:   "get dataset branch version + valid token + alias",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/staging",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, "test", false)
+				setDatasetAlias(t, e, "staging", "dev", "v1")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v1"`,
+			},
+		},
+		{
+			Name:   "get dataset aliases + valid token",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/alias",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, "test", false)
+				setDatasetAlias(t, e, "staging", "dev", "latest")
+				setDatasetAlias(t, e, "production", "dev", "v1")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`{"name":"production","branch":"dev","version":"v1"`,
+				`{"name":"staging","branch":"dev","version":"v2"`,
+				`"message":"All dataset aliases"`,
+			},
+		},
+		{
+			Name:   "add dataset version tags + valid token + alias",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/production/tags",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setDatasetAlias(t, e, "production", "dev", "v1")
+			},
+			Body:           strings.NewReader(`{"tags":["cleaned"]}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":["cleaned"]`,
+				`"message":"Dataset version tagged"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				versions, err := app.Dao().GetDatasetTaggedVersions(validDemoDatasetUuid, "cleaned")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(versions) != 1 || versions[0].Version != "v1" {
+					t.Fatalf("Expected the aliased version to be tagged, got %+v", versions)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
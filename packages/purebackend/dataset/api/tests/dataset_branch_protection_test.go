@@ -0,0 +1,433 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	datasetdbmodels "github.com/PureMLHQ/PureML/packages/purebackend/dataset/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+var validDatasetReviewUuid = uuid.Must(uuid.FromString("44444444-4444-4444-4444-444444444444"))
+
+// protectDatasetBranch sets the protection rules of the Demo Dataset branch.
+func protectDatasetBranch(t *testing.T, app *test.TestApp, branchUUID uuid.UUID, rules models.BranchProtectionRules) {
+	if _, err := app.Dao().SetDatasetBranchProtection(test.ValidAdminUserOrgUuid, branchUUID, rules, test.ValidAdminUserUuid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// demoDatasetMainBranchUuid returns the uuid of the Demo Dataset main branch.
+func demoDatasetMainBranchUuid(t *testing.T, app *test.TestApp) uuid.UUID {
+	mainBranch, err := app.Dao().GetDatasetBranchByName(test.ValidAdminUserOrgUuid, "Demo Dataset", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mainBranch.UUID
+}
+
+// createDatasetReview opens a review of the Demo Dataset dev v1 version into
+// main as validDatasetReviewUuid.
+func createDatasetReview(t *testing.T, app *test.TestApp) {
+	v1, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := app.Dao().CreateDatasetReview(validDemoDatasetUuid, test.ValidAdminUserUuid, validDemoDatasetDevBranchUuid, v1.UUID, demoDatasetMainBranchUuid(t, app), "Release v1", "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Dao().Datastore().DB.Model(&datasetdbmodels.DatasetReview{}).Where("uuid = ?", review.UUID).Update("uuid", validDatasetReviewUuid).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func addOrgMember(t *testing.T, app *test.TestApp) {
+	if _, err := app.Dao().CreateUserOrganizationFromEmailAndOrgId("notadmin@aztlan.in", test.ValidAdminUserOrgUuid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetDatasetBranchProtection(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "get dataset branch protection + valid token + main branch",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/main/protection",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"block_direct_registration":true`,
+				`"required_approvals":0`,
+				`"block_deletion":false`,
+				`"message":"Dataset branch protection"`,
+			},
+		},
+		{
+			Name:   "get dataset branch protection + valid token + unprotected branch",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/protection",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Branch is not protected"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestSetDatasetBranchProtection(t *testing.T) {
+	protectionUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/protection"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "set dataset branch protection + member token",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+			},
+			Body:           strings.NewReader(`{"block_deletion":true}`),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to protect the branches of this dataset"`,
+			},
+		},
+		{
+			Name:   "set dataset branch protection + valid token + reviewer not in organization",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"reviewers":["notadmin"]}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"User notadmin is not a member of the organization"`,
+			},
+		},
+		{
+			Name:   "set dataset branch protection + valid token + negative approvals",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"required_approvals":-1}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"required_approvals must be a non-negative integer"`,
+			},
+		},
+		{
+			Name:   "set dataset branch protection + valid token + approvals not a number",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"required_approvals":"2"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"required_approvals must be a non-negative integer"`,
+			},
+		},
+		{
+			Name:   "set dataset branch protection + valid token",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"block_direct_registration":true,"required_approvals":2,"block_deletion":true,"reviewers":["demo"]}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"block_direct_registration":true`,
+				`"required_approvals":2`,
+				`"block_deletion":true`,
+				`"reviewers":[{"uuid":"` + test.ValidAdminUserUuid.String() + `"`,
+				`"message":"Dataset branch protection updated"`,
+			},
+		},
+		{
+			Name:   "import dataset + valid token + protected branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/import",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				protectDatasetBranch(t, app, validDemoDatasetDevBranchUuid, models.BranchProtectionRules{BlockDirectRegistration: true})
+			},
+			Body:           strings.NewReader(`{}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Cannot register dataset directly to dev branch"`,
+			},
+		},
+		{
+			Name:   "delete dataset branch + valid token + protected branch",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				protectDatasetBranch(t, app, validDemoDatasetDevBranchUuid, models.BranchProtectionRules{BlockDeletion: true})
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Branch is protected against deletion"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestDeleteDatasetBranchProtection(t *testing.T) {
+	protectionUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/main/protection/delete"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "delete dataset branch protection + member token",
+			Method: http.MethodDelete,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+			},
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to unprotect the branches of this dataset"`,
+			},
+		},
+		{
+			Name:   "delete dataset branch protection + valid token",
+			Method: http.MethodDelete,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"message":"Dataset branch protection removed"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				protection, err := app.Dao().GetDatasetBranchProtection(demoDatasetMainBranchUuid(t, app))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if protection != nil {
+					t.Fatal("Expected the main branch to be unprotected")
+				}
+			},
+		},
+		{
+			Name:   "import dataset + valid token + unprotected main branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/main/import",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if err := app.Dao().DeleteDatasetBranchProtection(demoDatasetMainBranchUuid(t, app)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Url is required"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestApproveDatasetReview(t *testing.T) {
+	approveUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/review/" + validDatasetReviewUuid.String() + "/approve"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "approve dataset review + valid token + not found",
+			Method: http.MethodPost,
+			Url:    approveUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Review with given ID not found"`,
+			},
+		},
+		{
+			Name:   "approve dataset review + valid token + own review",
+			Method: http.MethodPost,
+			Url:    approveUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				createDatasetReview(t, app)
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"You cannot approve your own review"`,
+			},
+		},
+		{
+			Name:   "approve dataset review + member token + not a reviewer",
+			Method: http.MethodPost,
+			Url:    approveUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+				createDatasetReview(t, app)
+				protectDatasetBranch(t, app, demoDatasetMainBranchUuid(t, app), models.BranchProtectionRules{Reviewers: []uuid.UUID{test.ValidAdminUserUuid}})
+			},
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not a reviewer of this branch"`,
+			},
+		},
+		{
+			Name:   "approve dataset review + member token",
+			Method: http.MethodPost,
+			Url:    approveUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+				createDatasetReview(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"review_uuid":"` + validDatasetReviewUuid.String() + `"`,
+				`"handle":"notadmin"`,
+				`"message":"Dataset review approved"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestMergeDatasetReviewProtectedBranch(t *testing.T) {
+	updateUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/review/" + validDatasetReviewUuid.String() + "/update"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "accept dataset review + valid token + missing approvals",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				createDatasetReview(t, app)
+				protectDatasetBranch(t, app, demoDatasetMainBranchUuid(t, app), models.BranchProtectionRules{BlockDirectRegistration: true, RequiredApprovals: 1})
+			},
+			Body:           strings.NewReader(`{"is_accepted":true}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Review needs 1 approvals before merge"`,
+			},
+		},
+		{
+			Name:   "accept dataset review + valid token + not a reviewer",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+				createDatasetReview(t, app)
+				protectDatasetBranch(t, app, demoDatasetMainBranchUuid(t, app), models.BranchProtectionRules{Reviewers: []uuid.UUID{test.ValidUserUuid}})
+			},
+			Body:           strings.NewReader(`{"is_accepted":true}`),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not allowed to accept reviews into this branch"`,
+			},
+		},
+		{
+			Name:   "accept dataset review + valid token + hash already on branch",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				createDatasetReview(t, app)
+				if _, err := app.Dao().RegisterDatasetFile(demoDatasetMainBranchUuid(t, app), "LOCAL", "", "dataset.pkl", false, "1234567890", "sha256", "1234567890", "", uuid.NullUUID{}, nil, "", test.ValidAdminUserUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"is_accepted":true}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Dataset with this hash already exists"`,
+			},
+		},
+		{
+			Name:   "accept dataset review + valid token + approved",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+				createDatasetReview(t, app)
+				protectDatasetBranch(t, app, demoDatasetMainBranchUuid(t, app), models.BranchProtectionRules{BlockDirectRegistration: true, RequiredApprovals: 1})
+				if _, err := app.Dao().ApproveDatasetReview(validDatasetReviewUuid, test.ValidUserUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"is_accepted":true}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"is_accepted":true`,
+				`"message":"Dataset review updated"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetDatasetBranchVersion(demoDatasetMainBranchUuid(t, app), "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version == nil || version.Hash != "1234567890" {
+					t.Fatalf("Expected the review to be merged into main as v1, got %+v", version)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
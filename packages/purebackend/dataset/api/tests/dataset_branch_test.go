@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
 )
 
 func TestGetDatasetAllBranches(t *testing.T) {
@@ -332,6 +333,51 @@ func TestCreateDatasetBranch(t *testing.T) {
 				`"message":"Dataset branch created"`,
 			},
 		},
+		{
+			Name:   "create dataset branch + valid token + from branch not found",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/create",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":"experiment","from_branch":"nope","from_version":"v1"}`),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"From branch not found"`,
+			},
+		},
+		{
+			Name:   "create dataset branch + valid token + from version",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/create",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, "a,b\n1,2\n", false)
+			},
+			Body:           strings.NewReader(`{"branch_name":"experiment","from_branch":"dev","from_version":"latest"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"experiment"`,
+				`"base":{"branch":{"uuid":"` + validDemoDatasetDevBranchUuid.String() + `","name":"dev"},"version":{"uuid":`,
+				`"version":"v2"}}`,
+				`"message":"Dataset branch created"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				branch, err := app.Dao().GetDatasetBranchByName(test.ValidAdminUserOrgUuid, "Demo Dataset", "experiment")
+				if err != nil {
+					t.Fatal(err)
+				}
+				version, err := app.Dao().GetDatasetBranchVersion(branch.UUID, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version == nil || version.Hash != sha256Hex("a,b\n1,2\n") || version.Lineage.Lineage != "{}" {
+					t.Fatalf("Expected the branch to start with a copy of dev/v2 as v1, got %+v", version)
+				}
+			},
+		},
 	}
 
 	for _, scenario := range scenarios {
@@ -339,10 +385,209 @@ func TestCreateDatasetBranch(t *testing.T) {
 	}
 }
 
-// TODO
 func TestUpdateDatasetBranch(t *testing.T) {
+	updateUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/update"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "update dataset branch + unauthorized",
+			Method:         http.MethodPost,
+			Url:            updateUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "update dataset branch + valid token + empty name",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":""}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Branch name cannot be empty"`,
+			},
+		},
+		{
+			Name:   "update dataset branch + valid token + branch already exists",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":"main"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Branch already exists"`,
+			},
+		},
+		{
+			Name:   "update dataset branch + valid token + rename",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":"experiments"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"experiments"`,
+				`"is_default":false`,
+				`"message":"Dataset branch updated"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				branch, err := app.Dao().GetDatasetBranchByUUID(validDemoDatasetDevBranchUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if branch.Name != "experiments" {
+					t.Fatalf("Expected the branch to be renamed, got %s", branch.Name)
+				}
+			},
+		},
+		{
+			Name:   "update dataset branch + valid token + default branch",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"is_default":true}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"dev"`,
+				`"is_default":true`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				mainBranch, err := app.Dao().GetDatasetBranchByName(test.ValidAdminUserOrgUuid, "Demo Dataset", "main")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if mainBranch.IsDefault {
+					t.Fatal("Expected main to no longer be the default branch")
+				}
+			},
+		},
+		{
+			Name:   "update dataset branch + valid token + unset default branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/main/update",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"is_default":false}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Dataset needs a default branch, make another branch the default instead"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
 }
 
-// TODO
 func TestDeleteDatasetBranch(t *testing.T) {
+	deleteUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/delete"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "delete dataset branch + unauthorized",
+			Method:         http.MethodDelete,
+			Url:            deleteUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "delete dataset branch + valid token + default branch",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/main/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Default branch cannot be deleted"`,
+			},
+		},
+		{
+			Name:   "delete dataset branch + valid token",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"kind":"dataset_branch"`,
+				`"name":"Demo Dataset/dev"`,
+				`"message":"Dataset branch moved to trash"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				branch, err := app.Dao().GetDatasetBranchByUUID(validDemoDatasetDevBranchUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if branch != nil {
+					t.Fatal("Expected the branch to be hidden once in the trash")
+				}
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version != nil {
+					t.Fatal("Expected the versions of the branch to be hidden once in the trash")
+				}
+			},
+		},
+		{
+			Name:   "delete dataset branch + valid token + referenced by alias",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setDatasetAlias(t, e, "champion", "dev", "v1")
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"Branch is referenced by the alias champion"`,
+			},
+		},
+		{
+			Name:   "delete dataset branch + valid token + referenced by open review",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				mainBranch, err := app.Dao().GetDatasetBranchByName(test.ValidAdminUserOrgUuid, "Demo Dataset", "main")
+				if err != nil {
+					t.Fatal(err)
+				}
+				v1, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = app.Dao().CreateDatasetReview(validDemoDatasetUuid, test.ValidAdminUserUuid, validDemoDatasetDevBranchUuid, v1.UUID, mainBranch.UUID, "Release v1", "", false, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"Branch is referenced by an open review"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
 }
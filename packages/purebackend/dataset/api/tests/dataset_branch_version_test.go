@@ -1,11 +1,20 @@
 package tests
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/settings"
 	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
 )
 
 func TestGetDatasetBranchAllVersions(t *testing.T) {
@@ -692,3 +701,651 @@ func TestRegisterDataset(t *testing.T) {
 		scenario.Test(t)
 	}
 }
+
+func TestRegisterDatasetStorageSources(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	newBody := func(storage string) (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(map[string]string{
+			"hash":    sha256Hex("test"),
+			"storage": storage,
+			"lineage": "{}",
+		}, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	localBody, localContentType := newBody("LOCAL")
+	disabledBody, disabledContentType := newBody("PUREML-STORAGE")
+	managedBody, managedContentType := newBody("PUREML-STORAGE")
+	blobKey := testBlobKey(sha256Hex("test"))
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register dataset + valid token + local storage",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  localContentType,
+			},
+			Body:           localBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"version":"v2"`,
+				`"path":"` + blobKey + `"`,
+				`"source_type":"LOCAL"`,
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				content, err := os.ReadFile(filepath.Join(app.LocalStorageDir(), version.Path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != "test" {
+					t.Fatalf("Expected stored file content %q, got %q", "test", content)
+				}
+			},
+		},
+		{
+			Name:   "register dataset + valid token + pureml storage not configured",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  disabledContentType,
+			},
+			Body:           disabledBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"PureML storage is not configured for this instance"`,
+			},
+		},
+		{
+			Name:   "register dataset + valid token + pureml storage",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  managedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				app.Settings().S3 = settings.S3Config{
+					Enabled:        true,
+					Bucket:         test.TestS3BucketName,
+					Region:         "us-east-1",
+					Endpoint:       s3.URL,
+					AccessKey:      "test-key",
+					Secret:         "test-secret",
+					ForcePathStyle: true,
+				}
+			},
+			Body:           managedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"version":"v2"`,
+				`"path":"` + s3.URL + "/" + test.TestS3BucketName + "/" + blobKey + `"`,
+				`"source_type":"PUREML-STORAGE"`,
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				key := strings.TrimPrefix(version.Path, s3.URL+"/"+test.TestS3BucketName+"/")
+				if content, ok := s3.Object(test.TestS3BucketName, key); !ok || string(content) != "test" {
+					t.Fatalf("Expected file to be stored in the managed bucket at %s", key)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// seedDatasetBlob stores content as a LOCAL blob of the admin org
+// referenced once by another artifact.
+func seedDatasetBlob(t *testing.T, app *test.TestApp, content string) {
+	fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err := fs.Upload([]byte(content), testBlobKey(sha256Hex(content))); err != nil {
+		t.Fatal(err)
+	}
+	blob, _, err := app.Dao().AcquireBlob(test.ValidAdminUserOrgUuid, "LOCAL", "", sha256Hex(content), int64(len(content)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Dao().CompleteBlob(blob.UUID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func datasetBlobRefCount(t *testing.T, app *test.TestApp, digest string) int {
+	var blob dbmodels.Blob
+	if err := app.Dao().Datastore().DB.Where("digest = ?", digest).Limit(1).Find(&blob).Error; err != nil {
+		t.Fatal(err)
+	}
+	return blob.RefCount
+}
+
+func TestRegisterDatasetDeduplication(t *testing.T) {
+	newBody := func(hash string, hashAlgorithm string) (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(map[string]string{
+			"hash":           hash,
+			"hash_algorithm": hashAlgorithm,
+			"storage":        "LOCAL",
+			"lineage":        "{}",
+		}, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	fileHash := sha256Hex("test")
+	newBlobBody, newBlobContentType := newBody(fileHash, "sha256")
+	storedBody, storedContentType := newBody(fileHash, "sha256")
+	md5Body, md5ContentType := newBody("098f6bcd4621d373cade4e832627b4f6", "md5")
+	claimedBody, claimedContentType := newBody(sha256Hex("secret"), "sha256")
+	pendingBody, pendingContentType := newBody(fileHash, "sha256")
+	uploadDir := fmt.Sprintf("dataset-registry/%s/datasets/%s/%s", test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid)
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register dataset + valid token + new content stored as blob",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  newBlobContentType,
+			},
+			Body:           newBlobBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"path":"` + testBlobKey(fileHash) + `"`,
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := datasetBlobRefCount(t, app, fileHash); count != 1 {
+					t.Fatalf("Expected blob to be referenced once, got %d", count)
+				}
+				entries, _ := os.ReadDir(filepath.Join(app.LocalStorageDir(), uploadDir))
+				if len(entries) > 0 {
+					t.Fatalf("Expected uploaded file to be moved to its blob, found %d files", len(entries))
+				}
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				unreferenced, err := app.Dao().DeleteDatasetVersion(version.UUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(unreferenced) != 1 || unreferenced[0].Key != testBlobKey(fileHash) {
+					t.Fatalf("Expected blob to be unreferenced after deleting its only version, got %v", unreferenced)
+				}
+				if count := datasetBlobRefCount(t, app, fileHash); count != 0 {
+					t.Fatalf("Expected blob to be removed, got %d references", count)
+				}
+			},
+		},
+		{
+			Name:   "register dataset + valid token + stored content deduplicated after verification",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  storedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetBlob(t, app, "test")
+			},
+			Body:           storedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"hash":"` + fileHash + `"`,
+				`"path":"` + testBlobKey(fileHash) + `"`,
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := datasetBlobRefCount(t, app, fileHash); count != 2 {
+					t.Fatalf("Expected blob to be referenced twice, got %d", count)
+				}
+				entries, _ := os.ReadDir(filepath.Join(app.LocalStorageDir(), uploadDir))
+				if len(entries) > 0 {
+					t.Fatalf("Expected duplicate upload to be removed, found %d files", len(entries))
+				}
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				unreferenced, err := app.Dao().DeleteDatasetVersion(version.UUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(unreferenced) != 0 {
+					t.Fatal("Expected blob to stay referenced by the other artifact")
+				}
+				if count := datasetBlobRefCount(t, app, fileHash); count != 1 {
+					t.Fatalf("Expected blob to be referenced once, got %d", count)
+				}
+			},
+		},
+		{
+			Name:   "register dataset + valid token + stored hash claimed with other content",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  claimedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetBlob(t, app, "secret")
+			},
+			Body:           claimedBody,
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Hash mismatch: expected sha256 ` + sha256Hex("secret") + `, computed ` + fileHash + `"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := datasetBlobRefCount(t, app, sha256Hex("secret")); count != 1 {
+					t.Fatalf("Expected stored blob to stay referenced once, got %d", count)
+				}
+			},
+		},
+		{
+			Name:   "register dataset + valid token + same content being stored",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  pendingContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if _, _, err := app.Dao().AcquireBlob(test.ValidAdminUserOrgUuid, "LOCAL", "", fileHash, 4, nil); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           pendingBody,
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"The same content is being stored by another upload, retry later"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := datasetBlobRefCount(t, app, fileHash); count != 1 {
+					t.Fatalf("Expected pending blob to stay referenced once, got %d", count)
+				}
+				entries, _ := os.ReadDir(filepath.Join(app.LocalStorageDir(), uploadDir))
+				if len(entries) > 0 {
+					t.Fatalf("Expected upload to be removed, found %d files", len(entries))
+				}
+			},
+		},
+		{
+			Name:   "register dataset + valid token + stored content deduplicated after upload",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  md5ContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetBlob(t, app, "test")
+			},
+			Body:           md5Body,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"hash":"098f6bcd4621d373cade4e832627b4f6"`,
+				`"hash_algorithm":"md5"`,
+				`"path":"` + testBlobKey(fileHash) + `"`,
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := datasetBlobRefCount(t, app, fileHash); count != 2 {
+					t.Fatalf("Expected blob to be referenced twice, got %d", count)
+				}
+				entries, _ := os.ReadDir(filepath.Join(app.LocalStorageDir(), uploadDir))
+				if len(entries) > 0 {
+					t.Fatalf("Expected duplicate upload to be removed, found %d files", len(entries))
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// setOrgStorageQuota sets the storage quotas (in bytes) of the admin org.
+func setOrgStorageQuota(t *testing.T, app *test.TestApp, softQuota int64, hardQuota int64) {
+	_, err := app.Dao().UpdateOrg(test.ValidAdminUserOrgUuid, map[string]interface{}{
+		"storage_soft_quota": softQuota,
+		"storage_hard_quota": hardQuota,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterDatasetStorageQuota(t *testing.T) {
+	newBody := func() (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(map[string]string{
+			"hash":    sha256Hex("test"),
+			"storage": "LOCAL",
+			"lineage": "{}",
+		}, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	exceededBody, exceededContentType := newBody()
+	registeredBody, registeredContentType := newBody()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register dataset + valid token + hard quota exceeded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  exceededContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setOrgStorageQuota(t, app, 0, 10)
+				err := app.Dao().RecordDatasetStorageUsage(test.ValidAdminUserOrgUuid, validDemoDatasetUuid, uuid.NullUUID{}, "LOCAL", "", "dataset-registry/stored", 8)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           exceededBody,
+			ExpectedStatus: 507,
+			ExpectedContent: []string{
+				`"message":"Organization storage quota exceeded"`,
+			},
+		},
+		{
+			Name:   "register dataset + valid token + usage accounted",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  registeredContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setOrgStorageQuota(t, app, 0, 4)
+			},
+			Body:           registeredBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				usage, err := app.Dao().GetOrgStorageUsage(test.ValidAdminUserOrgUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if usage.UsedBytes != 4 || len(usage.Datasets) != 1 || usage.Datasets[0].UUID != validDemoDatasetUuid || usage.Datasets[0].UsedBytes != 4 {
+					t.Fatalf("Expected 4 bytes accounted to the dataset, got %+v", usage)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// seedDatasetVersionFile registers a new LOCAL version (v2) on the Demo Dataset
+// dev branch, optionally storing its file content.
+func seedDatasetVersionFile(t *testing.T, app *test.TestApp, content string, store bool) {
+	fileKey := fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/dataset.csv", test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid)
+	if store {
+		fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fs.Close()
+		if err := fs.Upload([]byte(content), fileKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := app.Dao().RegisterDatasetFile(validDemoDatasetDevBranchUuid, "LOCAL", "", fileKey, false, sha256Hex(content), "sha256", sha256Hex(content), "", uuid.NullUUID{}, nil, "{}", test.ValidAdminUserUuid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadDatasetBranchVersion(t *testing.T) {
+	content := "a,b,c\n1,2,3\n"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "download dataset branch version + unauthorized",
+			Method:         http.MethodGet,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v1/download",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "download dataset branch version + valid token + version not found",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v9/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`Dataset Branch Version not found`,
+			},
+		},
+		{
+			Name:   "download dataset branch version + valid token + empty version",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v1/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Dataset version has no file"`,
+			},
+		},
+		{
+			Name:   "download dataset branch version + valid token + file missing in storage",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, content, false)
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"File not found in storage"`,
+			},
+		},
+		{
+			Name:   "download dataset branch version + valid token + full file",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, content, true)
+			},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{content},
+		},
+		{
+			Name:   "download dataset branch version + valid token + range",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Range":         "bytes=0-4",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, content, true)
+			},
+			ExpectedStatus:     206,
+			ExpectedContent:    []string{"a,b,c"},
+			NotExpectedContent: []string{content},
+		},
+		{
+			Name:   "download dataset branch version + valid token + etag matches",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"If-None-Match": `"` + sha256Hex(content) + `"`,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, content, true)
+			},
+			ExpectedStatus: 304,
+		},
+		{
+			Name:   "download dataset branch version + valid token + etag changed",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"If-None-Match": `"1234567890"`,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, content, true)
+			},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{content},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestRegisterDatasetReference(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	referenceKey := "external/datasets/data.csv"
+	newBody := func(fields map[string]string) (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	seedReference := func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+		if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+			t.Fatal(err)
+		}
+		s3.PutObject(test.TestS3BucketName, referenceKey, []byte("referenced"))
+	}
+	referenceBody, referenceContentType := newBody(map[string]string{
+		"uri":     "s3://" + test.TestS3BucketName + "/" + referenceKey,
+		"lineage": "{}",
+		"storage": test.TestS3SecretName,
+	})
+	unverifiedBody, unverifiedContentType := newBody(map[string]string{
+		"uri":     "s3://" + test.TestS3BucketName + "/" + referenceKey,
+		"hash":    sha256Hex("referenced"),
+		"lineage": "{}",
+		"storage": test.TestS3SecretName,
+	})
+	managedBody, managedContentType := newBody(map[string]string{
+		"uri":     "s3://" + test.TestS3BucketName + "/dataset-registry/" + test.ValidAdminUserOrgUuid.String() + "/data.csv",
+		"lineage": "{}",
+		"storage": test.TestS3SecretName,
+	})
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register dataset + valid token + reference",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  referenceContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           referenceBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v2"`,
+				`"hash":"` + sha256Hex("referenced") + `"`,
+				`"lineage":"{}"`,
+				`"is_referenced":true`,
+				`"size":10`,
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := app.Dao().DeleteDatasetVersion(version.UUID); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := s3.Object(test.TestS3BucketName, referenceKey); !ok {
+					t.Fatal("Expected referenced object to be kept")
+				}
+			},
+		},
+		{
+			Name:   "register dataset + valid token + reference with unverified hash",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  unverifiedContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           unverifiedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"hash":"` + sha256Hex("referenced") + `"`,
+				`"digests":{}`,
+				`"is_referenced":true`,
+			},
+		},
+		{
+			Name:   "register dataset + valid token + reference to a registry object",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  managedContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           managedBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Objects stored by the registry can't be referenced"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
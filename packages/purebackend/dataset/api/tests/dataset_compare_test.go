@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// seedDatasetCompare forks an experiment branch from dev/v1 of the Demo Dataset
+// and registers a v2 with an accuracy log on both branches, updating the
+// readme in between.
+func seedDatasetCompare(t *testing.T, app *test.TestApp) {
+	v1, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	experiment, err := app.Dao().CreateDatasetBranchFromVersion(validDemoDatasetUuid, "experiment", v1.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedDatasetVersionFile(t, app, "dev", false)
+	devV2, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().CreateLogForDatasetVersion("accuracy", "0.8", devV2.UUID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().UpdateDatasetReadme(validDemoDatasetUuid, "markdown", "Experiment Readme"); err != nil {
+		t.Fatal(err)
+	}
+	experimentV2, err := app.Dao().RegisterDatasetFile(experiment.UUID, "LOCAL", "", "dataset.csv", false, sha256Hex("experiment"), "sha256", sha256Hex("experiment"), "", uuid.NullUUID{}, nil, "{}", test.ValidAdminUserUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().CreateLogForDatasetVersion("accuracy", "0.9", experimentV2.UUID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().CreateLogForDatasetVersion("loss", "0.1", experimentV2.UUID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompareDatasetBranches(t *testing.T) {
+	compareUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/compare"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "compare dataset branches + unauthorized",
+			Method:         http.MethodGet,
+			Url:            compareUrl + "?base=dev&head=main",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "compare dataset branches + valid token + no head",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Base and head branches are required"`,
+			},
+		},
+		{
+			Name:   "compare dataset branches + valid token + branch not found",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=noexist",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Branch noexist not found"`,
+			},
+		},
+		{
+			Name:   "compare dataset branches + valid token + version not found",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=main&base_version=v9",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Version v9 of branch dev not found"`,
+			},
+		},
+		{
+			Name:   "compare dataset branches + valid token + empty head",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=main",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"branch":"main","version":null}`,
+				`"fork_point":null`,
+				`"ahead_by":0`,
+				`"behind_by":1`,
+				`"only_in_head":[]`,
+				`"files":[]`,
+				`"message":"Dataset branches compared"`,
+			},
+		},
+		{
+			Name:   "compare dataset branches + valid token",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=experiment",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetCompare(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"fork_point":{"uuid":"` + validDemoDatasetUuid.String() + `","version":"v1","hash":"1234567890"`,
+				`"ahead_by":1`,
+				`"behind_by":1`,
+				`"base_moved":true`,
+				`"only_in_base":[{"uuid":`,
+				`"hash":"` + sha256Hex("dev") + `"`,
+				`"only_in_head":[{"uuid":`,
+				`"hash":"` + sha256Hex("experiment") + `"`,
+				`"logs":[{"key":"accuracy","base":"0.8","head":"0.9","changed":true},{"key":"loss","base":null,"head":"0.1","changed":true}]`,
+				`"readme":{"base_version":"v1","base":"Demo Readme","head_version":"v2","head":"Experiment Readme","changed":true}`,
+				`"path":"dataset.csv","status":"modified","base_digest":"` + sha256Hex("dev") + `","head_digest":"` + sha256Hex("experiment") + `"`,
+				`"message":"Dataset branches compared"`,
+			},
+		},
+		{
+			Name:   "compare dataset branches + valid token + fork point version",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=experiment&base_version=v1&head_version=v1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetCompare(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"ahead_by":0`,
+				`"behind_by":0`,
+				`"base_moved":false`,
+				`"logs":[]`,
+				`"readme":{"base_version":"v1","base":"Demo Readme","head_version":"v1","head":"Demo Readme","changed":false}`,
+				`"files":[]`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
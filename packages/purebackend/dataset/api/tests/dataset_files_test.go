@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	datasetSchemaContent = `{"columns":["x","y"]}`
+	datasetShardContent  = "\x00\x01\x02\x03"
+)
+
+// mockDatasetFilesData builds the multipart body of a multi-file
+// registration of a schema file and a data shard.
+func mockDatasetFilesData(t *testing.T, fields map[string]string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	mp := multipart.NewWriter(body)
+	for k, v := range fields {
+		if err := mp.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []struct {
+		path        string
+		content     string
+		contentType string
+	}{
+		{"shards/part-0.bin", datasetShardContent, ""},
+		{"schema.json", datasetSchemaContent, "application/json"},
+	}
+	for _, file := range files {
+		if err := mp.WriteField("paths", file.path); err != nil {
+			t.Fatal(err)
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`, file.path))
+		if file.contentType != "" {
+			h.Set("Content-Type", file.contentType)
+		}
+		part, err := mp.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body, mp.FormDataContentType()
+}
+
+func getDatasetVersionFile(e *echo.Echo, version string, filePath string) (int, string) {
+	url := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/" + version + "/files/" + filePath
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", test.ValidAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestRegisterDatasetFiles(t *testing.T) {
+	manifest := fmt.Sprintf(`[{"path":"schema.json","size":%d,"digest":"%s","media_type":"application/json"},{"path":"shards/part-0.bin","size":%d,"digest":"%s","media_type":"application/octet-stream"}]`,
+		len(datasetSchemaContent), sha256Hex(datasetSchemaContent), len(datasetShardContent), sha256Hex(datasetShardContent))
+	registeredBody, registeredContentType := mockDatasetFilesData(t, map[string]string{"storage": "LOCAL", "lineage": "{}"})
+	mainBody, mainContentType := mockDatasetFilesData(t, map[string]string{"storage": "LOCAL", "lineage": "{}"})
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "register dataset files + unauthorized",
+			Method:         http.MethodPost,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register/files",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "register dataset files + valid token + main branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/main/register/files",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  mainContentType,
+			},
+			Body:           mainBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Cannot register dataset directly to main branch"`,
+			},
+		},
+		{
+			Name:   "register dataset files + valid token + registered",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/register/files",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  registeredContentType,
+			},
+			Body:           registeredBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"hash":"` + sha256Hex(manifest) + `"`,
+				`"version":"v2"`,
+				`"is_manifest":true`,
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if datasetBlobRefCount(t, app, sha256Hex(datasetSchemaContent)) != 1 || datasetBlobRefCount(t, app, sha256Hex(datasetShardContent)) != 1 {
+					t.Fatal("Expected every file to be stored as a blob")
+				}
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				files, err := app.Dao().GetDatasetVersionFiles(version.UUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(files) != 2 || files[0].Path != "schema.json" || files[1].Path != "shards/part-0.bin" {
+					t.Fatalf("Expected manifest sorted by path, got %+v", files)
+				}
+				if code, body := getDatasetVersionFile(e, "v2", "shards/part-0.bin"); code != 200 || body != datasetShardContent {
+					t.Fatalf("Expected shard download, got %d %q", code, body)
+				}
+				if code, _ := getDatasetVersionFile(e, "v2", "shards/part-1.bin"); code != 404 {
+					t.Fatalf("Expected missing file to be not found, got %d", code)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+// waitForDatasetImport polls the status of the import of the Demo Dataset
+// dev branch until it is completed or failed, and returns its details.
+func waitForDatasetImport(t *testing.T, app *test.TestApp, e *echo.Echo) string {
+	var job dbmodels.ImportJob
+	if err := app.Dao().Datastore().DB.Where("dataset_branch_uuid = ?", validDemoDatasetDevBranchUuid).First(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req := httptest.NewRequest(http.MethodGet, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/dataset/Demo%20Dataset/branch/dev/import/"+job.UUID.String(), nil)
+		req.Header.Set("Authorization", test.ValidAdminToken)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != 200 {
+			t.Fatalf("Expected import details, got %d %s", rec.Code, rec.Body.String())
+		}
+		body := rec.Body.String()
+		if strings.Contains(body, `"status":"completed"`) || strings.Contains(body, `"status":"failed"`) {
+			return body
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Import didn't finish: %s", body)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestImportDataset(t *testing.T) {
+	artifacts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/artifacts/data.csv" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("a,b\n1,2\n"))
+	}))
+	defer artifacts.Close()
+	artifactURL := artifacts.URL + "/artifacts/data.csv"
+	importUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/import"
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "import dataset + unauthorized",
+			Method:         http.MethodPost,
+			Url:            importUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "import dataset + valid token + no url",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"hash":"` + sha256Hex("a,b\n1,2\n") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Url is required"`,
+			},
+		},
+		{
+			Name:   "import dataset + valid token + hash mismatch",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","hash":"` + sha256Hex("test") + `","storage":"LOCAL","lineage":"{}"}`),
+			ExpectedStatus: 202,
+			ExpectedContent: []string{
+				`"message":"Dataset import started"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body := waitForDatasetImport(t, app, e)
+				if !strings.Contains(body, `"status":"failed"`) || !strings.Contains(body, "Hash mismatch") {
+					t.Fatalf("Expected import to fail on hash mismatch, got %s", body)
+				}
+				if datasetBlobRefCount(t, app, sha256Hex("a,b\n1,2\n")) != 0 {
+					t.Fatal("Expected the mismatching artifact not to be stored")
+				}
+			},
+		},
+		{
+			Name:   "import dataset + valid token + storage quota exceeded",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setOrgStorageQuota(t, app, 0, 4)
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","hash":"` + sha256Hex("a,b\n1,2\n") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 202,
+			ExpectedContent: []string{
+				`"message":"Dataset import started"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body := waitForDatasetImport(t, app, e)
+				if !strings.Contains(body, `"status":"failed"`) || !strings.Contains(body, "Organization storage quota exceeded") {
+					t.Fatalf("Expected import to fail on the storage quota, got %s", body)
+				}
+				if datasetBlobRefCount(t, app, sha256Hex("a,b\n1,2\n")) != 0 {
+					t.Fatal("Expected the artifact not to be stored")
+				}
+			},
+		},
+		{
+			Name:   "import dataset + valid token + imported",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","hash":"` + sha256Hex("a,b\n1,2\n") + `","storage":"LOCAL","lineage":"{\"source\":\"import\"}"}`),
+			ExpectedStatus: 202,
+			ExpectedContent: []string{
+				`"status":"pending"`,
+				`"message":"Dataset import started"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body := waitForDatasetImport(t, app, e)
+				if !strings.Contains(body, `"status":"completed"`) || !strings.Contains(body, `"version":"v2"`) {
+					t.Fatalf("Expected import to complete, got %s", body)
+				}
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version == nil || version.FileName != "data.csv" || version.Lineage.Lineage != `{"source":"import"}` {
+					t.Fatalf("Expected imported dataset version, got %+v", version)
+				}
+				if datasetBlobRefCount(t, app, sha256Hex("a,b\n1,2\n")) != 1 {
+					t.Fatal("Expected the artifact to be stored as a blob")
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,489 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	commondbmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/dbmodels"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// seedDatasetPresignedUpload creates an upload session on the Demo Dataset
+// dev branch for the provided storage and, if content is not empty,
+// stores it as the uploaded file.
+func seedDatasetPresignedUpload(t *testing.T, app *test.TestApp, s3 *test.S3Server, storage string, content string) {
+	session := dbmodels.UploadSession{
+		BaseModel: commondbmodels.BaseModel{
+			UUID: validDatasetUploadSessionUuid,
+		},
+		OrganizationUUID: test.ValidAdminUserOrgUuid,
+		DatasetBranchUUID: uuid.NullUUID{
+			UUID:  validDemoDatasetDevBranchUuid,
+			Valid: true,
+		},
+		FileName:  "dataset.csv",
+		Storage:   storage,
+		CreatedBy: test.ValidAdminUserUuid,
+	}
+	if err := app.Dao().Datastore().DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if storage == test.TestS3SecretName {
+		if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if content == "" {
+		return
+	}
+	key := fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/%s_dataset.csv", test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+	if storage == test.TestS3SecretName {
+		s3.PutObject(test.TestS3BucketName, key, []byte(content))
+		return
+	}
+	fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err := fs.Upload([]byte(content), key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateDatasetPresignedUpload(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "create dataset presigned upload + unauthorized",
+			Method:         http.MethodPost,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "create dataset presigned upload + valid token + file name empty",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"File name is required"`,
+			},
+		},
+		{
+			Name:   "create dataset presigned upload + valid token + storage not connected",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"file_name":"dataset.csv","storage":"` + test.TestS3SecretName + `"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Source ` + test.TestS3SecretName + ` not connected properly to organization"`,
+			},
+		},
+		{
+			Name:   "create dataset presigned upload + valid token + local storage proxied",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"file_name":"dataset.csv","storage":"LOCAL"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"method":"PUT"`,
+				`"url":"/api/org/` + test.ValidAdminUserOrgUuid.String() + `/dataset/Demo%20Dataset/branch/dev/presigned-upload/`,
+				`"proxied":true`,
+				`"file_name":"dataset.csv"`,
+				`"message":"Dataset presigned upload url created"`,
+			},
+		},
+		{
+			Name:   "create dataset presigned upload + valid token + s3 storage presigned",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"file_name":"dataset.csv","storage":"` + test.TestS3SecretName + `"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"method":"PUT"`,
+				`"url":"` + s3.URL + `/` + test.TestS3BucketName + `/dataset-registry/` + test.ValidAdminUserOrgUuid.String(),
+				`X-Amz-Signature=`,
+				`"proxied":false`,
+				`"message":"Dataset presigned upload url created"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				// the presigned url can be used to upload the file directly
+				sourceSecrets, err := app.Dao().GetSecretByName(test.ValidAdminUserOrgUuid, test.TestS3SecretName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fs, err := app.NewFilesystem(sourceSecrets)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer fs.Close()
+				signedURL, err := fs.SignedURL("direct/dataset.csv", http.MethodPut, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req, err := http.NewRequest(http.MethodPut, signedURL, bytes.NewReader([]byte("direct")))
+				if err != nil {
+					t.Fatal(err)
+				}
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if content, ok := s3.Object(test.TestS3BucketName, "direct/dataset.csv"); !ok || string(content) != "direct" {
+					t.Fatalf("Expected the file to be uploaded through the presigned url, got %q", string(content))
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestUploadDatasetPresignedFile(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "upload dataset presigned file + valid token + session not found",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader("a,b,c\n1,2,3\n"),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Upload session not found"`,
+			},
+		},
+		{
+			Name:   "upload dataset presigned file + valid token + file empty",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, nil, "LOCAL", "")
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"File is empty"`,
+			},
+		},
+		{
+			Name:   "upload dataset presigned file + valid token + file too large",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  "application/octet-stream",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, nil, "LOCAL", "")
+				app.Settings().Upload.MaxFileSize = 4
+			},
+			Body:           strings.NewReader("first-second-third"),
+			ExpectedStatus: 413,
+			ExpectedContent: []string{
+				`"message":"File exceeds the maximum size of 4 bytes"`,
+			},
+		},
+		{
+			Name:   "upload dataset presigned file + valid token + file uploaded",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  "application/octet-stream",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, nil, "LOCAL", "")
+			},
+			Body:           strings.NewReader("a,b,c\n1,2,3\n"),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"message":"Dataset file uploaded"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				filePath := fmt.Sprintf("%s/storage/dataset-registry/%s/datasets/%s/%s/%s_dataset.csv", app.DataDir(), test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+				content, err := os.ReadFile(filePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != "a,b,c\n1,2,3\n" {
+					t.Fatalf("Expected uploaded file content %q, got %q", "a,b,c\n1,2,3\n", string(content))
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestConfirmDatasetPresignedUpload(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "confirm dataset presigned upload + valid token + hash empty",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, s3, "LOCAL", "a,b,c\n1,2,3\n")
+			},
+			Body:           strings.NewReader(`{}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Hash is required"`,
+			},
+		},
+		{
+			Name:   "confirm dataset presigned upload + valid token + file not uploaded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, s3, test.TestS3SecretName, "")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validDatasetUploadHash + `"}`),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Uploaded file not found"`,
+			},
+		},
+		{
+			Name:   "confirm dataset presigned upload + valid token + size mismatch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, s3, test.TestS3SecretName, "a,b,c\n1,2,3\n")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validDatasetUploadHash + `","size":10}`),
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Size mismatch: expected 10 bytes, uploaded 12 bytes"`,
+			},
+		},
+		{
+			Name:   "confirm dataset presigned upload + valid token + hash mismatch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, s3, test.TestS3SecretName, "a,b,c\n1,2,3\n")
+			},
+			Body:           strings.NewReader(`{"hash":"uploadhash","size":12}`),
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Hash mismatch: expected sha256 uploadhash, computed ` + validDatasetUploadHash + `"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				key := fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/%s_dataset.csv", test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+				if _, ok := s3.Object(test.TestS3BucketName, key); ok {
+					t.Fatal("Expected mismatched file to be deleted")
+				}
+			},
+		},
+		{
+			Name:   "confirm dataset presigned upload + valid token + storage quota exceeded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, s3, "LOCAL", "a,b,c\n1,2,3\n")
+				setOrgStorageQuota(t, app, 0, 20)
+				if err := app.Dao().RecordDatasetStorageUsage(test.ValidAdminUserOrgUuid, validDemoDatasetUuid, uuid.NullUUID{}, "LOCAL", "", "dataset-registry/stored", 10); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"hash":"` + validDatasetUploadHash + `","size":12}`),
+			ExpectedStatus: 507,
+			ExpectedContent: []string{
+				`"message":"Organization storage quota exceeded"`,
+			},
+		},
+		{
+			Name:   "confirm dataset presigned upload + valid token + local storage registered",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, s3, "LOCAL", "a,b,c\n1,2,3\n")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validDatasetUploadHash + `","size":12}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"hash":"` + validDatasetUploadHash + `"`,
+				`"version":"v2"`,
+				`"source_type":"LOCAL"`,
+				`"message":"Dataset successfully registered"`,
+			},
+		},
+		{
+			Name:   "confirm dataset presigned upload + valid token + s3 storage registered",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/presigned-upload/" + validDatasetUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetPresignedUpload(t, app, s3, test.TestS3SecretName, "a,b,c\n1,2,3\n")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validDatasetUploadHash + `","size":12}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"hash":"` + validDatasetUploadHash + `"`,
+				`"version":"v2"`,
+				`"path":"` + s3.URL + `/` + test.TestS3BucketName + `/` + testBlobKey(validDatasetUploadHash) + `"`,
+				`"source_type":"S3"`,
+				`"message":"Dataset successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetDatasetUploadSession(validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !session.IsComplete {
+					t.Fatal("Expected upload session to be complete")
+				}
+				key := fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/%s_dataset.csv", test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+				if _, ok := s3.Object(test.TestS3BucketName, key); ok {
+					t.Fatal("Expected uploaded file to be moved to its blob")
+				}
+				if _, ok := s3.Object(test.TestS3BucketName, testBlobKey(validDatasetUploadHash)); !ok {
+					t.Fatal("Expected blob to be stored")
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestGetDatasetPresignedDownload(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "get dataset presigned download + valid token + empty version",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v1/presigned-download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Dataset version has no file"`,
+			},
+		},
+		{
+			Name:   "get dataset presigned download + valid token + local storage proxied",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v2/presigned-download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetVersionFile(t, app, "a,b,c\n1,2,3\n", true)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"method":"GET"`,
+				`"url":"/api/org/` + test.ValidAdminUserOrgUuid.String() + `/dataset/Demo%20Dataset/branch/dev/version/v2/download"`,
+				`"proxied":true`,
+				`"message":"Dataset presigned download url created"`,
+			},
+		},
+		{
+			Name:   "get dataset presigned download + valid token + s3 storage presigned",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v2/presigned-download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+				key := fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/dataset.csv", test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid)
+				s3.PutObject(test.TestS3BucketName, key, []byte("a,b,c\n1,2,3\n"))
+				if _, err := app.Dao().RegisterDatasetFile(validDemoDatasetDevBranchUuid, "S3", s3.URL+"/"+test.TestS3BucketName, key, false, validDatasetUploadHash, "sha256", validDatasetUploadHash, "", uuid.NullUUID{}, nil, "{}", test.ValidAdminUserUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"method":"GET"`,
+				`"url":"` + s3.URL + `/` + test.TestS3BucketName + `/dataset-registry/` + test.ValidAdminUserOrgUuid.String(),
+				`X-Amz-Signature=`,
+				`"proxied":false`,
+				`"message":"Dataset presigned download url created"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// seedDatasetStream writes, in order, a log and a metric for dev/v1 of the
+// Demo Dataset, a review of dev/v1 into main and registers dev/v2.
+func seedDatasetStream(t *testing.T, app *test.TestApp) {
+	if _, err := app.Dao().CreateLogForDatasetVersion("epoch", "1", validDemoDatasetUuid); err != nil {
+		t.Fatal(err)
+	}
+	value := 0.5
+	step := int64(1)
+	_, err := app.Dao().CreateDatasetVersionMetrics(validDemoDatasetUuid, []commonmodels.MetricRequest{
+		{Key: "accuracy", Value: &value, Step: &step},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = app.Dao().CreateDatasetReview(validDemoDatasetUuid, test.ValidAdminUserUuid, validDemoDatasetDevBranchUuid, validDemoDatasetUuid, demoDatasetMainBranchUuid(t, app), "Promote v1", "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().RegisterDatasetFile(validDemoDatasetDevBranchUuid, "LOCAL", "", "dataset.csv", false, sha256Hex("v2"), "sha256", sha256Hex("v2"), "", uuid.NullUUID{}, nil, "{}", test.ValidAdminUserUuid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamDataset(t *testing.T) {
+	streamUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/stream"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "stream dataset + unauthorized",
+			Method:         http.MethodGet,
+			Url:            streamUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "stream dataset + valid token + invalid kind",
+			Method: http.MethodGet,
+			Url:    streamUrl + "?kind=log,file",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid event kind file, expected one of log, metric, version, review"`,
+			},
+		},
+		{
+			Name:   "stream dataset + valid token + invalid last event id",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "first",
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid last event id first"`,
+			},
+		},
+		{
+			Name:   "stream dataset + valid token + replay",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "0",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"retry: 3000\n\n",
+				"id: 1\nevent: log\ndata: {\"id\":1,\"kind\":\"log\",\"version_uuid\":\"" + validDemoDatasetUuid.String() + "\",\"data\":{\"key\":\"epoch\",\"data\":\"1\",\"type\":\"\"}",
+				"id: 2\nevent: metric\ndata: {\"id\":2,\"kind\":\"metric\",\"version_uuid\":\"" + validDemoDatasetUuid.String() + "\",\"data\":{\"key\":\"accuracy\",\"value\":0.5,\"step\":1,",
+				"id: 3\nevent: review\n",
+				`"title":"Promote v1","from_branch_uuid":"` + validDemoDatasetDevBranchUuid.String() + `","from_branch_version_uuid":"` + validDemoDatasetUuid.String() + `"`,
+				"id: 4\nevent: version\n",
+				`"version":"v2","branch":"dev","hash":"` + sha256Hex("v2") + `"`,
+			},
+		},
+		{
+			Name:   "stream dataset + valid token + resume",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "2",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 3\nevent: review\n",
+				"id: 4\nevent: version\n",
+			},
+			NotExpectedContent: []string{
+				"event: log",
+				"event: metric",
+			},
+		},
+		{
+			Name:   "stream dataset + valid token + resume from query + kind",
+			Method: http.MethodGet,
+			Url:    streamUrl + "?last_event_id=0&kind=version",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 4\nevent: version\n",
+			},
+			NotExpectedContent: []string{
+				"event: log",
+				"event: metric",
+				"event: review",
+			},
+		},
+		{
+			Name:   "stream dataset + valid token + new events only",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"retry: 3000\n\n",
+			},
+			NotExpectedContent: []string{
+				"event:",
+			},
+		},
+		{
+			Name:   "stream dataset + valid token + events written while streaming",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetStream(t, app)
+				app.Settings().Stream.PollInterval = 10
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					app.Dao().CreateLogForDatasetVersion("epoch", "2", validDemoDatasetUuid)
+				}()
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 5\nevent: log\n",
+				`"data":{"key":"epoch","data":"2","type":""}`,
+			},
+			NotExpectedContent: []string{
+				"id: 4\n",
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestStreamDatasetVersion(t *testing.T) {
+	streamUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v1/stream"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "stream dataset version + unauthorized",
+			Method:         http.MethodGet,
+			Url:            streamUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "stream dataset version + valid token + version not found",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v9/stream",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`Dataset Branch Version not found`,
+			},
+		},
+		{
+			Name:   "stream dataset version + valid token + invalid kind",
+			Method: http.MethodGet,
+			Url:    streamUrl + "?kind=version",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid event kind version, expected one of log, metric"`,
+			},
+		},
+		{
+			Name:   "stream dataset version + valid token + replay",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "0",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 1\nevent: log\n",
+				"id: 2\nevent: metric\n",
+			},
+			NotExpectedContent: []string{
+				"event: review",
+				"event: version",
+			},
+		},
+		{
+			Name:   "stream dataset version + valid token + metrics only",
+			Method: http.MethodGet,
+			Url:    streamUrl + "?kind=metric",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "0",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 2\nevent: metric\n",
+			},
+			NotExpectedContent: []string{
+				"event: log",
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+func TestDeleteDataset(t *testing.T) {
+	versionUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/version/v1/delete"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "delete dataset version + valid token",
+			Method: http.MethodDelete,
+			Url:    versionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"kind":"dataset_version"`,
+				`"name":"Demo Dataset/dev/v1"`,
+				`"message":"Dataset version moved to trash"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version != nil {
+					t.Fatal("Expected the version to be hidden once in the trash")
+				}
+			},
+		},
+		{
+			Name:   "delete dataset version + valid token + referenced by alias",
+			Method: http.MethodDelete,
+			Url:    versionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setDatasetAlias(t, e, "golden", "dev", "v1")
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"Version is referenced by the alias golden"`,
+			},
+		},
+		{
+			Name:   "delete dataset + valid token",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"kind":"dataset"`,
+				`"name":"Demo Dataset"`,
+				`"message":"Dataset moved to trash"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				dataset, err := app.Dao().GetDatasetByName(test.ValidAdminUserOrgUuid, "Demo Dataset")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if dataset != nil {
+					t.Fatal("Expected the dataset to be hidden once in the trash")
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,403 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	commondbmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/dbmodels"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	validDemoDatasetUuid          = uuid.Must(uuid.FromString("11111111-1111-1111-1111-111111111111"))
+	validDemoDatasetDevBranchUuid = uuid.Must(uuid.FromString("22222222-2222-2222-2222-222222222222"))
+	validDatasetUploadSessionUuid = uuid.Must(uuid.FromString("33333333-3333-3333-3333-333333333333"))
+	validDatasetUploadHash        = sha256Hex("a,b,c\n1,2,3\n")
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// testBlobKey returns the storage key of the admin org blob with the digest.
+func testBlobKey(digest string) string {
+	return fmt.Sprintf("blobs/%s/sha256/%s/%s/%s", test.ValidAdminUserOrgUuid, digest[0:2], digest[2:4], digest)
+}
+
+// seedDatasetUploadSession creates a LOCAL upload session on the Demo Dataset
+// dev branch and stores the provided chunks (numbered from 1) for it.
+func seedDatasetUploadSession(t *testing.T, app *test.TestApp, chunks ...string) {
+	session := dbmodels.UploadSession{
+		BaseModel: commondbmodels.BaseModel{
+			UUID: validDatasetUploadSessionUuid,
+		},
+		OrganizationUUID: test.ValidAdminUserOrgUuid,
+		DatasetBranchUUID: uuid.NullUUID{
+			UUID:  validDemoDatasetDevBranchUuid,
+			Valid: true,
+		},
+		FileName:  "dataset.csv",
+		Storage:   "LOCAL",
+		CreatedBy: test.ValidAdminUserUuid,
+	}
+	if err := app.Dao().Datastore().DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	for i, chunk := range chunks {
+		key := fmt.Sprintf("dataset-registry/%s/datasets/%s/%s/uploads/%s/%d", test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid, i+1)
+		if err := fs.Upload([]byte(chunk), key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.Dao().CreateUploadChunk(validDatasetUploadSessionUuid, i+1, int64(len(chunk))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateDatasetUploadSession(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "create dataset upload session + unauthorized",
+			Method:         http.MethodPost,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "create dataset upload session + valid token + file name empty",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"File name is required"`,
+			},
+		},
+		{
+			Name:   "create dataset upload session + valid token + session created",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"file_name":"dataset.csv","storage":"LOCAL"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"file_name":"dataset.csv"`,
+				`"storage":"LOCAL"`,
+				`"is_complete":false`,
+				`"message":"Dataset upload session created"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestUploadDatasetChunk(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "upload dataset chunk + valid token + session not found",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/chunk/1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader("chunk"),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Upload session not found"`,
+			},
+		},
+		{
+			Name:   "upload dataset chunk + valid token + chunk too large",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/chunk/1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  "application/octet-stream",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app)
+				app.Settings().Upload.MaxChunkSize = 4
+			},
+			Body:           strings.NewReader("first"),
+			ExpectedStatus: 413,
+			ExpectedContent: []string{
+				`"message":"Chunk exceeds the maximum size of 4 bytes"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetDatasetUploadSession(validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.ReceivedBytes != 0 {
+					t.Fatalf("Expected no received bytes, got %d", session.ReceivedBytes)
+				}
+			},
+		},
+		{
+			Name:   "upload dataset chunk + valid token + chunk uploaded",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/chunk/1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  "application/octet-stream",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app)
+			},
+			Body:           strings.NewReader("a,b,c"),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"number":1`,
+				`"size":5`,
+				`"message":"Dataset file chunk uploaded"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// concurrentFinalizes is the number of finalize requests sent at once for
+// the same upload session.
+const concurrentFinalizes = 10
+
+func TestFinalizeDatasetUpload(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "finalize dataset upload + valid token + hash already exists",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app, "a,b,c")
+			},
+			Body:           strings.NewReader(`{"hash":"1234567890"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Dataset with this hash already exists"`,
+			},
+		},
+		{
+			Name:   "finalize dataset upload + valid token + hash of a trashed version",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app, "a,b,c")
+				version, err := app.Dao().GetDatasetBranchVersion(validDemoDatasetDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := app.Dao().TrashDatasetVersion(test.ValidAdminUserOrgUuid, version.UUID, "Demo Dataset/dev/v1", test.ValidAdminUserUuid, time.Now().Add(time.Hour)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"hash":"1234567890"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Dataset with this hash already exists in the trash"`,
+			},
+		},
+		{
+			Name:   "finalize dataset upload + valid token + unsupported hash algorithm",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app, "a,b,c\n", "1,2,3\n")
+			},
+			Body:           strings.NewReader(`{"hash":"uploadhash","hash_algorithm":"crc32"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Unsupported hash algorithm"`,
+			},
+		},
+		{
+			Name:   "finalize dataset upload + valid token + hash mismatch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app, "a,b,c\n", "1,2,3\n")
+			},
+			Body:           strings.NewReader(`{"hash":"uploadhash"}`),
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Hash mismatch: expected sha256 uploadhash, computed ` + validDatasetUploadHash + `"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetDatasetUploadSession(validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.IsComplete {
+					t.Fatal("Expected upload session to stay open")
+				}
+				filePath := fmt.Sprintf("%s/storage/dataset-registry/%s/datasets/%s/%s/%s_dataset.csv", app.DataDir(), test.ValidAdminUserOrgUuid, validDemoDatasetUuid, validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+				if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+					t.Fatalf("Expected mismatched file to be deleted, got %v", err)
+				}
+			},
+		},
+		{
+			Name:   "finalize dataset upload + valid token + storage quota exceeded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app, "a,b,c\n", "1,2,3\n")
+				setOrgStorageQuota(t, app, 0, 10)
+			},
+			Body:           strings.NewReader(`{"hash":"` + validDatasetUploadHash + `"}`),
+			ExpectedStatus: 413,
+			ExpectedContent: []string{
+				`"message":"File exceeds the organization storage quota"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetDatasetUploadSession(validDemoDatasetDevBranchUuid, validDatasetUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.IsComplete {
+					t.Fatal("Expected upload session to stay open")
+				}
+				if _, err := os.Stat(filepath.Join(app.LocalStorageDir(), testBlobKey(validDatasetUploadHash))); !os.IsNotExist(err) {
+					t.Fatalf("Expected no blob to be stored, got %v", err)
+				}
+				// the chunks are kept for a retry
+				setOrgStorageQuota(t, app, 0, 0)
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/dataset/Demo%20Dataset/branch/dev/upload/"+validDatasetUploadSessionUuid.String()+"/finalize", strings.NewReader(`{"hash":"`+validDatasetUploadHash+`"}`))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				req.Header.Set("Authorization", test.ValidAdminToken)
+				e.ServeHTTP(recorder, req)
+				if recorder.Code != http.StatusOK {
+					t.Fatalf("Expected finalize retry to register the dataset, got status %d: %s", recorder.Code, recorder.Body.String())
+				}
+			},
+		},
+		{
+			Name:   "finalize dataset upload + valid token + concurrent finalizes",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app, "a,b,c\n", "1,2,3\n")
+				var wg sync.WaitGroup
+				statuses := make(chan int, concurrentFinalizes)
+				for i := 0; i < concurrentFinalizes; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						recorder := httptest.NewRecorder()
+						req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/dataset/Demo%20Dataset/branch/dev/upload/"+validDatasetUploadSessionUuid.String()+"/finalize", strings.NewReader(`{"hash":"`+validDatasetUploadHash+`"}`))
+						req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+						req.Header.Set("Authorization", test.ValidAdminToken)
+						e.ServeHTTP(recorder, req)
+						statuses <- recorder.Code
+					}()
+				}
+				wg.Wait()
+				close(statuses)
+				registered := 0
+				for status := range statuses {
+					switch status {
+					case http.StatusOK:
+						registered++
+					case http.StatusBadRequest, http.StatusConflict:
+					default:
+						t.Fatalf("Expected finalize to register or to be rejected as already finalized, got status %d", status)
+					}
+				}
+				if registered != 1 {
+					t.Fatalf("Expected exactly one finalize to register the dataset, got %d", registered)
+				}
+			},
+			Body:           strings.NewReader(`{"hash":"` + validDatasetUploadHash + `"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Upload session already finalized"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				versions, err := app.Dao().GetDatasetBranchAllVersions(validDemoDatasetDevBranchUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(versions) != 2 {
+					t.Fatalf("Expected 2 versions, got %d", len(versions))
+				}
+			},
+		},
+		{
+			Name:   "finalize dataset upload + valid token + registered successfully",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/dataset/Demo%20Dataset/branch/dev/upload/" + validDatasetUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedDatasetUploadSession(t, app, "a,b,c\n", "1,2,3\n")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validDatasetUploadHash + `","lineage":"{}"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"hash":"` + validDatasetUploadHash + `"`,
+				`"hash_algorithm":"sha256"`,
+				`"digests":{"sha256":"` + validDatasetUploadHash + `"}`,
+				`"version":"v2"`,
+				`"source_type":"LOCAL"`,
+				`"message":"Dataset successfully registered"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -35,8 +35,13 @@ type DatasetBranch struct {
 	Name                     string    `json:"name" gorm:"not null;index:idx_dataset_branch,unique"`
 	DatasetUUID              uuid.UUID `json:"dataset_uuid" gorm:"type:uuid;not null;index:idx_dataset_branch,unique"`
 	IsDefault                bool      `json:"is_default" default:"false"`
+	// the version the branch was created from, if any
+	BaseBranchUUID  uuid.NullUUID `json:"base_branch_uuid" gorm:"type:uuid;"`
+	BaseVersionUUID uuid.NullUUID `json:"base_version_uuid" gorm:"type:uuid;"`
 
-	Dataset Dataset `gorm:"foreignKey:DatasetUUID"`
+	Dataset     Dataset         `gorm:"foreignKey:DatasetUUID"`
+	BaseBranch  *DatasetBranch  `gorm:"foreignKey:BaseBranchUUID"`
+	BaseVersion *DatasetVersion `gorm:"foreignKey:BaseVersionUUID"`
 
 	Versions []DatasetVersion `gorm:"foreignKey:BranchUUID"`
 }
@@ -45,12 +50,22 @@ type DatasetVersion struct {
 	commondbmodels.BaseModel `gorm:"embedded"`
 	Version                  string        `json:"version" gorm:"not null;index:idx_dataset_branch_version,unique"`
 	Hash                     string        `json:"hash" gorm:"not null;index:idx_dataset_branch_hash,unique"`
+	HashAlgorithm            string        `json:"hash_algorithm"`
+	Digest                   string        `json:"digest"`
 	IsEmpty                  bool          `json:"is_empty"`
 	BranchUUID               uuid.UUID     `json:"branch_uuid" gorm:"type:uuid;not null;index:idx_dataset_branch_version,unique;index:idx_dataset_branch_hash,unique"`
 	LineageUUID              uuid.NullUUID `json:"lineage_uuid" gorm:"type:uuid;"`
 	PathUUID                 uuid.NullUUID `json:"path_uuid" gorm:"type:uuid;"`
 	Path                     string        `json:"path"`
 	SourceType               string        `json:"source_type"`
+	FileName                 string        `json:"file_name"`
+	BlobUUID                 uuid.NullUUID `json:"blob_uuid" gorm:"type:uuid;"`
+	EncryptionKey            string        `json:"encryption_key"`
+	EncryptionKeyVersion     int           `json:"encryption_key_version"`
+	IsManifest               bool          `json:"is_manifest"`
+	IsReferenced             bool          `json:"is_referenced"`
+	Size                     int64         `json:"size"`
+	ETag                     string        `json:"etag"`
 	CreatedBy                uuid.UUID     `json:"created_by" gorm:"type:uuid;"`
 
 	Branch        DatasetBranch        `gorm:"foreignKey:BranchUUID"`
@@ -23,6 +23,10 @@ func ValidateDatasetBranchVersion(app core.App) echo.MiddlewareFunc {
 				return nil
 			}
 			version, err := app.Dao().GetDatasetBranchVersion(datasetBranchUUID, datasetBranchVersion)
+			if err == nil && version == nil {
+				// the version may be an alias of a version of the branch
+				version, err = resolveDatasetAlias(app, context, datasetBranchVersion)
+			}
 			if err != nil {
 				context.Response().WriteHeader(http.StatusInternalServerError)
 				_, err = context.Response().Writer.Write([]byte(err.Error()))
@@ -47,3 +51,27 @@ func ValidateDatasetBranchVersion(app core.App) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// resolveDatasetAlias returns the version of the dataset branch the alias points
+// to, if any. The version path param is replaced by the resolved version so
+// that the handlers see the actual version.
+func resolveDatasetAlias(app core.App, context echo.Context, name string) (*models.DatasetBranchVersionResponse, error) {
+	datasetUUID := context.Get(ContextDatasetKey).(*models.DatasetNameResponse).UUID
+	datasetBranchUUID := context.Get(ContextDatasetBranchKey).(*models.DatasetBranchNameResponse).UUID
+	alias, err := app.Dao().GetDatasetAlias(datasetUUID, name)
+	if err != nil || alias == nil {
+		return nil, err
+	}
+	version, err := app.Dao().GetDatasetBranchVersion(datasetBranchUUID, alias.Version)
+	if err != nil || version == nil || version.UUID != alias.VersionUUID {
+		return nil, err
+	}
+	values := context.ParamValues()
+	for i, paramName := range context.ParamNames() {
+		if paramName == "version" && i < len(values) {
+			values[i] = version.Version
+		}
+	}
+	context.SetParamValues(values...)
+	return version, nil
+}
@@ -18,14 +18,19 @@ type CreateDatasetRequest struct {
 }
 
 type CreateDatasetBranchRequest struct {
-	BranchName string `json:"branch_name"`
+	BranchName  string `json:"branch_name"`
+	FromBranch  string `json:"from_branch"`
+	FromVersion string `json:"from_version"`
 }
 
 type RegisterDatasetRequest struct {
-	Hash       string `json:"hash"`
-	Lineage    string `json:"lineage"`
-	Storage    string `json:"storage"`
-	IsEmpty    bool   `json:"is_empty"`
+	Hash          string `json:"hash"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	Lineage       string `json:"lineage"`
+	Storage       string `json:"storage"`
+	IsEmpty       bool   `json:"is_empty"`
+	URI           string `json:"uri"`
+	ComputeHash   bool   `json:"compute_hash"`
 }
 
 type LogFileRequest struct {
@@ -78,10 +83,17 @@ type DatasetBranchNameResponse struct {
 }
 
 type DatasetBranchResponse struct {
-	UUID      uuid.UUID           `json:"uuid"`
-	Name      string              `json:"name"`
-	Dataset   DatasetNameResponse `json:"dataset"`
-	IsDefault bool                `json:"is_default"`
+	UUID      uuid.UUID                  `json:"uuid"`
+	Name      string                     `json:"name"`
+	Dataset   DatasetNameResponse        `json:"dataset"`
+	IsDefault bool                       `json:"is_default"`
+	Base      *DatasetBranchBaseResponse `json:"base,omitempty"`
+}
+
+// DatasetBranchBaseResponse is the version a branch was created from.
+type DatasetBranchBaseResponse struct {
+	Branch  DatasetBranchNameResponse        `json:"branch"`
+	Version DatasetBranchVersionNameResponse `json:"version"`
 }
 
 type DatasetBranchVersionNameResponse struct {
@@ -90,16 +102,25 @@ type DatasetBranchVersionNameResponse struct {
 }
 
 type DatasetBranchVersionResponse struct {
-	UUID       uuid.UUID                        `json:"uuid"`
-	Version    string                           `json:"version"`
-	Branch     DatasetBranchNameResponse        `json:"branch"`
-	Lineage    LineageResponse                  `json:"lineage"`
-	Hash       string                           `json:"hash"`
-	Path       string                           `json:"path"`
-	SourceType string                           `json:"source_type"`
-	IsEmpty    bool                             `json:"is_empty"`
-	CreatedBy  userorgmodels.UserHandleResponse `json:"created_by"`
-	CreatedAt  time.Time                        `json:"created_at"`
+	UUID          uuid.UUID                        `json:"uuid"`
+	Version       string                           `json:"version"`
+	Branch        DatasetBranchNameResponse        `json:"branch"`
+	Lineage       LineageResponse                  `json:"lineage"`
+	Hash          string                           `json:"hash"`
+	HashAlgorithm string                           `json:"hash_algorithm"`
+	Digests       map[string]string                `json:"digests"`
+	Path          string                           `json:"path"`
+	SourceType    string                           `json:"source_type"`
+	FileName      string                           `json:"file_name"`
+	IsEmpty       bool                             `json:"is_empty"`
+	Encrypted     bool                             `json:"encrypted"`
+	IsManifest    bool                             `json:"is_manifest"`
+	IsReferenced  bool                             `json:"is_referenced"`
+	Size          int64                            `json:"size,omitempty"`
+	ETag          string                           `json:"etag,omitempty"`
+	DataKey       *commonmodels.DataKey            `json:"-"`
+	CreatedBy     userorgmodels.UserHandleResponse `json:"created_by"`
+	CreatedAt     time.Time                        `json:"created_at"`
 }
 
 type LineageResponse struct {
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/PureMLHQ/PureML/packages/purebackend"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/settings"
@@ -24,6 +25,18 @@ func main() {
 		}
 	}
 
+	var trashRetention int64
+	if retentionEnv := os.Getenv("PURE_TRASH_RETENTION"); retentionEnv != "" {
+		trashRetention, err = strconv.ParseInt(retentionEnv, 10, 64)
+		if err != nil {
+			log.Fatal("PURE_TRASH_RETENTION is not a number")
+		}
+	}
+	var importAllowedHosts []string
+	if hosts := os.Getenv("PURE_IMPORT_ALLOWED_HOSTS"); hosts != "" {
+		importAllowedHosts = strings.Split(hosts, ",")
+	}
+
 	appSettings := &settings.Settings{
 		// set the default settings
 		S3: settings.S3Config{
@@ -45,6 +58,18 @@ func main() {
 		Search: settings.SearchConfig{
 			Enabled: false,
 		},
+		GC: settings.GCConfig{
+			Enabled: os.Getenv("PURE_GC_ENABLE") == "true",
+		},
+		Trash: settings.TrashConfig{
+			Retention: trashRetention,
+		},
+		Encryption: settings.EncryptionConfig{
+			MasterKey: os.Getenv("PURE_ENCRYPTION_MASTER_KEY"),
+		},
+		Import: settings.ImportConfig{
+			AllowedHosts: importAllowedHosts,
+		},
 		Site: settings.SiteConfig{
 			BaseURL: os.Getenv("PURE_SITE_BASE_URL"),
 		},
@@ -159,11 +159,18 @@ func (api *Api) CreateModel(request *models.Request) *models.Response {
 	if model != nil {
 		return models.NewErrorResponse(http.StatusBadRequest, "Model already exists")
 	}
+	trashed, err := api.app.Dao().IsModelNameTrashed(orgId, modelName)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if trashed {
+		return models.NewErrorResponse(http.StatusBadRequest, "Model with this name is in the trash")
+	}
 	model, err = api.app.Dao().CreateModel(orgId, modelName, modelWikiData, modelIsPublicData, modelReadmeData, userUUID)
 	if err != nil {
 		return models.NewServerErrorResponse(err)
 	}
-	_, err = api.app.Dao().CreateModelBranches(model.UUID, modelBranchNamesData)
+	_, err = api.app.Dao().CreateModelBranches(orgId, model.UUID, modelBranchNamesData, userUUID)
 	if err != nil {
 		return models.NewServerErrorResponse(err)
 	}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+)
+
+// BindModelAliasApi registers the model version alias api endpoints and the corresponding handlers.
+func BindModelAliasApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.GET("/:modelName/alias", api.DefaultHandler(GetModelAliases), middlewares.ValidateModel(api.app))
+	modelGroup.GET("/:modelName/alias/:aliasName", api.DefaultHandler(GetModelAlias), middlewares.ValidateModel(api.app))
+	modelGroup.POST("/:modelName/alias/:aliasName", api.DefaultHandler(SetModelAlias), middlewares.ValidateModel(api.app))
+	modelGroup.GET("/:modelName/alias/:aliasName/history", api.DefaultHandler(GetModelAliasHistory), middlewares.ValidateModel(api.app))
+	modelGroup.DELETE("/:modelName/alias/:aliasName/delete", api.DefaultHandler(DeleteModelAlias), middlewares.ValidateModel(api.app))
+}
+
+// GetModelAliases godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get all aliases of a model
+//	@Description	Get all version aliases of a model with the branch and version they point to
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/alias [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+func (api *Api) GetModelAliases(request *models.Request) *models.Response {
+	aliases, err := api.app.Dao().GetModelAliases(request.GetModelUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, aliases, "All model aliases")
+}
+
+// GetModelAlias godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get a model alias
+//	@Description	Get the branch and version a model alias points to
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/alias/{aliasName} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			aliasName	path	string	true	"Alias Name"
+func (api *Api) GetModelAlias(request *models.Request) *models.Response {
+	alias, err := api.app.Dao().GetModelAlias(request.GetModelUUID(), request.GetPathParam("aliasName"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if alias == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Alias not found")
+	}
+	return models.NewDataResponse(http.StatusOK, alias, "Model alias details")
+}
+
+// SetModelAlias godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Set a model alias
+//	@Description	Point a model alias (eg. production) to a branch version, creating the alias if needed. The move is recorded in the alias history. The alias can then be used wherever a version is accepted on that branch
+//	@Tags			Model
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/alias/{aliasName} [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			modelName	path	string								true	"Model Name"
+//	@Param			aliasName	path	string								true	"Alias Name"
+//	@Param			data		body	commonmodels.VersionAliasRequest	true	"Branch and version of the alias"
+func (api *Api) SetModelAlias(request *models.Request) *models.Response {
+	aliasName := request.GetPathParam("aliasName")
+	if !models.IsValidAliasName(aliasName) {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid alias name %s", aliasName))
+	}
+	request.ParseJsonBody()
+	branchName, _ := request.GetParsedBodyAttribute("branch").(string)
+	versionName, _ := request.GetParsedBodyAttribute("version").(string)
+	if branchName == "" || versionName == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Branch and version are required")
+	}
+	branch, err := api.app.Dao().GetModelBranchByName(request.GetOrgId(), request.GetModelName(), branchName)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if branch == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Branch not found")
+	}
+	version, err := api.app.Dao().GetModelBranchVersion(branch.UUID, versionName)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	alias, err := api.app.Dao().SetModelAlias(request.GetModelUUID(), aliasName, version.UUID, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, alias, "Model alias updated")
+}
+
+// GetModelAliasHistory godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the history of a model alias
+//	@Description	Get the moves of a model alias, latest first, with who moved it and when
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/alias/{aliasName}/history [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			aliasName	path	string	true	"Alias Name"
+func (api *Api) GetModelAliasHistory(request *models.Request) *models.Response {
+	history, err := api.app.Dao().GetModelAliasHistory(request.GetModelUUID(), request.GetPathParam("aliasName"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if len(history) == 0 {
+		return models.NewErrorResponse(http.StatusNotFound, "Alias not found")
+	}
+	return models.NewDataResponse(http.StatusOK, history, "Model alias history")
+}
+
+// DeleteModelAlias godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Delete a model alias
+//	@Description	Remove a model alias. The removal is recorded in the alias history
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/alias/{aliasName}/delete [delete]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			aliasName	path	string	true	"Alias Name"
+func (api *Api) DeleteModelAlias(request *models.Request) *models.Response {
+	aliasName := request.GetPathParam("aliasName")
+	alias, err := api.app.Dao().GetModelAlias(request.GetModelUUID(), aliasName)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if alias == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Alias not found")
+	}
+	err = api.app.Dao().DeleteModelAlias(request.GetModelUUID(), aliasName, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, nil, "Model alias deleted")
+}
+
+var GetModelAliases ServiceFunc = (*Api).GetModelAliases
+var GetModelAlias ServiceFunc = (*Api).GetModelAlias
+var SetModelAlias ServiceFunc = (*Api).SetModelAlias
+var GetModelAliasHistory ServiceFunc = (*Api).GetModelAliasHistory
+var DeleteModelAlias ServiceFunc = (*Api).DeleteModelAlias
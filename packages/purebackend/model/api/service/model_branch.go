@@ -1,14 +1,19 @@
 package service
 
 import (
+	"fmt"
 	"net/http"
 
 	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	coreservice "github.com/PureMLHQ/PureML/packages/purebackend/core/apis/service"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/trash"
 	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	modelmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/models"
 	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
 	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
 )
 
 // BindModelBranchApi registers the admin api endpoints and the corresponding handlers.
@@ -19,6 +24,8 @@ func BindModelBranchApi(app core.App, rg *echo.Group) {
 	modelGroup.GET("/:modelName/branch", api.DefaultHandler(GetModelAllBranches), middlewares.ValidateModel(api.app))
 	modelGroup.POST("/:modelName/branch/create", api.DefaultHandler(CreateModelBranch), middlewares.ValidateModel(api.app))
 	modelGroup.GET("/:modelName/branch/:branchName", api.DefaultHandler(GetModelBranch), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/update", api.DefaultHandler(UpdateModelBranch), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.DELETE("/:modelName/branch/:branchName/delete", api.DefaultHandler(DeleteModelBranch), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
 }
 
 // GetModelAllBranches godoc
@@ -74,7 +81,7 @@ func (api *Api) GetModelBranch(request *models.Request) *models.Response {
 //
 //	@Security		ApiKeyAuth
 //	@Summary		Create a new branch of a model
-//	@Description	Create a new branch of a model
+//	@Description	Create a new branch of a model. The branch starts empty, or with a copy of from_version of from_branch when they are given
 //	@Tags			Model
 //	@Accept			*/*
 //	@Produce		json
@@ -93,47 +100,84 @@ func (api *Api) CreateModelBranch(request *models.Request) *models.Response {
 	} else {
 		modelBranchNameData = modelBranchName.(string)
 	}
-	if modelBranchNameData == "" {
-		return models.NewErrorResponse(http.StatusBadRequest, "Branch name cannot be empty")
+	isTaken := func(name string) (bool, error) {
+		return api.app.Dao().IsModelBranchNameTaken(modelUUID, name)
+	}
+	if errresp := coreservice.ValidateBranchName(modelBranchNameData, isTaken); errresp != nil {
+		return errresp
 	}
 	modelBranches, err := api.app.Dao().GetModelAllBranches(modelUUID)
 	if err != nil {
 		return models.NewErrorResponse(http.StatusInternalServerError, err.Error())
 	}
-	for _, branch := range modelBranches {
-		if branch.Name == modelBranchNameData {
-			return models.NewErrorResponse(http.StatusBadRequest, "Branch already exists")
+	fromBranch, _ := request.GetParsedBodyAttribute("from_branch").(string)
+	fromVersion, _ := request.GetParsedBodyAttribute("from_version").(string)
+	if fromBranch == "" && fromVersion == "" {
+		modelBranch, err := api.app.Dao().CreateModelBranch(modelUUID, modelBranchNameData)
+		if err != nil {
+			return models.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		}
+		return models.NewDataResponse(http.StatusOK, modelBranch, "Model branch created")
+	}
+	if fromBranch == "" || fromVersion == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Both from_branch and from_version are required to create a branch from a version")
+	}
+	var baseBranch *modelmodels.ModelBranchResponse
+	for i := range modelBranches {
+		if modelBranches[i].Name == fromBranch {
+			baseBranch = &modelBranches[i]
 		}
 	}
-	modelBranch, err := api.app.Dao().CreateModelBranch(modelUUID, modelBranchNameData)
+	if baseBranch == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "From branch not found")
+	}
+	baseVersion, err := api.app.Dao().GetModelBranchVersion(baseBranch.UUID, fromVersion)
 	if err != nil {
-		return models.NewErrorResponse(http.StatusInternalServerError, err.Error())
+		return models.NewServerErrorResponse(err)
+	}
+	if baseVersion == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "From version not found")
+	}
+	modelBranch, err := api.app.Dao().CreateModelBranchFromVersion(modelUUID, modelBranchNameData, baseVersion.UUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
 	}
 	return models.NewDataResponse(http.StatusOK, modelBranch, "Model branch created")
 }
 
-// TODO: UpdateModelBranch godoc
+// UpdateModelBranch godoc
 //
 //	@Security		ApiKeyAuth
 //	@Summary		Update a branch of a model
-//	@Description	Update a branch of a model
+//	@Description	Rename a branch of a model, or make it the default branch of the model in place of the current one
 //	@Tags			Model
-//	@Accept			*/*
+//	@Accept			json
 //	@Produce		json
 //	@Success		200	{object}	map[string]interface{}
 //	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/update [post]
 //	@Param			orgId		path	string	true	"Organization Id"
 //	@Param			modelName	path	string	true	"Model Name"
 //	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			data		body	object	true	"branch_name and is_default"
 func (api *Api) UpdateModelBranch(request *models.Request) *models.Response {
-	return nil
+	modelUUID := request.GetModelUUID()
+	branch, err := api.app.Dao().GetModelBranchByUUID(request.GetModelBranchUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	isTaken := func(name string) (bool, error) {
+		return api.app.Dao().IsModelBranchNameTaken(modelUUID, name)
+	}
+	return coreservice.UpdateBranch(request, "Model", branch.Name, branch.IsDefault, isTaken, func(name string, isDefault bool) (interface{}, error) {
+		return api.app.Dao().UpdateModelBranch(modelUUID, branch.UUID, name, isDefault)
+	})
 }
 
-// TODO: DeleteModelBranch godoc
+// DeleteModelBranch godoc
 //
 //	@Security		ApiKeyAuth
 //	@Summary		Delete a branch of a model
-//	@Description	Delete a branch of a model
+//	@Description	Move a branch of a model with all its versions to the trash of the organization. It can be restored until it is purged, its versions, logs and reviews are removed when it is purged. The default branch and a branch referenced by an alias or an open review cannot be deleted
 //	@Tags			Model
 //	@Accept			*/*
 //	@Produce		json
@@ -143,7 +187,30 @@ func (api *Api) UpdateModelBranch(request *models.Request) *models.Response {
 //	@Param			modelName	path	string	true	"Model Name"
 //	@Param			branchName	path	string	true	"Branch Name"
 func (api *Api) DeleteModelBranch(request *models.Request) *models.Response {
-	return nil
+	branchUUID := request.GetModelBranchUUID()
+	branch, err := api.app.Dao().GetModelBranchByUUID(branchUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if branch.IsDefault {
+		return models.NewErrorResponse(http.StatusBadRequest, "Default branch cannot be deleted")
+	}
+	protection, err := api.app.Dao().GetModelBranchProtection(branchUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if protection != nil && protection.BlockDeletion {
+		return models.NewErrorResponse(http.StatusBadRequest, "Branch is protected against deletion")
+	}
+	if errresp := api.validateModelUnreferenced(request, "Branch", uuid.NullUUID{UUID: branchUUID, Valid: true}, uuid.NullUUID{}); errresp != nil {
+		return errresp
+	}
+	name := fmt.Sprintf("%s/%s", request.GetModelName(), request.GetModelBranchName())
+	item, err := api.app.Dao().TrashModelBranch(request.GetOrgId(), branchUUID, name, request.GetUserUUID(), trash.PurgeTime(api.app))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, item, "Model branch moved to trash")
 }
 
 var GetModelAllBranches ServiceFunc = (*Api).GetModelAllBranches
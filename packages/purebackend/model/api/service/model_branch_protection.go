@@ -0,0 +1,245 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindModelBranchProtectionApi registers the admin api endpoints and the corresponding handlers.
+func BindModelBranchProtectionApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/protection", api.DefaultHandler(GetModelBranchProtection), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/protection", api.DefaultHandler(SetModelBranchProtection), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.DELETE("/:modelName/branch/:branchName/protection/delete", api.DefaultHandler(DeleteModelBranchProtection), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.POST("/:modelName/review/:reviewId/approve", api.DefaultHandler(ApproveModelReview), middlewares.ValidateModel(api.app))
+}
+
+// GetModelBranchProtection godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the protection rules of a model branch
+//	@Description	Get the protection rules of a model branch
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/protection [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+func (api *Api) GetModelBranchProtection(request *models.Request) *models.Response {
+	protection, err := api.app.Dao().GetModelBranchProtection(request.GetModelBranchUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if protection == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Branch is not protected")
+	}
+	return models.NewDataResponse(http.StatusOK, protection, "Model branch protection")
+}
+
+// SetModelBranchProtection godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Set the protection rules of a model branch
+//	@Description	Block direct registration or deletion of a model branch, require approvals before a review is merged into it and restrict who can accept its reviews. Only organization owners can protect branches
+//	@Tags			Model
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/protection [post]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			data		body	object	true	"block_direct_registration, required_approvals, block_deletion and reviewers (user handles)"
+func (api *Api) SetModelBranchProtection(request *models.Request) *models.Response {
+	if errresp := api.validateModelOrgOwner(request, "You are not authorized to protect the branches of this model"); errresp != nil {
+		return errresp
+	}
+	request.ParseJsonBody()
+	rules, errresp := api.parseBranchProtectionRules(request)
+	if errresp != nil {
+		return errresp
+	}
+	protection, err := api.app.Dao().SetModelBranchProtection(request.GetOrgId(), request.GetModelBranchUUID(), *rules, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, protection, "Model branch protection updated")
+}
+
+// DeleteModelBranchProtection godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Remove the protection rules of a model branch
+//	@Description	Remove the protection rules of a model branch. Only organization owners can unprotect branches
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/protection/delete [delete]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+func (api *Api) DeleteModelBranchProtection(request *models.Request) *models.Response {
+	if errresp := api.validateModelOrgOwner(request, "You are not authorized to unprotect the branches of this model"); errresp != nil {
+		return errresp
+	}
+	err := api.app.Dao().DeleteModelBranchProtection(request.GetModelBranchUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, nil, "Model branch protection removed")
+}
+
+// ApproveModelReview godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Approve a review of a model
+//	@Description	Approve a review of a model. Protected branches can require approvals before a review is merged into them
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/review/{reviewId}/approve [post]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			reviewId	path	string	true	"Review UUID"
+func (api *Api) ApproveModelReview(request *models.Request) *models.Response {
+	reviewUUID, err := uuid.FromString(request.GetPathParam("reviewId"))
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid review id")
+	}
+	review, err := api.app.Dao().GetModelReview(reviewUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if review == nil || review.Model.UUID != request.GetModelUUID() {
+		return models.NewErrorResponse(http.StatusNotFound, "Review with given ID not found")
+	}
+	if review.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Review already complete")
+	}
+	userUUID := request.GetUserUUID()
+	if review.CreatedBy.UUID == userUUID {
+		return models.NewErrorResponse(http.StatusBadRequest, "You cannot approve your own review")
+	}
+	protection, err := api.app.Dao().GetModelBranchProtection(review.ToBranch.UUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if !isBranchReviewer(protection, userUUID) {
+		return models.NewErrorResponse(http.StatusForbidden, "You are not a reviewer of this branch")
+	}
+	approval, err := api.app.Dao().ApproveModelReview(reviewUUID, userUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, approval, "Model review approved")
+}
+
+// parseBranchProtectionRules reads the protection rules from the request
+// body, resolving the reviewer handles to members of the organization.
+func (api *Api) parseBranchProtectionRules(request *models.Request) (*models.BranchProtectionRules, *models.Response) {
+	rules := &models.BranchProtectionRules{}
+	rules.BlockDirectRegistration, _ = request.GetParsedBodyAttribute("block_direct_registration").(bool)
+	rules.BlockDeletion, _ = request.GetParsedBodyAttribute("block_deletion").(bool)
+	var requiredApprovals float64
+	if value := request.GetParsedBodyAttribute("required_approvals"); value != nil {
+		var ok bool
+		requiredApprovals, ok = value.(float64)
+		if !ok || requiredApprovals < 0 || requiredApprovals != float64(int(requiredApprovals)) {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, "required_approvals must be a non-negative integer")
+		}
+	}
+	rules.RequiredApprovals = int(requiredApprovals)
+	reviewers, _ := request.GetParsedBodyAttribute("reviewers").([]interface{})
+	for _, reviewer := range reviewers {
+		handle, _ := reviewer.(string)
+		user, err := api.app.Dao().GetUserByHandle(handle)
+		if err != nil {
+			return nil, models.NewServerErrorResponse(err)
+		}
+		if user == nil {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("User %s not found", handle))
+		}
+		userOrganization, err := api.app.Dao().GetUserOrganizationByOrgIdAndUserUUID(request.GetOrgId(), user.UUID)
+		if err != nil {
+			return nil, models.NewServerErrorResponse(err)
+		}
+		if userOrganization == nil {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("User %s is not a member of the organization", handle))
+		}
+		rules.Reviewers = append(rules.Reviewers, user.UUID)
+	}
+	return rules, nil
+}
+
+// isBranchReviewer reports whether the user may approve and accept the
+// reviews of the protected branch. Anyone can when no reviewers are listed.
+func isBranchReviewer(protection *models.BranchProtectionResponse, userUUID uuid.UUID) bool {
+	if protection == nil || len(protection.Reviewers) == 0 {
+		return true
+	}
+	for _, reviewer := range protection.Reviewers {
+		if reviewer.UUID == userUUID {
+			return true
+		}
+	}
+	return false
+}
+
+func (api *Api) validateModelBranchRegistration(request *models.Request) *models.Response {
+	protection, err := api.app.Dao().GetModelBranchProtection(request.GetModelBranchUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if protection != nil && protection.BlockDirectRegistration {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Cannot register model directly to %s branch", request.GetPathParam("branchName")))
+	}
+	return nil
+}
+
+// validateModelReviewMerge checks that the user may accept the review into
+// the branch. The review is nil while it is being created.
+func (api *Api) validateModelReviewMerge(toBranchUUID uuid.UUID, reviewUUID uuid.NullUUID, userUUID uuid.UUID) *models.Response {
+	protection, err := api.app.Dao().GetModelBranchProtection(toBranchUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if protection == nil {
+		return nil
+	}
+	if !isBranchReviewer(protection, userUUID) {
+		return models.NewErrorResponse(http.StatusForbidden, "You are not allowed to accept reviews into this branch")
+	}
+	if protection.RequiredApprovals == 0 {
+		return nil
+	}
+	var approvals int64
+	if reviewUUID.Valid {
+		approvals, err = api.app.Dao().CountModelReviewApprovals(reviewUUID.UUID)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+	}
+	if approvals < int64(protection.RequiredApprovals) {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Review needs %d approvals before merge", protection.RequiredApprovals))
+	}
+	return nil
+}
+
+var GetModelBranchProtection ServiceFunc = (*Api).GetModelBranchProtection
+var SetModelBranchProtection ServiceFunc = (*Api).SetModelBranchProtection
+var DeleteModelBranchProtection ServiceFunc = (*Api).DeleteModelBranchProtection
+var ApproveModelReview ServiceFunc = (*Api).ApproveModelReview
@@ -3,12 +3,15 @@ package service
 import (
 	"fmt"
 	"net/http"
+	"path"
 	"strings"
 
 	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core"
 	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/filesystem"
 	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
 	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
@@ -25,6 +28,7 @@ func BindModelBranchVersionApi(app core.App, rg *echo.Group) {
 	modelGroup.POST("/:modelName/branch/:branchName/register", api.DefaultHandler(RegisterModel), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
 	modelGroup.GET("/:modelName/branch/:branchName/version", api.DefaultHandler(GetModelBranchAllVersions), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
 	modelGroup.GET("/:modelName/branch/:branchName/version/:version", api.DefaultHandler(GetModelBranchVersion), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/download", api.FileHandler(DownloadModelBranchVersion), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
 }
 
 // GetModelBranchAllVersions godoc
@@ -40,6 +44,7 @@ func BindModelBranchVersionApi(app core.App, rg *echo.Group) {
 //	@Param			orgId		path	string	true	"Organization Id"
 //	@Param			modelName	path	string	true	"Model Name"
 //	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			tag			query	string	false	"Only the versions with the tag"
 func (api *Api) GetModelBranchAllVersions(request *models.Request) *models.Response {
 	var response *models.Response
 	branchUUID := request.GetModelBranchUUID()
@@ -47,6 +52,13 @@ func (api *Api) GetModelBranchAllVersions(request *models.Request) *models.Respo
 	allVersions, err := api.app.Dao().GetModelBranchAllVersions(branchUUID, withLogs)
 	if err != nil {
 		return models.NewServerErrorResponse(err)
+	}
+	if tag := request.GetQueryParam("tag"); tag != "" {
+		allVersions, err = api.filterModelVersionsByTag(request.GetModelUUID(), allVersions, tag)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		response = models.NewDataResponse(http.StatusOK, allVersions, "All model branch versions")
 	} else {
 		response = models.NewDataResponse(http.StatusOK, allVersions, "All model branch versions")
 	}
@@ -80,6 +92,68 @@ func (api *Api) GetModelBranchVersion(request *models.Request) *models.Response
 	return models.NewDataResponse(http.StatusOK, version, "Model branch version details")
 }
 
+// DownloadModelBranchVersion godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Download specific branch version file of a model
+//	@Description	Download the file of a specific branch version of a model. Supports Range, If-Range and If-None-Match requests to resume large downloads
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		octet-stream
+//	@Success		200	{file}	binary
+//	@Success		206	{file}	binary
+//	@Success		304
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/download [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) DownloadModelBranchVersion(request *models.Request) (*models.FileResponse, *models.Response) {
+	orgId := request.GetOrgId()
+	branchUUID := request.GetModelBranchUUID()
+	versionName := request.PathParams["version"]
+	version, err := api.app.Dao().GetModelBranchVersion(branchUUID, versionName)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	if version.IsManifest {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Model version has multiple files, download them individually")
+	}
+	if version.IsEmpty || version.Path == "" {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Model version has no file")
+	}
+	sourceSecrets, fileKey, errresp := api.GetStoredFileSourceSecrets(version.SourceType, version.Path, orgId)
+	if errresp != nil {
+		return nil, errresp
+	}
+	fileName := version.FileName
+	if fileName == "" {
+		fileName = path.Base(fileKey)
+	}
+	etag := version.Hash
+	if digest, ok := version.Digests["sha256"]; ok {
+		etag = digest
+	} else if version.IsReferenced && version.ETag != "" {
+		// the hash of a reference registered without compute_hash is not
+		// verified, the object etag is
+		etag = version.ETag
+	}
+	dataKey, err := encryption.Open(api.app, orgId, version.DataKey)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	return &models.FileResponse{
+		SourceSecrets: sourceSecrets,
+		Key:           fileKey,
+		Name:          fileName,
+		ETag:          etag,
+		DataKey:       dataKey,
+	}, nil
+}
+
 // VerifyModelBranchHashStatus godoc
 //
 //	@Security		ApiKeyAuth
@@ -137,13 +211,13 @@ func (api *Api) VerifyModelBranchHashStatus(request *models.Request) *models.Res
 //
 //	@Security		ApiKeyAuth
 //	@Summary		Register model
-//	@Description	Register model file. Create model and default branches if not exists
+//	@Description	Register model file. Create model and default branches if not exists. A version can reference an object already stored in a storage of the organization instead of uploading a file, the object is then never deleted nor moved by the registry
 //	@Tags			Model
 //	@Accept			*/*
 //	@Produce		json
 //	@Success		200	{object}	map[string]interface{}
 //	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/register [post]
-//	@Param			file		formData	file						true	"Model file"
+//	@Param			file		formData	file						false	"Model file (required unless uri is set)"
 //	@Param			orgId		path		string						true	"Organization UUID"
 //	@Param			modelName	path		string						true	"Model name"
 //	@Param			branchName	path		string						true	"Branch name"
@@ -153,12 +227,24 @@ func (api *Api) RegisterModel(request *models.Request) *models.Response {
 	userUUID := request.GetUserUUID()
 	modelUUID := request.GetModelUUID()
 	modelBranchUUID := request.GetModelBranchUUID()
+	var modelURI string
+	if request.FormValues["uri"] != nil && len(request.FormValues["uri"]) > 0 {
+		modelURI = request.FormValues["uri"][0]
+	}
 	var modelHash string
 	if request.FormValues["hash"] != nil && len(request.FormValues["hash"]) > 0 && request.FormValues["hash"][0] != "" {
 		modelHash = request.FormValues["hash"][0]
-	} else {
+	} else if modelURI == "" {
 		return models.NewErrorResponse(http.StatusBadRequest, "Hash is required")
 	}
+	var modelHashAlgorithm string
+	if request.FormValues["hash_algorithm"] != nil && len(request.FormValues["hash_algorithm"]) > 0 {
+		modelHashAlgorithm = request.FormValues["hash_algorithm"][0]
+	}
+	digester, errresp := api.NewDigester(modelHashAlgorithm)
+	if errresp != nil {
+		return errresp
+	}
 	var modelSourceSecretName string
 	if request.FormValues["storage"] != nil && len(request.FormValues["storage"]) > 0 {
 		modelSourceSecretName = request.FormValues["storage"][0]
@@ -168,69 +254,121 @@ func (api *Api) RegisterModel(request *models.Request) *models.Response {
 		modelIsEmpty = request.FormValues["is_empty"][0] == "true"
 	}
 	fileHeader := request.GetFormFile("file")
-	if fileHeader == nil {
+	if fileHeader == nil && modelURI == "" {
 		return models.NewErrorResponse(http.StatusBadRequest, "File is required")
 	}
-	modelBranchName := request.GetPathParam("branchName")
-	if modelBranchName == "main" {
-		return models.NewErrorResponse(http.StatusBadRequest, "Cannot register model directly to main branch")
+	if errresp := api.validateModelBranchRegistration(request); errresp != nil {
+		return errresp
 	}
-	versions, err := api.app.Dao().GetModelBranchAllVersions(modelBranchUUID, true)
-	if err != nil {
-		return models.NewServerErrorResponse(err)
+	if modelURI != "" {
+		computeHash := request.FormValues["compute_hash"] != nil && len(request.FormValues["compute_hash"]) > 0 && request.FormValues["compute_hash"][0] == "true"
+		return api.RegisterModelReference(request, modelURI, modelSourceSecretName, modelHash, computeHash, digester)
 	}
-	response := false
-	for _, version := range versions {
-		if version.Hash == modelHash {
-			response = true
-			break
-		}
+	if errresp := api.checkModelVersionHash(modelBranchUUID, modelHash); errresp != nil {
+		return errresp
 	}
-	if response {
-		return models.NewErrorResponse(http.StatusBadRequest, "Model with this hash already exists")
+	modelSourceSecrets, errresp := api.GetSourceSecrets(modelSourceSecretName, orgId)
+	if errresp != nil {
+		return errresp
 	}
-	var modelSourceType string
-	var modelSourceSecrets *commonmodels.SourceSecrets
-	var errresp *models.Response
-	if strings.ToUpper(modelSourceSecretName) != "LOCAL" {
-		modelSourceSecrets, errresp = api.ValidateSourceTypeAndGetSourceSecrets(modelSourceSecretName, orgId)
+	var filePath, hashAlgorithm, fileDigest, fileName string
+	var blobUUID uuid.NullUUID
+	var fileDataKey *commonmodels.DataKey
+	var softQuotaExceeded bool
+	if !modelIsEmpty {
+		file, err := filesystem.NewFileFromMultipart(fileHeader)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		dataKey, err := encryption.NewDataKey(api.app, orgId)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		uploadPath, err := api.app.UploadFile(file, fmt.Sprintf("model-registry/%s/models/%s/%s", orgId, modelUUID, modelBranchUUID), modelSourceSecrets, dataKey.Key, digester.Hashes()...)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		errresp = api.VerifyUploadedFileHash(digester, modelHash, uploadPath, modelSourceSecrets)
 		if errresp != nil {
 			return errresp
 		}
-		modelSourceType = modelSourceSecrets.SourceType
-	} else {
-		modelSourceType = "LOCAL"
-		modelSourceSecrets.SourceType = "LOCAL"
+		blob, exceeded, errresp := api.StoreUploadedBlob(orgId, modelUUID, modelSourceSecrets, uploadPath, digester.Sha256(), fileHeader.Size, dataKey)
+		if errresp != nil {
+			return errresp
+		}
+		softQuotaExceeded = exceeded
+		modelHash = digester.Sum()
+		hashAlgorithm = digester.Algorithm
+		fileDigest = blob.Digest
+		filePath = blob.Key
+		fileName = fileHeader.Filename
+		blobUUID = uuid.NullUUID{UUID: blob.UUID, Valid: true}
+		fileDataKey = blob.DataKey
 	}
-	sourceValid := false
-	for source := range commonmodels.SupportedSources {
-		if commonmodels.SupportedSources[source] == modelSourceType {
-			sourceValid = true
-			break
+	modelVersion, err := api.app.Dao().RegisterModelFile(modelBranchUUID, modelSourceSecrets.SourceType, modelSourceSecrets.PublicURL, filePath, modelIsEmpty, modelHash, hashAlgorithm, fileDigest, fileName, blobUUID, fileDataKey, userUUID)
+	if err != nil {
+		if blobUUID.Valid {
+			api.ReleaseBlob(modelSourceSecrets, blobUUID.UUID)
 		}
+		return registerVersionErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, modelVersion, withSoftQuotaWarning("Model successfully registered", softQuotaExceeded))
+}
+
+// RegisterModelReference registers a model version referencing the object
+// of the uri in the storage instead of uploading a file. The size and ETag
+// of the object are recorded, and its digest is computed by streaming it
+// once when requested or when no hash is provided.
+func (api *Api) RegisterModelReference(request *models.Request, uri string, storage string, hash string, computeHash bool, digester *digest.Digester) *models.Response {
+	orgId := request.GetOrgId()
+	modelBranchUUID := request.GetModelBranchUUID()
+	sourceSecrets, errresp := api.GetSourceSecrets(storage, orgId)
+	if errresp != nil {
+		return errresp
 	}
-	if !sourceValid {
-		return models.NewErrorResponse(http.StatusBadRequest, "Unsupported model storage")
+	key, errresp := api.ResolveReference(uri, sourceSecrets)
+	if errresp != nil {
+		return errresp
 	}
-	var filePath string
-	if !modelIsEmpty {
-		file, err := filesystem.NewFileFromMultipart(fileHeader)
-		if err != nil {
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	exists, err := fs.Exists(key)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if !exists {
+		return models.NewErrorResponse(http.StatusNotFound, "Referenced object not found")
+	}
+	attrs, err := fs.Attributes(key)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	var fileDigest string
+	if computeHash || hash == "" {
+		if _, err := fs.Hash(key, digester.Hashes()...); err != nil {
 			return models.NewServerErrorResponse(err)
 		}
-		filePath, err = api.app.UploadFile(file, fmt.Sprintf("model-registry/%s/models/%s/%s", orgId, modelUUID, modelBranchUUID), modelSourceSecrets)
-		if err != nil {
-			return models.NewServerErrorResponse(err)
+		if hash != "" && !digest.Equal(digester.Sum(), hash) {
+			return models.NewErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("Hash mismatch: expected %s %s, computed %s", digester.Algorithm, hash, digester.Sum()))
 		}
+		hash = digester.Sum()
+		fileDigest = digester.Sha256()
 	}
-	modelVersion, err := api.app.Dao().RegisterModelFile(modelBranchUUID, modelSourceType, modelSourceSecrets.PublicURL, filePath, modelIsEmpty, modelHash, userUUID)
+	if errresp := api.checkModelVersionHash(modelBranchUUID, hash); errresp != nil {
+		return errresp
+	}
+	modelVersion, err := api.app.Dao().RegisterModelReference(modelBranchUUID, sourceSecrets.SourceType, sourceSecrets.PublicURL, key, hash, digester.Algorithm, fileDigest, attrs.Size, attrs.ETag, request.GetUserUUID())
 	if err != nil {
-		return models.NewServerErrorResponse(err)
+		return registerVersionErrorResponse(err)
 	}
 	return models.NewDataResponse(http.StatusOK, modelVersion, "Model successfully registered")
 }
 
 var GetModelBranchAllVersions ServiceFunc = (*Api).GetModelBranchAllVersions
 var GetModelBranchVersion ServiceFunc = (*Api).GetModelBranchVersion
+var DownloadModelBranchVersion FileServiceFunc = (*Api).DownloadModelBranchVersion
 var VerifyModelBranchHashStatus ServiceFunc = (*Api).VerifyModelBranchHashStatus
 var RegisterModel ServiceFunc = (*Api).RegisterModel
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindModelCompareApi registers the model compare api endpoints and the corresponding handlers.
+func BindModelCompareApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.GET("/:modelName/compare", api.DefaultHandler(CompareModelBranches), middlewares.ValidateModel(api.app))
+}
+
+// CompareModelBranches godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Compare two branches or versions of a model
+//	@Description	Compare the head branch of a model with the base branch. Lists the versions only on each side, the fork point and whether the base moved since, and diffs the logs, readme and files of the compared versions, which are the latest versions unless base_version or head_version are given
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/compare [get]
+//	@Param			orgId			path	string	true	"Organization Id"
+//	@Param			modelName		path	string	true	"Model Name"
+//	@Param			base			query	string	true	"Base branch name"
+//	@Param			head			query	string	true	"Head branch name"
+//	@Param			base_version	query	string	false	"Base version"
+//	@Param			head_version	query	string	false	"Head version"
+func (api *Api) CompareModelBranches(request *models.Request) *models.Response {
+	baseBranchName := request.GetQueryParam("base")
+	headBranchName := request.GetQueryParam("head")
+	if baseBranchName == "" || headBranchName == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Base and head branches are required")
+	}
+	baseBranchUUID, baseVersionUUID, errresp := api.compareModelRef(request, baseBranchName, request.GetQueryParam("base_version"))
+	if errresp != nil {
+		return errresp
+	}
+	headBranchUUID, headVersionUUID, errresp := api.compareModelRef(request, headBranchName, request.GetQueryParam("head_version"))
+	if errresp != nil {
+		return errresp
+	}
+	compare, err := api.app.Dao().CompareModelBranches(baseBranchUUID, baseVersionUUID, headBranchUUID, headVersionUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, compare, "Model branches compared")
+}
+
+// compareModelRef resolves a side of a comparison to the branch and, when
+// given, the version of the branch.
+func (api *Api) compareModelRef(request *models.Request, branchName string, versionName string) (uuid.UUID, uuid.NullUUID, *models.Response) {
+	branch, err := api.app.Dao().GetModelBranchByName(request.GetOrgId(), request.GetModelName(), branchName)
+	if err != nil {
+		return uuid.Nil, uuid.NullUUID{}, models.NewServerErrorResponse(err)
+	}
+	if branch == nil {
+		return uuid.Nil, uuid.NullUUID{}, models.NewErrorResponse(http.StatusNotFound, fmt.Sprintf("Branch %s not found", branchName))
+	}
+	if versionName == "" {
+		return branch.UUID, uuid.NullUUID{}, nil
+	}
+	version, err := api.app.Dao().GetModelBranchVersion(branch.UUID, versionName)
+	if err != nil {
+		return uuid.Nil, uuid.NullUUID{}, models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return uuid.Nil, uuid.NullUUID{}, models.NewErrorResponse(http.StatusNotFound, fmt.Sprintf("Version %s of branch %s not found", versionName, branchName))
+	}
+	return branch.UUID, uuid.NullUUID{UUID: version.UUID, Valid: true}, nil
+}
+
+var CompareModelBranches ServiceFunc = (*Api).CompareModelBranches
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/filesystem"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindModelFilesApi registers the multi-file model version api endpoints and the corresponding handlers.
+func BindModelFilesApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/register/files", api.DefaultHandler(RegisterModelFiles), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/files", api.DefaultHandler(GetModelBranchVersionFiles), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/files/*", api.FileHandler(DownloadModelBranchVersionFile), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+}
+
+// RegisterModelFiles godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Register model files
+//	@Description	Register a model version made of several files (eg. weights, tokenizer and config). The version hash is the sha256 digest of its manifest, the json array of its files (path, size, digest and media type) sorted by path
+//	@Tags			Model
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/register/files [post]
+//	@Param			files		formData	file	true	"Model files"
+//	@Param			paths		formData	string	false	"Path of each file in the version, in the order of the files (defaults to the file names)"
+//	@Param			hash		formData	string	false	"Expected manifest digest"
+//	@Param			storage		formData	string	false	"Storage"
+//	@Param			orgId		path		string	true	"Organization UUID"
+//	@Param			modelName	path		string	true	"Model name"
+//	@Param			branchName	path		string	true	"Branch name"
+func (api *Api) RegisterModelFiles(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	modelUUID := request.GetModelUUID()
+	modelBranchUUID := request.GetModelBranchUUID()
+	if errresp := api.validateModelBranchRegistration(request); errresp != nil {
+		return errresp
+	}
+	fileHeaders := request.FormFiles["files"]
+	if len(fileHeaders) == 0 {
+		return models.NewErrorResponse(http.StatusBadRequest, "Files are required")
+	}
+	filePaths := request.FormValues["paths"]
+	if len(filePaths) > 0 && len(filePaths) != len(fileHeaders) {
+		return models.NewErrorResponse(http.StatusBadRequest, "A path is required for every file")
+	}
+	var modelHash, modelSourceSecretName string
+	if len(request.FormValues["hash"]) > 0 {
+		modelHash = request.FormValues["hash"][0]
+	}
+	if len(request.FormValues["storage"]) > 0 {
+		modelSourceSecretName = request.FormValues["storage"][0]
+	}
+
+	files := make([]models.VersionFileResponse, 0, len(fileHeaders))
+	seen := map[string]bool{}
+	for i, fileHeader := range fileHeaders {
+		filePath := fileHeader.Filename
+		if len(filePaths) > 0 {
+			filePath = filePaths[i]
+		}
+		filePath, ok := cleanVersionFilePath(filePath)
+		if !ok {
+			return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid file path %s", filePath))
+		}
+		if seen[filePath] {
+			return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Duplicate file path %s", filePath))
+		}
+		seen[filePath] = true
+		file, err := describeVersionFile(fileHeader, filePath)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		files = append(files, *file)
+	}
+	manifestDigest := models.ManifestDigest(files)
+	if modelHash != "" && !digest.Equal(modelHash, manifestDigest) {
+		return models.NewErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("Hash mismatch: expected sha256 %s, computed %s", modelHash, manifestDigest))
+	}
+	if errresp := api.checkModelVersionHash(modelBranchUUID, manifestDigest); errresp != nil {
+		return errresp
+	}
+	modelSourceSecrets, errresp := api.GetSourceSecrets(modelSourceSecretName, orgId)
+	if errresp != nil {
+		return errresp
+	}
+
+	var softQuotaExceeded bool
+	releaseFiles := func() {
+		for _, file := range files {
+			if file.BlobUUID.Valid {
+				api.ReleaseBlob(modelSourceSecrets, file.BlobUUID.UUID)
+			}
+		}
+	}
+	uploadDir := fmt.Sprintf("model-registry/%s/models/%s/%s", orgId, modelUUID, modelBranchUUID)
+	stored := map[string]int{}
+	for i := range files {
+		// identical files of the version share the blob of the first one
+		if first, ok := stored[files[i].Digest]; ok {
+			if err := api.app.Dao().RetainBlob(files[first].BlobUUID.UUID); err != nil {
+				releaseFiles()
+				return models.NewServerErrorResponse(err)
+			}
+			files[i].StoragePath = files[first].StoragePath
+			files[i].SourceType = files[first].SourceType
+			files[i].BlobUUID = files[first].BlobUUID
+			files[i].DataKey = files[first].DataKey
+			continue
+		}
+		exceeded, errresp := api.StoreVersionFile(orgId, modelUUID, modelSourceSecrets, uploadDir, fileHeaders[i], &files[i])
+		if errresp != nil {
+			releaseFiles()
+			return errresp
+		}
+		softQuotaExceeded = softQuotaExceeded || exceeded
+		stored[files[i].Digest] = i
+	}
+	modelVersion, err := api.app.Dao().RegisterModelFiles(modelBranchUUID, modelSourceSecrets.SourceType, modelSourceSecrets.PublicURL, manifestDigest, files, userUUID)
+	if err != nil {
+		releaseFiles()
+		return registerVersionErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, modelVersion, withSoftQuotaWarning("Model successfully registered", softQuotaExceeded))
+}
+
+// GetModelBranchVersionFiles godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the files of a model version
+//	@Description	Get the manifest of a model version, listing the path, size, digest and media type of its files
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/files [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) GetModelBranchVersionFiles(request *models.Request) *models.Response {
+	version, err := api.app.Dao().GetModelBranchVersion(request.GetModelBranchUUID(), request.PathParams["version"])
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	files, err := api.app.Dao().GetModelVersionFiles(version.UUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, files, "Model branch version files")
+}
+
+// DownloadModelBranchVersionFile godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Download a file of a model version
+//	@Description	Download a file of a model version by its path in the manifest. Supports Range, If-Range and If-None-Match requests to resume large downloads
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		octet-stream
+//	@Success		200	{file}	binary
+//	@Success		206	{file}	binary
+//	@Success		304
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/files/{path} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+//	@Param			path		path	string	true	"File path"
+func (api *Api) DownloadModelBranchVersionFile(request *models.Request) (*models.FileResponse, *models.Response) {
+	orgId := request.GetOrgId()
+	filePath := request.GetPathParam("*")
+	if unescaped, err := url.PathUnescape(filePath); err == nil {
+		filePath = unescaped
+	}
+	version, err := api.app.Dao().GetModelBranchVersion(request.GetModelBranchUUID(), request.PathParams["version"])
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	files, err := api.app.Dao().GetModelVersionFiles(version.UUID)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	var file *models.VersionFileResponse
+	for i := range files {
+		if files[i].Path == filePath {
+			file = &files[i]
+			break
+		}
+	}
+	if file == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "File not found in model version")
+	}
+	sourceSecrets, fileKey, errresp := api.GetStoredFileSourceSecrets(file.SourceType, file.StoragePath, orgId)
+	if errresp != nil {
+		return nil, errresp
+	}
+	dataKey, err := encryption.Open(api.app, orgId, file.DataKey)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	return &models.FileResponse{
+		SourceSecrets: sourceSecrets,
+		Key:           fileKey,
+		Name:          path.Base(file.Path),
+		ETag:          file.Digest,
+		DataKey:       dataKey,
+	}, nil
+}
+
+// StoreVersionFile stores the uploaded file of a multi-file model version
+// as a blob (unless the source already stores its content) and sets the
+// storage of its manifest entry. The returned flag reports whether the
+// storage soft quota is exceeded.
+func (api *Api) StoreVersionFile(orgId uuid.UUID, modelUUID uuid.UUID, sourceSecrets *commonmodels.SourceSecrets, uploadDir string, fileHeader *multipart.FileHeader, file *models.VersionFileResponse) (bool, *models.Response) {
+	blob, errresp := api.ReferenceStoredBlob(orgId, sourceSecrets, digest.DefaultAlgorithm, file.Digest)
+	if errresp != nil {
+		return false, errresp
+	}
+	var softQuotaExceeded bool
+	if blob == nil {
+		softQuotaExceeded, errresp = api.CheckStorageQuota(orgId, file.Size)
+		if errresp != nil {
+			return false, errresp
+		}
+		upload, err := filesystem.NewFileFromMultipart(fileHeader)
+		if err != nil {
+			return false, models.NewServerErrorResponse(err)
+		}
+		dataKey, err := encryption.NewDataKey(api.app, orgId)
+		if err != nil {
+			return false, models.NewServerErrorResponse(err)
+		}
+		digester, errresp := api.NewDigester(digest.DefaultAlgorithm)
+		if errresp != nil {
+			return false, errresp
+		}
+		uploadPath, err := api.app.UploadFile(upload, uploadDir, sourceSecrets, dataKey.Key, digester.Hashes()...)
+		if err != nil {
+			return false, models.NewServerErrorResponse(err)
+		}
+		errresp = api.VerifyUploadedFileHash(digester, file.Digest, uploadPath, sourceSecrets)
+		if errresp != nil {
+			return false, errresp
+		}
+		blob, errresp = api.StoreBlob(orgId, modelUUID, sourceSecrets, uploadPath, digester.Sha256(), dataKey)
+		if errresp != nil {
+			return false, errresp
+		}
+	}
+	file.StoragePath = blob.Key
+	file.SourceType = sourceSecrets.SourceType
+	file.BlobUUID = uuid.NullUUID{UUID: blob.UUID, Valid: true}
+	file.DataKey = blob.DataKey
+	return softQuotaExceeded, nil
+}
+
+// cleanVersionFilePath validates the path of a file in a multi-file
+// version, which must be relative and stay inside the version.
+func cleanVersionFilePath(filePath string) (string, bool) {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" || strings.HasPrefix(filePath, "/") || strings.Contains(filePath, "\\") {
+		return filePath, false
+	}
+	cleaned := path.Clean(filePath)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return filePath, false
+	}
+	return cleaned, true
+}
+
+// describeVersionFile computes the manifest entry (without its storage) of
+// an uploaded file. The media type sent with the file is kept unless it is
+// generic, in which case it is detected from the content.
+func describeVersionFile(fileHeader *multipart.FileHeader, filePath string) (*models.VersionFileResponse, error) {
+	f, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mediaType := fileHeader.Header.Get("Content-Type")
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mt, err := mimetype.DetectReader(f)
+		if err != nil {
+			return nil, err
+		}
+		mediaType = mt.String()
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return &models.VersionFileResponse{
+		Path:      filePath,
+		Size:      size,
+		Digest:    hex.EncodeToString(h.Sum(nil)),
+		MediaType: mediaType,
+	}, nil
+}
+
+var RegisterModelFiles ServiceFunc = (*Api).RegisterModelFiles
+var GetModelBranchVersionFiles ServiceFunc = (*Api).GetModelBranchVersionFiles
+var DownloadModelBranchVersionFile FileServiceFunc = (*Api).DownloadModelBranchVersionFile
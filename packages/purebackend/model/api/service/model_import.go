@@ -0,0 +1,183 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/importer"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/filesystem"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindModelImportApi registers the model import api endpoints and the corresponding handlers.
+func BindModelImportApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/import", api.DefaultHandler(ImportModel), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/import/:importId", api.DefaultHandler(GetModelImport), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+}
+
+// ImportModel godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Import model from a url
+//	@Description	Start importing a model file from an http(s) url. The file is fetched by the server into the storage, its hash is verified and the model version is registered once done. Poll the import to follow its status
+//	@Tags			Model
+//	@Accept			json
+//	@Produce		json
+//	@Success		202	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/import [post]
+//	@Param			orgId		path	string						true	"Organization Id"
+//	@Param			modelName	path	string						true	"Model Name"
+//	@Param			branchName	path	string						true	"Branch Name"
+//	@Param			data		body	commonmodels.ImportRequest	true	"Import details"
+func (api *Api) ImportModel(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	modelBranchUUID := request.GetModelBranchUUID()
+	if errresp := api.validateModelBranchRegistration(request); errresp != nil {
+		return errresp
+	}
+	request.ParseJsonBody()
+	importURL, _ := request.GetParsedBodyAttribute("url").(string)
+	if importURL == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Url is required")
+	}
+	if err := importer.ValidateURL(api.app, importURL); err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, err.Error())
+	}
+	hash, _ := request.GetParsedBodyAttribute("hash").(string)
+	if hash == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Hash is required")
+	}
+	hashAlgorithm, _ := request.GetParsedBodyAttribute("hash_algorithm").(string)
+	if _, errresp := api.NewDigester(hashAlgorithm); errresp != nil {
+		return errresp
+	}
+	storage, _ := request.GetParsedBodyAttribute("storage").(string)
+	if _, errresp := api.GetSourceSecrets(storage, orgId); errresp != nil {
+		return errresp
+	}
+	if errresp := api.checkModelVersionHash(modelBranchUUID, hash); errresp != nil {
+		return errresp
+	}
+	job, err := api.app.Dao().CreateModelImportJob(orgId, modelBranchUUID, importURL, hash, hashAlgorithm, storage, userUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	go api.runModelImport(job, orgId, request.GetModelUUID(), modelBranchUUID, userUUID)
+	return models.NewDataResponse(http.StatusAccepted, job, "Model import started")
+}
+
+// GetModelImport godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get model import
+//	@Description	Get the status of a model import, with the registered version once completed or the error if it failed
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/import/{importId} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			importId	path	string	true	"Import UUID"
+func (api *Api) GetModelImport(request *models.Request) *models.Response {
+	jobUUID, err := uuid.FromString(request.GetPathParam("importId"))
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid import id")
+	}
+	job, err := api.app.Dao().GetModelImportJob(request.GetModelBranchUUID(), jobUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if job == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Import not found")
+	}
+	return models.NewDataResponse(http.StatusOK, job, "Model import details")
+}
+
+// runModelImport fetches the url of the import job, verifies its hash and
+// stores it as a blob before registering the model version. The job status
+// records the outcome.
+func (api *Api) runModelImport(job *models.ImportJobResponse, orgId uuid.UUID, modelUUID uuid.UUID, modelBranchUUID uuid.UUID, userUUID uuid.UUID) {
+	api.app.Dao().UpdateImportJobStatus(job.UUID, models.ImportRunning, "")
+	size, version, errresp := api.importModel(job, orgId, modelUUID, modelBranchUUID, userUUID)
+	if errresp != nil {
+		api.app.Dao().UpdateImportJobStatus(job.UUID, models.ImportFailed, errresp.Body.Message)
+		return
+	}
+	api.app.Dao().CompleteImportJob(job.UUID, size, version)
+}
+
+func (api *Api) importModel(job *models.ImportJobResponse, orgId uuid.UUID, modelUUID uuid.UUID, modelBranchUUID uuid.UUID, userUUID uuid.UUID) (int64, string, *models.Response) {
+	sourceSecrets, errresp := api.GetSourceSecrets(job.Storage, orgId)
+	if errresp != nil {
+		return 0, "", errresp
+	}
+	digester, errresp := api.NewDigester(job.HashAlgorithm)
+	if errresp != nil {
+		return 0, "", errresp
+	}
+	// the download can't exceed the storage quota left to the org
+	maxSize, errresp := api.RemainingStorageQuota(orgId)
+	if errresp != nil {
+		return 0, "", errresp
+	}
+	artifact, err := importer.Fetch(api.app, job.URL, maxSize, digester.Hashes()...)
+	if errors.Is(err, importer.ErrTooLarge) {
+		return 0, "", models.NewErrorResponse(http.StatusInsufficientStorage, "Organization storage quota exceeded")
+	}
+	if err != nil {
+		return 0, "", models.NewErrorResponse(http.StatusBadGateway, err.Error())
+	}
+	defer artifact.Remove()
+	if !digest.Equal(digester.Sum(), job.Hash) {
+		return 0, "", models.NewErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("Hash mismatch: expected %s %s, computed %s", digester.Algorithm, job.Hash, digester.Sum()))
+	}
+	blob, errresp := api.ReferenceStoredBlob(orgId, sourceSecrets, digest.DefaultAlgorithm, digester.Sha256())
+	if errresp != nil {
+		return 0, "", errresp
+	}
+	if blob == nil {
+		if _, errresp := api.CheckStorageQuota(orgId, artifact.Size); errresp != nil {
+			return 0, "", errresp
+		}
+		file, err := filesystem.NewFileFromPath(artifact.Path)
+		if err != nil {
+			return 0, "", models.NewServerErrorResponse(err)
+		}
+		dataKey, err := encryption.NewDataKey(api.app, orgId)
+		if err != nil {
+			return 0, "", models.NewServerErrorResponse(err)
+		}
+		uploadPath, err := api.app.UploadFile(file, fmt.Sprintf("model-registry/%s/models/%s/%s", orgId, modelUUID, modelBranchUUID), sourceSecrets, dataKey.Key)
+		if err != nil {
+			return 0, "", models.NewServerErrorResponse(err)
+		}
+		blob, errresp = api.StoreBlob(orgId, modelUUID, sourceSecrets, uploadPath, digester.Sha256(), dataKey)
+		if errresp != nil {
+			return 0, "", errresp
+		}
+	}
+	modelVersion, err := api.app.Dao().RegisterModelFile(modelBranchUUID, sourceSecrets.SourceType, sourceSecrets.PublicURL, blob.Key, false, digester.Sum(), digester.Algorithm, blob.Digest, artifact.Name, uuid.NullUUID{UUID: blob.UUID, Valid: true}, blob.DataKey, userUUID)
+	if err != nil {
+		api.ReleaseBlob(sourceSecrets, blob.UUID)
+		return 0, "", registerVersionErrorResponse(err)
+	}
+	return artifact.Size, modelVersion.Version, nil
+}
+
+var ImportModel ServiceFunc = (*Api).ImportModel
+var GetModelImport ServiceFunc = (*Api).GetModelImport
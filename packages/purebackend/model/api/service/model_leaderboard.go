@@ -0,0 +1,153 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	modelmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/models"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// defaultLeaderboardLimit is the number of versions of a leaderboard unless
+// a limit is given.
+const defaultLeaderboardLimit = 50
+
+// BindModelLeaderboardApi registers the model leaderboard api endpoints and the corresponding handlers.
+func BindModelLeaderboardApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.GET("/leaderboard", api.DefaultHandler(GetOrgModelLeaderboard))
+	modelGroup.GET("/:modelName/leaderboard", api.DefaultHandler(GetModelLeaderboard), middlewares.ValidateModel(api.app))
+}
+
+// GetOrgModelLeaderboard godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Rank the model versions of an organization by a metric
+//	@Description	Rank the versions of all the models of an organization by the numeric value logged for a key. Versions without a numeric value for the key are left out
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/leaderboard [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			key			query	string	true	"Log key to rank by"
+//	@Param			direction	query	string	false	"desc (default) or asc"
+//	@Param			branch		query	string	false	"Branch name"
+//	@Param			stage		query	string	false	"Version stage"
+//	@Param			alias		query	string	false	"Version alias"
+//	@Param			from		query	string	false	"Registered after (RFC3339)"
+//	@Param			to			query	string	false	"Registered before (RFC3339)"
+//	@Param			limit		query	int		false	"Number of versions"
+//	@Param			metrics		query	string	false	"Comma separated log keys to return with the versions"
+func (api *Api) GetOrgModelLeaderboard(request *models.Request) *models.Response {
+	query, errresp := parseModelLeaderboardQuery(request)
+	if errresp != nil {
+		return errresp
+	}
+	leaderboard, err := api.app.Dao().GetModelLeaderboard(request.GetOrgId(), *query)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, leaderboard, "Model leaderboard")
+}
+
+// GetModelLeaderboard godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Rank the versions of a model by a metric
+//	@Description	Rank the versions of a model, across its branches, by the numeric value logged for a key. Versions without a numeric value for the key are left out
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/leaderboard [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			key			query	string	true	"Log key to rank by"
+//	@Param			direction	query	string	false	"desc (default) or asc"
+//	@Param			branch		query	string	false	"Branch name"
+//	@Param			stage		query	string	false	"Version stage"
+//	@Param			alias		query	string	false	"Version alias"
+//	@Param			from		query	string	false	"Registered after (RFC3339)"
+//	@Param			to			query	string	false	"Registered before (RFC3339)"
+//	@Param			limit		query	int		false	"Number of versions"
+//	@Param			metrics		query	string	false	"Comma separated log keys to return with the versions"
+func (api *Api) GetModelLeaderboard(request *models.Request) *models.Response {
+	query, errresp := parseModelLeaderboardQuery(request)
+	if errresp != nil {
+		return errresp
+	}
+	query.ModelUUID = uuid.NullUUID{UUID: request.GetModelUUID(), Valid: true}
+	leaderboard, err := api.app.Dao().GetModelLeaderboard(request.GetOrgId(), *query)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, leaderboard, "Model leaderboard")
+}
+
+// parseModelLeaderboardQuery reads the key, direction, filters and limit of a
+// leaderboard from the query parameters.
+func parseModelLeaderboardQuery(request *models.Request) (*modelmodels.ModelLeaderboardQuery, *models.Response) {
+	query := &modelmodels.ModelLeaderboardQuery{
+		Key:    request.GetQueryParam("key"),
+		Branch: request.GetQueryParam("branch"),
+		Stage:  request.GetQueryParam("stage"),
+		Alias:  request.GetQueryParam("alias"),
+		Limit:  defaultLeaderboardLimit,
+	}
+	if query.Key == "" {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Metric key is required")
+	}
+	switch direction := strings.ToLower(request.GetQueryParam("direction")); direction {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid direction %s, expected asc or desc", direction))
+	}
+	if query.Stage != "" && !modelmodels.IsValidModelStage(query.Stage) {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid stage %s", query.Stage))
+	}
+	for _, param := range []string{"from", "to"} {
+		value := request.GetQueryParam(param)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid %s date %s, expected RFC3339", param, value))
+		}
+		if param == "from" {
+			query.From = &date
+		} else {
+			query.To = &date
+		}
+	}
+	if limit := request.GetQueryParam("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit <= 0 {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, "Limit must be a positive integer")
+		}
+	}
+	for _, key := range strings.Split(request.GetQueryParam("metrics"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			query.Metrics = append(query.Metrics, key)
+		}
+	}
+	return query, nil
+}
+
+var GetOrgModelLeaderboard ServiceFunc = (*Api).GetOrgModelLeaderboard
+var GetModelLeaderboard ServiceFunc = (*Api).GetModelLeaderboard
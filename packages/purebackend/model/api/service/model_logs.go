@@ -1,7 +1,10 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -15,8 +18,12 @@ import (
 	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
 	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
 	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
 )
 
+// defaultMaxLogBatchSize is the maximum size of a log batch unless configured.
+const defaultMaxLogBatchSize = 1 << 20
+
 // BindModelLogsApi registers the admin api endpoints and the corresponding handlers.
 func BindModelLogsApi(app core.App, rg *echo.Group) {
 	api := Api{app: app}
@@ -25,6 +32,7 @@ func BindModelLogsApi(app core.App, rg *echo.Group) {
 	modelGroup.GET("/:modelName/branch/:branchName/version/:version/log", api.DefaultHandler(GetAllLogsModel), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
 	modelGroup.GET("/:modelName/branch/:branchName/version/:version/log/:key", api.DefaultHandler(GetKeyLogsModel), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
 	modelGroup.POST("/:modelName/branch/:branchName/version/:version/log", api.DefaultHandler(LogModel), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/version/:version/log/batch", api.LogBatchHandler(LogBatchModel), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
 	modelGroup.POST("/:modelName/branch/:branchName/version/:version/logfile", api.DefaultHandler(LogFileModel), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
 }
 
@@ -121,6 +129,47 @@ func (api *Api) LogModel(request *models.Request) *models.Response {
 	return response
 }
 
+// LogBatchModel godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Log a batch of data for model
+//	@Description	Log a batch of key, data and type entries for model in a single transaction. No entry is logged if any is invalid. Returns the result of each entry in the order of the batch
+//	@Tags			Model
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/log/batch [post]
+//	@Param			orgId		path	string						true	"Organization Id"
+//	@Param			modelName	path	string						true	"Model Name"
+//	@Param			branchName	path	string						true	"Branch Name"
+//	@Param			version		path	string						true	"Version"
+//	@Param			data		body	commonmodels.LogsRequest	true	"Data to log"
+func (api *Api) LogBatchModel(request *models.Request, body io.Reader) *models.Response {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return models.NewErrorResponse(http.StatusRequestEntityTooLarge, fmt.Sprintf("Log batch exceeds the maximum size of %d bytes", maxBytesErr.Limit))
+		}
+		return models.NewServerErrorResponse(err)
+	}
+	var logsRequest commonmodels.LogsRequest
+	if err := json.Unmarshal(data, &logsRequest); err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if len(logsRequest.Logs) == 0 {
+		return models.NewErrorResponse(http.StatusBadRequest, "Logs are required")
+	}
+	if entries := validateLogBatch(logsRequest.Logs); entries != nil {
+		return models.NewDataResponse(http.StatusBadRequest, entries, "Invalid log entries")
+	}
+	result, err := api.app.Dao().CreateLogsForModelVersion(request.GetModelBranchVersionUUID(), logsRequest.Logs)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, result, "Logs created")
+}
+
 // LogFileModel godoc
 //
 //	@Security		ApiKeyAuth
@@ -150,22 +199,19 @@ func (api *Api) LogFileModel(request *models.Request) *models.Response {
 		return models.NewErrorResponse(http.StatusBadRequest, "File is required")
 	}
 	versionUUID := request.GetModelBranchVersionUUID()
-	sourceSecrets, errresp := api.ValidateSourceTypeAndGetSourceSecrets(modelSourceSecretName, orgId)
+	sourceSecrets, errresp := api.GetSourceSecrets(modelSourceSecretName, orgId)
 	if errresp != nil {
 		return errresp
 	}
-	modelSourceType := sourceSecrets.SourceType
-	sourceValid := false
-	for source := range commonmodels.SupportedSources {
-		if commonmodels.SupportedSources[source] == modelSourceType {
-			sourceValid = true
-			break
-		}
+	var logsSize int64
+	for _, fileHeader := range fileHeaders {
+		logsSize += fileHeader.Size
 	}
-	if !sourceValid {
-		return models.NewErrorResponse(http.StatusBadRequest, "Unsupported model storage")
+	softQuotaExceeded, errresp := api.CheckStorageQuota(orgId, logsSize)
+	if errresp != nil {
+		return errresp
 	}
-	logs := make(map[string]string)
+	logs := []commonmodels.LogRequest{}
 	for _, fileHeader := range fileHeaders {
 		name := fileHeader.Filename
 		originalExt := filepath.Ext(name)
@@ -174,25 +220,59 @@ func (api *Api) LogFileModel(request *models.Request) *models.Response {
 		if err != nil {
 			return models.NewServerErrorResponse(err)
 		}
-		filePath, err := api.app.UploadFile(file, fmt.Sprintf("model-registry/%s/models/%s/%s/logs", orgId, modelUUID, modelBranchUUID), sourceSecrets)
+		filePath, err := api.app.UploadFile(file, fmt.Sprintf("model-registry/%s/models/%s/%s/logs", orgId, modelUUID, modelBranchUUID), sourceSecrets, "")
 		if err != nil {
 			return models.NewServerErrorResponse(err)
 		}
-		logs[key] = fmt.Sprintf("%s/%s", sourceSecrets.PublicURL, filePath)
-	}
-	var results []*models.LogResponse
-	for key, data := range logs {
-		result, err := api.app.Dao().CreateLogForModelVersion(key, data, versionUUID)
+		err = api.app.Dao().RecordModelStorageUsage(orgId, modelUUID, uuid.NullUUID{UUID: versionUUID, Valid: true}, sourceSecrets.SourceType, sourceSecrets.PublicURL, filePath, fileHeader.Size)
 		if err != nil {
 			return models.NewServerErrorResponse(err)
 		}
-		results = append(results, result)
+		logs = append(logs, commonmodels.LogRequest{Key: key, Data: fmt.Sprintf("%s/%s", sourceSecrets.PublicURL, filePath)})
+	}
+	entries, err := api.app.Dao().CreateLogsForModelVersion(versionUUID, logs)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	var results []*models.LogResponse
+	for _, entry := range entries {
+		results = append(results, entry.Log)
 	}
-	response := models.NewDataResponse(http.StatusOK, results, "Logs created")
+	response := models.NewDataResponse(http.StatusOK, results, withSoftQuotaWarning("Logs created", softQuotaExceeded))
 	return response
 }
 
+// maxLogBatchSize returns the maximum size in bytes of the body of a log
+// batch.
+func (api *Api) maxLogBatchSize() int64 {
+	if maxSize := api.app.Settings().Logs.MaxBatchSize; maxSize > 0 {
+		return maxSize
+	}
+	return defaultMaxLogBatchSize
+}
+
+// validateLogBatch checks the entries of a log batch, returning the result
+// of each entry if any is invalid.
+func validateLogBatch(logs []commonmodels.LogRequest) []models.LogBatchEntryResponse {
+	invalid := false
+	entries := make([]models.LogBatchEntryResponse, 0, len(logs))
+	for i, log := range logs {
+		entry := models.LogBatchEntryResponse{Index: i, Key: log.Key}
+		if log.Key == "" {
+			entry.Status = models.LogInvalid
+			entry.Error = "Key is required"
+			invalid = true
+		}
+		entries = append(entries, entry)
+	}
+	if !invalid {
+		return nil
+	}
+	return entries
+}
+
 var GetAllLogsModel ServiceFunc = (*Api).GetAllLogsModel
 var GetKeyLogsModel ServiceFunc = (*Api).GetKeyLogsModel
 var LogModel ServiceFunc = (*Api).LogModel
+var LogBatchModel ChunkServiceFunc = (*Api).LogBatchModel
 var LogFileModel ServiceFunc = (*Api).LogFileModel
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+)
+
+// BindModelMetricApi registers the model version metric api endpoints and the corresponding handlers.
+func BindModelMetricApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/metric", api.DefaultHandler(GetModelMetrics), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/metric/:key", api.DefaultHandler(GetModelMetric), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/version/:version/metric", api.DefaultHandler(LogModelMetrics), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+}
+
+// GetModelMetrics godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the metrics of a model version
+//	@Description	Get the series of every metric key of a model version with their last, min, max and mean values. The points are left out when summary is true
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/metric [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+//	@Param			summary		query	bool	false	"Only return the aggregates"
+func (api *Api) GetModelMetrics(request *models.Request) *models.Response {
+	withPoints := request.GetQueryParam("summary") != "true"
+	metrics, err := api.app.Dao().GetModelVersionMetrics(request.GetModelBranchVersionUUID(), "", withPoints)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, metrics, "Metrics for model version")
+}
+
+// GetModelMetric godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get a metric of a model version
+//	@Description	Get the series of a metric key of a model version with its last, min, max and mean values. The points are left out when summary is true
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/metric/{key} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+//	@Param			key			path	string	true	"Key"
+//	@Param			summary		query	bool	false	"Only return the aggregates"
+func (api *Api) GetModelMetric(request *models.Request) *models.Response {
+	key := request.GetPathParam("key")
+	withPoints := request.GetQueryParam("summary") != "true"
+	metrics, err := api.app.Dao().GetModelVersionMetrics(request.GetModelBranchVersionUUID(), key, withPoints)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if len(metrics) == 0 {
+		return models.NewErrorResponse(http.StatusNotFound, fmt.Sprintf("Metric %s not found", key))
+	}
+	return models.NewDataResponse(http.StatusOK, metrics[0], "Metric for model version")
+}
+
+// LogModelMetrics godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Log metrics for a model version
+//	@Description	Log numeric metric values, with an optional step and timestamp, for a model version. All the metrics are stored or none. Returns the aggregates of the logged keys
+//	@Tags			Model
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/metric [post]
+//	@Param			orgId		path	string						true	"Organization Id"
+//	@Param			modelName	path	string						true	"Model Name"
+//	@Param			branchName	path	string						true	"Branch Name"
+//	@Param			version		path	string						true	"Version"
+//	@Param			data		body	commonmodels.MetricsRequest	true	"Metrics to log"
+func (api *Api) LogModelMetrics(request *models.Request) *models.Response {
+	metrics, errresp := parseMetrics(request)
+	if errresp != nil {
+		return errresp
+	}
+	result, err := api.app.Dao().CreateModelVersionMetrics(request.GetModelBranchVersionUUID(), metrics)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, result, "Metrics logged")
+}
+
+// parseMetrics reads the metrics to log from the request body, each of
+// which needs a key and a finite value.
+func parseMetrics(request *models.Request) ([]commonmodels.MetricRequest, *models.Response) {
+	var metricsRequest commonmodels.MetricsRequest
+	if err := json.Unmarshal(request.Body, &metricsRequest); err != nil {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if len(metricsRequest.Metrics) == 0 {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Metrics are required")
+	}
+	for i, metric := range metricsRequest.Metrics {
+		if metric.Key == "" {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Key is required for metric %d", i))
+		}
+		if metric.Value == nil || math.IsNaN(*metric.Value) || math.IsInf(*metric.Value, 0) {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Value of metric %s must be a number", metric.Key))
+		}
+	}
+	return metricsRequest.Metrics, nil
+}
+
+var GetModelMetrics ServiceFunc = (*Api).GetModelMetrics
+var GetModelMetric ServiceFunc = (*Api).GetModelMetric
+var LogModelMetrics ServiceFunc = (*Api).LogModelMetrics
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// presignedURLExpiry is the validity duration of the minted presigned urls.
+const presignedURLExpiry = 15 * time.Minute
+
+// BindModelPresignedApi registers the admin api endpoints and the corresponding handlers.
+func BindModelPresignedApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/presigned-upload", api.DefaultHandler(CreateModelPresignedUpload), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.PUT("/:modelName/branch/:branchName/presigned-upload/:uploadId", api.FileUploadHandler(UploadModelPresignedFile), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/presigned-upload/:uploadId/confirm", api.DefaultHandler(ConfirmModelPresignedUpload), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/presigned-download", api.DefaultHandler(GetModelPresignedDownload), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+}
+
+// CreateModelPresignedUpload godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Create presigned model upload url
+//	@Description	Create a short-lived presigned PUT url to upload the model file directly to the S3/R2 bucket of the storage. For other storages the url points to the proxied upload endpoint which requires the usual authorization header. The model version is registered with the confirm endpoint once the file is uploaded
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/presigned-upload [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			modelName	path	string								true	"Model Name"
+//	@Param			branchName	path	string								true	"Branch Name"
+//	@Param			data		body	commonmodels.UploadSessionRequest	true	"Upload details"
+func (api *Api) CreateModelPresignedUpload(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	modelBranchUUID := request.GetModelBranchUUID()
+	request.ParseJsonBody()
+	fileName, _ := request.GetParsedBodyAttribute("file_name").(string)
+	if fileName == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "File name is required")
+	}
+	storage, _ := request.GetParsedBodyAttribute("storage").(string)
+	sourceSecrets, errresp := api.GetSourceSecrets(storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	session, err := api.app.Dao().CreateModelUploadSession(orgId, modelBranchUUID, filepath.Base(fileName), storage, userUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	presigned := &models.PresignedURLResponse{
+		Method:    http.MethodPut,
+		ExpiresAt: time.Now().Add(presignedURLExpiry),
+		Upload:    session,
+	}
+	if supportsPresignedURLs(sourceSecrets) {
+		fs, err := api.app.NewFilesystem(sourceSecrets)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		defer fs.Close()
+		presigned.URL, err = fs.SignedURL(modelUploadFileKey(request, session), http.MethodPut, presignedURLExpiry)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+	} else {
+		presigned.Proxied = true
+		presigned.URL = fmt.Sprintf("/api/org/%s/model/%s/branch/%s/presigned-upload/%s", orgId, url.PathEscape(request.GetModelName()), url.PathEscape(request.GetModelBranchName()), session.UUID)
+	}
+	return models.NewDataResponse(http.StatusOK, presigned, "Model presigned upload url created")
+}
+
+// UploadModelPresignedFile godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Upload model file of a presigned upload
+//	@Description	Proxied fallback of the presigned upload url for storages that don't support presigned urls
+//	@Tags			Model
+//	@Accept			application/octet-stream
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/presigned-upload/{uploadId} [put]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			uploadId	path	string	true	"Upload session UUID"
+func (api *Api) UploadModelPresignedFile(request *models.Request, body io.Reader) *models.Response {
+	orgId := request.GetOrgId()
+	session, errresp := api.getModelUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	if session.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Upload session already finalized")
+	}
+	sourceSecrets, errresp := api.GetSourceSecrets(session.Storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	size, err := fs.UploadReader(body, modelUploadFileKey(request, session))
+	if err != nil {
+		return fileUploadErrorResponse(err)
+	}
+	if size == 0 {
+		fs.Delete(modelUploadFileKey(request, session))
+		return models.NewErrorResponse(http.StatusBadRequest, "File is empty")
+	}
+	return models.NewDataResponse(http.StatusOK, nil, "Model file uploaded")
+}
+
+// ConfirmModelPresignedUpload godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Confirm presigned model upload
+//	@Description	Verify the size and hash of the uploaded model file and register it as a new model version
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/presigned-upload/{uploadId}/confirm [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			modelName	path	string								true	"Model Name"
+//	@Param			branchName	path	string								true	"Branch Name"
+//	@Param			uploadId	path	string								true	"Upload session UUID"
+//	@Param			data		body	commonmodels.ConfirmUploadRequest	true	"Expected hash and size"
+func (api *Api) ConfirmModelPresignedUpload(request *models.Request) *models.Response {
+	modelBranchUUID := request.GetModelBranchUUID()
+	session, errresp := api.getModelUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	if session.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Upload session already finalized")
+	}
+	request.ParseJsonBody()
+	modelHash, _ := request.GetParsedBodyAttribute("hash").(string)
+	if modelHash == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Hash is required")
+	}
+	hashAlgorithm, _ := request.GetParsedBodyAttribute("hash_algorithm").(string)
+	digester, errresp := api.NewDigester(hashAlgorithm)
+	if errresp != nil {
+		return errresp
+	}
+	modelSize, _ := request.GetParsedBodyAttribute("size").(float64)
+	if errresp := api.validateModelBranchRegistration(request); errresp != nil {
+		return errresp
+	}
+	if errresp := api.checkModelVersionHash(modelBranchUUID, modelHash); errresp != nil {
+		return errresp
+	}
+	if errresp := api.claimUploadSession(session.UUID); errresp != nil {
+		return errresp
+	}
+	response := api.registerModelPresignedUpload(request, session, modelHash, digester, int64(modelSize))
+	if response.Body.Status != http.StatusOK {
+		// reopen the session so that the confirmation can be retried
+		api.app.Dao().ReleaseUploadSession(session.UUID)
+	}
+	return response
+}
+
+// registerModelPresignedUpload verifies the file uploaded for the claimed
+// session and registers it as a new version of the branch.
+func (api *Api) registerModelPresignedUpload(request *models.Request, session *models.UploadSessionResponse, modelHash string, digester *digest.Digester, modelSize int64) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	modelBranchUUID := request.GetModelBranchUUID()
+	sourceSecrets, errresp := api.GetSourceSecrets(session.Storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	filePath := modelUploadFileKey(request, session)
+	exists, err := fs.Exists(filePath)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if !exists {
+		return models.NewErrorResponse(http.StatusNotFound, "Uploaded file not found")
+	}
+	attrs, err := fs.Attributes(filePath)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if modelSize > 0 && attrs.Size != modelSize {
+		return models.NewErrorResponse(http.StatusUnprocessableEntity, fmt.Sprintf("Size mismatch: expected %d bytes, uploaded %d bytes", modelSize, attrs.Size))
+	}
+	_, err = fs.Hash(filePath, digester.Hashes()...)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	errresp = api.VerifyUploadedFileHash(digester, modelHash, filePath, sourceSecrets)
+	if errresp != nil {
+		return errresp
+	}
+	dataKey, err := encryption.NewDataKey(api.app, orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if dataKey.Key != "" {
+		// the file was uploaded as is, bypassing the server
+		encryptedPath := filePath + ".encrypted"
+		if _, err := fs.CopyTo(fs.Encrypted(dataKey.Key), filePath, encryptedPath); err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		fs.Delete(filePath)
+		filePath = encryptedPath
+	}
+	blob, softQuotaExceeded, errresp := api.StoreUploadedBlob(orgId, request.GetModelUUID(), sourceSecrets, filePath, digester.Sha256(), attrs.Size, dataKey)
+	if errresp != nil {
+		return errresp
+	}
+	modelVersion, err := api.app.Dao().RegisterModelFile(modelBranchUUID, sourceSecrets.SourceType, sourceSecrets.PublicURL, blob.Key, false, digester.Sum(), digester.Algorithm, blob.Digest, session.FileName, uuid.NullUUID{UUID: blob.UUID, Valid: true}, blob.DataKey, userUUID)
+	if err != nil {
+		api.ReleaseBlob(sourceSecrets, blob.UUID)
+		return registerVersionErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, modelVersion, withSoftQuotaWarning("Model successfully registered", softQuotaExceeded))
+}
+
+// GetModelPresignedDownload godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get presigned model download url
+//	@Description	Get a short-lived presigned GET url to download the model version file directly from the S3/R2 bucket. For other storages the url points to the proxied download endpoint which requires the usual authorization header
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/presigned-download [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) GetModelPresignedDownload(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	version, err := api.app.Dao().GetModelBranchVersion(request.GetModelBranchUUID(), request.GetModelBranchVersionName())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if version == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Version not found")
+	}
+	if version.IsManifest {
+		return models.NewErrorResponse(http.StatusBadRequest, "Model version has multiple files, download them individually")
+	}
+	if version.IsEmpty || version.Path == "" {
+		return models.NewErrorResponse(http.StatusNotFound, "Model version has no file")
+	}
+	sourceSecrets, fileKey, errresp := api.GetStoredFileSourceSecrets(version.SourceType, version.Path, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	presigned := &models.PresignedURLResponse{
+		Method:    http.MethodGet,
+		ExpiresAt: time.Now().Add(presignedURLExpiry),
+	}
+	// encrypted files are decrypted by the proxied download
+	if supportsPresignedURLs(sourceSecrets) && !version.Encrypted {
+		fs, err := api.app.NewFilesystem(sourceSecrets)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		defer fs.Close()
+		presigned.URL, err = fs.SignedURL(fileKey, http.MethodGet, presignedURLExpiry)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+	} else {
+		presigned.Proxied = true
+		presigned.URL = fmt.Sprintf("/api/org/%s/model/%s/branch/%s/version/%s/download", orgId, url.PathEscape(request.GetModelName()), url.PathEscape(request.GetModelBranchName()), version.Version)
+	}
+	return models.NewDataResponse(http.StatusOK, presigned, "Model presigned download url created")
+}
+
+// supportsPresignedURLs reports whether files of the source can be
+// accessed directly through presigned urls.
+func supportsPresignedURLs(sourceSecrets *commonmodels.SourceSecrets) bool {
+	sourceType := strings.ToUpper(sourceSecrets.SourceType)
+	return sourceType == "S3" || sourceType == "R2" || sourceType == "PUREML-STORAGE"
+}
+
+func modelUploadFileKey(request *models.Request, session *models.UploadSessionResponse) string {
+	return fmt.Sprintf("model-registry/%s/models/%s/%s/%s_%s", request.GetOrgId(), request.GetModelUUID(), request.GetModelBranchUUID(), session.UUID, session.FileName)
+}
+
+var CreateModelPresignedUpload ServiceFunc = (*Api).CreateModelPresignedUpload
+var UploadModelPresignedFile ChunkServiceFunc = (*Api).UploadModelPresignedFile
+var ConfirmModelPresignedUpload ServiceFunc = (*Api).ConfirmModelPresignedUpload
+var GetModelPresignedDownload ServiceFunc = (*Api).GetModelPresignedDownload
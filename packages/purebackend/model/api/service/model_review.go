@@ -125,6 +125,11 @@ func (api *Api) CreateModelReview(request *models.Request) *models.Response {
 	if IsAccepted != nil {
 		isAcceptedData = IsAccepted.(bool)
 	}
+	if isAcceptedData {
+		if errresp := api.validateModelReviewMerge(toBranchUUID, uuid.NullUUID{}, userUUID); errresp != nil {
+			return errresp
+		}
+	}
 	createdReview, err := api.app.Dao().CreateModelReview(modelUUID, userUUID, fromBranchUUID, fromBranchVersionUUID, toBranchUUID, titleData, descriptionData, isCompleteData, isAcceptedData)
 	if err != nil {
 		return models.NewServerErrorResponse(err)
@@ -171,6 +176,11 @@ func (api *Api) UpdateModelReview(request *models.Request) *models.Response {
 		updatedAttributes["is_complete"] = isComplete.(bool)
 	}
 	if isAccepted != nil {
+		if isAccepted.(bool) && !review.IsAccepted && !review.IsComplete {
+			if errresp := api.validateModelReviewMerge(review.ToBranch.UUID, uuid.NullUUID{UUID: reviewUUID, Valid: true}, request.GetUserUUID()); errresp != nil {
+				return errresp
+			}
+		}
 		updatedAttributes["is_accepted"] = isAccepted.(bool)
 	}
 	updatedDbReview, err := api.app.Dao().UpdateModelReview(reviewUUID, updatedAttributes)
@@ -178,7 +188,7 @@ func (api *Api) UpdateModelReview(request *models.Request) *models.Response {
 		if err.Error() == "review already complete" {
 			return models.NewErrorResponse(http.StatusBadRequest, err.Error())
 		}
-		return models.NewServerErrorResponse(err)
+		return registerVersionErrorResponse(err)
 	}
 	return models.NewDataResponse(http.StatusOK, updatedDbReview, "Model review updated")
 }
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	modelmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/models"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+)
+
+// BindModelStageApi registers the model version lifecycle stage api endpoints and the corresponding handlers.
+func BindModelStageApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.GET("/:modelName/stage/history", api.DefaultHandler(GetModelStageHistory), middlewares.ValidateModel(api.app))
+	modelGroup.POST("/:modelName/stage/rules", api.DefaultHandler(UpdateModelStageRules), middlewares.ValidateModel(api.app))
+	modelGroup.GET("/:modelName/stage/:stage", api.DefaultHandler(GetModelStageVersions), middlewares.ValidateModel(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/stage/history", api.DefaultHandler(GetModelVersionStageHistory), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/version/:version/stage", api.DefaultHandler(TransitionModelVersionStage), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+}
+
+// GetModelStageHistory godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the stage transitions of a model
+//	@Description	Get the stage transitions of every version of a model, latest first, with who made them and why
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/stage/history [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+func (api *Api) GetModelStageHistory(request *models.Request) *models.Response {
+	transitions, err := api.app.Dao().GetModelStageTransitions(request.GetModelUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, transitions, "Model stage history")
+}
+
+// UpdateModelStageRules godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Update the stage rules of a model
+//	@Description	Set whether a model version needs an accepted review before it can be moved to production. Only organization owners can update the rules
+//	@Tags			Model
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/stage/rules [post]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			data		body	object	true	"require_production_review"
+func (api *Api) UpdateModelStageRules(request *models.Request) *models.Response {
+	if errresp := api.validateModelOrgOwner(request, "You are not authorized to update the stage rules of this model"); errresp != nil {
+		return errresp
+	}
+	request.ParseJsonBody()
+	require, ok := request.GetParsedBodyAttribute("require_production_review").(bool)
+	if !ok {
+		return models.NewErrorResponse(http.StatusBadRequest, "require_production_review is required")
+	}
+	err := api.app.Dao().SetModelRequireProductionReview(request.GetModelUUID(), require)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, map[string]bool{"require_production_review": require}, "Model stage rules updated")
+}
+
+// GetModelStageVersions godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the versions of a model in a stage
+//	@Description	Get the versions of every branch of a model in the stage, latest first
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/stage/{stage} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			stage		path	string	true	"Stage"
+func (api *Api) GetModelStageVersions(request *models.Request) *models.Response {
+	stage := request.GetPathParam("stage")
+	if !modelmodels.IsValidModelStage(stage) {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid stage %s", stage))
+	}
+	versions, err := api.app.Dao().GetModelStageVersions(request.GetModelUUID(), stage)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, versions, "Model versions in stage")
+}
+
+// GetModelVersionStageHistory godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the stage transitions of a model version
+//	@Description	Get the stage transitions of a model version, latest first, with who made them and why
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/stage/history [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) GetModelVersionStageHistory(request *models.Request) *models.Response {
+	transitions, err := api.app.Dao().GetModelVersionStageTransitions(request.GetModelBranchVersionUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, transitions, "Model version stage history")
+}
+
+// TransitionModelVersionStage godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Move a model version to a stage
+//	@Description	Move a model version through the none, staging, production and archived stages. A model has at most one production version, the current one is archived when another version is moved to production. Only organization owners can move a version to production, and the version needs an accepted review if the model requires it
+//	@Tags			Model
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/stage [post]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+//	@Param			data		body	object	true	"stage and reason"
+func (api *Api) TransitionModelVersionStage(request *models.Request) *models.Response {
+	request.ParseJsonBody()
+	stage, _ := request.GetParsedBodyAttribute("stage").(string)
+	reason, _ := request.GetParsedBodyAttribute("reason").(string)
+	if stage == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Stage is required")
+	}
+	if !modelmodels.IsValidModelStage(stage) {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid stage %s", stage))
+	}
+	version, err := api.app.Dao().GetModelBranchVersion(request.GetModelBranchUUID(), request.GetPathParam("version"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if version.Stage == stage {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Version is already in stage %s", stage))
+	}
+	if !modelmodels.CanTransitionModelStage(version.Stage, stage) {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Cannot move a version from stage %s to %s", version.Stage, stage))
+	}
+	if stage == modelmodels.ModelStageProduction {
+		if errresp := api.validateModelOrgOwner(request, "Only organization owners can move a version to production"); errresp != nil {
+			return errresp
+		}
+		model, err := api.app.Dao().GetModelByName(request.GetOrgId(), request.GetModelName())
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		if model.RequireProductionReview {
+			accepted, err := api.app.Dao().HasAcceptedModelReview(version.UUID)
+			if err != nil {
+				return models.NewServerErrorResponse(err)
+			}
+			if !accepted {
+				return models.NewErrorResponse(http.StatusBadRequest, "Version needs an accepted review to be moved to production")
+			}
+		}
+	}
+	transition, err := api.app.Dao().TransitionModelVersionStage(request.GetModelUUID(), version.UUID, stage, reason, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, transition, "Model version stage updated")
+}
+
+func (api *Api) validateModelOrgOwner(request *models.Request, message string) *models.Response {
+	userOrganization, err := api.app.Dao().GetUserOrganizationByOrgIdAndUserUUID(request.GetOrgId(), request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if userOrganization == nil || userOrganization.Role != "owner" {
+		return models.NewErrorResponse(http.StatusForbidden, message)
+	}
+	return nil
+}
+
+var GetModelStageHistory ServiceFunc = (*Api).GetModelStageHistory
+var UpdateModelStageRules ServiceFunc = (*Api).UpdateModelStageRules
+var GetModelStageVersions ServiceFunc = (*Api).GetModelStageVersions
+var GetModelVersionStageHistory ServiceFunc = (*Api).GetModelVersionStageHistory
+var TransitionModelVersionStage ServiceFunc = (*Api).TransitionModelVersionStage
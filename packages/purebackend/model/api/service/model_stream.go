@@ -0,0 +1,139 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindModelStreamApi registers the model event stream api endpoints and the corresponding handlers.
+func BindModelStreamApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.GET("/:modelName/stream", api.StreamHandler(StreamModel), middlewares.ValidateModel(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/stream", api.StreamHandler(StreamModelVersion), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+}
+
+// StreamModel godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Stream the events of a model
+//	@Description	Stream the logs and metrics written for the versions of a model, its registered versions and its reviews as server-sent events. Only new events are sent unless the Last-Event-ID header or the last_event_id query parameter is set, the stream then resumes after that event
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		text/event-stream
+//	@Success		200	{object}	models.StreamEventResponse
+//	@Router			/org/{orgId}/model/{modelName}/stream [get]
+//	@Param			orgId			path	string	true	"Organization Id"
+//	@Param			modelName		path	string	true	"Model Name"
+//	@Param			kind			query	string	false	"Comma separated event kinds (log, metric, version, review)"
+//	@Param			last_event_id	query	string	false	"Id of the last received event"
+//	@Param			Last-Event-ID	header	string	false	"Id of the last received event"
+func (api *Api) StreamModel(request *models.Request) (*models.EventStream, *models.Response) {
+	kinds, errresp := parseStreamKinds(request, []string{models.StreamLog, models.StreamMetric, models.StreamVersion, models.StreamReview})
+	if errresp != nil {
+		return nil, errresp
+	}
+	return api.modelEventStream(request, uuid.NullUUID{}, kinds)
+}
+
+// StreamModelVersion godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Stream the logs and metrics of a model version
+//	@Description	Stream the logs and metrics written for a model version as server-sent events. Only new events are sent unless the Last-Event-ID header or the last_event_id query parameter is set, the stream then resumes after that event
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		text/event-stream
+//	@Success		200	{object}	models.StreamEventResponse
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/stream [get]
+//	@Param			orgId			path	string	true	"Organization Id"
+//	@Param			modelName		path	string	true	"Model Name"
+//	@Param			branchName		path	string	true	"Branch Name"
+//	@Param			version			path	string	true	"Version"
+//	@Param			kind			query	string	false	"Comma separated event kinds (log, metric)"
+//	@Param			last_event_id	query	string	false	"Id of the last received event"
+//	@Param			Last-Event-ID	header	string	false	"Id of the last received event"
+func (api *Api) StreamModelVersion(request *models.Request) (*models.EventStream, *models.Response) {
+	kinds, errresp := parseStreamKinds(request, []string{models.StreamLog, models.StreamMetric})
+	if errresp != nil {
+		return nil, errresp
+	}
+	return api.modelEventStream(request, uuid.NullUUID{UUID: request.GetModelBranchVersionUUID(), Valid: true}, kinds)
+}
+
+// modelEventStream returns the stream of the events of the model, of the
+// version only if set, following the last event id of the request.
+func (api *Api) modelEventStream(request *models.Request, versionUUID uuid.NullUUID, kinds []string) (*models.EventStream, *models.Response) {
+	lastEventID, errresp := api.parseLastEventID(request)
+	if errresp != nil {
+		return nil, errresp
+	}
+	modelUUID := request.GetModelUUID()
+	return &models.EventStream{
+		LastEventID: lastEventID,
+		Fetch: func(afterID uint64) ([]models.StreamEventResponse, error) {
+			return api.app.Dao().GetModelStreamEvents(modelUUID, versionUUID, kinds, afterID)
+		},
+	}, nil
+}
+
+// parseStreamKinds returns the event kinds requested by the kind query
+// parameter, all the allowed kinds if not set.
+func parseStreamKinds(request *models.Request, allowed []string) ([]string, *models.Response) {
+	param := request.GetQueryParam("kind")
+	if param == "" {
+		return allowed, nil
+	}
+	kinds := []string{}
+	for _, kind := range strings.Split(param, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		valid := false
+		for _, allowedKind := range allowed {
+			if kind == allowedKind {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid event kind %s, expected one of %s", kind, strings.Join(allowed, ", ")))
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// parseLastEventID returns the id of the last event received by the client
+// from the Last-Event-ID header or the last_event_id query parameter. A new
+// stream starts after the last event written so far.
+func (api *Api) parseLastEventID(request *models.Request) (uint64, *models.Response) {
+	value := request.Headers[http.CanonicalHeaderKey("Last-Event-ID")]
+	if value == "" {
+		value = request.GetQueryParam("last_event_id")
+	}
+	if value == "" {
+		lastEventID, err := api.app.Dao().GetLastStreamEventID()
+		if err != nil {
+			return 0, models.NewServerErrorResponse(err)
+		}
+		return lastEventID, nil
+	}
+	lastEventID, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid last event id %s", value))
+	}
+	return lastEventID, nil
+}
+
+var StreamModel StreamServiceFunc = (*Api).StreamModel
+var StreamModelVersion StreamServiceFunc = (*Api).StreamModelVersion
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	modelmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/models"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// maxTagLength is the maximum length of a version tag.
+const maxTagLength = 128
+
+// BindModelTagApi registers the model version tag api endpoints and the corresponding handlers.
+func BindModelTagApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.GET("/:modelName/tag/:tag", api.DefaultHandler(GetModelTaggedVersions), middlewares.ValidateModel(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/version/:version/tags", api.DefaultHandler(GetModelVersionTags), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/version/:version/tags", api.DefaultHandler(AddModelVersionTags), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+}
+
+// GetModelTaggedVersions godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the versions of a model with a tag
+//	@Description	Get the versions of every branch of a model with the tag, latest first
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/tag/{tag} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			tag			path	string	true	"Tag"
+func (api *Api) GetModelTaggedVersions(request *models.Request) *models.Response {
+	versions, err := api.app.Dao().GetModelTaggedVersions(request.GetModelUUID(), request.GetPathParam("tag"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, versions, "Model versions with tag")
+}
+
+// GetModelVersionTags godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the tags of a model version
+//	@Description	Get the tags of a model version
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/tags [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) GetModelVersionTags(request *models.Request) *models.Response {
+	tags, err := api.app.Dao().GetModelVersionTags(request.GetModelBranchVersionUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, tags, "Model version tags")
+}
+
+// AddModelVersionTags godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Tag a model version
+//	@Description	Add free-form tags to a model version. Tags are immutable, the tags the version already has are kept
+//	@Tags			Model
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/tags [post]
+//	@Param			orgId		path	string							true	"Organization Id"
+//	@Param			modelName	path	string							true	"Model Name"
+//	@Param			branchName	path	string							true	"Branch Name"
+//	@Param			version		path	string							true	"Version"
+//	@Param			data		body	commonmodels.VersionTagsRequest	true	"Tags"
+func (api *Api) AddModelVersionTags(request *models.Request) *models.Response {
+	request.ParseJsonBody()
+	tags, errresp := parseVersionTags(request.GetParsedBodyAttribute("tags"))
+	if errresp != nil {
+		return errresp
+	}
+	allTags, err := api.app.Dao().AddModelVersionTags(request.GetOrgId(), request.GetModelBranchVersionUUID(), tags, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, allTags, "Model version tagged")
+}
+
+// parseVersionTags validates the tags of the request body, dropping the
+// surrounding spaces and duplicates.
+func parseVersionTags(value interface{}) ([]string, *models.Response) {
+	values, ok := value.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Tags are required")
+	}
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		tag, ok := value.(string)
+		tag = strings.TrimSpace(tag)
+		if !ok || tag == "" || len(tag) > maxTagLength {
+			return nil, models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid tag %v", value))
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// filterModelVersionsByTag keeps the versions with the tag.
+func (api *Api) filterModelVersionsByTag(modelUUID uuid.UUID, versions []modelmodels.ModelBranchVersionResponse, tag string) ([]modelmodels.ModelBranchVersionResponse, error) {
+	taggedVersions, err := api.app.Dao().GetModelTaggedVersions(modelUUID, tag)
+	if err != nil {
+		return nil, err
+	}
+	tagged := map[uuid.UUID]bool{}
+	for _, version := range taggedVersions {
+		tagged[version.UUID] = true
+	}
+	filtered := []modelmodels.ModelBranchVersionResponse{}
+	for _, version := range versions {
+		if tagged[version.UUID] {
+			filtered = append(filtered, version)
+		}
+	}
+	return filtered, nil
+}
+
+var GetModelTaggedVersions ServiceFunc = (*Api).GetModelTaggedVersions
+var GetModelVersionTags ServiceFunc = (*Api).GetModelVersionTags
+var AddModelVersionTags ServiceFunc = (*Api).AddModelVersionTags
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/trash"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindModelTrashApi registers the model and model version delete api endpoints and the corresponding handlers.
+func BindModelTrashApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.DELETE("/:modelName/delete", api.DefaultHandler(DeleteModel), middlewares.ValidateModel(api.app))
+	modelGroup.DELETE("/:modelName/branch/:branchName/version/:version/delete", api.DefaultHandler(DeleteModelVersion), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app), middlewares.ValidateModelBranchVersion(api.app))
+}
+
+// DeleteModel godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Delete a model
+//	@Description	Move a model with all its branches and versions to the trash of the organization. It can be restored until it is purged. A model referenced by an alias or an open review cannot be deleted
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/delete [delete]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+func (api *Api) DeleteModel(request *models.Request) *models.Response {
+	if errresp := api.validateModelUnreferenced(request, "Model", uuid.NullUUID{}, uuid.NullUUID{}); errresp != nil {
+		return errresp
+	}
+	item, err := api.app.Dao().TrashModel(request.GetOrgId(), request.GetModelUUID(), request.GetModelName(), request.GetUserUUID(), trash.PurgeTime(api.app))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, item, "Model moved to trash")
+}
+
+// DeleteModelVersion godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Delete a version of a model branch
+//	@Description	Move a version of a model branch to the trash of the organization. It can be restored until it is purged, its files are removed from the storage when it is purged. A version referenced by an alias or an open review cannot be deleted
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/version/{version}/delete [delete]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			version		path	string	true	"Version"
+func (api *Api) DeleteModelVersion(request *models.Request) *models.Response {
+	version, err := api.app.Dao().GetModelBranchVersion(request.GetModelBranchUUID(), request.GetPathParam("version"))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if errresp := api.validateModelUnreferenced(request, "Version", uuid.NullUUID{}, uuid.NullUUID{UUID: version.UUID, Valid: true}); errresp != nil {
+		return errresp
+	}
+	name := fmt.Sprintf("%s/%s/%s", request.GetModelName(), request.GetModelBranchName(), version.Version)
+	item, err := api.app.Dao().TrashModelVersion(request.GetOrgId(), version.UUID, name, request.GetUserUUID(), trash.PurgeTime(api.app))
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, item, "Model version moved to trash")
+}
+
+func (api *Api) validateModelUnreferenced(request *models.Request, subject string, branchUUID uuid.NullUUID, versionUUID uuid.NullUUID) *models.Response {
+	references, err := api.app.Dao().GetModelReferences(request.GetModelUUID(), branchUUID, versionUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if len(references.Aliases) > 0 {
+		return models.NewErrorResponse(http.StatusConflict, fmt.Sprintf("%s is referenced by the alias %s", subject, references.Aliases[0]))
+	}
+	if references.OpenReviews > 0 {
+		return models.NewErrorResponse(http.StatusConflict, fmt.Sprintf("%s is referenced by an open review", subject))
+	}
+	return nil
+}
+
+var DeleteModel ServiceFunc = (*Api).DeleteModel
+var DeleteModelVersion ServiceFunc = (*Api).DeleteModelVersion
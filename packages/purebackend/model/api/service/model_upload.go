@@ -0,0 +1,273 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/tools/digest"
+	"github.com/PureMLHQ/PureML/packages/purebackend/model/middlewares"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindModelUploadApi registers the admin api endpoints and the corresponding handlers.
+func BindModelUploadApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	modelGroup := rg.Group("/org/:orgId/model", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/upload", api.DefaultHandler(CreateModelUploadSession), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.GET("/:modelName/branch/:branchName/upload/:uploadId", api.DefaultHandler(GetModelUploadSession), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.PUT("/:modelName/branch/:branchName/upload/:uploadId/chunk/:chunkNumber", api.ChunkHandler(UploadModelChunk), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+	modelGroup.POST("/:modelName/branch/:branchName/upload/:uploadId/finalize", api.DefaultHandler(FinalizeModelUpload), middlewares.ValidateModel(api.app), middlewares.ValidateModelBranch(api.app))
+}
+
+// CreateModelUploadSession godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Create model upload session
+//	@Description	Create a resumable upload session for a model file. Chunks are uploaded separately and the model version is registered on finalize
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/upload [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			modelName	path	string								true	"Model Name"
+//	@Param			branchName	path	string								true	"Branch Name"
+//	@Param			data		body	commonmodels.UploadSessionRequest	true	"Upload session details"
+func (api *Api) CreateModelUploadSession(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	modelBranchUUID := request.GetModelBranchUUID()
+	request.ParseJsonBody()
+	fileName, _ := request.GetParsedBodyAttribute("file_name").(string)
+	if fileName == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "File name is required")
+	}
+	storage, _ := request.GetParsedBodyAttribute("storage").(string)
+	_, errresp := api.GetSourceSecrets(storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	session, err := api.app.Dao().CreateModelUploadSession(orgId, modelBranchUUID, filepath.Base(fileName), storage, userUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, session, "Model upload session created")
+}
+
+// GetModelUploadSession godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get model upload session
+//	@Description	Get model upload session details along with the received chunk ranges
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/upload/{uploadId} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			uploadId	path	string	true	"Upload session UUID"
+func (api *Api) GetModelUploadSession(request *models.Request) *models.Response {
+	session, errresp := api.getModelUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	return models.NewDataResponse(http.StatusOK, session, "Model upload session details")
+}
+
+// UploadModelChunk godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Upload model file chunk
+//	@Description	Upload a numbered chunk of the model file. Uploading the same chunk number again replaces it
+//	@Tags			Model
+//	@Accept			application/octet-stream
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/upload/{uploadId}/chunk/{chunkNumber} [put]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			modelName	path	string	true	"Model Name"
+//	@Param			branchName	path	string	true	"Branch Name"
+//	@Param			uploadId	path	string	true	"Upload session UUID"
+//	@Param			chunkNumber	path	int		true	"Chunk number (starting from 1)"
+func (api *Api) UploadModelChunk(request *models.Request, body io.Reader) *models.Response {
+	orgId := request.GetOrgId()
+	session, errresp := api.getModelUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	if session.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Upload session already finalized")
+	}
+	chunkNumber, err := strconv.Atoi(request.GetPathParam("chunkNumber"))
+	if err != nil || chunkNumber < 1 {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid chunk number")
+	}
+	sourceSecrets, errresp := api.GetSourceSecrets(session.Storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	size, err := fs.UploadReader(body, modelUploadChunkKey(request, session.UUID, chunkNumber))
+	if err != nil {
+		return chunkUploadErrorResponse(err)
+	}
+	if size == 0 {
+		fs.Delete(modelUploadChunkKey(request, session.UUID, chunkNumber))
+		return models.NewErrorResponse(http.StatusBadRequest, "Chunk is empty")
+	}
+	chunk, err := api.app.Dao().CreateUploadChunk(session.UUID, chunkNumber, size)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, chunk, "Model file chunk uploaded")
+}
+
+// FinalizeModelUpload godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Finalize model upload session
+//	@Description	Assemble the uploaded chunks into the model file and register it as a new model version
+//	@Tags			Model
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/model/{modelName}/branch/{branchName}/upload/{uploadId}/finalize [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			modelName	path	string								true	"Model Name"
+//	@Param			branchName	path	string								true	"Branch Name"
+//	@Param			uploadId	path	string								true	"Upload session UUID"
+//	@Param			data		body	commonmodels.FinalizeUploadRequest	true	"Expected hash"
+func (api *Api) FinalizeModelUpload(request *models.Request) *models.Response {
+	modelBranchUUID := request.GetModelBranchUUID()
+	session, errresp := api.getModelUploadSession(request)
+	if errresp != nil {
+		return errresp
+	}
+	if session.IsComplete {
+		return models.NewErrorResponse(http.StatusBadRequest, "Upload session already finalized")
+	}
+	request.ParseJsonBody()
+	modelHash, _ := request.GetParsedBodyAttribute("hash").(string)
+	if modelHash == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Hash is required")
+	}
+	hashAlgorithm, _ := request.GetParsedBodyAttribute("hash_algorithm").(string)
+	digester, errresp := api.NewDigester(hashAlgorithm)
+	if errresp != nil {
+		return errresp
+	}
+	if errresp := api.validateModelBranchRegistration(request); errresp != nil {
+		return errresp
+	}
+	if errresp := api.checkModelVersionHash(modelBranchUUID, modelHash); errresp != nil {
+		return errresp
+	}
+	if len(session.Chunks) == 0 {
+		return models.NewErrorResponse(http.StatusBadRequest, "No chunks uploaded")
+	}
+	var chunkKeys []string
+	for i, chunk := range session.Chunks {
+		if chunk.Number != i+1 {
+			return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Missing chunk %d", i+1))
+		}
+		chunkKeys = append(chunkKeys, modelUploadChunkKey(request, session.UUID, chunk.Number))
+	}
+	if errresp := api.claimUploadSession(session.UUID); errresp != nil {
+		return errresp
+	}
+	response := api.registerModelUpload(request, session, modelHash, digester, chunkKeys)
+	if response.Body.Status != http.StatusOK {
+		// reopen the session so that the finalize can be retried
+		api.app.Dao().ReleaseUploadSession(session.UUID)
+	}
+	return response
+}
+
+// registerModelUpload assembles the chunks of the claimed upload session
+// and registers the model file as a new version of the branch.
+func (api *Api) registerModelUpload(request *models.Request, session *models.UploadSessionResponse, modelHash string, digester *digest.Digester, chunkKeys []string) *models.Response {
+	orgId := request.GetOrgId()
+	userUUID := request.GetUserUUID()
+	modelUUID := request.GetModelUUID()
+	modelBranchUUID := request.GetModelBranchUUID()
+	sourceSecrets, errresp := api.GetSourceSecrets(session.Storage, orgId)
+	if errresp != nil {
+		return errresp
+	}
+	fs, err := api.app.NewFilesystem(sourceSecrets)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	defer fs.Close()
+	dataKey, err := encryption.NewDataKey(api.app, orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	filePath := modelUploadFileKey(request, session)
+	err = fs.Encrypted(dataKey.Key).Compose(chunkKeys, filePath, session.FileName, digester.Hashes()...)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	// on mismatch the chunks are kept so that the bad ones can be uploaded again
+	errresp = api.VerifyUploadedFileHash(digester, modelHash, filePath, sourceSecrets)
+	if errresp != nil {
+		return errresp
+	}
+	attrs, err := fs.Attributes(filePath)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	blob, softQuotaExceeded, errresp := api.StoreUploadedBlob(orgId, modelUUID, sourceSecrets, filePath, digester.Sha256(), attrs.Size, dataKey)
+	if errresp != nil {
+		return errresp
+	}
+	modelVersion, err := api.app.Dao().RegisterModelFile(modelBranchUUID, sourceSecrets.SourceType, sourceSecrets.PublicURL, blob.Key, false, digester.Sum(), digester.Algorithm, blob.Digest, session.FileName, uuid.NullUUID{UUID: blob.UUID, Valid: true}, blob.DataKey, userUUID)
+	if err != nil {
+		api.ReleaseBlob(sourceSecrets, blob.UUID)
+		return registerVersionErrorResponse(err)
+	}
+	// chunks are kept until the version is registered so that a failed
+	// finalize can be retried
+	fs.DeletePrefix(fmt.Sprintf("model-registry/%s/models/%s/%s/uploads/%s", orgId, modelUUID, modelBranchUUID, session.UUID))
+	return models.NewDataResponse(http.StatusOK, modelVersion, withSoftQuotaWarning("Model successfully registered", softQuotaExceeded))
+}
+
+func (api *Api) getModelUploadSession(request *models.Request) (*models.UploadSessionResponse, *models.Response) {
+	sessionUUID, err := uuid.FromString(request.GetPathParam("uploadId"))
+	if err != nil {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Invalid upload session id")
+	}
+	session, err := api.app.Dao().GetModelUploadSession(request.GetModelBranchUUID(), sessionUUID)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	if session == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Upload session not found")
+	}
+	return session, nil
+}
+
+func modelUploadChunkKey(request *models.Request, sessionUUID uuid.UUID, chunkNumber int) string {
+	return fmt.Sprintf("model-registry/%s/models/%s/%s/uploads/%s/%d", request.GetOrgId(), request.GetModelUUID(), request.GetModelBranchUUID(), sessionUUID, chunkNumber)
+}
+
+var CreateModelUploadSession ServiceFunc = (*Api).CreateModelUploadSession
+var GetModelUploadSession ServiceFunc = (*Api).GetModelUploadSession
+var UploadModelChunk ChunkServiceFunc = (*Api).UploadModelChunk
+var FinalizeModelUpload ServiceFunc = (*Api).FinalizeModelUpload
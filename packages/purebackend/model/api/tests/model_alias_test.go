@@ -0,0 +1,273 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+// setModelAlias points the alias of the Demo Model to the version of the
+// branch.
+func setModelAlias(t *testing.T, e *echo.Echo, name string, branch string, version string) {
+	req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model/alias/"+name, strings.NewReader(`{"branch":"`+branch+`","version":"`+version+`"}`))
+	req.Header.Set("Authorization", test.ValidAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Expected alias to be set, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSetModelAlias(t *testing.T) {
+	aliasUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/alias/production"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "set model alias + unauthorized",
+			Method:         http.MethodPost,
+			Url:            aliasUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "set model alias + valid token + reserved name",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/alias/v3",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch":"dev","version":"v1"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid alias name v3"`,
+			},
+		},
+		{
+			Name:   "set model alias + valid token + version not found",
+			Method: http.MethodPost,
+			Url:    aliasUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch":"dev","version":"v9"}`),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Version not found"`,
+			},
+		},
+		{
+			Name:   "set model alias + valid token",
+			Method: http.MethodPost,
+			Url:    aliasUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch":"dev","version":"v1"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"production"`,
+				`"branch":"dev"`,
+				`"version":"v1"`,
+				`"handle":"demo"`,
+				`"message":"Model alias updated"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestResolveModelAlias(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "get model branch version + valid token + alias",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/production",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelAlias(t, e, "production", "dev", "v1")
+				seedModelVersionFile(t, app, "test", false)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v1"`,
+			},
+			NotExpectedContent: []string{
+				`"version":"v2"`,
+			},
+		},
+		{
+			Name:   "get model branch version + valid token + moved alias",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/production",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelAlias(t, e, "production", "dev", "v1")
+				seedModelVersionFile(t, app, "test", false)
+				setModelAlias(t, e, "production", "dev", "v2")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v2"`,
+			},
+		},
+		{
+			Name:   "get model branch version + valid token + alias of another branch",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/main/version/production",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelAlias(t, e, "production", "dev", "v1")
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`Model Branch Version not found`,
+			},
+		},
+		{
+			Name:   "get model alias history + valid token",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/alias/production/history",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelAlias(t, e, "production", "dev", "v1")
+				seedModelVersionFile(t, app, "test", false)
+				setModelAlias(t, e, "production", "dev", "v2")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"name":"production","branch":"dev","version":"v2","moved_by":{`,
+				`},{"name":"production","branch":"dev","version":"v1","moved_by":{`,
+				`"message":"Model alias history"`,
+			},
+		},
+		{
+			Name:   "delete model alias + valid token",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/alias/production/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelAlias(t, e, "production", "dev", "v1")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"message":"Model alias deleted"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				alias, err := app.Dao().GetModelAlias(validDemoModelUuid, "production")
+				if err != nil || alias != nil {
+					t.Fatalf("Expected alias to be deleted, got %+v %v", alias, err)
+				}
+				history, err := app.Dao().GetModelAliasHistory(validDemoModelUuid, "production")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(history) != 2 || history[0].Version != "" || history[1].Version != "v1" {
+					t.Fatalf("Expected the removal in the alias history, got %+v", history)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestModelVersionTags(t *testing.T) {
+	tagsUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/tags"
+	tagVersion := func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+		req := httptest.NewRequest(http.MethodPost, tagsUrl, strings.NewReader(`{"tags":["resnet","baseline"]}`))
+		req.Header.Set("Authorization", test.ValidAdminToken)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != 200 {
+			t.Fatalf("Expected version to be tagged, got %d %s", rec.Code, rec.Body.String())
+		}
+		seedModelVersionFile(t, app, "test", false)
+	}
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "add model version tags + valid token + invalid tag",
+			Method: http.MethodPost,
+			Url:    tagsUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"tags":["resnet"," "]}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid tag  "`,
+			},
+		},
+		{
+			Name:   "add model version tags + valid token + existing tags kept",
+			Method: http.MethodPost,
+			Url:    tagsUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: tagVersion,
+			Body:           strings.NewReader(`{"tags":["resnet","imagenet","imagenet"]}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":["baseline","imagenet","resnet"]`,
+				`"message":"Model version tagged"`,
+			},
+		},
+		{
+			Name:   "get model tagged versions + valid token",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/tag/baseline",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: tagVersion,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v1"`,
+				`"message":"Model versions with tag"`,
+			},
+			NotExpectedContent: []string{
+				`"version":"v2"`,
+			},
+		},
+		{
+			Name:   "get model branch versions + valid token + tag filter",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version?tag=resnet",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: tagVersion,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v1"`,
+			},
+			NotExpectedContent: []string{
+				`"version":"v2"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
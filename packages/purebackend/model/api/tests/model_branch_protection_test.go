@@ -0,0 +1,433 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	modeldbmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+var validModelReviewUuid = uuid.Must(uuid.FromString("44444444-4444-4444-4444-444444444444"))
+
+// protectModelBranch sets the protection rules of the Demo Model branch.
+func protectModelBranch(t *testing.T, app *test.TestApp, branchUUID uuid.UUID, rules models.BranchProtectionRules) {
+	if _, err := app.Dao().SetModelBranchProtection(test.ValidAdminUserOrgUuid, branchUUID, rules, test.ValidAdminUserUuid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// demoModelMainBranchUuid returns the uuid of the Demo Model main branch.
+func demoModelMainBranchUuid(t *testing.T, app *test.TestApp) uuid.UUID {
+	mainBranch, err := app.Dao().GetModelBranchByName(test.ValidAdminUserOrgUuid, "Demo Model", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mainBranch.UUID
+}
+
+// createModelReview opens a review of the Demo Model dev v1 version into
+// main as validModelReviewUuid.
+func createModelReview(t *testing.T, app *test.TestApp) {
+	v1, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := app.Dao().CreateModelReview(validDemoModelUuid, test.ValidAdminUserUuid, validDemoModelDevBranchUuid, v1.UUID, demoModelMainBranchUuid(t, app), "Release v1", "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Dao().Datastore().DB.Model(&modeldbmodels.ModelReview{}).Where("uuid = ?", review.UUID).Update("uuid", validModelReviewUuid).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func addOrgMember(t *testing.T, app *test.TestApp) {
+	if _, err := app.Dao().CreateUserOrganizationFromEmailAndOrgId("notadmin@aztlan.in", test.ValidAdminUserOrgUuid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetModelBranchProtection(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "get model branch protection + valid token + main branch",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/main/protection",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"block_direct_registration":true`,
+				`"required_approvals":0`,
+				`"block_deletion":false`,
+				`"message":"Model branch protection"`,
+			},
+		},
+		{
+			Name:   "get model branch protection + valid token + unprotected branch",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/protection",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Branch is not protected"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestSetModelBranchProtection(t *testing.T) {
+	protectionUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/protection"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "set model branch protection + member token",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+			},
+			Body:           strings.NewReader(`{"block_deletion":true}`),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to protect the branches of this model"`,
+			},
+		},
+		{
+			Name:   "set model branch protection + valid token + reviewer not in organization",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"reviewers":["notadmin"]}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"User notadmin is not a member of the organization"`,
+			},
+		},
+		{
+			Name:   "set model branch protection + valid token + negative approvals",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"required_approvals":-1}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"required_approvals must be a non-negative integer"`,
+			},
+		},
+		{
+			Name:   "set model branch protection + valid token + approvals not a number",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"required_approvals":"2"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"required_approvals must be a non-negative integer"`,
+			},
+		},
+		{
+			Name:   "set model branch protection + valid token",
+			Method: http.MethodPost,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"block_direct_registration":true,"required_approvals":2,"block_deletion":true,"reviewers":["demo"]}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"block_direct_registration":true`,
+				`"required_approvals":2`,
+				`"block_deletion":true`,
+				`"reviewers":[{"uuid":"` + test.ValidAdminUserUuid.String() + `"`,
+				`"message":"Model branch protection updated"`,
+			},
+		},
+		{
+			Name:   "import model + valid token + protected branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/import",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				protectModelBranch(t, app, validDemoModelDevBranchUuid, models.BranchProtectionRules{BlockDirectRegistration: true})
+			},
+			Body:           strings.NewReader(`{}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Cannot register model directly to dev branch"`,
+			},
+		},
+		{
+			Name:   "delete model branch + valid token + protected branch",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				protectModelBranch(t, app, validDemoModelDevBranchUuid, models.BranchProtectionRules{BlockDeletion: true})
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Branch is protected against deletion"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestDeleteModelBranchProtection(t *testing.T) {
+	protectionUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/main/protection/delete"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "delete model branch protection + member token",
+			Method: http.MethodDelete,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+			},
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to unprotect the branches of this model"`,
+			},
+		},
+		{
+			Name:   "delete model branch protection + valid token",
+			Method: http.MethodDelete,
+			Url:    protectionUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"message":"Model branch protection removed"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				protection, err := app.Dao().GetModelBranchProtection(demoModelMainBranchUuid(t, app))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if protection != nil {
+					t.Fatal("Expected the main branch to be unprotected")
+				}
+			},
+		},
+		{
+			Name:   "import model + valid token + unprotected main branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/main/import",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if err := app.Dao().DeleteModelBranchProtection(demoModelMainBranchUuid(t, app)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Url is required"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestApproveModelReview(t *testing.T) {
+	approveUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/review/" + validModelReviewUuid.String() + "/approve"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "approve model review + valid token + not found",
+			Method: http.MethodPost,
+			Url:    approveUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Review with given ID not found"`,
+			},
+		},
+		{
+			Name:   "approve model review + valid token + own review",
+			Method: http.MethodPost,
+			Url:    approveUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				createModelReview(t, app)
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"You cannot approve your own review"`,
+			},
+		},
+		{
+			Name:   "approve model review + member token + not a reviewer",
+			Method: http.MethodPost,
+			Url:    approveUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+				createModelReview(t, app)
+				protectModelBranch(t, app, demoModelMainBranchUuid(t, app), models.BranchProtectionRules{Reviewers: []uuid.UUID{test.ValidAdminUserUuid}})
+			},
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not a reviewer of this branch"`,
+			},
+		},
+		{
+			Name:   "approve model review + member token",
+			Method: http.MethodPost,
+			Url:    approveUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+				createModelReview(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"review_uuid":"` + validModelReviewUuid.String() + `"`,
+				`"handle":"notadmin"`,
+				`"message":"Model review approved"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestMergeModelReviewProtectedBranch(t *testing.T) {
+	updateUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/review/" + validModelReviewUuid.String() + "/update"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "accept model review + valid token + missing approvals",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				createModelReview(t, app)
+				protectModelBranch(t, app, demoModelMainBranchUuid(t, app), models.BranchProtectionRules{BlockDirectRegistration: true, RequiredApprovals: 1})
+			},
+			Body:           strings.NewReader(`{"is_accepted":true}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Review needs 1 approvals before merge"`,
+			},
+		},
+		{
+			Name:   "accept model review + valid token + not a reviewer",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+				createModelReview(t, app)
+				protectModelBranch(t, app, demoModelMainBranchUuid(t, app), models.BranchProtectionRules{Reviewers: []uuid.UUID{test.ValidUserUuid}})
+			},
+			Body:           strings.NewReader(`{"is_accepted":true}`),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not allowed to accept reviews into this branch"`,
+			},
+		},
+		{
+			Name:   "accept model review + valid token + hash already on branch",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				createModelReview(t, app)
+				if _, err := app.Dao().RegisterModelFile(demoModelMainBranchUuid(t, app), "LOCAL", "", "model.pkl", false, "1234567890", "sha256", "1234567890", "", uuid.NullUUID{}, nil, test.ValidAdminUserUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"is_accepted":true}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Model with this hash already exists"`,
+			},
+		},
+		{
+			Name:   "accept model review + valid token + approved",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				addOrgMember(t, app)
+				createModelReview(t, app)
+				protectModelBranch(t, app, demoModelMainBranchUuid(t, app), models.BranchProtectionRules{BlockDirectRegistration: true, RequiredApprovals: 1})
+				if _, err := app.Dao().ApproveModelReview(validModelReviewUuid, test.ValidUserUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"is_accepted":true}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"is_accepted":true`,
+				`"message":"Model review updated"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetModelBranchVersion(demoModelMainBranchUuid(t, app), "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version == nil || version.Hash != "1234567890" {
+					t.Fatalf("Expected the review to be merged into main as v1, got %+v", version)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
 )
 
 func TestGetModelAllBranches(t *testing.T) {
@@ -332,6 +333,78 @@ func TestCreateModelBranch(t *testing.T) {
 				`"message":"Model branch created"`,
 			},
 		},
+		{
+			Name:   "create model branch + valid token + from version without branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/create",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":"experiment","from_version":"v1"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Both from_branch and from_version are required to create a branch from a version"`,
+			},
+		},
+		{
+			Name:   "create model branch + valid token + from version not found",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/create",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":"experiment","from_branch":"dev","from_version":"v9"}`),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"From version not found"`,
+			},
+		},
+		{
+			Name:   "create model branch + valid token + from version",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/create",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, "experiment", false)
+				v2, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := app.Dao().CreateLogForModelVersion("accuracy", "0.9", v2.UUID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"branch_name":"experiment","from_branch":"dev","from_version":"v2"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"experiment"`,
+				`"base":{"branch":{"uuid":"` + validDemoModelDevBranchUuid.String() + `","name":"dev"},"version":{"uuid":`,
+				`"version":"v2"}}`,
+				`"message":"Model branch created"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				branch, err := app.Dao().GetModelBranchByName(test.ValidAdminUserOrgUuid, "Demo Model", "experiment")
+				if err != nil {
+					t.Fatal(err)
+				}
+				versions, err := app.Dao().GetModelBranchAllVersions(branch.UUID, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(versions) != 1 || versions[0].Version != "v1" || versions[0].Hash != sha256Hex("experiment") {
+					t.Fatalf("Expected the branch to start with a copy of dev/v2 as v1, got %+v", versions)
+				}
+				logs, err := app.Dao().GetLogForModelVersion(versions[0].UUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(logs) != 1 || logs[0].Key != "accuracy" {
+					t.Fatalf("Expected the logs of the version to be copied, got %+v", logs)
+				}
+			},
+		},
 	}
 
 	for _, scenario := range scenarios {
@@ -339,10 +412,209 @@ func TestCreateModelBranch(t *testing.T) {
 	}
 }
 
-// TODO
 func TestUpdateModelBranch(t *testing.T) {
+	updateUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/update"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "update model branch + unauthorized",
+			Method:         http.MethodPost,
+			Url:            updateUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "update model branch + valid token + empty name",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":""}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Branch name cannot be empty"`,
+			},
+		},
+		{
+			Name:   "update model branch + valid token + branch already exists",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":"main"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Branch already exists"`,
+			},
+		},
+		{
+			Name:   "update model branch + valid token + rename",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"branch_name":"experiments"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"experiments"`,
+				`"is_default":false`,
+				`"message":"Model branch updated"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				branch, err := app.Dao().GetModelBranchByUUID(validDemoModelDevBranchUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if branch.Name != "experiments" {
+					t.Fatalf("Expected the branch to be renamed, got %s", branch.Name)
+				}
+			},
+		},
+		{
+			Name:   "update model branch + valid token + default branch",
+			Method: http.MethodPost,
+			Url:    updateUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"is_default":true}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"dev"`,
+				`"is_default":true`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				mainBranch, err := app.Dao().GetModelBranchByName(test.ValidAdminUserOrgUuid, "Demo Model", "main")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if mainBranch.IsDefault {
+					t.Fatal("Expected main to no longer be the default branch")
+				}
+			},
+		},
+		{
+			Name:   "update model branch + valid token + unset default branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/main/update",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"is_default":false}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Model needs a default branch, make another branch the default instead"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
 }
 
-// TODO
 func TestDeleteModelBranch(t *testing.T) {
+	deleteUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/delete"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "delete model branch + unauthorized",
+			Method:         http.MethodDelete,
+			Url:            deleteUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "delete model branch + valid token + default branch",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/main/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Default branch cannot be deleted"`,
+			},
+		},
+		{
+			Name:   "delete model branch + valid token",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"kind":"model_branch"`,
+				`"name":"Demo Model/dev"`,
+				`"message":"Model branch moved to trash"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				branch, err := app.Dao().GetModelBranchByUUID(validDemoModelDevBranchUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if branch != nil {
+					t.Fatal("Expected the branch to be hidden once in the trash")
+				}
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version != nil {
+					t.Fatal("Expected the versions of the branch to be hidden once in the trash")
+				}
+			},
+		},
+		{
+			Name:   "delete model branch + valid token + referenced by alias",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelAlias(t, e, "champion", "dev", "v1")
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"Branch is referenced by the alias champion"`,
+			},
+		},
+		{
+			Name:   "delete model branch + valid token + referenced by open review",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				mainBranch, err := app.Dao().GetModelBranchByName(test.ValidAdminUserOrgUuid, "Demo Model", "main")
+				if err != nil {
+					t.Fatal(err)
+				}
+				v1, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = app.Dao().CreateModelReview(validDemoModelUuid, test.ValidAdminUserUuid, validDemoModelDevBranchUuid, v1.UUID, mainBranch.UUID, "Release v1", "", false, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"Branch is referenced by an open review"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
 }
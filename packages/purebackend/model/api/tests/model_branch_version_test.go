@@ -1,11 +1,22 @@
 package tests
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/settings"
+	modeldbmodels "github.com/PureMLHQ/PureML/packages/purebackend/model/dbmodels"
 	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
 )
 
 func TestGetModelBranchAllVersions(t *testing.T) {
@@ -678,3 +689,1002 @@ func TestRegisterModel(t *testing.T) {
 		scenario.Test(t)
 	}
 }
+
+func TestRegisterModelStorageSources(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	newBody := func(storage string) (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(map[string]string{
+			"hash":    sha256Hex("test"),
+			"storage": storage,
+		}, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	localBody, localContentType := newBody("LOCAL")
+	disabledBody, disabledContentType := newBody("PUREML-STORAGE")
+	managedBody, managedContentType := newBody("PUREML-STORAGE")
+	blobKey := testBlobKey(sha256Hex("test"))
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register model + valid token + local storage",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  localContentType,
+			},
+			Body:           localBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"version":"v2"`,
+				`"path":"` + blobKey + `"`,
+				`"source_type":"LOCAL"`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				content, err := os.ReadFile(filepath.Join(app.LocalStorageDir(), version.Path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != "test" {
+					t.Fatalf("Expected stored file content %q, got %q", "test", content)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + pureml storage not configured",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  disabledContentType,
+			},
+			Body:           disabledBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"PureML storage is not configured for this instance"`,
+			},
+		},
+		{
+			Name:   "register model + valid token + pureml storage",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  managedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				app.Settings().S3 = settings.S3Config{
+					Enabled:        true,
+					Bucket:         test.TestS3BucketName,
+					Region:         "us-east-1",
+					Endpoint:       s3.URL,
+					AccessKey:      "test-key",
+					Secret:         "test-secret",
+					ForcePathStyle: true,
+				}
+			},
+			Body:           managedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"version":"v2"`,
+				`"path":"` + s3.URL + "/" + test.TestS3BucketName + "/" + blobKey + `"`,
+				`"source_type":"PUREML-STORAGE"`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				key := strings.TrimPrefix(version.Path, s3.URL+"/"+test.TestS3BucketName+"/")
+				if content, ok := s3.Object(test.TestS3BucketName, key); !ok || string(content) != "test" {
+					t.Fatalf("Expected file to be stored in the managed bucket at %s", key)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// seedModelBlob stores content as a LOCAL blob of the admin org
+// referenced once by another artifact.
+func seedModelBlob(t *testing.T, app *test.TestApp, content string) {
+	fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err := fs.Upload([]byte(content), testBlobKey(sha256Hex(content))); err != nil {
+		t.Fatal(err)
+	}
+	blob, _, err := app.Dao().AcquireBlob(test.ValidAdminUserOrgUuid, "LOCAL", "", sha256Hex(content), int64(len(content)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Dao().CompleteBlob(blob.UUID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func modelBlobRefCount(t *testing.T, app *test.TestApp, digest string) int {
+	var blob dbmodels.Blob
+	if err := app.Dao().Datastore().DB.Where("digest = ?", digest).Limit(1).Find(&blob).Error; err != nil {
+		t.Fatal(err)
+	}
+	return blob.RefCount
+}
+
+func TestRegisterModelDeduplication(t *testing.T) {
+	newBody := func(hash string, hashAlgorithm string) (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(map[string]string{
+			"hash":           hash,
+			"hash_algorithm": hashAlgorithm,
+			"storage":        "LOCAL",
+		}, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	fileHash := sha256Hex("test")
+	newBlobBody, newBlobContentType := newBody(fileHash, "sha256")
+	storedBody, storedContentType := newBody(fileHash, "sha256")
+	md5Body, md5ContentType := newBody("098f6bcd4621d373cade4e832627b4f6", "md5")
+	claimedBody, claimedContentType := newBody(sha256Hex("secret"), "sha256")
+	pendingBody, pendingContentType := newBody(fileHash, "sha256")
+	uploadDir := fmt.Sprintf("model-registry/%s/models/%s/%s", test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid)
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register model + valid token + new content stored as blob",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  newBlobContentType,
+			},
+			Body:           newBlobBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"path":"` + testBlobKey(fileHash) + `"`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := modelBlobRefCount(t, app, fileHash); count != 1 {
+					t.Fatalf("Expected blob to be referenced once, got %d", count)
+				}
+				entries, _ := os.ReadDir(filepath.Join(app.LocalStorageDir(), uploadDir))
+				if len(entries) > 0 {
+					t.Fatalf("Expected uploaded file to be moved to its blob, found %d files", len(entries))
+				}
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				unreferenced, err := app.Dao().DeleteModelVersion(version.UUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(unreferenced) != 1 || unreferenced[0].Key != testBlobKey(fileHash) {
+					t.Fatalf("Expected blob to be unreferenced after deleting its only version, got %v", unreferenced)
+				}
+				if count := modelBlobRefCount(t, app, fileHash); count != 0 {
+					t.Fatalf("Expected blob to be removed, got %d references", count)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + stored content deduplicated after verification",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  storedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelBlob(t, app, "test")
+			},
+			Body:           storedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"hash":"` + fileHash + `"`,
+				`"path":"` + testBlobKey(fileHash) + `"`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := modelBlobRefCount(t, app, fileHash); count != 2 {
+					t.Fatalf("Expected blob to be referenced twice, got %d", count)
+				}
+				entries, _ := os.ReadDir(filepath.Join(app.LocalStorageDir(), uploadDir))
+				if len(entries) > 0 {
+					t.Fatalf("Expected duplicate upload to be removed, found %d files", len(entries))
+				}
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				unreferenced, err := app.Dao().DeleteModelVersion(version.UUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(unreferenced) != 0 {
+					t.Fatal("Expected blob to stay referenced by the other artifact")
+				}
+				if count := modelBlobRefCount(t, app, fileHash); count != 1 {
+					t.Fatalf("Expected blob to be referenced once, got %d", count)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + stored hash claimed with other content",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  claimedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelBlob(t, app, "secret")
+			},
+			Body:           claimedBody,
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Hash mismatch: expected sha256 ` + sha256Hex("secret") + `, computed ` + fileHash + `"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := modelBlobRefCount(t, app, sha256Hex("secret")); count != 1 {
+					t.Fatalf("Expected stored blob to stay referenced once, got %d", count)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + same content being stored",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  pendingContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if _, _, err := app.Dao().AcquireBlob(test.ValidAdminUserOrgUuid, "LOCAL", "", fileHash, 4, nil); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           pendingBody,
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"The same content is being stored by another upload, retry later"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := modelBlobRefCount(t, app, fileHash); count != 1 {
+					t.Fatalf("Expected pending blob to stay referenced once, got %d", count)
+				}
+				entries, _ := os.ReadDir(filepath.Join(app.LocalStorageDir(), uploadDir))
+				if len(entries) > 0 {
+					t.Fatalf("Expected upload to be removed, found %d files", len(entries))
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + stored content deduplicated after upload",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  md5ContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelBlob(t, app, "test")
+			},
+			Body:           md5Body,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"hash":"098f6bcd4621d373cade4e832627b4f6"`,
+				`"hash_algorithm":"md5"`,
+				`"path":"` + testBlobKey(fileHash) + `"`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if count := modelBlobRefCount(t, app, fileHash); count != 2 {
+					t.Fatalf("Expected blob to be referenced twice, got %d", count)
+				}
+				entries, _ := os.ReadDir(filepath.Join(app.LocalStorageDir(), uploadDir))
+				if len(entries) > 0 {
+					t.Fatalf("Expected duplicate upload to be removed, found %d files", len(entries))
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// setOrgStorageQuota sets the storage quotas (in bytes) of the admin org.
+func setOrgStorageQuota(t *testing.T, app *test.TestApp, softQuota int64, hardQuota int64) {
+	_, err := app.Dao().UpdateOrg(test.ValidAdminUserOrgUuid, map[string]interface{}{
+		"storage_soft_quota": softQuota,
+		"storage_hard_quota": hardQuota,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func orgStorageUsedBytes(t *testing.T, app *test.TestApp) int64 {
+	usage, err := app.Dao().GetOrgStorageUsage(test.ValidAdminUserOrgUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return usage.UsedBytes
+}
+
+func TestRegisterModelStorageQuota(t *testing.T) {
+	newBody := func() (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(map[string]string{
+			"hash":    sha256Hex("test"),
+			"storage": "LOCAL",
+		}, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	tooLargeBody, tooLargeContentType := newBody()
+	exceededBody, exceededContentType := newBody()
+	softBody, softContentType := newBody()
+	storedBody, storedContentType := newBody()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register model + valid token + file larger than hard quota",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  tooLargeContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setOrgStorageQuota(t, app, 0, 2)
+			},
+			Body:           tooLargeBody,
+			ExpectedStatus: 413,
+			ExpectedContent: []string{
+				`"message":"File exceeds the organization storage quota"`,
+			},
+		},
+		{
+			Name:   "register model + valid token + hard quota exceeded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  exceededContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setOrgStorageQuota(t, app, 0, 10)
+				err := app.Dao().RecordModelStorageUsage(test.ValidAdminUserOrgUuid, validDemoModelUuid, uuid.NullUUID{}, "LOCAL", "", "model-registry/stored", 8)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           exceededBody,
+			ExpectedStatus: 507,
+			ExpectedContent: []string{
+				`"message":"Organization storage quota exceeded"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if used := orgStorageUsedBytes(t, app); used != 8 {
+					t.Fatalf("Expected usage to stay at 8 bytes, got %d", used)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + soft quota exceeded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  softContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setOrgStorageQuota(t, app, 2, 0)
+			},
+			Body:           softBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v2"`,
+				`"message":"Model successfully registered. Organization storage soft quota exceeded"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				usage, err := app.Dao().GetOrgStorageUsage(test.ValidAdminUserOrgUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if usage.UsedBytes != 4 || len(usage.Models) != 1 || usage.Models[0].UUID != validDemoModelUuid || usage.Models[0].UsedBytes != 4 {
+					t.Fatalf("Expected 4 bytes accounted to the model, got %+v", usage)
+				}
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := app.Dao().DeleteModelVersion(version.UUID); err != nil {
+					t.Fatal(err)
+				}
+				if used := orgStorageUsedBytes(t, app); used != 0 {
+					t.Fatalf("Expected usage to be released with the blob, got %d", used)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + stored content not counted against quota",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  storedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelBlob(t, app, "test")
+				setOrgStorageQuota(t, app, 0, 1)
+			},
+			Body:           storedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"message":"Model successfully registered"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// seedModelVersionFile registers a new LOCAL version (v2) on the Demo Model
+// dev branch, optionally storing its file content.
+func seedModelVersionFile(t *testing.T, app *test.TestApp, content string, store bool) {
+	fileKey := fmt.Sprintf("model-registry/%s/models/%s/%s/model.pkl", test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid)
+	if store {
+		fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fs.Close()
+		if err := fs.Upload([]byte(content), fileKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := app.Dao().RegisterModelFile(validDemoModelDevBranchUuid, "LOCAL", "", fileKey, false, sha256Hex(content), "sha256", sha256Hex(content), "", uuid.NullUUID{}, nil, test.ValidAdminUserUuid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadModelBranchVersion(t *testing.T) {
+	content := "first-second-third"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "download model branch version + unauthorized",
+			Method:         http.MethodGet,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/download",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "download model branch version + valid token + version not found",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v9/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`Model Branch Version not found`,
+			},
+		},
+		{
+			Name:   "download model branch version + valid token + empty version",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Model version has no file"`,
+			},
+		},
+		{
+			Name:   "download model branch version + valid token + file missing in storage",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, content, false)
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"File not found in storage"`,
+			},
+		},
+		{
+			Name:   "download model branch version + valid token + full file",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, content, true)
+			},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{content},
+		},
+		{
+			Name:   "download model branch version + valid token + range",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Range":         "bytes=0-4",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, content, true)
+			},
+			ExpectedStatus:     206,
+			ExpectedContent:    []string{"first"},
+			NotExpectedContent: []string{content},
+		},
+		{
+			Name:   "download model branch version + valid token + etag matches",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"If-None-Match": `"` + sha256Hex(content) + `"`,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, content, true)
+			},
+			ExpectedStatus: 304,
+		},
+		{
+			Name:   "download model branch version + valid token + etag changed",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"If-None-Match": `"1234567890"`,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, content, true)
+			},
+			ExpectedStatus:  200,
+			ExpectedContent: []string{content},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// enableOrgEncryption configures the instance master key and encrypts the
+// files stored for the admin org.
+func enableOrgEncryption(t *testing.T, app *test.TestApp) {
+	app.Settings().Encryption.MasterKey = "test-master-key"
+	_, err := app.Dao().UpdateOrg(test.ValidAdminUserOrgUuid, map[string]interface{}{
+		"encryption_enabled": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func downloadModelVersion(e *echo.Echo, version string) (int, string) {
+	url := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/" + version + "/download"
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", test.ValidAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestRegisterModelEncrypted(t *testing.T) {
+	newBody := func() (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(map[string]string{
+			"hash":    sha256Hex("test"),
+			"storage": "LOCAL",
+		}, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	encryptedBody, encryptedContentType := newBody()
+	rotatedBody, rotatedContentType := newBody()
+	storedBody, storedContentType := newBody()
+	firstBody, firstContentType := newBody()
+	dedupedBody, dedupedContentType := newBody()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register model + valid token + encrypted at rest",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  encryptedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				enableOrgEncryption(t, app)
+			},
+			Body:           encryptedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"encrypted":true`,
+				`"message":"Model successfully registered"`,
+			},
+			NotExpectedContent: []string{
+				`"encryption_key"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				stored, err := os.ReadFile(filepath.Join(app.LocalStorageDir(), testBlobKey(sha256Hex("test"))))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bytes.Contains(stored, []byte("test")) {
+					t.Fatal("Expected stored file to be encrypted")
+				}
+				if code, body := downloadModelVersion(e, "v2"); code != 200 || body != "test" {
+					t.Fatalf("Expected decrypted download, got %d %q", code, body)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + encrypted after key rotation",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  rotatedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				enableOrgEncryption(t, app)
+			},
+			Body:           rotatedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"encrypted":true`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				stored, err := os.ReadFile(filepath.Join(app.LocalStorageDir(), testBlobKey(sha256Hex("test"))))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/encryption/rotate", nil)
+				req.Header.Set("Authorization", test.ValidAdminToken)
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"rewrapped_keys":2`) {
+					t.Fatalf("Expected the data keys of the version and its blob to be rewrapped, got %d %s", rec.Code, rec.Body.String())
+				}
+				rotated, err := os.ReadFile(filepath.Join(app.LocalStorageDir(), testBlobKey(sha256Hex("test"))))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(stored, rotated) {
+					t.Fatal("Expected stored file to be kept by the key rotation")
+				}
+				if code, body := downloadModelVersion(e, "v2"); code != 200 || body != "test" {
+					t.Fatalf("Expected decrypted download after rotation, got %d %q", code, body)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + plain blob referenced when encrypted",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  storedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelBlob(t, app, "test")
+				enableOrgEncryption(t, app)
+			},
+			Body:           storedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"encrypted":false`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if code, body := downloadModelVersion(e, "v2"); code != 200 || body != "test" {
+					t.Fatalf("Expected plain download, got %d %q", code, body)
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + encrypted blob referenced by no version",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  dedupedContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				enableOrgEncryption(t, app)
+				req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model/branch/dev/register", firstBody)
+				req.Header.Set("Authorization", test.ValidAdminToken)
+				req.Header.Set("Content-Type", firstContentType)
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				if rec.Code != 200 {
+					t.Fatalf("Expected model to be registered, got %d %s", rec.Code, rec.Body.String())
+				}
+				// the blob outlives the version that stored it, as when its registration fails
+				err := app.Dao().Datastore().DB.Unscoped().Where("hash = ?", sha256Hex("test")).Delete(&modeldbmodels.ModelVersion{}).Error
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           dedupedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"encrypted":true`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if modelBlobRefCount(t, app, sha256Hex("test")) != 2 {
+					t.Fatal("Expected the stored blob to be referenced")
+				}
+				if code, body := downloadModelVersion(e, "v2"); code != 200 || body != "test" {
+					t.Fatalf("Expected decrypted download, got %d %q", code, body)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestRegisterModelReference(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	referenceKey := "external/models/model.pkl"
+	newBody := func(fields map[string]string) (*bytes.Buffer, string) {
+		body, mp, err := test.MockMultipartData(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, mp.FormDataContentType()
+	}
+	seedReference := func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+		if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+			t.Fatal(err)
+		}
+		s3.PutObject(test.TestS3BucketName, referenceKey, []byte("referenced"))
+	}
+	computedBody, computedContentType := newBody(map[string]string{
+		"uri":     "s3://" + test.TestS3BucketName + "/" + referenceKey,
+		"storage": test.TestS3SecretName,
+	})
+	publicURLBody, publicURLContentType := newBody(map[string]string{
+		"uri":     s3.URL + "/" + test.TestS3BucketName + "/" + referenceKey,
+		"hash":    sha256Hex("referenced"),
+		"storage": test.TestS3SecretName,
+	})
+	mismatchBody, mismatchContentType := newBody(map[string]string{
+		"uri":          "s3://" + test.TestS3BucketName + "/" + referenceKey,
+		"hash":         sha256Hex("test"),
+		"compute_hash": "true",
+		"storage":      test.TestS3SecretName,
+	})
+	otherBucketBody, otherBucketContentType := newBody(map[string]string{
+		"uri":     "s3://other-bucket/" + referenceKey,
+		"storage": test.TestS3SecretName,
+	})
+	missingBody, missingContentType := newBody(map[string]string{
+		"uri":     "s3://" + test.TestS3BucketName + "/external/missing.pkl",
+		"storage": test.TestS3SecretName,
+	})
+	managedBody, managedContentType := newBody(map[string]string{
+		"uri":     "s3://" + test.TestS3BucketName + "/" + testBlobKey(sha256Hex("test")),
+		"storage": test.TestS3SecretName,
+	})
+	localBody, localContentType := newBody(map[string]string{
+		"uri":     "file://" + referenceKey,
+		"storage": "LOCAL",
+	})
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "register model + valid token + reference with computed hash",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  computedContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           computedBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v2"`,
+				`"hash":"` + sha256Hex("referenced") + `"`,
+				`"path":"` + s3.URL + "/" + test.TestS3BucketName + "/" + referenceKey + `"`,
+				`"is_referenced":true`,
+				`"size":10`,
+				`"etag":`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if code, body := downloadModelVersion(e, "v2"); code != 200 || body != "referenced" {
+					t.Fatalf("Expected referenced object download, got %d %q", code, body)
+				}
+				migration, err := app.Dao().CreateStorageMigration(test.ValidAdminUserOrgUuid, test.TestS3SecretName, "LOCAL", "S3", s3.URL+"/"+test.TestS3BucketName, []uuid.UUID{validDemoModelUuid}, nil, test.ValidAdminUserUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(migration.Items) != 0 {
+					t.Fatalf("Expected referenced object not to be migrated, got %d items", len(migration.Items))
+				}
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := app.Dao().DeleteModelVersion(version.UUID); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := s3.Object(test.TestS3BucketName, referenceKey); !ok {
+					t.Fatal("Expected referenced object to be kept")
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + reference by public url",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  publicURLContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           publicURLBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"hash":"` + sha256Hex("referenced") + `"`,
+				`"digests":{}`,
+				`"file_name":"model.pkl"`,
+				`"is_referenced":true`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				// the hash was not verified, so it is not served as the etag
+				req := httptest.NewRequest(http.MethodGet, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model/branch/dev/version/v2/download", nil)
+				req.Header.Set("Authorization", test.ValidAdminToken)
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				if rec.Code != 200 || rec.Header().Get("ETag") == `"`+sha256Hex("referenced")+`"` {
+					t.Fatalf("Expected the object etag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+				}
+			},
+		},
+		{
+			Name:   "register model + valid token + reference hash mismatch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  mismatchContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           mismatchBody,
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Hash mismatch: expected sha256 ` + sha256Hex("test") + `, computed ` + sha256Hex("referenced") + `"`,
+			},
+		},
+		{
+			Name:   "register model + valid token + reference outside the storage",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  otherBucketContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           otherBucketBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Reference s3://other-bucket/` + referenceKey + ` does not belong to the s3 storage"`,
+			},
+		},
+		{
+			Name:   "register model + valid token + referenced object not found",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  missingContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           missingBody,
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Referenced object not found"`,
+			},
+		},
+		{
+			Name:   "register model + valid token + reference to a registry object",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  managedContentType,
+			},
+			BeforeTestFunc: seedReference,
+			Body:           managedBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Objects stored by the registry can't be referenced"`,
+			},
+		},
+		{
+			Name:   "register model + valid token + local reference",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  localContentType,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer fs.Close()
+				if err := fs.Upload([]byte("referenced"), referenceKey); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           localBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"path":"` + referenceKey + `"`,
+				`"source_type":"LOCAL"`,
+				`"is_referenced":true`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if code, body := downloadModelVersion(e, "v2"); code != 200 || body != "referenced" {
+					t.Fatalf("Expected referenced object download, got %d %q", code, body)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// seedModelCompare forks an experiment branch from dev/v1 of the Demo Model
+// and registers a v2 with an accuracy log on both branches, updating the
+// readme in between.
+func seedModelCompare(t *testing.T, app *test.TestApp) {
+	v1, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	experiment, err := app.Dao().CreateModelBranchFromVersion(validDemoModelUuid, "experiment", v1.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedModelVersionFile(t, app, "dev", false)
+	devV2, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().CreateLogForModelVersion("accuracy", "0.8", devV2.UUID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().UpdateModelReadme(validDemoModelUuid, "markdown", "Experiment Readme"); err != nil {
+		t.Fatal(err)
+	}
+	experimentV2, err := app.Dao().RegisterModelFile(experiment.UUID, "LOCAL", "", "model.pkl", false, sha256Hex("experiment"), "sha256", sha256Hex("experiment"), "", uuid.NullUUID{}, nil, test.ValidAdminUserUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().CreateLogForModelVersion("accuracy", "0.9", experimentV2.UUID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().CreateLogForModelVersion("loss", "0.1", experimentV2.UUID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompareModelBranches(t *testing.T) {
+	compareUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/compare"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "compare model branches + unauthorized",
+			Method:         http.MethodGet,
+			Url:            compareUrl + "?base=dev&head=main",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "compare model branches + valid token + no head",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Base and head branches are required"`,
+			},
+		},
+		{
+			Name:   "compare model branches + valid token + branch not found",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=noexist",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Branch noexist not found"`,
+			},
+		},
+		{
+			Name:   "compare model branches + valid token + version not found",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=main&base_version=v9",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Version v9 of branch dev not found"`,
+			},
+		},
+		{
+			Name:   "compare model branches + valid token + empty head",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=main",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"branch":"main","version":null}`,
+				`"fork_point":null`,
+				`"ahead_by":0`,
+				`"behind_by":1`,
+				`"only_in_head":[]`,
+				`"files":[]`,
+				`"message":"Model branches compared"`,
+			},
+		},
+		{
+			Name:   "compare model branches + valid token",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=experiment",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelCompare(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"fork_point":{"uuid":"` + validDemoModelUuid.String() + `","version":"v1","hash":"1234567890"`,
+				`"ahead_by":1`,
+				`"behind_by":1`,
+				`"base_moved":true`,
+				`"only_in_base":[{"uuid":`,
+				`"hash":"` + sha256Hex("dev") + `"`,
+				`"only_in_head":[{"uuid":`,
+				`"hash":"` + sha256Hex("experiment") + `"`,
+				`"logs":[{"key":"accuracy","base":"0.8","head":"0.9","changed":true},{"key":"loss","base":null,"head":"0.1","changed":true}]`,
+				`"readme":{"base_version":"v1","base":"Demo Readme","head_version":"v2","head":"Experiment Readme","changed":true}`,
+				`"path":"model.pkl","status":"modified","base_digest":"` + sha256Hex("dev") + `","head_digest":"` + sha256Hex("experiment") + `"`,
+				`"message":"Model branches compared"`,
+			},
+		},
+		{
+			Name:   "compare model branches + valid token + fork point version",
+			Method: http.MethodGet,
+			Url:    compareUrl + "?base=dev&head=experiment&base_version=v1&head_version=v1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelCompare(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"ahead_by":0`,
+				`"behind_by":0`,
+				`"base_moved":false`,
+				`"logs":[]`,
+				`"readme":{"base_version":"v1","base":"Demo Readme","head_version":"v1","head":"Demo Readme","changed":false}`,
+				`"files":[]`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,292 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	modelConfigContent  = `{"layers":2}`
+	modelWeightsContent = "\x00\x01\x02\x03"
+)
+
+type versionFilePart struct {
+	path        string
+	content     string
+	contentType string
+}
+
+// mockVersionFilesData builds the multipart body of a multi-file
+// registration, sending the path of every file in the paths field.
+func mockVersionFilesData(t *testing.T, fields map[string]string, files ...versionFilePart) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	mp := multipart.NewWriter(body)
+	for k, v := range fields {
+		if err := mp.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		if err := mp.WriteField("paths", file.path); err != nil {
+			t.Fatal(err)
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`, file.path))
+		if file.contentType != "" {
+			h.Set("Content-Type", file.contentType)
+		}
+		part, err := mp.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body, mp.FormDataContentType()
+}
+
+// modelManifestDigest is the digest of the manifest of the config and
+// weights files, in path order.
+func modelManifestDigest() string {
+	manifest := fmt.Sprintf(`[{"path":"config.json","size":%d,"digest":"%s","media_type":"application/json"},{"path":"weights/model.bin","size":%d,"digest":"%s","media_type":"application/octet-stream"}]`,
+		len(modelConfigContent), sha256Hex(modelConfigContent), len(modelWeightsContent), sha256Hex(modelWeightsContent))
+	return sha256Hex(manifest)
+}
+
+func modelVersionFiles() []versionFilePart {
+	return []versionFilePart{
+		{path: "weights/model.bin", content: modelWeightsContent},
+		{path: "config.json", content: modelConfigContent, contentType: "application/json"},
+	}
+}
+
+func getModelVersionFile(e *echo.Echo, version string, filePath string) (int, string) {
+	url := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/" + version + "/files/" + filePath
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", test.ValidAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestRegisterModelFiles(t *testing.T) {
+	registerUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/register/files"
+	registeredBody, registeredContentType := mockVersionFilesData(t, map[string]string{"storage": "LOCAL"}, modelVersionFiles()...)
+	hashBody, hashContentType := mockVersionFilesData(t, map[string]string{"storage": "LOCAL", "hash": sha256Hex("test")}, modelVersionFiles()...)
+	invalidBody, invalidContentType := mockVersionFilesData(t, map[string]string{"storage": "LOCAL"}, versionFilePart{path: "../model.bin", content: modelWeightsContent})
+	duplicateBody, duplicateContentType := mockVersionFilesData(t, map[string]string{"storage": "LOCAL"},
+		versionFilePart{path: "model.bin", content: modelWeightsContent},
+		versionFilePart{path: "./model.bin", content: modelConfigContent},
+	)
+	emptyBody, emptyContentType := mockVersionFilesData(t, map[string]string{"storage": "LOCAL"})
+	identicalBody, identicalContentType := mockVersionFilesData(t, map[string]string{"storage": "LOCAL"},
+		versionFilePart{path: "model.bin", content: modelWeightsContent},
+		versionFilePart{path: "copy/model.bin", content: modelWeightsContent},
+	)
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "register model files + unauthorized",
+			Method:         http.MethodPost,
+			Url:            registerUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "register model files + valid token + no files",
+			Method: http.MethodPost,
+			Url:    registerUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  emptyContentType,
+			},
+			Body:           emptyBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Files are required"`,
+			},
+		},
+		{
+			Name:   "register model files + valid token + path outside the version",
+			Method: http.MethodPost,
+			Url:    registerUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  invalidContentType,
+			},
+			Body:           invalidBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid file path ../model.bin"`,
+			},
+		},
+		{
+			Name:   "register model files + valid token + duplicate path",
+			Method: http.MethodPost,
+			Url:    registerUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  duplicateContentType,
+			},
+			Body:           duplicateBody,
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Duplicate file path model.bin"`,
+			},
+		},
+		{
+			Name:   "register model files + valid token + hash mismatch",
+			Method: http.MethodPost,
+			Url:    registerUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  hashContentType,
+			},
+			Body:           hashBody,
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Hash mismatch: expected sha256 ` + sha256Hex("test") + `, computed ` + modelManifestDigest() + `"`,
+			},
+		},
+		{
+			Name:   "register model files + valid token + registered",
+			Method: http.MethodPost,
+			Url:    registerUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  registeredContentType,
+			},
+			Body:           registeredBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"hash":"` + modelManifestDigest() + `"`,
+				`"version":"v2"`,
+				`"is_manifest":true`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if modelBlobRefCount(t, app, sha256Hex(modelConfigContent)) != 1 || modelBlobRefCount(t, app, sha256Hex(modelWeightsContent)) != 1 {
+					t.Fatal("Expected every file to be stored as a blob")
+				}
+				if code, body := getModelVersionFile(e, "v2", "weights/model.bin"); code != 200 || body != modelWeightsContent {
+					t.Fatalf("Expected weights download, got %d %q", code, body)
+				}
+				if code, body := getModelVersionFile(e, "v2", "config.json"); code != 200 || body != modelConfigContent {
+					t.Fatalf("Expected config download, got %d %q", code, body)
+				}
+				if code, _ := getModelVersionFile(e, "v2", "tokenizer.json"); code != 404 {
+					t.Fatalf("Expected missing file to be not found, got %d", code)
+				}
+				if code, body := downloadModelVersion(e, "v2"); code != 400 || !bytes.Contains([]byte(body), []byte("download them individually")) {
+					t.Fatalf("Expected whole version download to be rejected, got %d %q", code, body)
+				}
+			},
+		},
+		{
+			Name:   "register model files + valid token + identical files",
+			Method: http.MethodPost,
+			Url:    registerUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  identicalContentType,
+			},
+			Body:           identicalBody,
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"is_manifest":true`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if modelBlobRefCount(t, app, sha256Hex(modelWeightsContent)) != 2 {
+					t.Fatal("Expected the identical files to share a blob")
+				}
+				for _, filePath := range []string{"model.bin", "copy/model.bin"} {
+					if code, body := getModelVersionFile(e, "v2", filePath); code != 200 || body != modelWeightsContent {
+						t.Fatalf("Expected %s download, got %d %q", filePath, code, body)
+					}
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestGetModelBranchVersionFiles(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "get model branch version files + unauthorized",
+			Method:         http.MethodGet,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/files",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "get model branch version files + valid token + manifest version",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/files",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body, contentType := mockVersionFilesData(t, map[string]string{"storage": "LOCAL"}, modelVersionFiles()...)
+				req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model/branch/dev/register/files", body)
+				req.Header.Set("Authorization", test.ValidAdminToken)
+				req.Header.Set("Content-Type", contentType)
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				if rec.Code != 200 {
+					t.Fatalf("Expected files to be registered, got %d %s", rec.Code, rec.Body.String())
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"path":"config.json","size":12,"digest":"` + sha256Hex(modelConfigContent) + `","media_type":"application/json"},{"path":"weights/model.bin","size":4,"digest":"` + sha256Hex(modelWeightsContent) + `","media_type":"application/octet-stream"}]`,
+				`"message":"Model branch version files"`,
+			},
+			NotExpectedContent: []string{
+				`model-registry`,
+				`blobs/`,
+			},
+		},
+		{
+			Name:   "get model branch version files + valid token + single file version",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/files",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, "test", true)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"path":"model.pkl"`,
+				`"digest":"` + sha256Hex("test") + `"`,
+				`"message":"Model branch version files"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,321 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+var validModelImportJobUuid = uuid.Must(uuid.FromString("55555555-5555-5555-5555-555555555555"))
+
+// waitForModelImport polls the status of the import of the Demo Model dev
+// branch until it is completed or failed, and returns its details.
+func waitForModelImport(t *testing.T, app *test.TestApp, e *echo.Echo) string {
+	var job dbmodels.ImportJob
+	if err := app.Dao().Datastore().DB.Where("model_branch_uuid = ?", validDemoModelDevBranchUuid).First(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req := httptest.NewRequest(http.MethodGet, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model/branch/dev/import/"+job.UUID.String(), nil)
+		req.Header.Set("Authorization", test.ValidAdminToken)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != 200 {
+			t.Fatalf("Expected import details, got %d %s", rec.Code, rec.Body.String())
+		}
+		body := rec.Body.String()
+		if strings.Contains(body, `"status":"completed"`) || strings.Contains(body, `"status":"failed"`) {
+			return body
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Import didn't finish: %s", body)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestImportModel(t *testing.T) {
+	artifacts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/artifacts/model.pkl":
+			w.Write([]byte("imported"))
+		case "/artifacts/streamed.pkl":
+			// flushed in parts, so that the size is unknown upfront
+			w.Write([]byte("import"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("ed"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer artifacts.Close()
+	artifactURL := artifacts.URL + "/artifacts/model.pkl"
+	artifactHost, _ := url.Parse(artifacts.URL)
+	importUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/import"
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "import model + unauthorized",
+			Method:         http.MethodPost,
+			Url:            importUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "import model + valid token + main branch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/main/import",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","hash":"` + sha256Hex("imported") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Cannot register model directly to main branch"`,
+			},
+		},
+		{
+			Name:   "import model + valid token + invalid url",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"url":"file:///etc/passwd","hash":"` + sha256Hex("imported") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"only http and https urls can be imported"`,
+			},
+		},
+		{
+			Name:   "import model + valid token + host not allowed",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				app.Settings().Import.AllowedHosts = []string{"artifacts.example.com"}
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","hash":"` + sha256Hex("imported") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"importing from this host is not allowed"`,
+			},
+		},
+		{
+			Name:   "import model + valid token + no hash",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Hash is required"`,
+			},
+		},
+		{
+			Name:   "import model + valid token + hash mismatch",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","hash":"` + sha256Hex("test") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 202,
+			ExpectedContent: []string{
+				`"status":"pending"`,
+				`"message":"Model import started"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body := waitForModelImport(t, app, e)
+				if !strings.Contains(body, `"status":"failed"`) || !strings.Contains(body, "Hash mismatch: expected sha256 "+sha256Hex("test")+", computed "+sha256Hex("imported")) {
+					t.Fatalf("Expected import to fail on hash mismatch, got %s", body)
+				}
+				if modelBlobRefCount(t, app, sha256Hex("imported")) != 0 {
+					t.Fatal("Expected the mismatching artifact not to be stored")
+				}
+			},
+		},
+		{
+			Name:   "import model + valid token + missing artifact",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"url":"` + artifacts.URL + `/artifacts/missing.pkl","hash":"` + sha256Hex("imported") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 202,
+			ExpectedContent: []string{
+				`"message":"Model import started"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body := waitForModelImport(t, app, e)
+				if !strings.Contains(body, `"status":"failed"`) || !strings.Contains(body, "unexpected status 404") {
+					t.Fatalf("Expected import to fail on missing artifact, got %s", body)
+				}
+			},
+		},
+		{
+			Name:   "import model + valid token + storage quota exceeded",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setOrgStorageQuota(t, app, 0, 4)
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","hash":"` + sha256Hex("imported") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 202,
+			ExpectedContent: []string{
+				`"message":"Model import started"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body := waitForModelImport(t, app, e)
+				if !strings.Contains(body, `"status":"failed"`) || !strings.Contains(body, "Organization storage quota exceeded") {
+					t.Fatalf("Expected import to fail on the storage quota, got %s", body)
+				}
+				if modelBlobRefCount(t, app, sha256Hex("imported")) != 0 {
+					t.Fatal("Expected the artifact not to be stored")
+				}
+			},
+		},
+		{
+			Name:   "import model + valid token + streamed artifact exceeds storage quota",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setOrgStorageQuota(t, app, 0, 4)
+			},
+			Body:           strings.NewReader(`{"url":"` + artifacts.URL + `/artifacts/streamed.pkl","hash":"` + sha256Hex("imported") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 202,
+			ExpectedContent: []string{
+				`"message":"Model import started"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body := waitForModelImport(t, app, e)
+				if !strings.Contains(body, `"status":"failed"`) || !strings.Contains(body, "Organization storage quota exceeded") {
+					t.Fatalf("Expected import to fail on the storage quota, got %s", body)
+				}
+				if modelBlobRefCount(t, app, sha256Hex("imported")) != 0 {
+					t.Fatal("Expected the artifact not to be stored")
+				}
+			},
+		},
+		{
+			Name:   "import model + valid token + imported",
+			Method: http.MethodPost,
+			Url:    importUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				app.Settings().Import.AllowedHosts = []string{artifactHost.Hostname()}
+			},
+			Body:           strings.NewReader(`{"url":"` + artifactURL + `","hash":"` + sha256Hex("imported") + `","storage":"LOCAL"}`),
+			ExpectedStatus: 202,
+			ExpectedContent: []string{
+				`"url":"` + artifactURL + `"`,
+				`"status":"pending"`,
+				`"message":"Model import started"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				body := waitForModelImport(t, app, e)
+				if !strings.Contains(body, `"status":"completed"`) || !strings.Contains(body, `"version":"v2"`) || !strings.Contains(body, `"size":8`) {
+					t.Fatalf("Expected import to complete, got %s", body)
+				}
+				if modelBlobRefCount(t, app, sha256Hex("imported")) != 1 {
+					t.Fatal("Expected the artifact to be stored as a blob")
+				}
+				if code, body := downloadModelVersion(e, "v2"); code != 200 || body != "imported" {
+					t.Fatalf("Expected imported model download, got %d %q", code, body)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestGetModelImport(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "get model import + valid token + invalid id",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/import/invalid",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid import id"`,
+			},
+		},
+		{
+			Name:   "get model import + valid token + not found",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/import/" + validModelUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Import not found"`,
+			},
+		},
+		{
+			Name:   "get model import + valid token + interrupted",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/import/" + validModelImportJobUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				job := dbmodels.ImportJob{
+					OrganizationUUID: test.ValidAdminUserOrgUuid,
+					ModelBranchUUID:  uuid.NullUUID{UUID: validDemoModelDevBranchUuid, Valid: true},
+					URL:              "https://artifacts.example.com/model.pkl",
+					Hash:             sha256Hex("imported"),
+					Storage:          "LOCAL",
+					Status:           "running",
+					CreatedBy:        test.ValidAdminUserUuid,
+				}
+				job.UUID = validModelImportJobUuid
+				if err := app.Dao().Datastore().DB.Create(&job).Error; err != nil {
+					t.Fatal(err)
+				}
+				if err := app.Dao().FailInterruptedImportJobs(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":"failed"`,
+				`"error":"Import interrupted by a server restart"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
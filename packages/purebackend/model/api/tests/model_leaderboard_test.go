@@ -0,0 +1,291 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+var validDemoPrivateModelUuid = uuid.Must(uuid.FromString("22222222-2222-2222-2222-222222222222"))
+
+// registerModelVersion registers a new version of the branch with the auc
+// logged for it.
+func registerModelVersion(t *testing.T, app *test.TestApp, branchUUID uuid.UUID, content string, auc string) uuid.UUID {
+	version, err := app.Dao().RegisterModelFile(branchUUID, "LOCAL", "", "model.pkl", false, sha256Hex(content), "sha256", sha256Hex(content), "", uuid.NullUUID{}, nil, test.ValidAdminUserUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().CreateLogForModelVersion("auc", auc, version.UUID); err != nil {
+		t.Fatal(err)
+	}
+	return version.UUID
+}
+
+// seedModelLeaderboard logs an auc for dev/v1 (0.8), dev/v2 (0.9, staging)
+// and main/v1 (0.85, champion) of the Demo Model, and for main/v1 (0.95)
+// and main/v2 (not a number) of the Demo Private Model.
+func seedModelLeaderboard(t *testing.T, app *test.TestApp) {
+	if _, err := app.Dao().CreateLogForModelVersion("auc", "0.8", validDemoModelUuid); err != nil {
+		t.Fatal(err)
+	}
+	devV2 := registerModelVersion(t, app, validDemoModelDevBranchUuid, "dev", "0.9")
+	if _, err := app.Dao().CreateLogForModelVersion("loss", "0.1", devV2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().TransitionModelVersionStage(validDemoModelUuid, devV2, "staging", "", test.ValidAdminUserUuid); err != nil {
+		t.Fatal(err)
+	}
+	mainV1 := registerModelVersion(t, app, demoModelMainBranchUuid(t, app), "main", "0.85")
+	if _, err := app.Dao().SetModelAlias(validDemoModelUuid, "champion", mainV1, test.ValidAdminUserUuid); err != nil {
+		t.Fatal(err)
+	}
+	branches, err := app.Dao().CreateModelBranches(test.ValidAdminUserOrgUuid, validDemoPrivateModelUuid, []string{"main"}, test.ValidAdminUserUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registerModelVersion(t, app, branches[0].UUID, "private", "0.95")
+	registerModelVersion(t, app, branches[0].UUID, "private-nan", "n/a")
+}
+
+func TestGetModelLeaderboard(t *testing.T) {
+	leaderboardUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/leaderboard"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "get model leaderboard + unauthorized",
+			Method:         http.MethodGet,
+			Url:            leaderboardUrl + "?key=auc",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + no key",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Metric key is required"`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + invalid direction",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&direction=up",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid direction up, expected asc or desc"`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + invalid stage",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&stage=live",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid stage live"`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + invalid date",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&from=yesterday",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid from date yesterday, expected RFC3339"`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + invalid limit",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&limit=0",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Limit must be a positive integer"`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&metrics=loss",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelLeaderboard(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`{"rank":1,"model":{"uuid":"` + validDemoModelUuid.String() + `","name":"Demo Model"},"branch":{"uuid":"` + validDemoModelDevBranchUuid.String() + `","name":"dev"},"version":{"uuid":`,
+				`"version":"v2"},"hash":"` + sha256Hex("dev") + `","stage":"staging","aliases":[],`,
+				`"value":0.9,"metrics":{"loss":"0.1"}}`,
+				`{"rank":2,"model":{"uuid":"` + validDemoModelUuid.String() + `","name":"Demo Model"},"branch":{"uuid":"` + validDemoModelUuid.String() + `","name":"main"}`,
+				`"aliases":["champion"]`,
+				`"value":0.85}`,
+				`{"rank":3,"model":{"uuid":"` + validDemoModelUuid.String() + `","name":"Demo Model"},"branch":{"uuid":"` + validDemoModelDevBranchUuid.String() + `","name":"dev"},"version":{"uuid":"` + validDemoModelUuid.String() + `","version":"v1"}`,
+				`"value":0.8}`,
+				`"message":"Model leaderboard"`,
+			},
+			NotExpectedContent: []string{
+				`Demo Private Model`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + ascending + limit",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&direction=asc&limit=1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelLeaderboard(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"rank":1,`,
+				`"value":0.8}]`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + branch",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&branch=main",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelLeaderboard(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"rank":1,`,
+				`"value":0.85}]`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + stage",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&stage=staging",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelLeaderboard(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"rank":1,`,
+				`"value":0.9}]`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + alias",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&alias=champion",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelLeaderboard(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"rank":1,`,
+				`"aliases":["champion"]`,
+				`"value":0.85}]`,
+			},
+		},
+		{
+			Name:   "get model leaderboard + valid token + date range",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc&to=2024-01-01T00:00:00Z",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelLeaderboard(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"rank":1,`,
+				`"version":{"uuid":"` + validDemoModelUuid.String() + `","version":"v1"}`,
+				`"value":0.8}]`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestGetOrgModelLeaderboard(t *testing.T) {
+	leaderboardUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/leaderboard"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "get org model leaderboard + unauthorized",
+			Method:         http.MethodGet,
+			Url:            leaderboardUrl + "?key=auc",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "get org model leaderboard + valid token + no logs",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[]`,
+			},
+		},
+		{
+			Name:   "get org model leaderboard + valid token",
+			Method: http.MethodGet,
+			Url:    leaderboardUrl + "?key=auc",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelLeaderboard(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`{"rank":1,"model":{"uuid":"` + validDemoPrivateModelUuid.String() + `","name":"Demo Private Model"}`,
+				`"value":0.95}`,
+				`{"rank":2,"model":{"uuid":"` + validDemoModelUuid.String() + `","name":"Demo Model"}`,
+				`{"rank":4,"model":{"uuid":"` + validDemoModelUuid.String() + `","name":"Demo Model"}`,
+				`"value":0.8}]`,
+			},
+			NotExpectedContent: []string{
+				sha256Hex("private-nan"),
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -393,6 +393,125 @@ func TestLogModel(t *testing.T) {
 	}
 }
 
+func TestLogBatchModel(t *testing.T) {
+	batchUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/log/batch"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "log batch model + unauthorized",
+			Method:         http.MethodPost,
+			Url:            batchUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "log batch model + valid token + invalid body",
+			Method: http.MethodPost,
+			Url:    batchUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"logs":{"key":"accuracy"}}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid request body"`,
+			},
+		},
+		{
+			Name:   "log batch model + valid token + no logs",
+			Method: http.MethodPost,
+			Url:    batchUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"logs":[]}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Logs are required"`,
+			},
+		},
+		{
+			Name:   "log batch model + valid token + too large",
+			Method: http.MethodPost,
+			Url:    batchUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				app.Settings().Logs.MaxBatchSize = 16
+			},
+			Body:           strings.NewReader(`{"logs":[{"key":"accuracy","data":"0.9"}]}`),
+			ExpectedStatus: 413,
+			ExpectedContent: []string{
+				`"message":"Log batch exceeds the maximum size of 16 bytes"`,
+			},
+		},
+		{
+			Name:   "log batch model + valid token + invalid entry",
+			Method: http.MethodPost,
+			Url:    batchUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"logs":[{"key":"accuracy","data":"0.9"},{"data":"0.3"}]}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`{"index":0,"key":"accuracy","status":""}`,
+				`{"index":1,"key":"","status":"invalid","error":"Key is required"}`,
+				`"message":"Invalid log entries"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				logs, err := app.Dao().GetKeyLogForModelVersion(validDemoModelUuid, "accuracy")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(logs) != 0 {
+					t.Fatalf("Expected no logs to be created, got %+v", logs)
+				}
+			},
+		},
+		{
+			Name:   "log batch model + valid token",
+			Method: http.MethodPost,
+			Url:    batchUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if _, err := app.Dao().CreateLogForModelVersion("accuracy", "0.5", validDemoModelUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"logs":[{"key":"accuracy","data":"0.9","type":"float"},{"key":"loss","data":"0.3"},{"key":"loss","data":"0.2"}]}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`{"index":0,"key":"accuracy","status":"updated","log":{"key":"accuracy","data":"0.9"`,
+				`{"index":1,"key":"loss","status":"created","log":{"key":"loss","data":"0.3"`,
+				`{"index":2,"key":"loss","status":"updated","log":{"key":"loss","data":"0.2"`,
+				`"message":"Logs created"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				logs, err := app.Dao().GetLogForModelVersion(validDemoModelUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data := map[string]string{}
+				for _, log := range logs {
+					data[log.Key] = log.Data + ":" + log.Type
+				}
+				if len(logs) != 2 || data["accuracy"] != "0.9:float" || data["loss"] != "0.2:" {
+					t.Fatalf("Expected the last entry of each key to be logged, got %+v", logs)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
 func TestLogFileModel(t *testing.T) {
 	emptyFileMultipartBody, emptyFileMultipartContentType, err := test.MockMultipartData(map[string]string{
 		"storage": "local",
@@ -584,7 +703,7 @@ func TestLogFileModel(t *testing.T) {
 				`"status":200`,
 				`"data":[{`,
 				`"key":"tmpfile_`,
-				`"data":"file:///model-registry/` + test.ValidAdminUserUuid.String() + `/models/` + test.ValidAdminUserUuid.String() + `/` + test.ValidUserUuid.String() + `/logs/tmpfile_`,
+				`"data":"/model-registry/` + test.ValidAdminUserOrgUuid.String() + `/models/` + validDemoModelUuid.String() + `/` + validDemoModelDevBranchUuid.String() + `/logs/tmpfile_`,
 				`"message":"Logs created"`,
 			},
 		},
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+// seedModelMetrics logs an accuracy series, out of step order, and a loss
+// for dev/v1 of the Demo Model.
+func seedModelMetrics(t *testing.T, app *test.TestApp) {
+	v1, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := func(v float64) *float64 { return &v }
+	step := func(s int64) *int64 { return &s }
+	_, err = app.Dao().CreateModelVersionMetrics(v1.UUID, []commonmodels.MetricRequest{
+		{Key: "accuracy", Value: value(0.5), Step: step(1)},
+		{Key: "accuracy", Value: value(1), Step: step(3)},
+		{Key: "accuracy", Value: value(0.75), Step: step(2)},
+		{Key: "loss", Value: value(0.25)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogModelMetrics(t *testing.T) {
+	metricUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/metric"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "log model metrics + unauthorized",
+			Method:         http.MethodPost,
+			Url:            metricUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "log model metrics + valid token + invalid body",
+			Method: http.MethodPost,
+			Url:    metricUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"metrics":"accuracy"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid request body"`,
+			},
+		},
+		{
+			Name:   "log model metrics + valid token + no metrics",
+			Method: http.MethodPost,
+			Url:    metricUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"metrics":[]}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Metrics are required"`,
+			},
+		},
+		{
+			Name:   "log model metrics + valid token + no value",
+			Method: http.MethodPost,
+			Url:    metricUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"metrics":[{"key":"accuracy","value":0.5},{"key":"loss"}]}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Value of metric loss must be a number"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				v1, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				metrics, err := app.Dao().GetModelVersionMetrics(v1.UUID, "", false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(metrics) != 0 {
+					t.Fatalf("Expected no metrics to be logged, got %+v", metrics)
+				}
+			},
+		},
+		{
+			Name:   "log model metrics + valid token",
+			Method: http.MethodPost,
+			Url:    metricUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"metrics":[{"key":"accuracy","value":0.5,"step":1},{"key":"accuracy","value":1,"step":2,"timestamp":"2023-04-03T10:00:00Z"}]}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`{"key":"accuracy","count":2,"last":1,"min":0.5,"max":1,"mean":0.75}`,
+				`"message":"Metrics logged"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestGetModelMetrics(t *testing.T) {
+	metricUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/metric"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "get model metrics + unauthorized",
+			Method:         http.MethodGet,
+			Url:            metricUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "get model metrics + valid token + no metrics",
+			Method: http.MethodGet,
+			Url:    metricUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[]`,
+				`"message":"Metrics for model version"`,
+			},
+		},
+		{
+			Name:   "get model metrics + valid token",
+			Method: http.MethodGet,
+			Url:    metricUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelMetrics(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`{"key":"accuracy","points":[{"value":0.5,"step":1,`,
+				`{"value":0.75,"step":2,`,
+				`{"value":1,"step":3,`,
+				`"count":3,"last":1,"min":0.5,"max":1,"mean":0.75}`,
+				`{"key":"loss","points":[{"value":0.25,"step":null,`,
+				`"count":1,"last":0.25,"min":0.25,"max":0.25,"mean":0.25}`,
+			},
+		},
+		{
+			Name:   "get model metrics + valid token + summary",
+			Method: http.MethodGet,
+			Url:    metricUrl + "?summary=true",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelMetrics(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`[{"key":"accuracy","count":3,"last":1,"min":0.5,"max":1,"mean":0.75},{"key":"loss","count":1,"last":0.25,"min":0.25,"max":0.25,"mean":0.25}]`,
+			},
+			NotExpectedContent: []string{
+				`"points"`,
+			},
+		},
+		{
+			Name:   "get model metric + valid token + not found",
+			Method: http.MethodGet,
+			Url:    metricUrl + "/f1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Metric f1 not found"`,
+			},
+		},
+		{
+			Name:   "get model metric + valid token",
+			Method: http.MethodGet,
+			Url:    metricUrl + "/accuracy?summary=true",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelMetrics(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"key":"accuracy","count":3,"last":1,"min":0.5,"max":1,"mean":0.75}]`,
+				`"message":"Metric for model version"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,489 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	commondbmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/dbmodels"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// seedModelPresignedUpload creates an upload session on the Demo Model
+// dev branch for the provided storage and, if content is not empty,
+// stores it as the uploaded file.
+func seedModelPresignedUpload(t *testing.T, app *test.TestApp, s3 *test.S3Server, storage string, content string) {
+	session := dbmodels.UploadSession{
+		BaseModel: commondbmodels.BaseModel{
+			UUID: validModelUploadSessionUuid,
+		},
+		OrganizationUUID: test.ValidAdminUserOrgUuid,
+		ModelBranchUUID: uuid.NullUUID{
+			UUID:  validDemoModelDevBranchUuid,
+			Valid: true,
+		},
+		FileName:  "model.pkl",
+		Storage:   storage,
+		CreatedBy: test.ValidAdminUserUuid,
+	}
+	if err := app.Dao().Datastore().DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if storage == test.TestS3SecretName {
+		if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if content == "" {
+		return
+	}
+	key := fmt.Sprintf("model-registry/%s/models/%s/%s/%s_model.pkl", test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+	if storage == test.TestS3SecretName {
+		s3.PutObject(test.TestS3BucketName, key, []byte(content))
+		return
+	}
+	fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err := fs.Upload([]byte(content), key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateModelPresignedUpload(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "create model presigned upload + unauthorized",
+			Method:         http.MethodPost,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "create model presigned upload + valid token + file name empty",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"File name is required"`,
+			},
+		},
+		{
+			Name:   "create model presigned upload + valid token + storage not connected",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"file_name":"model.pkl","storage":"` + test.TestS3SecretName + `"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Source ` + test.TestS3SecretName + ` not connected properly to organization"`,
+			},
+		},
+		{
+			Name:   "create model presigned upload + valid token + local storage proxied",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"file_name":"model.pkl","storage":"LOCAL"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"method":"PUT"`,
+				`"url":"/api/org/` + test.ValidAdminUserOrgUuid.String() + `/model/Demo%20Model/branch/dev/presigned-upload/`,
+				`"proxied":true`,
+				`"file_name":"model.pkl"`,
+				`"message":"Model presigned upload url created"`,
+			},
+		},
+		{
+			Name:   "create model presigned upload + valid token + s3 storage presigned",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"file_name":"model.pkl","storage":"` + test.TestS3SecretName + `"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"method":"PUT"`,
+				`"url":"` + s3.URL + `/` + test.TestS3BucketName + `/model-registry/` + test.ValidAdminUserOrgUuid.String(),
+				`X-Amz-Signature=`,
+				`"proxied":false`,
+				`"message":"Model presigned upload url created"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				// the presigned url can be used to upload the file directly
+				sourceSecrets, err := app.Dao().GetSecretByName(test.ValidAdminUserOrgUuid, test.TestS3SecretName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fs, err := app.NewFilesystem(sourceSecrets)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer fs.Close()
+				signedURL, err := fs.SignedURL("direct/model.pkl", http.MethodPut, time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req, err := http.NewRequest(http.MethodPut, signedURL, bytes.NewReader([]byte("direct")))
+				if err != nil {
+					t.Fatal(err)
+				}
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if content, ok := s3.Object(test.TestS3BucketName, "direct/model.pkl"); !ok || string(content) != "direct" {
+					t.Fatalf("Expected the file to be uploaded through the presigned url, got %q", string(content))
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestUploadModelPresignedFile(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "upload model presigned file + valid token + session not found",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader("first-second-third"),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Upload session not found"`,
+			},
+		},
+		{
+			Name:   "upload model presigned file + valid token + file empty",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, nil, "LOCAL", "")
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"File is empty"`,
+			},
+		},
+		{
+			Name:   "upload model presigned file + valid token + file too large",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  "application/octet-stream",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, nil, "LOCAL", "")
+				app.Settings().Upload.MaxFileSize = 4
+			},
+			Body:           strings.NewReader("first-second-third"),
+			ExpectedStatus: 413,
+			ExpectedContent: []string{
+				`"message":"File exceeds the maximum size of 4 bytes"`,
+			},
+		},
+		{
+			Name:   "upload model presigned file + valid token + file uploaded",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  "application/octet-stream",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, nil, "LOCAL", "")
+			},
+			Body:           strings.NewReader("first-second-third"),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"message":"Model file uploaded"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				filePath := fmt.Sprintf("%s/storage/model-registry/%s/models/%s/%s/%s_model.pkl", app.DataDir(), test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				content, err := os.ReadFile(filePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != "first-second-third" {
+					t.Fatalf("Expected uploaded file content %q, got %q", "first-second-third", string(content))
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestConfirmModelPresignedUpload(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "confirm model presigned upload + valid token + hash empty",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, s3, "LOCAL", "first-second-third")
+			},
+			Body:           strings.NewReader(`{}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Hash is required"`,
+			},
+		},
+		{
+			Name:   "confirm model presigned upload + valid token + file not uploaded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, s3, test.TestS3SecretName, "")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validModelUploadHash + `"}`),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Uploaded file not found"`,
+			},
+		},
+		{
+			Name:   "confirm model presigned upload + valid token + size mismatch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, s3, test.TestS3SecretName, "first-second-third")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validModelUploadHash + `","size":10}`),
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Size mismatch: expected 10 bytes, uploaded 18 bytes"`,
+			},
+		},
+		{
+			Name:   "confirm model presigned upload + valid token + hash mismatch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, s3, test.TestS3SecretName, "first-second-third")
+			},
+			Body:           strings.NewReader(`{"hash":"uploadhash","size":18}`),
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Hash mismatch: expected sha256 uploadhash, computed ` + validModelUploadHash + `"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				key := fmt.Sprintf("model-registry/%s/models/%s/%s/%s_model.pkl", test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if _, ok := s3.Object(test.TestS3BucketName, key); ok {
+					t.Fatal("Expected mismatched file to be deleted")
+				}
+			},
+		},
+		{
+			Name:   "confirm model presigned upload + valid token + storage quota exceeded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, s3, "LOCAL", "first-second-third")
+				setOrgStorageQuota(t, app, 0, 20)
+				if err := app.Dao().RecordModelStorageUsage(test.ValidAdminUserOrgUuid, validDemoModelUuid, uuid.NullUUID{}, "LOCAL", "", "model-registry/stored", 10); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"hash":"` + validModelUploadHash + `","size":18}`),
+			ExpectedStatus: 507,
+			ExpectedContent: []string{
+				`"message":"Organization storage quota exceeded"`,
+			},
+		},
+		{
+			Name:   "confirm model presigned upload + valid token + local storage registered",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, s3, "LOCAL", "first-second-third")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validModelUploadHash + `","size":18}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"hash":"` + validModelUploadHash + `"`,
+				`"version":"v2"`,
+				`"source_type":"LOCAL"`,
+				`"message":"Model successfully registered"`,
+			},
+		},
+		{
+			Name:   "confirm model presigned upload + valid token + s3 storage registered",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/presigned-upload/" + validModelUploadSessionUuid.String() + "/confirm",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelPresignedUpload(t, app, s3, test.TestS3SecretName, "first-second-third")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validModelUploadHash + `","size":18}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"hash":"` + validModelUploadHash + `"`,
+				`"version":"v2"`,
+				`"path":"` + s3.URL + `/` + test.TestS3BucketName + `/` + testBlobKey(validModelUploadHash) + `"`,
+				`"source_type":"S3"`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetModelUploadSession(validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !session.IsComplete {
+					t.Fatal("Expected upload session to be complete")
+				}
+				key := fmt.Sprintf("model-registry/%s/models/%s/%s/%s_model.pkl", test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if _, ok := s3.Object(test.TestS3BucketName, key); ok {
+					t.Fatal("Expected uploaded file to be moved to its blob")
+				}
+				if _, ok := s3.Object(test.TestS3BucketName, testBlobKey(validModelUploadHash)); !ok {
+					t.Fatal("Expected blob to be stored")
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestGetModelPresignedDownload(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "get model presigned download + valid token + empty version",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/presigned-download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Model version has no file"`,
+			},
+		},
+		{
+			Name:   "get model presigned download + valid token + local storage proxied",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/presigned-download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, "first-second-third", true)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"method":"GET"`,
+				`"url":"/api/org/` + test.ValidAdminUserOrgUuid.String() + `/model/Demo%20Model/branch/dev/version/v2/download"`,
+				`"proxied":true`,
+				`"message":"Model presigned download url created"`,
+			},
+		},
+		{
+			Name:   "get model presigned download + valid token + s3 storage presigned",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/presigned-download",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+				key := fmt.Sprintf("model-registry/%s/models/%s/%s/model.pkl", test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid)
+				s3.PutObject(test.TestS3BucketName, key, []byte("first-second-third"))
+				if _, err := app.Dao().RegisterModelFile(validDemoModelDevBranchUuid, "S3", s3.URL+"/"+test.TestS3BucketName, key, false, validModelUploadHash, "sha256", validModelUploadHash, "", uuid.NullUUID{}, nil, test.ValidAdminUserUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"method":"GET"`,
+				`"url":"` + s3.URL + `/` + test.TestS3BucketName + `/model-registry/` + test.ValidAdminUserOrgUuid.String(),
+				`X-Amz-Signature=`,
+				`"proxied":false`,
+				`"message":"Model presigned download url created"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,342 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// concurrentPromotions is the number of Demo Model versions moved to
+// production at once.
+const concurrentPromotions = 10
+
+// setModelStage moves the version of the Demo Model dev branch to the stage.
+func setModelStage(t *testing.T, e *echo.Echo, version string, stage string) {
+	req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model/branch/dev/version/"+version+"/stage", strings.NewReader(`{"stage":"`+stage+`"}`))
+	req.Header.Set("Authorization", test.ValidAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Expected version stage to be updated, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// requireModelProductionReview makes the Demo Model require an accepted
+// review before a version is moved to production.
+func requireModelProductionReview(t *testing.T, app *test.TestApp) {
+	if err := app.Dao().SetModelRequireProductionReview(validDemoModelUuid, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransitionModelVersionStage(t *testing.T) {
+	stageUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/stage"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "transition model version stage + unauthorized",
+			Method:         http.MethodPost,
+			Url:            stageUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "transition model version stage + valid token + invalid stage",
+			Method: http.MethodPost,
+			Url:    stageUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"stage":"live"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid stage live"`,
+			},
+		},
+		{
+			Name:   "transition model version stage + valid token + skipped stage",
+			Method: http.MethodPost,
+			Url:    stageUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"stage":"production"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Cannot move a version from stage none to production"`,
+			},
+		},
+		{
+			Name:   "transition model version stage + valid token + staging",
+			Method: http.MethodPost,
+			Url:    stageUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"stage":"staging","reason":"Ready for evaluation"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"from_stage":"none"`,
+				`"to_stage":"staging"`,
+				`"reason":"Ready for evaluation"`,
+				`"handle":"demo"`,
+				`"message":"Model version stage updated"`,
+			},
+		},
+		{
+			Name:   "transition model version stage + member token + production",
+			Method: http.MethodPost,
+			Url:    stageUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelStage(t, e, "v1", "staging")
+				if _, err := app.Dao().CreateUserOrganizationFromEmailAndOrgId("notadmin@aztlan.in", test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"stage":"production"}`),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"Only organization owners can move a version to production"`,
+			},
+		},
+		{
+			Name:   "transition model version stage + valid token + production replaces current",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v2/stage",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, "test", false)
+				setModelStage(t, e, "v1", "staging")
+				setModelStage(t, e, "v1", "production")
+				setModelStage(t, e, "v2", "staging")
+			},
+			Body:           strings.NewReader(`{"stage":"production","reason":"Better accuracy"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v2"`,
+				`"from_stage":"staging"`,
+				`"to_stage":"production"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				versions, err := app.Dao().GetModelStageVersions(validDemoModelUuid, "production")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(versions) != 1 || versions[0].Version != "v2" {
+					t.Fatalf("Expected a single production version, got %+v", versions)
+				}
+				v1, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if v1.Stage != "archived" {
+					t.Fatalf("Expected the replaced version to be archived, got %s", v1.Stage)
+				}
+				history, err := app.Dao().GetModelVersionStageTransitions(v1.UUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(history) != 3 || history[0].ToStage != "archived" || history[0].Reason != "Replaced by dev/v2" {
+					t.Fatalf("Expected the archival in the stage history, got %+v", history)
+				}
+			},
+		},
+		{
+			Name:   "get model stage versions + valid token + concurrent promotions",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/stage/production",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				versionUUIDs := []uuid.UUID{}
+				for i := 0; i < concurrentPromotions; i++ {
+					hash := sha256Hex(fmt.Sprintf("promotion-%d", i))
+					version, err := app.Dao().RegisterModelFile(validDemoModelDevBranchUuid, "LOCAL", "", "model.pkl", false, hash, "sha256", hash, "", uuid.NullUUID{}, nil, test.ValidAdminUserUuid)
+					if err != nil {
+						t.Fatal(err)
+					}
+					_, err = app.Dao().TransitionModelVersionStage(validDemoModelUuid, version.UUID, "staging", "", test.ValidAdminUserUuid)
+					if err != nil {
+						t.Fatal(err)
+					}
+					versionUUIDs = append(versionUUIDs, version.UUID)
+				}
+				var wg sync.WaitGroup
+				errs := make(chan error, len(versionUUIDs))
+				for _, versionUUID := range versionUUIDs {
+					wg.Add(1)
+					go func(versionUUID uuid.UUID) {
+						defer wg.Done()
+						_, err := app.Dao().TransitionModelVersionStage(validDemoModelUuid, versionUUID, "production", "", test.ValidAdminUserUuid)
+						errs <- err
+					}(versionUUID)
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"stage":"production"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				versions, err := app.Dao().GetModelStageVersions(validDemoModelUuid, "production")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(versions) != 1 {
+					t.Fatalf("Expected a single production version, got %+v", versions)
+				}
+			},
+		},
+		{
+			Name:   "transition model version stage + valid token + review required",
+			Method: http.MethodPost,
+			Url:    stageUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				requireModelProductionReview(t, app)
+				setModelStage(t, e, "v1", "staging")
+			},
+			Body:           strings.NewReader(`{"stage":"production"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Version needs an accepted review to be moved to production"`,
+			},
+		},
+		{
+			Name:   "transition model version stage + valid token + accepted review",
+			Method: http.MethodPost,
+			Url:    stageUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				requireModelProductionReview(t, app)
+				setModelStage(t, e, "v1", "staging")
+				mainBranch, err := app.Dao().GetModelBranchByName(test.ValidAdminUserOrgUuid, "Demo Model", "main")
+				if err != nil {
+					t.Fatal(err)
+				}
+				v1, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = app.Dao().CreateModelReview(validDemoModelUuid, test.ValidAdminUserUuid, validDemoModelDevBranchUuid, v1.UUID, mainBranch.UUID, "Release v1", "", true, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"stage":"production"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"to_stage":"production"`,
+				`"message":"Model version stage updated"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestModelStageHistory(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "update model stage rules + member token",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/stage/rules",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if _, err := app.Dao().CreateUserOrganizationFromEmailAndOrgId("notadmin@aztlan.in", test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"require_production_review":true}`),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to update the stage rules of this model"`,
+			},
+		},
+		{
+			Name:   "update model stage rules + valid token",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/stage/rules",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"require_production_review":true}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"data":[{"require_production_review":true}]`,
+				`"message":"Model stage rules updated"`,
+			},
+		},
+		{
+			Name:   "get model stage history + valid token",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/stage/history",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelStage(t, e, "v1", "staging")
+				setModelStage(t, e, "v1", "archived")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"from_stage":"staging","to_stage":"archived"`,
+				`"from_stage":"none","to_stage":"staging"`,
+				`"message":"Model stage history"`,
+			},
+		},
+		{
+			Name:   "get model stage versions + valid token",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/stage/staging",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelVersionFile(t, app, "test", false)
+				setModelStage(t, e, "v2", "staging")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"version":"v2"`,
+				`"stage":"staging"`,
+				`"message":"Model versions in stage"`,
+			},
+			NotExpectedContent: []string{
+				`"version":"v1"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
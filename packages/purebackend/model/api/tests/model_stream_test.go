@@ -0,0 +1,335 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// seedModelStream writes, in order, a log and a metric for dev/v1 of the
+// Demo Model, a review of dev/v1 into main and registers dev/v2.
+func seedModelStream(t *testing.T, app *test.TestApp) {
+	if _, err := app.Dao().CreateLogForModelVersion("epoch", "1", validDemoModelUuid); err != nil {
+		t.Fatal(err)
+	}
+	value := 0.5
+	step := int64(1)
+	_, err := app.Dao().CreateModelVersionMetrics(validDemoModelUuid, []commonmodels.MetricRequest{
+		{Key: "accuracy", Value: &value, Step: &step},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = app.Dao().CreateModelReview(validDemoModelUuid, test.ValidAdminUserUuid, validDemoModelDevBranchUuid, validDemoModelUuid, demoModelMainBranchUuid(t, app), "Promote v1", "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().RegisterModelFile(validDemoModelDevBranchUuid, "LOCAL", "", "model.pkl", false, sha256Hex("v2"), "sha256", sha256Hex("v2"), "", uuid.NullUUID{}, nil, test.ValidAdminUserUuid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeModelStreamEvent writes a log event of the Demo Model with the id, as
+// a transaction holding the id until it commits would.
+func writeModelStreamEvent(t *testing.T, app *test.TestApp, id uint64, data string) {
+	event := dbmodels.StreamEvent{
+		ID:        id,
+		Kind:      "log",
+		ModelUUID: uuid.NullUUID{UUID: validDemoModelUuid, Valid: true},
+		Data:      `{"key":"epoch","data":"` + data + `","type":""}`,
+	}
+	if err := app.Dao().Datastore().DB.Create(&event).Error; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStreamModel(t *testing.T) {
+	streamUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/stream"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "stream model + unauthorized",
+			Method:         http.MethodGet,
+			Url:            streamUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "stream model + valid token + invalid kind",
+			Method: http.MethodGet,
+			Url:    streamUrl + "?kind=log,file",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid event kind file, expected one of log, metric, version, review"`,
+			},
+		},
+		{
+			Name:   "stream model + valid token + invalid last event id",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "first",
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid last event id first"`,
+			},
+		},
+		{
+			Name:   "stream model + valid token + replay",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "0",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"retry: 3000\n\n",
+				"id: 1\nevent: log\ndata: {\"id\":1,\"kind\":\"log\",\"version_uuid\":\"" + validDemoModelUuid.String() + "\",\"data\":{\"key\":\"epoch\",\"data\":\"1\",\"type\":\"\"}",
+				"id: 2\nevent: metric\ndata: {\"id\":2,\"kind\":\"metric\",\"version_uuid\":\"" + validDemoModelUuid.String() + "\",\"data\":{\"key\":\"accuracy\",\"value\":0.5,\"step\":1,",
+				"id: 3\nevent: review\n",
+				`"title":"Promote v1","from_branch_uuid":"` + validDemoModelDevBranchUuid.String() + `","from_branch_version_uuid":"` + validDemoModelUuid.String() + `"`,
+				"id: 4\nevent: version\n",
+				`"version":"v2","branch":"dev","hash":"` + sha256Hex("v2") + `"`,
+			},
+		},
+		{
+			Name:   "stream model + valid token + resume",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "2",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 3\nevent: review\n",
+				"id: 4\nevent: version\n",
+			},
+			NotExpectedContent: []string{
+				"event: log",
+				"event: metric",
+			},
+		},
+		{
+			Name:   "stream model + valid token + resume from query + kind",
+			Method: http.MethodGet,
+			Url:    streamUrl + "?last_event_id=0&kind=version",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 4\nevent: version\n",
+			},
+			NotExpectedContent: []string{
+				"event: log",
+				"event: metric",
+				"event: review",
+			},
+		},
+		{
+			Name:   "stream model + valid token + new events only",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"retry: 3000\n\n",
+			},
+			NotExpectedContent: []string{
+				"event:",
+			},
+		},
+		{
+			Name:   "stream model + valid token + events written while streaming",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+				app.Settings().Stream.PollInterval = 10
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					app.Dao().CreateLogForModelVersion("epoch", "2", validDemoModelUuid)
+				}()
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 5\nevent: log\n",
+				`"data":{"key":"epoch","data":"2","type":""}`,
+			},
+			NotExpectedContent: []string{
+				"id: 4\n",
+			},
+		},
+		{
+			Name:   "stream model + valid token + event committed late",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "4",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+				writeModelStreamEvent(t, app, 6, "3")
+				app.Settings().Stream.PollInterval = 10
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					writeModelStreamEvent(t, app, 5, "2")
+				}()
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 6\nevent: log\n",
+				"id: 5\nevent: log\n",
+			},
+			NotExpectedContent: []string{
+				"id: 4\n",
+			},
+		},
+		{
+			Name:   "stream model + valid token + event committed late before last event id",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "6",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+				writeModelStreamEvent(t, app, 6, "3")
+				app.Settings().Stream.PollInterval = 10
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					writeModelStreamEvent(t, app, 5, "2")
+				}()
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 5\nevent: log\n",
+				`"data":{"key":"epoch","data":"2","type":""}`,
+			},
+			NotExpectedContent: []string{
+				"id: 4\n",
+				"id: 6\n",
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestStreamModelVersion(t *testing.T) {
+	streamUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/stream"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "stream model version + unauthorized",
+			Method:         http.MethodGet,
+			Url:            streamUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "stream model version + valid token + version not found",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v9/stream",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`Model Branch Version not found`,
+			},
+		},
+		{
+			Name:   "stream model version + valid token + invalid kind",
+			Method: http.MethodGet,
+			Url:    streamUrl + "?kind=version",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid event kind version, expected one of log, metric"`,
+			},
+		},
+		{
+			Name:   "stream model version + valid token + replay",
+			Method: http.MethodGet,
+			Url:    streamUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "0",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 1\nevent: log\n",
+				"id: 2\nevent: metric\n",
+			},
+			NotExpectedContent: []string{
+				"event: review",
+				"event: version",
+			},
+		},
+		{
+			Name:   "stream model version + valid token + metrics only",
+			Method: http.MethodGet,
+			Url:    streamUrl + "?kind=metric",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Last-Event-ID": "0",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelStream(t, app)
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				"id: 2\nevent: metric\n",
+			},
+			NotExpectedContent: []string{
+				"event: log",
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+)
+
+// deleteModelPath moves the model, branch or version at the path of the
+// Demo Model to the trash.
+func deleteModelPath(t *testing.T, e *echo.Echo, path string) {
+	req := httptest.NewRequest(http.MethodDelete, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model"+path+"/delete", nil)
+	req.Header.Set("Authorization", test.ValidAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Expected %s to be moved to trash, got %d %s", path, rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteModelVersion(t *testing.T) {
+	deleteUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version/v1/delete"
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "delete model version + unauthorized",
+			Method:         http.MethodDelete,
+			Url:            deleteUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "delete model version + valid token",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"kind":"model_version"`,
+				`"name":"Demo Model/dev/v1"`,
+				`"handle":"demo"`,
+				`"message":"Model version moved to trash"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version != nil {
+					t.Fatal("Expected the version to be hidden once in the trash")
+				}
+				// trashed versions keep their number
+				seedModelVersionFile(t, app, "test", false)
+				if version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v2"); err != nil || version == nil {
+					t.Fatalf("Expected the next version to be v2, got %v %v", version, err)
+				}
+			},
+		},
+		{
+			Name:   "delete model version + valid token + referenced by alias",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelAlias(t, e, "production", "dev", "v1")
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"Version is referenced by the alias production"`,
+			},
+		},
+		{
+			Name:   "delete model version + valid token + referenced by open review",
+			Method: http.MethodDelete,
+			Url:    deleteUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				mainBranch, err := app.Dao().GetModelBranchByName(test.ValidAdminUserOrgUuid, "Demo Model", "main")
+				if err != nil {
+					t.Fatal(err)
+				}
+				v1, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = app.Dao().CreateModelReview(validDemoModelUuid, test.ValidAdminUserUuid, validDemoModelDevBranchUuid, v1.UUID, mainBranch.UUID, "Release v1", "", false, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"Version is referenced by an open review"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestDeleteModel(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "delete model branch + valid token",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"kind":"model_branch"`,
+				`"name":"Demo Model/dev"`,
+				`"message":"Model branch moved to trash"`,
+			},
+		},
+		{
+			Name:   "delete model branch + valid token + referenced by alias",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				setModelAlias(t, e, "champion", "dev", "v1")
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"Branch is referenced by the alias champion"`,
+			},
+		},
+		{
+			Name:   "delete model + valid token",
+			Method: http.MethodDelete,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/delete",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"kind":"model"`,
+				`"name":"Demo Model"`,
+				`"message":"Model moved to trash"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				model, err := app.Dao().GetModelByName(test.ValidAdminUserOrgUuid, "Demo Model")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if model != nil {
+					t.Fatal("Expected the model to be hidden once in the trash")
+				}
+			},
+		},
+		{
+			Name:   "create model + valid token + name in trash",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/create",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				deleteModelPath(t, e, "")
+			},
+			Body:           strings.NewReader(`{"wiki":"","is_public":false}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Model with this name is in the trash"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
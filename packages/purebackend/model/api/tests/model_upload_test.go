@@ -0,0 +1,601 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	commondbmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/dbmodels"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	validDemoModelUuid          = uuid.Must(uuid.FromString("11111111-1111-1111-1111-111111111111"))
+	validDemoModelDevBranchUuid = uuid.Must(uuid.FromString("22222222-2222-2222-2222-222222222222"))
+	validModelUploadSessionUuid = uuid.Must(uuid.FromString("33333333-3333-3333-3333-333333333333"))
+	validModelUploadHash        = sha256Hex("first-second-third")
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// testBlobKey returns the storage key of the admin org blob with the digest.
+func testBlobKey(digest string) string {
+	return fmt.Sprintf("blobs/%s/sha256/%s/%s/%s", test.ValidAdminUserOrgUuid, digest[0:2], digest[2:4], digest)
+}
+
+// seedModelUploadSession creates a LOCAL upload session on the Demo Model
+// dev branch and stores the provided chunks (numbered from 1) for it.
+func seedModelUploadSession(t *testing.T, app *test.TestApp, chunks ...string) {
+	session := dbmodels.UploadSession{
+		BaseModel: commondbmodels.BaseModel{
+			UUID: validModelUploadSessionUuid,
+		},
+		OrganizationUUID: test.ValidAdminUserOrgUuid,
+		ModelBranchUUID: uuid.NullUUID{
+			UUID:  validDemoModelDevBranchUuid,
+			Valid: true,
+		},
+		FileName:  "model.pkl",
+		Storage:   "LOCAL",
+		CreatedBy: test.ValidAdminUserUuid,
+	}
+	if err := app.Dao().Datastore().DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	for i, chunk := range chunks {
+		key := fmt.Sprintf("model-registry/%s/models/%s/%s/uploads/%s/%d", test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid, validModelUploadSessionUuid, i+1)
+		if err := fs.Upload([]byte(chunk), key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.Dao().CreateUploadChunk(validModelUploadSessionUuid, i+1, int64(len(chunk))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateModelUploadSession(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "create model upload session + unauthorized",
+			Method:         http.MethodPost,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "create model upload session + valid token + branch not found",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/nobranch/upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`Model Branch not found`,
+			},
+		},
+		{
+			Name:   "create model upload session + valid token + file name empty",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"storage":"LOCAL"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"status":400`,
+				`"data":null`,
+				`"message":"File name is required"`,
+			},
+		},
+		{
+			Name:   "create model upload session + valid token + storage not connected",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"file_name":"model.pkl","storage":"nope"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"status":400`,
+				`"data":null`,
+				`"message":"Source nope not connected properly to organization"`,
+			},
+		},
+		{
+			Name:   "create model upload session + valid token + session created",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"file_name":"model.pkl","storage":"LOCAL"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"uuid":"`,
+				`"file_name":"model.pkl"`,
+				`"storage":"LOCAL"`,
+				`"is_complete":false`,
+				`"chunks":[]`,
+				`"received_ranges":[]`,
+				`"received_bytes":0`,
+				`"email":"demo@aztlan.in"`,
+				`"message":"Model upload session created"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestGetModelUploadSession(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "get model upload session + valid token + invalid session id",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/nope",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid upload session id"`,
+			},
+		},
+		{
+			Name:   "get model upload session + valid token + session not found",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Upload session not found"`,
+			},
+		},
+		{
+			Name:   "get model upload session + valid token + received ranges",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String(),
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "aaa", "bb")
+				if _, err := app.Dao().CreateUploadChunk(validModelUploadSessionUuid, 4, 1); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"uuid":"` + validModelUploadSessionUuid.String() + `"`,
+				`"chunks":[{"number":1,"size":3},{"number":2,"size":2},{"number":4,"size":1}]`,
+				`"received_ranges":[{"start":1,"end":2},{"start":4,"end":4}]`,
+				`"received_bytes":6`,
+				`"message":"Model upload session details"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestUploadModelChunk(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "upload model chunk + valid token + session not found",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/chunk/1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader("chunk"),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Upload session not found"`,
+			},
+		},
+		{
+			Name:   "upload model chunk + valid token + invalid chunk number",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/chunk/0",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app)
+			},
+			Body:           strings.NewReader("chunk"),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid chunk number"`,
+			},
+		},
+		{
+			Name:   "upload model chunk + valid token + empty chunk",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/chunk/1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app)
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Chunk is empty"`,
+			},
+		},
+		{
+			Name:   "upload model chunk + valid token + chunk too large",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/chunk/1",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  "application/octet-stream",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app)
+				app.Settings().Upload.MaxChunkSize = 4
+			},
+			Body:           strings.NewReader("first"),
+			ExpectedStatus: 413,
+			ExpectedContent: []string{
+				`"message":"Chunk exceeds the maximum size of 4 bytes"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetModelUploadSession(validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.ReceivedBytes != 0 {
+					t.Fatalf("Expected no received bytes, got %d", session.ReceivedBytes)
+				}
+			},
+		},
+		{
+			Name:   "upload model chunk + valid token + chunk uploaded",
+			Method: http.MethodPut,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/chunk/2",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+				"Content-Type":  "application/octet-stream",
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first")
+			},
+			Body:           strings.NewReader("second"),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"number":2`,
+				`"size":6`,
+				`"message":"Model file chunk uploaded"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetModelUploadSession(validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.ReceivedBytes != 11 {
+					t.Fatalf("Expected 11 received bytes, got %d", session.ReceivedBytes)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// concurrentFinalizes is the number of finalize requests sent at once for
+// the same upload session.
+const concurrentFinalizes = 10
+
+func TestFinalizeModelUpload(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "finalize model upload + valid token + hash empty",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first")
+			},
+			Body:           strings.NewReader(`{}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Hash is required"`,
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + hash already exists",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first")
+			},
+			Body:           strings.NewReader(`{"hash":"1234567890"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Model with this hash already exists"`,
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + hash of a trashed version",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first")
+				version, err := app.Dao().GetModelBranchVersion(validDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := app.Dao().TrashModelVersion(test.ValidAdminUserOrgUuid, version.UUID, "Demo Model/dev/v1", test.ValidAdminUserUuid, time.Now().Add(time.Hour)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"hash":"1234567890"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Model with this hash already exists in the trash"`,
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + no chunks",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app)
+			},
+			Body:           strings.NewReader(`{"hash":"uploadhash"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"No chunks uploaded"`,
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + missing chunk",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first")
+				if _, err := app.Dao().CreateUploadChunk(validModelUploadSessionUuid, 3, 5); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"hash":"uploadhash"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Missing chunk 2"`,
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + unsupported hash algorithm",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first-", "second-", "third")
+			},
+			Body:           strings.NewReader(`{"hash":"uploadhash","hash_algorithm":"crc32"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Unsupported hash algorithm"`,
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + hash mismatch",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first-", "second-", "third")
+			},
+			Body:           strings.NewReader(`{"hash":"uploadhash"}`),
+			ExpectedStatus: 422,
+			ExpectedContent: []string{
+				`"message":"Hash mismatch: expected sha256 uploadhash, computed ` + validModelUploadHash + `"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetModelUploadSession(validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.IsComplete {
+					t.Fatal("Expected upload session to stay open")
+				}
+				filePath := fmt.Sprintf("%s/storage/model-registry/%s/models/%s/%s/%s_model.pkl", app.DataDir(), test.ValidAdminUserOrgUuid, validDemoModelUuid, validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+					t.Fatalf("Expected mismatched file to be deleted, got %v", err)
+				}
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + storage quota exceeded",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first-", "second-", "third")
+				setOrgStorageQuota(t, app, 0, 10)
+			},
+			Body:           strings.NewReader(`{"hash":"` + validModelUploadHash + `"}`),
+			ExpectedStatus: 413,
+			ExpectedContent: []string{
+				`"message":"File exceeds the organization storage quota"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetModelUploadSession(validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if session.IsComplete {
+					t.Fatal("Expected upload session to stay open")
+				}
+				if _, err := os.Stat(filepath.Join(app.LocalStorageDir(), testBlobKey(validModelUploadHash))); !os.IsNotExist(err) {
+					t.Fatalf("Expected no blob to be stored, got %v", err)
+				}
+				// the chunks are kept for a retry
+				setOrgStorageQuota(t, app, 0, 0)
+				recorder := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model/branch/dev/upload/"+validModelUploadSessionUuid.String()+"/finalize", strings.NewReader(`{"hash":"`+validModelUploadHash+`"}`))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				req.Header.Set("Authorization", test.ValidAdminToken)
+				e.ServeHTTP(recorder, req)
+				if recorder.Code != http.StatusOK {
+					t.Fatalf("Expected finalize retry to register the model, got status %d: %s", recorder.Code, recorder.Body.String())
+				}
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + concurrent finalizes",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first-", "second-", "third")
+				var wg sync.WaitGroup
+				statuses := make(chan int, concurrentFinalizes)
+				for i := 0; i < concurrentFinalizes; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						recorder := httptest.NewRecorder()
+						req := httptest.NewRequest(http.MethodPost, "/api/org/"+test.ValidAdminUserOrgUuid.String()+"/model/Demo%20Model/branch/dev/upload/"+validModelUploadSessionUuid.String()+"/finalize", strings.NewReader(`{"hash":"`+validModelUploadHash+`"}`))
+						req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+						req.Header.Set("Authorization", test.ValidAdminToken)
+						e.ServeHTTP(recorder, req)
+						statuses <- recorder.Code
+					}()
+				}
+				wg.Wait()
+				close(statuses)
+				registered := 0
+				for status := range statuses {
+					switch status {
+					case http.StatusOK:
+						registered++
+					case http.StatusBadRequest, http.StatusConflict:
+					default:
+						t.Fatalf("Expected finalize to register or to be rejected as already finalized, got status %d", status)
+					}
+				}
+				if registered != 1 {
+					t.Fatalf("Expected exactly one finalize to register the model, got %d", registered)
+				}
+			},
+			Body:           strings.NewReader(`{"hash":"` + validModelUploadHash + `"}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Upload session already finalized"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				versions, err := app.Dao().GetModelBranchAllVersions(validDemoModelDevBranchUuid, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(versions) != 2 {
+					t.Fatalf("Expected 2 versions, got %d", len(versions))
+				}
+			},
+		},
+		{
+			Name:   "finalize model upload + valid token + registered successfully",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/upload/" + validModelUploadSessionUuid.String() + "/finalize",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedModelUploadSession(t, app, "first-", "second-", "third")
+			},
+			Body:           strings.NewReader(`{"hash":"` + validModelUploadHash + `"}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"hash":"` + validModelUploadHash + `"`,
+				`"hash_algorithm":"sha256"`,
+				`"digests":{"sha256":"` + validModelUploadHash + `"}`,
+				`"version":"v2"`,
+				`"name":"dev"`,
+				`"source_type":"LOCAL"`,
+				`"message":"Model successfully registered"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				session, err := app.Dao().GetModelUploadSession(validDemoModelDevBranchUuid, validModelUploadSessionUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !session.IsComplete {
+					t.Fatal("Expected upload session to be complete")
+				}
+				content, err := os.ReadFile(filepath.Join(app.LocalStorageDir(), testBlobKey(validModelUploadHash)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != "first-second-third" {
+					t.Fatalf("Expected assembled file content %q, got %q", "first-second-third", string(content))
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// concurrentRegistrations is the number of versions registered at once on
+// the Demo Model dev branch.
+const concurrentRegistrations = 50
+
+func TestConcurrentModelVersionRegistration(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "get model branch versions + valid token + concurrent registrations",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/model/Demo%20Model/branch/dev/version",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				var wg sync.WaitGroup
+				errs := make(chan error, concurrentRegistrations)
+				for i := 0; i < concurrentRegistrations; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						hash := sha256Hex(fmt.Sprintf("content-%d", i))
+						if i%2 == 0 {
+							_, err := app.Dao().RegisterModelFile(validDemoModelDevBranchUuid, "LOCAL", "", "model.pkl", false, hash, "sha256", hash, "", uuid.NullUUID{}, nil, test.ValidAdminUserUuid)
+							errs <- err
+							return
+						}
+						_, err := app.Dao().RegisterModelReference(validDemoModelDevBranchUuid, "LOCAL", "", "model.pkl", hash, "sha256", hash, 0, "", test.ValidAdminUserUuid)
+						errs <- err
+					}(i)
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				fmt.Sprintf(`"version":"v%d"`, concurrentRegistrations+1),
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				versions, err := app.Dao().GetModelBranchAllVersions(validDemoModelDevBranchUuid, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				seen := map[string]bool{}
+				for _, version := range versions {
+					seen[version.Version] = true
+				}
+				for i := 1; i <= concurrentRegistrations+1; i++ {
+					if !seen[fmt.Sprintf("v%d", i)] {
+						t.Fatalf("Expected versions v1 to v%d, got %+v", concurrentRegistrations+1, seen)
+					}
+				}
+				if len(versions) != concurrentRegistrations+1 {
+					t.Fatalf("Expected %d versions, got %d", concurrentRegistrations+1, len(versions))
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -14,6 +14,7 @@ type Model struct {
 	CreatedBy                uuid.UUID     `json:"created_by" gorm:"type:uuid;not null"`
 	UpdatedBy                uuid.NullUUID `json:"updated_by" gorm:"type:uuid;"`
 	IsPublic                 bool          `json:"is_public" default:"false"`
+	RequireProductionReview  bool          `json:"require_production_review" default:"false"`
 
 	Org           userorgdbmodels.Organization `gorm:"foreignKey:OrganizationUUID"`
 	CreatedByUser userorgdbmodels.User         `gorm:"foreignKey:CreatedBy"`
@@ -35,9 +36,13 @@ type ModelBranch struct {
 	Name                     string    `json:"name" gorm:"not null;index:idx_model_branch,unique"`
 	ModelUUID                uuid.UUID `json:"model_uuid" gorm:"type:uuid;not null;index:idx_model_branch,unique"`
 	IsDefault                bool      `json:"is_default" default:"false"`
-	// IsProtected bool      `json:"is_protected" default:"false"`
+	// the version the branch was created from, if any
+	BaseBranchUUID  uuid.NullUUID `json:"base_branch_uuid" gorm:"type:uuid;"`
+	BaseVersionUUID uuid.NullUUID `json:"base_version_uuid" gorm:"type:uuid;"`
 
-	Model Model `gorm:"foreignKey:ModelUUID"`
+	Model       Model         `gorm:"foreignKey:ModelUUID"`
+	BaseBranch  *ModelBranch  `gorm:"foreignKey:BaseBranchUUID"`
+	BaseVersion *ModelVersion `gorm:"foreignKey:BaseVersionUUID"`
 
 	Versions []ModelVersion `gorm:"foreignKey:BranchUUID"`
 }
@@ -46,17 +51,42 @@ type ModelVersion struct {
 	commondbmodels.BaseModel `gorm:"embedded"`
 	Version                  string        `json:"version" gorm:"not null;index:idx_model_branch_version,unique"`
 	Hash                     string        `json:"hash" gorm:"not null;index:idx_model_branch_hash,unique"`
+	HashAlgorithm            string        `json:"hash_algorithm"`
+	Digest                   string        `json:"digest"`
 	IsEmpty                  bool          `json:"is_empty"`
 	BranchUUID               uuid.UUID     `json:"branch_uuid" gorm:"type:uuid;not null;index:idx_model_branch_version,unique;index:idx_model_branch_hash,unique"`
 	PathUUID                 uuid.NullUUID `json:"path_uuid" gorm:"type:uuid;"`
 	Path                     string        `json:"path"`
 	SourceType               string        `json:"source_type"`
+	FileName                 string        `json:"file_name"`
+	BlobUUID                 uuid.NullUUID `json:"blob_uuid" gorm:"type:uuid;"`
+	EncryptionKey            string        `json:"encryption_key"`
+	EncryptionKeyVersion     int           `json:"encryption_key_version"`
+	IsManifest               bool          `json:"is_manifest"`
+	IsReferenced             bool          `json:"is_referenced"`
+	Size                     int64         `json:"size"`
+	ETag                     string        `json:"etag"`
+	Stage                    string        `json:"stage" gorm:"not null;default:none"`
 	CreatedBy                uuid.UUID     `json:"created_by" gorm:"type:uuid;"`
 
 	Branch        ModelBranch          `gorm:"foreignKey:BranchUUID"`
 	CreatedByUser userorgdbmodels.User `gorm:"foreignKey:CreatedBy"`
 }
 
+type ModelStageTransition struct {
+	commondbmodels.BaseModel `gorm:"embedded"`
+	ModelUUID                uuid.UUID `json:"model_uuid" gorm:"type:uuid;not null;index"`
+	ModelVersionUUID         uuid.UUID `json:"model_version_uuid" gorm:"type:uuid;not null;index"`
+	FromStage                string    `json:"from_stage" gorm:"not null"`
+	ToStage                  string    `json:"to_stage" gorm:"not null"`
+	Reason                   string    `json:"reason"`
+	CreatedBy                uuid.UUID `json:"created_by" gorm:"type:uuid;not null"`
+
+	Model         Model                `gorm:"foreignKey:ModelUUID"`
+	ModelVersion  ModelVersion         `gorm:"foreignKey:ModelVersionUUID"`
+	CreatedByUser userorgdbmodels.User `gorm:"foreignKey:CreatedBy"`
+}
+
 type ModelReview struct {
 	commondbmodels.BaseModel `gorm:"embedded"`
 	ModelUUID                uuid.UUID     `json:"model_uuid" gorm:"type:uuid;not null"`
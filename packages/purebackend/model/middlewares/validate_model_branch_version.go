@@ -23,6 +23,10 @@ func ValidateModelBranchVersion(app core.App) echo.MiddlewareFunc {
 				return nil
 			}
 			version, err := app.Dao().GetModelBranchVersion(modelBranchUUID, modelBranchVersion)
+			if err == nil && version == nil {
+				// the version may be an alias of a version of the branch
+				version, err = resolveModelAlias(app, context, modelBranchVersion)
+			}
 			if err != nil {
 				context.Response().WriteHeader(http.StatusInternalServerError)
 				_, err = context.Response().Writer.Write([]byte(err.Error()))
@@ -47,3 +51,27 @@ func ValidateModelBranchVersion(app core.App) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// resolveModelAlias returns the version of the model branch the alias points
+// to, if any. The version path param is replaced by the resolved version so
+// that the handlers see the actual version.
+func resolveModelAlias(app core.App, context echo.Context, name string) (*models.ModelBranchVersionResponse, error) {
+	modelUUID := context.Get(ContextModelKey).(*models.ModelNameResponse).UUID
+	modelBranchUUID := context.Get(ContextModelBranchKey).(*models.ModelBranchNameResponse).UUID
+	alias, err := app.Dao().GetModelAlias(modelUUID, name)
+	if err != nil || alias == nil {
+		return nil, err
+	}
+	version, err := app.Dao().GetModelBranchVersion(modelBranchUUID, alias.Version)
+	if err != nil || version == nil || version.UUID != alias.VersionUUID {
+		return nil, err
+	}
+	values := context.ParamValues()
+	for i, paramName := range context.ParamNames() {
+		if paramName == "version" && i < len(values) {
+			values[i] = version.Version
+		}
+	}
+	context.SetParamValues(values...)
+	return version, nil
+}
@@ -18,13 +18,18 @@ type CreateModelRequest struct {
 }
 
 type CreateModelBranchRequest struct {
-	BranchName string `json:"branch_name"`
+	BranchName  string `json:"branch_name"`
+	FromBranch  string `json:"from_branch"`
+	FromVersion string `json:"from_version"`
 }
 
 type RegisterModelRequest struct {
-	Hash       string `json:"hash"`
-	Storage    string `json:"storage"`
-	IsEmpty    bool   `json:"is_empty"`
+	Hash          string `json:"hash"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	Storage       string `json:"storage"`
+	IsEmpty       bool   `json:"is_empty"`
+	URI           string `json:"uri"`
+	ComputeHash   bool   `json:"compute_hash"`
 }
 
 type ModelReviewRequest struct {
@@ -60,6 +65,8 @@ type ModelResponse struct {
 	UpdatedBy userorgmodels.UserHandleResponse         `json:"updated_by"`
 	Readme    commonmodels.ReadmeResponse              `json:"readme"`
 	IsPublic  bool                                     `json:"is_public"`
+
+	RequireProductionReview bool `json:"require_production_review"`
 }
 
 type ModelUserResponse struct {
@@ -73,10 +80,17 @@ type ModelBranchNameResponse struct {
 }
 
 type ModelBranchResponse struct {
-	UUID      uuid.UUID         `json:"uuid"`
-	Name      string            `json:"name"`
-	Model     ModelNameResponse `json:"model"`
-	IsDefault bool              `json:"is_default"`
+	UUID      uuid.UUID                `json:"uuid"`
+	Name      string                   `json:"name"`
+	Model     ModelNameResponse        `json:"model"`
+	IsDefault bool                     `json:"is_default"`
+	Base      *ModelBranchBaseResponse `json:"base,omitempty"`
+}
+
+// ModelBranchBaseResponse is the version a branch was created from.
+type ModelBranchBaseResponse struct {
+	Branch  ModelBranchNameResponse        `json:"branch"`
+	Version ModelBranchVersionNameResponse `json:"version"`
 }
 
 type ModelBranchVersionNameResponse struct {
@@ -85,16 +99,26 @@ type ModelBranchVersionNameResponse struct {
 }
 
 type ModelBranchVersionResponse struct {
-	UUID       uuid.UUID                        `json:"uuid"`
-	Version    string                           `json:"version"`
-	Branch     ModelBranchNameResponse          `json:"branch"`
-	Hash       string                           `json:"hash"`
-	Path       string                           `json:"path"`
-	SourceType string                           `json:"source_type"`
-	Logs       []commonmodels.LogDataResponse   `json:"logs"`
-	IsEmpty    bool                             `json:"is_empty"`
-	CreatedBy  userorgmodels.UserHandleResponse `json:"created_by"`
-	CreatedAt  time.Time                        `json:"created_at"`
+	UUID          uuid.UUID                        `json:"uuid"`
+	Version       string                           `json:"version"`
+	Branch        ModelBranchNameResponse          `json:"branch"`
+	Hash          string                           `json:"hash"`
+	HashAlgorithm string                           `json:"hash_algorithm"`
+	Digests       map[string]string                `json:"digests"`
+	Path          string                           `json:"path"`
+	SourceType    string                           `json:"source_type"`
+	FileName      string                           `json:"file_name"`
+	Logs          []commonmodels.LogDataResponse   `json:"logs"`
+	IsEmpty       bool                             `json:"is_empty"`
+	Encrypted     bool                             `json:"encrypted"`
+	IsManifest    bool                             `json:"is_manifest"`
+	IsReferenced  bool                             `json:"is_referenced"`
+	Size          int64                            `json:"size,omitempty"`
+	ETag          string                           `json:"etag,omitempty"`
+	Stage         string                           `json:"stage"`
+	DataKey       *commonmodels.DataKey            `json:"-"`
+	CreatedBy     userorgmodels.UserHandleResponse `json:"created_by"`
+	CreatedAt     time.Time                        `json:"created_at"`
 }
 
 type ModelReviewResponse struct {
@@ -110,3 +134,77 @@ type ModelReviewResponse struct {
 	IsComplete        bool                             `json:"is_complete"`
 	IsAccepted        bool                             `json:"is_accepted"`
 }
+
+// Model version lifecycle stages.
+const (
+	ModelStageNone       = "none"
+	ModelStageStaging    = "staging"
+	ModelStageProduction = "production"
+	ModelStageArchived   = "archived"
+)
+
+// modelStageTransitions lists the stages a version can move to from each stage.
+var modelStageTransitions = map[string][]string{
+	ModelStageNone:       {ModelStageStaging, ModelStageArchived},
+	ModelStageStaging:    {ModelStageNone, ModelStageProduction, ModelStageArchived},
+	ModelStageProduction: {ModelStageStaging, ModelStageArchived},
+	ModelStageArchived:   {ModelStageStaging},
+}
+
+func IsValidModelStage(stage string) bool {
+	_, ok := modelStageTransitions[stage]
+	return ok
+}
+
+// CanTransitionModelStage reports whether a version can move from a stage to another.
+func CanTransitionModelStage(from string, to string) bool {
+	for _, stage := range modelStageTransitions[from] {
+		if stage == to {
+			return true
+		}
+	}
+	return false
+}
+
+type ModelStageTransitionResponse struct {
+	UUID      uuid.UUID                        `json:"uuid"`
+	Branch    ModelBranchNameResponse          `json:"branch"`
+	Version   ModelBranchVersionNameResponse   `json:"version"`
+	FromStage string                           `json:"from_stage"`
+	ToStage   string                           `json:"to_stage"`
+	Reason    string                           `json:"reason"`
+	CreatedBy userorgmodels.UserHandleResponse `json:"created_by"`
+	CreatedAt time.Time                        `json:"created_at"`
+}
+
+// ModelLeaderboardQuery selects and ranks the versions of a leaderboard by
+// the numeric value logged for Key, highest first unless Ascending. The
+// empty filters match every version. Metrics are other keys whose logged
+// values are returned with the versions.
+type ModelLeaderboardQuery struct {
+	Key       string
+	Ascending bool
+	ModelUUID uuid.NullUUID
+	Branch    string
+	Stage     string
+	Alias     string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Metrics   []string
+}
+
+// ModelLeaderboardEntryResponse is a ranked model version. Versions with the
+// same value share their rank.
+type ModelLeaderboardEntryResponse struct {
+	Rank      int                            `json:"rank"`
+	Model     ModelNameResponse              `json:"model"`
+	Branch    ModelBranchNameResponse        `json:"branch"`
+	Version   ModelBranchVersionNameResponse `json:"version"`
+	Hash      string                         `json:"hash"`
+	Stage     string                         `json:"stage"`
+	Aliases   []string                       `json:"aliases"`
+	CreatedAt time.Time                      `json:"created_at"`
+	Value     float64                        `json:"value"`
+	Metrics   map[string]string              `json:"metrics,omitempty"`
+}
@@ -0,0 +1,161 @@
+package test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	userorgdbmodels "github.com/PureMLHQ/PureML/packages/purebackend/user_org/dbmodels"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	TestS3BucketName = "pureml-test"
+	TestS3SecretName = "test-s3"
+)
+
+// S3Server is a minimal in-memory S3 compatible server that supports
+// the path-style object operations (PUT, HEAD, GET and DELETE) used by
+// the app filesystem, including server side copies. Request signatures
+// are not verified.
+type S3Server struct {
+	*httptest.Server
+
+	mux     sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewS3Server starts a new fake S3 server.
+//
+// NB! Make sure to call `Close()` after you are done working with it.
+func NewS3Server() *S3Server {
+	s := &S3Server{
+		objects: map[string][]byte{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Object returns the stored content at bucket/key.
+func (s *S3Server) Object(bucket string, key string) ([]byte, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	content, ok := s.objects[bucket+"/"+key]
+	return content, ok
+}
+
+// PutObject stores content at bucket/key.
+func (s *S3Server) PutObject(bucket string, key string, content []byte) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.objects[bucket+"/"+key] = content
+}
+
+// CreateSecret connects the fake server to the org as a S3 source
+// named TestS3SecretName.
+func (s *S3Server) CreateSecret(app *TestApp, orgId uuid.UUID) error {
+	values := map[string]string{
+		"S3_ACCESS_KEY_ID":     "test-key",
+		"S3_ACCESS_KEY_SECRET": "test-secret",
+		"S3_BUCKET_NAME":       TestS3BucketName,
+		"S3_BUCKET_LOCATION":   "us-east-1",
+		"S3_ENDPOINT":          s.URL,
+		"S3_PUBLIC_URL":        s.URL + "/" + TestS3BucketName,
+	}
+	for key, value := range values {
+		secret := userorgdbmodels.Secret{
+			OrgUUID: orgId.String(),
+			Name:    TestS3SecretName,
+			Key:     key,
+			Value:   value,
+		}
+		if err := app.Dao().Datastore().DB.Create(&secret).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3Server) handle(w http.ResponseWriter, r *http.Request) {
+	objectKey := strings.TrimPrefix(r.URL.Path, "/")
+
+	switch r.Method {
+	case http.MethodPut:
+		if copySource := r.Header.Get("X-Amz-Copy-Source"); copySource != "" {
+			s.copyObject(w, copySource, objectKey)
+			return
+		}
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.mux.Lock()
+		s.objects[objectKey] = content
+		s.mux.Unlock()
+		w.Header().Set("ETag", etag(content))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodHead, http.MethodGet:
+		s.mux.RLock()
+		content, ok := s.objects[objectKey]
+		s.mux.RUnlock()
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message><Key>%s</Key></Error>`, objectKey)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("ETag", etag(content))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	case http.MethodDelete:
+		s.mux.Lock()
+		delete(s.objects, objectKey)
+		s.mux.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (s *S3Server) copyObject(w http.ResponseWriter, copySource string, objectKey string) {
+	sourceKey, err := url.PathUnescape(strings.TrimPrefix(copySource, "/"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mux.Lock()
+	content, ok := s.objects[sourceKey]
+	if ok {
+		s.objects[objectKey] = content
+	}
+	s.mux.Unlock()
+	w.Header().Set("Content-Type", "application/xml")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message><Key>%s</Key></Error>`, sourceKey)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><CopyObjectResult><ETag>%s</ETag><LastModified>%s</LastModified></CopyObjectResult>`, etag(content), time.Now().UTC().Format(time.RFC3339))
+}
+
+func etag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
@@ -1,12 +1,16 @@
 package service
 
 import (
+	"encoding/json"
 	"net/http"
 
 	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/config"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/encryption"
 	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
 	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	userorgmodels "github.com/PureMLHQ/PureML/packages/purebackend/user_org/models"
 	"github.com/labstack/echo/v4"
 	uuid "github.com/satori/go.uuid"
 )
@@ -22,6 +26,11 @@ func BindOrgApi(app core.App, rg *echo.Group) {
 	orgGroup.GET("/id/:orgId", api.DefaultHandler(GetOrgByID), orgmiddlewares.ValidateOrg(api.app))
 	orgGroup.POST("/create", api.DefaultHandler(CreateOrg))
 	orgGroup.POST("/:orgId/update", api.DefaultHandler(UpdateOrg), orgmiddlewares.ValidateOrg(api.app))
+	orgGroup.GET("/:orgId/usage", api.DefaultHandler(GetOrgStorageUsage), orgmiddlewares.ValidateOrg(api.app))
+	orgGroup.POST("/:orgId/quota", api.DefaultHandler(UpdateOrgStorageQuota))
+	orgGroup.GET("/:orgId/encryption", api.DefaultHandler(GetOrgEncryption), orgmiddlewares.ValidateOrg(api.app))
+	orgGroup.POST("/:orgId/encryption", api.DefaultHandler(UpdateOrgEncryption), orgmiddlewares.ValidateOrg(api.app))
+	orgGroup.POST("/:orgId/encryption/rotate", api.DefaultHandler(RotateOrgEncryptionKey), orgmiddlewares.ValidateOrg(api.app))
 }
 
 // GetOrgByHandle godoc
@@ -200,9 +209,197 @@ func (api *Api) UpdateOrg(request *models.Request) *models.Response {
 	return models.NewDataResponse(http.StatusOK, updatedOrg, "Organization updated")
 }
 
+// GetOrgStorageUsage godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get organization storage usage.
+//	@Description	Get the bytes stored by the organization, per model and per dataset, and its storage quotas.
+//	@Tags			Organization
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/usage [get]
+//	@Param			orgId	path	string	true	"Organization ID"
+func (api *Api) GetOrgStorageUsage(request *models.Request) *models.Response {
+	orgId := uuid.Must(uuid.FromString(request.PathParams["orgId"]))
+	usage, err := api.app.Dao().GetOrgStorageUsage(orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if usage == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Organization not found")
+	}
+	return models.NewDataResponse(http.StatusOK, usage, "Organization storage usage")
+}
+
+// UpdateOrgStorageQuota godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Update organization storage quotas.
+//	@Description	Update the soft and hard storage quotas (in bytes, 0 for unlimited) of the organization. Only accessible by the organization owners and admins.
+//	@Tags			Organization
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/quota [post]
+//	@Param			orgId	path	string								true	"Organization ID"
+//	@Param			quota	body	models.UpdateOrgStorageQuotaRequest	true	"Storage quotas"
+func (api *Api) UpdateOrgStorageQuota(request *models.Request) *models.Response {
+	orgId, err := uuid.FromString(request.PathParams["orgId"])
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid UUID format")
+	}
+	org, err := api.app.Dao().GetOrgById(orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if org == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Organization not found")
+	}
+	if request.User == nil || !config.HasAdminAccess(request.User.Email) {
+		UserOrganization, err := api.app.Dao().GetUserOrganizationByOrgIdAndUserUUID(orgId, request.GetUserUUID())
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		if UserOrganization == nil || UserOrganization.Role != "owner" {
+			return models.NewErrorResponse(http.StatusForbidden, "You are not authorized to update the storage quota of this organization")
+		}
+	}
+	var quota userorgmodels.UpdateOrgStorageQuotaRequest
+	if err := json.Unmarshal(request.Body, &quota); err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if quota.SoftQuota < 0 || quota.HardQuota < 0 {
+		return models.NewErrorResponse(http.StatusBadRequest, "Storage quotas must not be negative")
+	}
+	if quota.HardQuota > 0 && quota.SoftQuota > quota.HardQuota {
+		return models.NewErrorResponse(http.StatusBadRequest, "Soft quota cannot exceed the hard quota")
+	}
+	_, err = api.app.Dao().UpdateOrg(orgId, map[string]interface{}{
+		"storage_soft_quota": quota.SoftQuota,
+		"storage_hard_quota": quota.HardQuota,
+	})
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	usage, err := api.app.Dao().GetOrgStorageUsage(orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, usage, "Organization storage quota updated")
+}
+
+// GetOrgEncryption godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get organization encryption at rest.
+//	@Description	Get whether the files stored for the organization are encrypted and the version of its encryption key.
+//	@Tags			Organization
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/encryption [get]
+//	@Param			orgId	path	string	true	"Organization ID"
+func (api *Api) GetOrgEncryption(request *models.Request) *models.Response {
+	orgId := uuid.Must(uuid.FromString(request.PathParams["orgId"]))
+	orgEncryption, err := api.app.Dao().GetOrgEncryption(orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if orgEncryption == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Organization not found")
+	}
+	return models.NewDataResponse(http.StatusOK, orgEncryption, "Organization encryption")
+}
+
+// UpdateOrgEncryption godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Enable or disable organization encryption at rest.
+//	@Description	Enable or disable the encryption of the files stored for the organization. Files already stored are kept as they are. Only accessible by the organization owners and admins.
+//	@Tags			Organization
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/encryption [post]
+//	@Param			orgId		path	string								true	"Organization ID"
+//	@Param			encryption	body	models.UpdateOrgEncryptionRequest	true	"Encryption settings"
+func (api *Api) UpdateOrgEncryption(request *models.Request) *models.Response {
+	orgId := uuid.Must(uuid.FromString(request.PathParams["orgId"]))
+	if errresp := api.validateOrgEncryptionAccess(request, orgId); errresp != nil {
+		return errresp
+	}
+	var orgEncryption userorgmodels.UpdateOrgEncryptionRequest
+	if err := json.Unmarshal(request.Body, &orgEncryption); err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if orgEncryption.Enabled && !encryption.Configured(api.app) {
+		return models.NewErrorResponse(http.StatusBadRequest, "Encryption at rest is not configured for this instance")
+	}
+	_, err := api.app.Dao().UpdateOrg(orgId, map[string]interface{}{
+		"encryption_enabled": orgEncryption.Enabled,
+	})
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	updatedEncryption, err := api.app.Dao().GetOrgEncryption(orgId)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, updatedEncryption, "Organization encryption updated")
+}
+
+// RotateOrgEncryptionKey godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Rotate organization encryption key.
+//	@Description	Move the organization to a new encryption key and rewrap the data keys of its stored files, which are not uploaded again. Only accessible by the organization owners and admins.
+//	@Tags			Organization
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/encryption/rotate [post]
+//	@Param			orgId	path	string	true	"Organization ID"
+func (api *Api) RotateOrgEncryptionKey(request *models.Request) *models.Response {
+	orgId := uuid.Must(uuid.FromString(request.PathParams["orgId"]))
+	if errresp := api.validateOrgEncryptionAccess(request, orgId); errresp != nil {
+		return errresp
+	}
+	orgEncryption, err := encryption.Rotate(api.app, orgId)
+	if err != nil {
+		switch err {
+		case encryption.ErrNotConfigured:
+			return models.NewErrorResponse(http.StatusBadRequest, "Encryption at rest is not configured for this instance")
+		case encryption.ErrOrgNotFound:
+			return models.NewErrorResponse(http.StatusNotFound, "Organization not found")
+		}
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, orgEncryption, "Organization encryption key rotated")
+}
+
+func (api *Api) validateOrgEncryptionAccess(request *models.Request, orgId uuid.UUID) *models.Response {
+	if request.User != nil && config.HasAdminAccess(request.User.Email) {
+		return nil
+	}
+	userOrganization, err := api.app.Dao().GetUserOrganizationByOrgIdAndUserUUID(orgId, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if userOrganization == nil || userOrganization.Role != "owner" {
+		return models.NewErrorResponse(http.StatusForbidden, "You are not authorized to manage the encryption of this organization")
+	}
+	return nil
+}
+
 var GetOrgByHandle ServiceFunc = (*Api).GetOrgByHandle
 var GetOrgByID ServiceFunc = (*Api).GetOrgByID
 var GetOrgAllPublicModels ServiceFunc = (*Api).GetOrgAllPublicModels
 var GetOrgAllPublicDatasets ServiceFunc = (*Api).GetOrgAllPublicDatasets
 var CreateOrg ServiceFunc = (*Api).CreateOrg
 var UpdateOrg ServiceFunc = (*Api).UpdateOrg
+var GetOrgStorageUsage ServiceFunc = (*Api).GetOrgStorageUsage
+var UpdateOrgStorageQuota ServiceFunc = (*Api).UpdateOrgStorageQuota
+var GetOrgEncryption ServiceFunc = (*Api).GetOrgEncryption
+var UpdateOrgEncryption ServiceFunc = (*Api).UpdateOrgEncryption
+var RotateOrgEncryptionKey ServiceFunc = (*Api).RotateOrgEncryptionKey
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/migration"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindStorageMigrationApi registers the storage migration api endpoints and the corresponding handlers.
+func BindStorageMigrationApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	migrationGroup := rg.Group("/org/:orgId/storage/migration", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	migrationGroup.GET("", api.DefaultHandler(GetStorageMigrations))
+	migrationGroup.POST("", api.DefaultHandler(CreateStorageMigration))
+	migrationGroup.GET("/:migrationId", api.DefaultHandler(GetStorageMigration))
+	migrationGroup.POST("/:migrationId/resume", api.DefaultHandler(ResumeStorageMigration))
+	migrationGroup.POST("/:migrationId/confirm", api.DefaultHandler(ConfirmStorageMigration))
+}
+
+// GetStorageMigrations godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get all storage migrations of organization
+//	@Description	Get all storage migrations of organization with the progress of their files
+//	@Tags			Storage
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/storage/migration [get]
+//	@Param			orgId	path	string	true	"Organization Id"
+func (api *Api) GetStorageMigrations(request *models.Request) *models.Response {
+	migrations, err := api.app.Dao().GetOrgStorageMigrations(request.GetOrgId())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, migrations, "Storage migrations")
+}
+
+// GetStorageMigration godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get storage migration
+//	@Description	Get storage migration with the progress of its files
+//	@Tags			Storage
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/storage/migration/{migrationId} [get]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			migrationId	path	string	true	"Migration Id"
+func (api *Api) GetStorageMigration(request *models.Request) *models.Response {
+	migrationUUID, err := uuid.FromString(request.GetPathParam("migrationId"))
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid migration id")
+	}
+	storageMigration, err := api.app.Dao().GetStorageMigration(request.GetOrgId(), migrationUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if storageMigration == nil {
+		return models.NewErrorResponse(http.StatusNotFound, "Storage migration not found")
+	}
+	return models.NewDataResponse(http.StatusOK, storageMigration, "Storage migration")
+}
+
+// CreateStorageMigration godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Migrate models and datasets to another storage
+//	@Description	Copy the version files and file logs of the models and datasets from the source storage to the target storage. The copies are verified against the file digests before the versions and logs are pointed to them. The source files are kept until the migration is confirmed. Only accessible by the organization owners.
+//	@Tags			Storage
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/storage/migration [post]
+//	@Param			orgId		path	string								true	"Organization Id"
+//	@Param			migration	body	commonmodels.StorageMigrationRequest	true	"Storages and artifacts to migrate"
+func (api *Api) CreateStorageMigration(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	if errresp := api.validateOrgOwner(orgId, request.GetUserUUID(), "You are not authorized to migrate the storage of this organization"); errresp != nil {
+		return errresp
+	}
+	var migrationRequest commonmodels.StorageMigrationRequest
+	if err := json.Unmarshal(request.Body, &migrationRequest); err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid request body")
+	}
+	if migrationRequest.Source == "" || migrationRequest.Target == "" {
+		return models.NewErrorResponse(http.StatusBadRequest, "Source and target storages are required")
+	}
+	if strings.EqualFold(migrationRequest.Source, migrationRequest.Target) {
+		return models.NewErrorResponse(http.StatusBadRequest, "Source and target storages must be different")
+	}
+	if len(migrationRequest.Models) == 0 && len(migrationRequest.Datasets) == 0 {
+		return models.NewErrorResponse(http.StatusBadRequest, "No models or datasets to migrate")
+	}
+	source, err := migration.ResolveStorage(api.app, orgId, migrationRequest.Source)
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Source %s not connected properly to organization", migrationRequest.Source))
+	}
+	if _, err := migration.ResolveStorage(api.app, orgId, migrationRequest.Target); err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("Source %s not connected properly to organization", migrationRequest.Target))
+	}
+	modelUUIDs := []uuid.UUID{}
+	for _, modelName := range migrationRequest.Models {
+		model, err := api.app.Dao().GetModelByName(orgId, modelName)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		if model == nil {
+			return models.NewErrorResponse(http.StatusNotFound, fmt.Sprintf("Model %s not found", modelName))
+		}
+		modelUUIDs = append(modelUUIDs, model.UUID)
+	}
+	datasetUUIDs := []uuid.UUID{}
+	for _, datasetName := range migrationRequest.Datasets {
+		dataset, err := api.app.Dao().GetDatasetByName(orgId, datasetName)
+		if err != nil {
+			return models.NewServerErrorResponse(err)
+		}
+		if dataset == nil {
+			return models.NewErrorResponse(http.StatusNotFound, fmt.Sprintf("Dataset %s not found", datasetName))
+		}
+		datasetUUIDs = append(datasetUUIDs, dataset.UUID)
+	}
+	storageMigration, err := api.app.Dao().CreateStorageMigration(orgId, migrationRequest.Source, migrationRequest.Target, source.SourceType, source.PublicURL, modelUUIDs, datasetUUIDs, request.GetUserUUID())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return api.runStorageMigration(orgId, storageMigration.UUID)
+}
+
+// ResumeStorageMigration godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Resume storage migration
+//	@Description	Copy the files of the storage migration that are not copied yet. Only accessible by the organization owners.
+//	@Tags			Storage
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/storage/migration/{migrationId}/resume [post]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			migrationId	path	string	true	"Migration Id"
+func (api *Api) ResumeStorageMigration(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	if errresp := api.validateOrgOwner(orgId, request.GetUserUUID(), "You are not authorized to migrate the storage of this organization"); errresp != nil {
+		return errresp
+	}
+	migrationUUID, err := uuid.FromString(request.GetPathParam("migrationId"))
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid migration id")
+	}
+	return api.runStorageMigration(orgId, migrationUUID)
+}
+
+// ConfirmStorageMigration godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Confirm storage migration
+//	@Description	Delete the source files of a copied storage migration. Only accessible by the organization owners.
+//	@Tags			Storage
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/storage/migration/{migrationId}/confirm [post]
+//	@Param			orgId		path	string	true	"Organization Id"
+//	@Param			migrationId	path	string	true	"Migration Id"
+func (api *Api) ConfirmStorageMigration(request *models.Request) *models.Response {
+	orgId := request.GetOrgId()
+	if errresp := api.validateOrgOwner(orgId, request.GetUserUUID(), "You are not authorized to migrate the storage of this organization"); errresp != nil {
+		return errresp
+	}
+	migrationUUID, err := uuid.FromString(request.GetPathParam("migrationId"))
+	if err != nil {
+		return models.NewErrorResponse(http.StatusBadRequest, "Invalid migration id")
+	}
+	storageMigration, err := migration.Confirm(api.app, orgId, migrationUUID)
+	if err != nil {
+		return storageMigrationErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, storageMigration, "Storage migration completed")
+}
+
+func (api *Api) runStorageMigration(orgId uuid.UUID, migrationUUID uuid.UUID) *models.Response {
+	storageMigration, err := migration.Run(api.app, orgId, migrationUUID)
+	if err != nil {
+		return storageMigrationErrorResponse(err)
+	}
+	if storageMigration.Status != models.StorageMigrationCopied {
+		return models.NewDataResponse(http.StatusOK, storageMigration, "Storage migration failed, resume it to retry the failed files")
+	}
+	return models.NewDataResponse(http.StatusOK, storageMigration, "Storage migration copied, confirm it to delete the source files")
+}
+
+func (api *Api) validateOrgOwner(orgId uuid.UUID, userUUID uuid.UUID, message string) *models.Response {
+	userOrganization, err := api.app.Dao().GetUserOrganizationByOrgIdAndUserUUID(orgId, userUUID)
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	if userOrganization == nil || userOrganization.Role != "owner" {
+		return models.NewErrorResponse(http.StatusForbidden, message)
+	}
+	return nil
+}
+
+func storageMigrationErrorResponse(err error) *models.Response {
+	switch err {
+	case migration.ErrMigrationNotFound:
+		return models.NewErrorResponse(http.StatusNotFound, "Storage migration not found")
+	case migration.ErrMigrationCompleted:
+		return models.NewErrorResponse(http.StatusBadRequest, "Storage migration is already completed")
+	case migration.ErrMigrationNotCopied:
+		return models.NewErrorResponse(http.StatusBadRequest, "Storage migration has files left to copy")
+	}
+	return models.NewServerErrorResponse(err)
+}
+
+var GetStorageMigrations ServiceFunc = (*Api).GetStorageMigrations
+var GetStorageMigration ServiceFunc = (*Api).GetStorageMigration
+var CreateStorageMigration ServiceFunc = (*Api).CreateStorageMigration
+var ResumeStorageMigration ServiceFunc = (*Api).ResumeStorageMigration
+var ConfirmStorageMigration ServiceFunc = (*Api).ConfirmStorageMigration
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	authmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/auth/middlewares"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core"
+	impl "github.com/PureMLHQ/PureML/packages/purebackend/core/daos/datastore"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/models"
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/trash"
+	orgmiddlewares "github.com/PureMLHQ/PureML/packages/purebackend/user_org/middlewares"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BindTrashApi registers the trash api endpoints and the corresponding handlers.
+func BindTrashApi(app core.App, rg *echo.Group) {
+	api := Api{app: app}
+
+	trashGroup := rg.Group("/org/:orgId/trash", authmiddlewares.RequireAuthContext, orgmiddlewares.ValidateOrg(api.app))
+	trashGroup.GET("", api.DefaultHandler(GetOrgTrash))
+	trashGroup.POST("/:itemId/restore", api.DefaultHandler(RestoreTrashItem))
+	trashGroup.DELETE("/:itemId/delete", api.DefaultHandler(PurgeTrashItem))
+}
+
+// GetOrgTrash godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Get the trash of organization
+//	@Description	Get the deleted models, datasets, branches and versions of organization, latest deleted first, with when they are purged
+//	@Tags			Trash
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/trash [get]
+//	@Param			orgId	path	string	true	"Organization Id"
+func (api *Api) GetOrgTrash(request *models.Request) *models.Response {
+	items, err := api.app.Dao().GetOrgTrash(request.GetOrgId())
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, items, "Trash items")
+}
+
+// RestoreTrashItem godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Restore a trash item
+//	@Description	Restore a deleted model, dataset, branch or version with everything deleted along with it. The model, dataset or branch of the item must be restored first
+//	@Tags			Trash
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/trash/{itemId}/restore [post]
+//	@Param			orgId	path	string	true	"Organization Id"
+//	@Param			itemId	path	string	true	"Trash Item Id"
+func (api *Api) RestoreTrashItem(request *models.Request) *models.Response {
+	item, errresp := api.getTrashItem(request)
+	if errresp != nil {
+		return errresp
+	}
+	err := api.app.Dao().RestoreTrashItem(item.UUID)
+	if errors.Is(err, impl.ErrTrashedParent) {
+		return models.NewErrorResponse(http.StatusConflict, "The model, dataset or branch of the item is in the trash, restore it first")
+	}
+	if err != nil {
+		return models.NewServerErrorResponse(err)
+	}
+	return models.NewDataResponse(http.StatusOK, item, "Trash item restored")
+}
+
+// PurgeTrashItem godoc
+//
+//	@Security		ApiKeyAuth
+//	@Summary		Permanently delete a trash item
+//	@Description	Permanently delete a model, dataset, branch or version from the trash and remove its files from the storage. Only organization owners can permanently delete an item
+//	@Tags			Trash
+//	@Accept			*/*
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}
+//	@Router			/org/{orgId}/trash/{itemId}/delete [delete]
+//	@Param			orgId	path	string	true	"Organization Id"
+//	@Param			itemId	path	string	true	"Trash Item Id"
+func (api *Api) PurgeTrashItem(request *models.Request) *models.Response {
+	if errresp := api.validateOrgOwner(request.GetOrgId(), request.GetUserUUID(), "You are not authorized to permanently delete the trash items of this organization"); errresp != nil {
+		return errresp
+	}
+	item, errresp := api.getTrashItem(request)
+	if errresp != nil {
+		return errresp
+	}
+	errs := trash.Purge(api.app, item)
+	if len(errs) > 0 {
+		return models.NewServerErrorResponse(errs[0])
+	}
+	return models.NewDataResponse(http.StatusOK, item, "Trash item permanently deleted")
+}
+
+func (api *Api) getTrashItem(request *models.Request) (*models.TrashItemResponse, *models.Response) {
+	itemUUID, err := uuid.FromString(request.GetPathParam("itemId"))
+	if err != nil {
+		return nil, models.NewErrorResponse(http.StatusBadRequest, "Invalid trash item id")
+	}
+	item, err := api.app.Dao().GetTrashItem(request.GetOrgId(), itemUUID)
+	if err != nil {
+		return nil, models.NewServerErrorResponse(err)
+	}
+	if item == nil {
+		return nil, models.NewErrorResponse(http.StatusNotFound, "Trash item not found")
+	}
+	return item, nil
+}
+
+var GetOrgTrash ServiceFunc = (*Api).GetOrgTrash
+var RestoreTrashItem ServiceFunc = (*Api).RestoreTrashItem
+var PurgeTrashItem ServiceFunc = (*Api).PurgeTrashItem
@@ -8,6 +8,7 @@ import (
 
 	"github.com/PureMLHQ/PureML/packages/purebackend/test"
 	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
 )
 
 func TestGetOrgByHandle(t *testing.T) {
@@ -631,3 +632,255 @@ func TestUpdateOrg(t *testing.T) {
 		scenario.Test(t)
 	}
 }
+
+func TestGetOrgStorageUsage(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "get org storage usage + unauthorized",
+			Method:         http.MethodGet,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/usage",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "get org storage usage + valid token + not member",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/usage",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`You are not a member of this organization`,
+			},
+		},
+		{
+			Name:   "get org storage usage + valid token + usage per model",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/usage",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				modelUUID := uuid.Must(uuid.FromString("11111111-1111-1111-1111-111111111111"))
+				err := app.Dao().RecordModelStorageUsage(test.ValidAdminUserOrgUuid, modelUUID, uuid.NullUUID{}, "LOCAL", "", "model-registry/stored", 8)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":200`,
+				`"used_bytes":8`,
+				`"soft_quota":0`,
+				`"hard_quota":0`,
+				`"models":[{"uuid":"11111111-1111-1111-1111-111111111111","name":"Demo Model","used_bytes":8}]`,
+				`"datasets":[]`,
+				`"message":"Organization storage usage"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestUpdateOrgStorageQuota(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "update org storage quota + unauthorized",
+			Method:         http.MethodPost,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/quota",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "update org storage quota + valid token + org not found",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidNoOrgUuid.String() + "/quota",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"soft_quota":100,"hard_quota":200}`),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Organization not found"`,
+			},
+		},
+		{
+			Name:   "update org storage quota + valid token + not owner",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/quota",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			Body:           strings.NewReader(`{"soft_quota":100,"hard_quota":200}`),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to update the storage quota of this organization"`,
+			},
+		},
+		{
+			Name:   "update org storage quota + valid token + soft quota above hard quota",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidUserOrgUuid.String() + "/quota",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			Body:           strings.NewReader(`{"soft_quota":300,"hard_quota":200}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Soft quota cannot exceed the hard quota"`,
+			},
+		},
+		{
+			Name:   "update org storage quota + valid token + owner",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidUserOrgUuid.String() + "/quota",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			Body:           strings.NewReader(`{"soft_quota":100,"hard_quota":200}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"org_uuid":"` + test.ValidUserOrgUuid.String() + `"`,
+				`"soft_quota":100`,
+				`"hard_quota":200`,
+				`"message":"Organization storage quota updated"`,
+			},
+		},
+		{
+			Name:   "update org storage quota + valid token + admin not member",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidUserOrgUuid.String() + "/quota",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"hard_quota":1024}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"soft_quota":0`,
+				`"hard_quota":1024`,
+				`"message":"Organization storage quota updated"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestOrgEncryption(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "get org encryption + unauthorized",
+			Method:         http.MethodGet,
+			Url:            "/api/org/" + test.ValidUserOrgUuid.String() + "/encryption",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "get org encryption + valid token",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidUserOrgUuid.String() + "/encryption",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"enabled":false`,
+				`"key_version":1`,
+				`"message":"Organization encryption"`,
+			},
+		},
+		{
+			Name:   "update org encryption + valid token + not owner",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/encryption",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if _, err := app.Dao().CreateUserOrganizationFromEmailAndOrgId("notadmin@aztlan.in", test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"enabled":true}`),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to manage the encryption of this organization"`,
+			},
+		},
+		{
+			Name:   "update org encryption + valid token + not configured",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidUserOrgUuid.String() + "/encryption",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			Body:           strings.NewReader(`{"enabled":true}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Encryption at rest is not configured for this instance"`,
+			},
+		},
+		{
+			Name:   "update org encryption + valid token + owner",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidUserOrgUuid.String() + "/encryption",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				app.Settings().Encryption.MasterKey = "test-master-key"
+			},
+			Body:           strings.NewReader(`{"enabled":true}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"enabled":true`,
+				`"message":"Organization encryption updated"`,
+			},
+		},
+		{
+			Name:   "rotate org encryption key + valid token + not configured",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidUserOrgUuid.String() + "/encryption/rotate",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Encryption at rest is not configured for this instance"`,
+			},
+		},
+		{
+			Name:   "rotate org encryption key + valid token + owner",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidUserOrgUuid.String() + "/encryption/rotate",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				app.Settings().Encryption.MasterKey = "test-master-key"
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"key_version":2`,
+				`"rewrapped_keys":0`,
+				`"message":"Organization encryption key rotated"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,356 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	commonmodels "github.com/PureMLHQ/PureML/packages/purebackend/core/common/models"
+	impl "github.com/PureMLHQ/PureML/packages/purebackend/core/daos/datastore"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	migrationDemoModelUuid          = uuid.Must(uuid.FromString("11111111-1111-1111-1111-111111111111"))
+	migrationDemoModelDevBranchUuid = uuid.Must(uuid.FromString("22222222-2222-2222-2222-222222222222"))
+	migrationModelContent           = "model-content"
+	migrationLogContent             = "log-content"
+	migrationBlobKey                = impl.BlobKey(test.ValidAdminUserOrgUuid, sha256Hex(migrationModelContent))
+	migrationLogKey                 = fmt.Sprintf("model-registry/%s/models/%s/logs/metrics.json", test.ValidAdminUserOrgUuid, migrationDemoModelUuid)
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// seedMigrationModel registers a LOCAL blob backed version (v2) with a
+// file log on the Demo Model dev branch. The log file is only stored when
+// storeLog is set.
+func seedMigrationModel(t *testing.T, app *test.TestApp, storeLog bool) uuid.UUID {
+	fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err := fs.Upload([]byte(migrationModelContent), migrationBlobKey); err != nil {
+		t.Fatal(err)
+	}
+	if storeLog {
+		if err := fs.Upload([]byte(migrationLogContent), migrationLogKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	digest := sha256Hex(migrationModelContent)
+	blob, _, err := app.Dao().AcquireBlob(test.ValidAdminUserOrgUuid, "LOCAL", "", digest, int64(len(migrationModelContent)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Dao().CompleteBlob(blob.UUID); err != nil {
+		t.Fatal(err)
+	}
+	version, err := app.Dao().RegisterModelFile(migrationDemoModelDevBranchUuid, "LOCAL", "", blob.Key, false, digest, "sha256", digest, "", uuid.NullUUID{UUID: blob.UUID, Valid: true}, nil, test.ValidAdminUserUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Dao().CreateLogForModelVersion("metrics", "/"+migrationLogKey, version.UUID); err != nil {
+		t.Fatal(err)
+	}
+	return version.UUID
+}
+
+func localFileExists(app *test.TestApp, key string) bool {
+	_, err := os.Stat(filepath.Join(app.LocalStorageDir(), key))
+	return err == nil
+}
+
+func TestCreateStorageMigration(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+	// the copies of a migration are kept on its target, so the failing
+	// migration needs an empty one
+	emptyS3 := test.NewS3Server()
+	defer emptyS3.Close()
+
+	migrationUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/storage/migration"
+	body := `{"source":"LOCAL","target":"` + test.TestS3SecretName + `","models":["Demo Model"]}`
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "create storage migration + unauthorized",
+			Method:         http.MethodPost,
+			Url:            migrationUrl,
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "create storage migration + valid token + not owner",
+			Method: http.MethodPost,
+			Url:    migrationUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if _, err := app.Dao().CreateUserOrganizationFromEmailAndOrgId("notadmin@aztlan.in", test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(body),
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to migrate the storage of this organization"`,
+			},
+		},
+		{
+			Name:   "create storage migration + valid token + same storage",
+			Method: http.MethodPost,
+			Url:    migrationUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(`{"source":"LOCAL","target":"local","models":["Demo Model"]}`),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Source and target storages must be different"`,
+			},
+		},
+		{
+			Name:   "create storage migration + valid token + target not connected",
+			Method: http.MethodPost,
+			Url:    migrationUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			Body:           strings.NewReader(body),
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Source ` + test.TestS3SecretName + ` not connected properly to organization"`,
+			},
+		},
+		{
+			Name:   "create storage migration + valid token + model not found",
+			Method: http.MethodPost,
+			Url:    migrationUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"source":"LOCAL","target":"` + test.TestS3SecretName + `","models":["Missing Model"]}`),
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Model Missing Model not found"`,
+			},
+		},
+		{
+			Name:   "create storage migration + valid token + copied",
+			Method: http.MethodPost,
+			Url:    migrationUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedMigrationModel(t, app, true)
+				if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(body),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":"copied"`,
+				`"kind":"model_version"`,
+				`"kind":"log"`,
+				`"target_key":"` + migrationBlobKey + `"`,
+				`"target_key":"` + migrationLogKey + `"`,
+				`"message":"Storage migration copied, confirm it to delete the source files"`,
+			},
+			NotExpectedContent: []string{
+				`"status":"failed"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetModelBranchVersion(migrationDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version.SourceType != "S3" || version.Path != s3.URL+"/"+test.TestS3BucketName+"/"+migrationBlobKey {
+					t.Fatalf("Expected version to point to the S3 blob, got %s %s", version.SourceType, version.Path)
+				}
+				logs, err := app.Dao().GetLogForModelVersion(version.UUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(logs) != 1 || logs[0].Data != s3.URL+"/"+test.TestS3BucketName+"/"+migrationLogKey {
+					t.Fatalf("Expected log to point to the S3 file, got %v", logs)
+				}
+				if content, ok := s3.Object(test.TestS3BucketName, migrationBlobKey); !ok || string(content) != migrationModelContent {
+					t.Fatalf("Expected migrated blob content %q, got %q", migrationModelContent, content)
+				}
+				if content, ok := s3.Object(test.TestS3BucketName, migrationLogKey); !ok || string(content) != migrationLogContent {
+					t.Fatalf("Expected migrated log content %q, got %q", migrationLogContent, content)
+				}
+				if !localFileExists(app, migrationBlobKey) || !localFileExists(app, migrationLogKey) {
+					t.Fatal("Expected source files to be kept until the migration is confirmed")
+				}
+			},
+		},
+		{
+			Name:   "create storage migration + valid token + digest mismatch",
+			Method: http.MethodPost,
+			Url:    migrationUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedMigrationModel(t, app, true)
+				if err := os.WriteFile(filepath.Join(app.LocalStorageDir(), migrationBlobKey), []byte("corrupted"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := emptyS3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(body),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"status":"failed"`,
+				`"error":"1 of 2 files failed to migrate"`,
+				`digest mismatch`,
+				`"message":"Storage migration failed, resume it to retry the failed files"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetModelBranchVersion(migrationDemoModelDevBranchUuid, "v2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version.SourceType != "LOCAL" {
+					t.Fatalf("Expected version to stay on LOCAL storage, got %s", version.SourceType)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+// serveMigrationAction posts the action (resume or confirm) of the only
+// migration of the admin org.
+func serveMigrationAction(t *testing.T, app *test.TestApp, e *echo.Echo, action string) (int, string) {
+	migrations, err := app.Dao().GetOrgStorageMigrations(test.ValidAdminUserOrgUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 1 {
+		t.Fatalf("Expected 1 storage migration, got %d", len(migrations))
+	}
+	url := fmt.Sprintf("/api/org/%s/storage/migration/%s/%s", test.ValidAdminUserOrgUuid, migrations[0].UUID, action)
+	req := httptest.NewRequest(http.MethodPost, url, nil)
+	req.Header.Set("Authorization", test.ValidAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestResumeStorageMigration(t *testing.T) {
+	s3 := test.NewS3Server()
+	defer s3.Close()
+
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "resume storage migration + valid token + invalid migration id",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/storage/migration/invalid/resume",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 400,
+			ExpectedContent: []string{
+				`"message":"Invalid migration id"`,
+			},
+		},
+		{
+			Name:   "resume storage migration + valid token + migration not found",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/storage/migration/" + uuid.NewV4().String() + "/resume",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Storage migration not found"`,
+			},
+		},
+		{
+			Name:   "resume storage migration + valid token + missing file restored",
+			Method: http.MethodPost,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/storage/migration",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedMigrationModel(t, app, false)
+				if err := s3.CreateSecret(app, test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Body:           strings.NewReader(`{"source":"LOCAL","target":"` + test.TestS3SecretName + `","models":["Demo Model"]}`),
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"error":"1 of 2 files failed to migrate"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				// confirming deletes nothing while files are left to copy
+				if code, body := serveMigrationAction(t, app, e, "confirm"); code != 400 || !strings.Contains(body, `"message":"Storage migration has files left to copy"`) {
+					t.Fatalf("Expected early confirm to fail, got %d %s", code, body)
+				}
+
+				fs, err := app.NewFilesystem(&commonmodels.SourceSecrets{SourceType: "LOCAL"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer fs.Close()
+				if err := fs.Upload([]byte(migrationLogContent), migrationLogKey); err != nil {
+					t.Fatal(err)
+				}
+				if code, body := serveMigrationAction(t, app, e, "resume"); code != 200 || !strings.Contains(body, `"message":"Storage migration copied, confirm it to delete the source files"`) {
+					t.Fatalf("Expected resumed migration to be copied, got %d %s", code, body)
+				}
+				if content, ok := s3.Object(test.TestS3BucketName, migrationLogKey); !ok || string(content) != migrationLogContent {
+					t.Fatalf("Expected migrated log content %q, got %q", migrationLogContent, content)
+				}
+
+				code, body := serveMigrationAction(t, app, e, "confirm")
+				if code != 200 || !strings.Contains(body, `"message":"Storage migration completed"`) {
+					t.Fatalf("Expected migration to be completed, got %d %s", code, body)
+				}
+				if localFileExists(app, migrationBlobKey) || localFileExists(app, migrationLogKey) {
+					t.Fatal("Expected source files to be deleted once the migration is confirmed")
+				}
+				if _, ok := s3.Object(test.TestS3BucketName, migrationBlobKey); !ok {
+					t.Fatal("Expected migrated blob to be kept")
+				}
+				if code, body := serveMigrationAction(t, app, e, "resume"); code != 400 || !strings.Contains(body, `"message":"Storage migration is already completed"`) {
+					t.Fatalf("Expected completed migration not to be resumed, got %d %s", code, body)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
@@ -0,0 +1,280 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PureMLHQ/PureML/packages/purebackend/core/dbmodels"
+	"github.com/PureMLHQ/PureML/packages/purebackend/test"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+var validTrashItemUuid = uuid.Must(uuid.FromString("33333333-3333-3333-3333-333333333333"))
+
+// trashDemoModelVersion moves the version of the Demo Model dev branch to
+// the trash as validTrashItemUuid.
+func trashDemoModelVersion(t *testing.T, app *test.TestApp, version string) {
+	modelVersion, err := app.Dao().GetModelBranchVersion(migrationDemoModelDevBranchUuid, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := app.Dao().TrashModelVersion(test.ValidAdminUserOrgUuid, modelVersion.UUID, "Demo Model/dev/"+version, test.ValidAdminUserUuid, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Dao().Datastore().DB.Model(&dbmodels.TrashItem{}).Where("uuid = ?", item.UUID).Update("uuid", validTrashItemUuid).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetOrgTrash(t *testing.T) {
+	scenarios := []test.ApiScenario{
+		{
+			Name:           "get org trash + unauthorized",
+			Method:         http.MethodGet,
+			Url:            "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/trash",
+			ExpectedStatus: 401,
+			ExpectedContent: []string{
+				`Authentication token required`,
+			},
+		},
+		{
+			Name:   "get org trash + valid token",
+			Method: http.MethodGet,
+			Url:    "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/trash",
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				trashDemoModelVersion(t, app, "v1")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"uuid":"` + validTrashItemUuid.String() + `"`,
+				`"kind":"model_version"`,
+				`"name":"Demo Model/dev/v1"`,
+				`"purge_at":`,
+				`"message":"Trash items"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestRestoreTrashItem(t *testing.T) {
+	restoreUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/trash/" + validTrashItemUuid.String() + "/restore"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "restore trash item + valid token + not found",
+			Method: http.MethodPost,
+			Url:    restoreUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			ExpectedStatus: 404,
+			ExpectedContent: []string{
+				`"message":"Trash item not found"`,
+			},
+		},
+		{
+			Name:   "restore trash item + valid token",
+			Method: http.MethodPost,
+			Url:    restoreUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				trashDemoModelVersion(t, app, "v1")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"Demo Model/dev/v1"`,
+				`"message":"Trash item restored"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				version, err := app.Dao().GetModelBranchVersion(migrationDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if version == nil {
+					t.Fatal("Expected the version to be restored")
+				}
+				items, err := app.Dao().GetOrgTrash(test.ValidAdminUserOrgUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(items) != 0 {
+					t.Fatalf("Expected the trash to be empty, got %+v", items)
+				}
+			},
+		},
+		{
+			Name:   "restore trash item + valid token + branch in trash",
+			Method: http.MethodPost,
+			Url:    restoreUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				trashDemoModelVersion(t, app, "v1")
+				if _, err := app.Dao().TrashModelBranch(test.ValidAdminUserOrgUuid, migrationDemoModelDevBranchUuid, "Demo Model/dev", test.ValidAdminUserUuid, time.Now().Add(time.Hour)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 409,
+			ExpectedContent: []string{
+				`"message":"The model, dataset or branch of the item is in the trash, restore it first"`,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
+
+func TestPurgeTrashItem(t *testing.T) {
+	purgeUrl := "/api/org/" + test.ValidAdminUserOrgUuid.String() + "/trash/" + validTrashItemUuid.String() + "/delete"
+	scenarios := []test.ApiScenario{
+		{
+			Name:   "purge trash item + member token",
+			Method: http.MethodDelete,
+			Url:    purgeUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidUserToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if _, err := app.Dao().CreateUserOrganizationFromEmailAndOrgId("notadmin@aztlan.in", test.ValidAdminUserOrgUuid); err != nil {
+					t.Fatal(err)
+				}
+				trashDemoModelVersion(t, app, "v1")
+			},
+			ExpectedStatus: 403,
+			ExpectedContent: []string{
+				`"message":"You are not authorized to permanently delete the trash items of this organization"`,
+			},
+		},
+		{
+			Name:   "purge trash item + valid token",
+			Method: http.MethodDelete,
+			Url:    purgeUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedMigrationModel(t, app, false)
+				trashDemoModelVersion(t, app, "v2")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"Demo Model/dev/v2"`,
+				`"message":"Trash item permanently deleted"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if localFileExists(app, migrationBlobKey) {
+					t.Fatal("Expected the blob of the version to be removed from the storage")
+				}
+				item, err := app.Dao().GetTrashItem(test.ValidAdminUserOrgUuid, validTrashItemUuid)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item != nil {
+					t.Fatal("Expected the trash item to be removed")
+				}
+			},
+		},
+		{
+			Name:   "purge trash item + valid token + log file shared with a copy",
+			Method: http.MethodDelete,
+			Url:    purgeUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				versionUUID := seedMigrationModel(t, app, true)
+				if err := app.Dao().RecordModelStorageUsage(test.ValidAdminUserOrgUuid, migrationDemoModelUuid, uuid.NullUUID{UUID: versionUUID, Valid: true}, "LOCAL", "", migrationLogKey, int64(len(migrationLogContent))); err != nil {
+					t.Fatal(err)
+				}
+				// v1 shares the log like the copy of a merged version
+				copyVersion, err := app.Dao().GetModelBranchVersion(migrationDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := app.Dao().CreateLogForModelVersion("metrics", "/"+migrationLogKey, copyVersion.UUID); err != nil {
+					t.Fatal(err)
+				}
+				trashDemoModelVersion(t, app, "v2")
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"name":"Demo Model/dev/v2"`,
+				`"message":"Trash item permanently deleted"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if !localFileExists(app, migrationLogKey) {
+					t.Fatal("Expected the log file still referenced by v1 to be kept")
+				}
+				copyVersion, err := app.Dao().GetModelBranchVersion(migrationDemoModelDevBranchUuid, "v1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				var usage dbmodels.StorageUsage
+				if err := app.Dao().Datastore().DB.Where("key = ?", migrationLogKey).First(&usage).Error; err != nil {
+					t.Fatal(err)
+				}
+				if usage.ModelVersionUUID.UUID != copyVersion.UUID {
+					t.Fatal("Expected the usage of the log file to be handed over to v1")
+				}
+			},
+		},
+		{
+			Name:   "purge trash item + valid token + model",
+			Method: http.MethodDelete,
+			Url:    purgeUrl,
+			RequestHeaders: map[string]string{
+				"Authorization": test.ValidAdminToken,
+			},
+			BeforeTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				seedMigrationModel(t, app, false)
+				trashDemoModelVersion(t, app, "v2")
+				item, err := app.Dao().TrashModel(test.ValidAdminUserOrgUuid, migrationDemoModelUuid, "Demo Model", test.ValidAdminUserUuid, time.Now().Add(time.Hour))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the version is purged along with its model
+				if err := app.Dao().Datastore().DB.Unscoped().Delete(&dbmodels.TrashItem{}, "uuid = ?", validTrashItemUuid).Error; err != nil {
+					t.Fatal(err)
+				}
+				if err := app.Dao().Datastore().DB.Model(&dbmodels.TrashItem{}).Where("uuid = ?", item.UUID).Update("uuid", validTrashItemUuid).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			ExpectedStatus: 200,
+			ExpectedContent: []string{
+				`"kind":"model"`,
+				`"message":"Trash item permanently deleted"`,
+			},
+			AfterTestFunc: func(t *testing.T, app *test.TestApp, e *echo.Echo) {
+				if localFileExists(app, migrationBlobKey) {
+					t.Fatal("Expected the blob of the model to be removed from the storage")
+				}
+				var count int64
+				if err := app.Dao().Datastore().DB.Unscoped().Table("model_versions").Where("branch_uuid = ?", migrationDemoModelDevBranchUuid).Count(&count).Error; err != nil {
+					t.Fatal(err)
+				}
+				if count != 0 {
+					t.Fatalf("Expected the versions of the model to be removed, got %d", count)
+				}
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Test(t)
+	}
+}
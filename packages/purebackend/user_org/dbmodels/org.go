@@ -12,6 +12,10 @@ type Organization struct {
 	Description              string `json:"description"`
 	APITokenHash             string `json:"api_token_hash"`
 	JoinCode                 string `json:"join_code" gorm:"not null"`
+	StorageSoftQuota         int64  `json:"storage_soft_quota" gorm:"not null;default:0"`
+	StorageHardQuota         int64  `json:"storage_hard_quota" gorm:"not null;default:0"`
+	EncryptionEnabled        bool   `json:"encryption_enabled" gorm:"not null;default:false"`
+	EncryptionKeyVersion     int    `json:"encryption_key_version" gorm:"not null;default:1"`
 
 	Users   []User   `gorm:"many2many:user_organizations;"` // many to many
 	Secrets []Secret `gorm:"foreignKey:OrgUUID"`
@@ -19,6 +19,15 @@ type UpdateOrgRequest struct {
 	Avatar      string `json:"avatar"`
 }
 
+type UpdateOrgStorageQuotaRequest struct {
+	SoftQuota int64 `json:"soft_quota"`
+	HardQuota int64 `json:"hard_quota"`
+}
+
+type UpdateOrgEncryptionRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
 // Response models
 
 type OrganizationHandleResponse struct {